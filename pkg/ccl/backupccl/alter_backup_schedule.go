@@ -295,6 +295,18 @@ func processScheduleOptions(
 			// Until then this interception will have to do.
 			incDetails.Wait = jobspb.ScheduleDetails_WAIT
 			s.incJob.SetScheduleDetails(*incDetails)
+		case optAvoidNodeStates:
+			if err := schedulebase.ParseAvoidNodeStates(v, fullDetails); err != nil {
+				return err
+			}
+			s.fullJob.SetScheduleDetails(*fullDetails)
+			if incDetails == nil {
+				continue
+			}
+			if err := schedulebase.ParseAvoidNodeStates(v, incDetails); err != nil {
+				return err
+			}
+			s.incJob.SetScheduleDetails(*incDetails)
 		case optUpdatesLastBackupMetric:
 			// NB: as of 20.2, schedule creation requires admin so this is duplicative
 			// but in the future we might relax so you can schedule anything that you
@@ -671,6 +683,7 @@ var alterBackupScheduleOptions = exprutil.KVOptionValidationMap{
 	optOnExecFailure:           exprutil.KVStringOptAny,
 	optOnPreviousRunning:       exprutil.KVStringOptAny,
 	optUpdatesLastBackupMetric: exprutil.KVStringOptAny,
+	optAvoidNodeStates:         exprutil.KVStringOptAny,
 }
 
 func alterBackupScheduleTypeCheck(
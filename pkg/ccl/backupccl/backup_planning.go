@@ -31,6 +31,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/jobs/jobsprotectedts"
 	"github.com/cockroachdb/cockroach/pkg/keys"
 	"github.com/cockroachdb/cockroach/pkg/kv"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/protectedts"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/protectedts/ptpb"
 	"github.com/cockroachdb/cockroach/pkg/multitenant/mtinfopb"
@@ -307,6 +308,9 @@ func backupJobDescription(
 type annotatedBackupStatement struct {
 	*tree.Backup
 	*jobs.CreatedByInfo
+	// avoidNodeStates are the lifecycle states of the nodes the backup
+	// shouldn't run on, as declared by the schedule running it.
+	avoidNodeStates []livenesspb.NodeLifecycleState
 }
 
 func getBackupStatement(stmt tree.Statement) *annotatedBackupStatement {
@@ -767,6 +771,7 @@ func backupPlanHook(
 		if backupStmt.CreatedByInfo != nil && backupStmt.CreatedByInfo.Name == jobs.CreatedByScheduledJobs {
 			initialDetails.ScheduleID = backupStmt.CreatedByInfo.ID
 		}
+		initialDetails.AvoidNodeStates = backupStmt.avoidNodeStates
 
 		// For backups of specific targets, those targets were resolved with this
 		// planner's session, so we need to store the result of resolution. For
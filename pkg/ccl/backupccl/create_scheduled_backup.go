@@ -43,6 +43,7 @@ const (
 	optOnPreviousRunning       = "on_previous_running"
 	optIgnoreExistingBackups   = "ignore_existing_backups"
 	optUpdatesLastBackupMetric = "updates_cluster_last_backup_time_metric"
+	optAvoidNodeStates         = "avoid_node_states"
 )
 
 var scheduledBackupOptionExpectValues = map[string]exprutil.KVStringOptValidate{
@@ -51,6 +52,7 @@ var scheduledBackupOptionExpectValues = map[string]exprutil.KVStringOptValidate{
 	optOnPreviousRunning:       exprutil.KVStringOptRequireValue,
 	optIgnoreExistingBackups:   exprutil.KVStringOptRequireNoValue,
 	optUpdatesLastBackupMetric: exprutil.KVStringOptRequireNoValue,
+	optAvoidNodeStates:         exprutil.KVStringOptRequireValue,
 }
 
 // scheduledBackupGCProtectionEnabled is used to enable and disable the chaining
@@ -101,6 +103,12 @@ func makeScheduleDetails(opts map[string]string) (jobspb.ScheduleDetails, error)
 			return details, err
 		}
 	}
+
+	if v, ok := opts[optAvoidNodeStates]; ok {
+		if err := schedulebase.ParseAvoidNodeStates(v, &details); err != nil {
+			return details, err
+		}
+	}
 	return details, nil
 }
 
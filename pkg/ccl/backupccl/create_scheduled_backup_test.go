@@ -26,6 +26,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/jobs"
 	"github.com/cockroachdb/cockroach/pkg/jobs/jobspb"
 	"github.com/cockroachdb/cockroach/pkg/jobs/jobstest"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/protectedts"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/scheduledjobs"
@@ -805,6 +806,35 @@ INSERT INTO t1 values (1), (10), (100);
 	})
 }
 
+// TestScheduledBackupAvoidNodeStates tests that the node lifecycle states a
+// backup schedule declares to avoid are recorded on both the full and the
+// incremental schedules, and passed on to the backups they run.
+func TestScheduledBackupAvoidNodeStates(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	th, cleanup := newTestHelper(t)
+	defer cleanup()
+
+	schedule := `CREATE SCHEDULE FOR BACKUP INTO $1 RECURRING '@hourly' WITH SCHEDULE OPTIONS avoid_node_states = 'draining,suspect'`
+	schedules, err := th.createBackupSchedule(t, schedule, "nodelocal://1/backup/")
+	require.NoError(t, err)
+	require.Len(t, schedules, 2)
+	expected := []livenesspb.NodeLifecycleState{
+		livenesspb.NodeLifecycleState_DRAINING, livenesspb.NodeLifecycleState_SUSPECT,
+	}
+	for _, sj := range schedules {
+		require.Equal(t, expected, sj.ScheduleDetails().AvoidNodeStates)
+		stmt, err := extractBackupStatement(sj)
+		require.NoError(t, err)
+		require.Equal(t, expected, stmt.avoidNodeStates)
+	}
+
+	schedule = `CREATE SCHEDULE FOR BACKUP INTO $1 RECURRING '@hourly' WITH SCHEDULE OPTIONS avoid_node_states = 'bogus'`
+	_, err = th.createBackupSchedule(t, schedule, "nodelocal://1/backup/")
+	require.Regexp(t, "is not a valid avoid_node_states", err)
+}
+
 func TestScheduleBackup(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 	defer log.Scope(t).Close(t)
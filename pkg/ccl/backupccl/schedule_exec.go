@@ -282,6 +282,12 @@ func (e *scheduledBackupExecutor) GetCreateScheduleStatement(
 			Value: tree.NewDString(wait),
 		},
 	}
+	if avoid := sj.ScheduleDetails().AvoidNodeStates; len(avoid) > 0 {
+		scheduleOptions = append(scheduleOptions, tree.KVOption{
+			Key:   optAvoidNodeStates,
+			Value: tree.NewDString(schedulebase.ParseAvoidNodeStatesOption(avoid)),
+		})
+	}
 
 	var destinations []string
 	for i := range backupNode.To {
@@ -406,6 +412,7 @@ func extractBackupStatement(sj *jobs.ScheduledJob) (*annotatedBackupStatement, e
 				Name: jobs.CreatedByScheduledJobs,
 				ID:   sj.ScheduleID(),
 			},
+			avoidNodeStates: sj.ScheduleDetails().AvoidNodeStates,
 		}, nil
 	}
 
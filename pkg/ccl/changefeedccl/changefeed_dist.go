@@ -13,6 +13,7 @@ import (
 	"sort"
 	"time"
 
+	"github.com/cockroachdb/cockroach/pkg/base"
 	"github.com/cockroachdb/cockroach/pkg/ccl/changefeedccl/cdceval"
 	"github.com/cockroachdb/cockroach/pkg/ccl/changefeedccl/changefeedbase"
 	"github.com/cockroachdb/cockroach/pkg/ccl/changefeedccl/kvfeed"
@@ -389,6 +390,8 @@ func makePlan(
 		if err != nil {
 			return nil, nil, err
 		}
+		spanPartitions = avoidIneligibleNodes(
+			spanPartitions, dsp.GatewayID(), execCtx.ExecCfg().JobRegistry.IsEligibleCoordinator)
 
 		cfKnobs := execCtx.ExecCfg().DistSQLSrv.TestingKnobs.Changefeed
 		if knobs, ok := cfKnobs.(*TestingKnobs); ok && knobs != nil &&
@@ -549,6 +552,45 @@ var rebalanceThreshold = settings.RegisterFloatSetting(
 	settings.PositiveFloat,
 )
 
+// avoidIneligibleNodes moves the spans of the partitions assigned to nodes
+// that aren't fit to run long-lived changefeed aggregators, because they are
+// draining, decommissioning or suspect, to the partition of the gateway,
+// which coordinates the changefeed. The DistSQL planner already avoids nodes
+// that are unavailable, but such nodes may still be chosen and would only
+// have the feed replanned shortly after.
+func avoidIneligibleNodes(
+	p []sql.SpanPartition, gateway base.SQLInstanceID, isEligible func(base.SQLInstanceID) bool,
+) []sql.SpanPartition {
+	var moved roachpb.Spans
+	res := p[:0]
+	for _, part := range p {
+		if part.SQLInstanceID != gateway && !isEligible(part.SQLInstanceID) {
+			moved = append(moved, part.Spans...)
+			continue
+		}
+		res = append(res, part)
+	}
+	if len(moved) == 0 {
+		return res
+	}
+	for i := range res {
+		if res[i].SQLInstanceID == gateway {
+			var g roachpb.SpanGroup
+			g.Add(res[i].Spans...)
+			g.Add(moved...)
+			res[i].Spans = g.Slice()
+			return res
+		}
+	}
+	var g roachpb.SpanGroup
+	g.Add(moved...)
+	res = append(res, sql.SpanPartition{SQLInstanceID: gateway, Spans: g.Slice()})
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].SQLInstanceID < res[j].SQLInstanceID
+	})
+	return res
+}
+
 type rangeResolver interface {
 	getRangesForSpans(ctx context.Context, spans []roachpb.Span) ([]roachpb.Span, error)
 }
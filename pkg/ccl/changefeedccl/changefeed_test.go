@@ -8483,6 +8483,63 @@ func TestPartitionSpans(t *testing.T) {
 	}
 }
 
+func TestAvoidIneligibleNodes(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	mkPart := func(n base.SQLInstanceID, spans ...roachpb.Span) sql.SpanPartition {
+		return sql.SpanPartition{SQLInstanceID: n, Spans: spans}
+	}
+	mkSpan := func(start, end string) roachpb.Span {
+		return roachpb.Span{Key: []byte(start), EndKey: []byte(end)}
+	}
+	eligible := func(ids ...base.SQLInstanceID) func(base.SQLInstanceID) bool {
+		return func(id base.SQLInstanceID) bool {
+			for _, e := range ids {
+				if e == id {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	for i, tc := range []struct {
+		input    []sql.SpanPartition
+		gateway  base.SQLInstanceID
+		eligible func(base.SQLInstanceID) bool
+		expect   []sql.SpanPartition
+	}{
+		{
+			// All the nodes are eligible.
+			input:    []sql.SpanPartition{mkPart(1, mkSpan("a", "j")), mkPart(2, mkSpan("j", "z"))},
+			gateway:  1,
+			eligible: eligible(1, 2),
+			expect:   []sql.SpanPartition{mkPart(1, mkSpan("a", "j")), mkPart(2, mkSpan("j", "z"))},
+		},
+		{
+			// The spans of n2 move to the gateway.
+			input: []sql.SpanPartition{
+				mkPart(1, mkSpan("a", "j")), mkPart(2, mkSpan("j", "q")), mkPart(3, mkSpan("q", "z")),
+			},
+			gateway:  1,
+			eligible: eligible(1, 3),
+			expect:   []sql.SpanPartition{mkPart(1, mkSpan("a", "q")), mkPart(3, mkSpan("q", "z"))},
+		},
+		{
+			// The gateway has no partition yet, and keeps coordinating even if it
+			// is not eligible itself.
+			input:    []sql.SpanPartition{mkPart(2, mkSpan("a", "j")), mkPart(3, mkSpan("j", "z"))},
+			gateway:  1,
+			eligible: eligible(3),
+			expect:   []sql.SpanPartition{mkPart(1, mkSpan("a", "j")), mkPart(3, mkSpan("j", "z"))},
+		},
+	} {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			require.Equal(t, tc.expect, avoidIneligibleNodes(tc.input, tc.gateway, tc.eligible))
+		})
+	}
+}
+
 func TestChangefeedMetricsScopeNotice(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 
@@ -547,6 +547,18 @@ func TestTenantStreamingUnavailableStreamAddress(t *testing.T) {
 	c.DestSysSQL.Exec(t, `RESUME JOB $1`, ingestionJobID)
 	jobutils.WaitForJobToRun(t, c.DestSysSQL, jobspb.JobID(ingestionJobID))
 
+	// The source cluster reports its stopped node through the heartbeats of
+	// the stream, which the ingestion job records in its progress.
+	stoppedNodeID := c.SrcCluster.Server(0).NodeID()
+	testutils.SucceedsSoon(t, func() error {
+		progress := jobutils.GetJobProgress(c.T, c.DestSysSQL, jobspb.JobID(ingestionJobID))
+		if nodes := progress.GetStreamIngest().UnavailableSourceNodeIDs; len(nodes) != 1 ||
+			nodes[0] != stoppedNodeID {
+			return errors.Newf("unavailable source nodes %v", nodes)
+		}
+		return nil
+	})
+
 	alternateSrcTenantSQL.Exec(t, "CREATE TABLE d.x (id INT PRIMARY KEY, n INT)")
 	alternateSrcTenantSQL.Exec(t, `INSERT INTO d.x VALUES (3);`)
 
@@ -731,6 +743,73 @@ func TestTenantStreamingMultipleNodes(t *testing.T) {
 	require.Greater(t, len(clientAddresses), 1)
 }
 
+// TestTenantStreamingCutoverClearsSQLLiveness verifies that after cutover the
+// destination tenant doesn't inherit the SQL liveness sessions and instances of
+// the source tenant's SQL servers, whatever the shape of either cluster.
+func TestTenantStreamingCutoverClearsSQLLiveness(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	skip.UnderRace(t, "takes too long with multiple nodes")
+
+	for _, tc := range []struct {
+		name         string
+		srcNumNodes  int
+		destNumNodes int
+	}{
+		{name: "more-source-nodes", srcNumNodes: 3, destNumNodes: 1},
+		{name: "more-destination-nodes", srcNumNodes: 1, destNumNodes: 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			args := replicationtestutils.DefaultTenantStreamingClustersArgs
+			args.SrcNumNodes = tc.srcNumNodes
+			args.DestNumNodes = tc.destNumNodes
+
+			c, cleanup := replicationtestutils.CreateTenantStreamingClusters(ctx, t, args)
+			defer cleanup()
+
+			// Run a SQL server for the source tenant on every source node, so that
+			// the source has as many live SQL instances as it has nodes.
+			for i := 1; i < tc.srcNumNodes; i++ {
+				srcTenant, srcTenantConn := serverutils.StartTenant(t, c.SrcCluster.Server(i),
+					base.TestTenantArgs{TenantID: args.SrcTenantID, DisableCreateTenant: true})
+				defer srcTenant.Stopper().Stop(ctx)
+				defer srcTenantConn.Close()
+			}
+			c.SrcTenantSQL.CheckQueryResults(t,
+				`SELECT count(*) FROM system.sql_instances WHERE session_id IS NOT NULL`,
+				[][]string{{fmt.Sprint(tc.srcNumNodes)}})
+
+			producerJobID, ingestionJobID := c.StartStreamReplication(ctx)
+			jobutils.WaitForJobToRun(c.T, c.SrcSysSQL, jobspb.JobID(producerJobID))
+			jobutils.WaitForJobToRun(c.T, c.DestSysSQL, jobspb.JobID(ingestionJobID))
+
+			c.WaitUntilStartTimeReached(jobspb.JobID(ingestionJobID))
+			cutoverTime := c.DestSysServer.Clock().Now()
+			c.Cutover(producerJobID, ingestionJobID, cutoverTime.GoTime(), false)
+
+			cleanUpTenant := c.CreateDestTenantSQL(ctx)
+			defer func() {
+				require.NoError(t, cleanUpTenant())
+			}()
+
+			// The only session and claimed instance are those of the destination
+			// tenant's own SQL server.
+			c.DestTenantSQL.CheckQueryResults(t,
+				`SELECT count(*) FROM system.sqlliveness`, [][]string{{"1"}})
+			c.DestTenantSQL.CheckQueryResults(t, `
+SELECT count(*)
+  FROM system.sql_instances AS i
+  JOIN system.sqlliveness AS l ON i.session_id = l.session_id`,
+				[][]string{{"1"}})
+			c.DestTenantSQL.CheckQueryResults(t,
+				`SELECT count(*) FROM system.sql_instances WHERE session_id IS NOT NULL`,
+				[][]string{{"1"}})
+		})
+	}
+}
+
 // TestTenantReplicationProtectedTimestampManagement tests the active protected
 // timestamps management on the destination tenant's keyspan.
 func TestTenantReplicationProtectedTimestampManagement(t *testing.T) {
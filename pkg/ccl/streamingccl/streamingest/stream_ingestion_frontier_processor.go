@@ -31,6 +31,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
 	"github.com/cockroachdb/cockroach/pkg/util/span"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
 	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
 	"github.com/cockroachdb/errors"
 	"github.com/cockroachdb/redact"
@@ -166,6 +167,14 @@ type heartbeatSender struct {
 	cancel func()
 	// heartbeatSender closes this channel when it stops.
 	stoppedChan chan struct{}
+
+	mu struct {
+		syncutil.Mutex
+		// unavailableSourceNodes are the unavailable nodes of the source
+		// cluster as of the latest heartbeat, if sourceNodesKnown.
+		unavailableSourceNodes []roachpb.NodeID
+		sourceNodesKnown       bool
+	}
 }
 
 func newHeartbeatSender(
@@ -197,6 +206,22 @@ func (h *heartbeatSender) maybeHeartbeat(
 	return true, s, err
 }
 
+// setSourceNodes records the source cluster's nodes reported by a heartbeat.
+func (h *heartbeatSender) setSourceNodes(status streampb.StreamReplicationStatus) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.mu.unavailableSourceNodes = status.UnavailableSourceNodes()
+	h.mu.sourceNodesKnown = true
+}
+
+// unavailableSourceNodes returns the unavailable nodes of the source cluster as
+// of the latest heartbeat, and false if no heartbeat reported them yet.
+func (h *heartbeatSender) unavailableSourceNodes() ([]roachpb.NodeID, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.mu.unavailableSourceNodes, h.mu.sourceNodesKnown
+}
+
 func (h *heartbeatSender) startHeartbeatLoop(ctx context.Context) {
 	ctx, cancel := context.WithCancel(ctx)
 	h.cancel = cancel
@@ -210,6 +235,7 @@ func (h *heartbeatSender) startHeartbeatLoop(ctx context.Context) {
 				Get(&h.flowCtx.EvalCtx.Settings.SV))
 			defer timer.Stop()
 			unknownStreamStatusRetryErr := log.Every(1 * time.Minute)
+			unavailableSourceNodes := log.Every(1 * time.Minute)
 			for {
 				select {
 				case <-ctx.Done():
@@ -226,6 +252,15 @@ func (h *heartbeatSender) startHeartbeatLoop(ctx context.Context) {
 					return err
 				}
 
+				if sent && len(streamStatus.SourceNodes) > 0 {
+					h.setSourceNodes(streamStatus)
+					if nodes := streamStatus.UnavailableSourceNodes(); len(nodes) > 0 &&
+						unavailableSourceNodes.ShouldLog() {
+						log.Warningf(ctx, "replication stream %d source cluster reports unavailable nodes %v",
+							h.streamID, nodes)
+					}
+				}
+
 				if !sent || streamStatus.StreamStatus == streampb.StreamReplicationStatus_STREAM_ACTIVE {
 					continue
 				}
@@ -408,6 +443,7 @@ func (sf *streamIngestionFrontier) maybeUpdatePartitionProgress() error {
 
 	replicatedTime := f.Frontier()
 	partitionProgress := sf.partitionProgress
+	unavailableSourceNodes, sourceNodesKnown := sf.heartbeatSender.unavailableSourceNodes()
 
 	sf.lastPartitionUpdate = timeutil.Now()
 
@@ -422,6 +458,9 @@ func (sf *streamIngestionFrontier) maybeUpdatePartitionProgress() error {
 		streamProgress := progress.Details.(*jobspb.Progress_StreamIngest).StreamIngest
 		streamProgress.PartitionProgress = partitionProgress
 		streamProgress.Checkpoint.ResolvedSpans = frontierResolvedSpans
+		if sourceNodesKnown {
+			streamProgress.UnavailableSourceNodeIDs = unavailableSourceNodes
+		}
 
 		// Keep the recorded replicatedTime empty until some advancement has been made
 		if sf.replicatedTimeAtStart.Less(replicatedTime) {
@@ -19,6 +19,8 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/jobs"
 	"github.com/cockroachdb/cockroach/pkg/jobs/jobspb"
 	"github.com/cockroachdb/cockroach/pkg/jobs/jobsprotectedts"
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/kv"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/protectedts"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/protectedts/ptpb"
 	"github.com/cockroachdb/cockroach/pkg/multitenant/mtinfopb"
@@ -560,12 +562,48 @@ func activateTenant(ctx context.Context, execCtx interface{}, newTenantID roachp
 			return err
 		}
 
+		// Only clear the replicated liveness state if the tenant hasn't been
+		// activated already, in which case its SQL servers may have started to
+		// write their own.
+		if info.DataState != mtinfopb.DataStateReady {
+			if err := clearReplicatedLivenessState(ctx, txn.KV(), newTenantID); err != nil {
+				return err
+			}
+		}
+
 		info.DataState = mtinfopb.DataStateReady
 		info.TenantReplicationJobID = 0
 		return sql.UpdateTenantRecord(ctx, p.ExecCfg().Settings, txn, info)
 	})
 }
 
+// replicatedLivenessTables are the tenant system tables that track the liveness
+// and membership of the tenant's SQL servers. Their replicated contents
+// describe the source cluster's SQL servers, which are of no use to, and may
+// confuse, the SQL servers of the activated destination tenant.
+var replicatedLivenessTables = []uint32{
+	keys.SqllivenessID,
+	keys.SQLInstancesTableID,
+}
+
+// clearReplicatedLivenessState deletes the replicated liveness and membership
+// records of the source cluster's SQL servers from the destination tenant's
+// keyspace, so that the destination tenant's SQL servers build their own from
+// scratch once the tenant is activated. Pre-allocated instance IDs are
+// regenerated on demand by the SQL servers as they start up.
+func clearReplicatedLivenessState(
+	ctx context.Context, txn *kv.Txn, tenantID roachpb.TenantID,
+) error {
+	codec := keys.MakeSQLCodec(tenantID)
+	b := txn.NewBatch()
+	for _, id := range replicatedLivenessTables {
+		prefix := codec.TablePrefix(id)
+		b.DelRange(prefix, prefix.PrefixEnd(), false /* returnKeys */)
+	}
+	log.Infof(ctx, "clearing replicated SQL liveness state of tenant %d", tenantID)
+	return txn.Run(ctx, b)
+}
+
 // OnFailOrCancel is part of the jobs.Resumer interface.
 // There is a know race between the ingestion processors shutting down, and
 // OnFailOrCancel being invoked. As a result of which we might see some keys
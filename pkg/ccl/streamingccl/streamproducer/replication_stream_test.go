@@ -29,6 +29,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/keys"
 	"github.com/cockroachdb/cockroach/pkg/kv"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvpb"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/protectedts"
 	"github.com/cockroachdb/cockroach/pkg/repstream/streampb"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
@@ -215,8 +216,14 @@ func testStreamReplicationStatus(
 	}
 
 	updatedFrontier := hlc.Timestamp{WallTime: timeutil.Now().UnixNano()}
+	// The status carries the liveness of the single node of the source cluster.
 	expectedStreamStatus := streampb.StreamReplicationStatus{
 		StreamStatus: streamStatus,
+		SourceNodes: []streampb.StreamReplicationStatus_SourceNode{{
+			NodeID:     1,
+			Status:     livenesspb.NodeLivenessStatus_LIVE,
+			Membership: livenesspb.MembershipStatus_ACTIVE,
+		}},
 	}
 	// Send a heartbeat first, the protected timestamp should get updated.
 	if streamStatus == streampb.StreamReplicationStatus_STREAM_ACTIVE {
@@ -10,6 +10,7 @@ package streamproducer
 
 import (
 	"context"
+	"sort"
 	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/ccl/streamingccl"
@@ -17,6 +18,8 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/jobs/jobspb"
 	"github.com/cockroachdb/cockroach/pkg/jobs/jobsprotectedts"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/protectedts"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/protectedts/ptpb"
 	"github.com/cockroachdb/cockroach/pkg/repstream/streampb"
@@ -26,6 +29,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/sql/sem/eval"
 	"github.com/cockroachdb/cockroach/pkg/testutils"
 	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
 	"github.com/cockroachdb/cockroach/pkg/util/uuid"
 	"github.com/cockroachdb/errors"
@@ -169,13 +173,65 @@ func updateReplicationStreamProgress(
 
 // heartbeatReplicationStream updates replication stream progress and advances protected timestamp
 // record to the specified frontier. If 'frontier' is hlc.MaxTimestamp, returns the producer job
-// progress without updating it.
+// progress without updating it. The returned status also carries the liveness
+// of the source cluster's nodes, so that the standby can tell when the primary
+// is running degraded.
 func heartbeatReplicationStream(
 	ctx context.Context,
 	evalCtx *eval.Context,
 	txn isql.Txn,
 	streamID streampb.StreamID,
 	frontier hlc.Timestamp,
+) (streampb.StreamReplicationStatus, error) {
+	status, err := updateOrLoadReplicationStreamStatus(ctx, evalCtx, txn, streamID, frontier)
+	if err != nil {
+		return streampb.StreamReplicationStatus{}, err
+	}
+	status.SourceNodes = getSourceNodes(ctx, evalCtx)
+	return status, nil
+}
+
+// getSourceNodes returns the liveness and membership status of every
+// non-decommissioned node in the source cluster. Failing to read liveness does
+// not fail the heartbeat; the nodes are simply omitted.
+func getSourceNodes(
+	ctx context.Context, evalCtx *eval.Context,
+) []streampb.StreamReplicationStatus_SourceNode {
+	execConfig := evalCtx.Planner.ExecutorConfig().(*sql.ExecutorConfig)
+	nl, ok := execConfig.NodeLiveness.Optional(47900)
+	if !ok {
+		return nil
+	}
+	livenesses, err := nl.GetLivenessesFromKV(ctx)
+	if err != nil {
+		log.Warningf(ctx, "unable to read source cluster node liveness: %v", err)
+		return nil
+	}
+	now := execConfig.Clock.Now()
+	deadThreshold := liveness.TimeUntilStoreDead.Get(&evalCtx.Settings.SV)
+	nodes := make([]streampb.StreamReplicationStatus_SourceNode, 0, len(livenesses))
+	for i := range livenesses {
+		l := &livenesses[i]
+		status := l.Status(now, deadThreshold)
+		if status == livenesspb.NodeLivenessStatus_DECOMMISSIONED {
+			continue
+		}
+		nodes = append(nodes, streampb.StreamReplicationStatus_SourceNode{
+			NodeID:     l.NodeID,
+			Status:     status,
+			Membership: l.Membership,
+		})
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].NodeID < nodes[j].NodeID })
+	return nodes
+}
+
+func updateOrLoadReplicationStreamStatus(
+	ctx context.Context,
+	evalCtx *eval.Context,
+	txn isql.Txn,
+	streamID streampb.StreamID,
+	frontier hlc.Timestamp,
 ) (streampb.StreamReplicationStatus, error) {
 	execConfig := evalCtx.Planner.ExecutorConfig().(*sql.ExecutorConfig)
 	timeout := streamingccl.StreamReplicationJobLivenessTimeout.Get(&evalCtx.Settings.SV)
@@ -111,6 +111,17 @@ specialized hardware or number of cores (e.g. "gpu", "x16c"). For example:
   --attrs=x16c:gpu</PRE>`,
 	}
 
+	ColdNode = FlagInfo{
+		Name: "cold-node",
+		Description: `
+Run the node in cold mode, for mostly idle nodes such as those holding archival
+data. A cold node heartbeats its liveness record
+kv.liveness.cold_node.ttl_multiplier times less often than other nodes, with a
+proportionally longer expiration, which reduces its background load. Note that
+this also delays the detection of the node's failure, and with it the transfer
+of its leases to other nodes.`,
+	}
+
 	Locality = FlagInfo{
 		Name: "locality",
 		Description: `
@@ -1219,8 +1230,9 @@ Specifies when to return during the decommissioning process.
 Takes any of the following values:
 <PRE>
 
-  - all   waits until all target nodes' replica counts have dropped to zero and
-          marks the nodes as fully decommissioned. This is the default.
+  - all   waits until all target nodes' replica counts have dropped to zero,
+          marks the nodes as fully decommissioned, and waits until they are
+          reported as such. This is the default.
   - none  marks the targets as decommissioning, but does not wait for the
           replica counts to drop to zero before returning. If the replica counts
           are found to be zero, nodes are marked as fully decommissioned. Use
@@ -1285,12 +1297,78 @@ Takes any of the following values:
 status, without actually decommissioning the node.`,
 	}
 
+	NodeDecommissionAllowUnderReplication = FlagInfo{
+		Name: "allow-under-replication",
+		Description: `
+Decommission the nodes even though this leaves fewer eligible nodes than
+needed to place the replicas of some zones. Without this flag, such a
+decommission is refused and the affected zones are listed, if the
+server.decommission.under_replication_check.enabled cluster setting is
+set.`,
+	}
+
+	NodeDecommissionAsJob = FlagInfo{
+		Name: "as-job",
+		Description: `
+Hand the decommission over to a job once the nodes are marked as
+decommissioning, and return right away. The job moves the replicas off of the
+nodes and marks them as decommissioned. It is listed along with the other jobs,
+and can be paused, resumed and canceled like them; canceling it recommissions
+the nodes. The --wait flag is ignored.`,
+	}
+
+	NodeMembershipChangeReason = FlagInfo{
+		Name: "reason",
+		Description: `
+An optional, free-form explanation for the operation (e.g. a ticket number).
+It is recorded in the liveness records of the target nodes, shown in the
+output of 'node status --decommission' and included in the event log.`,
+	}
+
+	NodeMembershipChangeToken = FlagInfo{
+		Name: "idempotency-token",
+		Description: `
+An optional token identifying the operation, which makes it apply at most once
+to each node: rerunning the command with the same token doesn't change the
+membership of the nodes it was already applied to again, even if it has
+changed since, nor does it record the change in the event log again. Tokens
+are expected to be unique per operation.`,
+	}
+
 	NodeDrainSelf = FlagInfo{
 		Name: "self",
 		Description: `Use the node ID of the node connected to via --host
 as target of the drain or quit command.`,
 	}
 
+	NodeDrainPlannedRestart = FlagInfo{
+		Name: "planned-restart",
+		Description: `
+Declare that the node is going down for a planned restart after the drain and
+is expected back within the given duration (e.g. 10m). Until then, its
+replicas are not moved to other nodes even if it is considered dead, which
+avoids needless re-replication during routine rolling restarts. The duration
+is capped by the server.shutdown.planned_restart.max_duration cluster
+setting.`,
+	}
+
+	NodeDrainSticky = FlagInfo{
+		Name: "sticky",
+		Description: `
+Persist the drain in the node's liveness record, so that if the node is
+restarted before it is undrained, it comes back up still draining: it
+refuses range leases and SQL connections until it is undrained with
+--undrain.`,
+	}
+
+	NodeDrainUndrain = FlagInfo{
+		Name: "undrain",
+		Description: `
+Instead of draining the node, clear a sticky drain (see --sticky) and bring
+the node back into rotation. A node whose SQL layer was fully drained since
+it last started needs to be restarted instead, which it then does undrained.`,
+	}
+
 	SQLFmtLen = FlagInfo{
 		Name: "print-width",
 		Description: `
@@ -539,6 +539,15 @@ var drainCtx struct {
 	// nodeDrainSelf indicates that the command should target
 	// the node we're connected to (this is the default behavior).
 	nodeDrainSelf bool
+	// reason is an optional, operator-supplied explanation for the drain.
+	reason string
+	// plannedRestart, if non-zero, is how long the node is expected to be
+	// down for a planned restart after the drain.
+	plannedRestart time.Duration
+	// sticky indicates that the drain persists across restarts.
+	sticky bool
+	// undrain indicates that the command should undrain the node instead.
+	undrain bool
 }
 
 // setDrainContextDefaults set the default values in drainCtx.  This
@@ -547,6 +556,10 @@ var drainCtx struct {
 func setDrainContextDefaults() {
 	drainCtx.drainWait = 10 * time.Minute
 	drainCtx.nodeDrainSelf = false
+	drainCtx.reason = ""
+	drainCtx.plannedRestart = 0
+	drainCtx.sticky = false
+	drainCtx.undrain = false
 }
 
 // nodeCtx captures the command-line parameters of the `node` command.
@@ -556,10 +569,18 @@ var nodeCtx struct {
 	nodeDecommissionSelf   bool
 	nodeDecommissionChecks nodeDecommissionCheckMode
 	nodeDecommissionDryRun bool
+	nodeDecommissionReason string
+	nodeDecommissionToken  string
 	statusShowRanges       bool
 	statusShowStats        bool
 	statusShowDecommission bool
 	statusShowAll          bool
+
+	// nodeDecommissionAllowUnderReplication allows a decommission that leaves
+	// fewer eligible nodes than the replication factor of some zones.
+	nodeDecommissionAllowUnderReplication bool
+	// nodeDecommissionAsJob hands the decommission over to a job.
+	nodeDecommissionAsJob bool
 }
 
 // setNodeContextDefaults set the default values in nodeCtx.  This
@@ -570,6 +591,10 @@ func setNodeContextDefaults() {
 	nodeCtx.nodeDecommissionSelf = false
 	nodeCtx.nodeDecommissionChecks = nodeDecommissionChecksEnabled
 	nodeCtx.nodeDecommissionDryRun = false
+	nodeCtx.nodeDecommissionReason = ""
+	nodeCtx.nodeDecommissionToken = ""
+	nodeCtx.nodeDecommissionAllowUnderReplication = false
+	nodeCtx.nodeDecommissionAsJob = false
 	nodeCtx.statusShowRanges = false
 	nodeCtx.statusShowStats = false
 	nodeCtx.statusShowAll = false
@@ -1330,6 +1330,7 @@ var debugCmds = []*cobra.Command{
 	debugDecodeValueCmd,
 	debugDecodeProtoCmd,
 	debugGossipValuesCmd,
+	debugMembershipGraphCmd,
 	debugTimeSeriesDumpCmd,
 	debugSyncBenchCmd,
 	debugSyncTestCmd,
@@ -0,0 +1,57 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/cockroachdb/cockroach/pkg/cli/clierrorplus"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/errors"
+	"github.com/spf13/cobra"
+)
+
+var debugMembershipGraphCmd = &cobra.Command{
+	Use:   "membership-graph [dot|json]",
+	Short: "print the node membership state machine",
+	Long: `
+Prints the node membership state machine, i.e. the membership statuses and the
+transitions between them that are allowed, either in the Graphviz DOT language
+(the default) or as JSON. The state machine is the one this binary validates
+membership changes against.
+
+The membership status of a running node can be obtained along with the state
+machine from the /api/v2/liveness/membership-graph/ endpoint.
+`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: clierrorplus.MaybeDecorateError(runDebugMembershipGraph),
+}
+
+func runDebugMembershipGraph(cmd *cobra.Command, args []string) error {
+	format := "dot"
+	if len(args) > 0 {
+		format = args[0]
+	}
+	graph := livenesspb.MakeMembershipGraph(nil /* current */)
+	switch format {
+	case "dot":
+		fmt.Print(graph.DOT())
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(graph)
+	default:
+		return errors.Errorf("unknown format %q, expected dot or json", format)
+	}
+	return nil
+}
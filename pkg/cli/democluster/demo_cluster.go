@@ -1073,10 +1073,14 @@ func (c *transientCluster) Decommission(ctx context.Context, nodeID int32) error
 	// This (cumbersome) two step process is due to the allowed state
 	// transitions for membership status. To mark a node as fully
 	// decommissioned, it has to be marked as decommissioning first.
+	//
+	// Demo clusters are routinely smaller than the replication factor of the
+	// system ranges, so we don't refuse decommissions that under-replicate.
 	{
 		req := &serverpb.DecommissionRequest{
-			NodeIDs:          []roachpb.NodeID{roachpb.NodeID(nodeID)},
-			TargetMembership: livenesspb.MembershipStatus_DECOMMISSIONING,
+			NodeIDs:               []roachpb.NodeID{roachpb.NodeID(nodeID)},
+			TargetMembership:      livenesspb.MembershipStatus_DECOMMISSIONING,
+			AllowUnderReplication: true,
 		}
 		_, err = adminClient.Decommission(ctx, req)
 		if err != nil {
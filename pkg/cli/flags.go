@@ -394,6 +394,7 @@ func init() {
 		// planning?
 		if cmd != connectInitCmd && cmd != connectJoinCmd {
 			cliflagcfg.StringFlag(f, &serverCfg.Attrs, cliflags.Attrs)
+			cliflagcfg.BoolFlag(f, &serverCfg.ColdNode, cliflags.ColdNode)
 			// Cluster initialization. We only do this for a regular start command;
 			// SQL-only servers get their initialization payload from their tenant
 			// configuration.
@@ -713,11 +714,16 @@ func init() {
 	// Decommission pre-check flags.
 	cliflagcfg.VarFlag(decommissionNodeCmd.Flags(), &nodeCtx.nodeDecommissionChecks, cliflags.NodeDecommissionChecks)
 	cliflagcfg.BoolFlag(decommissionNodeCmd.Flags(), &nodeCtx.nodeDecommissionDryRun, cliflags.NodeDecommissionDryRun)
+	cliflagcfg.BoolFlag(decommissionNodeCmd.Flags(), &nodeCtx.nodeDecommissionAllowUnderReplication, cliflags.NodeDecommissionAllowUnderReplication)
+	cliflagcfg.BoolFlag(decommissionNodeCmd.Flags(), &nodeCtx.nodeDecommissionAsJob, cliflags.NodeDecommissionAsJob)
 
-	// Decommission and recommission share --self.
+	// Decommission and recommission share --self, --reason and
+	// --idempotency-token.
 	for _, cmd := range []*cobra.Command{decommissionNodeCmd, recommissionNodeCmd} {
 		f := cmd.Flags()
 		cliflagcfg.BoolFlag(f, &nodeCtx.nodeDecommissionSelf, cliflags.NodeDecommissionSelf)
+		cliflagcfg.StringFlag(f, &nodeCtx.nodeDecommissionReason, cliflags.NodeMembershipChangeReason)
+		cliflagcfg.StringFlag(f, &nodeCtx.nodeDecommissionToken, cliflags.NodeMembershipChangeToken)
 	}
 
 	// node drain command.
@@ -725,6 +731,10 @@ func init() {
 		f := drainNodeCmd.Flags()
 		cliflagcfg.DurationFlag(f, &drainCtx.drainWait, cliflags.DrainWait)
 		cliflagcfg.BoolFlag(f, &drainCtx.nodeDrainSelf, cliflags.NodeDrainSelf)
+		cliflagcfg.StringFlag(f, &drainCtx.reason, cliflags.NodeMembershipChangeReason)
+		cliflagcfg.DurationFlag(f, &drainCtx.plannedRestart, cliflags.NodeDrainPlannedRestart)
+		cliflagcfg.BoolFlag(f, &drainCtx.sticky, cliflags.NodeDrainSticky)
+		cliflagcfg.BoolFlag(f, &drainCtx.undrain, cliflags.NodeDrainUndrain)
 	}
 
 	// Commands that establish a SQL connection.
@@ -13,21 +13,26 @@ package cli
 import (
 	"context"
 	"fmt"
+	"io"
 	"math"
 	"os"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/cli/clierrorplus"
 	"github.com/cockroachdb/cockroach/pkg/cli/cliflags"
 	"github.com/cockroachdb/cockroach/pkg/cli/clisqlclient"
 	"github.com/cockroachdb/cockroach/pkg/cli/clisqlexec"
+	"github.com/cockroachdb/cockroach/pkg/clusterversion"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/retry"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
 	"github.com/cockroachdb/errors"
 	"github.com/cockroachdb/redact"
 	"github.com/spf13/cobra"
@@ -123,6 +128,7 @@ var statusNodesColumnHeadersForDecommission = []string{
 	"is_decommissioning",
 	"membership",
 	"is_draining",
+	"reason",
 }
 
 var statusNodeCmd = &cobra.Command{
@@ -206,12 +212,13 @@ FROM crdb_internal.kv_store_status
 GROUP BY node_id`
 
 	// TODO(irfansharif): Remove the `is_decommissioning` column in v20.2.
-	const decommissionQuery = `
+	const decommissionQueryFmt = `
 SELECT node_id AS id,
        ranges AS gossiped_replicas,
        membership != 'active' as is_decommissioning,
        membership AS membership,
-       draining AS is_draining
+       draining AS is_draining,
+       %s AS reason
 FROM crdb_internal.gossip_liveness LEFT JOIN crdb_internal.gossip_nodes USING (node_id)`
 
 	conn, err := makeSQLClient("cockroach node status", useSystemDb)
@@ -220,6 +227,11 @@ FROM crdb_internal.gossip_liveness LEFT JOIN crdb_internal.gossip_nodes USING (n
 	}
 	defer func() { resErr = errors.CombineErrors(resErr, conn.Close()) }()
 
+	ctx := context.Background()
+	if err = conn.EnsureConn(ctx); err != nil {
+		return nil, nil, err
+	}
+
 	queriesToJoin := []string{baseQuery}
 
 	if nodeCtx.statusShowAll || nodeCtx.statusShowRanges {
@@ -229,12 +241,19 @@ FROM crdb_internal.gossip_liveness LEFT JOIN crdb_internal.gossip_nodes USING (n
 		queriesToJoin = append(queriesToJoin, statsQuery)
 	}
 	if nodeCtx.statusShowAll || nodeCtx.statusShowDecommission {
-		queriesToJoin = append(queriesToJoin, decommissionQuery)
-	}
-
-	ctx := context.Background()
-	if err = conn.EnsureConn(ctx); err != nil {
-		return nil, nil, err
+		// The reason column of crdb_internal.gossip_liveness is only selected
+		// once the cluster version guarantees that every node, including the
+		// one serving the query, has it.
+		reason := "NULL::STRING"
+		row, err := conn.QueryRow(ctx, "SELECT crdb_internal.is_at_least_version($1)",
+			clusterversion.ByKey(clusterversion.V23_2_LivenessMembershipReason).String())
+		if err != nil {
+			return nil, nil, err
+		}
+		if hasReason, ok := row[0].(bool); ok && hasReason {
+			reason = "reason"
+		}
+		queriesToJoin = append(queriesToJoin, fmt.Sprintf(decommissionQueryFmt, reason))
 	}
 
 	// TODO(knz): This can use a context deadline instead, now that
@@ -536,12 +555,32 @@ func runDecommissionNodeImpl(
 		return err
 	}
 
+	if nodeCtx.nodeDecommissionAsJob {
+		resp, err := c.Decommission(ctx, &serverpb.DecommissionRequest{
+			NodeIDs:               nodeIDs,
+			TargetMembership:      livenesspb.MembershipStatus_DECOMMISSIONING,
+			Reason:                nodeCtx.nodeDecommissionReason,
+			AllowUnderReplication: nodeCtx.nodeDecommissionAllowUnderReplication,
+			IdempotencyToken:      nodeCtx.nodeDecommissionToken,
+			AsJob:                 true,
+		})
+		if err != nil {
+			fmt.Fprintln(stderr)
+			return errors.Wrap(err, "while trying to start the decommission job")
+		}
+		fmt.Fprintf(os.Stdout, "\nDecommission handed over to job %d.\n", resp.JobID)
+		return nil
+	}
+
 	prevResponse := serverpb.DecommissionStatusResponse{}
 	for r := retry.StartWithCtx(ctx, opts); r.Next(); {
 		req := &serverpb.DecommissionRequest{
-			NodeIDs:          nodeIDs,
-			TargetMembership: livenesspb.MembershipStatus_DECOMMISSIONING,
-			NumReplicaReport: int32(numReplicaReport),
+			NodeIDs:               nodeIDs,
+			TargetMembership:      livenesspb.MembershipStatus_DECOMMISSIONING,
+			NumReplicaReport:      int32(numReplicaReport),
+			Reason:                nodeCtx.nodeDecommissionReason,
+			AllowUnderReplication: nodeCtx.nodeDecommissionAllowUnderReplication,
+			IdempotencyToken:      nodeCtx.nodeDecommissionToken,
 		}
 		resp, err := c.Decommission(ctx, req)
 		if err != nil {
@@ -611,6 +650,7 @@ func runDecommissionNodeImpl(
 					Shutdown: false,
 					DoDrain:  true,
 					NodeId:   targetNode.String(),
+					Reason:   nodeCtx.nodeDecommissionReason,
 				}
 				if _, err = c.Drain(ctx, drainReq); err != nil {
 					fmt.Fprintln(stderr)
@@ -622,6 +662,8 @@ func runDecommissionNodeImpl(
 			decommissionReq := &serverpb.DecommissionRequest{
 				NodeIDs:          nodeIDs,
 				TargetMembership: livenesspb.MembershipStatus_DECOMMISSIONED,
+				Reason:           nodeCtx.nodeDecommissionReason,
+				IdempotencyToken: nodeCtx.nodeDecommissionToken,
 			}
 			_, err = c.Decommission(ctx, decommissionReq)
 			if err != nil {
@@ -629,6 +671,14 @@ func runDecommissionNodeImpl(
 				return errors.Wrap(err, "while trying to mark as decommissioned")
 			}
 
+			// Confirm that the nodes are decommissioned. The request above is
+			// a no-op for the nodes it was already applied to with the same
+			// idempotency token, even if they were recommissioned since.
+			if err := waitForDecommissioned(ctx, c, nodeIDs); err != nil {
+				fmt.Fprintln(stderr)
+				return errors.Wrap(err, "while waiting for nodes to be decommissioned")
+			}
+
 			fmt.Fprintln(os.Stdout, "\nNo more data reported on target nodes. "+
 				"Please verify cluster health before removing the nodes.")
 			return nil
@@ -649,6 +699,48 @@ func runDecommissionNodeImpl(
 	return errors.New("maximum number of retries exceeded")
 }
 
+// decommissionedWaitTimeout is how long waitForDecommissioned waits for at
+// most. The nodes are expected to be decommissioned already.
+const decommissionedWaitTimeout = time.Minute
+
+// waitForDecommissioned waits for the given nodes to be reported as fully
+// decommissioned, printing their status whenever it changes in the meantime.
+// The wait is skipped if the server runs a version that doesn't support it.
+func waitForDecommissioned(
+	ctx context.Context, c serverpb.AdminClient, nodeIDs []roachpb.NodeID,
+) error {
+	stream, err := c.WaitForDecommissioned(ctx, &serverpb.WaitForDecommissionedRequest{
+		NodeIDs: nodeIDs,
+		Timeout: decommissionedWaitTimeout,
+	})
+	if status.Code(err) == codes.Unimplemented {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return errors.New("decommission status stream ended unexpectedly")
+		}
+		if status.Code(err) == codes.Unimplemented {
+			// Errors of streaming RPCs only surface once the stream is read.
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if resp.Done {
+			return nil
+		}
+		fmt.Fprintln(stderr)
+		if err := printDecommissionStatus(serverpb.DecommissionStatusResponse{Status: resp.Status}); err != nil {
+			return err
+		}
+	}
+}
+
 func decommissionStatusAlignment() string {
 	return "rcrccc"
 }
@@ -759,12 +851,28 @@ func printDecommissionReplicas(resp serverpb.DecommissionStatusResponse) {
 func printDecommissionBlockingErrorSummary(
 	resp *serverpb.DecommissionPreCheckResponse, reportLimit int,
 ) {
-	fmt.Fprintln(stderr, "\nranges blocking decommission detected")
+	// Shortfalls of the remaining nodes are the same for all checked nodes.
+	for _, nodeCheckResult := range resp.CheckedNodes {
+		if len(nodeCheckResult.Shortfalls) == 0 {
+			continue
+		}
+		fmt.Fprintln(stderr, "\nremaining nodes cannot take over from the decommissioned nodes")
+		for _, shortfall := range nodeCheckResult.Shortfalls {
+			fmt.Fprintln(stderr, shortfall)
+		}
+		break
+	}
+
 	reported := 0
+	printedHeader := false
 	for _, nodeCheckResult := range resp.CheckedNodes {
 		if nodeCheckResult.DecommissionReadiness != serverpb.DecommissionPreCheckResponse_ALLOCATION_ERRORS {
 			continue
 		}
+		if !printedHeader {
+			fmt.Fprintln(stderr, "\nranges blocking decommission detected")
+			printedHeader = true
+		}
 
 		errCountMap := make(map[string]int)
 		for _, rangeCheckResult := range nodeCheckResult.CheckedRanges {
@@ -844,6 +952,8 @@ func runRecommissionNode(cmd *cobra.Command, args []string) error {
 	req := &serverpb.DecommissionRequest{
 		NodeIDs:          nodeIDs,
 		TargetMembership: livenesspb.MembershipStatus_ACTIVE,
+		Reason:           nodeCtx.nodeDecommissionReason,
+		IdempotencyToken: nodeCtx.nodeDecommissionToken,
 	}
 	resp, err := c.Decommission(ctx, req)
 	if err != nil {
@@ -883,6 +993,9 @@ gracefully using e.g. a unix signal.
 If an argument is specified, the command affects the node
 whose ID is given. If --self is specified, the command
 affects the node that the command is connected to (via --host).
+
+With --sticky, the node stays draining across restarts until it
+is undrained with --undrain.
 `,
 	Args: cobra.MaximumNArgs(1),
 	RunE: clierrorplus.MaybeDecorateError(runDrain),
@@ -921,10 +1034,279 @@ func runDrain(cmd *cobra.Command, args []string) (err error) {
 	}
 	defer finish()
 
+	if drainCtx.undrain {
+		if drainCtx.sticky {
+			return errors.Newf("cannot use --%s with --%s",
+				cliflags.NodeDrainSticky.Name, cliflags.NodeDrainUndrain.Name)
+		}
+		return doUndrain(ctx, c, targetNode)
+	}
 	_, _, err = doDrain(ctx, c, targetNode)
 	return err
 }
 
+var vitalityNodeCmd = &cobra.Command{
+	Use:   "vitality",
+	Short: "shows the liveness details of all nodes",
+	Long: `
+Shows the liveness details of all nodes as seen by the node the command is
+connected to (via --host): the verdict on the node's liveness, its liveness
+epoch and expiration, the margin left until the expiration, whether the
+allocator considers the node suspect, its membership, its drain phase, and
+why it is degraded, if it is (e.g. "compaction debt" if one of its stores has
+an LSM unhealthy enough that the allocator avoids moving leases to it,
+"memory pressure" if it is close to running out of memory, or "shedding
+leases" if it is overloaded and asks for its leases to be moved elsewhere).
+`,
+	Args: cobra.NoArgs,
+	RunE: clierrorplus.MaybeDecorateError(runVitalityNode),
+}
+
+var vitalityNodeColumnHeaders = []string{
+	"id",
+	"status",
+	"epoch",
+	"expiration",
+	"expiration_margin",
+	"clock_offset",
+	"suspect",
+	"membership",
+	"drain_phase",
+	"is_connected",
+	"degraded",
+}
+
+func runVitalityNode(cmd *cobra.Command, args []string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	c, finish, err := getStatusClient(ctx, serverCfg)
+	if err != nil {
+		return err
+	}
+	defer finish()
+
+	resp, err := c.NodeVitality(ctx, &serverpb.NodeVitalityRequest{})
+	if err != nil {
+		return err
+	}
+
+	now := timeutil.Now()
+	rows := make([][]string, 0, len(resp.Nodes))
+	for _, n := range resp.Nodes {
+		expiration := n.Liveness.Expiration.ToTimestamp().GoTime()
+		rows = append(rows, []string{
+			strconv.FormatInt(int64(n.NodeID), 10),
+			strings.ToLower(strings.TrimPrefix(n.Status.String(), "NODE_STATUS_")),
+			strconv.FormatInt(n.Liveness.Epoch, 10),
+			expiration.Local().Format(localTimeFormat),
+			expiration.Sub(now).Round(time.Millisecond).String(),
+			time.Duration(n.Liveness.MaxClockOffsetNanos).String(),
+			strconv.FormatBool(n.Suspect),
+			n.Liveness.Membership.String(),
+			drainPhase(n.Liveness, now),
+			strconv.FormatBool(n.Connectivity == livenesspb.Connectivity_CONNECTED),
+			n.Degraded,
+		})
+	}
+	sliceIter := clisqlexec.NewRowSliceIter(rows, "rlrlrrlllll")
+	return sqlExecCtx.PrintQueryOutput(os.Stdout, stderr, vitalityNodeColumnHeaders, sliceIter)
+}
+
+// drainPhase describes how far along a node is in leaving service: draining if
+// it is draining, maintenance if it is within its maintenance window, and none
+// otherwise.
+func drainPhase(l livenesspb.Liveness, now time.Time) string {
+	switch {
+	case l.Draining:
+		return "draining"
+	case l.InMaintenance(hlc.Timestamp{WallTime: now.UnixNano()}):
+		return "maintenance"
+	default:
+		return "none"
+	}
+}
+
+var doctorNodeCmd = &cobra.Command{
+	Use:   "doctor [<node id>]",
+	Short: "checks the sanity of the liveness of a node",
+	Long: `
+Has a node check the sanity of its own liveness: that its liveness record
+matches its identity, that its clock is within the tolerated offset of the
+leaseholder of the node liveness range, that its heartbeats succeed, and that
+gossip has a current view of it. Defaults to the node the command is connected
+to (via --host). Exits with an error if any check fails.
+`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: clierrorplus.MaybeDecorateError(runDoctorNode),
+}
+
+var doctorNodeColumnHeaders = []string{
+	"check",
+	"ok",
+	"detail",
+}
+
+func runDoctorNode(cmd *cobra.Command, args []string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	nodeID := "local"
+	if len(args) > 0 {
+		if _, err := strconv.ParseInt(args[0], 10, 32); err != nil {
+			return errors.Wrapf(err, "unable to parse %s", args[0])
+		}
+		nodeID = args[0]
+	}
+
+	c, finish, err := getStatusClient(ctx, serverCfg)
+	if err != nil {
+		return err
+	}
+	defer finish()
+
+	resp, err := c.NodeDoctor(ctx, &serverpb.NodeDoctorRequest{NodeId: nodeID})
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	rows := make([][]string, 0, len(resp.Checks))
+	for _, check := range resp.Checks {
+		if !check.OK {
+			failed++
+		}
+		rows = append(rows, []string{
+			check.Name,
+			strconv.FormatBool(check.OK),
+			check.Detail,
+		})
+	}
+	sliceIter := clisqlexec.NewRowSliceIter(rows, "lll")
+	if err := sqlExecCtx.PrintQueryOutput(os.Stdout, stderr, doctorNodeColumnHeaders, sliceIter); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return errors.Newf("n%d failed %d of %d checks", resp.NodeID, failed, len(resp.Checks))
+	}
+	return nil
+}
+
+var livenessDiffNodeCmd = &cobra.Command{
+	Use:   "liveness-diff <snapshot> <snapshot>",
+	Short: "compares two snapshots of the liveness of all nodes",
+	Long: `
+Compares two snapshots of the liveness of all nodes and shows, for every node,
+its status, epoch and membership in each snapshot along with how they differ.
+This helps diagnosing nodes that disagree on each other's liveness, e.g.
+during partitions. A snapshot is one of:
+
+  kv             the current liveness records in KV.
+  kv@<time>      the liveness records in KV as of the given time in the past,
+                 in RFC3339 format (e.g. kv@2023-06-01T12:00:00Z).
+  n<node id>     the view of liveness of the given node (e.g. n3).
+  local          the view of liveness of the node the command is connected to
+                 (via --host).
+`,
+	Args: cobra.ExactArgs(2),
+	RunE: clierrorplus.MaybeDecorateError(runLivenessDiffNode),
+}
+
+var livenessDiffNodeColumnHeaders = []string{
+	"id",
+	"status_a",
+	"status_b",
+	"epoch_a",
+	"epoch_b",
+	"membership_a",
+	"membership_b",
+	"differences",
+}
+
+// parseLivenessSnapshotSource parses the description of a liveness snapshot
+// accepted by the liveness-diff command.
+func parseLivenessSnapshotSource(s string) (serverpb.LivenessSnapshotSource, error) {
+	switch {
+	case s == "kv":
+		return serverpb.LivenessSnapshotSource{}, nil
+	case s == "local":
+		return serverpb.LivenessSnapshotSource{NodeID: s}, nil
+	case strings.HasPrefix(s, "kv@"):
+		asOf, err := time.Parse(time.RFC3339Nano, strings.TrimPrefix(s, "kv@"))
+		if err != nil {
+			return serverpb.LivenessSnapshotSource{}, errors.Wrapf(err, "unable to parse %s", s)
+		}
+		return serverpb.LivenessSnapshotSource{AsOf: &asOf}, nil
+	case strings.HasPrefix(s, "n"):
+		nodeID := strings.TrimPrefix(s, "n")
+		if _, err := strconv.ParseInt(nodeID, 10, 32); err != nil {
+			return serverpb.LivenessSnapshotSource{}, errors.Wrapf(err, "unable to parse %s", s)
+		}
+		return serverpb.LivenessSnapshotSource{NodeID: nodeID}, nil
+	default:
+		return serverpb.LivenessSnapshotSource{}, errors.Newf("invalid liveness snapshot %q", s)
+	}
+}
+
+func runLivenessDiffNode(cmd *cobra.Command, args []string) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	a, err := parseLivenessSnapshotSource(args[0])
+	if err != nil {
+		return err
+	}
+	b, err := parseLivenessSnapshotSource(args[1])
+	if err != nil {
+		return err
+	}
+
+	c, finish, err := getStatusClient(ctx, serverCfg)
+	if err != nil {
+		return err
+	}
+	defer finish()
+
+	resp, err := c.LivenessDiff(ctx, &serverpb.LivenessDiffRequest{A: a, B: b})
+	if err != nil {
+		return err
+	}
+
+	status := func(present bool, s livenesspb.NodeLivenessStatus) string {
+		if !present {
+			return "absent"
+		}
+		return strings.ToLower(strings.TrimPrefix(s.String(), "NODE_STATUS_"))
+	}
+	epoch := func(present bool, e int64) string {
+		if !present {
+			return ""
+		}
+		return strconv.FormatInt(e, 10)
+	}
+	membership := func(present bool, m livenesspb.MembershipStatus) string {
+		if !present {
+			return ""
+		}
+		return m.String()
+	}
+	rows := make([][]string, 0, len(resp.Nodes))
+	for _, n := range resp.Nodes {
+		rows = append(rows, []string{
+			strconv.FormatInt(int64(n.NodeID), 10),
+			status(n.InA, n.StatusA),
+			status(n.InB, n.StatusB),
+			epoch(n.InA, n.EpochA),
+			epoch(n.InB, n.EpochB),
+			membership(n.InA, n.MembershipA),
+			membership(n.InB, n.MembershipB),
+			strings.Join(n.Differences, ","),
+		})
+	}
+	sliceIter := clisqlexec.NewRowSliceIter(rows, "rllrrlll")
+	return sqlExecCtx.PrintQueryOutput(os.Stdout, stderr, livenessDiffNodeColumnHeaders, sliceIter)
+}
+
 // Sub-commands for node command.
 var nodeCmds = []*cobra.Command{
 	lsNodesCmd,
@@ -932,6 +1314,9 @@ var nodeCmds = []*cobra.Command{
 	decommissionNodeCmd,
 	recommissionNodeCmd,
 	drainNodeCmd,
+	vitalityNodeCmd,
+	doctorNodeCmd,
+	livenessDiffNodeCmd,
 }
 
 var nodeCmd = &cobra.Command{
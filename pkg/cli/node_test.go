@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/build"
+	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
 	"github.com/cockroachdb/cockroach/pkg/testutils/skip"
 	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
@@ -307,3 +308,136 @@ func extractFields(line string) ([]string, error) {
 	}
 	return r, nil
 }
+
+func TestNodeVitality(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	c := NewCLITest(TestCLIParams{})
+	defer c.Cleanup()
+
+	out, err := c.RunWithCapture("node vitality --format=csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	// Skip the command line.
+	if len(lines) != 3 {
+		t.Fatalf("expected a header and a row, got:\n%s", out)
+	}
+	if e, a := strings.Join(vitalityNodeColumnHeaders, ","), lines[1]; e != a {
+		t.Fatalf("expected header %q, got %q", e, a)
+	}
+	fields := strings.Split(lines[2], ",")
+	if len(fields) != len(vitalityNodeColumnHeaders) {
+		t.Fatalf("expected %d fields, got %q", len(vitalityNodeColumnHeaders), lines[2])
+	}
+	for i, e := range map[int]string{0: "1", 1: "live", 2: "1", 6: "false", 7: "active", 8: "none", 9: "true", 10: ""} {
+		if fields[i] != e {
+			t.Errorf("expected %s to be %q, got %q", vitalityNodeColumnHeaders[i], e, fields[i])
+		}
+	}
+	margin, err := time.ParseDuration(fields[4])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if margin <= 0 {
+		t.Errorf("expected a positive expiration margin, got %s", margin)
+	}
+}
+
+func TestNodeDoctor(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	c := NewCLITest(TestCLIParams{})
+	defer c.Cleanup()
+
+	out, err := c.RunWithCapture("node doctor --format=csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	// Skip the command line.
+	if e, a := strings.Join(doctorNodeColumnHeaders, ","), lines[1]; e != a {
+		t.Fatalf("expected header %q, got %q", e, a)
+	}
+	var checks []string
+	for _, line := range lines[2:] {
+		fields := strings.SplitN(line, ",", 3)
+		if len(fields) != 3 || fields[1] != "true" {
+			t.Errorf("expected a passing check, got %q", line)
+			continue
+		}
+		checks = append(checks, fields[0])
+	}
+	if e, a := "identity,clock,heartbeat,gossip", strings.Join(checks, ","); e != a {
+		t.Errorf("expected checks %s, got %s", e, a)
+	}
+}
+
+func TestParseLivenessSnapshotSource(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	asOf := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		in  string
+		exp serverpb.LivenessSnapshotSource
+		err bool
+	}{
+		{in: "kv", exp: serverpb.LivenessSnapshotSource{}},
+		{in: "kv@2023-06-01T12:00:00Z", exp: serverpb.LivenessSnapshotSource{AsOf: &asOf}},
+		{in: "n3", exp: serverpb.LivenessSnapshotSource{NodeID: "3"}},
+		{in: "local", exp: serverpb.LivenessSnapshotSource{NodeID: "local"}},
+		{in: "kv@yesterday", err: true},
+		{in: "nthree", err: true},
+		{in: "3", err: true},
+	} {
+		src, err := parseLivenessSnapshotSource(tc.in)
+		if tc.err {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %+v", tc.in, src)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.in, err)
+			continue
+		}
+		if src.NodeID != tc.exp.NodeID || (src.AsOf == nil) != (tc.exp.AsOf == nil) ||
+			(src.AsOf != nil && !src.AsOf.Equal(*tc.exp.AsOf)) {
+			t.Errorf("%s: expected %+v, got %+v", tc.in, tc.exp, src)
+		}
+	}
+}
+
+func TestNodeLivenessDiff(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	c := NewCLITest(TestCLIParams{})
+	defer c.Cleanup()
+
+	out, err := c.RunWithCapture("node liveness-diff kv local --format=csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	// Skip the command line.
+	if len(lines) != 3 {
+		t.Fatalf("expected a header and a row, got:\n%s", out)
+	}
+	if e, a := strings.Join(livenessDiffNodeColumnHeaders, ","), lines[1]; e != a {
+		t.Fatalf("expected header %q, got %q", e, a)
+	}
+	fields := strings.Split(lines[2], ",")
+	if len(fields) != len(livenessDiffNodeColumnHeaders) {
+		t.Fatalf("expected %d fields, got %q", len(livenessDiffNodeColumnHeaders), lines[2])
+	}
+	for i, e := range map[int]string{0: "1", 3: "1", 4: "1", 5: "active", 6: "active", 7: ""} {
+		if fields[i] != e {
+			t.Errorf("expected %s to be %q, got %q", livenessDiffNodeColumnHeaders[i], e, fields[i])
+		}
+	}
+}
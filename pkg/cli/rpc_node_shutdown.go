@@ -136,10 +136,13 @@ func doDrainNoTimeout(
 		// Send a drain request with the drain bit set and the shutdown bit
 		// unset.
 		stream, err := c.Drain(ctx, &serverpb.DrainRequest{
-			DoDrain:  true,
-			Shutdown: false,
-			NodeId:   targetNode,
-			Verbose:  verbose,
+			DoDrain:        true,
+			Shutdown:       false,
+			NodeId:         targetNode,
+			Verbose:        verbose,
+			Reason:         drainCtx.reason,
+			PlannedRestart: drainCtx.plannedRestart,
+			Sticky:         drainCtx.sticky,
 		})
 		if err != nil {
 			fmt.Fprintf(stderr, "\n") // finish the line started above.
@@ -185,6 +188,11 @@ func doDrainNoTimeout(
 				// (This can be revealed e.g. via --logtostderr.)
 				log.Infof(ctx, "drain details: %s\n", resp.DrainRemainingDescription)
 			}
+			if resp.DrainID != 0 {
+				// The progress and outcome of the drain can be looked up in the
+				// node's liveness record under this ID.
+				log.Infof(ctx, "drain ID: %d\n", resp.DrainID)
+			}
 
 			// Iterate until end of stream, which indicates the drain is
 			// complete.
@@ -207,6 +215,33 @@ func doDrainNoTimeout(
 	return false, remaining > 0, nil
 }
 
+// doUndrain clears a sticky drain of the target node and brings it back
+// into rotation.
+func doUndrain(ctx context.Context, c serverpb.AdminClient, targetNode string) error {
+	stream, err := c.Drain(ctx, &serverpb.DrainRequest{
+		Undrain: true,
+		NodeId:  targetNode,
+	})
+	if err != nil {
+		return errors.Wrap(err, "error sending undrain request")
+	}
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if grpcutil.IsWaitingForInit(err) {
+				return errors.New("node cannot be undrained before it has been initialized")
+			}
+			return err
+		}
+		if resp.IsDraining {
+			return errors.New("node is still draining")
+		}
+	}
+}
+
 // doShutdown attempts to trigger a server shutdown *without*
 // draining. Use doDrain() prior to perform a drain, or
 // drainAndShutdown() to combine both.
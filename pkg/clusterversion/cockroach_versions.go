@@ -542,6 +542,20 @@ const (
 	// that (optionally) embed below-raft admission data.
 	V23_2_UseACRaftEntryEntryEncodings
 
+	// V23_2_LivenessMembershipReason is the version where the liveness records
+	// carry the reason of the last membership change, which
+	// crdb_internal.gossip_liveness exposes.
+	V23_2_LivenessMembershipReason
+
+	// V23_2_DecommissionJob is the version where decommissions can be carried
+	// out by jobs, which all nodes know how to resume.
+	V23_2_DecommissionJob
+
+	// V23_2_LivenessRecordFields is the version where all nodes know about the
+	// fields of the liveness records added in 23.2, from the TTL of the
+	// heartbeats onwards (see livenesspb.Liveness.Normalize).
+	V23_2_LivenessRecordFields
+
 	// *************************************************
 	// Step (1) Add new versions here.
 	// Do not add new versions to a patch release.
@@ -943,6 +957,18 @@ var rawVersionsSingleton = keyedVersions{
 		Key:     V23_2_UseACRaftEntryEntryEncodings,
 		Version: roachpb.Version{Major: 23, Minor: 1, Internal: 10},
 	},
+	{
+		Key:     V23_2_LivenessMembershipReason,
+		Version: roachpb.Version{Major: 23, Minor: 1, Internal: 12},
+	},
+	{
+		Key:     V23_2_DecommissionJob,
+		Version: roachpb.Version{Major: 23, Minor: 1, Internal: 14},
+	},
+	{
+		Key:     V23_2_LivenessRecordFields,
+		Version: roachpb.Version{Major: 23, Minor: 1, Internal: 16},
+	},
 
 	// *************************************************
 	// Step (2): Add new versions here.
@@ -126,6 +126,11 @@ type Registry struct {
 	preventAdoptionFile     string
 	preventAdoptionLogEvery log.EveryN
 
+	// isEligibleCoordinator returns whether the given SQL instance is fit to
+	// adopt jobs and coordinate their execution, based on the vitality of its
+	// node. Instances that aren't hold off claiming jobs.
+	isEligibleCoordinator func(base.SQLInstanceID) bool
+
 	mu struct {
 		syncutil.Mutex
 
@@ -223,6 +228,7 @@ func MakeRegistry(
 	histogramWindowInterval time.Duration,
 	execCtxFn jobExecCtxMaker,
 	preventAdoptionFile string,
+	isEligibleCoordinator func(base.SQLInstanceID) bool,
 	td *tracedumper.TraceDumper,
 	knobs *TestingKnobs,
 ) *Registry {
@@ -238,6 +244,7 @@ func MakeRegistry(
 		execCtx:                 execCtxFn,
 		preventAdoptionFile:     preventAdoptionFile,
 		preventAdoptionLogEvery: log.Every(time.Minute),
+		isEligibleCoordinator:   isEligibleCoordinator,
 		td:                      td,
 		// Use a non-zero buffer to allow queueing of notifications.
 		// The writing method will use a default case to avoid blocking
@@ -285,6 +292,17 @@ func (r *Registry) CurrentlyRunningJobs() []jobspb.JobID {
 	return jobs
 }
 
+// IsEligibleCoordinator returns whether the given SQL instance is fit to
+// coordinate long-running work, such as jobs or changefeeds, based on the
+// vitality of its node: instances that are draining, decommissioning or
+// suspect are best avoided, since they may disappear soon.
+func (r *Registry) IsEligibleCoordinator(instanceID base.SQLInstanceID) bool {
+	if r.knobs.DisableVitalityChecks || r.isEligibleCoordinator == nil {
+		return true
+	}
+	return r.isEligibleCoordinator(instanceID)
+}
+
 // ID returns a unique during the lifetime of the registry id that is
 // used for keying sqlliveness claims held by the registry.
 func (r *Registry) ID() base.SQLInstanceID {
@@ -1063,6 +1081,7 @@ func (r *Registry) Start(ctx context.Context, stopper *stop.Stopper) error {
 	// claimJobs iterates the set of jobs which are not currently claimed and
 	// claims jobs up to maxAdoptionsPerLoop.
 	logDisabledAdoptionLimiter := log.Every(time.Minute)
+	logIneligibleLimiter := log.Every(time.Minute)
 	claimJobs := wrapWithSession(func(ctx context.Context, s sqlliveness.Session) {
 		if r.adoptionDisabled(ctx) {
 			if logDisabledAdoptionLimiter.ShouldLog() {
@@ -1070,6 +1089,14 @@ func (r *Registry) Start(ctx context.Context, stopper *stop.Stopper) error {
 			}
 			return
 		}
+		// Leave the jobs to the other nodes while this one is about to go
+		// away or just came back; the jobs it already runs are unaffected.
+		if !r.IsEligibleCoordinator(r.ID()) {
+			if logIneligibleLimiter.ShouldLog() {
+				log.Infof(ctx, "node is not fit to coordinate jobs, registry will not claim any jobs")
+			}
+			return
+		}
 		r.metrics.AdoptIterations.Inc(1)
 		if err := r.claimJobs(ctx, s); err != nil {
 			log.Errorf(ctx, "error claiming jobs: %s", err)
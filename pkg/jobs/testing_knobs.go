@@ -77,6 +77,10 @@ type TestingKnobs struct {
 	// validates that these knobs are used in tandem.
 	DisableAdoptions bool
 
+	// DisableVitalityChecks makes the registry consider every SQL instance fit
+	// to coordinate jobs, regardless of the vitality of its node.
+	DisableVitalityChecks bool
+
 	// DisableRegistryLifecycleManagement
 	DisableRegistryLifecycleManagent bool
 
@@ -293,6 +293,10 @@ var (
 	//
 	// TODO(postamar): remove along with clusterversion.V23_1DescIDSequenceForSystemTenant
 	LegacyDescIDGenerator = roachpb.Key(makeKey(SystemPrefix, roachpb.RKey("desc-idgen")))
+	// MembershipSpecKey is the key at which the desired membership of the nodes
+	// of the cluster, declared by an orchestrator, is stored along with the
+	// status of its reconciliation.
+	MembershipSpecKey = roachpb.Key(makeKey(SystemPrefix, roachpb.RKey("membership-spec")))
 	// NodeIDGenerator is the global node ID generator sequence.
 	NodeIDGenerator = roachpb.Key(makeKey(SystemPrefix, roachpb.RKey("node-idgen")))
 	// RangeIDGenerator is the global range ID generator sequence.
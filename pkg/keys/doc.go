@@ -245,6 +245,7 @@ var _ = [...]interface{}{
 	NodeLivenessPrefix,     // "\x00liveness-"
 	BootstrapVersionKey,    // "bootstrap-version"
 	LegacyDescIDGenerator,  // "desc-idgen"
+	MembershipSpecKey,      // "membership-spec"
 	NodeIDGenerator,        // "node-idgen"
 	RangeIDGenerator,       // "range-idgen"
 	StatusPrefix,           // "status-"
@@ -19,6 +19,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/cockroachdb/cockroach/pkg/keys"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvpb"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/allocator"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/allocator/load"
@@ -1980,6 +1981,31 @@ func (a *Allocator) ValidLeaseTargets(
 		candidates, false, /* includeSuspectAndDrainingStores */
 	)
 
+	// Exclude stores on nodes under memory pressure, which are likely to crash
+	// soon, and on nodes shedding their leases. The leaseholder remains a
+	// candidate, so that the lease isn't moved elsewhere only because its own
+	// node is under memory pressure; nodes shedding their leases are handled by
+	// the callers.
+	nonOverloaded := candidates[:0:0]
+	for _, repl := range candidates {
+		if repl.StoreID == leaseRepl.StoreID() {
+			nonOverloaded = append(nonOverloaded, repl)
+			continue
+		}
+		if storePool.IsUnderMemoryPressure(repl.StoreID) {
+			log.KvDistribution.VEventf(ctx, 3, "not considering s%d as a lease target: under memory pressure",
+				repl.StoreID)
+			continue
+		}
+		if storePool.ShouldShedLeases(repl.StoreID) {
+			log.KvDistribution.VEventf(ctx, 3, "not considering s%d as a lease target: shedding leases",
+				repl.StoreID)
+			continue
+		}
+		nonOverloaded = append(nonOverloaded, repl)
+	}
+	candidates = nonOverloaded
+
 	if a.knobs == nil || !a.knobs.AllowLeaseTransfersToReplicasNeedingSnapshots {
 		// Only proceed with the lease transfer if we are also the raft leader (we
 		// already know we are the leaseholder at this point), and only consider
@@ -2123,6 +2149,72 @@ func (a *Allocator) leaseholderShouldMoveDueToIOOverload(
 	return false
 }
 
+// livenessLeaseTarget returns the replica to transfer the lease for the node
+// liveness range to if the current leaseholder is unhealthy, or an empty
+// descriptor if the lease should stay where it is or the range isn't the
+// liveness range. The leaseholder is unhealthy if its store is suspect or its
+// IO overload score is at or above kv.allocator.liveness_lease_io_overload_threshold.
+// The replica on the healthiest store among the candidates is returned, as long
+// as its IO overload score is below LivenessLeaseHysteresis times the
+// threshold; without this margin, the lease could move back and forth between
+// two stores hovering around the threshold.
+//
+// The liveness range gets special treatment since the leaseholder for it being
+// unable to serve heartbeats promptly causes every node to lose its liveness,
+// and with it all its epoch-based leases.
+func (a *Allocator) livenessLeaseTarget(
+	ctx context.Context,
+	storePool storepool.AllocatorStorePool,
+	candidates []roachpb.ReplicaDescriptor,
+	leaseRepl interface {
+		StoreID() roachpb.StoreID
+		Desc() *roachpb.RangeDescriptor
+	},
+) roachpb.ReplicaDescriptor {
+	threshold := LivenessLeaseIOOverloadThreshold.Get(&a.st.SV)
+	if threshold == 0 || !leaseRepl.Desc().ContainsKey(roachpb.RKey(keys.NodeLivenessPrefix)) {
+		return roachpb.ReplicaDescriptor{}
+	}
+
+	storeIDs := append(replDescsToStoreIDs(candidates), leaseRepl.StoreID())
+	sl, _, _ := storePool.GetStoreListFromIDs(storeIDs, storepool.StoreFilterSuspect)
+	if store, ok := sl.FindStoreByID(leaseRepl.StoreID()); ok {
+		if score, _ := store.Capacity.IOThreshold.Score(); score < threshold {
+			return roachpb.ReplicaDescriptor{}
+		}
+	} else {
+		// A leaseholder store that was filtered out of the store list is
+		// suspect if it is part of the unfiltered list. Otherwise its status is
+		// unknown, e.g. because it hasn't gossiped yet, which is no reason to
+		// move the lease.
+		all, _, _ := storePool.GetStoreListFromIDs(
+			roachpb.StoreIDSlice{leaseRepl.StoreID()}, storepool.StoreFilterNone)
+		if _, ok := all.FindStoreByID(leaseRepl.StoreID()); !ok {
+			return roachpb.ReplicaDescriptor{}
+		}
+	}
+
+	var target roachpb.ReplicaDescriptor
+	targetScore := threshold * LivenessLeaseHysteresis
+	for _, repl := range candidates {
+		if repl.StoreID == leaseRepl.StoreID() {
+			continue
+		}
+		store, ok := sl.FindStoreByID(repl.StoreID)
+		if !ok {
+			continue
+		}
+		if score, _ := store.Capacity.IOThreshold.Score(); score < targetScore {
+			target, targetScore = repl, score
+		}
+	}
+	if target != (roachpb.ReplicaDescriptor{}) {
+		log.KvDistribution.VEventf(ctx, 2,
+			"liveness leaseholder s%d is unhealthy, moving lease to s%d", leaseRepl.StoreID(), target.StoreID)
+	}
+	return target
+}
+
 // leaseholderShouldMoveDueToPreferences returns true if the current leaseholder
 // is in violation of lease preferences _that can otherwise be satisfied_ by
 // some existing replica.
@@ -2192,6 +2284,7 @@ func (a *Allocator) IOOverloadOptions() IOOverloadOptions {
 		ReplicaIOOverloadThreshold:   ReplicaIOOverloadThreshold.Get(&a.st.SV),
 		LeaseIOOverloadThreshold:     LeaseIOOverloadThreshold.Get(&a.st.SV),
 		LeaseIOOverloadShedThreshold: LeaseIOOverloadShedThreshold.Get(&a.st.SV),
+		CompactionDebtThreshold:      storepool.CompactionDebtThreshold.Get(&a.st.SV),
 	}
 }
 
@@ -2227,10 +2320,11 @@ func (a *Allocator) TransferLeaseTarget(
 ) roachpb.ReplicaDescriptor {
 	excludeLeaseRepl := opts.ExcludeLeaseRepl
 	if a.leaseholderShouldMoveDueToPreferences(ctx, storePool, conf, leaseRepl, existing) ||
-		a.leaseholderShouldMoveDueToIOOverload(ctx, storePool, existing, leaseRepl.StoreID(), a.IOOverloadOptions()) {
+		a.leaseholderShouldMoveDueToIOOverload(ctx, storePool, existing, leaseRepl.StoreID(), a.IOOverloadOptions()) ||
+		storePool.ShouldShedLeases(leaseRepl.StoreID()) {
 		// Explicitly exclude the current leaseholder from the result set if it is
 		// in violation of lease preferences that can be satisfied by some other
-		// replica or is IO overloaded.
+		// replica, is IO overloaded or is on a node shedding its leases.
 		excludeLeaseRepl = true
 	}
 
@@ -2254,6 +2348,12 @@ func (a *Allocator) TransferLeaseTarget(
 		return roachpb.ReplicaDescriptor{}
 	}
 
+	// The lease for the liveness range moves to the healthiest replica if its
+	// leaseholder is unhealthy, irrespective of the goal.
+	if target := a.livenessLeaseTarget(ctx, storePool, validTargets, leaseRepl); target != (roachpb.ReplicaDescriptor{}) {
+		return target
+	}
+
 	switch g := opts.Goal; g {
 	case allocator.FollowTheWorkload:
 		// Try to pick a replica to transfer the lease to while also determining
@@ -2507,6 +2607,17 @@ func (a *Allocator) ShouldTransferLease(
 	if len(existing) == 0 || (len(existing) == 1 && existing[0].StoreID == leaseRepl.StoreID()) {
 		return false
 	}
+	if a.livenessLeaseTarget(ctx, storePool, existing, leaseRepl) != (roachpb.ReplicaDescriptor{}) {
+		return true
+	}
+	// An overloaded node asks for its leases to be moved elsewhere. The lease
+	// queue moves them one range at a time, as it gets to them, until the node
+	// stops asking.
+	if storePool.ShouldShedLeases(leaseRepl.StoreID()) {
+		log.KvDistribution.VEventf(ctx, 3, "ShouldTransferLease (lease-holder=s%d): shedding leases",
+			leaseRepl.StoreID())
+		return true
+	}
 	source, ok := storePool.GetStoreDescriptor(leaseRepl.StoreID())
 	if !ok {
 		return false
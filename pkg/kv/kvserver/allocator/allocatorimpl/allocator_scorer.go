@@ -87,6 +87,19 @@ const (
 	// typically used in conjunction with IOOverloadMeanThreshold below.
 	DefaultLeaseIOOverloadShedThreshold = 0.9
 
+	// DefaultLivenessLeaseIOOverloadThreshold is used to move the lease for the
+	// node liveness range off of stores with an IO overload score at or above
+	// this threshold. It is lower than the thresholds above since the liveness
+	// range is the most important range in the cluster.
+	DefaultLivenessLeaseIOOverloadThreshold = 0.3
+
+	// LivenessLeaseHysteresis is the fraction of the liveness lease IO overload
+	// threshold that the IO overload score of a store must be below to receive
+	// the lease for the node liveness range when it is moved off of an
+	// unhealthy store. The gap between the two thresholds prevents the lease
+	// from moving back and forth.
+	LivenessLeaseHysteresis = 0.5
+
 	// IOOverloadMeanThreshold is the percentage above the mean after which a
 	// store could be conisdered IO overload if also exceeding the absolute IO
 	// threshold.
@@ -203,6 +216,20 @@ var LeaseIOOverloadShedThreshold = settings.RegisterFloatSetting(
 	DefaultLeaseIOOverloadShedThreshold,
 )
 
+// LivenessLeaseIOOverloadThreshold is the IO overload score at or above which
+// the leaseholder store for the node liveness range proactively transfers the
+// lease to the healthiest replica, regardless of
+// LeaseIOOverloadThresholdEnforcement.
+var LivenessLeaseIOOverloadThreshold = settings.RegisterFloatSetting(
+	settings.SystemOnly,
+	"kv.allocator.liveness_lease_io_overload_threshold",
+	"the lease for the node liveness range is transferred away from a store whose IO "+
+		"overload score is at or above this value, or whose node is suspect, to a store "+
+		"whose IO overload score is below half this value; 0 disables",
+	DefaultLivenessLeaseIOOverloadThreshold,
+	settings.NonNegativeFloat,
+)
+
 // LeaseIOOverloadThresholdEnforcement defines the level of enforcement for
 // lease transfers when a candidate stores' IO overload exceeds the threshold
 // defined in IOOverloadThreshold, and additionally
@@ -2361,6 +2388,12 @@ type IOOverloadOptions struct {
 	ReplicaIOOverloadThreshold   float64
 	LeaseIOOverloadThreshold     float64
 	LeaseIOOverloadShedThreshold float64
+
+	// CompactionDebtThreshold is the IO overload score at or above which a
+	// store is degraded by compaction debt and receives no new leases,
+	// regardless of the IO overload of its peers. Zero disables the check. See
+	// storepool.HasCompactionDebt.
+	CompactionDebtThreshold float64
 }
 
 func ioOverloadCheck(
@@ -2428,13 +2461,23 @@ func (o IOOverloadOptions) rebalanceReplicaToCheck(
 }
 
 // transferLeaseToCheck returns true if the store IO overload does not exceed
-// the cluster threshold and mean, or the enforcement level does not prevent
-// lease transfers to IO overloaded stores.
+// the cluster threshold and mean, and the store isn't degraded by compaction
+// debt, or the enforcement level does not prevent lease transfers to IO
+// overloaded stores.
 func (o IOOverloadOptions) transferLeaseToCheck(
 	ctx context.Context, store roachpb.StoreDescriptor, avg float64,
 ) bool {
 	score, _ := store.Capacity.IOThreshold.Score()
 
+	// A store about to be throttled is no lease target, even if its peers are
+	// just as overloaded.
+	if o.LeaseEnforcementLevel != IOOverloadThresholdIgnore &&
+		storepool.HasCompactionDebt(store, o.CompactionDebtThreshold) {
+		log.KvDistribution.VEventf(ctx, 3, "s%d: degraded by compaction debt, io overload %.2f >= %.2f",
+			store.StoreID, score, o.CompactionDebtThreshold)
+		return false
+	}
+
 	if ok, reason := ioOverloadCheck(score, avg,
 		o.LeaseIOOverloadThreshold, IOOverloadMeanThreshold,
 		o.LeaseEnforcementLevel,
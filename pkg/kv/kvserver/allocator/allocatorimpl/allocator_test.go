@@ -1878,6 +1878,7 @@ type mockRepl struct {
 	replicationFactor     int32
 	storeID               roachpb.StoreID
 	replsInNeedOfSnapshot map[roachpb.ReplicaID]struct{}
+	desc                  *roachpb.RangeDescriptor // empty if nil
 }
 
 func (r *mockRepl) RaftStatus() *raft.Status {
@@ -1903,6 +1904,9 @@ func (r *mockRepl) StoreID() roachpb.StoreID {
 	return r.storeID
 }
 func (r *mockRepl) Desc() *roachpb.RangeDescriptor {
+	if r.desc != nil {
+		return r.desc
+	}
 	return &roachpb.RangeDescriptor{}
 }
 
@@ -1987,6 +1991,145 @@ func TestAllocatorTransferLeaseTarget(t *testing.T) {
 	}
 }
 
+func TestAllocatorTransferLeaseTargetMemoryPressure(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+	ctx := context.Background()
+	stopper, g, sp, a, _ := CreateTestAllocator(ctx, 10, true /* deterministic */)
+	defer stopper.Stop(ctx)
+
+	// 3 stores where the lease count for each store is equal to 10x the store
+	// ID. The node of store 1 is under memory pressure.
+	var stores []*roachpb.StoreDescriptor
+	for i := 1; i <= 3; i++ {
+		stores = append(stores, &roachpb.StoreDescriptor{
+			StoreID:  roachpb.StoreID(i),
+			Node:     roachpb.NodeDescriptor{NodeID: roachpb.NodeID(i)},
+			Capacity: roachpb.StoreCapacity{LeaseCount: int32(10 * i)},
+		})
+	}
+	sg := gossiputil.NewStoreGossiper(g)
+	sg.GossipStores(stores, t)
+	sp.NodeVitalityFn = func(nodeID roachpb.NodeID) (livenesspb.NodeVitality, bool) {
+		var v livenesspb.NodeVitality
+		v.Expiration = hlc.MaxTimestamp.ToLegacyTimestamp()
+		v.MemoryPressure = nodeID == 1
+		return v, true
+	}
+
+	existing := []roachpb.ReplicaDescriptor{
+		{StoreID: 1, ReplicaID: 1},
+		{StoreID: 2, ReplicaID: 2},
+		{StoreID: 3, ReplicaID: 3},
+	}
+
+	testCases := []struct {
+		leaseholder      roachpb.StoreID
+		excludeLeaseRepl bool
+		expected         roachpb.StoreID
+	}{
+		// Store 1 would be the target, but is under memory pressure.
+		{leaseholder: 2, excludeLeaseRepl: true, expected: 3},
+		{leaseholder: 3, excludeLeaseRepl: true, expected: 2},
+		// Store 1 keeps its lease though.
+		{leaseholder: 1, excludeLeaseRepl: false, expected: 0},
+	}
+	for _, c := range testCases {
+		t.Run("", func(t *testing.T) {
+			target := a.TransferLeaseTarget(
+				ctx,
+				sp,
+				emptySpanConfig(),
+				existing,
+				&mockRepl{
+					replicationFactor: 3,
+					storeID:           c.leaseholder,
+				},
+				allocator.RangeUsageInfo{}, /* stats */
+				false,                      /* forceDecisionWithoutStats */
+				allocator.TransferLeaseOptions{
+					ExcludeLeaseRepl:       c.excludeLeaseRepl,
+					CheckCandidateFullness: true,
+				},
+			)
+			require.Equal(t, c.expected, target.StoreID)
+		})
+	}
+}
+
+func TestAllocatorShedLeases(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+	ctx := context.Background()
+	stopper, g, sp, a, _ := CreateTestAllocator(ctx, 10, true /* deterministic */)
+	defer stopper.Stop(ctx)
+
+	// 3 stores with the same lease count. The node of store 2 asks for its
+	// leases to be moved elsewhere.
+	var stores []*roachpb.StoreDescriptor
+	for i := 1; i <= 3; i++ {
+		stores = append(stores, &roachpb.StoreDescriptor{
+			StoreID:  roachpb.StoreID(i),
+			Node:     roachpb.NodeDescriptor{NodeID: roachpb.NodeID(i)},
+			Capacity: roachpb.StoreCapacity{LeaseCount: 10},
+		})
+	}
+	sg := gossiputil.NewStoreGossiper(g)
+	sg.GossipStores(stores, t)
+	shedding := map[roachpb.NodeID]bool{2: true}
+	sp.NodeVitalityFn = func(nodeID roachpb.NodeID) (livenesspb.NodeVitality, bool) {
+		var v livenesspb.NodeVitality
+		v.Expiration = hlc.MaxTimestamp.ToLegacyTimestamp()
+		v.ShedLeases = shedding[nodeID]
+		return v, true
+	}
+
+	existing := []roachpb.ReplicaDescriptor{
+		{StoreID: 1, ReplicaID: 1},
+		{StoreID: 2, ReplicaID: 2},
+		{StoreID: 3, ReplicaID: 3},
+	}
+	transferLeaseTarget := func(leaseholder roachpb.StoreID, excludeLeaseRepl bool) roachpb.StoreID {
+		return a.TransferLeaseTarget(
+			ctx,
+			sp,
+			emptySpanConfig(),
+			existing,
+			&mockRepl{
+				replicationFactor: 3,
+				storeID:           leaseholder,
+			},
+			allocator.RangeUsageInfo{}, /* stats */
+			false,                      /* forceDecisionWithoutStats */
+			allocator.TransferLeaseOptions{
+				ExcludeLeaseRepl:       excludeLeaseRepl,
+				CheckCandidateFullness: true,
+			},
+		).StoreID
+	}
+	shouldTransferLease := func(leaseholder roachpb.StoreID) bool {
+		return a.ShouldTransferLease(ctx, sp, emptySpanConfig(), existing, &mockRepl{
+			replicationFactor: 3,
+			storeID:           leaseholder,
+		}, allocator.RangeUsageInfo{})
+	}
+
+	// The leases of store 2 move elsewhere, even though the lease counts are
+	// balanced.
+	require.True(t, shouldTransferLease(2))
+	require.Contains(t, []roachpb.StoreID{1, 3}, transferLeaseTarget(2, false /* excludeLeaseRepl */))
+
+	// The leases of other stores don't move to store 2.
+	require.False(t, shouldTransferLease(1))
+	require.Equal(t, roachpb.StoreID(3), transferLeaseTarget(1, true /* excludeLeaseRepl */))
+	require.Equal(t, roachpb.StoreID(1), transferLeaseTarget(3, true /* excludeLeaseRepl */))
+
+	// When most nodes ask for their leases to be moved, none are.
+	shedding[3] = true
+	require.False(t, shouldTransferLease(2))
+	require.False(t, shouldTransferLease(3))
+}
+
 func TestAllocatorTransferLeaseTargetIOOverloadCheck(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 	defer log.Scope(t).Close(t)
@@ -1998,8 +2141,11 @@ func TestAllocatorTransferLeaseTargetIOOverloadCheck(t *testing.T) {
 
 	// We want the shed threshold to be 0.9 and the overload threhsold to be 0.5
 	// i.e. block transfers at >=0.5 and block transfers + shed leases at >=0.9.
+	// Stores are degraded by compaction debt at >=0.8, which blocks transfers
+	// regardless of the mean.
 	const shedThreshold = 0.9
 	const threshold = 0.5
+	const compactionDebtThreshold = 0.8
 
 	testCases := []struct {
 		name                  string
@@ -2067,6 +2213,14 @@ func TestAllocatorTransferLeaseTargetIOOverloadCheck(t *testing.T) {
 			expected:    0,
 			enforcement: IOOverloadThresholdShed,
 		},
+		{
+			name:        "don't transfer to store with compaction debt even if as overloaded as the mean",
+			leaseCounts: floats(0, 0, 100, 400, 400),
+			IOScores:    floats(0.85, 0.85, 0.7, 0.85, 0.85),
+			leaseholder: 5,
+			expected:    3,
+			enforcement: IOOverloadThresholdBlockTransfers,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -2092,6 +2246,7 @@ func TestAllocatorTransferLeaseTargetIOOverloadCheck(t *testing.T) {
 			LeaseIOOverloadThresholdEnforcement.Override(ctx, &a.st.SV, int64(tc.enforcement))
 			LeaseIOOverloadThreshold.Override(ctx, &a.st.SV, threshold)
 			LeaseIOOverloadShedThreshold.Override(ctx, &a.st.SV, shedThreshold)
+			storepool.CompactionDebtThreshold.Override(ctx, &a.st.SV, compactionDebtThreshold)
 
 			target := a.TransferLeaseTarget(
 				ctx,
@@ -2114,6 +2269,128 @@ func TestAllocatorTransferLeaseTargetIOOverloadCheck(t *testing.T) {
 
 }
 
+func TestAllocatorTransferLivenessLeaseUnhealthyLeaseholder(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+	ctx := context.Background()
+
+	floats := func(nums ...float64) []float64 {
+		return nums
+	}
+	livenessDesc := &roachpb.RangeDescriptor{
+		StartKey: roachpb.RKeyMin,
+		EndKey:   roachpb.RKey(keys.NodeLivenessKeyMax),
+	}
+	otherDesc := &roachpb.RangeDescriptor{
+		StartKey: roachpb.RKey(keys.NodeLivenessKeyMax),
+		EndKey:   roachpb.RKeyMax,
+	}
+
+	// The lease moves off of stores with an IO overload score of 0.3 or more,
+	// to stores with a score below 0.15.
+	const threshold = 0.3
+
+	testCases := []struct {
+		name        string
+		desc        *roachpb.RangeDescriptor
+		IOScores    []float64
+		leaseholder roachpb.StoreID
+		// ungossiped is set if the store of the leaseholder hasn't gossiped
+		// its descriptor, which makes its status unknown.
+		ungossiped bool
+		expected   roachpb.StoreID
+	}{
+		{
+			name:        "healthy leaseholder",
+			desc:        livenessDesc,
+			IOScores:    floats(0.25, 0.1, 0, 0),
+			leaseholder: 1,
+			expected:    0,
+		},
+		{
+			name:        "unhealthy leaseholder moves to healthiest store",
+			desc:        livenessDesc,
+			IOScores:    floats(0.4, 0.1, 0.05, 0.12),
+			leaseholder: 1,
+			expected:    3,
+		},
+		{
+			name:        "no store healthy enough",
+			desc:        livenessDesc,
+			IOScores:    floats(0.4, 0.2, 0.15, 0.25),
+			leaseholder: 1,
+			expected:    0,
+		},
+		{
+			name:        "unknown leaseholder",
+			desc:        livenessDesc,
+			IOScores:    floats(0.4, 0.1, 0.05, 0.12),
+			leaseholder: 1,
+			ungossiped:  true,
+			expected:    0,
+		},
+		{
+			name:        "not the liveness range",
+			desc:        otherDesc,
+			IOScores:    floats(0.4, 0.1, 0.05, 0.12),
+			leaseholder: 1,
+			expected:    0,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			stopper, g, sp, a, _ := CreateTestAllocator(ctx, 10, true /* deterministic */)
+			defer stopper.Stop(ctx)
+			n := len(tc.IOScores)
+			stores := make([]*roachpb.StoreDescriptor, n)
+			existing := make([]roachpb.ReplicaDescriptor, 0, n)
+			for i := range tc.IOScores {
+				existing = append(existing, replicas(roachpb.StoreID(i+1))...)
+				stores[i] = &roachpb.StoreDescriptor{
+					StoreID: roachpb.StoreID(i + 1),
+					Node:    roachpb.NodeDescriptor{NodeID: roachpb.NodeID(i + 1)},
+					Capacity: roachpb.StoreCapacity{
+						// Balanced lease counts, so that the lease would otherwise stay.
+						LeaseCount:  100,
+						IOThreshold: TestingIOThresholdWithScore(tc.IOScores[i]),
+					},
+				}
+			}
+
+			if tc.ungossiped {
+				stores = stores[1:]
+			}
+			sg := gossiputil.NewStoreGossiper(g)
+			sg.GossipStores(stores, t)
+			LeaseIOOverloadThresholdEnforcement.Override(ctx, &a.st.SV, int64(IOOverloadThresholdIgnore))
+			LivenessLeaseIOOverloadThreshold.Override(ctx, &a.st.SV, threshold)
+
+			repl := &mockRepl{
+				replicationFactor: int32(n),
+				storeID:           tc.leaseholder,
+				desc:              tc.desc,
+			}
+			shouldTransfer := a.ShouldTransferLease(
+				ctx, sp, emptySpanConfig(), existing, repl, allocator.RangeUsageInfo{},
+			)
+			require.Equal(t, tc.expected != 0, shouldTransfer)
+			target := a.TransferLeaseTarget(
+				ctx,
+				sp,
+				emptySpanConfig(),
+				existing,
+				repl,
+				allocator.RangeUsageInfo{}, /* stats */
+				false,                      /* forceDecisionWithoutStats */
+				allocator.TransferLeaseOptions{
+					CheckCandidateFullness: true,
+				},
+			)
+			require.Equal(t, tc.expected, target.StoreID)
+		})
+	}
+}
+
 func TestAllocatorTransferLeaseToReplicasNeedingSnapshot(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 	defer log.Scope(t).Close(t)
@@ -187,6 +187,16 @@ func (o *OverrideStorePool) GetStoreDescriptor(
 	return o.sp.GetStoreDescriptor(storeID)
 }
 
+// IsUnderMemoryPressure implements the AllocatorStorePool interface.
+func (o *OverrideStorePool) IsUnderMemoryPressure(storeID roachpb.StoreID) bool {
+	return o.sp.IsUnderMemoryPressure(storeID)
+}
+
+// ShouldShedLeases implements the AllocatorStorePool interface.
+func (o *OverrideStorePool) ShouldShedLeases(storeID roachpb.StoreID) bool {
+	return o.sp.ShouldShedLeases(storeID)
+}
+
 // UpdateLocalStoreAfterRebalance implements the AllocatorStorePool interface.
 // This override method is a no-op, as
 // StorePool.UpdateLocalStoreAfterRebalance(..) is not a read-only method and
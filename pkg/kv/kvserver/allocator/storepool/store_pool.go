@@ -68,6 +68,33 @@ var TimeAfterStoreSuspect = settings.RegisterDurationSetting(
 	},
 )
 
+// CompactionDebtThreshold is the IO overload score at or above which a store is
+// considered degraded by compaction debt. See HasCompactionDebt.
+var CompactionDebtThreshold = settings.RegisterFloatSetting(
+	settings.SystemOnly,
+	"kv.allocator.compaction_debt_threshold",
+	"the IO overload score, i.e. the larger of the L0 sub-level count (read amplification) and "+
+		"the L0 file count of a store as a fraction of the counts at which admission control "+
+		"throttles writes, at or above which the store is considered degraded by compaction debt "+
+		"and receives no new leases unless "+
+		"`kv.allocator.lease_io_overload_threshold_enforcement` is `ignore`; 0 disables",
+	0.8,
+	settings.NonNegativeFloat,
+)
+
+// HasCompactionDebt returns whether the LSM of the given store is unhealthy
+// enough, per its read amplification and L0 file count, that the store is
+// about to have its writes throttled by admission control, i.e. whether its
+// IO overload score is at or above the given threshold. A zero threshold
+// disables the check.
+func HasCompactionDebt(desc roachpb.StoreDescriptor, threshold float64) bool {
+	if threshold == 0 {
+		return false
+	}
+	score, _ := desc.Capacity.IOThreshold.Score()
+	return score >= threshold
+}
+
 // The NodeCountFunc returns a count of the total number of nodes the user
 // intends for their to be in the cluster. The count includes dead nodes, but
 // not decommissioned nodes.
@@ -81,77 +108,55 @@ type NodeLivenessFunc func(
 ) livenesspb.NodeLivenessStatus
 
 // MakeStorePoolNodeLivenessFunc returns a function which determines
-// the status of a node based on information provided by the specified
-// NodeLiveness.
+// the status of a node based on its vitality, as provided by the specified
+// NodeLiveness. A node whose liveness record is live but which is unreachable
+// over RPC is thus considered unavailable.
 func MakeStorePoolNodeLivenessFunc(nodeLiveness *liveness.NodeLiveness) NodeLivenessFunc {
 	return func(
 		nodeID roachpb.NodeID, now hlc.Timestamp, timeUntilStoreDead time.Duration,
 	) livenesspb.NodeLivenessStatus {
-		liveness, ok := nodeLiveness.GetLiveness(nodeID)
+		vitality, ok := nodeLiveness.GetNodeVitality(nodeID)
 		if !ok {
 			return livenesspb.NodeLivenessStatus_UNKNOWN
 		}
-		return LivenessStatus(liveness.Liveness, now, timeUntilStoreDead)
+		return allocatorNodeStatus(vitality, now, timeUntilStoreDead)
+	}
+}
+
+// An awaitingRestartFunc accepts a node ID and returns whether the node is
+// down for a planned restart it isn't expected back from yet, as of the given
+// time. See livenesspb.Liveness.AwaitingRestart.
+type awaitingRestartFunc func(nid roachpb.NodeID, now hlc.Timestamp) bool
+
+// A NodeVitalityFunc accepts a node ID and returns the vitality of the node,
+// or false if it is unknown.
+type NodeVitalityFunc func(nid roachpb.NodeID) (livenesspb.NodeVitality, bool)
+
+// allocatorNodeStatus returns the status of the node with the given vitality,
+// as far as the allocator is concerned. This is the status of the vitality,
+// with the time until the node is considered dead overridden for the node if
+// an operator declared so (see livenesspb.Liveness.TimeUntilDead), and except
+// that a node that is down for a planned restart isn't considered dead until
+// it is expected back, so that routine restarts don't cause its replicas to be
+// moved elsewhere.
+func allocatorNodeStatus(
+	vitality livenesspb.NodeVitality, now hlc.Timestamp, timeUntilStoreDead time.Duration,
+) livenesspb.NodeLivenessStatus {
+	deadThreshold := vitality.TimeUntilDead(now, timeUntilStoreDead)
+	if vitality.LifecycleState(now, deadThreshold) == livenesspb.NodeLifecycleState_DEAD &&
+		vitality.AwaitingRestart(now) {
+		return livenesspb.NodeLivenessStatus_UNAVAILABLE
 	}
+	return vitality.Status(now, deadThreshold)
 }
 
 // LivenessStatus returns a NodeLivenessStatus enumeration value for the
-// provided Liveness based on the provided timestamp and threshold.
-//
-// See the note on IsLive() for considerations on what should be passed in as
-// `now`.
-//
-// The timeline of the states that a liveness goes through as time passes after
-// the respective liveness record is written is the following:
-//
-//	-----|-------LIVE---|------UNAVAILABLE---|------DEAD------------> time
-//	     tWrite         tExp                 tExp+threshold
-//
-// Explanation:
-//
-//   - Let's say a node write its liveness record at tWrite. It sets the
-//     Expiration field of the record as tExp=tWrite+livenessThreshold.
-//     The node is considered LIVE (or DECOMMISSIONING or DRAINING).
-//   - At tExp, the IsLive() method starts returning false. The state becomes
-//     UNAVAILABLE (or stays DECOMMISSIONING or DRAINING).
-//   - Once threshold passes, the node is considered DEAD (or DECOMMISSIONED).
-//
-// NB: There's a bit of discrepancy between what "Decommissioned" represents, as
-// seen by NodeStatusLiveness, and what "Decommissioned" represents as
-// understood by MembershipStatus. Currently it's possible for a live node, that
-// was marked as fully decommissioned, to have a NodeLivenessStatus of
-// "Decommissioning". This was kept this way for backwards compatibility, and
-// ideally we should remove usage of NodeLivenessStatus altogether. See #50707
-// for more details.
+// provided Liveness based on the provided timestamp and threshold. See
+// livenesspb.Liveness.Status.
 func LivenessStatus(
 	l livenesspb.Liveness, now hlc.Timestamp, deadThreshold time.Duration,
 ) livenesspb.NodeLivenessStatus {
-	// If we don't have a liveness expiration time, treat the status as unknown.
-	// This is different than unavailable as it doesn't transition through being
-	// marked as suspect. In unavailable we still won't transfer leases or
-	// replicas to it in this state. A node that is in UNKNOWN status can
-	// immediately transition to Available once it passes a liveness heartbeat.
-	if l.Expiration.WallTime == 0 {
-		return livenesspb.NodeLivenessStatus_UNKNOWN
-	}
-
-	if l.IsDead(now, deadThreshold) {
-		if !l.Membership.Active() {
-			return livenesspb.NodeLivenessStatus_DECOMMISSIONED
-		}
-		return livenesspb.NodeLivenessStatus_DEAD
-	}
-	if l.IsLive(now) {
-		if !l.Membership.Active() {
-			return livenesspb.NodeLivenessStatus_DECOMMISSIONING
-		}
-		if l.Draining {
-			return livenesspb.NodeLivenessStatus_DRAINING
-		}
-		return livenesspb.NodeLivenessStatus_LIVE
-	}
-	// Not yet dead, but has not heartbeated recently enough to be alive either.
-	return livenesspb.NodeLivenessStatus_UNAVAILABLE
+	return l.Status(now, deadThreshold)
 }
 
 // StoreDetail groups together store-relevant details.
@@ -205,6 +210,7 @@ func (sd *StoreDetail) status(
 	now hlc.Timestamp,
 	deadThreshold time.Duration,
 	nl NodeLivenessFunc,
+	awaitingRestart awaitingRestartFunc,
 	suspectDuration time.Duration,
 ) storeStatus {
 	// During normal operation, we expect the state transitions for stores to look like the following:
@@ -232,8 +238,13 @@ func (sd *StoreDetail) status(
 	// within the liveness threshold. Note that LastUpdatedTime is set
 	// when the store detail is created and will have a non-zero value
 	// even before the first gossip arrives for a store.
+	//
+	// A node that is down for a planned restart doesn't gossip either; until it
+	// is expected back (see allocatorNodeStatus), neither does the lack of
+	// gossip make its stores dead.
 	deadAsOf := sd.LastUpdatedTime.AddDuration(deadThreshold)
-	if now.After(deadAsOf) {
+	if now.After(deadAsOf) && (sd.Desc == nil || awaitingRestart == nil ||
+		!awaitingRestart(sd.Desc.Node.NodeID, now)) {
 		sd.LastUnavailable = now
 		return storeStatusDead
 	}
@@ -359,6 +370,16 @@ type AllocatorStorePool interface {
 		filter StoreFilter,
 	) (StoreList, int, ThrottledStoreReasons)
 
+	// IsUnderMemoryPressure returns whether the node of the given store is
+	// under memory pressure.
+	// See comment on StorePool.IsUnderMemoryPressure(..).
+	IsUnderMemoryPressure(storeID roachpb.StoreID) bool
+
+	// ShouldShedLeases returns whether the node of the given store asked for
+	// its leases to be moved elsewhere.
+	// See comment on StorePool.ShouldShedLeases(..).
+	ShouldShedLeases(storeID roachpb.StoreID) bool
+
 	// LiveAndDeadReplicas divides the provided repls slice into two slices: the
 	// first for live replicas, and the second for dead replicas.
 	// See comment on StorePool.LiveAndDeadReplicas(..).
@@ -432,6 +453,12 @@ type StorePool struct {
 		onChange []CapacityChangeFn
 	}
 
+	// NodeVitalityFn, if set, is used to look up the overload signals the node
+	// of a store advertises in its liveness record (see IsUnderMemoryPressure
+	// and ShouldShedLeases), and when the node last came back up after being
+	// unavailable.
+	NodeVitalityFn NodeVitalityFunc
+
 	// OverrideIsStoreReadyForRoutineReplicaTransferFn, if set, is used in
 	// IsStoreReadyForRoutineReplicaTransfer. This is defined as a closure reference here instead
 	// of a regular method so it can be overridden in tests.
@@ -496,8 +523,9 @@ func (sp *StorePool) statusString(nl NodeLivenessFunc) string {
 
 	for _, id := range ids {
 		detail := sp.DetailsMu.StoreDetails[id]
+		sp.forwardLastUnavailableLocked(detail)
 		fmt.Fprintf(&buf, "%d", id)
-		status := detail.status(now, timeUntilStoreDead, nl, timeAfterStoreSuspect)
+		status := detail.status(now, timeUntilStoreDead, nl, sp.awaitingRestart, timeAfterStoreSuspect)
 		if status != storeStatusAvailable {
 			fmt.Fprintf(&buf, " (status=%d)", status)
 		}
@@ -760,7 +788,7 @@ func (sp *StorePool) GetStoreDescriptor(storeID roachpb.StoreID) (roachpb.StoreD
 	defer sp.DetailsMu.RUnlock()
 
 	if detail, ok := sp.DetailsMu.StoreDetails[storeID]; ok && detail.Desc != nil {
-		return *detail.Desc, true
+		return sp.digestedDescriptorLocked(detail), true
 	}
 	return roachpb.StoreDescriptor{}, false
 }
@@ -789,7 +817,8 @@ func (sp *StorePool) decommissioningReplicasWithLiveness(
 
 	for _, repl := range repls {
 		detail := sp.GetStoreDetailLocked(repl.StoreID)
-		switch detail.status(now, timeUntilStoreDead, nl, timeAfterStoreSuspect) {
+		sp.forwardLastUnavailableLocked(detail)
+		switch detail.status(now, timeUntilStoreDead, nl, sp.awaitingRestart, timeAfterStoreSuspect) {
 		case storeStatusDecommissioning:
 			decommissioningReplicas = append(decommissioningReplicas, repl)
 		}
@@ -864,6 +893,127 @@ func (sp *StorePool) IsDraining(storeID roachpb.StoreID) (bool, error) {
 	return status == storeStatusDraining, nil
 }
 
+// IsSuspect returns true if the given store's status is `storeStatusSuspect`
+// or an error if the store is not found in the pool.
+func (sp *StorePool) IsSuspect(storeID roachpb.StoreID) (bool, error) {
+	status, err := sp.storeStatus(storeID, sp.NodeLivenessFn)
+	if err != nil {
+		return false, err
+	}
+	return status == storeStatusSuspect, nil
+}
+
+// HasCompactionDebt returns whether the given store is degraded by compaction
+// debt (see the HasCompactionDebt function), or an error if the store is not
+// found in the pool.
+func (sp *StorePool) HasCompactionDebt(storeID roachpb.StoreID) (bool, error) {
+	desc, ok := sp.GetStoreDescriptor(storeID)
+	if !ok {
+		return false, errors.Errorf("store %d was not found", storeID)
+	}
+	return HasCompactionDebt(desc, CompactionDebtThreshold.Get(&sp.st.SV)), nil
+}
+
+// IsUnderMemoryPressure returns whether the node of the given store reported
+// being under memory pressure when it last heartbeated its liveness record, in
+// which case the store shouldn't receive leases. Returns false if the store is
+// not found in the pool or NodeVitalityFn isn't set.
+func (sp *StorePool) IsUnderMemoryPressure(storeID roachpb.StoreID) bool {
+	vitality, ok := sp.storeNodeVitality(storeID)
+	// The memory pressure of a node that stopped heartbeating is stale.
+	return ok && vitality.MemoryPressure && vitality.IsLive(sp.clock.Now())
+}
+
+// ShouldShedLeases returns whether the node of the given store asked for its
+// leases to be moved elsewhere when it last heartbeated its liveness record,
+// because it is overloaded. Such a store shouldn't receive leases either.
+//
+// The node is only considered overloaded relative to its peers: when at least
+// half of the live nodes ask for their leases to be moved, the whole cluster is
+// overloaded, and moving leases around would only add to the load. Returns
+// false if the store is not found in the pool or NodeVitalityFn isn't set.
+func (sp *StorePool) ShouldShedLeases(storeID roachpb.StoreID) bool {
+	vitality, ok := sp.storeNodeVitality(storeID)
+	now := sp.clock.Now()
+	if !ok || !vitality.ShedLeases || !vitality.IsLive(now) {
+		return false
+	}
+	var live, shedding int
+	seen := make(map[roachpb.NodeID]struct{})
+	for _, desc := range sp.GetStores() {
+		nodeID := desc.Node.NodeID
+		if _, ok := seen[nodeID]; ok {
+			continue
+		}
+		seen[nodeID] = struct{}{}
+		if v, ok := sp.NodeVitalityFn(nodeID); ok && v.IsLive(now) {
+			live++
+			if v.ShedLeases {
+				shedding++
+			}
+		}
+	}
+	return 2*shedding < live
+}
+
+// forwardLastUnavailableLocked forwards the time at which the given store was
+// last unavailable to the one recorded in the liveness record of its node (see
+// livenesspb.Liveness.LastUnavailable), which survives restarts of the node
+// and of the store pool, unlike the store pool's own observations. Nothing is
+// done if NodeVitalityFn isn't set.
+func (sp *StorePool) forwardLastUnavailableLocked(sd *StoreDetail) {
+	if sp.NodeVitalityFn == nil || sd.Desc == nil {
+		return
+	}
+	if vitality, ok := sp.NodeVitalityFn(sd.Desc.Node.NodeID); ok {
+		sd.LastUnavailable.Forward(vitality.LastUnavailable)
+	}
+}
+
+// awaitingRestart returns whether the given node is down for a planned restart
+// it isn't expected back from yet, as of the given time. It is false if
+// NodeVitalityFn isn't set.
+func (sp *StorePool) awaitingRestart(nodeID roachpb.NodeID, now hlc.Timestamp) bool {
+	if sp.NodeVitalityFn == nil {
+		return false
+	}
+	vitality, ok := sp.NodeVitalityFn(nodeID)
+	return ok && vitality.AwaitingRestart(now)
+}
+
+// digestedDescriptorLocked returns a copy of the descriptor of the given
+// store, whose capacity is updated with the digest the node of the store
+// recorded in its liveness record (see livenesspb.Liveness.StoreDigests) if
+// the digest is more recent than the descriptor. The descriptor must be set.
+func (sp *StorePool) digestedDescriptorLocked(sd *StoreDetail) roachpb.StoreDescriptor {
+	desc := *sd.Desc
+	if sp.NodeVitalityFn == nil {
+		return desc
+	}
+	vitality, ok := sp.NodeVitalityFn(desc.Node.NodeID)
+	if !ok {
+		return desc
+	}
+	if digest, ok := vitality.StoreDigest(desc.StoreID); ok && sd.LastUpdatedTime.Less(digest.Timestamp) {
+		digest.ApplyTo(&desc.Capacity)
+	}
+	return desc
+}
+
+// storeNodeVitality returns the vitality of the node of the given store.
+func (sp *StorePool) storeNodeVitality(
+	storeID roachpb.StoreID,
+) (livenesspb.NodeVitality, bool) {
+	if sp.NodeVitalityFn == nil {
+		return livenesspb.NodeVitality{}, false
+	}
+	desc, ok := sp.GetStoreDescriptor(storeID)
+	if !ok {
+		return livenesspb.NodeVitality{}, false
+	}
+	return sp.NodeVitalityFn(desc.Node.NodeID)
+}
+
 // IsLive returns true if the node is considered alive by the store pool or an error
 // if the store is not found in the pool.
 func (sp *StorePool) IsLive(storeID roachpb.StoreID) (bool, error) {
@@ -906,7 +1056,8 @@ func (sp *StorePool) storeStatus(
 	now := sp.clock.Now()
 	timeUntilStoreDead := liveness.TimeUntilStoreDead.Get(&sp.st.SV)
 	timeAfterStoreSuspect := TimeAfterStoreSuspect.Get(&sp.st.SV)
-	return sd.status(now, timeUntilStoreDead, nl, timeAfterStoreSuspect), nil
+	sp.forwardLastUnavailableLocked(sd)
+	return sd.status(now, timeUntilStoreDead, nl, sp.awaitingRestart, timeAfterStoreSuspect), nil
 }
 
 // LiveAndDeadReplicas divides the provided repls slice into two slices: the
@@ -943,8 +1094,9 @@ func (sp *StorePool) liveAndDeadReplicasWithLiveness(
 
 	for _, repl := range repls {
 		detail := sp.GetStoreDetailLocked(repl.StoreID)
+		sp.forwardLastUnavailableLocked(detail)
 		// Mark replica as dead if store is dead.
-		status := detail.status(now, timeUntilStoreDead, nl, timeAfterStoreSuspect)
+		status := detail.status(now, timeUntilStoreDead, nl, sp.awaitingRestart, timeAfterStoreSuspect)
 		switch status {
 		case storeStatusDead:
 			deadReplicas = append(deadReplicas, repl)
@@ -1223,23 +1375,24 @@ func (sp *StorePool) getStoreListFromIDsLocked(
 			// Do nothing; this store is not in the StorePool.
 			continue
 		}
-		switch s := detail.status(now, timeUntilStoreDead, nl, timeAfterStoreSuspect); s {
+		sp.forwardLastUnavailableLocked(detail)
+		switch s := detail.status(now, timeUntilStoreDead, nl, sp.awaitingRestart, timeAfterStoreSuspect); s {
 		case storeStatusThrottled:
 			aliveStoreCount++
 			throttled = append(throttled, detail.throttledBecause)
 			if filter != StoreFilterThrottled {
-				storeDescriptors = append(storeDescriptors, *detail.Desc)
+				storeDescriptors = append(storeDescriptors, sp.digestedDescriptorLocked(detail))
 			}
 		case storeStatusAvailable:
 			aliveStoreCount++
-			storeDescriptors = append(storeDescriptors, *detail.Desc)
+			storeDescriptors = append(storeDescriptors, sp.digestedDescriptorLocked(detail))
 		case storeStatusDraining:
 			throttled = append(throttled, fmt.Sprintf("s%d: draining", storeID))
 		case storeStatusSuspect:
 			aliveStoreCount++
 			throttled = append(throttled, fmt.Sprintf("s%d: suspect", storeID))
 			if filter != StoreFilterThrottled && filter != StoreFilterSuspect {
-				storeDescriptors = append(storeDescriptors, *detail.Desc)
+				storeDescriptors = append(storeDescriptors, sp.digestedDescriptorLocked(detail))
 			}
 		case storeStatusDead, storeStatusUnknown, storeStatusDecommissioning:
 			// Do nothing; this store cannot be used.
@@ -620,7 +620,7 @@ func TestStorePoolSuspected(t *testing.T) {
 
 	// Verify a store that we haven't seen yet is unknown status.
 	detail := sp.GetStoreDetailLocked(0)
-	s := detail.status(now, timeUntilStoreDead, sp.NodeLivenessFn, timeAfterStoreSuspect)
+	s := detail.status(now, timeUntilStoreDead, sp.NodeLivenessFn, nil /* awaitingRestart */, timeAfterStoreSuspect)
 	require.Equal(t, s, storeStatusUnknown)
 	require.Equal(t, hlc.Timestamp{}, detail.LastUnavailable)
 
@@ -635,57 +635,241 @@ func TestStorePoolSuspected(t *testing.T) {
 	detail = sp.GetStoreDetailLocked(store.StoreID)
 	defer sp.DetailsMu.Unlock()
 
-	s = detail.status(now, timeUntilStoreDead, sp.NodeLivenessFn, timeAfterStoreSuspect)
+	s = detail.status(now, timeUntilStoreDead, sp.NodeLivenessFn, nil /* awaitingRestart */, timeAfterStoreSuspect)
 	require.Equal(t, s, storeStatusAvailable)
 	require.Equal(t, hlc.Timestamp{}, detail.LastUnavailable)
 
 	// When the store transitions to unavailable, its status changes to temporarily unknown.
 	mnl.SetNodeStatus(store.Node.NodeID, livenesspb.NodeLivenessStatus_UNAVAILABLE)
-	s = detail.status(now, timeUntilStoreDead, sp.NodeLivenessFn, timeAfterStoreSuspect)
+	s = detail.status(now, timeUntilStoreDead, sp.NodeLivenessFn, nil /* awaitingRestart */, timeAfterStoreSuspect)
 	require.Equal(t, s, storeStatusUnknown)
 	require.NotEqual(t, hlc.Timestamp{}, detail.LastUnavailable)
 
 	// When the store transitions back to live, it passes through suspect for a period.
 	mnl.SetNodeStatus(store.Node.NodeID, livenesspb.NodeLivenessStatus_LIVE)
-	s = detail.status(now, timeUntilStoreDead, sp.NodeLivenessFn, timeAfterStoreSuspect)
+	s = detail.status(now, timeUntilStoreDead, sp.NodeLivenessFn, nil /* awaitingRestart */, timeAfterStoreSuspect)
 	require.Equal(t, s, storeStatusSuspect)
 
 	// Once the window has passed, it will return to available.
 	now = now.AddDuration(timeAfterStoreSuspect).AddDuration(time.Millisecond)
-	s = detail.status(now, timeUntilStoreDead, sp.NodeLivenessFn, timeAfterStoreSuspect)
+	s = detail.status(now, timeUntilStoreDead, sp.NodeLivenessFn, nil /* awaitingRestart */, timeAfterStoreSuspect)
 	require.Equal(t, s, storeStatusAvailable)
 
 	// Return a liveness of dead.
 	mnl.SetNodeStatus(store.Node.NodeID, livenesspb.NodeLivenessStatus_DEAD)
-	s = detail.status(now, timeUntilStoreDead, sp.NodeLivenessFn, timeAfterStoreSuspect)
+	s = detail.status(now, timeUntilStoreDead, sp.NodeLivenessFn, nil /* awaitingRestart */, timeAfterStoreSuspect)
 	require.Equal(t, s, storeStatusDead)
 
 	// When the store transitions back to live, it passes through suspect for a period.
 	mnl.SetNodeStatus(store.Node.NodeID, livenesspb.NodeLivenessStatus_LIVE)
-	s = detail.status(now, timeUntilStoreDead, sp.NodeLivenessFn, timeAfterStoreSuspect)
+	s = detail.status(now, timeUntilStoreDead, sp.NodeLivenessFn, nil /* awaitingRestart */, timeAfterStoreSuspect)
 	require.Equal(t, s, storeStatusSuspect)
 
 	// Verify it also returns correctly to available after suspect time.
 	now = now.AddDuration(timeAfterStoreSuspect).AddDuration(time.Millisecond)
-	s = detail.status(now, timeUntilStoreDead, sp.NodeLivenessFn, timeAfterStoreSuspect)
+	s = detail.status(now, timeUntilStoreDead, sp.NodeLivenessFn, nil /* awaitingRestart */, timeAfterStoreSuspect)
 	require.Equal(t, s, storeStatusAvailable)
 
 	// Verify that restart after draining also makes it temporarily suspect.
 	mnl.SetNodeStatus(store.Node.NodeID, livenesspb.NodeLivenessStatus_DRAINING)
-	s = detail.status(now, timeUntilStoreDead, sp.NodeLivenessFn, timeAfterStoreSuspect)
+	s = detail.status(now, timeUntilStoreDead, sp.NodeLivenessFn, nil /* awaitingRestart */, timeAfterStoreSuspect)
 	require.Equal(t, s, storeStatusDraining)
 
 	// Verify suspect when restarting after a drain.
 	mnl.SetNodeStatus(store.Node.NodeID, livenesspb.NodeLivenessStatus_LIVE)
-	s = detail.status(now, timeUntilStoreDead, sp.NodeLivenessFn, timeAfterStoreSuspect)
+	s = detail.status(now, timeUntilStoreDead, sp.NodeLivenessFn, nil /* awaitingRestart */, timeAfterStoreSuspect)
 	require.Equal(t, s, storeStatusSuspect)
 
 	now = now.AddDuration(timeAfterStoreSuspect).AddDuration(time.Millisecond)
 	mnl.SetNodeStatus(store.Node.NodeID, livenesspb.NodeLivenessStatus_LIVE)
-	s = detail.status(now, timeUntilStoreDead, sp.NodeLivenessFn, timeAfterStoreSuspect)
+	s = detail.status(now, timeUntilStoreDead, sp.NodeLivenessFn, nil /* awaitingRestart */, timeAfterStoreSuspect)
 	require.Equal(t, s, storeStatusAvailable)
 }
 
+// TestStorePoolPersistedSuspect verifies that a store is suspect after its
+// node came back up from being unavailable according to the node's liveness
+// record, even though the store pool didn't observe the node unavailable.
+func TestStorePoolPersistedSuspect(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+	ctx := context.Background()
+	st := cluster.MakeTestingClusterSettings()
+	stopper, g, _, sp, mnl := CreateTestStorePool(ctx, st,
+		liveness.TestTimeUntilStoreDeadOff, false, /* deterministic */
+		func() int { return 10 }, /* nodeCount */
+		livenesspb.NodeLivenessStatus_DEAD)
+	defer stopper.Stop(ctx)
+
+	sg := gossiputil.NewStoreGossiper(g)
+	sg.GossipStores(uniqueStore, t)
+	store := uniqueStore[0]
+	mnl.SetNodeStatus(store.Node.NodeID, livenesspb.NodeLivenessStatus_LIVE)
+
+	var lastUnavailable hlc.Timestamp
+	sp.NodeVitalityFn = func(nodeID roachpb.NodeID) (livenesspb.NodeVitality, bool) {
+		return livenesspb.NodeVitality{Liveness: livenesspb.Liveness{
+			NodeID:          nodeID,
+			LastUnavailable: lastUnavailable,
+		}}, true
+	}
+
+	suspect, err := sp.IsSuspect(store.StoreID)
+	require.NoError(t, err)
+	require.False(t, suspect)
+
+	// The node restarted a while ago, longer than the suspect duration.
+	timeAfterStoreSuspect := TimeAfterStoreSuspect.Get(&sp.st.SV)
+	lastUnavailable = sp.clock.Now().AddDuration(-2 * timeAfterStoreSuspect)
+	suspect, err = sp.IsSuspect(store.StoreID)
+	require.NoError(t, err)
+	require.False(t, suspect)
+
+	// The node just restarted.
+	lastUnavailable = sp.clock.Now()
+	suspect, err = sp.IsSuspect(store.StoreID)
+	require.NoError(t, err)
+	require.True(t, suspect)
+}
+
+// TestStorePoolStoreDigests verifies that the capacity of a store is updated
+// with the digest recorded in the liveness record of its node, as long as the
+// digest is more recent than the gossiped store descriptor.
+func TestStorePoolStoreDigests(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+	ctx := context.Background()
+	st := cluster.MakeTestingClusterSettings()
+	stopper, g, _, sp, mnl := CreateTestStorePool(ctx, st,
+		liveness.TestTimeUntilStoreDeadOff, true, /* deterministic */
+		func() int { return 10 }, /* nodeCount */
+		livenesspb.NodeLivenessStatus_DEAD)
+	defer stopper.Stop(ctx)
+
+	sg := gossiputil.NewStoreGossiper(g)
+	sg.GossipStores(uniqueStore, t)
+	store := uniqueStore[0]
+	mnl.SetNodeStatus(store.Node.NodeID, livenesspb.NodeLivenessStatus_LIVE)
+
+	var digests []livenesspb.StoreDigest
+	sp.NodeVitalityFn = func(nodeID roachpb.NodeID) (livenesspb.NodeVitality, bool) {
+		return livenesspb.NodeVitality{Liveness: livenesspb.Liveness{
+			NodeID:       nodeID,
+			StoreDigests: digests,
+		}}, true
+	}
+	rangeCount := func() int32 {
+		desc, ok := sp.GetStoreDescriptor(store.StoreID)
+		require.True(t, ok)
+		return desc.Capacity.RangeCount
+	}
+	require.Equal(t, store.Capacity.RangeCount, rangeCount())
+
+	// A digest older than the descriptor is ignored.
+	digest := livenesspb.MakeStoreDigest(store.StoreID, store.Capacity)
+	digest.RangeCount = store.Capacity.RangeCount + 10
+	digests = []livenesspb.StoreDigest{digest}
+	require.Equal(t, store.Capacity.RangeCount, rangeCount())
+
+	// A more recent one is applied, to the store list too.
+	digests[0].Timestamp = sp.clock.Now()
+	require.Equal(t, store.Capacity.RangeCount+10, rangeCount())
+	sl, _, _ := sp.GetStoreList(StoreFilterNone)
+	desc, ok := sl.FindStoreByID(store.StoreID)
+	require.True(t, ok)
+	require.Equal(t, store.Capacity.RangeCount+10, desc.Capacity.RangeCount)
+}
+
+// TestStorePoolPlannedRestart verifies that a node that is down for a planned
+// restart isn't considered dead until it is expected back, even though it
+// stopped gossiping.
+func TestStorePoolPlannedRestart(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+	ctx := context.Background()
+	st := cluster.MakeTestingClusterSettings()
+	stopper, g, _, sp, mnl := CreateTestStorePool(ctx, st,
+		liveness.TestTimeUntilStoreDeadOff, false, /* deterministic */
+		func() int { return 10 }, /* nodeCount */
+		livenesspb.NodeLivenessStatus_DEAD)
+	defer stopper.Stop(ctx)
+
+	now := sp.clock.Now()
+	timeUntilStoreDead := liveness.TimeUntilStoreDead.Get(&sp.st.SV)
+	timeAfterStoreSuspect := TimeAfterStoreSuspect.Get(&sp.st.SV)
+
+	// The node's liveness expired long enough ago for it to be dead, but it is
+	// expected back in a minute.
+	vitality := livenesspb.NodeVitality{
+		Liveness: livenesspb.Liveness{
+			NodeID:              1,
+			Epoch:               1,
+			Expiration:          now.AddDuration(-timeUntilStoreDead).ToLegacyTimestamp(),
+			PlannedRestartUntil: now.AddDuration(time.Minute),
+		},
+	}
+	require.Equal(t, livenesspb.NodeLivenessStatus_UNAVAILABLE,
+		allocatorNodeStatus(vitality, now, timeUntilStoreDead))
+	require.Equal(t, livenesspb.NodeLivenessStatus_DEAD,
+		allocatorNodeStatus(vitality, vitality.PlannedRestartUntil, timeUntilStoreDead))
+	vitality.PlannedRestartUntil = hlc.Timestamp{}
+	require.Equal(t, livenesspb.NodeLivenessStatus_DEAD,
+		allocatorNodeStatus(vitality, now, timeUntilStoreDead))
+
+	// The lack of gossip from the node doesn't make its store dead while it is
+	// expected back from a planned restart, but does otherwise, even if its
+	// liveness doesn't consider it dead yet.
+	sg := gossiputil.NewStoreGossiper(g)
+	sg.GossipStores(uniqueStore, t)
+	store := uniqueStore[0]
+	sp.DetailsMu.Lock()
+	defer sp.DetailsMu.Unlock()
+	detail := sp.GetStoreDetailLocked(store.StoreID)
+	stale := detail.LastUpdatedTime.AddDuration(timeUntilStoreDead).AddDuration(time.Second)
+	awaitingRestart := func(nodeID roachpb.NodeID, _ hlc.Timestamp) bool {
+		return nodeID == store.Node.NodeID
+	}
+
+	mnl.SetNodeStatus(store.Node.NodeID, livenesspb.NodeLivenessStatus_UNAVAILABLE)
+	s := detail.status(stale, timeUntilStoreDead, sp.NodeLivenessFn, awaitingRestart, timeAfterStoreSuspect)
+	require.Equal(t, storeStatusUnknown, s)
+	s = detail.status(stale, timeUntilStoreDead, sp.NodeLivenessFn, nil /* awaitingRestart */, timeAfterStoreSuspect)
+	require.Equal(t, storeStatusDead, s)
+
+	mnl.SetNodeStatus(store.Node.NodeID, livenesspb.NodeLivenessStatus_DEAD)
+	s = detail.status(stale, timeUntilStoreDead, sp.NodeLivenessFn, awaitingRestart, timeAfterStoreSuspect)
+	require.Equal(t, storeStatusDead, s)
+}
+
+// TestStorePoolTimeUntilDeadOverride verifies that the time until a node is
+// considered dead can be overridden for the node, until the override lapses.
+func TestStorePoolTimeUntilDeadOverride(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+	st := cluster.MakeTestingClusterSettings()
+	timeUntilStoreDead := liveness.TimeUntilStoreDead.Get(&st.SV)
+	now := hlc.Timestamp{WallTime: time.Hour.Nanoseconds()}
+
+	// The node's liveness expired long enough ago for it to be dead.
+	vitality := livenesspb.NodeVitality{
+		Liveness: livenesspb.Liveness{
+			NodeID:     1,
+			Epoch:      1,
+			Expiration: now.AddDuration(-timeUntilStoreDead).ToLegacyTimestamp(),
+		},
+	}
+	require.Equal(t, livenesspb.NodeLivenessStatus_DEAD,
+		allocatorNodeStatus(vitality, now, timeUntilStoreDead))
+
+	vitality.TimeUntilDeadOverride = livenesspb.TimeUntilDeadOverride{
+		TimeUntilDeadNanos: (2 * timeUntilStoreDead).Nanoseconds(),
+		Expiration:         now.AddDuration(time.Minute),
+	}
+	require.Equal(t, livenesspb.NodeLivenessStatus_UNAVAILABLE,
+		allocatorNodeStatus(vitality, now, timeUntilStoreDead))
+	require.Equal(t, livenesspb.NodeLivenessStatus_DEAD,
+		allocatorNodeStatus(vitality, vitality.TimeUntilDeadOverride.Expiration, timeUntilStoreDead))
+}
+
 func TestGetLocalities(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 	defer log.Scope(t).Close(t)
@@ -984,6 +1168,39 @@ func TestNodeLivenessLivenessStatus(t *testing.T) {
 			},
 			expected: livenesspb.NodeLivenessStatus_DRAINING,
 		},
+		{
+			name: "In maintenance window",
+			liveness: livenesspb.Liveness{
+				NodeID:           1,
+				Epoch:            1,
+				Expiration:       now.AddDuration(5 * time.Minute).ToLegacyTimestamp(),
+				MaintenanceStart: now.AddDuration(-time.Minute),
+				MaintenanceEnd:   now.AddDuration(time.Minute),
+			},
+			expected: livenesspb.NodeLivenessStatus_DRAINING,
+		},
+		{
+			name: "Maintenance window ended",
+			liveness: livenesspb.Liveness{
+				NodeID:           1,
+				Epoch:            1,
+				Expiration:       now.AddDuration(5 * time.Minute).ToLegacyTimestamp(),
+				MaintenanceStart: now.AddDuration(-2 * time.Minute),
+				MaintenanceEnd:   now,
+			},
+			expected: livenesspb.NodeLivenessStatus_LIVE,
+		},
+		{
+			name: "Maintenance window not started",
+			liveness: livenesspb.Liveness{
+				NodeID:           1,
+				Epoch:            1,
+				Expiration:       now.AddDuration(5 * time.Minute).ToLegacyTimestamp(),
+				MaintenanceStart: now.AddDuration(time.Minute),
+				MaintenanceEnd:   now.AddDuration(2 * time.Minute),
+			},
+			expected: livenesspb.NodeLivenessStatus_LIVE,
+		},
 		{
 			name: "Decommissioning that is unavailable",
 			liveness: livenesspb.Liveness{
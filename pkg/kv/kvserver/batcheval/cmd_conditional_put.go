@@ -14,18 +14,42 @@ import (
 	"context"
 	"time"
 
+	"github.com/cockroachdb/cockroach/pkg/keys"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvpb"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/batcheval/result"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/kvserverbase"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/lockspanset"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/spanset"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/settings"
 	"github.com/cockroachdb/cockroach/pkg/storage"
 	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/errors"
 )
 
 func init() {
 	RegisterReadWriteCommand(kvpb.ConditionalPut, declareKeysConditionalPut, ConditionalPut)
 }
 
+// livenessUpdateAuthEnabled controls whether conditional puts to node liveness
+// records are checked against the identity of the node that sent them.
+//
+// The sender is the node the RPC peer authenticated as, which requires node
+// certificates to name their node ID (see security.GetCertificateNodeID).
+// Senders that aren't authenticated as a node, e.g. all of them in insecure
+// clusters, may only create liveness records, which is why the check is off by
+// default.
+var livenessUpdateAuthEnabled = settings.RegisterBoolSetting(
+	settings.SystemOnly,
+	"kv.liveness.authenticated_updates.enabled",
+	"if enabled, a node may only update the liveness record of another node to increment "+
+		"its epoch once expired, to change its administrative fields (e.g. membership) or, "+
+		"as its heartbeat aggregator, to renew it; the node is identified by its client "+
+		"certificate, which must name its node ID",
+	false,
+)
+
 func declareKeysConditionalPut(
 	rs ImmutableRangeState,
 	header *kvpb.Header,
@@ -56,6 +80,18 @@ func ConditionalPut(
 		ts = h.Timestamp
 	}
 
+	if keys.NodeLivenessSpan.ContainsKey(args.Key) &&
+		livenessUpdateAuthEnabled.Get(&cArgs.EvalCtx.ClusterSettings().SV) {
+		sender, _ := roachpb.ClientNodeIDFromContext(ctx)
+		var aggregatorOf func(roachpb.NodeID) roachpb.NodeID
+		if kvserverbase.LivenessHeartbeatBatchingEnabled.Get(&cArgs.EvalCtx.ClusterSettings().SV) {
+			aggregatorOf = cArgs.EvalCtx.HeartbeatAggregatorOf
+		}
+		if err := validateLivenessUpdate(sender, args, h.Timestamp, aggregatorOf); err != nil {
+			return result.Result{}, err
+		}
+	}
+
 	handleMissing := storage.CPutMissingBehavior(args.AllowIfDoesNotExist)
 	var err error
 	if args.Blind {
@@ -70,3 +106,37 @@ func ConditionalPut(
 	}
 	return result.FromAcquiredLocks(h.Txn, args.Key), nil
 }
+
+// validateLivenessUpdate checks that the node that sent the conditional put is
+// allowed to replace the liveness record it expects with the one it writes.
+// See livenesspb.ValidateUpdateBy. If aggregatorOf is set, the heartbeat
+// aggregator of a node, as it returns, may also renew the record of the node
+// (see livenesspb.IsRenewal).
+func validateLivenessUpdate(
+	sender roachpb.NodeID,
+	args *kvpb.ConditionalPutRequest,
+	now hlc.Timestamp,
+	aggregatorOf func(roachpb.NodeID) roachpb.NodeID,
+) error {
+	var newLiveness livenesspb.Liveness
+	if err := args.Value.GetProto(&newLiveness); err != nil {
+		return errors.Wrap(err, "invalid liveness record")
+	}
+	if !args.Key.Equal(keys.NodeLivenessKey(newLiveness.NodeID)) {
+		return errors.Errorf("liveness record of n%d written to key %s", newLiveness.NodeID, args.Key)
+	}
+	var oldLiveness *livenesspb.Liveness
+	if args.ExpBytes != nil {
+		var expValue roachpb.Value
+		expValue.SetTagAndData(args.ExpBytes)
+		oldLiveness = &livenesspb.Liveness{}
+		if err := expValue.GetProto(oldLiveness); err != nil {
+			return errors.Wrap(err, "invalid expected liveness record")
+		}
+	}
+	if aggregatorOf != nil && sender != 0 && oldLiveness != nil &&
+		aggregatorOf(newLiveness.NodeID) == sender && livenesspb.IsRenewal(*oldLiveness, newLiveness) {
+		return nil
+	}
+	return livenesspb.ValidateUpdateBy(sender, oldLiveness, newLiveness, now)
+}
@@ -60,6 +60,10 @@ type EvalContext interface {
 	StoreID() roachpb.StoreID
 	GetRangeID() roachpb.RangeID
 	GetNodeLocality() roachpb.Locality
+	// HeartbeatAggregatorOf returns the node that writes the liveness
+	// renewals of the given node on its behalf, or the node itself if its
+	// heartbeats aren't batched.
+	HeartbeatAggregatorOf(nodeID roachpb.NodeID) roachpb.NodeID
 
 	IsFirstRange() bool
 	GetFirstIndex() kvpb.RaftIndex
@@ -221,6 +225,9 @@ func (m *mockEvalCtxImpl) NodeID() roachpb.NodeID {
 func (m *mockEvalCtxImpl) GetNodeLocality() roachpb.Locality {
 	panic("unimplemented")
 }
+func (m *mockEvalCtxImpl) HeartbeatAggregatorOf(nodeID roachpb.NodeID) roachpb.NodeID {
+	return nodeID
+}
 func (m *mockEvalCtxImpl) StoreID() roachpb.StoreID {
 	return m.MockEvalCtx.StoreID
 }
@@ -36,6 +36,31 @@ var MergeQueueEnabled = settings.RegisterBoolSetting(
 	true,
 )
 
+// LivenessHeartbeatBatchingEnabled is a setting that controls whether nodes
+// send their liveness heartbeats through a heartbeat aggregator in their
+// region, which renews the liveness records of many nodes in a single write.
+var LivenessHeartbeatBatchingEnabled = settings.RegisterBoolSetting(
+	settings.SystemOnly,
+	"kv.liveness.heartbeat_batching.enabled",
+	"if enabled, nodes send their liveness heartbeats to an aggregator in their region, "+
+		"which renews the records of many nodes in a single write to the node liveness range; "+
+		"aggregators are allowed to renew the liveness records of other nodes",
+	false,
+)
+
+// LivenessStoreDigestsEnabled is a setting that controls whether nodes record
+// a digest of the capacity of their stores in their liveness records on every
+// heartbeat, in which case stores don't gossip their descriptors on capacity
+// changes, only periodically.
+var LivenessStoreDigestsEnabled = settings.RegisterBoolSetting(
+	settings.SystemOnly,
+	"kv.liveness.store_digests.enabled",
+	"if enabled, nodes record a digest of the capacity of their stores in their liveness "+
+		"records on every heartbeat, which gives the allocator fresher capacity signals, and "+
+		"stores gossip their descriptors only periodically rather than on capacity changes",
+	false,
+)
+
 // ReplicateQueueEnabled is a setting that controls whether the replicate queue
 // is enabled.
 var ReplicateQueueEnabled = settings.RegisterBoolSetting(
@@ -0,0 +1,100 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package liveness
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/settings"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+)
+
+var ttlAutotuneEnabled = settings.RegisterBoolSetting(
+	settings.SystemOnly,
+	"kv.liveness.ttl_autotune.enabled",
+	"if enabled, nodes extend the expiration of their liveness records, the time they leave "+
+		"themselves to renew them and the interval between their heartbeats, up to "+
+		"kv.liveness.ttl_autotune.max_ttl, when the clock offsets across the cluster or the "+
+		"latency of their heartbeats are high, trading failover speed for stability",
+	false,
+)
+
+var ttlAutotuneMaxTTL = settings.RegisterDurationSetting(
+	settings.SystemOnly,
+	"kv.liveness.ttl_autotune.max_ttl",
+	"the duration up to which kv.liveness.ttl_autotune.enabled may extend liveness records",
+	30*time.Second,
+	settings.PositiveDuration,
+)
+
+const (
+	// ttlAutotuneClockOffsetMultiple is the minimum ratio between the liveness
+	// TTL and the largest clock offset across the cluster. Liveness expirations
+	// are compared against the clocks of other nodes, so an offset that is a
+	// significant fraction of the TTL makes liveness flaky.
+	ttlAutotuneClockOffsetMultiple = 20
+	// ttlAutotuneLatencyMultiple is the minimum ratio between the window a node
+	// has to renew its liveness record and the mean latency of its heartbeats.
+	ttlAutotuneLatencyMultiple = 4
+	// ttlAutotuneDecreaseFactor is the largest factor by which the TTL shrinks
+	// from one load sample to the next, so that a brief lull in clock offsets
+	// or heartbeat latencies doesn't make liveness flaky again right away. The
+	// TTL grows as fast as needed.
+	ttlAutotuneDecreaseFactor = 0.9
+)
+
+// nextTTLAutotuneMultiplier returns the factor by which the TTL controller
+// should extend the liveness TTL, given the previous factor, the base TTL and
+// renewal window, the upper bound on the TTL, the largest clock offset across
+// the cluster and the mean latency of the local node's heartbeats. The result
+// is at least 1.
+func nextTTLAutotuneMultiplier(
+	prev float64, ttl, renewal, maxTTL, maxClockOffset, heartbeatLatency time.Duration,
+) float64 {
+	next := math.Max(1, math.Max(
+		ttlAutotuneClockOffsetMultiple*maxClockOffset.Seconds()/ttl.Seconds(),
+		ttlAutotuneLatencyMultiple*heartbeatLatency.Seconds()/renewal.Seconds(),
+	))
+	next = math.Max(next, prev*ttlAutotuneDecreaseFactor)
+	return math.Max(1, math.Min(next, maxTTL.Seconds()/ttl.Seconds()))
+}
+
+// ttlAutotuneMultiplier returns the factor by which the TTL controller
+// currently extends the local node's liveness record, its renewal window and
+// the interval between its heartbeats.
+func (nl *NodeLiveness) ttlAutotuneMultiplier() float64 {
+	return math.Max(1, syncutil.LoadFloat64(&nl.ttlAutotune))
+}
+
+// autotuneTTL updates the factor by which the local node extends its liveness
+// record, if kv.liveness.ttl_autotune.enabled is set, from the given load
+// sample and the clock offsets recorded in the liveness records of live nodes.
+func (nl *NodeLiveness) autotuneTTL(ctx context.Context, load RangeLoad) {
+	prev := nl.ttlAutotuneMultiplier()
+	next := 1.0
+	if ttlAutotuneEnabled.Get(&nl.st.SV) {
+		next = nextTTLAutotuneMultiplier(prev, nl.livenessThreshold, nl.renewalDuration,
+			ttlAutotuneMaxTTL.Get(&nl.st.SV), time.Duration(nl.maxClockOffsetNanos()),
+			load.MeanHeartbeatLatency)
+	}
+	syncutil.StoreFloat64(&nl.ttlAutotune, next)
+	switch {
+	case next > 1 && prev == 1:
+		log.Infof(ctx, "extending liveness records by a factor of %.1f (max clock offset %s, "+
+			"mean heartbeat latency %s)", next, time.Duration(nl.maxClockOffsetNanos()),
+			load.MeanHeartbeatLatency)
+	case next == 1 && prev > 1:
+		log.Infof(ctx, "no longer extending liveness records")
+	}
+}
@@ -0,0 +1,359 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package liveness
+
+import (
+	"context"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/kvserverbase"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/rpc"
+	"github.com/cockroachdb/cockroach/pkg/settings"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var heartbeatBatchWindow = settings.RegisterDurationSetting(
+	settings.SystemOnly,
+	"kv.liveness.heartbeat_batching.window",
+	"the time a heartbeat aggregator waits for more heartbeats before renewing a batch of "+
+		"liveness records",
+	10*time.Millisecond,
+	settings.NonNegativeDuration,
+)
+
+// errRenewalConditionFailed is returned by the condition failure handler passed
+// to storage.update by the heartbeat batcher, which only needs to know the
+// actual record.
+var errRenewalConditionFailed = errors.New("liveness record changed")
+
+// renewalResult is the outcome of a renewal through a heartbeat aggregator.
+type renewalResult struct {
+	// written is the renewed record, if the renewal succeeded.
+	written Record
+	// actual is the record found in the database, if it didn't match the
+	// expected one.
+	actual *Record
+	err    error
+}
+
+type pendingRenewal struct {
+	update livenessUpdate
+	done   chan renewalResult
+}
+
+// heartbeatBatcher renews the liveness records of the nodes that use the local
+// node as their heartbeat aggregator. Renewals received within
+// kv.liveness.heartbeat_batching.window of each other are written in a single
+// transaction, which the liveness range applies as a single Raft command.
+type heartbeatBatcher struct {
+	nl *NodeLiveness
+
+	mu struct {
+		syncutil.Mutex
+		pending []*pendingRenewal
+	}
+
+	aggregators struct {
+		syncutil.Mutex
+		// view is nil when it needs to be recomputed. See
+		// NodeLiveness.HeartbeatAggregatorOf.
+		view *aggregatorView
+		// gen is incremented whenever the view is invalidated, so that a view
+		// computed concurrently isn't cached.
+		gen int64
+	}
+}
+
+// aggregatorView is what the heartbeat aggregators of the nodes are computed
+// from. It is recomputed when the liveness status or the node descriptor of a
+// node changes (see invalidateAggregators), and once the liveness of one of the
+// aggregators expires.
+type aggregatorView struct {
+	// regions maps the nodes with a cached liveness record to their region.
+	regions map[roachpb.NodeID]string
+	// lowestLive maps each region to the live node with the lowest ID in it.
+	lowestLive map[string]roachpb.NodeID
+	// validUntil is the earliest expiration of the liveness of the nodes in
+	// lowestLive.
+	validUntil hlc.Timestamp
+}
+
+// renew queues the given renewal, and waits for the batch it ends up in to be
+// written. Only renewals of the local node's record, and of the records of the
+// nodes the local node is the heartbeat aggregator of, are batched.
+func (b *heartbeatBatcher) renew(ctx context.Context, update livenessUpdate) renewalResult {
+	nodeID := update.newLiveness.NodeID
+	if self := b.nl.cache.selfID(); nodeID != self && b.nl.HeartbeatAggregatorOf(nodeID) != self {
+		return renewalResult{err: errors.Errorf("n%d is not the heartbeat aggregator of n%d", self, nodeID)}
+	}
+	p := &pendingRenewal{update: update, done: make(chan renewalResult, 1)}
+	b.mu.Lock()
+	b.mu.pending = append(b.mu.pending, p)
+	first := len(b.mu.pending) == 1
+	b.mu.Unlock()
+
+	if first {
+		// The batch is written with a context of its own, since it doesn't
+		// belong to any of the callers.
+		if err := b.nl.stopper.RunAsyncTask(ctx, "liveness-heartbeat-batch", func(context.Context) {
+			ctx, cancel := b.nl.stopper.WithCancelOnQuiesce(b.nl.ambientCtx.AnnotateCtx(context.Background()))
+			defer cancel()
+			b.flush(ctx)
+		}); err != nil {
+			// Fail the whole batch, which would otherwise never be flushed:
+			// the renewals queued after this one rely on it to be.
+			b.mu.Lock()
+			batch := b.mu.pending
+			b.mu.pending = nil
+			b.mu.Unlock()
+			for _, p := range batch {
+				p.done <- renewalResult{err: err}
+			}
+		}
+	}
+
+	select {
+	case res := <-p.done:
+		return res
+	case <-ctx.Done():
+		return renewalResult{err: ctx.Err()}
+	}
+}
+
+// flush waits for the batch window to pass, and writes all the renewals queued
+// by then.
+func (b *heartbeatBatcher) flush(ctx context.Context) {
+	select {
+	case <-time.After(heartbeatBatchWindow.Get(&b.nl.st.SV)):
+	case <-ctx.Done():
+	}
+	b.mu.Lock()
+	batch := b.mu.pending
+	b.mu.pending = nil
+	b.mu.Unlock()
+
+	// Don't write anything once the server is quiescing.
+	if err := ctx.Err(); err != nil {
+		for _, p := range batch {
+			p.done <- renewalResult{err: err}
+		}
+		return
+	}
+
+	if len(batch) > 1 {
+		updates := make([]livenessUpdate, len(batch))
+		for i, p := range batch {
+			updates[i] = p.update
+		}
+		written, err := b.nl.storage.updateBatch(ctx, updates)
+		if err == nil {
+			for i, p := range batch {
+				p.done <- renewalResult{written: written[i]}
+			}
+			return
+		}
+		log.VEventf(ctx, 2, "unable to renew %d liveness records in a batch: %v", len(batch), err)
+	}
+
+	// Write the renewals one by one, which tells apart those whose records
+	// changed from the others.
+	for _, p := range batch {
+		var actual *Record
+		written, err := b.nl.storage.update(ctx, p.update, func(a Record) error {
+			actual = &a
+			return errRenewalConditionFailed
+		})
+		if actual != nil {
+			err = nil
+		}
+		p.done <- renewalResult{written: written, actual: actual, err: err}
+	}
+}
+
+// Renew implements the livenesspb.HeartbeatAggregatorServer interface.
+func (nl *NodeLiveness) Renew(
+	ctx context.Context, req *livenesspb.RenewRequest,
+) (*livenesspb.RenewResponse, error) {
+	// A node may only renew its own record through its aggregator, which
+	// requires the node certificate of the peer to name its node ID. Nodes that
+	// can't be authenticated as such write their records directly.
+	if sender, _ := roachpb.ClientNodeIDFromContext(ctx); sender != req.Liveness.NodeID {
+		return nil, status.Errorf(codes.PermissionDenied,
+			"n%d cannot renew the liveness record of n%d", sender, req.Liveness.NodeID)
+	}
+	old, err := nl.storage.decodeRecord(ctx, req.ExpectedRaw)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	// Only renewals are batched: the batch would fail as a whole on any other
+	// update to another node's record.
+	if old.NodeID != req.Liveness.NodeID || !livenesspb.IsRenewal(old.Liveness, req.Liveness) {
+		return nil, status.Errorf(codes.InvalidArgument,
+			"liveness update of n%d is not a renewal", req.Liveness.NodeID)
+	}
+
+	res := nl.batcher.renew(ctx, livenessUpdate{
+		newLiveness: req.Liveness,
+		oldLiveness: old.Liveness,
+		oldRaw:      req.ExpectedRaw,
+	})
+	if res.err != nil {
+		return nil, res.err
+	}
+	if res.actual != nil {
+		return &livenesspb.RenewResponse{ConditionFailed: true, Raw: res.actual.raw}, nil
+	}
+	return &livenesspb.RenewResponse{Raw: res.written.raw}, nil
+}
+
+// shouldBatch returns whether the given update should be sent through a
+// heartbeat aggregator: only renewals of the local node's own record are.
+func (nl *NodeLiveness) shouldBatch(update livenessUpdate) bool {
+	return kvserverbase.LivenessHeartbeatBatchingEnabled.Get(&nl.st.SV) &&
+		update.newLiveness.NodeID == nl.cache.selfID() &&
+		livenesspb.IsRenewal(update.oldLiveness, update.newLiveness)
+}
+
+// renewBatched renews the local node's liveness record through its heartbeat
+// aggregator, falling back to writing it directly if the aggregator is
+// unavailable.
+func (nl *NodeLiveness) renewBatched(
+	ctx context.Context, update livenessUpdate, handleCondFailed func(actual Record) error,
+) (Record, error) {
+	res := nl.renewThroughAggregator(ctx, update)
+	if res.err != nil {
+		log.VEventf(ctx, 2, "unable to renew liveness through aggregator, writing it directly: %v", res.err)
+		return nl.storage.update(ctx, update, handleCondFailed)
+	}
+	if res.actual != nil {
+		return Record{}, handleCondFailed(*res.actual)
+	}
+	return res.written, nil
+}
+
+func (nl *NodeLiveness) renewThroughAggregator(
+	ctx context.Context, update livenessUpdate,
+) renewalResult {
+	aggregator := nl.heartbeatAggregator()
+	if aggregator == nl.cache.selfID() || nl.nodeDialer == nil {
+		return nl.batcher.renew(ctx, update)
+	}
+	conn, err := nl.nodeDialer.Dial(ctx, aggregator, rpc.SystemClass)
+	if err != nil {
+		return renewalResult{err: err}
+	}
+	resp, err := livenesspb.NewHeartbeatAggregatorClient(conn).Renew(ctx, &livenesspb.RenewRequest{
+		Liveness:    update.newLiveness,
+		ExpectedRaw: update.oldRaw,
+	})
+	if err != nil {
+		return renewalResult{err: err}
+	}
+	rec, err := nl.storage.decodeRecord(ctx, resp.Raw)
+	if err != nil {
+		return renewalResult{err: err}
+	}
+	if resp.ConditionFailed {
+		return renewalResult{actual: &rec}
+	}
+	return renewalResult{written: rec}
+}
+
+// heartbeatAggregator returns the node the local node sends its heartbeats to.
+// See HeartbeatAggregatorOf.
+func (nl *NodeLiveness) heartbeatAggregator() roachpb.NodeID {
+	return nl.HeartbeatAggregatorOf(nl.cache.selfID())
+}
+
+// HeartbeatAggregatorOf returns the node the given node sends its heartbeats
+// to: the live node with the lowest ID in the node's region (or among the nodes
+// without a region, if the node doesn't have one). It is the node itself if no
+// other node qualifies.
+func (nl *NodeLiveness) HeartbeatAggregatorOf(nodeID roachpb.NodeID) roachpb.NodeID {
+	view := nl.aggregatorView(nl.clock.Now())
+	region, ok := view.regions[nodeID]
+	if !ok {
+		region = nl.nodeRegion(nodeID)
+	}
+	if aggregator, ok := view.lowestLive[region]; ok && aggregator < nodeID {
+		return aggregator
+	}
+	return nodeID
+}
+
+// aggregatorView returns the cached aggregatorView, recomputing it if it is
+// invalid as of now.
+func (nl *NodeLiveness) aggregatorView(now hlc.Timestamp) *aggregatorView {
+	a := &nl.batcher.aggregators
+	a.Lock()
+	view, gen := a.view, a.gen
+	a.Unlock()
+	if view != nil && now.Less(view.validUntil) {
+		return view
+	}
+
+	// The view is computed without holding the lock, which would otherwise be
+	// held while looking up node descriptors in gossip.
+	view = &aggregatorView{
+		regions:    make(map[roachpb.NodeID]string),
+		lowestLive: make(map[string]roachpb.NodeID),
+		validUntil: hlc.MaxTimestamp,
+	}
+	for _, l := range nl.cache.getAllLivenesses() {
+		region := nl.nodeRegion(l.NodeID)
+		view.regions[l.NodeID] = region
+		if !l.IsLive(now) {
+			continue
+		}
+		if lowest, ok := view.lowestLive[region]; !ok || l.NodeID < lowest {
+			view.lowestLive[region] = l.NodeID
+		}
+	}
+	for _, nodeID := range view.lowestLive {
+		if l, ok := nl.cache.GetLiveness(nodeID); ok {
+			view.validUntil.Backward(l.Expiration.ToTimestamp())
+		}
+	}
+	a.Lock()
+	if a.gen == gen {
+		a.view = view
+	}
+	a.Unlock()
+	return view
+}
+
+// invalidateAggregators makes the next call to HeartbeatAggregatorOf recompute
+// the aggregators. It is called when a node becomes live or stops being live,
+// and when a node descriptor is gossiped, which may change the node's region.
+func (b *heartbeatBatcher) invalidateAggregators() {
+	b.aggregators.Lock()
+	b.aggregators.view = nil
+	b.aggregators.gen++
+	b.aggregators.Unlock()
+}
+
+// nodeRegion returns the region of the given node, as found in its gossiped
+// node descriptor, or "" if unknown.
+func (nl *NodeLiveness) nodeRegion(nodeID roachpb.NodeID) string {
+	desc, err := nl.cache.gossip.GetNodeDescriptor(nodeID)
+	if err != nil {
+		return ""
+	}
+	region, _ := desc.Locality.Find("region")
+	return region
+}
@@ -13,7 +13,9 @@ package liveness
 import (
 	"bytes"
 	"context"
+	"time"
 
+	"github.com/cockroachdb/cockroach/pkg/clusterversion"
 	"github.com/cockroachdb/cockroach/pkg/gossip"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
@@ -34,7 +36,10 @@ import (
 type cache struct {
 	gossip                *gossip.Gossip
 	clock                 *hlc.Clock
+	version               clusterversion.Handle
+	defaultTTL            time.Duration
 	notifyLivenessChanged func(old, new livenesspb.Liveness)
+	notifyGossiped        func(old, new livenesspb.Liveness)
 	mu                    struct {
 		syncutil.RWMutex
 		// lastNodeUpdate stores timestamps of StoreDescriptor updates in Gossip.
@@ -44,19 +49,36 @@ type cache struct {
 		lastNodeUpdate map[roachpb.NodeID]hlc.Timestamp
 		// nodes stores liveness records read from Gossip
 		nodes map[roachpb.NodeID]Record
+		// quarantined stores the latest invalid liveness record received for
+		// each node, until a valid one supersedes it. See Liveness.Validate.
+		quarantined map[roachpb.NodeID]Record
+		// dead stores the nodes found dead, along with the dead threshold
+		// they were found dead with, until their liveness record is updated.
+		// See isDead.
+		dead map[roachpb.NodeID]time.Duration
 	}
 }
 
 func newCache(
-	g *gossip.Gossip, clock *hlc.Clock, cbFn func(livenesspb.Liveness, livenesspb.Liveness),
+	g *gossip.Gossip,
+	clock *hlc.Clock,
+	version clusterversion.Handle,
+	defaultTTL time.Duration,
+	cbFn func(livenesspb.Liveness, livenesspb.Liveness),
+	gossipFn func(livenesspb.Liveness, livenesspb.Liveness),
 ) *cache {
 	c := cache{}
 	c.gossip = g
 	c.clock = clock
+	c.version = version
+	c.defaultTTL = defaultTTL
 	c.mu.nodes = make(map[roachpb.NodeID]Record)
+	c.mu.quarantined = make(map[roachpb.NodeID]Record)
+	c.mu.dead = make(map[roachpb.NodeID]time.Duration)
 	c.mu.lastNodeUpdate = make(map[roachpb.NodeID]hlc.Timestamp)
 
 	c.notifyLivenessChanged = cbFn
+	c.notifyGossiped = gossipFn
 
 	// NB: we should consider moving this registration to .Start() once we
 	// have ensured that nobody uses the server's KV client (kv.DB) before
@@ -89,6 +111,12 @@ func (c *cache) livenessGossipUpdate(_ string, content roachpb.Value) {
 		log.Errorf(ctx, "%v", err)
 		return
 	}
+	liveness.Normalize(ctx, c.version, c.defaultTTL)
+
+	c.mu.RLock()
+	old := c.mu.nodes[liveness.NodeID]
+	c.mu.RUnlock()
+	c.notifyGossiped(old.Liveness, liveness)
 
 	c.maybeUpdate(ctx, Record{Liveness: liveness, raw: content.TagAndDataBytes()})
 }
@@ -114,12 +142,21 @@ func (c *cache) storeGossipUpdate(_ string, content roachpb.Value) {
 // maybeUpdate replaces the liveness (if it appears newer) and invokes the
 // registered callbacks if the node became live in the process.
 func (c *cache) maybeUpdate(ctx context.Context, newLivenessRec Record) {
-	if newLivenessRec.Liveness == (livenesspb.Liveness{}) {
+	if newLivenessRec.Liveness.Equal(livenesspb.Liveness{}) {
 		log.Fatal(ctx, "invalid new liveness record; found to be empty")
 	}
 
+	// Keep records that fail the sanity checks out of the cache, lest they
+	// poison it: an absurd epoch or expiration would never be superseded.
+	if err := newLivenessRec.Validate(c.clock.Now()); err != nil {
+		c.quarantine(ctx, newLivenessRec, err)
+		return
+	}
+
 	shouldReplace := true
 	c.mu.Lock()
+	delete(c.mu.quarantined, newLivenessRec.NodeID)
+	delete(c.mu.dead, newLivenessRec.NodeID)
 
 	// NB: shouldReplace will always be true right after a node restarts since the
 	// `nodes` map will be empty. This means that the callbacks called below will
@@ -139,6 +176,56 @@ func (c *cache) maybeUpdate(ctx context.Context, newLivenessRec Record) {
 	}
 }
 
+// quarantine records the invalid liveness record, logging it unless it was
+// already quarantined.
+func (c *cache) quarantine(ctx context.Context, rec Record, err error) {
+	c.mu.Lock()
+	old, ok := c.mu.quarantined[rec.NodeID]
+	c.mu.quarantined[rec.NodeID] = rec
+	c.mu.Unlock()
+	if !ok || !old.Liveness.Equal(rec.Liveness) {
+		log.Warningf(ctx, "quarantining invalid liveness record: %v", err)
+	}
+}
+
+// getQuarantined returns the invalid liveness records that were kept out of
+// the cache, see quarantine.
+func (c *cache) getQuarantined() []livenesspb.Liveness {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	livenesses := make([]livenesspb.Liveness, 0, len(c.mu.quarantined))
+	for _, l := range c.mu.quarantined {
+		livenesses = append(livenesses, l.Liveness)
+	}
+	return livenesses
+}
+
+// isDead returns whether the node is dead as of now, given the dead threshold,
+// according to its cached liveness record, and whether the answer came from a
+// previous verdict. A dead node stays dead as time passes, until its liveness
+// record is updated, so the verdict is kept until then, for the benefit of hot
+// paths that keep looking up long-dead nodes. A node without a cached record
+// isn't considered dead.
+func (c *cache) isDead(nodeID roachpb.NodeID, deadThreshold time.Duration) (dead, cached bool) {
+	c.mu.RLock()
+	threshold, cached := c.mu.dead[nodeID]
+	rec, ok := c.mu.nodes[nodeID]
+	c.mu.RUnlock()
+	if cached && threshold == deadThreshold {
+		return true, true
+	}
+	if !ok || !rec.IsDead(c.clock.Now(), deadThreshold) {
+		return false, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// Only record the verdict if the record wasn't updated in the meantime.
+	if cur, ok := c.mu.nodes[nodeID]; ok && cur.Liveness.Equal(rec.Liveness) {
+		c.mu.dead[nodeID] = deadThreshold
+	}
+	return true, false
+}
+
 // livenessChanged checks to see if the new liveness is in fact newer
 // than the old liveness.
 func livenessChanged(old, new Record) bool {
@@ -150,7 +237,8 @@ func livenessChanged(old, new Record) bool {
 	}
 
 	// If Epoch and Expiration are unchanged, assume that the update is newer
-	// when its draining or decommissioning field changed.
+	// when its draining, decommissioning, maintenance, scheduling, planned
+	// restart, time until dead override or external failure fields changed.
 	//
 	// Similarly, assume that the update is newer if the raw encoding is changed
 	// when all the fields are the same. This ensures that the CPut performed
@@ -164,6 +252,15 @@ func livenessChanged(old, new Record) bool {
 	// See #18219.
 	return oldL.Draining != newL.Draining ||
 		oldL.Membership != newL.Membership ||
+		oldL.MaintenanceStart != newL.MaintenanceStart ||
+		oldL.MaintenanceEnd != newL.MaintenanceEnd ||
+		oldL.DecommissionAt != newL.DecommissionAt ||
+		oldL.PlannedRestartUntil != newL.PlannedRestartUntil ||
+		oldL.StickyDrain != newL.StickyDrain ||
+		oldL.Departing != newL.Departing ||
+		!livenesspb.TagsEqual(oldL.Tags, newL.Tags) ||
+		oldL.TimeUntilDeadOverride != newL.TimeUntilDeadOverride ||
+		!oldL.ExternalFailure.Equal(newL.ExternalFailure) ||
 		(oldL.Equal(newL) && !bytes.Equal(old.raw, new.raw))
 }
 
@@ -197,10 +294,29 @@ func (c *cache) getLivenessLocked(nodeID roachpb.NodeID) (_ Record, ok bool) {
 	return Record{}, false
 }
 
+// getAllLivenesses returns all the liveness records in the cache, including
+// those of decommissioned nodes.
+func (c *cache) getAllLivenesses() []livenesspb.Liveness {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	livenesses := make([]livenesspb.Liveness, 0, len(c.mu.nodes))
+	for _, l := range c.mu.nodes {
+		livenesses = append(livenesses, l.Liveness)
+	}
+	return livenesses
+}
+
 // GetIsLiveMap returns a map of nodeID to boolean liveness status of
 // each node. This excludes nodes that were removed completely (dead +
-// decommissioned)
-func (c *cache) GetIsLiveMap() livenesspb.IsLiveMap {
+// decommissioned). The derived status of the entries is computed with the
+// given dead threshold, unless overridden by the record.
+func (c *cache) GetIsLiveMap(deadThreshold time.Duration) livenesspb.IsLiveMap {
+	return c.GetLivenessView(deadThreshold).IsLiveMap
+}
+
+// GetLivenessView is like GetIsLiveMap, but also returns the time and dead
+// threshold the map was built with.
+func (c *cache) GetLivenessView(deadThreshold time.Duration) livenesspb.LivenessView {
 	lMap := livenesspb.IsLiveMap{}
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -212,9 +328,10 @@ func (c *cache) GetIsLiveMap() livenesspb.IsLiveMap {
 			continue
 		}
 		lMap[nID] = livenesspb.IsLiveMapEntry{
-			Liveness: l.Liveness,
-			IsLive:   isLive,
+			Liveness:       l.Liveness,
+			IsLive:         isLive,
+			LivenessStatus: l.Status(now, l.TimeUntilDead(now, deadThreshold)),
 		}
 	}
-	return lMap
+	return livenesspb.LivenessView{IsLiveMap: lMap, Now: now, DeadThreshold: deadThreshold}
 }
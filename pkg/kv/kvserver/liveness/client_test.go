@@ -21,6 +21,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/base"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/allocator/plan"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/kvserverbase"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
@@ -29,6 +30,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/testutils"
 	"github.com/cockroachdb/cockroach/pkg/testutils/skip"
 	"github.com/cockroachdb/cockroach/pkg/testutils/testcluster"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
 	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
@@ -80,6 +82,80 @@ func TestNodeLivenessAppearsAtStart(t *testing.T) {
 	}
 }
 
+// TestNodeLivenessProvisionalRecord verifies that the liveness record created
+// for a joining node is visible before the node first heartbeats, without the
+// node being live, and that the node is dead if it fails to join in time.
+func TestNodeLivenessProvisionalRecord(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := testcluster.StartTestCluster(t, 2, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	nodeID := tc.Server(1).NodeID() + 1
+	nl := tc.Server(0).NodeLiveness().(*liveness.NodeLiveness)
+	require.NoError(t, nl.CreateLivenessRecord(ctx, nodeID))
+
+	// The coordinator sees the record right away.
+	l, ok := nl.GetLiveness(nodeID)
+	require.True(t, ok)
+	require.Equal(t, int64(0), l.Epoch)
+	now := tc.Server(0).Clock().Now()
+	require.False(t, l.IsLive(now))
+	require.Equal(t, livenesspb.NodeLivenessStatus_UNKNOWN, l.Status(now, time.Hour))
+	require.Equal(t, livenesspb.NodeLivenessStatus_DEAD, l.Status(l.Expiration.ToTimestamp(), time.Hour))
+
+	// The other nodes learn about it through gossip.
+	otherNL := tc.Server(1).NodeLiveness().(*liveness.NodeLiveness)
+	testutils.SucceedsSoon(t, func() error {
+		if _, ok := otherNL.GetLiveness(nodeID); !ok {
+			return errors.Errorf("n%d doesn't know about the record of n%d", tc.Server(1).NodeID(), nodeID)
+		}
+		return nil
+	})
+
+	// An existing record isn't overwritten.
+	require.Error(t, nl.CreateLivenessRecord(ctx, nodeID))
+}
+
+// TestNodeLivenessAllocateNodeID verifies that allocating a node ID also
+// creates its liveness record.
+func TestNodeLivenessAllocateNodeID(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := testcluster.StartTestCluster(t, 1, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	nl := tc.Server(0).NodeLiveness().(*liveness.NodeLiveness)
+	nodeID, err := nl.AllocateNodeIDWithLivenessRecord(ctx)
+	require.NoError(t, err)
+	require.Greater(t, nodeID, tc.Server(0).NodeID())
+
+	l, ok := nl.GetLiveness(nodeID)
+	require.True(t, ok)
+	require.Equal(t, int64(0), l.Epoch)
+
+	livenesses, err := nl.GetLivenessesFromKV(ctx)
+	require.NoError(t, err)
+	var found bool
+	for _, l := range livenesses {
+		found = found || l.NodeID == nodeID
+	}
+	require.True(t, found, "no liveness record for n%d", nodeID)
+
+	// The next allocation doesn't reuse the node ID.
+	nextNodeID, err := nl.AllocateNodeIDWithLivenessRecord(ctx)
+	require.NoError(t, err)
+	require.Greater(t, nextNodeID, nodeID)
+}
+
 // TestGetLivenessesFromKV verifies that fetching liveness records from KV
 // directly retrieves all the records we expect.
 func TestGetLivenessesFromKV(t *testing.T) {
@@ -134,6 +210,404 @@ func TestGetLivenessesFromKV(t *testing.T) {
 
 }
 
+// TestGetLivenessesFromKVAsOf verifies that liveness records can be read as
+// of a past timestamp, returning the records as they were at that time rather
+// than the latest ones.
+func TestGetLivenessesFromKVAsOf(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := testcluster.StartTestCluster(t, 3, base.TestClusterArgs{})
+	defer tc.Stopper().Stop(ctx)
+
+	nl := tc.Server(0).NodeLiveness().(*liveness.NodeLiveness)
+	asOf := tc.Server(0).Clock().Now()
+	before, err := nl.GetLivenessesFromKVAsOf(ctx, asOf)
+	require.NoError(t, err)
+	require.Len(t, before, tc.NumServers())
+
+	// Wait for all nodes to heartbeat their liveness record past the one we
+	// read above.
+	var latest []livenesspb.Liveness
+	testutils.SucceedsSoon(t, func() error {
+		latest, err = nl.GetLivenessesFromKV(ctx)
+		if err != nil {
+			return err
+		}
+		for i := range latest {
+			if latest[i].Expiration == before[i].Expiration {
+				return errors.Errorf("n%d has not heartbeated yet", latest[i].NodeID)
+			}
+		}
+		return nil
+	})
+
+	// Reading at the same timestamp again must not observe the new heartbeats.
+	historical, err := nl.GetLivenessesFromKVAsOf(ctx, asOf)
+	require.NoError(t, err)
+	require.Equal(t, before, historical)
+	require.NotEqual(t, latest, historical)
+}
+
+// TestNodeLivenessMaintenanceWindow verifies that a node in a maintenance
+// window is treated as draining, and that it reverts to its regular state
+// once the window ends.
+func TestNodeLivenessMaintenanceWindow(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := testcluster.StartTestCluster(t, 2, base.TestClusterArgs{})
+	defer tc.Stopper().Stop(ctx)
+
+	nl := tc.Server(0).NodeLiveness().(*liveness.NodeLiveness)
+	clock := tc.Server(0).Clock()
+	targetID := tc.Server(1).NodeID()
+
+	waitForAvailableNotDraining := func(exp bool) {
+		testutils.SucceedsSoon(t, func() error {
+			if nl.IsAvailableNotDraining(targetID) != exp {
+				return errors.Errorf("expected IsAvailableNotDraining(n%d) = %t", targetID, exp)
+			}
+			return nil
+		})
+	}
+
+	// Declare a long window and clear it explicitly.
+	now := clock.Now()
+	require.NoError(t, nl.SetMaintenanceWindow(ctx, targetID, now, now.AddDuration(time.Hour)))
+	waitForAvailableNotDraining(false)
+	require.NoError(t, nl.SetMaintenanceWindow(ctx, targetID, hlc.Timestamp{}, hlc.Timestamp{}))
+	waitForAvailableNotDraining(true)
+
+	// Declare a short window and let it lapse on its own.
+	now = clock.Now()
+	require.NoError(t, nl.SetMaintenanceWindow(ctx, targetID, now, now.AddDuration(time.Second)))
+	waitForAvailableNotDraining(true)
+
+	// An invalid window is rejected.
+	require.Error(t, nl.SetMaintenanceWindow(ctx, targetID, now, now))
+}
+
+// TestNodeLivenessPlannedRestart verifies that a planned restart declared for a
+// node is carried along by its heartbeats until it has passed.
+func TestNodeLivenessPlannedRestart(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := testcluster.StartTestCluster(t, 2, base.TestClusterArgs{})
+	defer tc.Stopper().Stop(ctx)
+
+	nl := tc.Server(0).NodeLiveness().(*liveness.NodeLiveness)
+	clock := tc.Server(0).Clock()
+	targetID := tc.Server(1).NodeID()
+
+	waitForPlannedRestart := func(exp hlc.Timestamp) {
+		testutils.SucceedsSoon(t, func() error {
+			l, ok := nl.GetLiveness(targetID)
+			if !ok {
+				return errors.Errorf("n%d has no liveness record", targetID)
+			}
+			if l.PlannedRestartUntil != exp {
+				return errors.Errorf("expected n%d to be expected back by %s, found %s",
+					targetID, exp, l.PlannedRestartUntil)
+			}
+			return nil
+		})
+	}
+
+	// A planned restart survives heartbeats until it is cleared explicitly.
+	until := clock.Now().AddDuration(time.Hour)
+	require.NoError(t, nl.SetPlannedRestart(ctx, targetID, until))
+	waitForPlannedRestart(until)
+	require.NoError(t, nl.SetPlannedRestart(ctx, targetID, hlc.Timestamp{}))
+	waitForPlannedRestart(hlc.Timestamp{})
+
+	// A planned restart that has passed is cleared by the node's heartbeats.
+	require.NoError(t, nl.SetPlannedRestart(ctx, targetID, clock.Now().AddDuration(time.Second)))
+	waitForPlannedRestart(hlc.Timestamp{})
+}
+
+func TestNodeLivenessStickyDrain(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := testcluster.StartTestCluster(t, 2, base.TestClusterArgs{})
+	defer tc.Stopper().Stop(ctx)
+
+	nl := tc.Server(0).NodeLiveness().(*liveness.NodeLiveness)
+	targetID := tc.Server(1).NodeID()
+
+	waitForStickyDrain := func(exp bool) {
+		testutils.SucceedsSoon(t, func() error {
+			l, ok := nl.GetLiveness(targetID)
+			if !ok {
+				return errors.Errorf("n%d has no liveness record", targetID)
+			}
+			if l.StickyDrain != exp {
+				return errors.Errorf("expected sticky drain of n%d to be %t", targetID, exp)
+			}
+			return nil
+		})
+	}
+
+	// A sticky drain is only cleared explicitly.
+	require.NoError(t, nl.SetStickyDrain(ctx, targetID, true /* sticky */))
+	waitForStickyDrain(true)
+	require.NoError(t, nl.SetStickyDrain(ctx, targetID, false /* sticky */))
+	waitForStickyDrain(false)
+}
+
+// TestNodeLivenessDeparting verifies that a node announcing its clean shutdown
+// is considered unavailable by the other nodes right away, and keeps
+// announcing it on subsequent heartbeats.
+func TestNodeLivenessDeparting(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := testcluster.StartTestCluster(t, 2, base.TestClusterArgs{})
+	defer tc.Stopper().Stop(ctx)
+
+	nl := tc.Server(0).NodeLiveness().(*liveness.NodeLiveness)
+	departingNL := tc.Server(1).NodeLiveness().(*liveness.NodeLiveness)
+	departingID := tc.Server(1).NodeID()
+
+	require.NoError(t, departingNL.SetDeparting(ctx))
+	require.NoError(t, tc.Server(1).HeartbeatNodeLiveness())
+
+	testutils.SucceedsSoon(t, func() error {
+		l, ok := nl.GetLiveness(departingID)
+		if !ok {
+			return errors.Errorf("n%d has no liveness record", departingID)
+		}
+		if !l.Departing {
+			return errors.Errorf("n%d not departing yet", departingID)
+		}
+		return nil
+	})
+	l, _ := nl.GetLiveness(departingID)
+	now := tc.Server(0).Clock().Now()
+	// The record is still live, as the node's leases remain valid until it
+	// expires, but the node is no longer available.
+	require.True(t, l.IsLive(now))
+	require.Equal(t, livenesspb.NodeLivenessStatus_UNAVAILABLE,
+		l.Status(now, liveness.TimeUntilStoreDead.Get(&tc.Server(0).ClusterSettings().SV)))
+}
+
+// TestNodeVitality verifies that the vitality of nodes reflects whether they
+// are up.
+func TestNodeVitality(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := testcluster.StartTestCluster(t, 3, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	nl := tc.Server(0).NodeLiveness().(*liveness.NodeLiveness)
+	clock := tc.Server(0).Clock()
+	stoppedID := tc.Server(2).NodeID()
+
+	testutils.SucceedsSoon(t, func() error {
+		vitality := nl.ScanNodeVitalityFromCache()
+		if len(vitality) != 3 {
+			return errors.Errorf("expected vitality of 3 nodes, found %d", len(vitality))
+		}
+		for nodeID, v := range vitality {
+			if !v.IsLive(clock.Now()) {
+				return errors.Errorf("n%d not live: %+v", nodeID, v)
+			}
+		}
+		return nil
+	})
+	self, ok := nl.GetNodeVitality(tc.Server(0).NodeID())
+	require.True(t, ok)
+	require.Equal(t, livenesspb.Connectivity_CONNECTED, self.Connectivity)
+
+	tc.StopServer(2)
+	testutils.SucceedsSoon(t, func() error {
+		v, ok := nl.GetNodeVitality(stoppedID)
+		if !ok {
+			return errors.Errorf("no vitality for n%d", stoppedID)
+		}
+		if now := clock.Now(); v.IsLive(now) {
+			return errors.Errorf("n%d still live: %s", stoppedID, v.Status(now, time.Minute))
+		}
+		return nil
+	})
+}
+
+func TestNodeLivenessHeartbeatBatching(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := testcluster.StartTestCluster(t, 3, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	nl := tc.Server(0).NodeLiveness().(*liveness.NodeLiveness)
+	before, err := nl.GetLivenessesFromKV(ctx)
+	require.NoError(t, err)
+	require.Len(t, before, 3)
+
+	for _, s := range tc.Servers {
+		kvserverbase.LivenessHeartbeatBatchingEnabled.Override(ctx, &s.ClusterSettings().SV, true)
+	}
+
+	// All nodes keep renewing their records, through n1 which is the
+	// aggregator for all of them since none has a region.
+	testutils.SucceedsSoon(t, func() error {
+		after, err := nl.GetLivenessesFromKV(ctx)
+		if err != nil {
+			return err
+		}
+		for i, l := range after {
+			if !before[i].Expiration.Less(l.Expiration) {
+				return errors.Errorf("n%d has not heartbeated yet", l.NodeID)
+			}
+			if l.Epoch != before[i].Epoch {
+				return errors.Errorf("n%d epoch changed from %d to %d", l.NodeID, before[i].Epoch, l.Epoch)
+			}
+		}
+		return nil
+	})
+}
+
+func TestNodeLivenessBinaryVersion(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := testcluster.StartTestCluster(t, 3, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	nl := tc.Server(0).NodeLiveness().(*liveness.NodeLiveness)
+	binaryVersion := tc.Server(0).ClusterSettings().Version.BinaryVersion()
+	testutils.SucceedsSoon(t, func() error {
+		livenesses, err := nl.GetLivenessesFromKV(ctx)
+		if err != nil {
+			return err
+		}
+		for _, l := range livenesses {
+			if l.BinaryVersion != binaryVersion {
+				return errors.Errorf("n%d has binary version %s, expected %s", l.NodeID, l.BinaryVersion, binaryVersion)
+			}
+			if l.ActiveVersion.Less(binaryVersion) {
+				return errors.Errorf("n%d has active version %s", l.NodeID, l.ActiveVersion)
+			}
+		}
+		return nil
+	})
+
+	nodeIDs, err := nl.NodesBelowBinaryVersion(ctx, binaryVersion)
+	require.NoError(t, err)
+	require.Empty(t, nodeIDs)
+
+	newer := binaryVersion
+	newer.Internal += 2
+	nodeIDs, err = nl.NodesBelowBinaryVersion(ctx, newer)
+	require.NoError(t, err)
+	require.Len(t, nodeIDs, 3)
+}
+
+// TestNodeLivenessDecommissionTrace tests that the trace a decommission is
+// initiated in is recorded in the liveness record of the node for as long as
+// it is decommissioning.
+func TestNodeLivenessDecommissionTrace(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := testcluster.StartTestCluster(t, 3, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	s := tc.Server(0)
+	nl := s.NodeLiveness().(*liveness.NodeLiveness)
+	nodeID := tc.Server(2).NodeID()
+	getTrace := func() livenesspb.DecommissionTrace {
+		livenesses, err := nl.GetLivenessesFromKV(ctx)
+		require.NoError(t, err)
+		for _, l := range livenesses {
+			if l.NodeID == nodeID {
+				return l.DecommissionTrace
+			}
+		}
+		t.Fatalf("no liveness record for n%d", nodeID)
+		return livenesspb.DecommissionTrace{}
+	}
+
+	require.NoError(t, s.Decommission(ctx, livenesspb.MembershipStatus_DECOMMISSIONING, []roachpb.NodeID{nodeID}))
+	trace := getTrace()
+	require.False(t, trace.Empty())
+
+	// Repeating the decommission keeps the original trace.
+	require.NoError(t, s.Decommission(ctx, livenesspb.MembershipStatus_DECOMMISSIONING, []roachpb.NodeID{nodeID}))
+	require.Equal(t, trace, getTrace())
+
+	// Recommissioning the node clears it.
+	require.NoError(t, s.Decommission(ctx, livenesspb.MembershipStatus_ACTIVE, []roachpb.NodeID{nodeID}))
+	require.True(t, getTrace().Empty())
+}
+
+// TestNodeLivenessMembershipChangeLock tests that a membership change of a
+// node is refused while a different one is in flight.
+func TestNodeLivenessMembershipChangeLock(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := testcluster.StartTestCluster(t, 3, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	nl := tc.Server(0).NodeLiveness().(*liveness.NodeLiveness)
+	nodeID := tc.Server(2).NodeID()
+
+	_, err := tc.ServerConn(0).Exec(`SET CLUSTER SETTING kv.liveness.membership_change_lock.duration = '1m'`)
+	require.NoError(t, err)
+	testutils.SucceedsSoon(t, func() error {
+		if _, err := nl.SetMembershipStatus(ctx, nodeID, livenesspb.MembershipStatus_DECOMMISSIONING); err != nil {
+			return err
+		}
+		l, ok := nl.GetLiveness(nodeID)
+		if !ok || l.MembershipChangeLock.Operation != "decommission" {
+			return errors.Errorf("n%d not locked by a decommission: %+v", nodeID, l.MembershipChangeLock)
+		}
+		return nil
+	})
+	l, _ := nl.GetLiveness(nodeID)
+	require.Equal(t, tc.Server(0).NodeID(), l.MembershipChangeLock.HolderNodeID)
+
+	// Repeating the decommission is allowed, recommissioning the node isn't.
+	_, err = nl.SetMembershipStatus(ctx, nodeID, livenesspb.MembershipStatus_DECOMMISSIONING)
+	require.NoError(t, err)
+	_, err = nl.SetMembershipStatus(ctx, nodeID, livenesspb.MembershipStatus_ACTIVE)
+	var conflictErr *liveness.MembershipChangeConflictError
+	require.True(t, errors.As(err, &conflictErr), "%v", err)
+	require.Equal(t, "decommission", conflictErr.Lock.Operation)
+
+	// Without the lock, the node can be recommissioned.
+	_, err = tc.ServerConn(0).Exec(`SET CLUSTER SETTING kv.liveness.membership_change_lock.duration = '0s'`)
+	require.NoError(t, err)
+	testutils.SucceedsSoon(t, func() error {
+		_, err := nl.SetMembershipStatus(ctx, nodeID, livenesspb.MembershipStatus_ACTIVE)
+		return err
+	})
+}
+
 func TestNodeLivenessStatusMap(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 	defer log.Scope(t).Close(t)
@@ -386,3 +860,62 @@ func TestNodeLivenessNodeCount(t *testing.T) {
 	overrides[3] = livenesspb.NodeLivenessStatus_DECOMMISSIONING
 	require.Equal(t, numNodes-1, nl1.GetNodeCountWithOverrides(overrides))
 }
+
+// TestNodeLivenessUpdateFields verifies that several fields of a liveness
+// record can be updated together, and that the membership part of the update
+// follows the same rules as SetMembershipStatusWithToken.
+func TestNodeLivenessUpdateFields(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := testcluster.StartTestCluster(t, 2, base.TestClusterArgs{})
+	defer tc.Stopper().Stop(ctx)
+
+	// The draining status of a node is only ever updated by the node itself.
+	nl := tc.Server(1).NodeLiveness().(*liveness.NodeLiveness)
+	targetID := tc.Server(1).NodeID()
+
+	decommissioning := livenesspb.MembershipStatus_DECOMMISSIONING
+	draining := true
+	update := liveness.FieldUpdate{
+		Membership:       &decommissioning,
+		Draining:         &draining,
+		Reason:           "hardware refresh",
+		IdempotencyToken: "token-1",
+		Tags:             map[string]string{"rack": "r1"},
+	}
+	changed, err := nl.UpdateFields(ctx, targetID, update)
+	require.NoError(t, err)
+	require.True(t, changed)
+
+	livenesses, err := nl.GetLivenessesFromKV(ctx)
+	require.NoError(t, err)
+	var l livenesspb.Liveness
+	for _, l = range livenesses {
+		if l.NodeID == targetID {
+			break
+		}
+	}
+	require.Equal(t, targetID, l.NodeID)
+	require.Equal(t, decommissioning, l.Membership)
+	require.True(t, l.Draining)
+	require.Equal(t, "hardware refresh", l.Reason)
+	require.Equal(t, map[string]string{"rack": "r1"}, l.Tags)
+	require.Equal(t, livenesspb.AppliedMembershipChange{
+		Token: "token-1", Membership: decommissioning,
+	}, l.LastMembershipChange)
+
+	// Repeating the update is a no-op.
+	changed, err = nl.UpdateFields(ctx, targetID, update)
+	require.NoError(t, err)
+	require.False(t, changed)
+
+	// Invalid updates are refused as a whole.
+	active := livenesspb.MembershipStatus_ACTIVE
+	_, err = nl.UpdateFields(ctx, targetID, liveness.FieldUpdate{
+		Membership: &active,
+		Tags:       map[string]string{"": "empty key"},
+	})
+	require.Error(t, err)
+}
@@ -0,0 +1,132 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package liveness
+
+import (
+	"context"
+
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/settings"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/stop"
+	"github.com/cockroachdb/errors"
+)
+
+var externalProbesEnabled = settings.RegisterBoolSetting(
+	settings.SystemOnly,
+	"kv.liveness.external_probes.enabled",
+	"whether the reports of external failure detector probes, e.g. ones watching the instance "+
+		"termination notices of a cloud provider, are recorded; a node reported as gone by the "+
+		"probes of at least two nodes is considered dead as soon as its liveness expires, rather "+
+		"than after server.time_until_store_dead",
+	false,
+)
+
+// ExternalProbe is a source of external signals that nodes are definitively
+// gone, such as the instance termination notices of a cloud provider or the
+// conditions of Kubernetes nodes. Such signals let the cluster consider these
+// nodes dead, and re-replicate their data, as soon as their liveness expires.
+//
+// A probe must only report nodes that won't come back: a node that is merely
+// unreachable recovers without re-replication if it comes back before
+// server.time_until_store_dead.
+type ExternalProbe interface {
+	// Name identifies the probe in liveness records and logs.
+	Name() string
+	// Run watches for nodes that are gone until the context is canceled,
+	// reporting each through the given function, which returns an error if the
+	// report couldn't be recorded.
+	Run(ctx context.Context, report ExternalFailureReporter)
+}
+
+// ExternalFailureReporter reports the given node as definitively gone, with
+// the signal the report is based on as the reason.
+type ExternalFailureReporter func(ctx context.Context, nodeID roachpb.NodeID, reason string) error
+
+// runExternalProbes runs each of the given probes until the stopper quiesces.
+func (nl *NodeLiveness) runExternalProbes(ctx context.Context, probes []ExternalProbe) {
+	for _, probe := range probes {
+		probe := probe
+		taskName := "liveness-external-probe-" + probe.Name()
+		_ = nl.stopper.RunAsyncTaskEx(ctx, stop.TaskOpts{TaskName: taskName, SpanOpt: stop.SterileRootSpan}, func(context.Context) {
+			ambient := nl.ambientCtx
+			ambient.AddLogTag("liveness-external-probe", probe.Name())
+			ctx, cancel := nl.stopper.WithCancelOnQuiesce(context.Background())
+			defer cancel()
+			probe.Run(ambient.AnnotateCtx(ctx), func(
+				ctx context.Context, nodeID roachpb.NodeID, reason string,
+			) error {
+				return nl.ReportExternalFailure(ctx, probe.Name(), nodeID, reason)
+			})
+		})
+	}
+}
+
+// ReportExternalFailure records in the liveness record of the given node that
+// the named external probe reported it as definitively gone, for the given
+// reason. The first report keeps track of the probe and the reason; the
+// reports of other nodes corroborate it. Once corroborated, the node is
+// considered dead as soon as its liveness expires, so that a single node
+// can't have another one declared dead. The node clears the report should it
+// heartbeat again.
+func (nl *NodeLiveness) ReportExternalFailure(
+	ctx context.Context, probe string, nodeID roachpb.NodeID, reason string,
+) error {
+	ctx = nl.ambientCtx.AnnotateCtx(ctx)
+	if probe == "" {
+		return errors.AssertionFailedf("external failure reported without a probe name")
+	}
+	if !externalProbesEnabled.Get(&nl.st.SV) {
+		log.Ops.Infof(ctx, "ignoring report of probe %s that n%d is gone (%s): "+
+			"external probes are disabled", probe, nodeID, reason)
+		return nil
+	}
+	if nodeID == nl.cache.selfID() {
+		return errors.Errorf("probe %s cannot report the local node n%d as gone", probe, nodeID)
+	}
+	selfID := nl.cache.selfID()
+	var reported, gone bool
+	if err := nl.modifyLivenessRecord(ctx, nodeID, func(l *livenesspb.Liveness) error {
+		reported = false
+		if l.Membership.Decommissioned() || l.ExternalFailure.ReportedBy(selfID) {
+			return nil
+		}
+		if !l.ExternalFailure.Reported() {
+			l.ExternalFailure = livenesspb.ExternalFailure{
+				Probe:          probe,
+				Reason:         reason,
+				ReporterNodeID: selfID,
+				Timestamp:      nl.clock.Now(),
+			}
+		} else {
+			// NB: the record shares the slice with the one read from KV.
+			l.ExternalFailure.CorroboratingNodeIDs = append(
+				append([]roachpb.NodeID(nil), l.ExternalFailure.CorroboratingNodeIDs...), selfID)
+		}
+		reported, gone = true, l.ReportedGone()
+		return nil
+	}); err != nil {
+		return err
+	}
+	if !reported {
+		return nil
+	}
+	nl.metrics.ExternalFailureReports.Inc(1)
+	if gone {
+		log.Ops.Warningf(ctx, "probe %s reported n%d as gone: %s; the report is corroborated, "+
+			"the node is considered dead as soon as its liveness expires", probe, nodeID, reason)
+	} else {
+		log.Ops.Infof(ctx, "probe %s reported n%d as gone: %s; the report awaits corroboration "+
+			"by another node", probe, nodeID, reason)
+	}
+	return nil
+}
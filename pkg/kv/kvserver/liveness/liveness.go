@@ -17,12 +17,14 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/clusterversion"
 	"github.com/cockroachdb/cockroach/pkg/gossip"
 	"github.com/cockroachdb/cockroach/pkg/kv"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvpb"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/kvserverbase"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/rpc/nodedialer"
 	"github.com/cockroachdb/cockroach/pkg/server/telemetry"
 	"github.com/cockroachdb/cockroach/pkg/settings"
 	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
@@ -31,12 +33,12 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/util/hlc"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/metric"
-	"github.com/cockroachdb/cockroach/pkg/util/retry"
 	"github.com/cockroachdb/cockroach/pkg/util/stop"
 	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
 	"github.com/cockroachdb/cockroach/pkg/util/syncutil/singleflight"
 	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
 	"github.com/cockroachdb/cockroach/pkg/util/tracing"
+	"github.com/cockroachdb/cockroach/pkg/util/tracing/tracingpb"
 	"github.com/cockroachdb/errors"
 	"github.com/cockroachdb/redact"
 )
@@ -59,20 +61,100 @@ var TimeUntilStoreDead = settings.RegisterDurationSetting(
 	timeUntilStoreDeadSettingName,
 	"the time after which if there is no new gossiped information about a store, it is considered dead",
 	5*time.Minute,
-	func(v time.Duration) error {
-		// Setting this to less than the interval for gossiping stores is a big
-		// no-no, since this value is compared to the age of the most recent gossip
-		// from each store to determine whether that store is live. Put a buffer of
-		// 15 seconds on top to allow time for gossip to propagate.
-		const minTimeUntilStoreDead = gossip.StoresInterval + 15*time.Second
-		if v < minTimeUntilStoreDead {
-			return errors.Errorf("cannot set %s to less than %v: %v",
-				timeUntilStoreDeadSettingName, minTimeUntilStoreDead, v)
-		}
-		return nil
-	},
+	validateTimeUntilStoreDead,
 ).WithPublic()
 
+// minTimeUntilStoreDead is the lower bound of TimeUntilStoreDead, and of the
+// overrides of it declared for individual nodes. Setting this to less than the
+// interval for gossiping stores is a big no-no, since this value is compared
+// to the age of the most recent gossip from each store to determine whether
+// that store is live. Put a buffer of 15 seconds on top to allow time for
+// gossip to propagate.
+const minTimeUntilStoreDead = gossip.StoresInterval + 15*time.Second
+
+func validateTimeUntilStoreDead(v time.Duration) error {
+	if v < minTimeUntilStoreDead {
+		return errors.Errorf("cannot set %s to less than %v: %v",
+			timeUntilStoreDeadSettingName, minTimeUntilStoreDead, v)
+	}
+	return nil
+}
+
+// ValidateTimeUntilDeadOverride returns an error if the given duration cannot
+// be used to override TimeUntilStoreDead for a node.
+func ValidateTimeUntilDeadOverride(timeUntilDead time.Duration) error {
+	if timeUntilDead < minTimeUntilStoreDead {
+		return errors.Errorf("time until dead override cannot be less than %v: %v",
+			minTimeUntilStoreDead, timeUntilDead)
+	}
+	return nil
+}
+
+var membershipChangeLockDuration = settings.RegisterDurationSetting(
+	settings.SystemOnly,
+	"kv.liveness.membership_change_lock.duration",
+	"the time for which a membership change of a node (a decommission or a recommission) "+
+		"is considered in flight, during which different membership changes of the node are "+
+		"refused; repeating the change, as the decommission command does while it waits for "+
+		"the node to be drained of its replicas, extends it; 0 disables the check",
+	0,
+	settings.NonNegativeDuration,
+)
+
+var applicationStallThreshold = settings.RegisterDurationSetting(
+	settings.SystemOnly,
+	"kv.liveness.application_stall_threshold",
+	"the time for which a store of a node may be applying committed Raft commands without "+
+		"completing any before the node stops heartbeating its liveness record, so that its "+
+		"leases move elsewhere; 0 disables the check",
+	time.Minute,
+	settings.NonNegativeDuration,
+)
+
+var diskProbeTimeout = settings.RegisterDurationSetting(
+	settings.SystemOnly,
+	"kv.liveness.disk_probe_timeout",
+	"the time for which a heartbeat waits for the synchronous write to each local store that "+
+		"precedes it before failing, so that a node with a stalled disk stops heartbeating its "+
+		"liveness record early; 0 waits as long as the heartbeat itself",
+	0,
+	settings.NonNegativeDuration,
+)
+
+var memoryPressureThreshold = settings.RegisterFloatSetting(
+	settings.SystemOnly,
+	"kv.liveness.memory_pressure_threshold",
+	"the fraction of the memory available to a node, or of its Go memory limit, past which the "+
+		"node reports being under memory pressure in its liveness record, so that no leases are "+
+		"transferred to it and DistSQL doesn't plan flows on it; 0 disables the check",
+	0.9,
+	settings.NonNegativeFloatWithMaximum(1),
+)
+
+var shedLeasesCPUThreshold = settings.RegisterFloatSetting(
+	settings.SystemOnly,
+	"kv.liveness.shed_leases.cpu_threshold",
+	"the CPU usage of a node, as a fraction of its cores, past which the node asks for its "+
+		"leases to be moved elsewhere through its liveness record; 0 disables the check",
+	0,
+	settings.NonNegativeFloatWithMaximum(1),
+)
+
+var shedLeasesRunnableGoroutinesThreshold = settings.RegisterFloatSetting(
+	settings.SystemOnly,
+	"kv.liveness.shed_leases.runnable_goroutines_threshold",
+	"the average number of goroutines waiting to run per core of a node past which the node "+
+		"asks for its leases to be moved elsewhere through its liveness record; 0 disables the check",
+	0,
+	settings.NonNegativeFloat,
+)
+
+// shedLeasesHysteresis is the fraction of the kv.liveness.shed_leases
+// thresholds the load of a node shedding its leases must fall below for it to
+// stop doing so. This keeps a node whose load hovers around the thresholds
+// from flapping between shedding leases and attracting them back.
+const shedLeasesHysteresis = 0.8
+
 var (
 	// ErrMissingRecord is returned when asking for liveness information
 	// about a node for which nothing is known. This happens when attempting to
@@ -88,6 +170,11 @@ var (
 	// when encountering this error.
 	errChangeMembershipStatusFailed = errors.New("failed to change the membership status")
 
+	// errModifyLivenessRecordFailed is returned when we're not able to
+	// conditionally write a modification of a liveness record. It's safe to
+	// retry when encountering this error.
+	errModifyLivenessRecordFailed = errors.New("failed to modify the liveness record")
+
 	// ErrEpochIncremented is returned when a heartbeat request fails because
 	// the underlying liveness record has had its epoch incremented.
 	ErrEpochIncremented = errors.New("heartbeat failed on epoch increment")
@@ -98,6 +185,19 @@ var (
 	ErrEpochAlreadyIncremented = errors.New("epoch already incremented")
 )
 
+// MembershipChangeConflictError is returned when changing the membership of a
+// node conflicts with another membership change of the node in flight.
+type MembershipChangeConflictError struct {
+	NodeID roachpb.NodeID
+	// Lock is the lock held by the membership change in flight.
+	Lock livenesspb.MembershipChangeLock
+}
+
+func (e *MembershipChangeConflictError) Error() string {
+	return fmt.Sprintf("a %s of n%d is in flight through n%d (until %s)",
+		e.Lock.Operation, e.NodeID, e.Lock.HolderNodeID, e.Lock.Expiration)
+}
+
 type ErrEpochCondFailed struct {
 	expected, actual livenesspb.Liveness
 }
@@ -172,6 +272,26 @@ var (
 		Measurement: "Messages",
 		Unit:        metric.Unit_COUNT,
 	}
+	metaDiskProbeFailures = metric.Metadata{
+		Name: "liveness.disk_probe_failures",
+		Help: "Number of synchronous writes to a local store preceding a node liveness " +
+			"heartbeat that failed or timed out, failing the heartbeat",
+		Measurement: "Probes",
+		Unit:        metric.Unit_COUNT,
+	}
+	metaUpdateRetries = metric.Metadata{
+		Name: "liveness.update_retries",
+		Help: "Number of retries of node liveness record updates that raced with another " +
+			"update of the same record or had an ambiguous result",
+		Measurement: "Retries",
+		Unit:        metric.Unit_COUNT,
+	}
+	metaUpdateRetriesExhausted = metric.Metadata{
+		Name:        "liveness.update_retries_exhausted",
+		Help:        "Number of node liveness record updates that failed after exhausting their retries",
+		Measurement: "Updates",
+		Unit:        metric.Unit_COUNT,
+	}
 	metaEpochIncrements = metric.Metadata{
 		Name:        "liveness.epochincrements",
 		Help:        "Number of times this node has incremented its liveness epoch",
@@ -184,6 +304,79 @@ var (
 		Measurement: "Latency",
 		Unit:        metric.Unit_NANOSECONDS,
 	}
+	metaRenewalMargin = metric.Metadata{
+		Name: "liveness.renewal_margin",
+		Help: "Time left on the liveness record of this node when a heartbeat renewed it; " +
+			"a margin shrinking towards zero warns that the node is about to lose its liveness",
+		Measurement: "Margin",
+		Unit:        metric.Unit_NANOSECONDS,
+	}
+	metaGossipPropagationLatency = metric.Metadata{
+		Name: "liveness.gossip.propagation_latency",
+		Help: "Time between the heartbeats of other nodes and the receipt of their liveness " +
+			"records by this node through gossip, including the clock offset between the nodes",
+		Measurement: "Latency",
+		Unit:        metric.Unit_NANOSECONDS,
+	}
+	metaGossipSkippedHeartbeats = metric.Metadata{
+		Name: "liveness.gossip.skipped_heartbeats",
+		Help: "Number of heartbeats of other nodes that were superseded before their liveness " +
+			"records reached this node through gossip",
+		Measurement: "Heartbeats",
+		Unit:        metric.Unit_COUNT,
+	}
+	metaRangeHeartbeatRate = metric.Metadata{
+		Name:        "liveness.range.heartbeat_rate",
+		Help:        "Rate of heartbeats of all nodes to the node liveness range, as observed by this node",
+		Measurement: "Heartbeats/Sec",
+		Unit:        metric.Unit_COUNT,
+	}
+	metaRangeUtilization = metric.Metadata{
+		Name:        "liveness.range.utilization",
+		Help:        "Estimated utilization of the node liveness range, relative to kv.liveness.range_capacity and the liveness renewal duration",
+		Measurement: "Utilization",
+		Unit:        metric.Unit_PERCENT,
+	}
+	metaRangeHot = metric.Metadata{
+		Name:        "liveness.range.hot",
+		Help:        "1 if the node liveness range is considered hot and heartbeats are spread out, 0 otherwise",
+		Measurement: "Hot",
+		Unit:        metric.Unit_COUNT,
+	}
+	metaHeartbeatLoopStalls = metric.Metadata{
+		Name: "liveness.heartbeat_loop.stalls",
+		Help: "Number of times a node liveness heartbeat attempt of this node was found wedged " +
+			"by the watchdog (see kv.liveness.heartbeat_watchdog.threshold)",
+		Measurement: "Stalls",
+		Unit:        metric.Unit_COUNT,
+	}
+	metaHeartbeatLoopRestarts = metric.Metadata{
+		Name:        "liveness.heartbeat_loop.restarts",
+		Help:        "Number of times the watchdog replaced the wedged node liveness heartbeat loop of this node",
+		Measurement: "Restarts",
+		Unit:        metric.Unit_COUNT,
+	}
+	metaExternalFailureReports = metric.Metadata{
+		Name: "liveness.external_probe.reports",
+		Help: "Number of reports that nodes are definitively gone, first or corroborating, " +
+			"recorded on behalf of the external failure detector probes running on this node",
+		Measurement: "Reports",
+		Unit:        metric.Unit_COUNT,
+	}
+	metaDeadVerdictCacheHits = metric.Metadata{
+		Name: "liveness.dead_verdict_cache.hits",
+		Help: "Number of checks of whether a node is dead answered by a previous verdict, " +
+			"since the liveness record of the node wasn't updated since it was found dead",
+		Measurement: "Lookups",
+		Unit:        metric.Unit_COUNT,
+	}
+	metaMaxClockOffset = metric.Metadata{
+		Name: "liveness.max_clock_offset",
+		Help: "Largest clock offset against their peers recorded by the nodes in their " +
+			"liveness records at their last heartbeat",
+		Measurement: "Clock Offset",
+		Unit:        metric.Unit_NANOSECONDS,
+	}
 )
 
 // Metrics holds metrics for use with node liveness activity.
@@ -192,8 +385,44 @@ type Metrics struct {
 	HeartbeatsInFlight *metric.Gauge
 	HeartbeatSuccesses *metric.Counter
 	HeartbeatFailures  telemetry.CounterWithMetric
+	DiskProbeFailures  *metric.Counter
 	EpochIncrements    telemetry.CounterWithMetric
 	HeartbeatLatency   metric.IHistogram
+	RangeHeartbeatRate *metric.GaugeFloat64
+	RangeUtilization   *metric.GaugeFloat64
+	RangeHot           *metric.Gauge
+	MaxClockOffset     *metric.Gauge
+	MembershipState    *stateSetGauge
+	StatusState        *stateSetGauge
+	LocalityStatus     *localityStatusGauge
+
+	// HeartbeatLoopStalls and HeartbeatLoopRestarts track the interventions
+	// of the heartbeat loop watchdog, see checkHeartbeatLoop.
+	HeartbeatLoopStalls   *metric.Counter
+	HeartbeatLoopRestarts *metric.Counter
+
+	// ExternalFailureReports counts the reports of external probes that nodes
+	// are gone recorded by this node, see ReportExternalFailure.
+	ExternalFailureReports *metric.Counter
+
+	// DeadVerdictCacheHits counts the checks answered by the cache of dead
+	// nodes, see IsDead.
+	DeadVerdictCacheHits *metric.Counter
+
+	// RenewalMargin records the time left on the liveness record of this node
+	// whenever a heartbeat renews it, or zero if the record had expired.
+	RenewalMargin metric.IHistogram
+
+	// GossipPropagationLatency and GossipSkippedHeartbeats track the
+	// propagation of the liveness records of other nodes through gossip, see
+	// livenessGossiped.
+	GossipPropagationLatency metric.IHistogram
+	GossipSkippedHeartbeats  *metric.Counter
+
+	// UpdateRetries and UpdateRetriesExhausted track the retries of liveness
+	// record updates, see RetryPolicy.
+	UpdateRetries          *metric.Counter
+	UpdateRetriesExhausted *metric.Counter
 }
 
 // IsLiveCallback is invoked when a node's IsLive state changes to true.
@@ -241,6 +470,10 @@ type NodeLiveness struct {
 	livenessThreshold time.Duration
 	cache             *cache
 	renewalDuration   time.Duration
+	nodeDialer        *nodedialer.Dialer
+	swim              *swimDetector // nil if no Prober was provided
+	load              *loadTracker
+	batcher           heartbeatBatcher
 	selfSem           chan struct{}
 	st                *cluster.Settings
 	otherSem          chan struct{}
@@ -262,6 +495,70 @@ type NodeLiveness struct {
 	// of the local liveness instance's heartbeat loop.
 	onSelfHeartbeat HeartbeatCallback
 
+	// heartbeatsPausedUntil is the time, in nanoseconds since the epoch, until
+	// which the heartbeats of the local node are paused through
+	// PauseHeartbeats. Accessed atomically.
+	heartbeatsPausedUntil int64
+
+	// maxClockOffset returns the largest clock offset observed against other
+	// nodes, recorded in the liveness record on every heartbeat. Nil if
+	// unknown.
+	maxClockOffset func() time.Duration
+
+	// checkApplicationProgress returns an error if a local store is wedged
+	// applying committed Raft commands, in which case the local node doesn't
+	// heartbeat. Nil if the check is skipped.
+	checkApplicationProgress func(threshold time.Duration) error
+
+	// underMemoryPressure returns whether the local node is, or recently was,
+	// using at least the given fraction of the memory available to it. This is
+	// recorded in the liveness record on every heartbeat. Nil if unknown.
+	underMemoryPressure func(threshold float64) bool
+
+	// overloaded returns whether the local node's CPU usage or goroutine
+	// backlog is at least the given thresholds, in which case it asks for its
+	// leases to be moved elsewhere through its liveness record. Nil if unknown.
+	overloaded func(cpuThreshold, runnableGoroutinesThreshold float64) bool
+
+	// cold is set if the local node runs in cold mode, in which case it
+	// heartbeats kv.liveness.cold_node.ttl_multiplier times less often, with
+	// a proportionally longer TTL.
+	cold bool
+
+	// retryPolicy governs the retries of liveness record updates.
+	retryPolicy RetryPolicy
+
+	// storeDigests returns the digests of the capacity of the local node's
+	// stores, which are recorded in its liveness record on every heartbeat.
+	// Nil if unknown.
+	storeDigests func(ctx context.Context) []livenesspb.StoreDigest
+
+	// externalProbes report nodes that are definitively gone. See
+	// ExternalProbe.
+	externalProbes []ExternalProbe
+
+	// departing is set once the local node is done draining as part of a
+	// clean shutdown, and recorded in the liveness record on every heartbeat.
+	// See SetDeparting.
+	departing syncutil.AtomicBool
+
+	// watchdog tracks the attempts of the heartbeat loop, to detect and
+	// recover from a wedged loop. See runHeartbeatWatchdog.
+	watchdog heartbeatWatchdog
+
+	// heartbeatFailures tracks the failed heartbeat attempts of the local node,
+	// and is recorded in the liveness record on every heartbeat. See
+	// HeartbeatsFailing.
+	heartbeatFailures struct {
+		syncutil.Mutex
+		livenesspb.HeartbeatFailures
+	}
+
+	// ttlAutotune is the factor by which the TTL controller extends the local
+	// node's liveness record, its renewal window and the interval between its
+	// heartbeats. See autotuneTTL.
+	ttlAutotune syncutil.AtomicFloat64
+
 	// engines is written to before heartbeating to avoid maintaining liveness
 	// when a local disks is stalled.
 	engines []diskStorage.Engine
@@ -307,18 +604,67 @@ type NodeLivenessOptions struct {
 	OnNodeDecommissioning OnNodeDecommissionCallback
 	Engines               []diskStorage.Engine
 	OnSelfHeartbeat       HeartbeatCallback
+	// NodeDialer is used to determine the health of RPC connections to other
+	// nodes, which is fused with their liveness records into a NodeVitality.
+	// If nil, the connectivity of other nodes is unknown.
+	NodeDialer *nodedialer.Dialer
+	// Prober is used by the SWIM failure detector, which replaces the RPC
+	// connection health as the source of connectivity when
+	// kv.liveness.failure_detector.mode is set to 'swim'. If nil, the SWIM
+	// failure detector is unavailable.
+	Prober Prober
+	// MaxClockOffset returns the largest clock offset the node observed against
+	// its peers, which is recorded in its liveness record on every heartbeat.
+	// If nil, no offset is recorded.
+	MaxClockOffset func() time.Duration
+	// CheckApplicationProgress returns an error if a store of the node has
+	// been applying committed Raft commands for at least the given threshold
+	// without completing any. The node doesn't heartbeat its liveness record
+	// while it does. If nil, the check is skipped.
+	CheckApplicationProgress func(threshold time.Duration) error
+	// UnderMemoryPressure returns whether the node is, or recently was, using
+	// at least the given fraction of the memory available to it, which is
+	// recorded in its liveness record on every heartbeat. If nil, the node is
+	// never considered under memory pressure.
+	UnderMemoryPressure func(threshold float64) bool
+	// Overloaded returns whether the CPU usage of the node, as a fraction of
+	// its cores, or its average number of runnable goroutines per core, is at
+	// least the given thresholds. The node then asks for its leases to be moved
+	// elsewhere through its liveness record. If nil, the node never does.
+	Overloaded func(cpuThreshold, runnableGoroutinesThreshold float64) bool
+	// Cold, if set, runs the node in cold mode, meant for mostly idle nodes
+	// such as archival ones: the node heartbeats its liveness record
+	// kv.liveness.cold_node.ttl_multiplier times less often, with a
+	// proportionally longer TTL, which it records in its liveness record.
+	Cold bool
+	// RetryPolicy governs the retries of liveness record updates. If unset,
+	// DefaultRetryPolicy is used.
+	RetryPolicy RetryPolicy
+	// StoreDigests returns the digests of the capacity of the stores of the
+	// node, which are recorded in its liveness record on every heartbeat if
+	// kv.liveness.store_digests.enabled is set. If nil, none are recorded.
+	StoreDigests func(ctx context.Context) []livenesspb.StoreDigest
+	// ExternalProbes are run once the node liveness is started, and report
+	// nodes that are definitively gone, which are then considered dead as soon
+	// as their liveness expires.
+	ExternalProbes []ExternalProbe
 }
 
 // NewNodeLiveness returns a new instance of NodeLiveness configured
 // with the specified gossip instance.
 func NewNodeLiveness(opts NodeLivenessOptions) *NodeLiveness {
+	var version clusterversion.Handle
+	if opts.Settings != nil {
+		version = opts.Settings.Version
+	}
 	nl := &NodeLiveness{
 		ambientCtx:            opts.AmbientCtx,
 		stopper:               opts.Stopper,
 		clock:                 opts.Clock,
-		storage:               storage{db: opts.DB},
+		storage:               storage{db: opts.DB, version: version, defaultTTL: opts.LivenessThreshold},
 		livenessThreshold:     opts.LivenessThreshold,
 		renewalDuration:       opts.RenewalDuration,
+		nodeDialer:            opts.NodeDialer,
 		selfSem:               make(chan struct{}, 1),
 		st:                    opts.Settings,
 		otherSem:              make(chan struct{}, 1),
@@ -328,12 +674,25 @@ func NewNodeLiveness(opts NodeLivenessOptions) *NodeLiveness {
 		engineSyncs:           singleflight.NewGroup("engine sync", "engine"),
 		engines:               opts.Engines,
 		onSelfHeartbeat:       opts.OnSelfHeartbeat,
+		maxClockOffset:        opts.MaxClockOffset,
+
+		checkApplicationProgress: opts.CheckApplicationProgress,
+		underMemoryPressure:      opts.UnderMemoryPressure,
+		overloaded:               opts.Overloaded,
+		cold:                     opts.Cold,
+		retryPolicy:              opts.RetryPolicy,
+		storeDigests:             opts.StoreDigests,
+		externalProbes:           opts.ExternalProbes,
+	}
+	if nl.retryPolicy == (RetryPolicy{}) {
+		nl.retryPolicy = DefaultRetryPolicy()
 	}
 	nl.metrics = Metrics{
 		LiveNodes:          metric.NewFunctionalGauge(metaLiveNodes, nl.numLiveNodes),
 		HeartbeatsInFlight: metric.NewGauge(metaHeartbeatsInFlight),
 		HeartbeatSuccesses: metric.NewCounter(metaHeartbeatSuccesses),
 		HeartbeatFailures:  telemetry.NewCounterWithMetric(metaHeartbeatFailures),
+		DiskProbeFailures:  metric.NewCounter(metaDiskProbeFailures),
 		EpochIncrements:    telemetry.NewCounterWithMetric(metaEpochIncrements),
 		HeartbeatLatency: metric.NewHistogram(metric.HistogramOptions{
 			Mode:     metric.HistogramModePreferHdrLatency,
@@ -341,8 +700,46 @@ func NewNodeLiveness(opts NodeLivenessOptions) *NodeLiveness {
 			Duration: opts.HistogramWindowInterval,
 			Buckets:  metric.NetworkLatencyBuckets,
 		}),
+		RangeHeartbeatRate: metric.NewGaugeFloat64(metaRangeHeartbeatRate),
+		RangeUtilization:   metric.NewGaugeFloat64(metaRangeUtilization),
+		RangeHot:           metric.NewGauge(metaRangeHot),
+		MaxClockOffset:     metric.NewFunctionalGauge(metaMaxClockOffset, nl.maxClockOffsetNanos),
+
+		HeartbeatLoopStalls:   metric.NewCounter(metaHeartbeatLoopStalls),
+		HeartbeatLoopRestarts: metric.NewCounter(metaHeartbeatLoopRestarts),
+
+		ExternalFailureReports: metric.NewCounter(metaExternalFailureReports),
+		DeadVerdictCacheHits:   metric.NewCounter(metaDeadVerdictCacheHits),
+
+		RenewalMargin: metric.NewHistogram(metric.HistogramOptions{
+			Mode:     metric.HistogramModePreferHdrLatency,
+			Metadata: metaRenewalMargin,
+			Duration: opts.HistogramWindowInterval,
+			Buckets:  metric.IOLatencyBuckets,
+		}),
+
+		GossipPropagationLatency: metric.NewHistogram(metric.HistogramOptions{
+			Mode:     metric.HistogramModePreferHdrLatency,
+			Metadata: metaGossipPropagationLatency,
+			Duration: opts.HistogramWindowInterval,
+			Buckets:  metric.NetworkLatencyBuckets,
+		}),
+		GossipSkippedHeartbeats: metric.NewCounter(metaGossipSkippedHeartbeats),
+
+		UpdateRetries:          metric.NewCounter(metaUpdateRetries),
+		UpdateRetriesExhausted: metric.NewCounter(metaUpdateRetriesExhausted),
+	}
+	nl.metrics.MembershipState = nl.newMembershipStateSet()
+	nl.metrics.StatusState = nl.newStatusStateSet()
+	nl.metrics.LocalityStatus = nl.newLocalityStatusGauge()
+	nl.load = newLoadTracker(opts.Settings, opts.RenewalDuration)
+	nl.batcher.nl = nl
+	nl.cache = newCache(opts.Gossip, opts.Clock, version, opts.LivenessThreshold, nl.cacheUpdated, nl.livenessGossiped)
+	opts.Gossip.RegisterCallback(gossip.MakePrefixPattern(gossip.KeyNodeDescPrefix),
+		func(string, roachpb.Value) { nl.batcher.invalidateAggregators() })
+	if opts.Prober != nil {
+		nl.swim = newSWIMDetector(opts.Settings, opts.Clock, opts.Stopper, opts.Prober, nl.swimMembers)
 	}
-	nl.cache = newCache(opts.Gossip, opts.Clock, nl.cacheUpdated)
 	nl.heartbeatToken <- struct{}{}
 
 	return nl
@@ -366,11 +763,20 @@ func (nl *NodeLiveness) sem(nodeID roachpb.NodeID) chan struct{} {
 // pkg/server/drain.go for details.
 func (nl *NodeLiveness) SetDraining(
 	ctx context.Context, drain bool, reporter func(int, redact.SafeString),
+) error {
+	return nl.SetDrainingWithReason(ctx, drain, reporter, "" /* reason */)
+}
+
+// SetDrainingWithReason is like SetDraining, but additionally records the
+// given operator-supplied reason in the liveness record. An empty reason
+// leaves the previously recorded one in place.
+func (nl *NodeLiveness) SetDrainingWithReason(
+	ctx context.Context, drain bool, reporter func(int, redact.SafeString), reason string,
 ) error {
 	ctx = nl.ambientCtx.AnnotateCtx(ctx)
-	retryOpts := base.DefaultRetryOptions()
-	retryOpts.Closer = nl.stopper.ShouldQuiesce()
-	for r := retry.StartWithCtx(ctx, retryOpts); r.Next(); {
+	var lastErr error
+	r := nl.startRetries(ctx)
+	for r.Next() {
 		oldLivenessRec, ok := nl.cache.Self()
 		if !ok {
 			// There was a cache miss, let's now fetch the record from KV
@@ -382,13 +788,14 @@ func (nl *NodeLiveness) SetDraining(
 			}
 			oldLivenessRec = livenessRec
 		}
-		if err := nl.setDrainingInternal(ctx, oldLivenessRec, drain, reporter); err != nil {
+		if err := nl.setDrainingInternal(ctx, oldLivenessRec, drain, reporter, reason); err != nil {
 			if log.V(1) {
 				log.Infof(ctx, "attempting to set liveness draining status to %v: %v", drain, err)
 			}
 			if grpcutil.IsConnectionRejected(err) {
 				return err
 			}
+			lastErr = err
 			continue
 		}
 		return nil
@@ -396,6 +803,9 @@ func (nl *NodeLiveness) SetDraining(
 	if err := ctx.Err(); err != nil {
 		return err
 	}
+	if r.exhausted() {
+		return errors.Wrapf(lastErr, "failed to drain self after %d retries", r.CurrentAttempt())
+	}
 	return errors.New("failed to drain self")
 }
 
@@ -406,6 +816,33 @@ func (nl *NodeLiveness) SetDraining(
 // finding the target status possibly set by another node).
 func (nl *NodeLiveness) SetMembershipStatus(
 	ctx context.Context, nodeID roachpb.NodeID, targetStatus livenesspb.MembershipStatus,
+) (statusChanged bool, err error) {
+	return nl.SetMembershipStatusWithReason(ctx, nodeID, targetStatus, "" /* reason */)
+}
+
+// SetMembershipStatusWithReason is like SetMembershipStatus, but additionally
+// records the given operator-supplied reason in the liveness record, replacing
+// the previously recorded one.
+func (nl *NodeLiveness) SetMembershipStatusWithReason(
+	ctx context.Context,
+	nodeID roachpb.NodeID,
+	targetStatus livenesspb.MembershipStatus,
+	reason string,
+) (statusChanged bool, err error) {
+	return nl.SetMembershipStatusWithToken(ctx, nodeID, targetStatus, reason, "" /* token */)
+}
+
+// SetMembershipStatusWithToken is like SetMembershipStatusWithReason, but
+// additionally records the given client-supplied idempotency token in the
+// liveness record. If the record shows that the membership change was already
+// made with the token, the change is a no-op, even if the membership status
+// has changed since. An empty token is ignored.
+func (nl *NodeLiveness) SetMembershipStatusWithToken(
+	ctx context.Context,
+	nodeID roachpb.NodeID,
+	targetStatus livenesspb.MembershipStatus,
+	reason string,
+	token string,
 ) (statusChanged bool, err error) {
 	ctx = nl.ambientCtx.AnnotateCtx(ctx)
 
@@ -446,7 +883,7 @@ func (nl *NodeLiveness) SetMembershipStatus(
 			return false, err
 		}
 
-		return nl.setMembershipStatusInternal(ctx, oldLivenessRec, targetStatus)
+		return nl.setMembershipStatusInternal(ctx, oldLivenessRec, targetStatus, reason, token)
 	}
 
 	for {
@@ -459,8 +896,324 @@ func (nl *NodeLiveness) SetMembershipStatus(
 	}
 }
 
+// SetMaintenanceWindow declares a maintenance window for the given node,
+// starting at start and ending at end. While the window is in effect, the node
+// is treated as draining. The window lapses on its own at end, so there is no
+// need to clear it explicitly; passing an empty end clears the window early.
+func (nl *NodeLiveness) SetMaintenanceWindow(
+	ctx context.Context, nodeID roachpb.NodeID, start, end hlc.Timestamp,
+) error {
+	ctx = nl.ambientCtx.AnnotateCtx(ctx)
+	if !end.IsEmpty() && !start.Less(end) {
+		return errors.Errorf("maintenance window must end after it starts (start: %s, end: %s)", start, end)
+	}
+	if end.IsEmpty() {
+		start = hlc.Timestamp{}
+	}
+	return nl.modifyLivenessRecord(ctx, nodeID, func(l *livenesspb.Liveness) error {
+		l.MaintenanceStart = start
+		l.MaintenanceEnd = end
+		return nil
+	})
+}
+
+// SetPlannedRestart declares that the given node is going down for a planned
+// restart and is expected back by until. Until then, the allocator doesn't
+// consider the node dead (see livenesspb.Liveness.AwaitingRestart). The
+// declaration is cleared when the node comes back; passing an empty timestamp
+// clears it early.
+func (nl *NodeLiveness) SetPlannedRestart(
+	ctx context.Context, nodeID roachpb.NodeID, until hlc.Timestamp,
+) error {
+	ctx = nl.ambientCtx.AnnotateCtx(ctx)
+	return nl.modifyLivenessRecord(ctx, nodeID, func(l *livenesspb.Liveness) error {
+		l.PlannedRestartUntil = until
+		return nil
+	})
+}
+
+// SetStickyDrain records whether the given node is drained with a sticky
+// drain, i.e. whether it stays draining across restarts until it is
+// explicitly undrained. The draining status itself is set separately, by
+// SetDraining.
+func (nl *NodeLiveness) SetStickyDrain(
+	ctx context.Context, nodeID roachpb.NodeID, sticky bool,
+) error {
+	ctx = nl.ambientCtx.AnnotateCtx(ctx)
+	return nl.modifyLivenessRecord(ctx, nodeID, func(l *livenesspb.Liveness) error {
+		l.StickyDrain = sticky
+		return nil
+	})
+}
+
+// SetDeparting announces that the local node is shutting down cleanly, which
+// makes other nodes treat it as unavailable right away instead of waiting for
+// its liveness record to expire (see livenesspb.Liveness.Departing). It is
+// meant to be called once the node is done draining, right before it stops;
+// the node keeps announcing its departure on any heartbeat it may still send.
+func (nl *NodeLiveness) SetDeparting(ctx context.Context) error {
+	ctx = nl.ambientCtx.AnnotateCtx(ctx)
+	nl.departing.Set(true)
+	return nl.modifyLivenessRecord(ctx, nl.cache.selfID(), func(l *livenesspb.Liveness) error {
+		l.Departing = true
+		return nil
+	})
+}
+
+// UpdateLastDrain applies the given change, made at the given time, to the
+// record of the local node's last drain (see livenesspb.Liveness.LastDrain),
+// and returns the updated record.
+func (nl *NodeLiveness) UpdateLastDrain(
+	ctx context.Context, update func(d *livenesspb.DrainOperation, now hlc.Timestamp),
+) (livenesspb.DrainOperation, error) {
+	ctx = nl.ambientCtx.AnnotateCtx(ctx)
+	var drain livenesspb.DrainOperation
+	err := nl.modifyLivenessRecord(ctx, nl.cache.selfID(), func(l *livenesspb.Liveness) error {
+		update(&l.LastDrain, nl.clock.Now())
+		drain = l.LastDrain
+		return nil
+	})
+	return drain, err
+}
+
+// SetTags replaces the tags of the given node's liveness record (see
+// livenesspb.Liveness.Tags). Passing no tags clears them.
+func (nl *NodeLiveness) SetTags(
+	ctx context.Context, nodeID roachpb.NodeID, tags map[string]string,
+) error {
+	ctx = nl.ambientCtx.AnnotateCtx(ctx)
+	if err := livenesspb.ValidateTags(tags); err != nil {
+		return err
+	}
+	// Copy the tags, as the record is shared with the cache once written.
+	var newTags map[string]string
+	if len(tags) > 0 {
+		newTags = make(map[string]string, len(tags))
+		for k, v := range tags {
+			newTags[k] = v
+		}
+	}
+	return nl.modifyLivenessRecord(ctx, nodeID, func(l *livenesspb.Liveness) error {
+		l.Tags = newTags
+		return nil
+	})
+}
+
+// SetTimeUntilDeadOverride overrides TimeUntilStoreDead for the given node
+// until the given expiration, which lets the allocator wait longer (or less)
+// than usual before re-replicating the node's replicas during a maintenance
+// event. The override lapses on its own; passing an empty expiration clears it
+// early.
+func (nl *NodeLiveness) SetTimeUntilDeadOverride(
+	ctx context.Context, nodeID roachpb.NodeID, timeUntilDead time.Duration, expiration hlc.Timestamp,
+) error {
+	ctx = nl.ambientCtx.AnnotateCtx(ctx)
+	var override livenesspb.TimeUntilDeadOverride
+	if !expiration.IsEmpty() {
+		if err := ValidateTimeUntilDeadOverride(timeUntilDead); err != nil {
+			return err
+		}
+		override = livenesspb.TimeUntilDeadOverride{
+			TimeUntilDeadNanos: timeUntilDead.Nanoseconds(),
+			Expiration:         expiration,
+		}
+	}
+	return nl.modifyLivenessRecord(ctx, nodeID, func(l *livenesspb.Liveness) error {
+		l.TimeUntilDeadOverride = override
+		return nil
+	})
+}
+
+// ScheduleDecommission durably records that the given node is to start
+// decommissioning at the given time. The decommission itself is carried out by
+// the servers in the cluster once that time has passed (see
+// livenesspb.Liveness.DecommissionDue). An empty timestamp cancels a
+// previously scheduled decommission. The reason, if non-empty, is recorded in
+// the liveness record and used for the eventual decommission.
+func (nl *NodeLiveness) ScheduleDecommission(
+	ctx context.Context, nodeID roachpb.NodeID, at hlc.Timestamp, reason string,
+) error {
+	ctx = nl.ambientCtx.AnnotateCtx(ctx)
+	return nl.modifyLivenessRecord(ctx, nodeID, func(l *livenesspb.Liveness) error {
+		if !at.IsEmpty() && !l.Membership.Active() {
+			return errors.Errorf("can only schedule the decommission of an active node; n%d found to be %s",
+				nodeID, l.Membership)
+		}
+		l.DecommissionAt = at
+		if reason != "" {
+			l.Reason = reason
+		}
+		return nil
+	})
+}
+
+// FieldUpdate describes changes to several fields of a liveness record that
+// UpdateFields applies together. Fields left nil (or empty) are unchanged.
+type FieldUpdate struct {
+	// Draining is the new draining status of the node.
+	Draining *bool
+	// StickyDrain is whether the node is drained with a sticky drain (see
+	// SetStickyDrain).
+	StickyDrain *bool
+	// PlannedRestartUntil is the time by which the node is expected back from
+	// a planned restart (see SetPlannedRestart).
+	PlannedRestartUntil *hlc.Timestamp
+	// Membership is the membership status to move the node to. As with
+	// SetMembershipStatus, moving the node to its current status is a no-op.
+	Membership *livenesspb.MembershipStatus
+	// Reason replaces the operator-supplied reason recorded in the liveness
+	// record.
+	Reason string
+	// IdempotencyToken identifies the membership change, as with
+	// SetMembershipStatusWithToken.
+	IdempotencyToken string
+	// Tags replace the tags of the node (see SetTags); an empty non-nil map
+	// clears them.
+	Tags map[string]string
+}
+
+// UpdateFields applies all the changes of the given update to the liveness
+// record of the given node in a single conditional put, retrying as a whole if
+// the record is concurrently updated. This spares the liveness range the
+// contention of one update per field. The membership part of the update
+// follows the same rules as SetMembershipStatusWithToken. It returns whether
+// the membership status of the node was changed by this call.
+func (nl *NodeLiveness) UpdateFields(
+	ctx context.Context, nodeID roachpb.NodeID, u FieldUpdate,
+) (membershipChanged bool, err error) {
+	ctx = nl.ambientCtx.AnnotateCtx(ctx)
+	if u.Membership != nil {
+		if err := livenesspb.ValidateMembershipStatus(*u.Membership); err != nil {
+			return false, err
+		}
+	}
+	var tags map[string]string
+	if u.Tags != nil {
+		if err := livenesspb.ValidateTags(u.Tags); err != nil {
+			return false, err
+		}
+		// Copy the tags, as the record is shared with the cache once written.
+		if len(u.Tags) > 0 {
+			tags = make(map[string]string, len(u.Tags))
+			for k, v := range u.Tags {
+				tags[k] = v
+			}
+		}
+	}
+
+	err = nl.modifyLivenessRecord(ctx, nodeID, func(l *livenesspb.Liveness) error {
+		membershipChanged = false
+		if u.Membership != nil {
+			// Unlike SetMembershipStatus, the recorded reason is kept unless
+			// the update replaces it.
+			reason := l.Reason
+			if u.Reason != "" {
+				reason = u.Reason
+			}
+			changed, err := nl.applyMembershipChange(ctx, l, *u.Membership, reason, u.IdempotencyToken)
+			if err != nil {
+				return err
+			}
+			membershipChanged = changed
+		}
+		if u.Draining != nil {
+			l.Draining = *u.Draining
+		}
+		if u.StickyDrain != nil {
+			l.StickyDrain = *u.StickyDrain
+		}
+		if u.PlannedRestartUntil != nil {
+			l.PlannedRestartUntil = *u.PlannedRestartUntil
+		}
+		if u.Reason != "" {
+			l.Reason = u.Reason
+		}
+		if u.Tags != nil {
+			l.Tags = tags
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return membershipChanged, nil
+}
+
+// modifyLivenessRecord reads the liveness record of the given node from KV,
+// applies modify to it and durably writes the result. It retries, with the
+// backoff of liveness updates, if the record is concurrently updated (e.g. by a
+// heartbeat), and is a no-op if the record already reflects the modification.
+func (nl *NodeLiveness) modifyLivenessRecord(
+	ctx context.Context, nodeID roachpb.NodeID, modify func(l *livenesspb.Liveness) error,
+) error {
+	attempt := func() error {
+		sem := nl.sem(nodeID)
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		defer func() {
+			<-sem
+		}()
+
+		oldLivenessRec, err := nl.getLivenessRecordFromKV(ctx, nodeID)
+		if err != nil {
+			return err
+		}
+		newLiveness := oldLivenessRec.Liveness
+		if err := modify(&newLiveness); err != nil {
+			return err
+		}
+		if newLiveness.Equal(oldLivenessRec.Liveness) {
+			return nil
+		}
+		update := livenessUpdate{
+			newLiveness: newLiveness,
+			oldLiveness: oldLivenessRec.Liveness,
+			oldRaw:      oldLivenessRec.raw,
+		}
+		_, err = nl.updateLiveness(ctx, update, func(actual Record) error {
+			// NB: modify isn't applied to the actual record, since the callers
+			// capture its outputs: they must be those of the last attempt.
+			if actual.Liveness.Equal(newLiveness) {
+				// Someone else already wrote the same record.
+				return nil
+			}
+			// We're racing with another update of the liveness record (e.g.
+			// a heartbeat); retry. The retry is a no-op if the record already
+			// reflects the modification.
+			return errModifyLivenessRecordFailed
+		})
+		return err
+	}
+
+	var lastErr error
+	r := nl.startRetries(ctx)
+	for r.Next() {
+		err := attempt()
+		if errors.Is(err, errModifyLivenessRecordFailed) {
+			lastErr = err
+			continue
+		}
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if r.exhausted() {
+		return errors.Wrapf(lastErr, "giving up on modifying the liveness record of n%d after %d retries",
+			nodeID, r.CurrentAttempt())
+	}
+	return errors.New("retry loop ended without error - likely shutting down")
+}
+
 func (nl *NodeLiveness) setDrainingInternal(
-	ctx context.Context, oldLivenessRec Record, drain bool, reporter func(int, redact.SafeString),
+	ctx context.Context,
+	oldLivenessRec Record,
+	drain bool,
+	reporter func(int, redact.SafeString),
+	reason string,
 ) error {
 	sem := nl.selfSem
 	// Allow only one attempt to set the draining field at a time.
@@ -473,7 +1226,7 @@ func (nl *NodeLiveness) setDrainingInternal(
 		<-sem
 	}()
 
-	if oldLivenessRec.Liveness == (livenesspb.Liveness{}) {
+	if oldLivenessRec.Liveness.Equal(livenesspb.Liveness{}) {
 		return errors.AssertionFailedf("invalid old liveness record; found to be empty")
 	}
 
@@ -486,6 +1239,9 @@ func (nl *NodeLiveness) setDrainingInternal(
 		reporter(1, "liveness record")
 	}
 	newLiveness.Draining = drain
+	if reason != "" {
+		newLiveness.Reason = reason
+	}
 
 	update := livenessUpdate{
 		oldLiveness: oldLivenessRec.Liveness,
@@ -519,7 +1275,13 @@ func (nl *NodeLiveness) setDrainingInternal(
 func (nl *NodeLiveness) cacheUpdated(old livenesspb.Liveness, new livenesspb.Liveness) {
 	// TODO(baptist): This won't work correctly we remove expiration timestamp.
 	// Need to use a different signal to determine if liveness changed.
+	if old.NodeID != 0 && old.Expiration != new.Expiration {
+		nl.load.recordHeartbeat(new)
+	}
 	now := nl.clock.Now()
+	if old.IsLive(now) != new.IsLive(now) {
+		nl.batcher.invalidateAggregators()
+	}
 	if !old.IsLive(now) && new.IsLive(now) {
 		// NB: If we are not started, we don't use the onIsLive callbacks since they
 		// can still change. This is a bit of a tangled mess since the startup of
@@ -540,33 +1302,120 @@ func (nl *NodeLiveness) cacheUpdated(old livenesspb.Liveness, new livenesspb.Liv
 	}
 }
 
+// livenessGossiped is called with every liveness record received through
+// gossip, along with the record cached for the node until then. It measures how
+// long the heartbeats of other nodes take to reach this node, which includes
+// the clock offset between the nodes, and how many of them were superseded
+// before they did.
+func (nl *NodeLiveness) livenessGossiped(old, new livenesspb.Liveness) {
+	if new.NodeID == nl.cache.selfID() || new.HeartbeatTimestamp.IsEmpty() ||
+		new.HeartbeatSeq <= old.HeartbeatSeq {
+		// Not a heartbeat of another node that this node hasn't seen yet.
+		return
+	}
+	latency := nl.clock.Now().WallTime - new.HeartbeatTimestamp.WallTime
+	if latency < 0 {
+		latency = 0
+	}
+	nl.metrics.GossipPropagationLatency.RecordValue(latency)
+	if old.HeartbeatSeq != 0 {
+		nl.metrics.GossipSkippedHeartbeats.Inc(new.HeartbeatSeq - old.HeartbeatSeq - 1)
+	}
+}
+
 // CreateLivenessRecord creates a liveness record for the node specified by the
 // given node ID. This is typically used when adding a new node to a running
 // cluster, or when bootstrapping a cluster through a given node.
 //
-// This is a pared down version of Start; it exists only to durably
-// persist a liveness to record the node's existence. Nodes will heartbeat their
-// records after starting up, and incrementing to epoch=1 when doing so, at
-// which point we'll set an appropriate expiration timestamp, gossip the
-// liveness record, and update our in-memory representation of it.
+// This is a pared down version of Start; it exists to durably persist a
+// liveness to record the node's existence, and to make the node visible to the
+// allocator and to the liveness map before it first heartbeats. The record
+// starts off at epoch=0 with a provisional expiration one liveness TTL away,
+// and is gossiped right away. Such a provisional record isn't live (see
+// livenesspb.Liveness.Provisional). Nodes will heartbeat their records after
+// starting up, and incrementing to epoch=1 when doing so, at which point the
+// record is handed over to them; a node that doesn't come up before the
+// provisional expiration lapses failed to join, and is considered dead.
 //
 // NB: An existing liveness record is not overwritten by this method, we return
 // an error instead.
 func (nl *NodeLiveness) CreateLivenessRecord(ctx context.Context, nodeID roachpb.NodeID) error {
-	return nl.storage.create(ctx, nodeID)
+	rec, err := nl.storage.create(ctx, nl.provisionalLiveness(nodeID))
+	if err != nil {
+		return err
+	}
+	nl.cache.maybeUpdate(ctx, rec)
+	return nil
+}
+
+// AllocateNodeIDWithLivenessRecord allocates a new node ID, and creates its
+// liveness record as CreateLivenessRecord does. The ID is claimed by the
+// creation of the record, which ensures that a failure while a node joins the
+// cluster can't leave behind a node ID without a liveness record, which
+// decommissioning and the node tombstones rely on.
+func (nl *NodeLiveness) AllocateNodeIDWithLivenessRecord(
+	ctx context.Context,
+) (roachpb.NodeID, error) {
+	rec, err := nl.storage.allocateAndCreate(ctx, nl.provisionalLiveness(0 /* nodeID */))
+	if err != nil {
+		return 0, err
+	}
+	nl.cache.maybeUpdate(ctx, rec)
+	return rec.NodeID, nil
+}
+
+// provisionalLiveness returns the liveness record created for a node before it
+// first heartbeats: at epoch=0, with an expiration one liveness TTL away.
+func (nl *NodeLiveness) provisionalLiveness(nodeID roachpb.NodeID) livenesspb.Liveness {
+	return livenesspb.Liveness{
+		NodeID:     nodeID,
+		Epoch:      0,
+		Expiration: nl.clock.Now().Add(nl.livenessTTL().Nanoseconds(), 0).ToLegacyTimestamp(),
+	}
 }
 
 func (nl *NodeLiveness) setMembershipStatusInternal(
-	ctx context.Context, oldLivenessRec Record, targetStatus livenesspb.MembershipStatus,
+	ctx context.Context,
+	oldLivenessRec Record,
+	targetStatus livenesspb.MembershipStatus,
+	reason string,
+	token string,
 ) (statusChanged bool, err error) {
-	if valid, err := livenesspb.ValidateTransition(oldLivenessRec.Liveness, targetStatus); !valid {
+	if err := livenesspb.ValidateMembershipStatus(targetStatus); err != nil {
 		return false, err
 	}
 
 	// Let's compute what our new liveness record should be. We start off with a
 	// copy of our existing liveness record.
 	newLiveness := oldLivenessRec.Liveness
-	newLiveness.Membership = targetStatus
+	valid, err := nl.applyMembershipChange(ctx, &newLiveness, targetStatus, reason, token)
+	if err != nil {
+		return false, err
+	}
+	if !valid {
+		// The membership change is a no-op, save maybe for the extension of the
+		// lock of the change in flight.
+		if newLiveness.Equal(oldLivenessRec.Liveness) {
+			return false, nil
+		}
+		_, err := nl.updateLiveness(ctx, livenessUpdate{
+			newLiveness: newLiveness,
+			oldLiveness: oldLivenessRec.Liveness,
+			oldRaw:      oldLivenessRec.raw,
+		}, func(actual Record) error {
+			return errChangeMembershipStatusFailed
+		})
+		return false, err
+	}
+
+	if targetStatus.Decommissioned() && !newLiveness.DecommissionTrace.Empty() &&
+		nl.ambientCtx.Tracer != nil {
+		// Trace the final write as part of the decommission.
+		var sp *tracing.Span
+		ctx, sp = nl.ambientCtx.Tracer.StartSpanCtx(ctx, "liveness.mark-decommissioned",
+			tracing.WithRemoteParentFromTraceInfo(newLiveness.DecommissionTrace.TraceInfo()))
+		defer sp.Finish()
+	}
 
 	update := livenessUpdate{
 		newLiveness: newLiveness,
@@ -592,6 +1441,83 @@ func (nl *NodeLiveness) setMembershipStatusInternal(
 	return statusChanged, nil
 }
 
+// applyMembershipChange changes the membership status of the given liveness
+// record to targetStatus, along with the fields that go with it: the reason,
+// the idempotency token, the membership change lock and the decommission
+// trace. It returns whether the membership status is changed. A change that
+// isn't one may still extend the lock of the membership change in flight.
+func (nl *NodeLiveness) applyMembershipChange(
+	ctx context.Context,
+	l *livenesspb.Liveness,
+	targetStatus livenesspb.MembershipStatus,
+	reason string,
+	token string,
+) (statusChanged bool, err error) {
+	// A retry of a change that was already made, after which the membership
+	// status changed, mustn't undo the later change.
+	applied := livenesspb.AppliedMembershipChange{Token: token, Membership: targetStatus}
+	if token != "" && l.LastMembershipChange == applied && l.Membership != targetStatus {
+		log.VEventf(ctx, 2, "membership change of n%d to %s with token %q already applied",
+			l.NodeID, targetStatus, token)
+		return false, nil
+	}
+
+	// Refuse to race with a different membership change in flight. Membership
+	// changes other than the final step of a decommission take the lock, and
+	// hold it until it expires.
+	now := nl.clock.Now()
+	op := livenesspb.MembershipChangeOperation(targetStatus)
+	lockDuration := membershipChangeLockDuration.Get(&nl.st.SV)
+	oldLock := l.MembershipChangeLock
+	if lockDuration > 0 && oldLock.HeldAt(now) && oldLock.Operation != op {
+		return false, &MembershipChangeConflictError{NodeID: l.NodeID, Lock: oldLock}
+	}
+	var newLock livenesspb.MembershipChangeLock
+	if lockDuration > 0 && !targetStatus.Decommissioned() {
+		newLock = livenesspb.MembershipChangeLock{
+			Operation:    op,
+			HolderNodeID: nl.cache.selfID(),
+			Expiration:   now.AddDuration(lockDuration),
+		}
+	}
+
+	valid, err := livenesspb.ValidateTransition(*l, targetStatus)
+	if err != nil {
+		return false, err
+	}
+	if !valid {
+		// If the no-op repeats the change in flight, as the decommission
+		// command does while it waits for the node to be drained of its
+		// replicas, extend the lock once half of it has lapsed.
+		if newLock != (livenesspb.MembershipChangeLock{}) && l.Membership == targetStatus &&
+			!now.AddDuration(lockDuration/2).Less(oldLock.Expiration) {
+			l.MembershipChangeLock = newLock
+		}
+		return false, nil
+	}
+
+	l.MembershipChangeLock = newLock
+	l.Membership = targetStatus
+	l.Reason = reason
+	if token != "" {
+		l.LastMembershipChange = applied
+	}
+	// A membership change supersedes any scheduled decommission: either it is
+	// the scheduled decommission itself, or the operator changed their mind.
+	l.DecommissionAt = hlc.Timestamp{}
+	switch {
+	case targetStatus.Active():
+		l.DecommissionTrace = livenesspb.DecommissionTrace{}
+	case targetStatus.Decommissioning() && l.DecommissionTrace.Empty():
+		// Record the trace the decommission is initiated in, so that the work
+		// carried out on its behalf can be traced as part of it.
+		if sp := tracing.SpanFromContext(ctx); sp != nil && !sp.IsNoop() {
+			l.DecommissionTrace = livenesspb.MakeDecommissionTrace(sp.Meta().ToProto())
+		}
+	}
+	return true, nil
+}
+
 // GetLivenessThreshold returns the maximum duration between heartbeats
 // before a node is considered not-live.
 func (nl *NodeLiveness) GetLivenessThreshold() time.Duration {
@@ -624,6 +1550,14 @@ func (nl *NodeLiveness) IsAvailable(nodeID roachpb.NodeID) bool {
 	return ok && liveness.IsLive(nl.clock.Now()) && !liveness.Membership.Decommissioned()
 }
 
+// IsUnderMemoryPressure returns whether the specified node is live and
+// reported being under memory pressure when it last heartbeated its liveness
+// record. Returns false if the node is not in the local liveness table.
+func (nl *NodeLiveness) IsUnderMemoryPressure(nodeID roachpb.NodeID) bool {
+	liveness, ok := nl.GetLiveness(nodeID)
+	return ok && liveness.IsLive(nl.clock.Now()) && liveness.MemoryPressure
+}
+
 // IsAvailableNotDraining returns whether or not the specified node is available
 // to serve requests (i.e. it is live and not decommissioned) and is not in the
 // process of draining/decommissioning. Note that draining/decommissioning nodes
@@ -636,43 +1570,37 @@ func (nl *NodeLiveness) IsAvailableNotDraining(nodeID roachpb.NodeID) bool {
 		liveness.IsLive(nl.clock.Now()) &&
 		!liveness.Membership.Decommissioning() &&
 		!liveness.Membership.Decommissioned() &&
-		!liveness.Draining
+		!liveness.Draining &&
+		!liveness.InMaintenance(nl.clock.Now())
+}
+
+// IsEligibleTarget returns whether the specified node is an eligible target
+// for the given intent, given the duration for which a node that came back
+// from being unavailable remains suspect. See IsLiveMapEntry.IsEligibleTarget.
+// Returns false if the node is not in the local liveness table.
+func (nl *NodeLiveness) IsEligibleTarget(
+	nodeID roachpb.NodeID, intent livenesspb.TargetIntent, suspectDuration time.Duration,
+) bool {
+	liveness, ok := nl.GetLiveness(nodeID)
+	if !ok {
+		return false
+	}
+	now := nl.clock.Now()
+	entry := livenesspb.IsLiveMapEntry{Liveness: liveness.Liveness, IsLive: liveness.IsLive(now)}
+	return entry.IsEligibleTarget(intent, now, suspectDuration)
 }
 
 // OnNodeDecommissionCallback is a callback that is invoked when a node is
 // detected to be decommissioning.
 type OnNodeDecommissionCallback func(nodeID roachpb.NodeID)
 
-// Start starts a periodic heartbeat to refresh this node's last
-// heartbeat in the node liveness table. The optionally provided
-// HeartbeatCallback will be invoked whenever this node updates its
-// own liveness. The slice of engines will be written to before each
-// heartbeat to avoid maintaining liveness in the presence of disk stalls.
-// TODO(baptist): If we completely remove epoch leases, this can be merged with
-// the NewNodeLiveness function. Currently the liveness is required prior to
-// Start getting called in replica_range_lease. For non-epoch leases this should
-// be possible.
-func (nl *NodeLiveness) Start(ctx context.Context) {
-	log.VEventf(ctx, 1, "starting node liveness instance")
-	if nl.started.Get() {
-		// This is meant to prevent tests from calling start twice.
-		log.Fatal(ctx, "liveness already started")
-	}
-
-	retryOpts := base.DefaultRetryOptions()
-	retryOpts.Closer = nl.stopper.ShouldQuiesce()
-
-	nl.started.Set(true)
-	// We may have received some liveness records from Gossip prior to Start being
-	// called. We need to go through and notify all the callers of them now.
-	for _, entry := range nl.GetIsLiveMap() {
-		if entry.IsLive {
-			for _, fn := range nl.onIsLive {
-				fn(entry.Liveness)
-			}
-		}
-	}
-
+// startHeartbeatLoop starts the loop heartbeating the liveness record of the
+// local node. The loop exits once the watchdog replaces it with a loop of a
+// newer generation (see checkHeartbeatLoop). incrementEpoch is set if the
+// local node has yet to heartbeat since it started.
+func (nl *NodeLiveness) startHeartbeatLoop(
+	ctx context.Context, generation int64, incrementEpoch bool,
+) {
 	_ = nl.stopper.RunAsyncTaskEx(ctx, stop.TaskOpts{TaskName: "liveness-hb", SpanOpt: stop.SterileRootSpan}, func(context.Context) {
 		ambient := nl.ambientCtx
 		ambient.AddLogTag("liveness-hb", nil)
@@ -681,9 +1609,7 @@ func (nl *NodeLiveness) Start(ctx context.Context) {
 		ctx, sp := ambient.AnnotateCtxWithSpan(ctx, "liveness heartbeat loop")
 		defer sp.Finish()
 
-		incrementEpoch := true
-		heartbeatInterval := nl.livenessThreshold - nl.renewalDuration
-		ticker := time.NewTicker(heartbeatInterval)
+		ticker := time.NewTicker(nl.heartbeatInterval())
 		defer ticker.Stop()
 		for {
 			select {
@@ -691,12 +1617,22 @@ func (nl *NodeLiveness) Start(ctx context.Context) {
 			case <-nl.stopper.ShouldQuiesce():
 				return
 			}
+			// Record the attempt, so that the watchdog can tell if it wedges. The
+			// attempt is traced so that its progress can be dumped if it does. The
+			// recording is only a structured one: a verbose recording would be
+			// propagated to the liveness range on every heartbeat.
+			attemptCtx, attemptSp := tracing.EnsureChildSpan(ctx, nl.ambientCtx.Tracer,
+				"liveness heartbeat attempt", tracing.WithRecording(tracingpb.RecordingStructured))
+			attemptCtx, cancelAttempt := context.WithCancel(attemptCtx)
+			nl.watchdog.beginAttempt(generation, attemptSp, cancelAttempt)
 			// Give the context a timeout approximately as long as the time we
 			// have left before our liveness entry expires.
-			if err := timeutil.RunWithTimeout(ctx, "node liveness heartbeat", nl.renewalDuration,
+			if err := timeutil.RunWithTimeout(attemptCtx, "node liveness heartbeat", nl.renewalWindow(),
 				func(ctx context.Context) error {
 					// Retry heartbeat in the event the conditional put fails.
-					for r := retry.StartWithCtx(ctx, retryOpts); r.Next(); {
+					var lastErr error
+					r := nl.startRetries(ctx)
+					for r.Next() {
 						oldLiveness, ok := nl.Self()
 						if !ok {
 							nodeID := nl.cache.selfID()
@@ -706,33 +1642,147 @@ func (nl *NodeLiveness) Start(ctx context.Context) {
 								if grpcutil.IsConnectionRejected(err) {
 									return err
 								}
+								lastErr = err
 								continue
 							}
 							oldLiveness = liveness.Liveness
 						}
-						if err := nl.heartbeatInternal(ctx, oldLiveness, incrementEpoch); err != nil {
+						// A node restarting shortly after its last heartbeat may keep
+						// its epoch, and with it its epoch-based leases.
+						increment := incrementEpoch && !nl.canResumeOwnEpoch(oldLiveness)
+						if err := nl.heartbeatInternal(ctx, oldLiveness, increment); err != nil {
 							if errors.Is(err, ErrEpochIncremented) {
 								log.Infof(ctx, "%s; retrying", err)
+								lastErr = err
 								continue
 							}
 							return err
 						}
+						if incrementEpoch && !increment {
+							log.Infof(ctx, "resumed liveness epoch %d after restart", oldLiveness.Epoch)
+						}
 						incrementEpoch = false // don't increment epoch after first heartbeat
-						break
+						return nil
+					}
+					if r.exhausted() {
+						return errors.Wrapf(lastErr, "giving up after %d retries", r.CurrentAttempt())
 					}
 					return nil
 				}); err != nil {
 				log.Warningf(ctx, heartbeatFailureLogFormat, err)
 			}
+			superseded := nl.watchdog.endAttempt(generation, !incrementEpoch)
+			cancelAttempt()
+			attemptSp.Finish()
+			if superseded {
+				// The watchdog gave up on this loop and started another one, which
+				// owns the heartbeat token now.
+				log.Infof(ctx, "exiting heartbeat loop replaced by the watchdog")
+				return
+			}
 
 			nl.heartbeatToken <- struct{}{}
+			// The interval is longer while the liveness range is hot.
+			ticker.Reset(nl.heartbeatInterval())
+			select {
+			case <-ticker.C:
+			case <-nl.stopper.ShouldQuiesce():
+				return
+			}
+		}
+	})
+}
+
+// Start starts a periodic heartbeat to refresh this node's last
+// heartbeat in the node liveness table. The optionally provided
+// HeartbeatCallback will be invoked whenever this node updates its
+// own liveness. The slice of engines will be written to before each
+// heartbeat to avoid maintaining liveness in the presence of disk stalls.
+// TODO(baptist): If we completely remove epoch leases, this can be merged with
+// the NewNodeLiveness function. Currently the liveness is required prior to
+// Start getting called in replica_range_lease. For non-epoch leases this should
+// be possible.
+func (nl *NodeLiveness) Start(ctx context.Context) {
+	log.VEventf(ctx, 1, "starting node liveness instance")
+	if nl.started.Get() {
+		// This is meant to prevent tests from calling start twice.
+		log.Fatal(ctx, "liveness already started")
+	}
+
+	nl.started.Set(true)
+	// We may have received some liveness records from Gossip or from KV prior to
+	// Start being called. We need to go through and notify all the callers of
+	// them now.
+	for _, entry := range nl.GetIsLiveMap() {
+		if entry.IsLive {
+			for _, fn := range nl.onIsLive {
+				fn(entry.Liveness)
+			}
+		}
+	}
+
+	nl.startHeartbeatLoop(ctx, 0 /* generation */, true /* incrementEpoch */)
+
+	// Warm up the cache in the background, so as not to delay the first
+	// heartbeat. The records it reads notify the callers as they are cached.
+	_ = nl.stopper.RunAsyncTaskEx(ctx, stop.TaskOpts{TaskName: "liveness-warm-up", SpanOpt: stop.SterileRootSpan}, func(context.Context) {
+		ambient := nl.ambientCtx
+		ambient.AddLogTag("liveness-warm-up", nil)
+		ctx, cancel := nl.stopper.WithCancelOnQuiesce(context.Background())
+		defer cancel()
+		nl.warmUpCache(ambient.AnnotateCtx(ctx))
+	})
+
+	_ = nl.stopper.RunAsyncTaskEx(ctx, stop.TaskOpts{TaskName: "liveness-hb-watchdog", SpanOpt: stop.SterileRootSpan}, func(context.Context) {
+		ambient := nl.ambientCtx
+		ambient.AddLogTag("liveness-hb-watchdog", nil)
+		ctx, cancel := nl.stopper.WithCancelOnQuiesce(context.Background())
+		defer cancel()
+		nl.runHeartbeatWatchdog(ambient.AnnotateCtx(ctx))
+	})
+
+	_ = nl.stopper.RunAsyncTaskEx(ctx, stop.TaskOpts{TaskName: "liveness-load", SpanOpt: stop.SterileRootSpan}, func(context.Context) {
+		ambient := nl.ambientCtx
+		ambient.AddLogTag("liveness-load", nil)
+		ctx := ambient.AnnotateCtx(context.Background())
+		ticker := time.NewTicker(loadSampleInterval)
+		defer ticker.Stop()
+		for {
 			select {
 			case <-ticker.C:
+				nl.sampleLoad(ctx)
 			case <-nl.stopper.ShouldQuiesce():
 				return
 			}
 		}
 	})
+
+	if nl.swim != nil {
+		_ = nl.stopper.RunAsyncTaskEx(ctx, stop.TaskOpts{TaskName: "liveness-swim", SpanOpt: stop.SterileRootSpan}, func(context.Context) {
+			ambient := nl.ambientCtx
+			ambient.AddLogTag("liveness-swim", nil)
+			ctx, cancel := nl.stopper.WithCancelOnQuiesce(context.Background())
+			defer cancel()
+			nl.swim.run(ambient.AnnotateCtx(ctx))
+		})
+	}
+
+	nl.runExternalProbes(ctx, nl.externalProbes)
+}
+
+// swimMembers returns the nodes probed by the SWIM failure detector: all nodes
+// with a liveness record that haven't been decommissioned, except for the
+// local node.
+func (nl *NodeLiveness) swimMembers() []roachpb.NodeID {
+	self := nl.cache.selfID()
+	var members []roachpb.NodeID
+	for _, l := range nl.cache.getAllLivenesses() {
+		if l.NodeID == self || l.Membership.Decommissioned() {
+			continue
+		}
+		members = append(members, l.NodeID)
+	}
+	return members
 }
 
 const heartbeatFailureLogFormat = `failed node liveness heartbeat: %v
@@ -787,6 +1837,204 @@ func (nl *NodeLiveness) PauseAllHeartbeatsForTest() func() {
 	}
 }
 
+// MaxHeartbeatPause is the longest PauseHeartbeats pauses heartbeats for.
+const MaxHeartbeatPause = 10 * time.Minute
+
+// errHeartbeatsPaused is returned when attempting to heartbeat the local node
+// while its heartbeats are paused through PauseHeartbeats.
+var errHeartbeatsPaused = errors.New("heartbeats paused")
+
+// PauseHeartbeats pauses the heartbeats of the local node for the given
+// duration, capped at MaxHeartbeatPause, after which they resume on their own.
+// This lets the handling of a node failing to heartbeat be exercised on a
+// running cluster. A non-positive duration resumes heartbeats right away.
+// Returns the time until which heartbeats are paused.
+func (nl *NodeLiveness) PauseHeartbeats(ctx context.Context, d time.Duration) time.Time {
+	if d > MaxHeartbeatPause {
+		d = MaxHeartbeatPause
+	}
+	var until time.Time
+	if d > 0 {
+		until = timeutil.Now().Add(d)
+		atomic.StoreInt64(&nl.heartbeatsPausedUntil, until.UnixNano())
+		log.Ops.Warningf(ctx, "pausing liveness heartbeats until %s", until)
+	} else {
+		atomic.StoreInt64(&nl.heartbeatsPausedUntil, 0)
+		log.Ops.Infof(ctx, "resuming liveness heartbeats")
+	}
+	return until
+}
+
+// HeartbeatsFailing returns whether the last heartbeat attempt of the local
+// node failed. The node may still be live, but is at risk of losing its
+// liveness, and with it its epoch-based leases.
+func (nl *NodeLiveness) HeartbeatsFailing() bool {
+	return nl.HeartbeatFailures().Consecutive > 0
+}
+
+// leaseBackpressureHeartbeatFailures is the number of consecutive failed
+// heartbeat attempts past which the local node is considered likely to lose its
+// liveness, regardless of the expiration of its record. See
+// LikelyToLoseLiveness.
+const leaseBackpressureHeartbeatFailures = 3
+
+// LikelyToLoseLiveness returns whether the heartbeats of the local node fail
+// badly enough for it to be likely to lose its liveness: they failed
+// leaseBackpressureHeartbeatFailures times in a row, or the last one failed and
+// the record expires within half of the renewal window. A single failed
+// heartbeat with plenty of time left to retry doesn't qualify.
+func (nl *NodeLiveness) LikelyToLoseLiveness() bool {
+	failures := nl.HeartbeatFailures().Consecutive
+	if failures == 0 {
+		return false
+	}
+	if failures >= leaseBackpressureHeartbeatFailures {
+		return true
+	}
+	l, ok := nl.Self()
+	if !ok {
+		return false
+	}
+	return l.Expiration.ToTimestamp().Less(nl.clock.Now().AddDuration(nl.renewalWindow() / 2))
+}
+
+// HeartbeatFailures returns the failed heartbeat attempts of the local node.
+func (nl *NodeLiveness) HeartbeatFailures() livenesspb.HeartbeatFailures {
+	nl.heartbeatFailures.Lock()
+	defer nl.heartbeatFailures.Unlock()
+	return nl.heartbeatFailures.HeartbeatFailures
+}
+
+// maxHeartbeatFailureErrorLen bounds the length of the error of a failed
+// heartbeat attempt that is recorded in the liveness record.
+const maxHeartbeatFailureErrorLen = 256
+
+// recordHeartbeatAttempt records the outcome of a heartbeat attempt of the
+// local node. A successful attempt resets the count of consecutive failures,
+// but keeps the last error around for troubleshooting.
+func (nl *NodeLiveness) recordHeartbeatAttempt(err error) {
+	nl.heartbeatFailures.Lock()
+	defer nl.heartbeatFailures.Unlock()
+	if err == nil {
+		nl.heartbeatFailures.Consecutive = 0
+		return
+	}
+	msg := err.Error()
+	if len(msg) > maxHeartbeatFailureErrorLen {
+		msg = msg[:maxHeartbeatFailureErrorLen]
+	}
+	nl.heartbeatFailures.Consecutive++
+	nl.heartbeatFailures.LastError = msg
+	nl.heartbeatFailures.LastFailure = nl.clock.Now()
+}
+
+// heartbeatRecord returns the record a heartbeat of the local node at the
+// given time replaces its record old with. Whatever the node reports about
+// itself goes in its livenesspb.HeartbeatTelemetry, so that the heartbeats that
+// only extend the expiration of the record and refresh it are renewals, which
+// may be batched (see livenesspb.IsRenewal).
+func (nl *NodeLiveness) heartbeatRecord(
+	ctx context.Context,
+	old livenesspb.Liveness,
+	now hlc.Timestamp,
+	ttl time.Duration,
+	incrementEpoch bool,
+) (livenesspb.Liveness, error) {
+	newLiveness := old
+	if incrementEpoch {
+		newLiveness.Epoch++
+		// Clear the draining field, unless the node was drained with a sticky
+		// drain.
+		newLiveness.Draining = newLiveness.StickyDrain
+		// The node is back, so a planned restart it declared is over.
+		newLiveness.PlannedRestartUntil = hlc.Timestamp{}
+	}
+
+	// Record that the node comes back after its record expired, which makes it
+	// suspect for a while. The first heartbeat of a new node doesn't count.
+	if incrementEpoch && old.Epoch > 0 && !old.IsLive(now) {
+		newLiveness.LastUnavailable = now
+	}
+	newLiveness.Expiration = now.Add(ttl.Nanoseconds(), 0).ToLegacyTimestamp()
+	// Record the TTL, which differs across nodes, e.g. for cold nodes, so that
+	// the time of the heartbeat can be derived from the expiration.
+	newLiveness.TTLNanos = ttl.Nanoseconds()
+	// This guards against the system clock moving backwards. As long
+	// as the cockroach process is running, checks inside hlc.Clock
+	// will ensure that the clock never moves backwards, but these
+	// checks don't work across process restarts.
+	if newLiveness.Expiration.Less(old.Expiration) {
+		return livenesspb.Liveness{}, errors.Errorf("proposed liveness update expires earlier than previous record")
+	}
+	// Record the versions the node is running, and what else it reports about
+	// itself.
+	telemetry := old.HeartbeatTelemetry()
+	telemetry.BinaryVersion = nl.st.Version.BinaryVersion()
+	telemetry.ActiveVersion = nl.st.Version.ActiveVersionOrEmpty(ctx).Version
+	if nl.maxClockOffset != nil {
+		telemetry.MaxClockOffsetNanos = nl.maxClockOffset().Nanoseconds()
+	}
+	if nl.underMemoryPressure != nil {
+		telemetry.MemoryPressure = nl.underMemoryPressure(memoryPressureThreshold.Get(&nl.st.SV))
+	}
+	if nl.overloaded != nil {
+		cpuThreshold := shedLeasesCPUThreshold.Get(&nl.st.SV)
+		runnableThreshold := shedLeasesRunnableGoroutinesThreshold.Get(&nl.st.SV)
+		if old.ShedLeases {
+			cpuThreshold *= shedLeasesHysteresis
+			runnableThreshold *= shedLeasesHysteresis
+		}
+		newLiveness.ShedLeases = nl.overloaded(cpuThreshold, runnableThreshold)
+	}
+	newLiveness.Departing = nl.departing.Get()
+	// Record the attempts that failed before this one, so that other nodes can
+	// tell that the node struggles to heartbeat before its record expires.
+	telemetry.HeartbeatFailures = nl.HeartbeatFailures()
+	telemetry.StoreDigests = nil
+	if nl.storeDigests != nil && kvserverbase.LivenessStoreDigestsEnabled.Get(&nl.st.SV) {
+		telemetry.StoreDigests = nl.storeDigests(ctx)
+		for i := range telemetry.StoreDigests {
+			telemetry.StoreDigests[i].Timestamp = now
+		}
+	}
+	telemetry.HeartbeatTimestamp = now
+	telemetry.HeartbeatSeq++
+	telemetry.HeartbeatLatencyNanos = nl.load.load().MeanHeartbeatLatency.Nanoseconds()
+	newLiveness.SetHeartbeatTelemetry(telemetry)
+	// Clear a maintenance window that has lapsed. The window has no effect
+	// past its end anyway, but we don't want it to linger in the record.
+	if newLiveness.MaintenanceExpired(now) {
+		newLiveness.MaintenanceStart = hlc.Timestamp{}
+		newLiveness.MaintenanceEnd = hlc.Timestamp{}
+	}
+	// Likewise for a planned restart the node wasn't back from in time.
+	if !newLiveness.AwaitingRestart(now) {
+		newLiveness.PlannedRestartUntil = hlc.Timestamp{}
+	}
+	// And for a lapsed time until dead override.
+	if !now.Less(newLiveness.TimeUntilDeadOverride.Expiration) {
+		newLiveness.TimeUntilDeadOverride = livenesspb.TimeUntilDeadOverride{}
+	}
+	// A node that heartbeats isn't gone, whatever an external probe reported.
+	if newLiveness.ExternalFailure.Reported() {
+		log.Ops.Warningf(ctx, "clearing the report of probe %s that the local node is gone: %s",
+			newLiveness.ExternalFailure.Probe, newLiveness.ExternalFailure.Reason)
+		newLiveness.ExternalFailure = livenesspb.ExternalFailure{}
+	}
+	return newLiveness, nil
+}
+
+// HeartbeatsPausedUntil returns the time until which the heartbeats of the
+// local node are paused, if they are.
+func (nl *NodeLiveness) HeartbeatsPausedUntil() (time.Time, bool) {
+	nanos := atomic.LoadInt64(&nl.heartbeatsPausedUntil)
+	if nanos == 0 {
+		return time.Time{}, false
+	}
+	until := timeutil.Unix(0, nanos)
+	return until, timeutil.Now().Before(until)
+}
+
 var errNodeAlreadyLive = errors.New("node already live")
 
 // Heartbeat is called to update a node's expiration timestamp. This
@@ -814,11 +2062,23 @@ func (nl *NodeLiveness) Heartbeat(ctx context.Context, liveness livenesspb.Liven
 func (nl *NodeLiveness) heartbeatInternal(
 	ctx context.Context, oldLiveness livenesspb.Liveness, incrementEpoch bool,
 ) (err error) {
+	if until, paused := nl.HeartbeatsPausedUntil(); paused {
+		return errors.Wrapf(errHeartbeatsPaused, "until %s", until)
+	}
+	defer func() {
+		nl.recordHeartbeatAttempt(err)
+	}()
+	// A node that can write its liveness record but can't apply anything
+	// shouldn't claim to be live, so that its leases move elsewhere.
+	if err := nl.verifyApplicationProgress(); err != nil {
+		return err
+	}
 	ctx, sp := tracing.EnsureChildSpan(ctx, nl.ambientCtx.Tracer, "liveness heartbeat")
 	defer sp.Finish()
 	defer func(start time.Time) {
 		dur := timeutil.Since(start)
 		nl.metrics.HeartbeatLatency.RecordValue(dur.Nanoseconds())
+		nl.load.recordLatency(dur)
 		if dur > time.Second {
 			log.Warningf(ctx, "slow heartbeat took %s; err=%v", dur, err)
 		}
@@ -835,7 +2095,8 @@ func (nl *NodeLiveness) heartbeatInternal(
 	// [*]: see TODO below about how errNodeAlreadyLive handling does not
 	//      enforce this guarantee.
 	beforeQueueTS := nl.clock.Now()
-	minExpiration := beforeQueueTS.Add(nl.livenessThreshold.Nanoseconds(), 0).ToLegacyTimestamp()
+	ttl := nl.livenessTTL()
+	minExpiration := beforeQueueTS.Add(ttl.Nanoseconds(), 0).ToLegacyTimestamp()
 
 	// Before queueing, record the heartbeat as in-flight.
 	nl.metrics.HeartbeatsInFlight.Inc(1)
@@ -866,28 +2127,16 @@ func (nl *NodeLiveness) heartbeatInternal(
 		}
 	}
 
-	if oldLiveness == (livenesspb.Liveness{}) {
+	if oldLiveness.Equal(livenesspb.Liveness{}) {
 		return errors.AssertionFailedf("invalid old liveness record; found to be empty")
 	}
 
-	// Let's compute what our new liveness record should be. Start off with our
-	// existing view of things.
-	newLiveness := oldLiveness
-	if incrementEpoch {
-		newLiveness.Epoch++
-		newLiveness.Draining = false // clear draining field
-	}
-
 	// Grab a new clock reading to compute the new expiration time,
 	// since we may have queued on the semaphore for a while.
 	afterQueueTS := nl.clock.Now()
-	newLiveness.Expiration = afterQueueTS.Add(nl.livenessThreshold.Nanoseconds(), 0).ToLegacyTimestamp()
-	// This guards against the system clock moving backwards. As long
-	// as the cockroach process is running, checks inside hlc.Clock
-	// will ensure that the clock never moves backwards, but these
-	// checks don't work across process restarts.
-	if newLiveness.Expiration.Less(oldLiveness.Expiration) {
-		return errors.Errorf("proposed liveness update expires earlier than previous record")
+	newLiveness, err := nl.heartbeatRecord(ctx, oldLiveness, afterQueueTS, ttl, incrementEpoch)
+	if err != nil {
+		return err
 	}
 
 	update := livenessUpdate{
@@ -939,6 +2188,15 @@ func (nl *NodeLiveness) heartbeatInternal(
 	log.VEventf(ctx, 1, "heartbeat %+v", written.Expiration)
 	nl.cache.maybeUpdate(ctx, written)
 	nl.metrics.HeartbeatSuccesses.Inc(1)
+	// Record how much time was left on the record when it got renewed. The
+	// record of a node that is starting up has no expiration to measure.
+	if oldLiveness.Expiration.WallTime != 0 {
+		margin := oldLiveness.Expiration.WallTime - nl.clock.Now().WallTime
+		if margin < 0 {
+			margin = 0
+		}
+		nl.metrics.RenewalMargin.RecordValue(margin)
+	}
 	return nil
 }
 
@@ -956,9 +2214,17 @@ func (nl *NodeLiveness) Self() (_ livenesspb.Liveness, ok bool) {
 
 // GetIsLiveMap returns a map of nodeID to boolean liveness status of
 // each node. This excludes nodes that were removed completely (dead +
-// decommissioning).
+// decommissioning). The entries also carry the status derived with
+// TimeUntilStoreDead, which tells apart dead and decommissioned nodes.
 func (nl *NodeLiveness) GetIsLiveMap() livenesspb.IsLiveMap {
-	return nl.cache.GetIsLiveMap()
+	return nl.cache.GetIsLiveMap(TimeUntilStoreDead.Get(&nl.st.SV))
+}
+
+// GetLivenessView returns a snapshot of the liveness of the nodes, as in
+// GetIsLiveMap, along with the time and dead threshold it was taken with, so
+// that the status of the nodes can be derived from it later on.
+func (nl *NodeLiveness) GetLivenessView() livenesspb.LivenessView {
+	return nl.cache.GetLivenessView(TimeUntilStoreDead.Get(&nl.st.SV))
 }
 
 // GetLivenessesFromKV returns a slice containing the liveness record of all
@@ -978,6 +2244,138 @@ func (nl *NodeLiveness) GetLivenessesFromKV(ctx context.Context) ([]livenesspb.L
 	return livenesses, nil
 }
 
+// EncodedLivenessesFromKV is like GetLivenessesFromKV, but returns the records
+// in their encoded form (the tag and data bytes of their roachpb.Value), e.g.
+// to hand them over to a joining node. See SeedCache.
+func (nl *NodeLiveness) EncodedLivenessesFromKV(ctx context.Context) ([][]byte, error) {
+	records, err := nl.storage.scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	raws := make([][]byte, len(records))
+	for i, r := range records {
+		raws[i] = r.raw
+		nl.cache.maybeUpdate(ctx, r)
+	}
+	return raws, nil
+}
+
+// SeedCache populates the cache with the given encoded liveness records, as
+// returned by EncodedLivenessesFromKV, e.g. on another node. Records that
+// can't be decoded are skipped; newer records from gossip or KV supersede the
+// others as usual.
+func (nl *NodeLiveness) SeedCache(ctx context.Context, raws [][]byte) {
+	for _, raw := range raws {
+		r, err := nl.storage.decodeRecord(ctx, raw)
+		if err != nil {
+			log.Warningf(ctx, "skipping liveness record: %v", err)
+			continue
+		}
+		if r.Liveness.Equal(livenesspb.Liveness{}) {
+			continue
+		}
+		nl.cache.maybeUpdate(ctx, r)
+	}
+	if len(raws) > 0 {
+		log.VEventf(ctx, 1, "seeded the liveness cache with %d records", len(raws))
+	}
+}
+
+// cacheWarmUpTimeout bounds the time spent on the scan that warms up the
+// liveness cache at startup.
+const cacheWarmUpTimeout = 5 * time.Second
+
+// warmUpCache populates the cache with the liveness records of all nodes, read
+// from KV. Gossip may take a few seconds to deliver them after the node
+// starts, and until then decisions about leases and replica placement would be
+// made against an empty or partial map. If the records can't be read promptly,
+// the node relies on gossip alone.
+func (nl *NodeLiveness) warmUpCache(ctx context.Context) {
+	var n int
+	if err := timeutil.RunWithTimeout(ctx, "warm up liveness cache", cacheWarmUpTimeout,
+		func(ctx context.Context) error {
+			livenesses, err := nl.GetLivenessesFromKV(ctx)
+			n = len(livenesses)
+			return err
+		}); err != nil {
+		log.Warningf(ctx, "unable to warm up the liveness cache from KV: %v", err)
+		return
+	}
+	log.VEventf(ctx, 1, "warmed up the liveness cache with %d records", n)
+}
+
+// GetLivenessesFromKVAsOf is like GetLivenessesFromKV, but returns the liveness
+// records as they were at the given (past) timestamp. This can be used to
+// reconstruct the cluster's view of liveness at some point in time, as long as
+// that point is within the GC TTL of the liveness range. Unlike
+// GetLivenessesFromKV, the in-memory cache is not updated.
+func (nl *NodeLiveness) GetLivenessesFromKVAsOf(
+	ctx context.Context, ts hlc.Timestamp,
+) ([]livenesspb.Liveness, error) {
+	records, err := nl.storage.scanAsOf(ctx, ts)
+	if err != nil {
+		return nil, err
+	}
+	livenesses := make([]livenesspb.Liveness, len(records))
+	for i, r := range records {
+		livenesses[i] = r.Liveness
+	}
+	return livenesses, nil
+}
+
+// NodesBelowBinaryVersion returns the nodes that last heartbeated their
+// liveness records with a binary older than the given version, as read from KV.
+// This includes nodes that are currently down, as well as nodes whose binaries
+// predate the recording of the binary version; decommissioned nodes are
+// excluded. The in-memory cache is not updated.
+func (nl *NodeLiveness) NodesBelowBinaryVersion(
+	ctx context.Context, v roachpb.Version,
+) ([]roachpb.NodeID, error) {
+	records, err := nl.storage.scan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var nodeIDs []roachpb.NodeID
+	for _, r := range records {
+		if r.Membership.Decommissioned() || !r.BinaryVersion.Less(v) {
+			continue
+		}
+		nodeIDs = append(nodeIDs, r.NodeID)
+	}
+	return nodeIDs, nil
+}
+
+// LastHeartbeatFromKV returns the approximate time at which the given node last
+// heartbeat its liveness record, as read from KV. It is derived from the
+// record's expiration and the node's TTL (see livenesspb.Liveness.TTL). An
+// empty timestamp is returned if the node has no liveness record. The
+// in-memory cache is not updated.
+func (nl *NodeLiveness) LastHeartbeatFromKV(
+	ctx context.Context, nodeID roachpb.NodeID,
+) (hlc.Timestamp, error) {
+	rec, err := nl.storage.get(ctx, nodeID)
+	if err != nil {
+		if errors.Is(err, ErrMissingRecord) {
+			return hlc.Timestamp{}, nil
+		}
+		return hlc.Timestamp{}, err
+	}
+	return rec.Expiration.ToTimestamp().AddDuration(-rec.TTL(nl.livenessThreshold)), nil
+}
+
+// GetLivenessFromKV returns the liveness record of the given node as read from
+// KV. Unlike getLivenessRecordFromKV, the in-memory cache is not updated, so
+// that the record can be compared with the cached one.
+func (nl *NodeLiveness) GetLivenessFromKV(
+	ctx context.Context, nodeID roachpb.NodeID,
+) (livenesspb.Liveness, error) {
+	rec, err := nl.storage.get(ctx, nodeID)
+	if err != nil {
+		return livenesspb.Liveness{}, err
+	}
+	return rec.Liveness, nil
+}
+
 // GetLiveness returns the liveness record for the specified nodeID. If the
 // liveness record is not found (due to gossip propagation delays or due to the
 // node not existing), we surface that to the caller. The record returned also
@@ -987,6 +2385,20 @@ func (nl *NodeLiveness) GetLiveness(nodeID roachpb.NodeID) (_ Record, ok bool) {
 	return nl.cache.GetLiveness(nodeID)
 }
 
+// IsDead returns whether the given node is dead, i.e. whether its cached
+// liveness record expired more than server.time_until_store_dead ago. The
+// verdict is cached until the liveness record of the node is updated, so that
+// hot paths that keep looking up long-dead nodes, such as the GC of their
+// replicas, don't recompute it. A node without a cached record isn't
+// considered dead.
+func (nl *NodeLiveness) IsDead(nodeID roachpb.NodeID) bool {
+	dead, cached := nl.cache.isDead(nodeID, TimeUntilStoreDead.Get(&nl.st.SV))
+	if cached {
+		nl.metrics.DeadVerdictCacheHits.Inc(1)
+	}
+	return dead
+}
+
 // getLivenessRecordFromKV fetches the liveness record from KV for a given node,
 // and updates the internal in-memory cache when doing so. It returns a Record
 // with the encoded value that the database has for this liveness record in
@@ -1028,6 +2440,64 @@ func (nl *NodeLiveness) getLivenessRecordFromKV(
 // another successful heartbeat, and a second increment to come in
 // after that)
 func (nl *NodeLiveness) IncrementEpoch(ctx context.Context, liveness livenesspb.Liveness) error {
+	return nl.IncrementEpochOf(ctx, EpochIncrementRequest{
+		Observed: liveness,
+		Now:      nl.clock.NowAsClockTimestamp(),
+		Reason:   EpochIncrementReasonExpired,
+	})
+}
+
+// EpochIncrementReasonExpired is the reason recorded for epoch increments
+// that are only motivated by the node's record having expired.
+const EpochIncrementReasonExpired = "expired record"
+
+// EpochIncrementReasonLeaseAcquisition returns the reason recorded for an
+// epoch increment carried out to acquire the lease of the given range.
+func EpochIncrementReasonLeaseAcquisition(rangeID roachpb.RangeID) string {
+	return fmt.Sprintf("lease acquisition on r%d", rangeID)
+}
+
+// EpochIncrementRequest is a request to increment the epoch of another node.
+// See IncrementEpochOf.
+type EpochIncrementRequest struct {
+	// Observed is the liveness record of the node, exactly as the caller
+	// observed it. The epoch is only incremented if the record is unchanged.
+	Observed livenesspb.Liveness
+	// Now is the time, as read from this node's clock, at which the caller
+	// found the record expired, e.g. the time a lease status was evaluated at.
+	// The record must be expired at that time.
+	Now hlc.ClockTimestamp
+	// Reason is why the epoch is incremented. It is required.
+	Reason string
+}
+
+// IncrementEpochOf increments the epoch of another node, revoking its leases,
+// provided that the record the caller observed is still current and was
+// expired at the time the caller observed it. The time is read from the
+// hybrid logical clock of this node, which takes the clock signals received
+// from the other nodes into account, rather than from its physical clock (see
+// the note on Liveness.IsLive). The increment is recorded in the
+// LastEpochIncrement field of the incremented record, along with the reason
+// and this node's ID, so that it can be told who keeps incrementing the epoch
+// of a node, and why.
+//
+// The errors are those of IncrementEpoch.
+func (nl *NodeLiveness) IncrementEpochOf(ctx context.Context, req EpochIncrementRequest) error {
+	liveness := req.Observed
+	if liveness.Equal(livenesspb.Liveness{}) {
+		return errors.AssertionFailedf("invalid observed liveness record; found to be empty")
+	}
+	if req.Reason == "" {
+		return errors.AssertionFailedf("no reason given to increment the epoch of n%d", liveness.NodeID)
+	}
+	if liveness.NodeID == nl.cache.selfID() {
+		return errors.AssertionFailedf("n%d cannot increment its own epoch", liveness.NodeID)
+	}
+	if now := nl.clock.NowAsClockTimestamp(); now.Less(req.Now) {
+		return errors.AssertionFailedf("epoch increment timestamp %s is ahead of the clock at %s",
+			req.Now, now)
+	}
+
 	// Allow only one increment at a time.
 	sem := nl.sem(liveness.NodeID)
 	select {
@@ -1039,7 +2509,9 @@ func (nl *NodeLiveness) IncrementEpoch(ctx context.Context, liveness livenesspb.
 		<-sem
 	}()
 
-	if liveness.IsLive(nl.clock.Now()) {
+	// NB: a record that is expired as of req.Now is also expired as of any
+	// later time, including the current one.
+	if liveness.IsLive(req.Now.ToTimestamp()) {
 		return errors.Errorf("cannot increment epoch on live node: %+v", liveness)
 	}
 
@@ -1048,6 +2520,12 @@ func (nl *NodeLiveness) IncrementEpoch(ctx context.Context, liveness livenesspb.
 		oldLiveness: liveness,
 	}
 	update.newLiveness.Epoch++
+	update.newLiveness.LastEpochIncrement = livenesspb.EpochIncrement{
+		Epoch:             update.newLiveness.Epoch,
+		IncrementerNodeID: nl.cache.selfID(),
+		Reason:            req.Reason,
+		Timestamp:         nl.clock.Now(),
+	}
 
 	written, err := nl.updateLiveness(ctx, update, func(actual Record) error {
 		nl.cache.maybeUpdate(ctx, actual)
@@ -1066,7 +2544,8 @@ func (nl *NodeLiveness) IncrementEpoch(ctx context.Context, liveness livenesspb.
 		return err
 	}
 
-	log.Infof(ctx, "incremented n%d liveness epoch to %d", written.NodeID, written.Epoch)
+	log.Infof(ctx, "incremented n%d liveness epoch to %d (%s); record expired at %s, observed expired at %s",
+		written.NodeID, written.Epoch, req.Reason, liveness.Expiration, req.Now)
 	nl.cache.maybeUpdate(ctx, written)
 	nl.metrics.EpochIncrements.Inc()
 	return nil
@@ -1110,13 +2589,14 @@ func (nl *NodeLiveness) updateLiveness(
 	if err := nl.verifyDiskHealth(ctx); err != nil {
 		return Record{}, err
 	}
-	retryOpts := base.DefaultRetryOptions()
-	retryOpts.Closer = nl.stopper.ShouldQuiesce()
-	for r := retry.StartWithCtx(ctx, retryOpts); r.Next(); {
+	var lastErr error
+	r := nl.startRetries(ctx)
+	for r.Next() {
 		written, err := nl.updateLivenessAttempt(ctx, update, handleCondFailed)
 		if err != nil {
 			if errors.HasType(err, (*errRetryLiveness)(nil)) {
 				log.Infof(ctx, "retrying liveness update after %s", err)
+				lastErr = err
 				continue
 			}
 			return Record{}, err
@@ -1129,6 +2609,9 @@ func (nl *NodeLiveness) updateLiveness(
 	if err := ctx.Err(); err != nil {
 		return Record{}, err
 	}
+	if r.exhausted() {
+		return Record{}, errors.Wrapf(lastErr, "giving up on liveness update after %d retries", r.CurrentAttempt())
+	}
 	return Record{}, errors.New("retry loop ended without error - likely shutting down")
 }
 
@@ -1140,8 +2623,14 @@ func (nl *NodeLiveness) updateLiveness(
 // relevant for a stalled disk during a lease acquisition heartbeat, where we
 // need to return a timely NLHE to the caller such that it will try a different
 // replica and nudge it into acquiring the lease. This can leak a goroutine in
-// the case of a stalled disk.
+// the case of a stalled disk. The wait is bounded by
+// kv.liveness.disk_probe_timeout, if set.
 func (nl *NodeLiveness) verifyDiskHealth(ctx context.Context) error {
+	if timeout := diskProbeTimeout.Get(&nl.st.SV); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 	resultCs := make([]singleflight.Future, len(nl.engines))
 	for i, eng := range nl.engines {
 		eng := eng // pin the loop variable
@@ -1155,15 +2644,33 @@ func (nl *NodeLiveness) verifyDiskHealth(ctx context.Context) error {
 				return nil, diskStorage.WriteSyncNoop(eng)
 			})
 	}
-	for _, resultC := range resultCs {
+	for i, resultC := range resultCs {
 		r := resultC.WaitForResult(ctx)
 		if r.Err != nil {
+			nl.metrics.DiskProbeFailures.Inc(1)
+			if storeID, err := nl.engines[i].GetStoreID(); err == nil {
+				return errors.Wrapf(r.Err, "disk write to s%d failed while updating node liveness", storeID)
+			}
 			return errors.Wrapf(r.Err, "disk write failed while updating node liveness")
 		}
 	}
 	return nil
 }
 
+// verifyApplicationProgress returns an error if a local store has been
+// applying committed Raft commands for longer than
+// kv.liveness.application_stall_threshold without completing any.
+func (nl *NodeLiveness) verifyApplicationProgress() error {
+	threshold := applicationStallThreshold.Get(&nl.st.SV)
+	if nl.checkApplicationProgress == nil || threshold == 0 {
+		return nil
+	}
+	if err := nl.checkApplicationProgress(threshold); err != nil {
+		return errors.Wrap(err, "not heartbeating liveness")
+	}
+	return nil
+}
+
 func (nl *NodeLiveness) updateLivenessAttempt(
 	ctx context.Context, update livenessUpdate, handleCondFailed func(actual Record) error,
 ) (Record, error) {
@@ -1177,11 +2684,25 @@ func (nl *NodeLiveness) updateLivenessAttempt(
 			// return ErrMissingRecord here instead.
 			return Record{}, ErrRecordCacheMiss
 		}
-		if l.Liveness != update.oldLiveness {
+		if !l.Liveness.Equal(update.oldLiveness) {
 			return Record{}, handleCondFailed(l)
 		}
 		update.oldRaw = l.raw
 	}
+	if update.newLiveness.NodeID != nl.cache.selfID() && len(update.oldRaw) > 0 {
+		// The update of the record of another node was made to its normalized
+		// form, so write back the fields normalization changed as they were
+		// found: other nodes may only update some of them (see
+		// livenesspb.Liveness.ValidateUpdateBy).
+		raw, err := decodeRawLiveness(update.oldRaw)
+		if err != nil {
+			return Record{}, err
+		}
+		update.newLiveness.Denormalize(update.oldLiveness, raw)
+	}
+	if nl.shouldBatch(update) {
+		return nl.renewBatched(ctx, update, handleCondFailed)
+	}
 	return nl.storage.update(ctx, update, handleCondFailed)
 }
 
@@ -1201,18 +2722,26 @@ func (nl *NodeLiveness) numLiveNodes() int64 {
 		return 0
 	}
 
-	var liveNodes int64
-	for n, v := range nl.cache.GetIsLiveMap() {
-		if v.IsLive {
-			liveNodes++
-		}
-		// If this node isn't live, we don't want to report its view of node liveness
-		// because it's more likely to be inaccurate than the view of a live node.
-		if n == selfID && !v.IsLive {
-			return 0
+	isLiveMap := nl.GetIsLiveMap()
+	// If this node isn't live, we don't want to report its view of node liveness
+	// because it's more likely to be inaccurate than the view of a live node.
+	if self, ok := isLiveMap[selfID]; ok && !self.IsLive {
+		return 0
+	}
+	return int64(isLiveMap.CountByStatus().Live)
+}
+
+// maxClockOffsetNanos returns the largest clock offset recorded in the liveness
+// records of the live nodes, which backs the liveness.max_clock_offset metric.
+func (nl *NodeLiveness) maxClockOffsetNanos() int64 {
+	now := nl.clock.Now()
+	var maxOffset int64
+	for _, l := range nl.cache.getAllLivenesses() {
+		if l.IsLive(now) && l.MaxClockOffsetNanos > maxOffset {
+			maxOffset = l.MaxClockOffsetNanos
 		}
 	}
-	return liveNodes
+	return maxOffset
 }
 
 // GetNodeCount returns a count of the number of nodes in the cluster,
@@ -1257,7 +2786,7 @@ func (nl *NodeLiveness) GetNodeCountWithOverrides(
 func (nl *NodeLiveness) TestingSetDrainingInternal(
 	ctx context.Context, liveness Record, drain bool,
 ) error {
-	return nl.setDrainingInternal(ctx, liveness, drain, nil /* reporter */)
+	return nl.setDrainingInternal(ctx, liveness, drain, nil /* reporter */, "" /* reason */)
 }
 
 // TestingSetDecommissioningInternal is a testing helper to set the internal
@@ -1265,7 +2794,7 @@ func (nl *NodeLiveness) TestingSetDrainingInternal(
 func (nl *NodeLiveness) TestingSetDecommissioningInternal(
 	ctx context.Context, oldLivenessRec Record, targetStatus livenesspb.MembershipStatus,
 ) (changeCommitted bool, err error) {
-	return nl.setMembershipStatusInternal(ctx, oldLivenessRec, targetStatus)
+	return nl.setMembershipStatusInternal(ctx, oldLivenessRec, targetStatus, "" /* reason */, "" /* token */)
 }
 
 // TestingMaybeUpdate replaces the liveness (if it appears newer) and invokes
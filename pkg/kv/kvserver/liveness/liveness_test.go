@@ -11,14 +11,29 @@
 package liveness
 
 import (
+	"context"
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/kvserverbase"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
+	"github.com/cockroachdb/cockroach/pkg/storage"
+	"github.com/cockroachdb/cockroach/pkg/testutils"
 	"github.com/cockroachdb/cockroach/pkg/util/hlc"
 	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/metric"
 	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
+	"github.com/cockroachdb/cockroach/pkg/util/stop"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil/singleflight"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/errors"
+	"github.com/kr/pretty"
+	prometheusgo "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
 )
 
 func TestShouldReplaceLiveness(t *testing.T) {
@@ -131,3 +146,619 @@ func TestShouldReplaceLiveness(t *testing.T) {
 		})
 	}
 }
+
+// fakeProber is a Prober for which reachability between nodes is given by a
+// set of broken links.
+type fakeProber struct {
+	self   roachpb.NodeID
+	broken map[[2]roachpb.NodeID]bool
+}
+
+func (p *fakeProber) reachable(from, to roachpb.NodeID) error {
+	if p.broken[[2]roachpb.NodeID{from, to}] {
+		return errors.Errorf("n%d cannot reach n%d", from, to)
+	}
+	return nil
+}
+
+func (p *fakeProber) Probe(_ context.Context, target roachpb.NodeID) error {
+	return p.reachable(p.self, target)
+}
+
+func (p *fakeProber) ProbeVia(_ context.Context, via, target roachpb.NodeID) error {
+	if err := p.reachable(p.self, via); err != nil {
+		return err
+	}
+	return p.reachable(via, target)
+}
+
+func TestSWIMDetector(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	stopper := stop.NewStopper()
+	defer stopper.Stop(ctx)
+	st := cluster.MakeTestingClusterSettings()
+	FailureDetectorMode.Override(ctx, &st.SV, int64(failureDetectorSWIM))
+	swimSuspicionTimeout.Override(ctx, &st.SV, 5*time.Second)
+	manual := timeutil.NewManualTime(timeutil.Unix(0, 123))
+	clock := hlc.NewClockForTesting(manual)
+
+	prober := &fakeProber{self: 1, broken: map[[2]roachpb.NodeID]bool{}}
+	members := func() []roachpb.NodeID { return []roachpb.NodeID{2, 3, 4} }
+	d := newSWIMDetector(st, clock, stopper, prober, members)
+	require.True(t, d.enabled())
+
+	// probeRound probes every member once.
+	probeRound := func() {
+		for range members() {
+			d.probeNext(ctx)
+		}
+	}
+	connectivity := func(nodeID roachpb.NodeID) livenesspb.Connectivity {
+		return d.connectivity(nodeID, clock.Now())
+	}
+
+	// Nothing is known about nodes that weren't probed yet.
+	require.Equal(t, livenesspb.Connectivity_UNKNOWN, connectivity(2))
+	probeRound()
+	for _, nodeID := range members() {
+		require.Equal(t, livenesspb.Connectivity_CONNECTED, connectivity(nodeID))
+	}
+
+	// A broken link between n1 and n2 is bridged by the indirect probes.
+	prober.broken[[2]roachpb.NodeID{1, 2}] = true
+	probeRound()
+	require.Equal(t, livenesspb.Connectivity_CONNECTED, connectivity(2))
+
+	// Once n2 can't be reached from anywhere, it becomes suspect, and is
+	// disconnected after the suspicion timeout.
+	prober.broken[[2]roachpb.NodeID{3, 2}] = true
+	prober.broken[[2]roachpb.NodeID{4, 2}] = true
+	probeRound()
+	require.Equal(t, livenesspb.Connectivity_UNKNOWN, connectivity(2))
+	manual.Advance(4 * time.Second)
+	probeRound()
+	require.Equal(t, livenesspb.Connectivity_UNKNOWN, connectivity(2))
+	manual.Advance(time.Second)
+	require.Equal(t, livenesspb.Connectivity_DISCONNECTED, connectivity(2))
+	require.Equal(t, livenesspb.Connectivity_CONNECTED, connectivity(3))
+
+	// Suspect nodes are not asked to probe on our behalf.
+	require.NotContains(t, d.pickHelpers(3, 3), roachpb.NodeID(2))
+
+	// A successful probe clears the suspicion.
+	prober.broken = map[[2]roachpb.NodeID]bool{}
+	probeRound()
+	require.Equal(t, livenesspb.Connectivity_CONNECTED, connectivity(2))
+}
+
+func TestLoadTracker(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	st := cluster.MakeTestingClusterSettings()
+	livenessRangeCapacity.Override(ctx, &st.SV, 100)
+	livenessHotThreshold.Override(ctx, &st.SV, 0.5)
+	livenessHotTTLMultiplier.Override(ctx, &st.SV, 2)
+	lt := newLoadTracker(st, time.Second)
+	now := lt.mu.lastSample
+
+	// sample records n heartbeats over 10s, and samples the load.
+	sample := func(n int) RangeLoad {
+		for i := 0; i < n; i++ {
+			lt.recordHeartbeat(livenesspb.Liveness{NodeID: 1})
+		}
+		now = now.Add(10 * time.Second)
+		load, _ := lt.sample(now)
+		return load
+	}
+
+	// 40 heartbeats/s is below the threshold.
+	load := sample(400)
+	require.Equal(t, 40.0, load.HeartbeatsPerSecond)
+	require.InDelta(t, 0.4, load.Utilization, 1e-9)
+	require.False(t, load.Hot)
+	require.Equal(t, 1.0, lt.ttlMultiplier())
+
+	// 60 heartbeats/s turns the range hot, which spreads out heartbeats.
+	load = sample(600)
+	require.True(t, load.Hot)
+	require.Equal(t, 2.0, lt.ttlMultiplier())
+
+	// Once heartbeats are spread out, the rate halves, but the range stays hot
+	// since its utilization would be back above the threshold without the
+	// mitigation.
+	load = sample(300)
+	require.InDelta(t, 0.6, load.Utilization, 1e-9)
+	require.True(t, load.Hot)
+
+	// The range cools down once the unmitigated rate drops below the threshold.
+	load = sample(200)
+	require.InDelta(t, 0.4, load.Utilization, 1e-9)
+	require.False(t, load.Hot)
+
+	// Slow local heartbeats alone don't make the range hot.
+	lt.recordLatency(400 * time.Millisecond)
+	lt.recordLatency(800 * time.Millisecond)
+	lt.recordHeartbeat(livenesspb.Liveness{NodeID: 1, HeartbeatLatencyNanos: int64(600 * time.Millisecond)})
+	lt.recordHeartbeat(livenesspb.Liveness{NodeID: 2, HeartbeatLatencyNanos: int64(10 * time.Millisecond)})
+	lt.recordHeartbeat(livenesspb.Liveness{NodeID: 3, HeartbeatLatencyNanos: int64(20 * time.Millisecond)})
+	load = sample(0)
+	require.Equal(t, 600*time.Millisecond, load.MeanHeartbeatLatency)
+	require.Equal(t, 20*time.Millisecond, load.PeerHeartbeatLatency)
+	require.False(t, load.Hot)
+
+	// Slow heartbeats reported by most nodes do.
+	lt.recordHeartbeat(livenesspb.Liveness{NodeID: 1, HeartbeatLatencyNanos: int64(600 * time.Millisecond)})
+	lt.recordHeartbeat(livenesspb.Liveness{NodeID: 2, HeartbeatLatencyNanos: int64(700 * time.Millisecond)})
+	lt.recordHeartbeat(livenesspb.Liveness{NodeID: 3, HeartbeatLatencyNanos: int64(20 * time.Millisecond)})
+	load = sample(0)
+	require.Equal(t, 600*time.Millisecond, load.PeerHeartbeatLatency)
+	require.InDelta(t, 0.6, load.Utilization, 1e-9)
+	require.True(t, load.Hot)
+}
+
+func TestCacheQuarantinesInvalidRecords(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	clock := hlc.NewClockForTesting(timeutil.NewManualTime(timeutil.Unix(0, 123)))
+	var notified []livenesspb.Liveness
+	c := &cache{
+		clock: clock,
+		notifyLivenessChanged: func(_, new livenesspb.Liveness) {
+			notified = append(notified, new)
+		},
+	}
+	c.mu.nodes = make(map[roachpb.NodeID]Record)
+	c.mu.quarantined = make(map[roachpb.NodeID]Record)
+
+	now := clock.Now()
+	valid := livenesspb.Liveness{
+		NodeID:     1,
+		Epoch:      1,
+		Expiration: now.AddDuration(9 * time.Second).ToLegacyTimestamp(),
+	}
+	invalid := valid
+	invalid.Epoch = 1 << 40
+	invalid.Expiration = now.AddDuration(100 * 24 * time.Hour).ToLegacyTimestamp()
+
+	// The invalid record is kept out of the cache.
+	c.maybeUpdate(ctx, Record{Liveness: invalid})
+	_, ok := c.GetLiveness(1)
+	require.False(t, ok)
+	require.Empty(t, notified)
+	require.Equal(t, []livenesspb.Liveness{invalid}, c.getQuarantined())
+
+	// A valid record is cached, and lifts the quarantine.
+	c.maybeUpdate(ctx, Record{Liveness: valid})
+	l, ok := c.GetLiveness(1)
+	require.True(t, ok)
+	require.Equal(t, valid, l.Liveness)
+	require.Equal(t, []livenesspb.Liveness{valid}, notified)
+	require.Empty(t, c.getQuarantined())
+
+	// A later invalid record doesn't poison the cache.
+	c.maybeUpdate(ctx, Record{Liveness: invalid})
+	l, _ = c.GetLiveness(1)
+	require.Equal(t, valid, l.Liveness)
+	require.Len(t, c.getQuarantined(), 1)
+}
+
+func TestCacheDeadVerdicts(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	manual := timeutil.NewManualTime(timeutil.Unix(0, 123))
+	clock := hlc.NewClockForTesting(manual)
+	c := &cache{
+		clock:                 clock,
+		notifyLivenessChanged: func(_, _ livenesspb.Liveness) {},
+	}
+	c.mu.nodes = make(map[roachpb.NodeID]Record)
+	c.mu.quarantined = make(map[roachpb.NodeID]Record)
+	c.mu.dead = make(map[roachpb.NodeID]time.Duration)
+	const threshold = time.Minute
+	record := func(epoch int64) Record {
+		return Record{Liveness: livenesspb.Liveness{
+			NodeID:     1,
+			Epoch:      epoch,
+			Expiration: clock.Now().AddDuration(9 * time.Second).ToLegacyTimestamp(),
+		}}
+	}
+
+	// Nodes without a record, or whose record didn't expire long enough ago,
+	// aren't dead.
+	dead, _ := c.isDead(1, threshold)
+	require.False(t, dead)
+	c.maybeUpdate(ctx, record(1))
+	dead, _ = c.isDead(1, threshold)
+	require.False(t, dead)
+
+	// Once the node is found dead, the verdict is reused.
+	manual.Advance(9*time.Second + threshold)
+	dead, cached := c.isDead(1, threshold)
+	require.True(t, dead)
+	require.False(t, cached)
+	dead, cached = c.isDead(1, threshold)
+	require.True(t, dead)
+	require.True(t, cached)
+
+	// The verdict isn't reused for a different threshold.
+	dead, cached = c.isDead(1, 2*threshold)
+	require.False(t, dead)
+	require.False(t, cached)
+
+	// An update of the record invalidates the verdict.
+	c.maybeUpdate(ctx, record(2))
+	dead, _ = c.isDead(1, threshold)
+	require.False(t, dead)
+	require.Empty(t, c.mu.dead)
+}
+
+func TestColdNodeLivenessTTL(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	st := cluster.MakeTestingClusterSettings()
+	coldNodeTTLMultiplier.Override(ctx, &st.SV, 10)
+	newNodeLiveness := func(cold bool) *NodeLiveness {
+		return &NodeLiveness{
+			st:                st,
+			livenessThreshold: 9 * time.Second,
+			renewalDuration:   4500 * time.Millisecond,
+			load:              newLoadTracker(st, 4500*time.Millisecond),
+			cold:              cold,
+		}
+	}
+
+	nl := newNodeLiveness(false /* cold */)
+	require.Equal(t, 9*time.Second, nl.livenessTTL())
+	require.Equal(t, 4500*time.Millisecond, nl.heartbeatInterval())
+
+	// Cold nodes heartbeat 10 times less often, with a 10 times longer TTL,
+	// but renew their liveness just as long before it expires.
+	nl = newNodeLiveness(true /* cold */)
+	require.Equal(t, 90*time.Second, nl.livenessTTL())
+	require.Equal(t, 85500*time.Millisecond, nl.heartbeatInterval())
+}
+
+// TestHeartbeatRecordIsRenewal verifies that a heartbeat that doesn't change
+// how the other nodes treat the node is a renewal, which fails if the heartbeat
+// sets a field without going through livenesspb.HeartbeatTelemetry.
+func TestHeartbeatRecordIsRenewal(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	st := cluster.MakeTestingClusterSettings()
+	kvserverbase.LivenessStoreDigestsEnabled.Override(ctx, &st.SV, true)
+	manual := timeutil.NewManualTime(timeutil.Unix(0, int64(time.Hour)))
+	nl := &NodeLiveness{
+		st:                  st,
+		clock:               hlc.NewClockForTesting(manual),
+		load:                newLoadTracker(st, 4500*time.Millisecond),
+		maxClockOffset:      func() time.Duration { return 250 * time.Millisecond },
+		underMemoryPressure: func(float64) bool { return true },
+		storeDigests: func(context.Context) []livenesspb.StoreDigest {
+			return []livenesspb.StoreDigest{{StoreID: 1, RangeCount: 10}}
+		},
+	}
+	nl.recordHeartbeatAttempt(errors.New("boom"))
+
+	// The record of a node that never reported anything about itself, so that
+	// the heartbeat sets all it sets.
+	ttl := 9 * time.Second
+	now := nl.clock.Now()
+	old := livenesspb.Liveness{
+		NodeID:     1,
+		Epoch:      1,
+		Expiration: now.ToLegacyTimestamp(),
+		TTLNanos:   ttl.Nanoseconds(),
+	}
+	renewed, err := nl.heartbeatRecord(ctx, old, now, ttl, false /* incrementEpoch */)
+	require.NoError(t, err)
+	require.NotEqual(t, old.HeartbeatTelemetry(), renewed.HeartbeatTelemetry())
+	require.True(t, livenesspb.IsRenewal(old, renewed), "%s", pretty.Diff(old, renewed))
+
+	// A heartbeat that increments the epoch isn't.
+	incremented, err := nl.heartbeatRecord(ctx, old, now, ttl, true /* incrementEpoch */)
+	require.NoError(t, err)
+	require.False(t, livenesspb.IsRenewal(old, incremented))
+}
+
+// stalledEngine is an engine whose synchronous batch commits block until
+// unblock is closed.
+type stalledEngine struct {
+	storage.Engine
+	unblock chan struct{}
+}
+
+func (e stalledEngine) NewBatch() storage.Batch {
+	return stalledBatch{Batch: e.Engine.NewBatch(), unblock: e.unblock}
+}
+
+type stalledBatch struct {
+	storage.Batch
+	unblock chan struct{}
+}
+
+func (b stalledBatch) Commit(sync bool) error {
+	<-b.unblock
+	return b.Batch.Commit(sync)
+}
+
+func TestVerifyDiskHealthTimeout(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	stopper := stop.NewStopper()
+	defer stopper.Stop(ctx)
+
+	eng := storage.NewDefaultInMemForTesting()
+	defer eng.Close()
+	unblock := make(chan struct{})
+	st := cluster.MakeTestingClusterSettings()
+	nl := &NodeLiveness{
+		st:          st,
+		stopper:     stopper,
+		engineSyncs: singleflight.NewGroup("engine sync", "engine"),
+		engines:     []storage.Engine{stalledEngine{Engine: eng, unblock: unblock}},
+		metrics:     Metrics{DiskProbeFailures: metric.NewCounter(metaDiskProbeFailures)},
+	}
+
+	// The stalled write fails the probe once the timeout expires, well before
+	// the deadline of the caller.
+	diskProbeTimeout.Override(ctx, &st.SV, time.Millisecond)
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	err := nl.verifyDiskHealth(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Contains(t, err.Error(), "disk write failed while updating node liveness")
+	require.Equal(t, int64(1), nl.metrics.DiskProbeFailures.Count())
+
+	// Once the disk recovers, the probe succeeds.
+	close(unblock)
+	testutils.SucceedsSoon(t, func() error {
+		return nl.verifyDiskHealth(ctx)
+	})
+}
+
+func TestRetryPolicy(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	ctx := context.Background()
+	metrics := Metrics{
+		UpdateRetries:          metric.NewCounter(metaUpdateRetries),
+		UpdateRetriesExhausted: metric.NewCounter(metaUpdateRetriesExhausted),
+	}
+	policy := RetryPolicy{
+		InitialBackoff: time.Microsecond,
+		MaxBackoff:     time.Microsecond,
+		MaxRetries:     3,
+	}
+
+	// A loop that keeps failing gives up after MaxRetries retries.
+	var attempts int
+	r := newRetrier(ctx, policy, nil /* closer */, &metrics)
+	for r.Next() {
+		attempts++
+	}
+	require.Equal(t, 4, attempts)
+	require.True(t, r.exhausted())
+	require.Equal(t, int64(3), metrics.UpdateRetries.Count())
+	require.Equal(t, int64(1), metrics.UpdateRetriesExhausted.Count())
+
+	// A loop that succeeds doesn't record any retry.
+	r = newRetrier(ctx, policy, nil /* closer */, &metrics)
+	require.True(t, r.Next())
+	require.Equal(t, int64(3), metrics.UpdateRetries.Count())
+
+	// A loop ended by its closer didn't exhaust its retries.
+	closer := make(chan struct{})
+	close(closer)
+	policy.InitialBackoff, policy.MaxBackoff = time.Hour, time.Hour
+	r = newRetrier(ctx, policy, closer, &metrics)
+	require.True(t, r.Next())
+	require.False(t, r.Next())
+	require.False(t, r.exhausted())
+	require.Equal(t, int64(1), metrics.UpdateRetriesExhausted.Count())
+}
+
+func TestNextTTLAutotuneMultiplier(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	const ttl, renewal, maxTTL = 9 * time.Second, 4500 * time.Millisecond, 27 * time.Second
+	testCases := []struct {
+		name             string
+		prev             float64
+		maxClockOffset   time.Duration
+		heartbeatLatency time.Duration
+		expected         float64
+	}{
+		{"healthy", 1, 10 * time.Millisecond, 10 * time.Millisecond, 1},
+		{"large clock offset", 1, 900 * time.Millisecond, 10 * time.Millisecond, 2},
+		{"slow heartbeats", 1, 10 * time.Millisecond, 1500 * time.Millisecond, 4.0 / 3},
+		{"bounded", 1, 10 * time.Second, 10 * time.Millisecond, 3},
+		{"shrinks gradually", 2, 10 * time.Millisecond, 10 * time.Millisecond, 1.8},
+		{"shrinks back to the base ttl", 1.05, 10 * time.Millisecond, 10 * time.Millisecond, 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.InDelta(t, tc.expected, nextTTLAutotuneMultiplier(
+				tc.prev, ttl, renewal, maxTTL, tc.maxClockOffset, tc.heartbeatLatency), 1e-9)
+		})
+	}
+}
+
+func TestCanResumeEpoch(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	const ttl, grace = 9 * time.Second, 2 * time.Second
+	// The last heartbeat was at t=10s.
+	l := livenesspb.Liveness{
+		NodeID:     1,
+		Epoch:      3,
+		Expiration: hlc.LegacyTimestamp{WallTime: (19 * time.Second).Nanoseconds()},
+	}
+	at := func(d time.Duration) hlc.Timestamp {
+		return hlc.Timestamp{WallTime: d.Nanoseconds()}
+	}
+	draining := l
+	draining.Draining = true
+	cold := l
+	cold.Expiration = hlc.LegacyTimestamp{WallTime: (100 * time.Second).Nanoseconds()}
+	cold.TTLNanos = (90 * time.Second).Nanoseconds()
+
+	testCases := []struct {
+		name     string
+		l        livenesspb.Liveness
+		now      hlc.Timestamp
+		grace    time.Duration
+		expected bool
+	}{
+		{"fast restart", l, at(11 * time.Second), grace, true},
+		{"disabled", l, at(11 * time.Second), 0, false},
+		{"slow restart", l, at(13 * time.Second), grace, false},
+		{"expired", l, at(20 * time.Second), time.Minute, false},
+		{"draining", draining, at(11 * time.Second), grace, false},
+		{"provisional", livenesspb.Liveness{NodeID: 1, Expiration: l.Expiration},
+			at(11 * time.Second), grace, false},
+		{"cold node", cold, at(11 * time.Second), grace, true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, canResumeEpoch(tc.l, tc.now, ttl, tc.grace))
+		})
+	}
+}
+
+func TestStateSetGauge(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	g := &stateSetGauge{
+		Metadata: metaMembershipState,
+		label:    "membership",
+		states:   []string{"active", "decommissioning", "decommissioned"},
+		nodeStates: func() map[roachpb.NodeID]string {
+			return map[roachpb.NodeID]string{1: "active", 2: "decommissioning"}
+		},
+	}
+	require.Equal(t, 2.0, g.ToPrometheusMetric().Gauge.GetValue())
+
+	var series []string
+	g.Each(nil, func(m *prometheusgo.Metric) {
+		var labels string
+		for _, l := range m.Label {
+			labels += fmt.Sprintf("%s=%s,", l.GetName(), l.GetValue())
+		}
+		series = append(series, fmt.Sprintf("%s%.0f", labels, m.Gauge.GetValue()))
+	})
+	require.Equal(t, []string{
+		"peer_node_id=1,membership=active,1",
+		"peer_node_id=1,membership=decommissioning,0",
+		"peer_node_id=1,membership=decommissioned,0",
+		"peer_node_id=2,membership=active,0",
+		"peer_node_id=2,membership=decommissioning,1",
+		"peer_node_id=2,membership=decommissioned,0",
+	}, series)
+}
+
+func TestLocalityStatusGauge(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	locality := func(s string) roachpb.Locality {
+		var l roachpb.Locality
+		require.NoError(t, l.Set(s))
+		return l
+	}
+	g := &localityStatusGauge{
+		Metadata: metaLocalityStatus,
+		states:   []string{"live", "dead"},
+		nodes: func() []nodeLocalityStatus {
+			return []nodeLocalityStatus{
+				{locality("region=east,zone=a"), "live"},
+				{locality("region=east,zone=b"), "dead"},
+				{locality("region=west,zone=a"), "live"},
+			}
+		},
+	}
+	require.Equal(t, 3.0, g.ToPrometheusMetric().Gauge.GetValue())
+
+	var series []string
+	g.Each(nil, func(m *prometheusgo.Metric) {
+		var labels string
+		for _, l := range m.Label {
+			labels += fmt.Sprintf("%s=%s;", l.GetName(), l.GetValue())
+		}
+		series = append(series, fmt.Sprintf("%s%.0f", labels, m.Gauge.GetValue()))
+	})
+	// Zones of the same name in different regions are told apart.
+	require.Equal(t, []string{
+		"locality_tier=region;locality=region=east;status=live;1",
+		"locality_tier=region;locality=region=east;status=dead;1",
+		"locality_tier=zone;locality=region=east,zone=a;status=live;1",
+		"locality_tier=zone;locality=region=east,zone=a;status=dead;0",
+		"locality_tier=zone;locality=region=east,zone=b;status=live;0",
+		"locality_tier=zone;locality=region=east,zone=b;status=dead;1",
+		"locality_tier=region;locality=region=west;status=live;1",
+		"locality_tier=region;locality=region=west;status=dead;0",
+		"locality_tier=zone;locality=region=west,zone=a;status=live;1",
+		"locality_tier=zone;locality=region=west,zone=a;status=dead;0",
+	}, series)
+}
+
+func TestHeartbeatWatchdog(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	st := cluster.MakeTestingClusterSettings()
+	const threshold = time.Minute
+	heartbeatWatchdogThreshold.Override(ctx, &st.SV, threshold)
+	nl := &NodeLiveness{
+		st:             st,
+		heartbeatToken: make(chan struct{}, 1),
+		metrics: Metrics{
+			HeartbeatLoopStalls:   metric.NewCounter(metaHeartbeatLoopStalls),
+			HeartbeatLoopRestarts: metric.NewCounter(metaHeartbeatLoopRestarts),
+		},
+	}
+
+	// An idle loop or a short attempt is left alone.
+	now := timeutil.Now()
+	nl.checkHeartbeatLoop(ctx, now)
+	var canceled bool
+	nl.watchdog.beginAttempt(0 /* generation */, nil /* sp */, func() { canceled = true })
+	start := nl.watchdog.start
+	nl.checkHeartbeatLoop(ctx, start.Add(threshold/2))
+	require.False(t, canceled)
+
+	// A wedged attempt is canceled.
+	nl.checkHeartbeatLoop(ctx, start.Add(threshold))
+	require.True(t, canceled)
+	require.Equal(t, int64(1), nl.metrics.HeartbeatLoopStalls.Count())
+
+	// An attempt that is still wedged afterwards gets its loop restarted,
+	// unless a previously restarted loop is wedged too.
+	nl.watchdog.abandoned = 1
+	nl.checkHeartbeatLoop(ctx, start.Add(3*threshold))
+	require.Zero(t, nl.metrics.HeartbeatLoopRestarts.Count())
+	require.Equal(t, int64(0), nl.watchdog.generation)
+
+	// The attempt finally ends, and its loop keeps going.
+	require.False(t, nl.watchdog.endAttempt(0 /* generation */, true /* heartbeated */))
+	require.True(t, nl.watchdog.start.IsZero())
+
+	// A replaced loop exits when its attempt ends.
+	nl.watchdog.generation = 1
+	require.True(t, nl.watchdog.endAttempt(0 /* generation */, true /* heartbeated */))
+	require.Zero(t, nl.watchdog.abandoned)
+}
@@ -0,0 +1,138 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package livenesspb
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/errors"
+)
+
+// String implements fmt.Stringer.
+func (s NodeLifecycleState) String() string {
+	switch s {
+	case NodeLifecycleState_JOINING:
+		return "joining"
+	case NodeLifecycleState_ACTIVE:
+		return "active"
+	case NodeLifecycleState_DRAINING:
+		return "draining"
+	case NodeLifecycleState_SUSPECT:
+		return "suspect"
+	case NodeLifecycleState_DEAD:
+		return "dead"
+	case NodeLifecycleState_DECOMMISSIONING:
+		return "decommissioning"
+	case NodeLifecycleState_DECOMMISSIONED:
+		return "decommissioned"
+	default:
+		return fmt.Sprintf("unknown(%d)", int32(s))
+	}
+}
+
+// ParseNodeLifecycleState parses the name of a lifecycle state, as returned by
+// NodeLifecycleState.String, case-insensitively. "maintenance" is accepted as
+// an alias of the draining state, which the nodes in a maintenance window are
+// in (see Liveness.InMaintenance).
+func ParseNodeLifecycleState(name string) (NodeLifecycleState, error) {
+	if strings.EqualFold(name, "maintenance") {
+		return NodeLifecycleState_DRAINING, nil
+	}
+	for v := range NodeLifecycleState_name {
+		if s := NodeLifecycleState(v); strings.EqualFold(s.String(), name) {
+			return s, nil
+		}
+	}
+	return 0, errors.Errorf("unknown node lifecycle state %q", name)
+}
+
+// LifecycleState returns the lifecycle state of the node at the given time,
+// based on its liveness record alone. Nodes whose liveness expired for longer
+// than the given threshold are considered dead.
+func (l *Liveness) LifecycleState(
+	now hlc.Timestamp, deadThreshold time.Duration,
+) NodeLifecycleState {
+	state, _ := deriveLifecycle(l, Connectivity_UNKNOWN, now, deadThreshold)
+	return state
+}
+
+// LifecycleState returns the lifecycle state of the node at the given time.
+// Unlike Liveness.LifecycleState, a node that is unreachable over RPC is
+// suspect even if its liveness record hasn't expired yet.
+func (v NodeVitality) LifecycleState(
+	now hlc.Timestamp, deadThreshold time.Duration,
+) NodeLifecycleState {
+	state, _ := deriveLifecycle(&v.Liveness, v.Connectivity, now, deadThreshold)
+	return state
+}
+
+// deriveLifecycle derives the lifecycle state of a node from its liveness
+// record and the connectivity to it. It also returns the
+// NodeLivenessStatus the state maps to, which is coarser (e.g. it doesn't tell
+// a dead decommissioning node from a decommissioned one) and kept for
+// backwards compatibility. Liveness.Status and NodeVitality.Status are defined
+// in terms of it, so that the two can't diverge.
+func deriveLifecycle(
+	l *Liveness, conn Connectivity, now hlc.Timestamp, deadThreshold time.Duration,
+) (NodeLifecycleState, NodeLivenessStatus) {
+	// The state of the process of the node, regardless of its membership.
+	var process NodeLifecycleState
+	switch {
+	case l.Expiration.WallTime == 0:
+		process = NodeLifecycleState_JOINING
+	case l.IsDead(now, deadThreshold):
+		process = NodeLifecycleState_DEAD
+	case l.Provisional():
+		process = NodeLifecycleState_JOINING
+	case !l.IsLive(now) || l.Departing || conn == Connectivity_DISCONNECTED:
+		// A node that announced its clean shutdown is gone already, even if its
+		// record hasn't expired yet, and so is a node we can't reach.
+		process = NodeLifecycleState_SUSPECT
+	case l.Draining || l.InMaintenance(now):
+		// A node in a maintenance window is treated as draining, regardless of
+		// whether it actually drained.
+		process = NodeLifecycleState_DRAINING
+	default:
+		process = NodeLifecycleState_ACTIVE
+	}
+
+	var status NodeLivenessStatus
+	switch {
+	case process == NodeLifecycleState_JOINING:
+		status = NodeLivenessStatus_UNKNOWN
+	case process == NodeLifecycleState_DEAD && !l.Membership.Active():
+		status = NodeLivenessStatus_DECOMMISSIONED
+	case process == NodeLifecycleState_DEAD:
+		status = NodeLivenessStatus_DEAD
+	case !l.Membership.Active() && l.IsLive(now):
+		// NB: this is the case even for a fully decommissioned node, see the
+		// comment on Liveness.Status.
+		status = NodeLivenessStatus_DECOMMISSIONING
+	case process == NodeLifecycleState_ACTIVE:
+		status = NodeLivenessStatus_LIVE
+	case process == NodeLifecycleState_DRAINING:
+		status = NodeLivenessStatus_DRAINING
+	default:
+		status = NodeLivenessStatus_UNAVAILABLE
+	}
+
+	switch l.Membership {
+	case MembershipStatus_DECOMMISSIONING:
+		return NodeLifecycleState_DECOMMISSIONING, status
+	case MembershipStatus_DECOMMISSIONED:
+		return NodeLifecycleState_DECOMMISSIONED, status
+	default:
+		return process, status
+	}
+}
@@ -11,17 +11,22 @@
 package livenesspb
 
 import (
+	"context"
+	"encoding/binary"
 	"fmt"
 	"time"
 
+	"github.com/cockroachdb/cockroach/pkg/clusterversion"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/tracing/tracingpb"
 	"github.com/cockroachdb/errors"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
-// IsLive returns whether the node is considered live at the given time.
+// IsLive returns whether the node is considered live at the given time. A
+// provisional record is never live.
 //
 // NOTE: If one is interested whether the Liveness is valid currently, then the
 // timestamp passed in should be the known high-water mark of all the clocks of
@@ -32,17 +37,281 @@ import (
 // clock.Now().GoTime() rather than clock.PhysicalNow() - the former takes into
 // consideration clock signals from other nodes, the latter doesn't.
 func (l *Liveness) IsLive(now hlc.Timestamp) bool {
-	return now.Less(l.Expiration.ToTimestamp())
+	return !l.Provisional() && now.Less(l.Expiration.ToTimestamp())
 }
 
-// IsDead returns true if the liveness expired more than threshold ago.
+// IsDead returns true if the liveness expired more than threshold ago. A
+// provisional record is dead as soon as it expires: the node failed to join.
 //
 // Note that, because of threshold, IsDead() is not the inverse of IsLive().
 func (l *Liveness) IsDead(now hlc.Timestamp, threshold time.Duration) bool {
+	if l.Provisional() {
+		threshold = 0
+	}
 	expiration := l.Expiration.ToTimestamp().AddDuration(threshold)
 	return !now.Less(expiration)
 }
 
+// InMaintenance returns whether the liveness record declares a maintenance
+// window that is in effect at the given time.
+func (l *Liveness) InMaintenance(now hlc.Timestamp) bool {
+	if l.MaintenanceEnd.IsEmpty() {
+		return false
+	}
+	return l.MaintenanceStart.LessEq(now) && now.Less(l.MaintenanceEnd)
+}
+
+// MaintenanceExpired returns whether the liveness record declares a
+// maintenance window that has ended by the given time.
+func (l *Liveness) MaintenanceExpired(now hlc.Timestamp) bool {
+	return !l.MaintenanceEnd.IsEmpty() && l.MaintenanceEnd.LessEq(now)
+}
+
+// AwaitingRestart returns whether the liveness record declares that the node
+// is down for a planned restart and is still expected back at the given time.
+func (l *Liveness) AwaitingRestart(now hlc.Timestamp) bool {
+	return !l.PlannedRestartUntil.IsEmpty() && now.Less(l.PlannedRestartUntil)
+}
+
+// IsSuspect returns whether the node came back from being unavailable less
+// than suspectDuration ago, as of the given time. See LastUnavailable.
+func (l *Liveness) IsSuspect(now hlc.Timestamp, suspectDuration time.Duration) bool {
+	return !l.LastUnavailable.IsEmpty() && now.Less(l.LastUnavailable.AddDuration(suspectDuration))
+}
+
+// Provisional returns whether the liveness record is the one created for a
+// node before its first heartbeat, which increments the epoch to 1. Such a
+// record may carry a provisional expiration, before which the node is joining
+// (see NodeLifecycleState_JOINING).
+func (l *Liveness) Provisional() bool {
+	return l.Epoch == 0
+}
+
+// ReportedGone returns whether external failure detector probes reported the
+// node as definitively gone, from more than one node. See ExternalFailure.
+func (l *Liveness) ReportedGone() bool {
+	return l.ExternalFailure.Reported() && len(l.ExternalFailure.CorroboratingNodeIDs) > 0
+}
+
+// Reported returns whether a probe reported the node as gone, whether or not
+// other nodes corroborated the report.
+func (f *ExternalFailure) Reported() bool {
+	return f.Probe != ""
+}
+
+// ReportedBy returns whether the given node reported the node as gone, be it
+// first or to corroborate the report.
+func (f *ExternalFailure) ReportedBy(nodeID roachpb.NodeID) bool {
+	if f.ReporterNodeID == nodeID {
+		return true
+	}
+	for _, id := range f.CorroboratingNodeIDs {
+		if id == nodeID {
+			return true
+		}
+	}
+	return false
+}
+
+// validateExternalFailureReport returns an error unless the given change of
+// the external failure report of a node is the report of the given sender:
+// the first report, or the corroboration of an existing one by a node that
+// hasn't reported the node yet.
+func validateExternalFailureReport(sender roachpb.NodeID, old, new ExternalFailure) error {
+	if new.Equal(old) {
+		return nil
+	}
+	if !old.Reported() {
+		if new.ReporterNodeID != sender || len(new.CorroboratingNodeIDs) > 0 {
+			return errors.Errorf("n%d can only report a node as gone on its own behalf", sender)
+		}
+		return nil
+	}
+	corroborated := old
+	corroborated.CorroboratingNodeIDs = append(
+		append([]roachpb.NodeID(nil), old.CorroboratingNodeIDs...), sender)
+	if old.ReportedBy(sender) || !new.Equal(corroborated) {
+		return errors.Errorf("n%d can only corroborate the report that a node is gone "+
+			"on its own behalf", sender)
+	}
+	return nil
+}
+
+// TimeUntilDead returns the time after which the node is considered dead by the
+// allocator once its liveness expired, at the given time. This is the given
+// default, unless the liveness record declares an override that is in effect,
+// or the node was reported as gone by an external probe, in which case it is
+// considered dead as soon as its liveness expires.
+func (l *Liveness) TimeUntilDead(now hlc.Timestamp, def time.Duration) time.Duration {
+	if l.ReportedGone() {
+		return 0
+	}
+	if now.Less(l.TimeUntilDeadOverride.Expiration) {
+		return time.Duration(l.TimeUntilDeadOverride.TimeUntilDeadNanos)
+	}
+	return def
+}
+
+// RemainingUntilDead returns the time left at the given time until the node
+// is considered dead, given the default dead threshold (see TimeUntilDead).
+// It returns false if the liveness record hasn't expired yet, the node is
+// already dead, or its liveness is unknown.
+func (l *Liveness) RemainingUntilDead(
+	now hlc.Timestamp, deadThreshold time.Duration,
+) (time.Duration, bool) {
+	if l.Expiration.WallTime == 0 || l.Provisional() || l.IsLive(now) {
+		return 0, false
+	}
+	deadAt := l.Expiration.ToTimestamp().AddDuration(l.TimeUntilDead(now, deadThreshold))
+	if !now.Less(deadAt) {
+		return 0, false
+	}
+	return time.Duration(deadAt.WallTime - now.WallTime), true
+}
+
+// StoreDigest returns the digest of the given store recorded in the liveness
+// record, if any.
+func (l *Liveness) StoreDigest(storeID roachpb.StoreID) (StoreDigest, bool) {
+	for _, d := range l.StoreDigests {
+		if d.StoreID == storeID {
+			return d, true
+		}
+	}
+	return StoreDigest{}, false
+}
+
+// MakeStoreDigest returns the digest of the given capacity of the given store.
+func MakeStoreDigest(storeID roachpb.StoreID, c roachpb.StoreCapacity) StoreDigest {
+	return StoreDigest{
+		StoreID:          storeID,
+		Capacity:         c.Capacity,
+		Available:        c.Available,
+		Used:             c.Used,
+		LogicalBytes:     c.LogicalBytes,
+		RangeCount:       c.RangeCount,
+		LeaseCount:       c.LeaseCount,
+		QueriesPerSecond: c.QueriesPerSecond,
+		WritesPerSecond:  c.WritesPerSecond,
+		CPUPerSecond:     c.CPUPerSecond,
+		L0Sublevels:      c.L0Sublevels,
+	}
+}
+
+// ApplyTo overwrites the fields of the given capacity that the digest
+// summarizes, leaving the others untouched.
+func (d *StoreDigest) ApplyTo(c *roachpb.StoreCapacity) {
+	c.Capacity = d.Capacity
+	c.Available = d.Available
+	c.Used = d.Used
+	c.LogicalBytes = d.LogicalBytes
+	c.RangeCount = d.RangeCount
+	c.LeaseCount = d.LeaseCount
+	c.QueriesPerSecond = d.QueriesPerSecond
+	c.WritesPerSecond = d.WritesPerSecond
+	c.CPUPerSecond = d.CPUPerSecond
+	c.L0Sublevels = d.L0Sublevels
+}
+
+// TTL returns the duration for which the last heartbeat of the node extended
+// its liveness record, as recorded by the node. This is the given default for
+// records written by nodes that didn't record it.
+func (l *Liveness) TTL(def time.Duration) time.Duration {
+	if l.TTLNanos != 0 {
+		return time.Duration(l.TTLNanos)
+	}
+	return def
+}
+
+// DecommissionDue returns whether the node is scheduled to start
+// decommissioning at or before the given time, and hasn't started yet.
+func (l *Liveness) DecommissionDue(now hlc.Timestamp) bool {
+	return l.Membership.Active() && !l.DecommissionAt.IsEmpty() && l.DecommissionAt.LessEq(now)
+}
+
+// DrainExpected returns whether the liveness record declares a maintenance
+// window that is in effect at the given time or begins within leadTime of it,
+// or a decommission scheduled to start within leadTime of it. In either case
+// the node is about to stop serving, and should stop accepting new clients.
+func (l *Liveness) DrainExpected(now hlc.Timestamp, leadTime time.Duration) bool {
+	horizon := now.AddDuration(leadTime)
+	if !l.MaintenanceEnd.IsEmpty() && l.MaintenanceStart.LessEq(horizon) && now.Less(l.MaintenanceEnd) {
+		return true
+	}
+	return l.DecommissionDue(horizon)
+}
+
+// Status returns a NodeLivenessStatus enumeration value for the
+// Liveness based on the provided timestamp and threshold.
+//
+// See the note on IsLive() for considerations on what should be passed in as
+// `now`.
+//
+// The timeline of the states that a liveness goes through as time passes after
+// the respective liveness record is written is the following:
+//
+//	-----|-------LIVE---|------UNAVAILABLE---|------DEAD------------> time
+//	     tWrite         tExp                 tExp+threshold
+//
+// Explanation:
+//
+//   - Let's say a node write its liveness record at tWrite. It sets the
+//     Expiration field of the record as tExp=tWrite+livenessThreshold.
+//     The node is considered LIVE (or DECOMMISSIONING or DRAINING).
+//   - At tExp, the IsLive() method starts returning false. The state becomes
+//     UNAVAILABLE (or stays DECOMMISSIONING or DRAINING).
+//   - Once threshold passes, the node is considered DEAD (or DECOMMISSIONED).
+//
+// A node that announced its clean shutdown (see Departing) becomes UNAVAILABLE
+// as soon as it does, without waiting for tExp.
+//
+// NB: There's a bit of discrepancy between what "Decommissioned" represents, as
+// seen by NodeStatusLiveness, and what "Decommissioned" represents as
+// understood by MembershipStatus. Currently it's possible for a live node, that
+// was marked as fully decommissioned, to have a NodeLivenessStatus of
+// "Decommissioning". This was kept this way for backwards compatibility, and
+// ideally we should remove usage of NodeLivenessStatus altogether. See #50707
+// for more details.
+func (l *Liveness) Status(now hlc.Timestamp, deadThreshold time.Duration) NodeLivenessStatus {
+	// NB: A node that hasn't heartbeated yet (i.e. a joining one) has an
+	// UNKNOWN status until its provisional expiration, if any, and is DEAD
+	// after it. This is different than unavailable as it doesn't
+	// transition through being marked as suspect. In unavailable we still won't
+	// transfer leases or replicas to it in this state. A node that is in
+	// UNKNOWN status can immediately transition to Available once it passes a
+	// liveness heartbeat.
+	_, status := deriveLifecycle(l, Connectivity_UNKNOWN, now, deadThreshold)
+	return status
+}
+
+// MaxExpirationLead is the furthest into the future, relative to the current
+// time, that the expiration of a valid liveness record may lie. Nodes extend
+// their liveness by at most a few multiples of the liveness TTL, so a record
+// expiring further out than this was not written by a sane node.
+const MaxExpirationLead = 24 * time.Hour
+
+// Validate performs sanity checks on a liveness record read from KV or
+// gossip, returning an error if the record is invalid and must not be used.
+// The record must identify a node, have a non-negative epoch, and an
+// expiration that is neither negative nor more than MaxExpirationLead past
+// the given time.
+func (l *Liveness) Validate(now hlc.Timestamp) error {
+	if l.NodeID == 0 {
+		return errors.Errorf("liveness record has no node ID: %s", l)
+	}
+	if l.Epoch < 0 {
+		return errors.Errorf("liveness record of n%d has negative epoch %d", l.NodeID, l.Epoch)
+	}
+	if l.Expiration.WallTime < 0 || l.Expiration.Logical < 0 {
+		return errors.Errorf("liveness record of n%d has negative expiration %s",
+			l.NodeID, l.Expiration)
+	}
+	if horizon := now.AddDuration(MaxExpirationLead); horizon.Less(l.Expiration.ToTimestamp()) {
+		return errors.Errorf("liveness record of n%d expires at %s, more than %s after %s",
+			l.NodeID, l.Expiration, MaxExpirationLead, now)
+	}
+	return nil
+}
+
 // Compare returns an integer comparing two pieces of liveness information,
 // based on which liveness information is more recent.
 func (l *Liveness) Compare(o Liveness) int {
@@ -94,6 +363,132 @@ func (c MembershipStatus) String() string {
 	}
 }
 
+// known returns whether the membership status is one this binary knows how to
+// interpret. A new membership status must be added here, and gated on a
+// cluster version in ValidateMembershipStatus and Normalize, since nodes
+// running older binaries cannot interpret it.
+func (c MembershipStatus) known() bool {
+	switch c {
+	case MembershipStatus_ACTIVE, MembershipStatus_DECOMMISSIONING, MembershipStatus_DECOMMISSIONED:
+		return true
+	default:
+		return false
+	}
+}
+
+// SafeEquivalent returns the membership status that should be used in place of
+// c when c is not known to this binary, which can happen when reading a
+// liveness record written by a newer binary. Unknown statuses are treated as
+// ACTIVE: the node is still a member of the cluster, and treating it as
+// decommissioning would trigger replica movement that is hard to undo.
+func (c MembershipStatus) SafeEquivalent() MembershipStatus {
+	if !c.known() {
+		return MembershipStatus_ACTIVE
+	}
+	return c
+}
+
+// ValidateMembershipStatus returns an error if the given membership status
+// cannot be written, because this binary doesn't know about it.
+func ValidateMembershipStatus(c MembershipStatus) error {
+	if !c.known() {
+		return status.Errorf(codes.InvalidArgument, "unknown membership status %d", int32(c))
+	}
+	return nil
+}
+
+// SanitizeMembership replaces the membership status of the liveness record
+// with its safe equivalent, if it is not known to this binary.
+func (l *Liveness) SanitizeMembership() {
+	l.Membership = l.Membership.SafeEquivalent()
+}
+
+// fieldMinVersion maps the fields of the liveness record whose interpretation
+// is gated on a cluster version to that version, along with a function copying
+// them from one record to another. Until the version is active, some nodes may
+// run binaries that don't know about the field, so a value found in a record
+// (e.g. written by a node running a newer binary before the upgrade was
+// finalized) is stripped rather than acted upon by only part of the cluster.
+//
+// Any new version-gated field must be added here.
+var fieldMinVersion = []struct {
+	key  clusterversion.Key
+	copy func(dst, src *Liveness)
+}{
+	{clusterversion.V23_2_LivenessRecordFields, func(dst, src *Liveness) { dst.TTLNanos = src.TTLNanos }},
+	{clusterversion.V23_2_LivenessRecordFields, func(dst, src *Liveness) { dst.StickyDrain = src.StickyDrain }},
+	{clusterversion.V23_2_LivenessRecordFields, func(dst, src *Liveness) { dst.LastUnavailable = src.LastUnavailable }},
+	{clusterversion.V23_2_LivenessRecordFields, func(dst, src *Liveness) { dst.Tags = src.Tags }},
+	{clusterversion.V23_2_LivenessRecordFields, func(dst, src *Liveness) { dst.Departing = src.Departing }},
+	{clusterversion.V23_2_LivenessRecordFields, func(dst, src *Liveness) { dst.LastEpochIncrement = src.LastEpochIncrement }},
+	{clusterversion.V23_2_LivenessRecordFields, func(dst, src *Liveness) { dst.LastDrain = src.LastDrain }},
+	{clusterversion.V23_2_LivenessRecordFields, func(dst, src *Liveness) { dst.HeartbeatFailures = src.HeartbeatFailures }},
+	{clusterversion.V23_2_LivenessRecordFields, func(dst, src *Liveness) { dst.ExternalFailure = src.ExternalFailure }},
+	{clusterversion.V23_2_LivenessRecordFields, func(dst, src *Liveness) { dst.StoreDigests = src.StoreDigests }},
+	{clusterversion.V23_2_LivenessRecordFields, func(dst, src *Liveness) { dst.HeartbeatTimestamp = src.HeartbeatTimestamp }},
+	{clusterversion.V23_2_LivenessRecordFields, func(dst, src *Liveness) { dst.HeartbeatSeq = src.HeartbeatSeq }},
+	{clusterversion.V23_2_LivenessRecordFields, func(dst, src *Liveness) { dst.HeartbeatLatencyNanos = src.HeartbeatLatencyNanos }},
+}
+
+// Normalize brings a liveness record decoded from KV or gossip into the form
+// this binary expects, given the active cluster version and the default TTL of
+// liveness records, and should be called whenever a liveness record is
+// decoded:
+//
+//   - values this binary doesn't know about, written by newer binaries, are
+//     replaced by their safe equivalents (see SanitizeMembership).
+//   - fields that can't be used at the active cluster version are stripped
+//     (see fieldMinVersion). A nil version skips this, e.g. in tests.
+//   - fields missing from records written by older binaries, or stripped
+//     above, are filled with their defaults (see fillDefaults). A zero TTL
+//     skips this.
+//
+// Nodes writing back a normalized record of another node need to undo this
+// first, see Denormalize.
+func (l *Liveness) Normalize(
+	ctx context.Context, version clusterversion.Handle, defaultTTL time.Duration,
+) {
+	l.SanitizeMembership()
+	if version != nil {
+		for _, f := range fieldMinVersion {
+			if !version.IsActive(ctx, f.key) {
+				f.copy(l, &Liveness{})
+			}
+		}
+	}
+	if defaultTTL != 0 {
+		l.fillDefaults(defaultTTL)
+	}
+}
+
+// fillDefaults fills the fields of the liveness record that are missing with
+// the values they default to, given the default TTL of liveness records.
+func (l *Liveness) fillDefaults(defaultTTL time.Duration) {
+	if l.TTLNanos == 0 {
+		l.TTLNanos = defaultTTL.Nanoseconds()
+	}
+	if l.HeartbeatTimestamp.IsEmpty() && !l.Expiration.ToTimestamp().IsEmpty() {
+		// The last heartbeat extended the record by its TTL.
+		l.HeartbeatTimestamp = l.Expiration.ToTimestamp().AddDuration(-time.Duration(l.TTLNanos))
+	}
+}
+
+// Denormalize undoes what Normalize did to the given record of another node,
+// decoded from raw, in l, an update of that record: the fields Normalize
+// changed and the update left alone are restored to their values in raw. The
+// other nodes may only update some of the fields of the record of a node (see
+// ValidateUpdateBy), so they need to write back the rest as they found them.
+func (l *Liveness) Denormalize(normalized, raw Liveness) {
+	for _, f := range fieldMinVersion {
+		var updated, found Liveness
+		f.copy(&updated, l)
+		f.copy(&found, &normalized)
+		if updated.Equal(found) {
+			f.copy(l, &raw)
+		}
+	}
+}
+
 // ValidateTransition validates transitions of the liveness record,
 // returning an error if the proposed transition is invalid. Ignoring no-ops
 // (which also includes decommissioning a decommissioned node) the valid state
@@ -103,10 +498,11 @@ func (c MembershipStatus) String() string {
 //	Active           => Decommissioning
 //	Decommissioning  => Decommissioned
 //
-// This returns an error if the transition is invalid, and false if the
-// transition is unnecessary (since it would be a no-op).
+// See membershipTransitions for the authoritative list. This returns an error
+// if the transition is invalid, and false if the transition is unnecessary
+// (since it would be a no-op).
 func ValidateTransition(old Liveness, newStatus MembershipStatus) (bool, error) {
-	if (old == Liveness{}) {
+	if old.Equal(Liveness{}) {
 		return false, errors.AssertionFailedf("invalid old liveness record; found to be empty")
 	}
 
@@ -115,27 +511,269 @@ func ValidateTransition(old Liveness, newStatus MembershipStatus) (bool, error)
 		return false, nil
 	}
 
-	if old.Membership.Decommissioned() && newStatus.Decommissioning() {
-		// No-op as it would just move directly back to decommissioned.
-		return false, nil
+	if t, ok := lookupMembershipTransition(old.Membership, newStatus); ok {
+		return !t.NoOp, nil
 	}
 
-	if newStatus.Active() && !old.Membership.Decommissioning() {
-		err := fmt.Sprintf("can only recommission a decommissioning node; n%d found to be %s",
+	var err string
+	switch {
+	case newStatus.Active():
+		err = fmt.Sprintf("can only recommission a decommissioning node; n%d found to be %s",
 			old.NodeID, old.Membership.String())
-		return false, status.Error(codes.FailedPrecondition, err)
+	case newStatus.Decommissioned():
+		err = fmt.Sprintf("can only fully decommission an already decommissioning node; n%d found to be %s",
+			old.NodeID, old.Membership.String())
+	case !newStatus.known():
+		err = fmt.Sprintf("unknown membership status %d", int32(newStatus))
+	default:
+		err = fmt.Sprintf("cannot change the membership of n%d from %s to %s",
+			old.NodeID, old.Membership.String(), newStatus.String())
 	}
+	return false, status.Error(codes.FailedPrecondition, err)
+}
 
-	// We don't assert on the new membership being "decommissioning" as all
-	// previous states are valid (again, consider no-ops).
+// HeartbeatTelemetry is what a node reports about itself in its liveness
+// record with every heartbeat. Unlike the other fields a heartbeat may set,
+// e.g. the TTL or the lease shedding hint, it has no bearing on how the other
+// nodes treat the node's leases or membership, which is what allows a
+// heartbeat aggregator to write it on behalf of the node (see IsRenewal).
+//
+// The heartbeat sets these fields through Liveness.SetHeartbeatTelemetry, so
+// that a field added here is part of renewals right away.
+type HeartbeatTelemetry struct {
+	BinaryVersion         roachpb.Version
+	ActiveVersion         roachpb.Version
+	MaxClockOffsetNanos   int64
+	MemoryPressure        bool
+	HeartbeatFailures     HeartbeatFailures
+	StoreDigests          []StoreDigest
+	HeartbeatTimestamp    hlc.Timestamp
+	HeartbeatSeq          int64
+	HeartbeatLatencyNanos int64
+}
 
-	if newStatus.Decommissioned() && !old.Membership.Decommissioning() {
-		err := fmt.Sprintf("can only fully decommission an already decommissioning node; n%d found to be %s",
-			old.NodeID, old.Membership.String())
-		return false, status.Error(codes.FailedPrecondition, err)
+// HeartbeatTelemetry returns the heartbeat telemetry recorded in the record.
+func (l *Liveness) HeartbeatTelemetry() HeartbeatTelemetry {
+	return HeartbeatTelemetry{
+		BinaryVersion:         l.BinaryVersion,
+		ActiveVersion:         l.ActiveVersion,
+		MaxClockOffsetNanos:   l.MaxClockOffsetNanos,
+		MemoryPressure:        l.MemoryPressure,
+		HeartbeatFailures:     l.HeartbeatFailures,
+		StoreDigests:          l.StoreDigests,
+		HeartbeatTimestamp:    l.HeartbeatTimestamp,
+		HeartbeatSeq:          l.HeartbeatSeq,
+		HeartbeatLatencyNanos: l.HeartbeatLatencyNanos,
+	}
+}
+
+// SetHeartbeatTelemetry replaces the heartbeat telemetry recorded in the
+// record.
+func (l *Liveness) SetHeartbeatTelemetry(t HeartbeatTelemetry) {
+	l.BinaryVersion = t.BinaryVersion
+	l.ActiveVersion = t.ActiveVersion
+	l.MaxClockOffsetNanos = t.MaxClockOffsetNanos
+	l.MemoryPressure = t.MemoryPressure
+	l.HeartbeatFailures = t.HeartbeatFailures
+	l.StoreDigests = t.StoreDigests
+	l.HeartbeatTimestamp = t.HeartbeatTimestamp
+	l.HeartbeatSeq = t.HeartbeatSeq
+	l.HeartbeatLatencyNanos = t.HeartbeatLatencyNanos
+}
+
+// IsRenewal returns whether new only extends the expiration of old and
+// refreshes its heartbeat telemetry, as most heartbeats do. Only renewals are
+// written by heartbeat aggregators on behalf of other nodes; the heartbeats
+// that also change e.g. the TTL of the record or clear its maintenance window
+// are written by the node itself.
+func IsRenewal(old, new Liveness) bool {
+	if !old.Expiration.Less(new.Expiration) {
+		return false
+	}
+	renewed := old
+	renewed.Expiration = new.Expiration
+	renewed.SetHeartbeatTelemetry(new.HeartbeatTelemetry())
+	return new.Equal(renewed)
+}
+
+// ValidateUpdateBy returns an error if the given node is not allowed to replace
+// the liveness record old (nil if there is none) with new at the given
+// timestamp. A node may write its own record freely. Other nodes may only:
+//
+//   - create a record, which happens when a node ID is allocated to a joining
+//     node;
+//   - increment the epoch of a record that has expired, leaving it otherwise
+//     unchanged but for the attribution of the increment, which must name the
+//     sender if it names a node;
+//   - change the administrative fields of a record (membership, reason,
+//     maintenance window, scheduled decommission, decommission trace,
+//     membership change lock, last membership change, time until dead
+//     override and tags), leaving the epoch, expiration and draining status untouched;
+//   - report the node as gone on behalf of an external failure detector probe,
+//     or corroborate such a report, which is an administrative change too, as
+//     long as the sender reports on its own behalf.
+//
+// A zero sender, i.e. an update whose origin isn't known, may only create a
+// record.
+func ValidateUpdateBy(
+	sender roachpb.NodeID, old *Liveness, new Liveness, now hlc.Timestamp,
+) error {
+	if sender == new.NodeID || old == nil {
+		return nil
+	}
+	if sender == 0 {
+		return errors.Errorf("liveness record of n%d cannot be updated by an unknown node",
+			new.NodeID)
+	}
+	if old.NodeID != new.NodeID {
+		return errors.AssertionFailedf("liveness update changes node ID from n%d to n%d",
+			old.NodeID, new.NodeID)
+	}
+
+	// Epoch increment.
+	incremented := *old
+	incremented.Epoch++
+	incremented.LastEpochIncrement = new.LastEpochIncrement
+	if new.Equal(incremented) {
+		if old.IsLive(now) {
+			return errors.Errorf("n%d cannot increment the epoch of live node n%d",
+				sender, new.NodeID)
+		}
+		if incrementer := new.LastEpochIncrement.IncrementerNodeID; incrementer != 0 && incrementer != sender {
+			return errors.Errorf("n%d cannot increment the epoch of n%d on behalf of n%d",
+				sender, new.NodeID, incrementer)
+		}
+		return nil
+	}
+
+	// Administrative change.
+	administrative := *old
+	administrative.Membership = new.Membership
+	administrative.Reason = new.Reason
+	administrative.MaintenanceStart = new.MaintenanceStart
+	administrative.MaintenanceEnd = new.MaintenanceEnd
+	administrative.DecommissionAt = new.DecommissionAt
+	administrative.DecommissionTrace = new.DecommissionTrace
+	administrative.MembershipChangeLock = new.MembershipChangeLock
+	administrative.LastMembershipChange = new.LastMembershipChange
+	administrative.TimeUntilDeadOverride = new.TimeUntilDeadOverride
+	administrative.Tags = new.Tags
+	administrative.ExternalFailure = new.ExternalFailure
+	if new.Equal(administrative) {
+		return validateExternalFailureReport(sender, old.ExternalFailure, new.ExternalFailure)
+	}
+
+	return errors.Errorf("n%d is not allowed to update the liveness record of n%d "+
+		"(epoch %d -> %d, expiration %s -> %s, draining %t -> %t)",
+		sender, new.NodeID, old.Epoch, new.Epoch, old.Expiration, new.Expiration,
+		old.Draining, new.Draining)
+}
+
+// MakeDecommissionTrace returns the DecommissionTrace identifying the span
+// described by the given TraceInfo.
+func MakeDecommissionTrace(ti *tracingpb.TraceInfo) DecommissionTrace {
+	t := DecommissionTrace{
+		TraceID: uint64(ti.TraceID),
+		SpanID:  uint64(ti.ParentSpanID),
+	}
+	if ti.Otel != nil && len(ti.Otel.TraceID) == 16 && len(ti.Otel.SpanID) == 8 {
+		t.OtelTraceIDHigh = binary.BigEndian.Uint64(ti.Otel.TraceID[:8])
+		t.OtelTraceIDLow = binary.BigEndian.Uint64(ti.Otel.TraceID[8:])
+		t.OtelSpanID = binary.BigEndian.Uint64(ti.Otel.SpanID)
+	}
+	return t
+}
+
+// Empty returns whether the DecommissionTrace doesn't identify any span.
+func (t DecommissionTrace) Empty() bool {
+	return t.TraceID == 0 && t.OtelTraceIDHigh == 0 && t.OtelTraceIDLow == 0
+}
+
+// TraceInfo returns the TraceInfo of the span identified by the
+// DecommissionTrace, suitable for creating children of that span with
+// tracing.WithRemoteParentFromTraceInfo. The children don't have their
+// recording collected by the span, which has likely finished by then.
+func (t DecommissionTrace) TraceInfo() *tracingpb.TraceInfo {
+	ti := &tracingpb.TraceInfo{
+		TraceID:       tracingpb.TraceID(t.TraceID),
+		ParentSpanID:  tracingpb.SpanID(t.SpanID),
+		RecordingMode: tracingpb.RecordingMode_OFF,
+	}
+	if t.OtelTraceIDHigh != 0 || t.OtelTraceIDLow != 0 {
+		ti.Otel = &tracingpb.TraceInfo_OtelInfo{
+			TraceID: make([]byte, 16),
+			SpanID:  make([]byte, 8),
+		}
+		binary.BigEndian.PutUint64(ti.Otel.TraceID[:8], t.OtelTraceIDHigh)
+		binary.BigEndian.PutUint64(ti.Otel.TraceID[8:], t.OtelTraceIDLow)
+		binary.BigEndian.PutUint64(ti.Otel.SpanID, t.OtelSpanID)
+	}
+	return ti
+}
+
+// MembershipChangeOperation returns the name of the membership change
+// operation that moves a node to the given membership status, as recorded in
+// its MembershipChangeLock. The steps of a decommission share the name.
+func MembershipChangeOperation(targetStatus MembershipStatus) string {
+	if targetStatus.Active() {
+		return "recommission"
+	}
+	return "decommission"
+}
+
+// HeldAt returns whether the lock is held at the given time.
+func (l MembershipChangeLock) HeldAt(now hlc.Timestamp) bool {
+	return now.Less(l.Expiration)
+}
+
+// Running returns whether the drain is still running.
+func (d DrainOperation) Running() bool {
+	return d.Status == DrainOperation_RUNNING
+}
+
+// StartRequest records a drain request made at the given time. The request
+// starts a new drain, with the next ID, unless a drain is running already.
+func (d *DrainOperation) StartRequest(reason string, now hlc.Timestamp) {
+	if !d.Running() {
+		*d = DrainOperation{
+			ID:     d.ID + 1,
+			Status: DrainOperation_RUNNING,
+			Reason: reason,
+			Start:  now,
+		}
 	}
+	d.Requests++
+	d.LastUpdate = now
+}
+
+// FinishRequest records the outcome of the last request of the running drain:
+// the drain fails if the request failed, and succeeds if the request left
+// nothing to shed away from the node.
+func (d *DrainOperation) FinishRequest(
+	remaining uint64, description string, err error, now hlc.Timestamp,
+) {
+	if !d.Running() {
+		return
+	}
+	d.Remaining = remaining
+	d.RemainingDescription = description
+	switch {
+	case err != nil:
+		d.Status = DrainOperation_FAILED
+		d.Error = err.Error()
+	case remaining == 0:
+		d.Status = DrainOperation_SUCCEEDED
+	}
+	d.LastUpdate = now
+}
 
-	return true, nil
+// End ends the running drain, if any, with the given status, e.g. CANCELED.
+func (d *DrainOperation) End(status DrainOperation_Status, now hlc.Timestamp) {
+	if !d.Running() {
+		return
+	}
+	d.Status = status
+	d.LastUpdate = now
 }
 
 // IsLiveMapEntry encapsulates data about current liveness for a
@@ -143,7 +781,164 @@ func ValidateTransition(old Liveness, newStatus MembershipStatus) (bool, error)
 type IsLiveMapEntry struct {
 	Liveness
 	IsLive bool
+	// LivenessStatus is the status derived from the liveness record, taking
+	// the dead threshold into account. It tells a node that is temporarily
+	// dead (DEAD) from one that left the cluster for good (DECOMMISSIONED).
+	LivenessStatus NodeLivenessStatus
 }
 
 // IsLiveMap is a type alias for a map from NodeID to IsLiveMapEntry.
 type IsLiveMap map[roachpb.NodeID]IsLiveMapEntry
+
+// IsLiveMapCounts is the number of nodes in each liveness status. Each node is
+// counted as either Live, Unavailable, Dead or Decommissioned.
+type IsLiveMapCounts struct {
+	// Live is the number of live nodes, with a LIVE, DRAINING or
+	// DECOMMISSIONING status. Draining and Decommissioning are the number of
+	// those that are draining and being decommissioned respectively.
+	Live            int `json:"live"`
+	Draining        int `json:"draining"`
+	Decommissioning int `json:"decommissioning"`
+	// Unavailable is the number of nodes that aren't live but aren't dead yet,
+	// including those that haven't heartbeated yet.
+	Unavailable int `json:"unavailable"`
+	// Dead is the number of dead nodes that are still members, and
+	// Decommissioned the number of dead nodes that are no longer members:
+	// unlike the other dead nodes, these won't come back.
+	Dead           int `json:"dead"`
+	Decommissioned int `json:"decommissioned"`
+}
+
+// add counts a node with the given status.
+func (c *IsLiveMapCounts) add(status NodeLivenessStatus) {
+	switch status {
+	case NodeLivenessStatus_LIVE:
+		c.Live++
+	case NodeLivenessStatus_DRAINING:
+		c.Live++
+		c.Draining++
+	case NodeLivenessStatus_DECOMMISSIONING:
+		c.Live++
+		c.Decommissioning++
+	case NodeLivenessStatus_DEAD:
+		c.Dead++
+	case NodeLivenessStatus_DECOMMISSIONED:
+		c.Decommissioned++
+	default:
+		c.Unavailable++
+	}
+}
+
+// CountStatuses counts the nodes in each status, given the liveness status of
+// each node, e.g. as derived for serverpb.LivenessResponse.
+func CountStatuses(statuses map[roachpb.NodeID]NodeLivenessStatus) IsLiveMapCounts {
+	var c IsLiveMapCounts
+	for _, status := range statuses {
+		c.add(status)
+	}
+	return c
+}
+
+// CountByStatus counts the nodes of the map in each status in a single pass,
+// from the LivenessStatus of their entries. Note that the map doesn't include
+// the nodes that were fully decommissioned, see CountStatuses to count those.
+func (m IsLiveMap) CountByStatus() IsLiveMapCounts {
+	var c IsLiveMapCounts
+	for _, entry := range m {
+		c.add(entry.LivenessStatus)
+	}
+	return c
+}
+
+// TargetIntent is the purpose for which IsLiveMapEntry.IsEligibleTarget
+// selects nodes. Lease and replica placement go through the store pool, and
+// DistSQL planning through the health checks of the physical planner, rather
+// than through here.
+type TargetIntent int
+
+const (
+	// TargetJobCoordination selects the nodes that may adopt a job or
+	// coordinate a long-running flow such as a changefeed.
+	TargetJobCoordination TargetIntent = iota
+)
+
+// IsEligibleTarget returns whether the node is an eligible target for the
+// given intent at the given time. This is the case of the nodes in the active
+// lifecycle state that weren't recently suspect (see IsSuspect), since jobs
+// would have to be moved away from them again.
+func (e IsLiveMapEntry) IsEligibleTarget(
+	intent TargetIntent, now hlc.Timestamp, suspectDuration time.Duration,
+) bool {
+	// Only live nodes are active, so the dead threshold is irrelevant.
+	return e.LifecycleState(now, 0 /* deadThreshold */) == NodeLifecycleState_ACTIVE &&
+		!e.IsSuspect(now, suspectDuration)
+}
+
+// HasEligibleTarget returns whether any node of the map is an eligible target
+// for the given intent at the given time. See IsLiveMapEntry.IsEligibleTarget.
+func (m IsLiveMap) HasEligibleTarget(
+	intent TargetIntent, now hlc.Timestamp, suspectDuration time.Duration,
+) bool {
+	for _, entry := range m {
+		if entry.IsEligibleTarget(intent, now, suspectDuration) {
+			return true
+		}
+	}
+	return false
+}
+
+// LivenessView is a snapshot of the liveness of the nodes, bundled with the
+// time and dead threshold it was taken with. Answers derived from a view are
+// consistent with each other, however long after the snapshot they are asked
+// for, and don't require the caller to carry the clock and threshold around.
+type LivenessView struct {
+	IsLiveMap
+	// Now is the time at which the snapshot was taken.
+	Now hlc.Timestamp
+	// DeadThreshold is the default duration after which a node that stopped
+	// heartbeating is considered dead. Nodes may override it in their
+	// liveness record (see TimeUntilDead).
+	DeadThreshold time.Duration
+}
+
+// Status returns the liveness status of the given node as of the snapshot,
+// or UNKNOWN if the node isn't part of it (e.g. because it was removed from
+// the cluster).
+func (v LivenessView) Status(nodeID roachpb.NodeID) NodeLivenessStatus {
+	entry, ok := v.IsLiveMap[nodeID]
+	if !ok {
+		return NodeLivenessStatus_UNKNOWN
+	}
+	return entry.Status(v.Now, entry.TimeUntilDead(v.Now, v.DeadThreshold))
+}
+
+// IsLive returns whether the given node was live as of the snapshot. Nodes
+// that aren't part of it aren't live.
+func (v LivenessView) IsLive(nodeID roachpb.NodeID) bool {
+	entry, ok := v.IsLiveMap[nodeID]
+	return ok && entry.IsLive
+}
+
+// IsDead returns whether the given node was dead as of the snapshot, i.e. its
+// liveness had expired for longer than its dead threshold. Nodes that aren't
+// part of the snapshot are not known to be dead.
+func (v LivenessView) IsDead(nodeID roachpb.NodeID) bool {
+	entry, ok := v.IsLiveMap[nodeID]
+	return ok && entry.IsDead(v.Now, entry.TimeUntilDead(v.Now, v.DeadThreshold))
+}
+
+// LifecycleState returns the lifecycle state of the given node as of the
+// snapshot. Nodes that aren't part of it are reported as joining.
+func (v LivenessView) LifecycleState(nodeID roachpb.NodeID) NodeLifecycleState {
+	entry, ok := v.IsLiveMap[nodeID]
+	if !ok {
+		return NodeLifecycleState_JOINING
+	}
+	return entry.LifecycleState(v.Now, entry.TimeUntilDead(v.Now, v.DeadThreshold))
+}
+
+// HasEligibleTarget is like IsLiveMap.HasEligibleTarget, as of the time of
+// the snapshot.
+func (v LivenessView) HasEligibleTarget(intent TargetIntent, suspectDuration time.Duration) bool {
+	return v.IsLiveMap.HasEligibleTarget(intent, v.Now, suspectDuration)
+}
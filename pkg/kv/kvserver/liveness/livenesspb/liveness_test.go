@@ -0,0 +1,726 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package livenesspb
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/clusterversion"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/tracing/tracingpb"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateMembershipStatus(t *testing.T) {
+	for _, c := range []MembershipStatus{
+		MembershipStatus_ACTIVE,
+		MembershipStatus_DECOMMISSIONING,
+		MembershipStatus_DECOMMISSIONED,
+	} {
+		require.Equal(t, c, c.SafeEquivalent())
+		require.NoError(t, ValidateMembershipStatus(c))
+	}
+
+	// A status this binary doesn't know about, e.g. one written by a newer
+	// binary, is read as ACTIVE and can't be written.
+	unknown := MembershipStatus(42)
+	require.Equal(t, MembershipStatus_ACTIVE, unknown.SafeEquivalent())
+	require.Error(t, ValidateMembershipStatus(unknown))
+
+	l := Liveness{NodeID: 1, Membership: unknown}
+	l.SanitizeMembership()
+	require.Equal(t, MembershipStatus_ACTIVE, l.Membership)
+}
+
+func TestNormalize(t *testing.T) {
+	ctx := context.Background()
+
+	// A status written by a newer binary is read as ACTIVE, with or without a
+	// version.
+	for _, version := range []clusterversion.Handle{
+		nil, cluster.MakeTestingClusterSettings().Version,
+	} {
+		l := Liveness{NodeID: 1, Membership: MembershipStatus(42)}
+		l.Normalize(ctx, version, 0 /* defaultTTL */)
+		require.Equal(t, MembershipStatus_ACTIVE, l.Membership)
+	}
+
+	// Version-gated fields are stripped until their version is active.
+	oldSt := cluster.MakeTestingClusterSettingsWithVersions(
+		clusterversion.TestingBinaryVersion, clusterversion.TestingBinaryMinSupportedVersion,
+		false /* initializeVersion */)
+	require.NoError(t, clusterversion.Initialize(ctx,
+		clusterversion.TestingBinaryMinSupportedVersion, &oldSt.SV))
+	raw := Liveness{NodeID: 1, Departing: true, TTLNanos: 5}
+	l := raw
+	l.Normalize(ctx, oldSt.Version, 0 /* defaultTTL */)
+	require.Equal(t, Liveness{NodeID: 1}, l)
+
+	l = raw
+	l.Normalize(ctx, cluster.MakeTestingClusterSettings().Version, 0 /* defaultTTL */)
+	require.Equal(t, raw, l)
+
+	// Missing fields are filled with their defaults, including those that were
+	// stripped.
+	const ttl = 10 * time.Second
+	raw.Expiration = hlc.LegacyTimestamp{WallTime: (20 * time.Second).Nanoseconds()}
+	normalized := raw
+	normalized.Normalize(ctx, oldSt.Version, ttl)
+	require.Equal(t, Liveness{
+		NodeID:             1,
+		Expiration:         raw.Expiration,
+		TTLNanos:           ttl.Nanoseconds(),
+		HeartbeatTimestamp: hlc.Timestamp{WallTime: (10 * time.Second).Nanoseconds()},
+	}, normalized)
+
+	// Updates of normalized records get the fields the update left alone back
+	// as they were found, but keep those it changed.
+	update := normalized
+	update.Epoch++
+	update.Tags = map[string]string{"a": "b"}
+	update.Denormalize(normalized, raw)
+	exp := raw
+	exp.Epoch++
+	exp.Tags = update.Tags
+	require.Equal(t, exp, update)
+}
+
+func TestValidateUpdateBy(t *testing.T) {
+	now := hlc.Timestamp{WallTime: 100}
+	old := Liveness{
+		NodeID:     2,
+		Epoch:      3,
+		Expiration: hlc.LegacyTimestamp{WallTime: 50},
+		Membership: MembershipStatus_ACTIVE,
+	}
+	heartbeat := old
+	heartbeat.Expiration = hlc.LegacyTimestamp{WallTime: 200}
+	incremented := old
+	incremented.Epoch++
+	incrementedWithReason := incremented
+	incrementedWithReason.LastEpochIncrement = EpochIncrement{
+		Epoch: incremented.Epoch, IncrementerNodeID: 1, Reason: "lease acquisition on r42",
+	}
+	decommissioning := old
+	decommissioning.Membership = MembershipStatus_DECOMMISSIONING
+	decommissioning.Reason = "TICKET-1"
+	draining := old
+	draining.Draining = true
+	reportedGone := old
+	reportedGone.ExternalFailure = ExternalFailure{
+		Probe: "aws", Reason: "instance i-0abc terminated", ReporterNodeID: 1,
+	}
+	corroborated := reportedGone
+	corroborated.ExternalFailure.CorroboratingNodeIDs = []roachpb.NodeID{3}
+
+	testCases := []struct {
+		name   string
+		sender int32
+		old    *Liveness
+		new    Liveness
+		expErr string
+	}{
+		{name: "own heartbeat", sender: 2, old: &old, new: heartbeat},
+		{name: "unknown sender", sender: 0, old: &old, new: heartbeat,
+			expErr: "cannot be updated by an unknown node"},
+		{name: "create by unknown sender", sender: 0, old: nil, new: old},
+		{name: "create", sender: 1, old: nil, new: old},
+		{name: "epoch increment", sender: 1, old: &old, new: incremented},
+		{name: "epoch increment with reason", sender: 1, old: &old, new: incrementedWithReason},
+		{name: "epoch increment on behalf of another node", sender: 3, old: &old, new: incrementedWithReason,
+			expErr: "n3 cannot increment the epoch of n2 on behalf of n1"},
+		{name: "epoch increment of live node", sender: 1, old: &heartbeat,
+			new:    func() Liveness { l := heartbeat; l.Epoch++; return l }(),
+			expErr: "cannot increment the epoch of live node n2"},
+		{name: "membership change", sender: 1, old: &old, new: decommissioning},
+		{name: "external failure", sender: 1, old: &old, new: reportedGone},
+		{name: "external failure on behalf of another node", sender: 3, old: &old, new: reportedGone,
+			expErr: "n3 can only report a node as gone on its own behalf"},
+		{name: "corroborated external failure", sender: 3, old: &reportedGone, new: corroborated},
+		{name: "corroborated external failure on behalf of another node", sender: 4,
+			old: &reportedGone, new: corroborated,
+			expErr: "n4 can only corroborate the report that a node is gone on its own behalf"},
+		{name: "external failure corroborated by its reporter", sender: 1, old: &reportedGone,
+			new: func() Liveness {
+				l := reportedGone
+				l.ExternalFailure.CorroboratingNodeIDs = []roachpb.NodeID{1}
+				return l
+			}(),
+			expErr: "n1 can only corroborate the report that a node is gone on its own behalf"},
+		{name: "external failure reported anew", sender: 3, old: &reportedGone,
+			new: func() Liveness {
+				l := reportedGone
+				l.ExternalFailure.ReporterNodeID = 3
+				return l
+			}(),
+			expErr: "n3 can only corroborate the report that a node is gone on its own behalf"},
+		{name: "foreign heartbeat", sender: 1, old: &old, new: heartbeat,
+			expErr: "n1 is not allowed to update the liveness record of n2"},
+		{name: "foreign drain", sender: 1, old: &old, new: draining,
+			expErr: "n1 is not allowed to update the liveness record of n2"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateUpdateBy(roachpb.NodeID(tc.sender), tc.old, tc.new, now)
+			if tc.expErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.ErrorContains(t, err, tc.expErr)
+			}
+		})
+	}
+}
+
+func TestIsRenewal(t *testing.T) {
+	old := Liveness{
+		NodeID:           2,
+		Epoch:            3,
+		Expiration:       hlc.LegacyTimestamp{WallTime: 50},
+		MaintenanceStart: hlc.Timestamp{WallTime: 10},
+		MaintenanceEnd:   hlc.Timestamp{WallTime: 40},
+	}
+	old.PlannedRestartUntil = hlc.Timestamp{WallTime: 45}
+	renewed := old
+	renewed.Expiration = hlc.LegacyTimestamp{WallTime: 100}
+	require.True(t, IsRenewal(old, renewed))
+
+	// The heartbeat telemetry is refreshed by renewals.
+	reported := renewed
+	reported.SetHeartbeatTelemetry(HeartbeatTelemetry{
+		BinaryVersion:         roachpb.Version{Major: 23, Minor: 2},
+		ActiveVersion:         roachpb.Version{Major: 23, Minor: 1},
+		MaxClockOffsetNanos:   250,
+		MemoryPressure:        true,
+		HeartbeatFailures:     HeartbeatFailures{Consecutive: 2, LastError: "boom"},
+		StoreDigests:          []StoreDigest{{StoreID: 1, RangeCount: 10}},
+		HeartbeatTimestamp:    hlc.Timestamp{WallTime: 60},
+		HeartbeatSeq:          7,
+		HeartbeatLatencyNanos: 1000,
+	})
+	require.True(t, IsRenewal(old, reported))
+
+	// Anything else that bears on how the other nodes treat the node isn't,
+	// even if a heartbeat of the node itself may change it.
+	for name, update := range map[string]func(l *Liveness){
+		"shed leases": func(l *Liveness) { l.ShedLeases = true },
+		"ttl":         func(l *Liveness) { l.TTLNanos = int64(time.Minute) },
+		"departing":   func(l *Liveness) { l.Departing = true },
+		"draining":    func(l *Liveness) { l.Draining = true },
+		"epoch":       func(l *Liveness) { l.Epoch++ },
+		"maintenance": func(l *Liveness) { l.MaintenanceStart, l.MaintenanceEnd = hlc.Timestamp{}, hlc.Timestamp{} },
+		"restart":     func(l *Liveness) { l.PlannedRestartUntil = hlc.Timestamp{} },
+		"external failure": func(l *Liveness) {
+			l.ExternalFailure = ExternalFailure{Probe: "k8s", Reason: "node not ready"}
+		},
+		"time until dead override": func(l *Liveness) {
+			l.TimeUntilDeadOverride = TimeUntilDeadOverride{TimeUntilDeadNanos: int64(time.Hour)}
+		},
+	} {
+		l := renewed
+		update(&l)
+		require.False(t, IsRenewal(old, l), name)
+	}
+
+	require.False(t, IsRenewal(old, old))
+	require.False(t, IsRenewal(renewed, old))
+}
+
+func TestValidate(t *testing.T) {
+	now := hlc.Timestamp{WallTime: int64(time.Hour)}
+	valid := Liveness{
+		NodeID:     1,
+		Epoch:      2,
+		Expiration: now.AddDuration(9 * time.Second).ToLegacyTimestamp(),
+	}
+	require.NoError(t, valid.Validate(now))
+
+	// A freshly created record has neither an epoch nor an expiration yet.
+	require.NoError(t, (&Liveness{NodeID: 1}).Validate(now))
+
+	noNodeID := valid
+	noNodeID.NodeID = 0
+	require.Error(t, noNodeID.Validate(now))
+
+	negativeEpoch := valid
+	negativeEpoch.Epoch = -1
+	require.Error(t, negativeEpoch.Validate(now))
+
+	negativeExpiration := valid
+	negativeExpiration.Expiration = hlc.LegacyTimestamp{WallTime: -1}
+	require.Error(t, negativeExpiration.Validate(now))
+
+	farFuture := valid
+	farFuture.Expiration = now.AddDuration(MaxExpirationLead + time.Second).ToLegacyTimestamp()
+	require.Error(t, farFuture.Validate(now))
+}
+
+func TestDrainExpected(t *testing.T) {
+	ts := func(sec int64) hlc.Timestamp {
+		return hlc.Timestamp{WallTime: sec * int64(time.Second)}
+	}
+	now := ts(100)
+	const leadTime = 10 * time.Second
+
+	testCases := []struct {
+		name     string
+		l        Liveness
+		expected bool
+	}{
+		{"none", Liveness{}, false},
+		{"maintenance in effect", Liveness{MaintenanceStart: ts(90), MaintenanceEnd: ts(120)}, true},
+		{"maintenance within lead time", Liveness{MaintenanceStart: ts(105), MaintenanceEnd: ts(106)}, true},
+		{"maintenance beyond lead time", Liveness{MaintenanceStart: ts(111), MaintenanceEnd: ts(120)}, false},
+		{"maintenance over", Liveness{MaintenanceStart: ts(80), MaintenanceEnd: ts(100)}, false},
+		{"decommission within lead time", Liveness{DecommissionAt: ts(110)}, true},
+		{"decommission beyond lead time", Liveness{DecommissionAt: ts(111)}, false},
+		{"decommission already started", Liveness{
+			DecommissionAt: ts(105), Membership: MembershipStatus_DECOMMISSIONING,
+		}, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.l.DrainExpected(now, leadTime))
+		})
+	}
+}
+
+func TestDecommissionTrace(t *testing.T) {
+	require.True(t, DecommissionTrace{}.Empty())
+
+	ti := &tracingpb.TraceInfo{
+		TraceID:       1,
+		ParentSpanID:  2,
+		RecordingMode: tracingpb.RecordingMode_OFF,
+	}
+	trace := MakeDecommissionTrace(ti)
+	require.False(t, trace.Empty())
+	require.Equal(t, ti, trace.TraceInfo())
+
+	ti.Otel = &tracingpb.TraceInfo_OtelInfo{
+		TraceID: []byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		SpanID:  []byte{1, 2, 3, 4, 5, 6, 7, 8},
+	}
+	trace = MakeDecommissionTrace(ti)
+	require.Equal(t, ti, trace.TraceInfo())
+
+	// The trace is an administrative field, which other nodes may change.
+	old := Liveness{NodeID: 1, Epoch: 1}
+	l := old
+	l.DecommissionTrace = trace
+	require.NoError(t, ValidateUpdateBy(2, &old, l, hlc.Timestamp{}))
+}
+
+func TestDrainOperation(t *testing.T) {
+	ts := func(wallTime int64) hlc.Timestamp { return hlc.Timestamp{WallTime: wallTime} }
+
+	// Requests made while a drain is running are part of that drain.
+	var d DrainOperation
+	d.StartRequest("restart", ts(1))
+	d.FinishRequest(10, "range lease iterations: 10", nil, ts(2))
+	d.StartRequest("ignored", ts(3))
+	d.FinishRequest(0, "", nil, ts(4))
+	require.Equal(t, DrainOperation{
+		ID:         1,
+		Status:     DrainOperation_SUCCEEDED,
+		Reason:     "restart",
+		Start:      ts(1),
+		LastUpdate: ts(4),
+		Requests:   2,
+	}, d)
+
+	// A request made once the drain is over starts a new one.
+	d.StartRequest("", ts(5))
+	d.FinishRequest(3, "", errors.New("boom"), ts(6))
+	require.Equal(t, DrainOperation{
+		ID:         2,
+		Status:     DrainOperation_FAILED,
+		Start:      ts(5),
+		LastUpdate: ts(6),
+		Requests:   1,
+		Remaining:  3,
+		Error:      "boom",
+	}, d)
+
+	// Only a running drain can be ended.
+	d.End(DrainOperation_INTERRUPTED, ts(7))
+	require.Equal(t, DrainOperation_FAILED, d.Status)
+	d.StartRequest("", ts(8))
+	d.End(DrainOperation_CANCELED, ts(9))
+	require.Equal(t, DrainOperation_CANCELED, d.Status)
+	require.Equal(t, ts(9), d.LastUpdate)
+	d.FinishRequest(0, "", nil, ts(10))
+	require.Equal(t, DrainOperation_CANCELED, d.Status)
+}
+
+func TestNodeVitality(t *testing.T) {
+	const deadThreshold = 50
+	l := Liveness{
+		NodeID:     1,
+		Epoch:      1,
+		Expiration: hlc.LegacyTimestamp{WallTime: 100},
+		Membership: MembershipStatus_ACTIVE,
+	}
+	ts := func(wallTime int64) hlc.Timestamp { return hlc.Timestamp{WallTime: wallTime} }
+
+	testCases := []struct {
+		name          string
+		liveness      Liveness
+		connectivity  Connectivity
+		now           int64
+		expStatus     NodeLivenessStatus
+		expValidUntil hlc.Timestamp
+	}{
+		{name: "live", liveness: l, connectivity: Connectivity_CONNECTED, now: 10,
+			expStatus: NodeLivenessStatus_LIVE, expValidUntil: ts(100)},
+		{name: "live, connectivity unknown", liveness: l, connectivity: Connectivity_UNKNOWN, now: 10,
+			expStatus: NodeLivenessStatus_LIVE, expValidUntil: ts(100)},
+		{name: "live, disconnected", liveness: l, connectivity: Connectivity_DISCONNECTED, now: 10,
+			expStatus: NodeLivenessStatus_UNAVAILABLE, expValidUntil: ts(100)},
+		{name: "live, upcoming maintenance",
+			liveness: func() Liveness {
+				l := l
+				l.MaintenanceStart, l.MaintenanceEnd = ts(20), ts(200)
+				return l
+			}(),
+			connectivity: Connectivity_CONNECTED, now: 10,
+			expStatus: NodeLivenessStatus_LIVE, expValidUntil: ts(20)},
+		{name: "unavailable", liveness: l, connectivity: Connectivity_CONNECTED, now: 120,
+			expStatus: NodeLivenessStatus_UNAVAILABLE, expValidUntil: ts(150)},
+		{name: "dead", liveness: l, connectivity: Connectivity_DISCONNECTED, now: 160,
+			expStatus: NodeLivenessStatus_DEAD, expValidUntil: hlc.MaxTimestamp},
+		{name: "unknown", liveness: Liveness{NodeID: 1}, now: 10,
+			expStatus: NodeLivenessStatus_UNKNOWN, expValidUntil: hlc.MaxTimestamp},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := NodeVitality{Liveness: tc.liveness, Connectivity: tc.connectivity}
+			now := ts(tc.now)
+			require.Equal(t, tc.expStatus, v.Status(now, deadThreshold))
+			require.Equal(t, tc.expValidUntil, v.ValidUntil(now, deadThreshold))
+			require.Equal(t, tc.expStatus == NodeLivenessStatus_LIVE, v.IsLive(now))
+		})
+	}
+}
+
+func TestNodeLifecycleState(t *testing.T) {
+	const deadThreshold = 50
+	ts := func(wallTime int64) hlc.Timestamp { return hlc.Timestamp{WallTime: wallTime} }
+	mk := func(fn func(l *Liveness)) Liveness {
+		l := Liveness{
+			NodeID:     1,
+			Epoch:      1,
+			Expiration: hlc.LegacyTimestamp{WallTime: 100},
+			Membership: MembershipStatus_ACTIVE,
+		}
+		if fn != nil {
+			fn(&l)
+		}
+		return l
+	}
+
+	testCases := []struct {
+		name         string
+		liveness     Liveness
+		connectivity Connectivity
+		now          int64
+		expState     NodeLifecycleState
+		expStatus    NodeLivenessStatus
+	}{
+		{name: "joining", liveness: Liveness{NodeID: 1}, now: 10,
+			expState: NodeLifecycleState_JOINING, expStatus: NodeLivenessStatus_UNKNOWN},
+		{name: "joining, provisional", liveness: mk(func(l *Liveness) { l.Epoch = 0 }), now: 10,
+			expState: NodeLifecycleState_JOINING, expStatus: NodeLivenessStatus_UNKNOWN},
+		// A node that doesn't heartbeat before its provisional expiration failed
+		// to join, and is dead right away.
+		{name: "failed join", liveness: mk(func(l *Liveness) { l.Epoch = 0 }), now: 120,
+			expState: NodeLifecycleState_DEAD, expStatus: NodeLivenessStatus_DEAD},
+		{name: "active", liveness: mk(nil), now: 10,
+			expState: NodeLifecycleState_ACTIVE, expStatus: NodeLivenessStatus_LIVE},
+		{name: "draining", liveness: mk(func(l *Liveness) { l.Draining = true }), now: 10,
+			expState: NodeLifecycleState_DRAINING, expStatus: NodeLivenessStatus_DRAINING},
+		{name: "maintenance",
+			liveness: mk(func(l *Liveness) { l.MaintenanceStart, l.MaintenanceEnd = ts(5), ts(200) }),
+			now:      10,
+			expState: NodeLifecycleState_DRAINING, expStatus: NodeLivenessStatus_DRAINING},
+		{name: "expired", liveness: mk(nil), now: 120,
+			expState: NodeLifecycleState_SUSPECT, expStatus: NodeLivenessStatus_UNAVAILABLE},
+		{name: "departing", liveness: mk(func(l *Liveness) { l.Departing = true }), now: 10,
+			expState: NodeLifecycleState_SUSPECT, expStatus: NodeLivenessStatus_UNAVAILABLE},
+		{name: "disconnected", liveness: mk(nil), connectivity: Connectivity_DISCONNECTED, now: 10,
+			expState: NodeLifecycleState_SUSPECT, expStatus: NodeLivenessStatus_UNAVAILABLE},
+		{name: "draining, disconnected", liveness: mk(func(l *Liveness) { l.Draining = true }),
+			connectivity: Connectivity_DISCONNECTED, now: 10,
+			expState: NodeLifecycleState_SUSPECT, expStatus: NodeLivenessStatus_UNAVAILABLE},
+		{name: "dead", liveness: mk(nil), now: 160,
+			expState: NodeLifecycleState_DEAD, expStatus: NodeLivenessStatus_DEAD},
+		{name: "decommissioning",
+			liveness:     mk(func(l *Liveness) { l.Membership = MembershipStatus_DECOMMISSIONING }),
+			connectivity: Connectivity_DISCONNECTED, now: 10,
+			expState: NodeLifecycleState_DECOMMISSIONING, expStatus: NodeLivenessStatus_DECOMMISSIONING},
+		{name: "decommissioning, expired",
+			liveness: mk(func(l *Liveness) { l.Membership = MembershipStatus_DECOMMISSIONING }),
+			now:      120,
+			expState: NodeLifecycleState_DECOMMISSIONING, expStatus: NodeLivenessStatus_UNAVAILABLE},
+		{name: "decommissioned, live",
+			liveness: mk(func(l *Liveness) { l.Membership = MembershipStatus_DECOMMISSIONED }),
+			now:      10,
+			expState: NodeLifecycleState_DECOMMISSIONED, expStatus: NodeLivenessStatus_DECOMMISSIONING},
+		{name: "decommissioned, dead",
+			liveness: mk(func(l *Liveness) { l.Membership = MembershipStatus_DECOMMISSIONED }),
+			now:      160,
+			expState: NodeLifecycleState_DECOMMISSIONED, expStatus: NodeLivenessStatus_DECOMMISSIONED},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			v := NodeVitality{Liveness: tc.liveness, Connectivity: tc.connectivity}
+			now := ts(tc.now)
+			require.Equal(t, tc.expState, v.LifecycleState(now, deadThreshold))
+			require.Equal(t, tc.expStatus, v.Status(now, deadThreshold))
+			if tc.connectivity != Connectivity_DISCONNECTED {
+				require.Equal(t, tc.expState, tc.liveness.LifecycleState(now, deadThreshold))
+				require.Equal(t, tc.expStatus, tc.liveness.Status(now, deadThreshold))
+			}
+		})
+	}
+}
+
+func TestParseNodeLifecycleState(t *testing.T) {
+	for v := range NodeLifecycleState_name {
+		s := NodeLifecycleState(v)
+		parsed, err := ParseNodeLifecycleState(strings.ToUpper(s.String()))
+		require.NoError(t, err)
+		require.Equal(t, s, parsed)
+	}
+	parsed, err := ParseNodeLifecycleState("Maintenance")
+	require.NoError(t, err)
+	require.Equal(t, NodeLifecycleState_DRAINING, parsed)
+	_, err = ParseNodeLifecycleState("unknown")
+	require.Error(t, err)
+}
+
+func TestMembershipGraph(t *testing.T) {
+	// The graph agrees with ValidateTransition for every pair of statuses.
+	g := MakeMembershipGraph(nil /* current */)
+	edges := make(map[[2]string]MembershipGraphEdge)
+	for _, e := range g.Transitions {
+		edges[[2]string{e.From, e.To}] = e
+	}
+	for _, from := range membershipStates {
+		for _, to := range membershipStates {
+			valid, err := ValidateTransition(Liveness{NodeID: 1, Membership: from}, to)
+			e, ok := edges[[2]string{from.String(), to.String()}]
+			if from == to {
+				require.NoError(t, err)
+				require.False(t, valid)
+				continue
+			}
+			require.Equal(t, ok, err == nil, "%s -> %s", from, to)
+			require.Equal(t, ok && !e.NoOp, valid, "%s -> %s", from, to)
+		}
+	}
+
+	current := MembershipStatus_DECOMMISSIONING
+	require.Equal(t, `digraph membership {
+  "active";
+  "decommissioning" [style=filled];
+  "decommissioned";
+  "active" -> "decommissioning";
+  "decommissioning" -> "active";
+  "decommissioning" -> "decommissioned";
+  "decommissioned" -> "decommissioning" [style=dashed, label="no-op"];
+}
+`, MakeMembershipGraph(&current).DOT())
+}
+
+func TestIsLiveMapCountByStatus(t *testing.T) {
+	m := IsLiveMap{
+		1: {IsLive: true, LivenessStatus: NodeLivenessStatus_LIVE},
+		2: {IsLive: true, LivenessStatus: NodeLivenessStatus_DRAINING},
+		3: {IsLive: true, LivenessStatus: NodeLivenessStatus_DECOMMISSIONING},
+		// A node that isn't dead yet may still come back, even if it is
+		// decommissioning.
+		4: {IsLive: false, LivenessStatus: NodeLivenessStatus_UNAVAILABLE},
+		5: {IsLive: false, LivenessStatus: NodeLivenessStatus_UNKNOWN},
+		6: {IsLive: false, LivenessStatus: NodeLivenessStatus_DEAD},
+		7: {IsLive: false, LivenessStatus: NodeLivenessStatus_DECOMMISSIONED},
+	}
+	exp := IsLiveMapCounts{
+		Live:            3,
+		Draining:        1,
+		Decommissioning: 1,
+		Unavailable:     2,
+		Dead:            1,
+		Decommissioned:  1,
+	}
+	require.Equal(t, exp, m.CountByStatus())
+	require.Equal(t, IsLiveMapCounts{}, IsLiveMap(nil).CountByStatus())
+
+	statuses := make(map[roachpb.NodeID]NodeLivenessStatus, len(m))
+	for nodeID, entry := range m {
+		statuses[nodeID] = entry.LivenessStatus
+	}
+	require.Equal(t, exp, CountStatuses(statuses))
+}
+
+func TestIsLiveMapEligibleTargets(t *testing.T) {
+	now := hlc.Timestamp{WallTime: int64(time.Hour)}
+	const suspectDuration = 30 * time.Second
+	live := now.Add(int64(time.Second), 0).ToLegacyTimestamp()
+	m := IsLiveMap{
+		1: {IsLive: true, Liveness: Liveness{NodeID: 1, Epoch: 1, Expiration: live}},
+		2: {IsLive: false, Liveness: Liveness{NodeID: 2, Epoch: 1,
+			Expiration: now.Add(-int64(time.Second), 0).ToLegacyTimestamp()}},
+		3: {IsLive: true, Liveness: Liveness{NodeID: 3, Epoch: 1, Expiration: live, Draining: true}},
+		4: {IsLive: true, Liveness: Liveness{NodeID: 4, Epoch: 1, Expiration: live, Departing: true}},
+		5: {IsLive: true, Liveness: Liveness{NodeID: 5, Epoch: 1, Expiration: live,
+			Membership: MembershipStatus_DECOMMISSIONING}},
+		6: {IsLive: true, Liveness: Liveness{NodeID: 6, Epoch: 1, Expiration: live,
+			LastUnavailable: now.Add(-int64(time.Second), 0)}},
+		7: {IsLive: true, Liveness: Liveness{NodeID: 7, Epoch: 1, Expiration: live,
+			LastUnavailable: now.Add(-int64(time.Minute), 0)}},
+		8: {IsLive: true, Liveness: Liveness{NodeID: 8, Epoch: 1, Expiration: live,
+			MaintenanceStart: now.Add(-int64(time.Second), 0), MaintenanceEnd: now.Add(int64(time.Second), 0)}},
+		// A node that never heartbeated is still joining.
+		9: {IsLive: true, Liveness: Liveness{NodeID: 9, Expiration: live}},
+	}
+	var eligible []roachpb.NodeID
+	for nodeID, entry := range m {
+		if entry.IsEligibleTarget(TargetJobCoordination, now, suspectDuration) {
+			eligible = append(eligible, nodeID)
+		}
+	}
+	require.ElementsMatch(t, []roachpb.NodeID{1, 7}, eligible)
+	require.True(t, m.HasEligibleTarget(TargetJobCoordination, now, suspectDuration))
+	delete(m, 1)
+	delete(m, 7)
+	require.False(t, m.HasEligibleTarget(TargetJobCoordination, now, suspectDuration))
+	require.False(t, IsLiveMap(nil).HasEligibleTarget(TargetJobCoordination, now, suspectDuration))
+}
+
+func TestLivenessView(t *testing.T) {
+	now := hlc.Timestamp{WallTime: int64(time.Hour)}
+	const deadThreshold = 5 * time.Minute
+	expiration := func(d time.Duration) hlc.LegacyTimestamp {
+		return now.Add(int64(d), 0).ToLegacyTimestamp()
+	}
+	v := LivenessView{
+		IsLiveMap: IsLiveMap{
+			1: {IsLive: true, Liveness: Liveness{NodeID: 1, Epoch: 1, Expiration: expiration(time.Second)}},
+			2: {Liveness: Liveness{NodeID: 2, Epoch: 1, Expiration: expiration(-time.Minute)}},
+			3: {Liveness: Liveness{NodeID: 3, Epoch: 1, Expiration: expiration(-time.Hour)}},
+			// The node overrides the dead threshold.
+			4: {Liveness: Liveness{NodeID: 4, Epoch: 1, Expiration: expiration(-time.Minute),
+				TimeUntilDeadOverride: TimeUntilDeadOverride{
+					TimeUntilDeadNanos: int64(time.Second),
+					Expiration:         now.Add(int64(time.Hour), 0),
+				}}},
+		},
+		Now:           now,
+		DeadThreshold: deadThreshold,
+	}
+	for _, tc := range []struct {
+		nodeID roachpb.NodeID
+		status NodeLivenessStatus
+		live   bool
+		dead   bool
+	}{
+		{1, NodeLivenessStatus_LIVE, true, false},
+		{2, NodeLivenessStatus_UNAVAILABLE, false, false},
+		{3, NodeLivenessStatus_DEAD, false, true},
+		{4, NodeLivenessStatus_DEAD, false, true},
+		{5, NodeLivenessStatus_UNKNOWN, false, false},
+	} {
+		require.Equal(t, tc.status, v.Status(tc.nodeID), "n%d", tc.nodeID)
+		require.Equal(t, tc.live, v.IsLive(tc.nodeID), "n%d", tc.nodeID)
+		require.Equal(t, tc.dead, v.IsDead(tc.nodeID), "n%d", tc.nodeID)
+	}
+	require.True(t, v.HasEligibleTarget(TargetJobCoordination, time.Minute))
+}
+
+func TestLivenessRemainingUntilDead(t *testing.T) {
+	now := hlc.Timestamp{WallTime: int64(time.Hour)}
+	const deadThreshold = 5 * time.Minute
+	expiration := func(d time.Duration) hlc.LegacyTimestamp {
+		return now.Add(int64(d), 0).ToLegacyTimestamp()
+	}
+	for _, tc := range []struct {
+		name      string
+		l         Liveness
+		remaining time.Duration
+		ok        bool
+	}{
+		{"unknown", Liveness{}, 0, false},
+		{"live", Liveness{Epoch: 1, Expiration: expiration(time.Second)}, 0, false},
+		{"unavailable", Liveness{Epoch: 1, Expiration: expiration(-time.Minute)}, 4 * time.Minute, true},
+		{"dead", Liveness{Epoch: 1, Expiration: expiration(-time.Hour)}, 0, false},
+		{"provisional", Liveness{Expiration: expiration(-time.Minute)}, 0, false},
+		{"override", Liveness{
+			Epoch:      1,
+			Expiration: expiration(-time.Minute),
+			TimeUntilDeadOverride: TimeUntilDeadOverride{
+				TimeUntilDeadNanos: int64(time.Hour),
+				Expiration:         now.Add(int64(time.Hour), 0),
+			},
+		}, 59 * time.Minute, true},
+		{"reported gone", Liveness{
+			Epoch:      1,
+			Expiration: expiration(-time.Minute),
+			ExternalFailure: ExternalFailure{
+				Probe: "k8s", Reason: "node not ready", ReporterNodeID: 1,
+				CorroboratingNodeIDs: []roachpb.NodeID{3},
+			},
+		}, 0, false},
+		{"reported gone without corroboration", Liveness{
+			Epoch:           1,
+			Expiration:      expiration(-time.Minute),
+			ExternalFailure: ExternalFailure{Probe: "k8s", Reason: "node not ready", ReporterNodeID: 1},
+		}, 4 * time.Minute, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			remaining, ok := tc.l.RemainingUntilDead(now, deadThreshold)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.remaining, remaining)
+		})
+	}
+}
+
+func TestStoreDigest(t *testing.T) {
+	c := roachpb.StoreCapacity{
+		Capacity:         100,
+		Available:        40,
+		Used:             60,
+		LogicalBytes:     50,
+		RangeCount:       10,
+		LeaseCount:       5,
+		QueriesPerSecond: 1000,
+		WritesPerSecond:  100,
+		CPUPerSecond:     1e9,
+		L0Sublevels:      3,
+	}
+	l := Liveness{StoreDigests: []StoreDigest{MakeStoreDigest(2, c)}}
+	_, ok := l.StoreDigest(1)
+	require.False(t, ok)
+	d, ok := l.StoreDigest(2)
+	require.True(t, ok)
+
+	// Applying the digest restores the summarized fields only.
+	applied := roachpb.StoreCapacity{BytesPerReplica: roachpb.Percentiles{P50: 1}}
+	d.ApplyTo(&applied)
+	expected := c
+	expected.BytesPerReplica = roachpb.Percentiles{P50: 1}
+	require.Equal(t, expected, applied)
+}
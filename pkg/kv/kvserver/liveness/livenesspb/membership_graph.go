@@ -0,0 +1,114 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package livenesspb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MembershipTransition is a change of the membership status of a node that
+// ValidateTransition accepts, other than leaving the status unchanged.
+type MembershipTransition struct {
+	From, To MembershipStatus
+	// NoOp is whether the transition is accepted without changing the
+	// record, because the node is already past the target status.
+	NoOp bool
+}
+
+// membershipStates lists the membership statuses, in the order in which a
+// node goes through them.
+var membershipStates = []MembershipStatus{
+	MembershipStatus_ACTIVE,
+	MembershipStatus_DECOMMISSIONING,
+	MembershipStatus_DECOMMISSIONED,
+}
+
+// membershipTransitions is the membership state machine. ValidateTransition
+// rejects any transition not listed here, and the exported graph is generated
+// from it, so that the two can't diverge.
+var membershipTransitions = []MembershipTransition{
+	{From: MembershipStatus_ACTIVE, To: MembershipStatus_DECOMMISSIONING},
+	{From: MembershipStatus_DECOMMISSIONING, To: MembershipStatus_ACTIVE},
+	{From: MembershipStatus_DECOMMISSIONING, To: MembershipStatus_DECOMMISSIONED},
+	// Decommissioning a decommissioned node would just move it directly back
+	// to decommissioned.
+	{From: MembershipStatus_DECOMMISSIONED, To: MembershipStatus_DECOMMISSIONING, NoOp: true},
+}
+
+// lookupMembershipTransition returns the transition between the given
+// statuses, if it is valid.
+func lookupMembershipTransition(from, to MembershipStatus) (MembershipTransition, bool) {
+	for _, t := range membershipTransitions {
+		if t.From == from && t.To == to {
+			return t, true
+		}
+	}
+	return MembershipTransition{}, false
+}
+
+// MembershipGraph describes the membership state machine, and optionally the
+// position of a node in it. It serializes to JSON.
+type MembershipGraph struct {
+	States      []string              `json:"states"`
+	Transitions []MembershipGraphEdge `json:"transitions"`
+	Current     string                `json:"current,omitempty"`
+}
+
+// MembershipGraphEdge is a transition of the membership state machine.
+type MembershipGraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	NoOp bool   `json:"no_op"`
+}
+
+// MakeMembershipGraph returns the membership state machine. If current is
+// non-nil, it marks the given status as the position of a node.
+func MakeMembershipGraph(current *MembershipStatus) MembershipGraph {
+	var g MembershipGraph
+	for _, s := range membershipStates {
+		g.States = append(g.States, s.String())
+	}
+	for _, t := range membershipTransitions {
+		g.Transitions = append(g.Transitions, MembershipGraphEdge{
+			From: t.From.String(),
+			To:   t.To.String(),
+			NoOp: t.NoOp,
+		})
+	}
+	if current != nil {
+		g.Current = current.String()
+	}
+	return g
+}
+
+// DOT renders the membership state machine in the Graphviz DOT language. The
+// current status, if any, is highlighted and no-op transitions are dashed.
+func (g MembershipGraph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph membership {\n")
+	for _, s := range g.States {
+		if s == g.Current {
+			fmt.Fprintf(&b, "  %q [style=filled];\n", s)
+		} else {
+			fmt.Fprintf(&b, "  %q;\n", s)
+		}
+	}
+	for _, t := range g.Transitions {
+		if t.NoOp {
+			fmt.Fprintf(&b, "  %q -> %q [style=dashed, label=\"no-op\"];\n", t.From, t.To)
+		} else {
+			fmt.Fprintf(&b, "  %q -> %q;\n", t.From, t.To)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
@@ -0,0 +1,70 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package livenesspb
+
+import "github.com/cockroachdb/errors"
+
+// Limits on the tags of a liveness record. The record is gossiped and read on
+// every heartbeat, so the tags are kept small.
+const (
+	// MaxTags is the maximum number of tags on a liveness record.
+	MaxTags = 16
+	// MaxTagKeyLength is the maximum length of a tag key, in bytes.
+	MaxTagKeyLength = 64
+	// MaxTagValueLength is the maximum length of a tag value, in bytes.
+	MaxTagValueLength = 256
+)
+
+// ValidateTags returns an error if the given tags exceed the limits above or
+// have an empty key.
+func ValidateTags(tags map[string]string) error {
+	if len(tags) > MaxTags {
+		return errors.Errorf("too many tags: %d (maximum %d)", len(tags), MaxTags)
+	}
+	for k, v := range tags {
+		if k == "" {
+			return errors.Errorf("tag keys must not be empty")
+		}
+		if len(k) > MaxTagKeyLength {
+			return errors.Errorf("tag key %q too long: %d bytes (maximum %d)", k, len(k), MaxTagKeyLength)
+		}
+		if len(v) > MaxTagValueLength {
+			return errors.Errorf("value of tag %q too long: %d bytes (maximum %d)",
+				k, len(v), MaxTagValueLength)
+		}
+	}
+	return nil
+}
+
+// TagsEqual returns whether the two sets of tags are identical. A nil set is
+// equal to an empty one.
+func TagsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || v != w {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchesTags returns whether the record carries all of the given tags, with
+// the same values. Every record matches an empty set of tags.
+func (l *Liveness) MatchesTags(tags map[string]string) bool {
+	for k, v := range tags {
+		if w, ok := l.Tags[k]; !ok || v != w {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package livenesspb
+
+import (
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+)
+
+// NodeVitality is the local node's view of the health of another node. It fuses
+// the node's liveness record with what the RPC layer knows about the
+// connection to it, so that components such as the store pool base their
+// decisions on a single verdict instead of consulting either source on its
+// own.
+//
+// NodeVitality is a snapshot; the verdict it returns for a given time is only
+// valid until ValidUntil, after which it needs to be recomputed from a more
+// recent liveness record. Note that connectivity can change at any time, so
+// the bound only accounts for the passage of time.
+type NodeVitality struct {
+	Liveness
+	// Connectivity is the state of the RPC connection to the node at the time
+	// the NodeVitality was computed. It is always CONNECTED for the local node.
+	Connectivity Connectivity
+}
+
+// NodeVitalityMap is a map from NodeID to NodeVitality.
+type NodeVitalityMap map[roachpb.NodeID]NodeVitality
+
+// Status returns the verdict for the node at the given time. This is the
+// status of the liveness record (see Liveness.Status), except that a node
+// whose record is live but which the RPC layer knows to be unreachable is
+// considered UNAVAILABLE.
+func (v NodeVitality) Status(now hlc.Timestamp, deadThreshold time.Duration) NodeLivenessStatus {
+	_, status := deriveLifecycle(&v.Liveness, v.Connectivity, now, deadThreshold)
+	return status
+}
+
+// IsLive returns whether the node is live and reachable at the given time.
+// Unlike Liveness.IsLive, a node that is unreachable over RPC is not live even
+// if its liveness record hasn't expired yet.
+func (v NodeVitality) IsLive(now hlc.Timestamp) bool {
+	return v.Liveness.IsLive(now) && v.Connectivity != Connectivity_DISCONNECTED
+}
+
+// ValidUntil returns the time until which the verdict returned by Status for
+// the given time holds, absent new liveness records and connectivity changes.
+// The verdict of a node that is dead or whose liveness is unknown only changes
+// once new information arrives, in which case hlc.MaxTimestamp is returned.
+func (v NodeVitality) ValidUntil(now hlc.Timestamp, deadThreshold time.Duration) hlc.Timestamp {
+	if v.Expiration.WallTime == 0 {
+		return hlc.MaxTimestamp
+	}
+	expiration := v.Expiration.ToTimestamp()
+	if now.Less(expiration) {
+		// The node is live. Its status may change when a maintenance window
+		// starts or ends before the record expires.
+		for _, ts := range []hlc.Timestamp{v.MaintenanceStart, v.MaintenanceEnd} {
+			if !v.MaintenanceEnd.IsEmpty() && now.Less(ts) && ts.Less(expiration) {
+				expiration = ts
+			}
+		}
+		return expiration
+	}
+	if dead := expiration.AddDuration(deadThreshold); now.Less(dead) {
+		return dead
+	}
+	return hlc.MaxTimestamp
+}
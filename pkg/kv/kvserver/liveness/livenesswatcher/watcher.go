@@ -0,0 +1,324 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+// Package livenesswatcher provides a subscription service over the node
+// liveness keyspan using a rangefeed. Components anywhere in the process can
+// use it to follow changes to the liveness records, in timestamp order, without
+// depending on gossip.
+package livenesswatcher
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/clusterversion"
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvclient/rangefeed"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvclient/rangefeed/rangefeedbuffer"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvclient/rangefeed/rangefeedcache"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvpb"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/stop"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+)
+
+// bufferSize is the number of liveness record writes that can accumulate
+// between two resolved timestamps before the rangefeed is restarted. Every
+// node writes its record once per heartbeat, so this is plenty.
+const bufferSize = 1 << 14
+
+// maxRetainedUpdates is the number of updates retained to serve subscribers
+// resuming from a checkpoint. Subscribers resuming from further back receive
+// a full snapshot instead.
+const maxRetainedUpdates = 1 << 12
+
+// Update is a new version of the liveness record of a node.
+type Update struct {
+	Liveness livenesspb.Liveness
+	// Timestamp is the MVCC timestamp at which the record was written.
+	Timestamp hlc.Timestamp
+}
+
+// Handler is invoked with the updates to the liveness records, in timestamp
+// order, along with a checkpoint: all the updates at or below the checkpoint
+// have been delivered. Passing the checkpoint back to Subscribe resumes the
+// subscription from there. Handlers are invoked one at a time and must not
+// block.
+type Handler func(ctx context.Context, updates []Update, checkpoint hlc.Timestamp)
+
+// Watcher maintains a view of the liveness records of all the nodes using a
+// rangefeed over the node liveness keyspan, and publishes the changes to its
+// subscribers. The rangefeed only runs once the Watcher is started and has a
+// subscriber, so that nodes that don't use the Watcher don't pay for it.
+//
+// Sample usage:
+//
+//	w := livenesswatcher.New(...)
+//	if err := w.Start(ctx); err != nil { ... }
+//
+//	unsubscribe := w.Subscribe(ctx, checkpoint, func(
+//	  ctx context.Context, updates []livenesswatcher.Update, ts hlc.Timestamp,
+//	) {
+//	  ...
+//	  checkpoint = ts
+//	})
+//	defer unsubscribe()
+type Watcher struct {
+	clock   *hlc.Clock
+	f       *rangefeed.Factory
+	stopper *stop.Stopper
+	version clusterversion.Handle
+	// defaultTTL is the default TTL of the liveness records, used along with
+	// version to normalize them.
+	defaultTTL time.Duration
+
+	// deliveryMu serializes the delivery of updates to subscribers, which is
+	// what guarantees their ordering. It's acquired before mu.
+	deliveryMu syncutil.Mutex
+
+	mu struct {
+		syncutil.Mutex
+		// frontier is the timestamp up to which the records are known.
+		frontier hlc.Timestamp
+		records  map[roachpb.NodeID]Update
+		// retained are the most recent updates, in timestamp order. All the
+		// updates above retainedFrom are included.
+		retained     []Update
+		retainedFrom hlc.Timestamp
+		subscribers  map[*subscriber]struct{}
+		// startCtx is the context the Watcher was started with, which the
+		// rangefeed runs with once it's needed.
+		startCtx context.Context
+		// running is set once the rangefeed is started.
+		running bool
+	}
+
+	// testingWatcherKnobs allows the client to inject testing knobs into
+	// the underlying rangefeedcache.Watcher.
+	testingWatcherKnobs *rangefeedcache.TestingKnobs
+}
+
+type subscriber struct {
+	handler Handler
+	// skipUntil filters out the updates the subscriber has already seen.
+	skipUntil hlc.Timestamp
+}
+
+// New constructs a new Watcher.
+func New(
+	clock *hlc.Clock,
+	f *rangefeed.Factory,
+	stopper *stop.Stopper,
+	version clusterversion.Handle,
+	defaultTTL time.Duration,
+) *Watcher {
+	w := &Watcher{
+		clock:      clock,
+		f:          f,
+		stopper:    stopper,
+		version:    version,
+		defaultTTL: defaultTTL,
+	}
+	w.mu.records = make(map[roachpb.NodeID]Update)
+	w.mu.subscribers = make(map[*subscriber]struct{})
+	return w
+}
+
+// Start will start the Watcher. The rangefeed is started along with the first
+// subscription, if there is none yet. It doesn't wait for the initial scan of
+// the liveness records: subscribers receive them once available.
+func (w *Watcher) Start(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.mu.startCtx = ctx
+	if len(w.mu.subscribers) == 0 {
+		return nil
+	}
+	return w.startRangefeedLocked()
+}
+
+// startRangefeedLocked starts the rangefeed, unless it's running already.
+func (w *Watcher) startRangefeedLocked() error {
+	if w.mu.running {
+		return nil
+	}
+	ctx := w.mu.startCtx
+	c := rangefeedcache.NewWatcher(
+		"liveness-watcher",
+		w.clock, w.f,
+		bufferSize,
+		[]roachpb.Span{keys.NodeLivenessSpan},
+		false, // withPrevValue
+		w.translateEvent,
+		w.handleUpdate,
+		w.testingWatcherKnobs,
+	)
+	if err := rangefeedcache.Start(ctx, w.stopper, c, func(err error) {
+		log.Warningf(ctx, "liveness watcher rangefeed failed: %v", err)
+	}); err != nil {
+		return err
+	}
+	w.mu.running = true
+	return nil
+}
+
+// Subscribe registers a handler for the updates to the liveness records.
+//
+// If resumeFrom is empty, or further back than the retained history, the
+// handler is first invoked with the current record of every node. Otherwise,
+// it's only invoked with the updates above resumeFrom. The returned function
+// unregisters the handler.
+func (w *Watcher) Subscribe(
+	ctx context.Context, resumeFrom hlc.Timestamp, handler Handler,
+) (unsubscribe func()) {
+	w.deliveryMu.Lock()
+	defer w.deliveryMu.Unlock()
+
+	s := &subscriber{handler: handler}
+	w.mu.Lock()
+	var updates []Update
+	if !resumeFrom.IsEmpty() && !w.mu.retainedFrom.IsEmpty() && w.mu.retainedFrom.LessEq(resumeFrom) {
+		s.skipUntil = resumeFrom
+		updates = filterUpdates(w.mu.retained, resumeFrom)
+	} else {
+		updates = make([]Update, 0, len(w.mu.records))
+		for _, u := range w.mu.records {
+			updates = append(updates, u)
+		}
+		sortUpdates(updates)
+	}
+	frontier := w.mu.frontier
+	w.mu.subscribers[s] = struct{}{}
+	if w.mu.startCtx != nil {
+		if err := w.startRangefeedLocked(); err != nil {
+			// The subscriber is still registered, and the next subscription
+			// retries.
+			log.Warningf(ctx, "unable to start the liveness watcher rangefeed: %v", err)
+		}
+	}
+	w.mu.Unlock()
+
+	if !frontier.IsEmpty() {
+		s.deliver(ctx, updates, frontier)
+	}
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		delete(w.mu.subscribers, s)
+	}
+}
+
+// Frontier returns the timestamp up to which the liveness records are known,
+// which is empty until the initial scan completes.
+func (w *Watcher) Frontier() hlc.Timestamp {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.mu.frontier
+}
+
+type event struct {
+	update Update
+}
+
+// Timestamp implements the rangefeedbuffer.Event interface.
+func (e *event) Timestamp() hlc.Timestamp {
+	return e.update.Timestamp
+}
+
+func (w *Watcher) translateEvent(
+	ctx context.Context, ev *kvpb.RangeFeedValue,
+) rangefeedbuffer.Event {
+	// Liveness records are never deleted.
+	if !ev.Value.IsPresent() {
+		return nil
+	}
+	var l livenesspb.Liveness
+	if err := ev.Value.GetProto(&l); err != nil {
+		log.Warningf(ctx, "failed to decode liveness record at key %s: %v", ev.Key, err)
+		return nil
+	}
+	l.Normalize(ctx, w.version, w.defaultTTL)
+	return &event{update: Update{Liveness: l, Timestamp: ev.Value.Timestamp}}
+}
+
+func (w *Watcher) handleUpdate(ctx context.Context, u rangefeedcache.Update) {
+	w.deliveryMu.Lock()
+	defer w.deliveryMu.Unlock()
+
+	w.mu.Lock()
+	// A complete update follows the (re)start of the rangefeed. Only the
+	// records that differ from the ones we know are new to the subscribers.
+	updates := make([]Update, 0, len(u.Events))
+	for _, ev := range u.Events {
+		update := ev.(*event).update
+		if prev, ok := w.mu.records[update.Liveness.NodeID]; ok &&
+			(update.Timestamp.LessEq(prev.Timestamp) || update.Liveness.Equal(prev.Liveness)) {
+			continue
+		}
+		w.mu.records[update.Liveness.NodeID] = update
+		updates = append(updates, update)
+	}
+	sortUpdates(updates)
+	if w.mu.frontier.Less(u.Timestamp) {
+		w.mu.frontier = u.Timestamp
+	}
+	w.retainLocked(updates)
+	frontier := w.mu.frontier
+	subscribers := make([]*subscriber, 0, len(w.mu.subscribers))
+	for s := range w.mu.subscribers {
+		subscribers = append(subscribers, s)
+	}
+	w.mu.Unlock()
+
+	for _, s := range subscribers {
+		s.deliver(ctx, filterUpdates(updates, s.skipUntil), frontier)
+	}
+}
+
+// retainLocked appends the given updates to the retained history, trimming
+// it to maxRetainedUpdates.
+func (w *Watcher) retainLocked(updates []Update) {
+	if w.mu.retainedFrom.IsEmpty() {
+		// Nothing is retained from before the initial scan.
+		w.mu.retainedFrom = w.mu.frontier
+		return
+	}
+	w.mu.retained = append(w.mu.retained, updates...)
+	if excess := len(w.mu.retained) - maxRetainedUpdates; excess > 0 {
+		w.mu.retainedFrom = w.mu.retained[excess-1].Timestamp
+		w.mu.retained = append([]Update(nil), w.mu.retained[excess:]...)
+	}
+}
+
+func (s *subscriber) deliver(ctx context.Context, updates []Update, checkpoint hlc.Timestamp) {
+	if checkpoint.LessEq(s.skipUntil) {
+		return
+	}
+	s.skipUntil = hlc.Timestamp{}
+	s.handler(ctx, updates, checkpoint)
+}
+
+// filterUpdates returns the updates above the given timestamp.
+func filterUpdates(updates []Update, after hlc.Timestamp) []Update {
+	i := sort.Search(len(updates), func(i int) bool {
+		return after.Less(updates[i].Timestamp)
+	})
+	return updates[i:]
+}
+
+func sortUpdates(updates []Update) {
+	sort.Slice(updates, func(i, j int) bool {
+		return updates[i].Timestamp.Less(updates[j].Timestamp)
+	})
+}
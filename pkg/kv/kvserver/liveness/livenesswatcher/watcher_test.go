@@ -0,0 +1,116 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package livenesswatcher
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/kv/kvclient/rangefeed/rangefeedbuffer"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvclient/rangefeed/rangefeedcache"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcherSubscribe(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	w := New(nil /* clock */, nil /* f */, nil /* stopper */, nil /* version */, 0 /* defaultTTL */)
+
+	ts := func(wt int64) hlc.Timestamp { return hlc.Timestamp{WallTime: wt} }
+	ev := func(nodeID roachpb.NodeID, epoch int64, wt int64) rangefeedbuffer.Event {
+		return &event{update: Update{
+			Liveness:  livenesspb.Liveness{NodeID: nodeID, Epoch: epoch},
+			Timestamp: ts(wt),
+		}}
+	}
+	type delivery struct {
+		epochs     map[roachpb.NodeID]int64
+		checkpoint hlc.Timestamp
+	}
+	subscribe := func(resumeFrom hlc.Timestamp) (*[]delivery, func()) {
+		var deliveries []delivery
+		unsubscribe := w.Subscribe(ctx, resumeFrom, func(
+			_ context.Context, updates []Update, checkpoint hlc.Timestamp,
+		) {
+			d := delivery{epochs: map[roachpb.NodeID]int64{}, checkpoint: checkpoint}
+			for i, u := range updates {
+				if i > 0 {
+					require.True(t, updates[i-1].Timestamp.Less(u.Timestamp))
+				}
+				d.epochs[u.Liveness.NodeID] = u.Liveness.Epoch
+			}
+			deliveries = append(deliveries, d)
+		})
+		return &deliveries, unsubscribe
+	}
+
+	// A subscriber registered before the initial scan receives it.
+	early, unsubscribeEarly := subscribe(hlc.Timestamp{})
+	require.Empty(t, *early)
+	w.handleUpdate(ctx, rangefeedcache.Update{
+		Type:      rangefeedcache.CompleteUpdate,
+		Timestamp: ts(10),
+		Events:    []rangefeedbuffer.Event{ev(2, 1, 5), ev(1, 1, 3)},
+	})
+	require.Equal(t, []delivery{
+		{epochs: map[roachpb.NodeID]int64{1: 1, 2: 1}, checkpoint: ts(10)},
+	}, *early)
+	unsubscribeEarly()
+
+	w.handleUpdate(ctx, rangefeedcache.Update{
+		Type:      rangefeedcache.IncrementalUpdate,
+		Timestamp: ts(20),
+		Events:    []rangefeedbuffer.Event{ev(1, 2, 15)},
+	})
+	require.Len(t, *early, 1)
+
+	// A new subscriber receives the current records.
+	full, unsubscribeFull := subscribe(hlc.Timestamp{})
+	defer unsubscribeFull()
+	require.Equal(t, []delivery{
+		{epochs: map[roachpb.NodeID]int64{1: 2, 2: 1}, checkpoint: ts(20)},
+	}, *full)
+
+	// A subscriber resuming from a checkpoint only receives what came after.
+	resumed, unsubscribeResumed := subscribe(ts(10))
+	defer unsubscribeResumed()
+	require.Equal(t, []delivery{
+		{epochs: map[roachpb.NodeID]int64{1: 2}, checkpoint: ts(20)},
+	}, *resumed)
+
+	// A restarted rangefeed only publishes the records that changed.
+	w.handleUpdate(ctx, rangefeedcache.Update{
+		Type:      rangefeedcache.CompleteUpdate,
+		Timestamp: ts(30),
+		Events:    []rangefeedbuffer.Event{ev(1, 2, 15), ev(2, 2, 25)},
+	})
+	for _, deliveries := range []*[]delivery{full, resumed} {
+		require.Equal(t, delivery{
+			epochs: map[roachpb.NodeID]int64{2: 2}, checkpoint: ts(30),
+		}, (*deliveries)[len(*deliveries)-1])
+	}
+
+	// A subscriber resuming from before the retained history receives the
+	// current records.
+	stale, unsubscribeStale := subscribe(ts(1))
+	defer unsubscribeStale()
+	require.Equal(t, []delivery{
+		{epochs: map[roachpb.NodeID]int64{1: 2, 2: 2}, checkpoint: ts(30)},
+	}, *stale)
+	require.Equal(t, ts(30), w.Frontier())
+}
@@ -0,0 +1,262 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package liveness
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/settings"
+	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/errors"
+)
+
+var livenessRangeCapacity = settings.RegisterIntSetting(
+	settings.SystemOnly,
+	"kv.liveness.range_capacity",
+	"the number of heartbeats per second the node liveness range is expected to sustain, "+
+		"used to detect when the heartbeat volume approaches capacity",
+	2000,
+	settings.PositiveInt,
+)
+
+var livenessHotThreshold = settings.RegisterFloatSetting(
+	settings.SystemOnly,
+	"kv.liveness.hotspot.utilization_threshold",
+	"the utilization of the node liveness range at or above which it is considered hot and "+
+		"heartbeats are spread out; the utilization is the larger of the heartbeat rate as a "+
+		"fraction of kv.liveness.range_capacity and the median of the heartbeat latencies "+
+		"reported by all nodes as a fraction of the time a node has to renew its liveness",
+	0.75,
+	settings.NonNegativeFloatWithMaximum(1),
+)
+
+var livenessHotTTLMultiplier = settings.RegisterFloatSetting(
+	settings.SystemOnly,
+	"kv.liveness.hotspot.ttl_multiplier",
+	"the factor by which nodes extend the expiration of their liveness records, and the "+
+		"interval between their heartbeats, while the node liveness range is hot; 1 disables "+
+		"the mitigation. Note that this also delays the detection of failed nodes",
+	1,
+	func(v float64) error {
+		if v < 1 {
+			return errors.Errorf("cannot set to a value less than 1: %f", v)
+		}
+		return nil
+	},
+)
+
+var coldNodeTTLMultiplier = settings.RegisterFloatSetting(
+	settings.SystemOnly,
+	"kv.liveness.cold_node.ttl_multiplier",
+	"the factor by which nodes started with --cold-node extend the expiration of their "+
+		"liveness records, and the interval between their heartbeats. Note that this also "+
+		"delays the detection of the failure of such nodes",
+	10,
+	func(v float64) error {
+		if v < 1 {
+			return errors.Errorf("cannot set to a value less than 1: %f", v)
+		}
+		return nil
+	},
+)
+
+// loadSampleInterval is the interval at which the load on the node liveness
+// range is estimated.
+const loadSampleInterval = 10 * time.Second
+
+// RangeLoad is an estimate of the load on the node liveness range, as observed
+// by the local node.
+type RangeLoad struct {
+	// HeartbeatsPerSecond is the rate of heartbeats of all nodes, as observed
+	// through gossip.
+	HeartbeatsPerSecond float64
+	// MeanHeartbeatLatency is the mean latency of the local node's heartbeats.
+	MeanHeartbeatLatency time.Duration
+	// PeerHeartbeatLatency is the median of the heartbeat latencies reported
+	// in the liveness records of all nodes, the local node included. Unlike
+	// MeanHeartbeatLatency, it isn't skewed by a local slow disk or network.
+	PeerHeartbeatLatency time.Duration
+	// Utilization is the larger of HeartbeatsPerSecond as a fraction of
+	// kv.liveness.range_capacity and PeerHeartbeatLatency as a fraction of the
+	// renewal duration. The heartbeat rate is scaled back up by the TTL
+	// multiplier while the range is hot, so that it reflects the load the range
+	// would be under without mitigations.
+	Utilization float64
+	// Hot is true if Utilization is at or above
+	// kv.liveness.hotspot.utilization_threshold, in which case nodes extend
+	// their liveness records by kv.liveness.hotspot.ttl_multiplier.
+	Hot bool
+}
+
+// loadTracker estimates the load on the node liveness range from the
+// heartbeats of all nodes, which the local node observes through gossip, along
+// with the heartbeat latencies they report in their liveness records.
+type loadTracker struct {
+	st              *cluster.Settings
+	renewalDuration time.Duration
+
+	// heartbeats is the number of heartbeats observed since the last sample.
+	// Accessed atomically.
+	heartbeats int64
+
+	mu struct {
+		syncutil.Mutex
+		latencySum   time.Duration
+		latencyCount int64
+		// reported is the heartbeat latency last reported by each node since
+		// the last sample.
+		reported   map[roachpb.NodeID]time.Duration
+		lastSample time.Time
+		load       RangeLoad
+	}
+}
+
+func newLoadTracker(st *cluster.Settings, renewalDuration time.Duration) *loadTracker {
+	t := &loadTracker{st: st, renewalDuration: renewalDuration}
+	t.mu.lastSample = timeutil.Now()
+	t.mu.reported = make(map[roachpb.NodeID]time.Duration)
+	return t
+}
+
+// recordHeartbeat records a heartbeat of any node, which resulted in the given
+// liveness record.
+func (t *loadTracker) recordHeartbeat(l livenesspb.Liveness) {
+	atomic.AddInt64(&t.heartbeats, 1)
+	if l.HeartbeatLatencyNanos == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mu.reported[l.NodeID] = time.Duration(l.HeartbeatLatencyNanos)
+}
+
+// recordLatency records the latency of one of the local node's heartbeats.
+func (t *loadTracker) recordLatency(dur time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mu.latencySum += dur
+	t.mu.latencyCount++
+}
+
+// sample estimates the load since the previous sample, and returns it along
+// with the previous estimate.
+func (t *loadTracker) sample(now time.Time) (load, prev RangeLoad) {
+	heartbeats := atomic.SwapInt64(&t.heartbeats, 0)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	prev = t.mu.load
+	if elapsed := now.Sub(t.mu.lastSample); elapsed > 0 {
+		load.HeartbeatsPerSecond = float64(heartbeats) / elapsed.Seconds()
+	}
+	if t.mu.latencyCount > 0 {
+		load.MeanHeartbeatLatency = t.mu.latencySum / time.Duration(t.mu.latencyCount)
+	}
+	if len(t.mu.reported) > 0 {
+		latencies := make([]time.Duration, 0, len(t.mu.reported))
+		for _, l := range t.mu.reported {
+			latencies = append(latencies, l)
+		}
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		load.PeerHeartbeatLatency = latencies[len(latencies)/2]
+	}
+	unmitigatedRate := load.HeartbeatsPerSecond
+	if prev.Hot {
+		unmitigatedRate *= livenessHotTTLMultiplier.Get(&t.st.SV)
+	}
+	load.Utilization = math.Max(
+		unmitigatedRate/float64(livenessRangeCapacity.Get(&t.st.SV)),
+		load.PeerHeartbeatLatency.Seconds()/t.renewalDuration.Seconds(),
+	)
+	load.Hot = load.Utilization >= livenessHotThreshold.Get(&t.st.SV)
+
+	t.mu.load = load
+	t.mu.lastSample = now
+	t.mu.latencySum, t.mu.latencyCount = 0, 0
+	t.mu.reported = make(map[roachpb.NodeID]time.Duration, len(t.mu.reported))
+	return load, prev
+}
+
+// load returns the most recent estimate.
+func (t *loadTracker) load() RangeLoad {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.mu.load
+}
+
+// ttlMultiplier returns the factor by which the local node extends its
+// liveness record and the interval between its heartbeats.
+func (t *loadTracker) ttlMultiplier() float64 {
+	if !t.load().Hot {
+		return 1
+	}
+	return livenessHotTTLMultiplier.Get(&t.st.SV)
+}
+
+// RangeLoad returns the most recent estimate of the load on the node liveness
+// range.
+func (nl *NodeLiveness) RangeLoad() RangeLoad {
+	return nl.load.load()
+}
+
+// livenessTTL returns the duration for which a heartbeat extends the local
+// node's liveness record. Cold nodes extend it kv.liveness.cold_node.ttl_multiplier
+// times further, and the TTL controller may extend it further still.
+func (nl *NodeLiveness) livenessTTL() time.Duration {
+	multiplier := nl.load.ttlMultiplier() * nl.ttlAutotuneMultiplier()
+	if nl.cold {
+		multiplier *= coldNodeTTLMultiplier.Get(&nl.st.SV)
+	}
+	return time.Duration(float64(nl.livenessThreshold) * multiplier)
+}
+
+// renewalWindow returns how long before its liveness record expires the local
+// node heartbeats it, which is also the timeout of the heartbeat. It is
+// extended by the TTL controller along with the TTL.
+func (nl *NodeLiveness) renewalWindow() time.Duration {
+	return time.Duration(float64(nl.renewalDuration) * nl.ttlAutotuneMultiplier())
+}
+
+// heartbeatInterval returns the interval between heartbeats of the local node.
+func (nl *NodeLiveness) heartbeatInterval() time.Duration {
+	return nl.livenessTTL() - nl.renewalWindow()
+}
+
+// sampleLoad updates the estimate of the load on the node liveness range,
+// along with the corresponding metrics, and warns when the range turns hot.
+func (nl *NodeLiveness) sampleLoad(ctx context.Context) {
+	load, prev := nl.load.sample(timeutil.Now())
+	nl.autotuneTTL(ctx, load)
+	nl.metrics.RangeHeartbeatRate.Update(load.HeartbeatsPerSecond)
+	nl.metrics.RangeUtilization.Update(load.Utilization)
+	if load.Hot {
+		nl.metrics.RangeHot.Update(1)
+	} else {
+		nl.metrics.RangeHot.Update(0)
+	}
+	switch {
+	case load.Hot && !prev.Hot:
+		log.Warningf(ctx, "node liveness range is hot (utilization %.2f, %.0f heartbeats/s, "+
+			"median heartbeat latency %s); extending liveness records by a factor of %.1f",
+			load.Utilization, load.HeartbeatsPerSecond, load.PeerHeartbeatLatency,
+			livenessHotTTLMultiplier.Get(&nl.st.SV))
+	case !load.Hot && prev.Hot:
+		log.Infof(ctx, "node liveness range is no longer hot (utilization %.2f)", load.Utilization)
+	}
+}
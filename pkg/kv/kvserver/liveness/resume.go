@@ -0,0 +1,53 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package liveness
+
+import (
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/settings"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+)
+
+var epochResumeGrace = settings.RegisterDurationSetting(
+	settings.SystemOnly,
+	"kv.liveness.epoch_resume_grace",
+	"if non-zero, a node that restarts within this duration of its last liveness heartbeat, "+
+		"and before its liveness record expired, resumes its liveness epoch instead of "+
+		"incrementing it, so that its epoch-based leases remain valid across the restart",
+	0,
+	settings.NonNegativeDuration,
+)
+
+// canResumeEpoch returns whether a node restarting at the given time may keep
+// the epoch of its liveness record l, instead of incrementing it on its first
+// heartbeat. This is the case if its last heartbeat was at most grace ago and
+// the record is still live, so that no other node can have incremented the
+// epoch or acquired the leases of the node in the meantime. Epoch leases held
+// before the restart still can't be used as they are: the replicas of the
+// restarted node reacquire them with a higher sequence number (see
+// Replica.mu.minLeaseProposedTS), but without moving them.
+//
+// The node must also not be draining, as the first heartbeat after a restart
+// is the one clearing the draining flag.
+func canResumeEpoch(l livenesspb.Liveness, now hlc.Timestamp, defTTL, grace time.Duration) bool {
+	if grace == 0 || l.Epoch == 0 || l.Draining || !l.IsLive(now) {
+		return false
+	}
+	lastHeartbeat := l.Expiration.ToTimestamp().AddDuration(-l.TTL(defTTL))
+	return now.Less(lastHeartbeat.AddDuration(grace))
+}
+
+// canResumeOwnEpoch is like canResumeEpoch, for the local node.
+func (nl *NodeLiveness) canResumeOwnEpoch(l livenesspb.Liveness) bool {
+	return canResumeEpoch(l, nl.clock.Now(), nl.livenessThreshold, epochResumeGrace.Get(&nl.st.SV))
+}
@@ -0,0 +1,112 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package liveness
+
+import (
+	"context"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/util/retry"
+)
+
+// RetryPolicy is the backoff policy of the updates of liveness records that
+// need to be retried, because they raced with another update of the same
+// record (e.g. a heartbeat racing with an epoch increment or a drain), or
+// because their outcome is ambiguous. Retrying right away under heavy
+// contention makes the contenders collide again, hence the jittered backoff.
+type RetryPolicy struct {
+	// InitialBackoff is the backoff before the first retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the backoff between two retries.
+	MaxBackoff time.Duration
+	// Multiplier is the factor by which the backoff grows after each retry.
+	Multiplier float64
+	// RandomizationFactor is the fraction by which backoffs are randomly
+	// shortened or lengthened, so that contenders retry at different times.
+	RandomizationFactor float64
+	// MaxRetries is the number of retries after which an update gives up. Zero
+	// means that updates are retried until their context is canceled or the
+	// node shuts down.
+	MaxRetries int
+}
+
+// DefaultRetryPolicy returns the RetryPolicy used unless NodeLivenessOptions
+// specifies one.
+func DefaultRetryPolicy() RetryPolicy {
+	opts := base.DefaultRetryOptions()
+	return RetryPolicy{
+		InitialBackoff:      opts.InitialBackoff,
+		MaxBackoff:          opts.MaxBackoff,
+		Multiplier:          opts.Multiplier,
+		RandomizationFactor: 0.15,
+		MaxRetries:          opts.MaxRetries,
+	}
+}
+
+// retrier is a retry loop following a RetryPolicy, which records its retries
+// in the liveness metrics. It's used like a retry.Retry:
+//
+//	for r := nl.startRetries(ctx); r.Next(); {
+//	  ...
+//	}
+//	if r.exhausted() {
+//	  ...
+//	}
+type retrier struct {
+	retry.Retry
+	policy  RetryPolicy
+	metrics *Metrics
+}
+
+func newRetrier(
+	ctx context.Context, policy RetryPolicy, closer <-chan struct{}, metrics *Metrics,
+) retrier {
+	return retrier{
+		Retry: retry.StartWithCtx(ctx, retry.Options{
+			InitialBackoff:      policy.InitialBackoff,
+			MaxBackoff:          policy.MaxBackoff,
+			Multiplier:          policy.Multiplier,
+			RandomizationFactor: policy.RandomizationFactor,
+			MaxRetries:          policy.MaxRetries,
+			Closer:              closer,
+		}),
+		policy:  policy,
+		metrics: metrics,
+	}
+}
+
+// startRetries starts a retry loop following the retry policy of the node,
+// which ends when the node quiesces.
+func (nl *NodeLiveness) startRetries(ctx context.Context) retrier {
+	return newRetrier(ctx, nl.retryPolicy, nl.stopper.ShouldQuiesce(), &nl.metrics)
+}
+
+// Next is like retry.Retry.Next, but records the retries, and the loops that
+// give up because the policy doesn't allow for more retries.
+func (r *retrier) Next() bool {
+	if !r.Retry.Next() {
+		if r.exhausted() {
+			r.metrics.UpdateRetriesExhausted.Inc(1)
+		}
+		return false
+	}
+	if r.CurrentAttempt() > 0 {
+		r.metrics.UpdateRetries.Inc(1)
+	}
+	return true
+}
+
+// exhausted returns whether the loop ended because the policy doesn't allow
+// for more retries.
+func (r *retrier) exhausted() bool {
+	return r.policy.MaxRetries > 0 && r.CurrentAttempt() >= r.policy.MaxRetries
+}
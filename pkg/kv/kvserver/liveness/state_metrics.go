@@ -0,0 +1,335 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package liveness
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/util/metric"
+	"github.com/gogo/protobuf/proto"
+	prometheusgo "github.com/prometheus/client_model/go"
+)
+
+var (
+	metaMembershipState = metric.Metadata{
+		Name: "liveness.membership",
+		Help: "Membership of each node, as a state set: for every node and membership " +
+			"(active, decommissioning, decommissioned), a series labeled with peer_node_id and " +
+			"membership is 1 if the node has that membership and 0 otherwise. The per-node " +
+			"series are exported to Prometheus when server.child_metrics.enabled is set; the " +
+			"aggregate is the number of nodes with a liveness record",
+		Measurement: "Nodes",
+		Unit:        metric.Unit_COUNT,
+	}
+	metaStatusState = metric.Metadata{
+		Name: "liveness.status",
+		Help: "Liveness status of each node, as a state set: for every node and status " +
+			"(unknown, dead, unavailable, live, decommissioning, decommissioned, draining), a " +
+			"series labeled with peer_node_id and status is 1 if the node has that status and 0 " +
+			"otherwise. The per-node series are exported to Prometheus when " +
+			"server.child_metrics.enabled is set; the aggregate is the number of nodes with a " +
+			"liveness record",
+		Measurement: "Nodes",
+		Unit:        metric.Unit_COUNT,
+	}
+	metaLocalityStatus = metric.Metadata{
+		Name: "liveness.locality.nodes",
+		Help: "Number of nodes in each locality by liveness status: for every tier of the " +
+			"localities of the nodes (e.g. region, zone), every locality down to that tier and " +
+			"every status (unknown, dead, unavailable, live, decommissioning, decommissioned, " +
+			"draining), a series labeled with locality_tier, locality (e.g. " +
+			"region=us-east1,zone=us-east1-b) and status counts the nodes of the locality that have " +
+			"the status. The series are always exported to Prometheus, regardless of " +
+			"server.child_metrics.enabled; the aggregate is the number of nodes whose locality is " +
+			"known",
+		Measurement: "Nodes",
+		Unit:        metric.Unit_COUNT,
+	}
+)
+
+var membershipStates = []livenesspb.MembershipStatus{
+	livenesspb.MembershipStatus_ACTIVE,
+	livenesspb.MembershipStatus_DECOMMISSIONING,
+	livenesspb.MembershipStatus_DECOMMISSIONED,
+}
+
+// livenessStatusLabels are the label values of the liveness.status metric.
+var livenessStatusLabels = map[livenesspb.NodeLivenessStatus]string{
+	livenesspb.NodeLivenessStatus_UNKNOWN:         "unknown",
+	livenesspb.NodeLivenessStatus_DEAD:            "dead",
+	livenesspb.NodeLivenessStatus_UNAVAILABLE:     "unavailable",
+	livenesspb.NodeLivenessStatus_LIVE:            "live",
+	livenesspb.NodeLivenessStatus_DECOMMISSIONING: "decommissioning",
+	livenesspb.NodeLivenessStatus_DECOMMISSIONED:  "decommissioned",
+	livenesspb.NodeLivenessStatus_DRAINING:        "draining",
+}
+
+// stateSetGauge is a gauge exporting the state of every node in the manner of
+// an OpenMetrics state set: a series per node and possible state, labeled with
+// the node ID and the state, with a value of 1 for the node's current state
+// and 0 for the others. This lets alerting rules match on a state by name
+// rather than on a numeric encoding. The states are computed when the metric
+// is scraped.
+//
+// The per-node series are exported as children of the metric, and as such
+// only when server.child_metrics.enabled is set. The metric itself, which is
+// also recorded in the internal time series database, is the number of nodes.
+type stateSetGauge struct {
+	metric.Metadata
+	// label is the name of the label carrying the state.
+	label string
+	// states are the possible states, in the order in which they're exported.
+	states []string
+	// nodeStates returns the current state of each node.
+	nodeStates func() map[roachpb.NodeID]string
+}
+
+var _ metric.Iterable = (*stateSetGauge)(nil)
+var _ metric.PrometheusIterable = (*stateSetGauge)(nil)
+
+// GetType is part of the metric.PrometheusExportable interface.
+func (g *stateSetGauge) GetType() *prometheusgo.MetricType {
+	return prometheusgo.MetricType_GAUGE.Enum()
+}
+
+// GetMetadata is part of the metric.Iterable interface.
+func (g *stateSetGauge) GetMetadata() metric.Metadata {
+	md := g.Metadata
+	md.MetricType = prometheusgo.MetricType_GAUGE
+	return md
+}
+
+// Inspect is part of the metric.Iterable interface.
+func (g *stateSetGauge) Inspect(f func(interface{})) { f(g) }
+
+// ToPrometheusMetric is part of the metric.PrometheusExportable interface.
+func (g *stateSetGauge) ToPrometheusMetric() *prometheusgo.Metric {
+	return &prometheusgo.Metric{
+		Gauge: &prometheusgo.Gauge{Value: proto.Float64(float64(len(g.nodeStates())))},
+	}
+}
+
+// Each is part of the metric.PrometheusIterable interface.
+func (g *stateSetGauge) Each(
+	labels []*prometheusgo.LabelPair, f func(metric *prometheusgo.Metric),
+) {
+	nodeStates := g.nodeStates()
+	nodeIDs := make([]roachpb.NodeID, 0, len(nodeStates))
+	for nodeID := range nodeStates {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Slice(nodeIDs, func(i, j int) bool { return nodeIDs[i] < nodeIDs[j] })
+	for _, nodeID := range nodeIDs {
+		cur := nodeStates[nodeID]
+		for _, state := range g.states {
+			var v float64
+			if state == cur {
+				v = 1
+			}
+			childLabels := make([]*prometheusgo.LabelPair, 0, len(labels)+2)
+			childLabels = append(childLabels, labels...)
+			childLabels = append(childLabels,
+				&prometheusgo.LabelPair{
+					Name:  proto.String("peer_node_id"),
+					Value: proto.String(strconv.Itoa(int(nodeID))),
+				},
+				&prometheusgo.LabelPair{
+					Name:  proto.String(g.label),
+					Value: proto.String(state),
+				})
+			f(&prometheusgo.Metric{
+				Label: childLabels,
+				Gauge: &prometheusgo.Gauge{Value: proto.Float64(v)},
+			})
+		}
+	}
+}
+
+func (nl *NodeLiveness) newMembershipStateSet() *stateSetGauge {
+	states := make([]string, len(membershipStates))
+	for i, m := range membershipStates {
+		states[i] = m.String()
+	}
+	return &stateSetGauge{
+		Metadata: metaMembershipState,
+		label:    "membership",
+		states:   states,
+		nodeStates: func() map[roachpb.NodeID]string {
+			m := make(map[roachpb.NodeID]string)
+			for _, l := range nl.cache.getAllLivenesses() {
+				m[l.NodeID] = l.Membership.String()
+			}
+			return m
+		},
+	}
+}
+
+func (nl *NodeLiveness) newStatusStateSet() *stateSetGauge {
+	states := make([]string, 0, len(livenessStatusLabels))
+	for s := livenesspb.NodeLivenessStatus_UNKNOWN; s <= livenesspb.NodeLivenessStatus_DRAINING; s++ {
+		states = append(states, livenessStatusLabels[s])
+	}
+	return &stateSetGauge{
+		Metadata: metaStatusState,
+		label:    "status",
+		states:   states,
+		nodeStates: func() map[roachpb.NodeID]string {
+			now := nl.clock.Now()
+			threshold := TimeUntilStoreDead.Get(&nl.st.SV)
+			m := make(map[roachpb.NodeID]string)
+			for nodeID, v := range nl.ScanNodeVitalityFromCache() {
+				m[nodeID] = livenessStatusLabels[v.Status(now, threshold)]
+			}
+			return m
+		},
+	}
+}
+
+// nodeLocalityStatus is the liveness status of a node, along with its
+// locality.
+type nodeLocalityStatus struct {
+	locality roachpb.Locality
+	status   string
+}
+
+// localityKey identifies a locality down to one of its tiers.
+type localityKey struct {
+	tier     string
+	locality string
+}
+
+// localityStatusGauge is a gauge exporting the number of nodes in each
+// locality by state, so that dashboards of multi-region clusters don't need to
+// join per-node series with the localities of the nodes. A node is counted in
+// every locality it is part of, from its first tier (e.g. its region) down to
+// its last one (e.g. its zone); localities are identified by all their tiers
+// down to the counted one, since the values of lower tiers need not be unique
+// across the values of higher tiers. The counts are computed when the metric
+// is scraped.
+//
+// Unlike the per-node series of stateSetGauge, the per-locality series are
+// exported regardless of server.child_metrics.enabled: there are only a few of
+// them, and the metric is of little use without them. The metric itself is the
+// number of nodes whose locality is known.
+type localityStatusGauge struct {
+	metric.Metadata
+	// states are the possible states, in the order in which they're exported.
+	states []string
+	// nodes returns the locality and state of each node whose locality is
+	// known.
+	nodes func() []nodeLocalityStatus
+}
+
+var _ metric.Iterable = (*localityStatusGauge)(nil)
+var _ metric.PrometheusEssentialIterable = (*localityStatusGauge)(nil)
+
+// GetType is part of the metric.PrometheusExportable interface.
+func (g *localityStatusGauge) GetType() *prometheusgo.MetricType {
+	return prometheusgo.MetricType_GAUGE.Enum()
+}
+
+// GetMetadata is part of the metric.Iterable interface.
+func (g *localityStatusGauge) GetMetadata() metric.Metadata {
+	md := g.Metadata
+	md.MetricType = prometheusgo.MetricType_GAUGE
+	return md
+}
+
+// Inspect is part of the metric.Iterable interface.
+func (g *localityStatusGauge) Inspect(f func(interface{})) { f(g) }
+
+// EssentialChildren is part of the metric.PrometheusEssentialIterable
+// interface.
+func (g *localityStatusGauge) EssentialChildren() {}
+
+// ToPrometheusMetric is part of the metric.PrometheusExportable interface.
+func (g *localityStatusGauge) ToPrometheusMetric() *prometheusgo.Metric {
+	return &prometheusgo.Metric{
+		Gauge: &prometheusgo.Gauge{Value: proto.Float64(float64(len(g.nodes())))},
+	}
+}
+
+// Each is part of the metric.PrometheusIterable interface.
+func (g *localityStatusGauge) Each(
+	labels []*prometheusgo.LabelPair, f func(metric *prometheusgo.Metric),
+) {
+	counts := make(map[localityKey]map[string]int)
+	for _, n := range g.nodes() {
+		for i, tier := range n.locality.Tiers {
+			key := localityKey{
+				tier:     tier.Key,
+				locality: roachpb.Locality{Tiers: n.locality.Tiers[:i+1]}.String(),
+			}
+			if counts[key] == nil {
+				counts[key] = make(map[string]int)
+			}
+			counts[key][n.status]++
+		}
+	}
+	keys := make([]localityKey, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].locality < keys[j].locality })
+	for _, key := range keys {
+		for _, state := range g.states {
+			childLabels := make([]*prometheusgo.LabelPair, 0, len(labels)+3)
+			childLabels = append(childLabels, labels...)
+			childLabels = append(childLabels,
+				&prometheusgo.LabelPair{
+					Name:  proto.String("locality_tier"),
+					Value: proto.String(key.tier),
+				},
+				&prometheusgo.LabelPair{
+					Name:  proto.String("locality"),
+					Value: proto.String(key.locality),
+				},
+				&prometheusgo.LabelPair{
+					Name:  proto.String("status"),
+					Value: proto.String(state),
+				})
+			f(&prometheusgo.Metric{
+				Label: childLabels,
+				Gauge: &prometheusgo.Gauge{Value: proto.Float64(float64(counts[key][state]))},
+			})
+		}
+	}
+}
+
+func (nl *NodeLiveness) newLocalityStatusGauge() *localityStatusGauge {
+	states := make([]string, 0, len(livenessStatusLabels))
+	for s := livenesspb.NodeLivenessStatus_UNKNOWN; s <= livenesspb.NodeLivenessStatus_DRAINING; s++ {
+		states = append(states, livenessStatusLabels[s])
+	}
+	return &localityStatusGauge{
+		Metadata: metaLocalityStatus,
+		states:   states,
+		nodes: func() []nodeLocalityStatus {
+			now := nl.clock.Now()
+			threshold := TimeUntilStoreDead.Get(&nl.st.SV)
+			var nodes []nodeLocalityStatus
+			for nodeID, v := range nl.ScanNodeVitalityFromCache() {
+				desc, err := nl.cache.gossip.GetNodeDescriptor(nodeID)
+				if err != nil {
+					continue
+				}
+				nodes = append(nodes, nodeLocalityStatus{
+					locality: desc.Locality,
+					status:   livenessStatusLabels[v.Status(now, threshold)],
+				})
+			}
+			return nodes
+		},
+	}
+}
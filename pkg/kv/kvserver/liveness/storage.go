@@ -12,13 +12,16 @@ package liveness
 
 import (
 	"context"
+	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/clusterversion"
 	"github.com/cockroachdb/cockroach/pkg/keys"
 	"github.com/cockroachdb/cockroach/pkg/kv"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvpb"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/retry"
 	"github.com/cockroachdb/errors"
@@ -28,6 +31,10 @@ import (
 // liveness records to kv. All calls to modify liveness are centrialized here.
 type storage struct {
 	db *kv.DB
+	// version and defaultTTL are used to normalize the liveness records read
+	// from kv, see livenesspb.Liveness.Normalize.
+	version    clusterversion.Handle
+	defaultTTL time.Duration
 }
 
 // livenessUpdate contains the information for CPutting a new version of a
@@ -63,6 +70,7 @@ func (ls storage) get(ctx context.Context, nodeID roachpb.NodeID) (Record, error
 	if err := record.Value.GetProto(&oldLiveness); err != nil {
 		return Record{}, errors.Wrap(err, "invalid liveness record")
 	}
+	oldLiveness.Normalize(ctx, ls.version, ls.defaultTTL)
 
 	return Record{
 		Liveness: oldLiveness,
@@ -116,6 +124,7 @@ func (ls storage) update(
 			if err := tErr.ActualValue.GetProto(&actualLiveness); err != nil {
 				return Record{}, errors.Wrapf(err, "couldn't update node liveness from CPut actual value")
 			}
+			actualLiveness.Normalize(ctx, ls.version, ls.defaultTTL)
 			return Record{}, handleCondFailed(Record{Liveness: actualLiveness, raw: tErr.ActualValue.TagAndDataBytes()})
 		} else if isErrRetryLiveness(ctx, err) {
 			return Record{}, &errRetryLiveness{err}
@@ -123,24 +132,70 @@ func (ls storage) update(
 		return Record{}, err
 	}
 
-	return Record{Liveness: update.newLiveness, raw: v.TagAndDataBytes()}, nil
+	// Normalize the record as written like those read from kv, e.g. to fill
+	// in the fields it was written without.
+	written := update.newLiveness
+	written.Normalize(ctx, ls.version, ls.defaultTTL)
+	return Record{Liveness: written, raw: v.TagAndDataBytes()}, nil
 }
 
-// create creates a liveness record for the node specified by the
-// given node ID. This is typically used when adding a new node to a running
+// updateBatch is like update, but CPuts the liveness records of several nodes
+// in a single transaction, which commits in a single Raft command. A
+// ConditionFailedError is returned as is if any of the records didn't match;
+// the caller is expected to fall back to update to tell which.
+func (ls storage) updateBatch(ctx context.Context, updates []livenessUpdate) ([]Record, error) {
+	var vs []*roachpb.Value
+	if err := ls.db.Txn(ctx, func(ctx context.Context, txn *kv.Txn) error {
+		vs = make([]*roachpb.Value, len(updates))
+		b := txn.NewBatch()
+		var span roachpb.Span
+		for i, update := range updates {
+			vs[i] = new(roachpb.Value)
+			key := keys.NodeLivenessKey(update.newLiveness.NodeID)
+			if err := vs[i].SetProto(&update.newLiveness); err != nil {
+				log.Fatalf(ctx, "failed to marshall proto: %s", err)
+			}
+			b.CPut(key, vs[i], update.oldRaw)
+			if keySpan := (roachpb.Span{Key: key, EndKey: key.Next()}); i == 0 {
+				span = keySpan
+			} else {
+				span = span.Combine(keySpan)
+			}
+		}
+		// As in update, gossip the records on commit and require a one phase
+		// commit.
+		b.AddRawRequest(&kvpb.EndTxnRequest{
+			Commit:     true,
+			Require1PC: true,
+			InternalCommitTrigger: &roachpb.InternalCommitTrigger{
+				ModifiedSpanTrigger: &roachpb.ModifiedSpanTrigger{
+					NodeLivenessSpan: &span,
+				},
+			},
+		})
+		return txn.Run(ctx, b)
+	}); err != nil {
+		return nil, err
+	}
+
+	records := make([]Record, len(updates))
+	for i, update := range updates {
+		written := update.newLiveness
+		written.Normalize(ctx, ls.version, ls.defaultTTL)
+		records[i] = Record{Liveness: written, raw: vs[i].TagAndDataBytes()}
+	}
+	return records, nil
+}
+
+// create creates the given liveness record, and returns it together with its
+// encoding. This is typically used when adding a new node to a running
 // cluster, or when bootstrapping a cluster through a given node.
 //
 // NB: An existing liveness record is not overwritten by this method, we return
 // an error instead.
-func (ls storage) create(ctx context.Context, nodeID roachpb.NodeID) error {
+func (ls storage) create(ctx context.Context, liveness livenesspb.Liveness) (Record, error) {
+	nodeID := liveness.NodeID
 	for r := retry.StartWithCtx(ctx, base.DefaultRetryOptions()); r.Next(); {
-		// We start off at epoch=0, entrusting the initial heartbeat to increment it
-		// to epoch=1 to signal the very first time the node is up and running.
-		liveness := livenesspb.Liveness{NodeID: nodeID, Epoch: 0}
-
-		// We skip adding an expiration, we only really care about the liveness
-		// record existing within KV.
-
 		v := new(roachpb.Value)
 		err := ls.db.Txn(ctx, func(ctx context.Context, txn *kv.Txn) error {
 			b := txn.NewBatch()
@@ -152,31 +207,96 @@ func (ls storage) create(ctx context.Context, nodeID roachpb.NodeID) error {
 			// expect to find anything.
 			b.CPut(key, v, nil)
 
-			// We don't bother adding a gossip trigger, that'll happen with the
-			// first heartbeat. We still keep it as a 1PC commit to avoid leaving
-			// write intents.
+			// As in update, gossip the record on commit, so that the rest of the
+			// cluster learns about the node before its first heartbeat, and
+			// require a one phase commit to avoid leaving write intents.
 			b.AddRawRequest(&kvpb.EndTxnRequest{
 				Commit:     true,
 				Require1PC: true,
+				InternalCommitTrigger: &roachpb.InternalCommitTrigger{
+					ModifiedSpanTrigger: &roachpb.ModifiedSpanTrigger{
+						NodeLivenessSpan: &roachpb.Span{
+							Key:    key,
+							EndKey: key.Next(),
+						},
+					},
+				},
 			})
 			return txn.Run(ctx, b)
 		})
 
 		if err == nil {
-			// We'll learn about this liveness record through gossip eventually, so we
-			// don't bother updating our in-memory view of node liveness.
 			log.Infof(ctx, "created liveness record for n%d", nodeID)
-			return nil
+			return Record{Liveness: liveness, raw: v.TagAndDataBytes()}, nil
 		}
 		if !isErrRetryLiveness(ctx, err) {
-			return err
+			return Record{}, err
 		}
 		log.VEventf(ctx, 2, "failed to create liveness record for node %d, because of %s. retrying...", nodeID, err)
 	}
 	if err := ctx.Err(); err != nil {
-		return err
+		return Record{}, err
+	}
+	return Record{}, errors.AssertionFailedf("unexpected problem while creating liveness record for node %d", nodeID)
+}
+
+// allocateAndCreate allocates a node ID and creates the liveness record for it,
+// so that no node ID is ever allocated without a liveness record. The given
+// record is used as a template; its node ID is ignored.
+//
+// The node ID generator and the liveness record live on different ranges, so
+// they can't be written in a single transaction without giving up on the one
+// phase commit of the liveness record, and leaving intents on the liveness
+// range. Instead, the ID following the generator is claimed by creating its
+// liveness record, as create does, and the generator is then advanced past it.
+// An ID whose record exists is never handed out again, even if the generator
+// wasn't advanced past it (e.g. because of a crash in between): the next
+// allocation finds the record and advances the generator itself.
+func (ls storage) allocateAndCreate(
+	ctx context.Context, liveness livenesspb.Liveness,
+) (Record, error) {
+	for {
+		gen, err := ls.db.Get(ctx, keys.NodeIDGenerator)
+		if err != nil {
+			return Record{}, errors.Wrap(err, "unable to allocate node ID")
+		}
+		liveness.NodeID = roachpb.NodeID(gen.ValueInt() + 1)
+		rec, err := ls.create(ctx, liveness)
+		if tErr := (*kvpb.ConditionFailedError)(nil); errors.As(err, &tErr) {
+			// The ID was claimed already.
+			if err := ls.advanceNodeIDGenerator(ctx, gen, liveness.NodeID); err != nil {
+				return Record{}, errors.Wrap(err, "unable to allocate node ID")
+			}
+			continue
+		}
+		if err != nil {
+			return Record{}, err
+		}
+		if err := ls.advanceNodeIDGenerator(ctx, gen, liveness.NodeID); err != nil {
+			// The record claims the ID regardless.
+			log.Warningf(ctx, "unable to advance the node ID generator past n%d: %v", liveness.NodeID, err)
+		}
+		return rec, nil
 	}
-	return errors.AssertionFailedf("unexpected problem while creating liveness record for node %d", nodeID)
+}
+
+// advanceNodeIDGenerator sets the node ID generator to the given node ID,
+// unless it changed since it was read as gen, in which case another allocation
+// advanced it already.
+func (ls storage) advanceNodeIDGenerator(
+	ctx context.Context, gen kv.KeyValue, nodeID roachpb.NodeID,
+) error {
+	var expValue []byte
+	if gen.Value != nil {
+		expValue = gen.Value.TagAndDataBytes()
+	}
+	var v roachpb.Value
+	v.SetInt(int64(nodeID))
+	err := ls.db.CPut(ctx, keys.NodeIDGenerator, &v, expValue)
+	if tErr := (*kvpb.ConditionFailedError)(nil); errors.As(err, &tErr) {
+		return nil
+	}
+	return err
 }
 
 // scan will iterate over the KV liveness names and generate liveness records from them.
@@ -185,6 +305,58 @@ func (ls storage) scan(ctx context.Context) ([]Record, error) {
 	if err != nil {
 		return nil, errors.Wrap(err, "unable to get liveness")
 	}
+	return ls.decodeLivenessRecords(ctx, kvs)
+}
+
+// scanAsOf is like scan, but reads the liveness records as they were at the
+// given timestamp. The timestamp needs to be above the GC threshold of the
+// liveness range, otherwise an error is returned.
+func (ls storage) scanAsOf(ctx context.Context, ts hlc.Timestamp) ([]Record, error) {
+	var kvs []kv.KeyValue
+	if err := ls.db.Txn(ctx, func(ctx context.Context, txn *kv.Txn) error {
+		if err := txn.SetFixedTimestamp(ctx, ts); err != nil {
+			return err
+		}
+		var err error
+		kvs, err = txn.Scan(ctx, keys.NodeLivenessPrefix, keys.NodeLivenessKeyMax, 0)
+		return err
+	}); err != nil {
+		return nil, errors.Wrapf(err, "unable to get liveness as of %s", ts)
+	}
+	return ls.decodeLivenessRecords(ctx, kvs)
+}
+
+// decodeRecord decodes a liveness record from its raw encoding. An empty
+// encoding decodes to an empty record.
+func (ls storage) decodeRecord(ctx context.Context, raw []byte) (Record, error) {
+	if len(raw) == 0 {
+		return Record{}, nil
+	}
+	liveness, err := decodeRawLiveness(raw)
+	if err != nil {
+		return Record{}, err
+	}
+	liveness.Normalize(ctx, ls.version, ls.defaultTTL)
+	return Record{Liveness: liveness, raw: raw}, nil
+}
+
+// decodeRawLiveness decodes a liveness record from its raw encoding as is,
+// without normalizing it.
+func decodeRawLiveness(raw []byte) (livenesspb.Liveness, error) {
+	var v roachpb.Value
+	v.SetTagAndData(raw)
+	var liveness livenesspb.Liveness
+	if err := v.GetProto(&liveness); err != nil {
+		return livenesspb.Liveness{}, errors.Wrap(err, "invalid liveness record")
+	}
+	return liveness, nil
+}
+
+// decodeLivenessRecords decodes the liveness records from the result of a scan
+// over the liveness key span.
+func (ls storage) decodeLivenessRecords(
+	ctx context.Context, kvs []kv.KeyValue,
+) ([]Record, error) {
 	var results []Record
 	for _, kv := range kvs {
 		if kv.Value == nil {
@@ -194,6 +366,7 @@ func (ls storage) scan(ctx context.Context) ([]Record, error) {
 		if err := kv.Value.GetProto(&liveness); err != nil {
 			return nil, errors.Wrap(err, "invalid liveness record")
 		}
+		liveness.Normalize(ctx, ls.version, ls.defaultTTL)
 
 		results = append(results, Record{
 			Liveness: liveness,
@@ -0,0 +1,295 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package liveness
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/settings"
+	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/randutil"
+	"github.com/cockroachdb/cockroach/pkg/util/stop"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/errors"
+)
+
+type failureDetectorMode int64
+
+const (
+	// failureDetectorRPC determines the connectivity of other nodes from the
+	// health of the RPC connections to them.
+	failureDetectorRPC failureDetectorMode = iota
+	// failureDetectorSWIM determines the connectivity of other nodes through
+	// SWIM-style direct and indirect probes.
+	failureDetectorSWIM
+)
+
+// FailureDetectorMode selects how the connectivity of other nodes, which is
+// fused with their liveness records into their vitality, is determined.
+var FailureDetectorMode = settings.RegisterEnumSetting(
+	settings.SystemOnly,
+	"kv.liveness.failure_detector.mode",
+	"how the connectivity of other nodes is determined: 'rpc' uses the health of the RPC "+
+		"connections to them, 'swim' uses SWIM-style direct and indirect probes, which "+
+		"distributes failure detection across the cluster",
+	"rpc",
+	map[int64]string{
+		int64(failureDetectorRPC):  "rpc",
+		int64(failureDetectorSWIM): "swim",
+	},
+)
+
+var swimProbeInterval = settings.RegisterDurationSetting(
+	settings.SystemOnly,
+	"kv.liveness.swim.probe_interval",
+	"the interval at which each node probes another node in SWIM mode",
+	time.Second,
+	settings.PositiveDuration,
+)
+
+var swimProbeTimeout = settings.RegisterDurationSetting(
+	settings.SystemOnly,
+	"kv.liveness.swim.probe_timeout",
+	"the time after which a direct or indirect probe is considered failed in SWIM mode",
+	500*time.Millisecond,
+	settings.PositiveDuration,
+)
+
+var swimIndirectProbes = settings.RegisterIntSetting(
+	settings.SystemOnly,
+	"kv.liveness.swim.indirect_probes",
+	"the number of peers asked to probe a node on our behalf when a direct probe fails in SWIM mode",
+	3,
+	settings.NonNegativeInt,
+)
+
+var swimSuspicionTimeout = settings.RegisterDurationSetting(
+	settings.SystemOnly,
+	"kv.liveness.swim.suspicion_timeout",
+	"the time a node remains suspect after failing a probe before it is considered "+
+		"disconnected in SWIM mode",
+	5*time.Second,
+	settings.NonNegativeDuration,
+)
+
+// Prober probes the reachability of other nodes for the SWIM failure detector.
+type Prober interface {
+	// Probe returns an error if the target node is not reachable from the
+	// local node.
+	Probe(ctx context.Context, target roachpb.NodeID) error
+	// ProbeVia asks the via node to probe the target node on behalf of the
+	// local node, returning an error if the target is not reachable from it (or
+	// if the via node itself isn't reachable).
+	ProbeVia(ctx context.Context, via, target roachpb.NodeID) error
+}
+
+// swimDetector is a failure detector in the style of SWIM[1]. Each protocol
+// period, it probes one node, going through all nodes in a random order. If
+// the direct probe fails, a few other nodes are asked to probe the node on our
+// behalf, which avoids declaring a node failed because of a problem with the
+// link between the two nodes. A node that fails both is suspect, and is
+// considered disconnected once it has been suspect for the suspicion timeout.
+//
+// Unlike full SWIM, suspicions are not disseminated to other nodes: every node
+// forms its own view, which then feeds the connectivity in its NodeVitality.
+//
+// [1]: https://www.cs.cornell.edu/projects/Quicksilver/public_pdfs/SWIM.pdf
+type swimDetector struct {
+	st      *cluster.Settings
+	clock   *hlc.Clock
+	stopper *stop.Stopper
+	prober  Prober
+	// members returns the nodes to probe, excluding the local node.
+	members func() []roachpb.NodeID
+
+	mu struct {
+		syncutil.Mutex
+		rng *rand.Rand
+		// queue contains the nodes left to probe in the current round.
+		queue []roachpb.NodeID
+		// lastAck is the time of the last successful probe of each node.
+		lastAck map[roachpb.NodeID]hlc.Timestamp
+		// suspectSince is the time of the first failed probe of each node
+		// suspected to have failed.
+		suspectSince map[roachpb.NodeID]hlc.Timestamp
+	}
+}
+
+func newSWIMDetector(
+	st *cluster.Settings,
+	clock *hlc.Clock,
+	stopper *stop.Stopper,
+	prober Prober,
+	members func() []roachpb.NodeID,
+) *swimDetector {
+	d := &swimDetector{
+		st:      st,
+		clock:   clock,
+		stopper: stopper,
+		prober:  prober,
+		members: members,
+	}
+	d.mu.rng, _ = randutil.NewPseudoRand()
+	d.mu.lastAck = make(map[roachpb.NodeID]hlc.Timestamp)
+	d.mu.suspectSince = make(map[roachpb.NodeID]hlc.Timestamp)
+	return d
+}
+
+// enabled returns whether the SWIM failure detector is selected.
+func (d *swimDetector) enabled() bool {
+	return failureDetectorMode(FailureDetectorMode.Get(&d.st.SV)) == failureDetectorSWIM
+}
+
+// run probes a node every probe interval until the stopper quiesces.
+func (d *swimDetector) run(ctx context.Context) {
+	var timer timeutil.Timer
+	defer timer.Stop()
+	for {
+		timer.Reset(swimProbeInterval.Get(&d.st.SV))
+		select {
+		case <-timer.C:
+			timer.Read = true
+			if d.enabled() {
+				d.probeNext(ctx)
+			}
+		case <-d.stopper.ShouldQuiesce():
+			return
+		}
+	}
+}
+
+// probeNext probes the next node of the current round.
+func (d *swimDetector) probeNext(ctx context.Context) {
+	target, ok := d.nextTarget()
+	if !ok {
+		return
+	}
+	timeout := swimProbeTimeout.Get(&d.st.SV)
+	err := timeutil.RunWithTimeout(ctx, "swim probe", timeout, func(ctx context.Context) error {
+		return d.prober.Probe(ctx, target)
+	})
+	if err != nil {
+		err = d.probeIndirectly(ctx, target, timeout)
+	}
+	d.recordProbe(target, err == nil, d.clock.Now())
+}
+
+// nextTarget returns the next node to probe, starting a new round in a new
+// random order once all nodes have been probed.
+func (d *swimDetector) nextTarget() (roachpb.NodeID, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.mu.queue) == 0 {
+		d.mu.queue = d.members()
+		d.mu.rng.Shuffle(len(d.mu.queue), func(i, j int) {
+			d.mu.queue[i], d.mu.queue[j] = d.mu.queue[j], d.mu.queue[i]
+		})
+	}
+	if len(d.mu.queue) == 0 {
+		return 0, false
+	}
+	target := d.mu.queue[0]
+	d.mu.queue = d.mu.queue[1:]
+	return target, true
+}
+
+// probeIndirectly asks up to kv.liveness.swim.indirect_probes other nodes to
+// probe the target in parallel, returning nil as soon as one of them succeeds.
+func (d *swimDetector) probeIndirectly(
+	ctx context.Context, target roachpb.NodeID, timeout time.Duration,
+) error {
+	helpers := d.pickHelpers(target, int(swimIndirectProbes.Get(&d.st.SV)))
+	if len(helpers) == 0 {
+		return errors.Errorf("no peers available to probe n%d indirectly", target)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	results := make(chan error, len(helpers))
+	for _, via := range helpers {
+		via := via
+		if err := d.stopper.RunAsyncTask(ctx, "swim-indirect-probe", func(ctx context.Context) {
+			results <- d.prober.ProbeVia(ctx, via, target)
+		}); err != nil {
+			results <- err
+		}
+	}
+	var err error
+	for range helpers {
+		probeErr := <-results
+		if probeErr == nil {
+			return nil
+		}
+		err = errors.CombineErrors(err, probeErr)
+	}
+	return err
+}
+
+// pickHelpers returns up to n random nodes, other than the target, that aren't
+// suspect themselves.
+func (d *swimDetector) pickHelpers(target roachpb.NodeID, n int) []roachpb.NodeID {
+	members := d.members()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.mu.rng.Shuffle(len(members), func(i, j int) {
+		members[i], members[j] = members[j], members[i]
+	})
+	var helpers []roachpb.NodeID
+	for _, nodeID := range members {
+		if len(helpers) >= n {
+			break
+		}
+		if _, suspect := d.mu.suspectSince[nodeID]; nodeID == target || suspect {
+			continue
+		}
+		helpers = append(helpers, nodeID)
+	}
+	return helpers
+}
+
+// recordProbe records the outcome of probing the target at the given time.
+func (d *swimDetector) recordProbe(target roachpb.NodeID, ok bool, now hlc.Timestamp) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if ok {
+		d.mu.lastAck[target] = now
+		delete(d.mu.suspectSince, target)
+		return
+	}
+	if _, suspect := d.mu.suspectSince[target]; !suspect {
+		d.mu.suspectSince[target] = now
+	}
+}
+
+// connectivity returns the connectivity of the given node according to the
+// probes so far. A node that was never probed, or that is suspect but hasn't
+// been for the suspicion timeout yet, has unknown connectivity.
+func (d *swimDetector) connectivity(
+	nodeID roachpb.NodeID, now hlc.Timestamp,
+) livenesspb.Connectivity {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if since, suspect := d.mu.suspectSince[nodeID]; suspect {
+		if since.AddDuration(swimSuspicionTimeout.Get(&d.st.SV)).LessEq(now) {
+			return livenesspb.Connectivity_DISCONNECTED
+		}
+		return livenesspb.Connectivity_UNKNOWN
+	}
+	if _, ok := d.mu.lastAck[nodeID]; ok {
+		return livenesspb.Connectivity_CONNECTED
+	}
+	return livenesspb.Connectivity_UNKNOWN
+}
@@ -0,0 +1,72 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package liveness
+
+import (
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/rpc"
+	"github.com/cockroachdb/errors"
+)
+
+// GetNodeVitality returns the vitality of the given node, fusing the liveness
+// record found in the cache with the state of the RPC connection to the node.
+// ok is false if there is no liveness record for the node in the cache.
+func (nl *NodeLiveness) GetNodeVitality(nodeID roachpb.NodeID) (_ livenesspb.NodeVitality, ok bool) {
+	rec, ok := nl.cache.GetLiveness(nodeID)
+	if !ok {
+		return livenesspb.NodeVitality{}, false
+	}
+	return nl.makeNodeVitality(rec.Liveness), true
+}
+
+// ScanNodeVitalityFromCache returns the vitality of all nodes that have a
+// liveness record in the cache, including decommissioned ones.
+func (nl *NodeLiveness) ScanNodeVitalityFromCache() livenesspb.NodeVitalityMap {
+	livenesses := nl.cache.getAllLivenesses()
+	m := make(livenesspb.NodeVitalityMap, len(livenesses))
+	for _, l := range livenesses {
+		m[l.NodeID] = nl.makeNodeVitality(l)
+	}
+	return m
+}
+
+func (nl *NodeLiveness) makeNodeVitality(l livenesspb.Liveness) livenesspb.NodeVitality {
+	return livenesspb.NodeVitality{
+		Liveness:     l,
+		Connectivity: nl.connectivity(l.NodeID),
+	}
+}
+
+// connectivity returns what the RPC layer knows about the connection to the
+// given node. A connection that hasn't been heartbeated yet (or doesn't exist)
+// provides no evidence either way and is reported as UNKNOWN. In SWIM mode,
+// the verdict of the SWIM failure detector is returned instead.
+func (nl *NodeLiveness) connectivity(nodeID roachpb.NodeID) livenesspb.Connectivity {
+	if nodeID == nl.cache.selfID() {
+		return livenesspb.Connectivity_CONNECTED
+	}
+	if nl.swim != nil && nl.swim.enabled() {
+		return nl.swim.connectivity(nodeID, nl.clock.Now())
+	}
+	if nl.nodeDialer == nil {
+		return livenesspb.Connectivity_UNKNOWN
+	}
+	err := nl.nodeDialer.ConnHealth(nodeID, rpc.SystemClass)
+	switch {
+	case err == nil:
+		return livenesspb.Connectivity_CONNECTED
+	case errors.Is(err, rpc.ErrNotHeartbeated):
+		return livenesspb.Connectivity_UNKNOWN
+	default:
+		return livenesspb.Connectivity_DISCONNECTED
+	}
+}
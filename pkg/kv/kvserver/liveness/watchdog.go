@@ -0,0 +1,180 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package liveness
+
+import (
+	"context"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/settings"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/cockroach/pkg/util/tracing"
+	"github.com/cockroachdb/redact"
+)
+
+var heartbeatWatchdogThreshold = settings.RegisterDurationSetting(
+	settings.SystemOnly,
+	"kv.liveness.heartbeat_watchdog.threshold",
+	"the duration after which a liveness heartbeat attempt is considered wedged, in which case "+
+		"stacks and the trace of the attempt are dumped and the attempt is canceled, and the "+
+		"heartbeat loop is restarted if the attempt is still wedged after that long again "+
+		"(0 disables the watchdog)",
+	time.Minute,
+	settings.NonNegativeDuration,
+)
+
+// minHeartbeatWatchdogInterval is the shortest interval at which the watchdog
+// checks on the heartbeat loop.
+const minHeartbeatWatchdogInterval = time.Second
+
+// heartbeatWatchdog tracks the attempts of the heartbeat loop of the local
+// node, so that a loop that wedges, e.g. on a mutex or a stalled KV call, is
+// noticed instead of just looking like a node failing its liveness.
+type heartbeatWatchdog struct {
+	syncutil.Mutex
+	// generation is the generation of the current heartbeat loop. It is bumped
+	// when the watchdog replaces a wedged loop.
+	generation int64
+	// start is the time at which the current attempt started, or at which the
+	// watchdog last acted on it. Zero while the loop is idle.
+	start time.Time
+	// span traces the current attempt, and cancel cancels it.
+	span   *tracing.Span
+	cancel func()
+	// canceled is set once the watchdog canceled the current attempt.
+	canceled bool
+	// abandoned is the number of replaced loops that are still wedged. A loop
+	// is only replaced if no other one is, so that a systemic problem doesn't
+	// leak a goroutine per threshold.
+	abandoned int
+	// heartbeated is set once the loop heartbeated successfully for the first
+	// time, so that a replacement loop knows whether to increment the epoch of
+	// the local node.
+	heartbeated bool
+}
+
+// beginAttempt records the start of a heartbeat attempt by the loop of the
+// given generation.
+func (w *heartbeatWatchdog) beginAttempt(generation int64, sp *tracing.Span, cancel func()) {
+	w.Lock()
+	defer w.Unlock()
+	if generation != w.generation {
+		return
+	}
+	w.start = timeutil.Now()
+	w.span = sp
+	w.cancel = cancel
+	w.canceled = false
+}
+
+// endAttempt records the end of a heartbeat attempt by the loop of the given
+// generation, and returns whether the loop was replaced in the meantime, in
+// which case it must exit without handing back the heartbeat token.
+func (w *heartbeatWatchdog) endAttempt(generation int64, heartbeated bool) (superseded bool) {
+	w.Lock()
+	defer w.Unlock()
+	if generation != w.generation {
+		w.abandoned--
+		return true
+	}
+	w.start = time.Time{}
+	w.span = nil
+	w.cancel = nil
+	w.heartbeated = heartbeated
+	return false
+}
+
+// runHeartbeatWatchdog periodically checks on the heartbeat loop until the
+// stopper quiesces. See checkHeartbeatLoop.
+func (nl *NodeLiveness) runHeartbeatWatchdog(ctx context.Context) {
+	var timer timeutil.Timer
+	defer timer.Stop()
+	for {
+		interval := heartbeatWatchdogThreshold.Get(&nl.st.SV) / 4
+		if interval < minHeartbeatWatchdogInterval {
+			interval = minHeartbeatWatchdogInterval
+		}
+		timer.Reset(interval)
+		select {
+		case <-timer.C:
+			timer.Read = true
+			nl.checkHeartbeatLoop(ctx, timeutil.Now())
+		case <-nl.stopper.ShouldQuiesce():
+			return
+		}
+	}
+}
+
+// checkHeartbeatLoop checks whether the current attempt of the heartbeat loop
+// has been running for longer than kv.liveness.heartbeat_watchdog.threshold at
+// the given time. If so, it dumps the stacks and the trace of the attempt, and
+// cancels it, which unwedges attempts stuck on a KV call. If the attempt is
+// still running after that long again, the loop is likely stuck on something
+// that doesn't heed cancellation, and is replaced by a new loop, unless a
+// previously replaced loop is still wedged.
+func (nl *NodeLiveness) checkHeartbeatLoop(ctx context.Context, now time.Time) {
+	threshold := heartbeatWatchdogThreshold.Get(&nl.st.SV)
+	w := &nl.watchdog
+	w.Lock()
+	if threshold == 0 || w.start.IsZero() || now.Sub(w.start) < threshold {
+		w.Unlock()
+		return
+	}
+	stalled := now.Sub(w.start)
+	if !w.canceled {
+		nl.metrics.HeartbeatLoopStalls.Inc(1)
+		// The recording is taken under the lock, which the attempt acquires
+		// before finishing its span.
+		var recording redact.RedactableString
+		if w.span != nil {
+			recording = redact.Sprint(w.span.GetConfiguredRecording())
+		}
+		cancel := w.cancel
+		w.canceled = true
+		w.start = now
+		w.Unlock()
+
+		// Dumping the stacks takes a while, so it's done outside of the lock,
+		// which the wedged attempt may need to end.
+		log.Warningf(ctx, "liveness heartbeat attempt wedged for %s; canceling it. trace:\n%s",
+			stalled, recording)
+		log.DumpStacks(ctx, redact.Sprintf("liveness heartbeat attempt wedged for %s", stalled))
+		if cancel != nil {
+			cancel()
+		}
+		return
+	}
+	defer w.Unlock()
+	if w.abandoned > 0 {
+		log.Warningf(ctx, "liveness heartbeat loop still wedged %s after canceling its attempt; "+
+			"not restarting it since a previously restarted loop is wedged too", stalled)
+		return
+	}
+	log.Warningf(ctx, "liveness heartbeat loop still wedged %s after canceling its attempt; "+
+		"restarting it", stalled)
+	nl.metrics.HeartbeatLoopRestarts.Inc(1)
+	// The wedged loop holds the heartbeat token, and drops it once it notices
+	// that it was replaced, so the new loop gets a token of its own.
+	w.generation++
+	w.abandoned++
+	w.start = time.Time{}
+	w.span = nil
+	w.cancel = nil
+	select {
+	case nl.heartbeatToken <- struct{}{}:
+	default:
+		// A token is available already, so the new loop doesn't need one of its
+		// own.
+	}
+	nl.startHeartbeatLoop(ctx, w.generation, !w.heartbeated)
+}
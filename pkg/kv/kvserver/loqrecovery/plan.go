@@ -177,8 +177,18 @@ func PlanReplicas(
 	}
 	report.UpdatedNodes = updatedLocations.asSortedSlice()
 
-	var decommissionNodeIDs []roachpb.NodeID
+	// Nodes listed as dead are decommissioned even if they hold no replicas,
+	// so that the recovered cluster doesn't carry over liveness records of
+	// nodes that no longer exist.
+	decommissionNodes := make(map[roachpb.NodeID]struct{}, len(deadNodes)+len(deadNodeIDs))
 	for id := range deadNodes {
+		decommissionNodes[id] = struct{}{}
+	}
+	for _, id := range deadNodeIDs {
+		decommissionNodes[id] = struct{}{}
+	}
+	var decommissionNodeIDs []roachpb.NodeID
+	for id := range decommissionNodes {
 		decommissionNodeIDs = append(decommissionNodeIDs, id)
 	}
 	sort.Sort(roachpb.NodeIDSlice(decommissionNodeIDs))
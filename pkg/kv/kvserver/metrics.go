@@ -140,6 +140,18 @@ var (
 		Measurement: "Latency",
 		Unit:        metric.Unit_NANOSECONDS,
 	}
+	metaLeaseLivenessVerifications = metric.Metadata{
+		Name:        "leases.liveness_verification.checks",
+		Help:        "Number of epoch-based lease requests whose liveness record was double-checked against KV",
+		Measurement: "Lease Requests",
+		Unit:        metric.Unit_COUNT,
+	}
+	metaLeaseLivenessDivergences = metric.Metadata{
+		Name:        "leases.liveness_verification.divergences",
+		Help:        "Number of epoch-based lease requests found to rely on a cached liveness record diverging from KV",
+		Measurement: "Lease Requests",
+		Unit:        metric.Unit_COUNT,
+	}
 	metaLeaseTransferSuccessCount = metric.Metadata{
 		Name:        "leases.transfers.success",
 		Help:        "Number of successful lease transfers",
@@ -2073,6 +2085,12 @@ type StoreMetrics struct {
 	LeaseEpochCount           *metric.Gauge
 	LeaseLivenessCount        *metric.Gauge
 
+	// LeaseLivenessVerifications and LeaseLivenessDivergences track the
+	// verification of the liveness records epoch-based leases rely on, see
+	// LeaseLivenessVerificationSampleRate.
+	LeaseLivenessVerifications *metric.Counter
+	LeaseLivenessDivergences   *metric.Counter
+
 	// Storage metrics.
 	ResolveCommitCount *metric.Counter
 	ResolveAbortCount  *metric.Counter
@@ -2712,6 +2730,9 @@ func newStoreMetrics(histogramWindow time.Duration) *StoreMetrics {
 		LeaseEpochCount:           metric.NewGauge(metaLeaseEpochCount),
 		LeaseLivenessCount:        metric.NewGauge(metaLeaseLivenessCount),
 
+		LeaseLivenessVerifications: metric.NewCounter(metaLeaseLivenessVerifications),
+		LeaseLivenessDivergences:   metric.NewCounter(metaLeaseLivenessDivergences),
+
 		// Intent resolution metrics.
 		ResolveCommitCount: metric.NewCounter(metaResolveCommit),
 		ResolveAbortCount:  metric.NewCounter(metaResolveAbort),
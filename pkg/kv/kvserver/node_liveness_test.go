@@ -149,7 +149,21 @@ func TestNodeLiveness(t *testing.T) {
 		if c := nl.Metrics().HeartbeatSuccesses.Count(); c < 2 {
 			t.Errorf("node %d: expected metrics count >= 2; got %d", (i + 1), c)
 		}
+		// The manual heartbeat renewed a record that had an expiration.
+		if c, _ := nl.Metrics().RenewalMargin.Total(); c < 1 {
+			t.Errorf("node %d: expected renewal margin count >= 1; got %d", (i + 1), c)
+		}
 	}
+	// The heartbeats of the other nodes reach every node through gossip.
+	testutils.SucceedsSoon(t, func() error {
+		for i, s := range tc.Servers {
+			nl := s.NodeLiveness().(*liveness.NodeLiveness)
+			if c, _ := nl.Metrics().GossipPropagationLatency.Total(); c < 1 {
+				return errors.Errorf("node %d: expected gossip propagation count >= 1; got %d", (i + 1), c)
+			}
+		}
+		return nil
+	})
 }
 
 func TestNodeLivenessInitialIncrement(t *testing.T) {
@@ -453,8 +467,38 @@ func TestNodeLivenessEpochIncrement(t *testing.T) {
 		t.Fatalf("expected error incrementing a live node: %+v", err)
 	}
 
+	// Increments need a reason, can't target the incrementing node, and can't
+	// claim to have observed the record in the future.
+	nl := tc.Servers[0].NodeLiveness().(*liveness.NodeLiveness)
+	observedAt := tc.Servers[0].Clock().NowAsClockTimestamp()
+	self, ok := nl.Self()
+	require.True(t, ok)
+	future := observedAt
+	future.WallTime += nl.GetLivenessThreshold().Nanoseconds()
+	for _, c := range []struct {
+		req    liveness.EpochIncrementRequest
+		expErr string
+	}{
+		{req: liveness.EpochIncrementRequest{Observed: oldLiveness.Liveness, Now: observedAt},
+			expErr: "no reason given"},
+		{req: liveness.EpochIncrementRequest{Observed: self, Now: observedAt, Reason: "test"},
+			expErr: "cannot increment its own epoch"},
+		{req: liveness.EpochIncrementRequest{Observed: oldLiveness.Liveness, Now: future, Reason: "test"},
+			expErr: "is ahead of the clock"},
+	} {
+		if err := nl.IncrementEpochOf(ctx, c.req); !testutils.IsError(err, c.expErr) {
+			t.Fatalf("expected error %q, got %+v", c.expErr, err)
+		}
+	}
+
 	// Advance clock past liveness threshold & increment epoch.
 	manualClock.Increment(tc.Servers[0].NodeLiveness().(*liveness.NodeLiveness).GetLivenessThreshold().Nanoseconds() + 1)
+	// The record must have been expired when it was observed, not only now.
+	if err := nl.IncrementEpochOf(ctx, liveness.EpochIncrementRequest{
+		Observed: oldLiveness.Liveness, Now: observedAt, Reason: "test",
+	}); !testutils.IsError(err, "cannot increment epoch on live node") {
+		t.Fatalf("expected error incrementing a node observed live: %+v", err)
+	}
 	if err := tc.Servers[0].NodeLiveness().(*liveness.NodeLiveness).IncrementEpoch(ctx, oldLiveness.Liveness); err != nil {
 		t.Fatalf("unexpected error incrementing a non-live node: %+v", err)
 	}
@@ -484,6 +528,13 @@ func TestNodeLivenessEpochIncrement(t *testing.T) {
 		t.Errorf("expected epoch increment == 1; got %d", c)
 	}
 
+	// Verify that the increment was recorded, along with its origin.
+	newLiveness, ok := tc.Servers[0].NodeLiveness().(*liveness.NodeLiveness).GetLiveness(deadNodeID)
+	require.True(t, ok)
+	require.Equal(t, newLiveness.Epoch, newLiveness.LastEpochIncrement.Epoch)
+	require.Equal(t, tc.Servers[0].NodeID(), newLiveness.LastEpochIncrement.IncrementerNodeID)
+	require.Equal(t, liveness.EpochIncrementReasonExpired, newLiveness.LastEpochIncrement.Reason)
+
 	// Verify error on incrementing an already-incremented epoch.
 	if err := tc.Servers[0].NodeLiveness().(*liveness.NodeLiveness).IncrementEpoch(
 		ctx, oldLiveness.Liveness,
@@ -680,9 +731,9 @@ func TestNodeLivenessGetIsLiveMap(t *testing.T) {
 	l2, _ := nl.GetLiveness(2)
 	l3, _ := nl.GetLiveness(3)
 	expectedLMap := livenesspb.IsLiveMap{
-		1: {Liveness: l1.Liveness, IsLive: true},
-		2: {Liveness: l2.Liveness, IsLive: true},
-		3: {Liveness: l3.Liveness, IsLive: true},
+		1: {Liveness: l1.Liveness, IsLive: true, LivenessStatus: livenesspb.NodeLivenessStatus_LIVE},
+		2: {Liveness: l2.Liveness, IsLive: true, LivenessStatus: livenesspb.NodeLivenessStatus_LIVE},
+		3: {Liveness: l3.Liveness, IsLive: true, LivenessStatus: livenesspb.NodeLivenessStatus_LIVE},
 	}
 	if !reflect.DeepEqual(expectedLMap, lMap) {
 		t.Errorf("expected liveness map %+v; got %+v", expectedLMap, lMap)
@@ -716,9 +767,10 @@ func TestNodeLivenessGetIsLiveMap(t *testing.T) {
 	l2, _ = nl.GetLiveness(2)
 	l3, _ = nl.GetLiveness(3)
 	expectedLMap = livenesspb.IsLiveMap{
-		1: {Liveness: l1.Liveness, IsLive: true},
-		2: {Liveness: l2.Liveness, IsLive: false},
-		3: {Liveness: l3.Liveness, IsLive: false},
+		1: {Liveness: l1.Liveness, IsLive: true, LivenessStatus: livenesspb.NodeLivenessStatus_LIVE},
+		// Nodes 2 and 3 expired, but not for long enough to be considered dead.
+		2: {Liveness: l2.Liveness, IsLive: false, LivenessStatus: livenesspb.NodeLivenessStatus_UNAVAILABLE},
+		3: {Liveness: l3.Liveness, IsLive: false, LivenessStatus: livenesspb.NodeLivenessStatus_UNAVAILABLE},
 	}
 	if !reflect.DeepEqual(expectedLMap, lMap) {
 		t.Errorf("expected liveness map %+v; got %+v", expectedLMap, lMap)
@@ -1067,6 +1119,167 @@ func TestNodeLivenessRetryAmbiguousResultError(t *testing.T) {
 	}
 }
 
+// TestNodeLivenessHeartbeatsFailing verifies that a node reports its
+// heartbeats as failing from a failed heartbeat until the next successful one,
+// which records the failure in the liveness record.
+func TestNodeLivenessHeartbeatsFailing(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	var injectError atomic.Bool
+	testingEvalFilter := func(args kvserverbase.FilterArgs) *kvpb.Error {
+		if _, ok := args.Req.(*kvpb.ConditionalPutRequest); !ok {
+			return nil
+		}
+		if injectError.Load() && args.Req.Header().Key.Equal(keys.NodeLivenessKey(1)) {
+			return kvpb.NewErrorf("injected heartbeat error")
+		}
+		return nil
+	}
+	ctx := context.Background()
+	serv, _, _ := serverutils.StartServer(t, base.TestServerArgs{
+		Knobs: base.TestingKnobs{
+			Store: &kvserver.StoreTestingKnobs{
+				EvalKnobs: kvserverbase.BatchEvalTestingKnobs{
+					TestingEvalFilter: testingEvalFilter,
+				},
+			},
+		},
+	})
+	s := serv.(*server.TestServer)
+	defer s.Stopper().Stop(ctx)
+
+	testutils.SucceedsSoon(t, func() error {
+		return verifyLivenessServer(s, 1)
+	})
+	nl := s.NodeLiveness().(*liveness.NodeLiveness)
+	l, ok := nl.Self()
+	require.True(t, ok)
+	require.NoError(t, nl.Heartbeat(ctx, l))
+	require.False(t, nl.HeartbeatsFailing())
+
+	require.Zero(t, nl.HeartbeatFailures().Consecutive)
+
+	injectError.Store(true)
+	require.Error(t, nl.Heartbeat(ctx, l))
+	require.True(t, nl.HeartbeatsFailing())
+	failures := nl.HeartbeatFailures()
+	require.Positive(t, failures.Consecutive)
+	require.Contains(t, failures.LastError, "injected heartbeat error")
+
+	// A single failure, with the record far from expiring, doesn't make the
+	// node likely to lose its liveness, but repeated failures do.
+	require.False(t, nl.LikelyToLoseLiveness())
+	for nl.HeartbeatFailures().Consecutive < 3 {
+		require.Error(t, nl.Heartbeat(ctx, l))
+	}
+	require.True(t, nl.LikelyToLoseLiveness())
+
+	injectError.Store(false)
+	testutils.SucceedsSoon(t, func() error {
+		l, _ := nl.Self()
+		if err := nl.Heartbeat(ctx, l); err != nil {
+			return err
+		}
+		if nl.HeartbeatsFailing() {
+			return errors.New("heartbeats still failing")
+		}
+		return nil
+	})
+	// The last failure outlives the recovery, in the liveness record too.
+	l, ok = nl.Self()
+	require.True(t, ok)
+	require.Contains(t, l.HeartbeatFailures.LastError, "injected heartbeat error")
+	require.False(t, l.HeartbeatFailures.LastFailure.IsEmpty())
+}
+
+// TestNodeLivenessReportExternalFailure verifies that the reports of external
+// probes that a node is gone are recorded in the node's liveness record, that
+// they only take effect once another node corroborates them, and that the node
+// clears them when it heartbeats since it isn't gone after all.
+func TestNodeLivenessReportExternalFailure(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := testcluster.StartTestCluster(t, 3, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	defer tc.Stopper().Stop(ctx)
+	verifyLiveness(t, tc)
+	_, err := tc.ServerConn(0).Exec(`SET CLUSTER SETTING kv.liveness.external_probes.enabled = true`)
+	require.NoError(t, err)
+
+	nl1 := tc.Servers[0].NodeLiveness().(*liveness.NodeLiveness)
+	nl2 := tc.Servers[1].NodeLiveness().(*liveness.NodeLiveness)
+	nl3 := tc.Servers[2].NodeLiveness().(*liveness.NodeLiveness)
+	n2 := tc.Servers[1].NodeID()
+	// Keep n2 from clearing the report before we get to look at it.
+	defer nl2.PauseHeartbeatLoopForTest()()
+
+	getLiveness := func() livenesspb.Liveness {
+		livenesses, err := nl1.GetLivenessesFromKV(ctx)
+		require.NoError(t, err)
+		for _, l := range livenesses {
+			if l.NodeID == n2 {
+				return l
+			}
+		}
+		t.Fatalf("no liveness record for n%d", n2)
+		return livenesspb.Liveness{}
+	}
+	// The setting takes a moment to propagate to all nodes, and the reports
+	// are ignored until then.
+	report := func(nl *liveness.NodeLiveness, probe, reason string, done func(livenesspb.Liveness) bool) {
+		testutils.SucceedsSoon(t, func() error {
+			if err := nl.ReportExternalFailure(ctx, probe, n2, reason); err != nil {
+				return err
+			}
+			if !done(getLiveness()) {
+				return errors.New("report not recorded yet")
+			}
+			return nil
+		})
+	}
+
+	require.Error(t, nl1.ReportExternalFailure(ctx, "test", tc.Servers[0].NodeID(), "terminated"))
+
+	// The first report is recorded, but isn't in effect on its own.
+	report(nl1, "test", "instance terminated", func(l livenesspb.Liveness) bool {
+		return l.ExternalFailure.Reported()
+	})
+	l := getLiveness()
+	require.False(t, l.ReportedGone())
+	require.Equal(t, "test", l.ExternalFailure.Probe)
+	require.Equal(t, "instance terminated", l.ExternalFailure.Reason)
+	require.Equal(t, tc.Servers[0].NodeID(), l.ExternalFailure.ReporterNodeID)
+
+	// The reporter can't corroborate its own report.
+	require.NoError(t, nl1.ReportExternalFailure(ctx, "other", n2, "node not ready"))
+	require.Equal(t, l.ExternalFailure, getLiveness().ExternalFailure)
+	require.Equal(t, int64(1), nl1.Metrics().ExternalFailureReports.Count())
+
+	// Another node can, which puts the report in effect.
+	report(nl3, "other", "node not ready", func(l livenesspb.Liveness) bool {
+		return l.ReportedGone()
+	})
+	l = getLiveness()
+	require.Equal(t, "test", l.ExternalFailure.Probe)
+	require.Equal(t, []roachpb.NodeID{tc.Servers[2].NodeID()}, l.ExternalFailure.CorroboratingNodeIDs)
+	require.Equal(t, int64(1), nl3.Metrics().ExternalFailureReports.Count())
+
+	testutils.SucceedsSoon(t, func() error {
+		self, _ := nl2.Self()
+		if err := nl2.Heartbeat(ctx, self); err != nil {
+			return err
+		}
+		if getLiveness().ExternalFailure.Reported() {
+			return errors.New("external failure not cleared yet")
+		}
+		return nil
+	})
+}
+
 // This tests the create code path for node liveness, for that we need to create
 // a cluster of nodes, because we need to exercise to join RPC codepath.
 func TestNodeLivenessRetryAmbiguousResultOnCreateError(t *testing.T) {
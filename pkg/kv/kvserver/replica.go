@@ -1057,6 +1057,15 @@ func (r *Replica) GetNodeLocality() roachpb.Locality {
 	return r.store.nodeDesc.Locality
 }
 
+// HeartbeatAggregatorOf returns the node that writes the liveness renewals of
+// the given node on its behalf.
+func (r *Replica) HeartbeatAggregatorOf(nodeID roachpb.NodeID) roachpb.NodeID {
+	if r.store.cfg.NodeLiveness == nil {
+		return nodeID
+	}
+	return r.store.cfg.NodeLiveness.HeartbeatAggregatorOf(nodeID)
+}
+
 // ClusterSettings returns the node's ClusterSettings.
 func (r *Replica) ClusterSettings() *cluster.Settings {
 	return r.store.cfg.Settings
@@ -1029,6 +1029,10 @@ func (r *Replica) changeReplicasImpl(
 		return nil, errors.Mark(err, errMarkInvalidReplicationChange)
 	}
 	targets := SynthesizeTargetsByChangeType(chgs)
+	// Snapshots sent by the replicate queue for changes that remove a replica
+	// from a decommissioning node are moving that replica off the node.
+	decommissioning := senderName == kvserverpb.SnapshotRequest_REPLICATE_QUEUE &&
+		r.removesDecommissioningReplica(chgs)
 
 	// NB: As of the time of this writing,`AdminRelocateRange` will only execute
 	// replication changes one by one. Thus, the order in which we execute the
@@ -1078,7 +1082,8 @@ func (r *Replica) changeReplicasImpl(
 		_ = roachpb.ReplicaSet.LearnerDescriptors
 		var err error
 		desc, err = r.initializeRaftLearners(
-			ctx, desc, priority, senderName, senderQueuePriority, reason, details, adds, roachpb.LEARNER,
+			ctx, desc, priority, senderName, senderQueuePriority, decommissioning, reason, details,
+			adds, roachpb.LEARNER,
 		)
 		if err != nil {
 			return nil, err
@@ -1124,7 +1129,8 @@ func (r *Replica) changeReplicasImpl(
 		// disruption to foreground traffic. See
 		// https://github.com/cockroachdb/cockroach/issues/63199 for an example.
 		desc, err = r.initializeRaftLearners(
-			ctx, desc, priority, senderName, senderQueuePriority, reason, details, adds, roachpb.NON_VOTER,
+			ctx, desc, priority, senderName, senderQueuePriority, decommissioning, reason, details,
+			adds, roachpb.NON_VOTER,
 		)
 		if err != nil {
 			return nil, err
@@ -1694,6 +1700,7 @@ func (r *Replica) initializeRaftLearners(
 	priority kvserverpb.SnapshotRequest_Priority,
 	senderName kvserverpb.SnapshotRequest_QueueName,
 	senderQueuePriority float64,
+	decommissioning bool,
 	reason kvserverpb.RangeLogEventReason,
 	details string,
 	targets []roachpb.ReplicationTarget,
@@ -1842,6 +1849,7 @@ func (r *Replica) initializeRaftLearners(
 		// these, it would be susceptible to future similar issues.
 		if err := r.sendSnapshotUsingDelegate(
 			ctx, rDesc, kvserverpb.SnapshotRequest_INITIAL, priority, senderName, senderQueuePriority,
+			decommissioning,
 		); err != nil {
 			return nil, err
 		}
@@ -2774,6 +2782,7 @@ func (r *Replica) sendSnapshotUsingDelegate(
 	priority kvserverpb.SnapshotRequest_Priority,
 	senderQueueName kvserverpb.SnapshotRequest_QueueName,
 	senderQueuePriority float64,
+	decommissioning bool,
 ) (retErr error) {
 
 	defer func() {
@@ -2838,6 +2847,7 @@ func (r *Replica) sendSnapshotUsingDelegate(
 		DescriptorGeneration: r.Desc().Generation,
 		QueueOnDelegateLen:   MaxQueueOnDelegateLimit.Get(&r.ClusterSettings().SV),
 		SnapId:               snapUUID,
+		Decommissioning:      decommissioning,
 	}
 
 	// Get the list of senders in order.
@@ -3037,6 +3047,25 @@ var traceSnapshotThreshold = settings.RegisterDurationSetting(
 		"trace logged (set to 0 to disable);", 0,
 )
 
+// removesDecommissioningReplica returns whether the given changes remove a
+// replica from a node that is decommissioning.
+func (r *Replica) removesDecommissioningReplica(chgs kvpb.ReplicationChanges) bool {
+	nl := r.store.cfg.NodeLiveness
+	if nl == nil {
+		return false
+	}
+	for _, removals := range [][]roachpb.ReplicationTarget{
+		chgs.VoterRemovals(), chgs.NonVoterRemovals(),
+	} {
+		for _, target := range removals {
+			if l, ok := nl.GetLiveness(target.NodeID); ok && l.Membership.Decommissioning() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // followerSendSnapshot receives a delegate snapshot request and generates the
 // snapshot from this replica. The entire process of generating and transmitting
 // the snapshot is handled, and errors are propagated back to the leaseholder.
@@ -3135,6 +3164,7 @@ func (r *Replica) followerSendSnapshot(
 		SenderQueuePriority: req.SenderQueuePriority,
 		Strategy:            kvserverpb.SnapshotRequest_KV_BATCH,
 		Type:                req.Type,
+		Decommissioning:     req.Decommissioning,
 	}
 	newBatchFn := func() storage.WriteBatch {
 		return r.store.TODOEngine().NewWriteBatch()
@@ -84,6 +84,11 @@ func (rec *SpanSetReplicaEvalContext) GetNodeLocality() roachpb.Locality {
 	return rec.i.GetNodeLocality()
 }
 
+// HeartbeatAggregatorOf returns the heartbeat aggregator of the given node.
+func (rec *SpanSetReplicaEvalContext) HeartbeatAggregatorOf(nodeID roachpb.NodeID) roachpb.NodeID {
+	return rec.i.HeartbeatAggregatorOf(nodeID)
+}
+
 // GetFirstIndex returns the first index.
 func (rec *SpanSetReplicaEvalContext) GetFirstIndex() kvpb.RaftIndex {
 	return rec.i.GetFirstIndex()
@@ -168,7 +168,17 @@ func replicaIsSuspect(repl *Replica) bool {
 		return ok && !liveness.Membership.Active()
 	}
 
-	livenessMap := repl.store.cfg.NodeLiveness.GetIsLiveMap()
+	nl := repl.store.cfg.NodeLiveness
+	isLive := func(nodeID roachpb.NodeID) bool {
+		// Replicas that lost their peers to long-dead nodes are checked over
+		// and over until they are GCed, which the cached dead verdicts make
+		// cheap.
+		if nl.IsDead(nodeID) {
+			return false
+		}
+		live, err := nl.IsLive(nodeID)
+		return err == nil && live
+	}
 	switch raftStatus.SoftState.RaftState {
 	// If a replica is a candidate, then by definition it has lost contact with
 	// its leader and possibly the rest of the Raft group, so consider it suspect.
@@ -183,7 +193,7 @@ func replicaIsSuspect(repl *Replica) bool {
 	// conditions, but if it fails it will be GCed within 12 hours anyway.
 	case raft.StateFollower:
 		leadDesc, ok := repl.Desc().GetReplicaDescriptorByID(roachpb.ReplicaID(raftStatus.Lead))
-		if !ok || !livenessMap[leadDesc.NodeID].IsLive {
+		if !ok || !isLive(leadDesc.NodeID) {
 			return true
 		}
 
@@ -192,7 +202,7 @@ func replicaIsSuspect(repl *Replica) bool {
 	// which must cause the stale leader to relinquish its lease and GC itself.
 	case raft.StateLeader:
 		if !repl.Desc().Replicas().CanMakeProgress(func(d roachpb.ReplicaDescriptor) bool {
-			return livenessMap[d.NodeID].IsLive
+			return isLive(d.NodeID)
 		}) {
 			return true
 		}
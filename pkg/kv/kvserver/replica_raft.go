@@ -1105,7 +1105,9 @@ func (r *Replica) handleRaftReadyRaftMuLocked(
 	if hasMsg(msgStorageApply) {
 		r.traceEntries(msgStorageApply.Entries, "committed, before applying any entries")
 
+		r.store.applyProgress.start(stats.tApplicationBegin)
 		err := appTask.ApplyCommittedEntries(ctx)
+		r.store.applyProgress.finish(timeutil.Now())
 		stats.apply = sm.moveStats()
 		if err != nil {
 			// NB: this branch will be hit when the replica has been removed,
@@ -45,6 +45,7 @@ package kvserver
 import (
 	"context"
 	"fmt"
+	"math/rand"
 	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/base"
@@ -54,6 +55,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/constraint"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/kvserverpb"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/raftutil"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/settings"
@@ -108,6 +110,49 @@ var EagerLeaseAcquisitionConcurrency = settings.RegisterIntSetting(
 	settings.NonNegativeInt,
 )
 
+// StrictSelfLivenessEnabled controls whether a node refuses to acquire leases
+// or serve requests that require one while it cannot verify that its own
+// liveness record is live, e.g. during an outage of the liveness range. This
+// trades availability for the certainty that a node only serves while the
+// rest of the cluster also considers it live. The meta and liveness ranges
+// are exempt, since the node needs them to heartbeat its liveness record.
+var StrictSelfLivenessEnabled = settings.RegisterBoolSetting(
+	settings.SystemOnly,
+	"kv.lease.strict_self_liveness.enabled",
+	"refuse to acquire leases or serve requests that require one while this node "+
+		"cannot verify that its own liveness record is live",
+	false,
+)
+
+// HeartbeatFailureBackpressureEnabled controls whether a node refrains from
+// acquiring new epoch-based leases while its own liveness heartbeats fail
+// badly enough for it to be likely to lose its liveness (see
+// liveness.NodeLiveness.LikelyToLoseLiveness).
+// Such a node is likely to lose its liveness, and with it all its epoch-based
+// leases, so acquiring more would only widen the eventual failover. The leases
+// it already holds are kept until they expire.
+var HeartbeatFailureBackpressureEnabled = settings.RegisterBoolSetting(
+	settings.SystemOnly,
+	"kv.lease.heartbeat_failure_backpressure.enabled",
+	"refuse to acquire new epoch-based leases while this node's liveness heartbeats fail "+
+		"repeatedly or its liveness record is about to expire",
+	false,
+)
+
+// LeaseLivenessVerificationSampleRate is the fraction of the epoch-based leases
+// acquired or extended by a node whose liveness record is double-checked
+// against KV, to catch bugs where the cached liveness records leases rely on
+// are stale. See maybeVerifyLeaseLiveness.
+var LeaseLivenessVerificationSampleRate = settings.RegisterFloatSetting(
+	settings.SystemOnly,
+	"kv.lease.liveness_verification.sample_rate",
+	"the fraction of epoch-based lease acquisitions and extensions that double-check the "+
+		"liveness record they rely on against KV, reporting divergences from the cached record "+
+		"(0 disables the verification)",
+	0,
+	settings.NonNegativeFloatWithMaximum(1),
+)
+
 var leaseStatusLogLimiter = func() *log.EveryN {
 	e := log.Every(15 * time.Second)
 	e.ShouldLog() // waste the first shot
@@ -483,7 +528,11 @@ func (p *pendingLeaseRequest) requestLease(
 						status.Liveness.NodeID, nextLeaseHolder.NodeID)
 				}
 				log.VEventf(ctx, 1, "%v", err)
-			} else if err = p.repl.store.cfg.NodeLiveness.IncrementEpoch(ctx, status.Liveness); err != nil {
+			} else if err = p.repl.store.cfg.NodeLiveness.IncrementEpochOf(ctx, liveness.EpochIncrementRequest{
+				Observed: status.Liveness,
+				Now:      status.Now,
+				Reason:   liveness.EpochIncrementReasonLeaseAcquisition(p.repl.RangeID),
+			}); err != nil {
 				// If we get ErrEpochAlreadyIncremented, someone else beat
 				// us to it. This proves that the target node is truly
 				// dead *now*, but it doesn't prove that it was dead at
@@ -543,6 +592,9 @@ func (p *pendingLeaseRequest) requestLease(
 	// solution to the below issue:
 	//
 	// https://github.com/cockroachdb/cockroach/issues/37906
+	if req, ok := leaseReq.(*kvpb.RequestLeaseRequest); ok {
+		p.maybeVerifyLeaseLiveness(ctx, req.Lease)
+	}
 	ba := &kvpb.BatchRequest{}
 	ba.Timestamp = p.repl.store.Clock().Now()
 	ba.RangeID = p.repl.RangeID
@@ -565,6 +617,50 @@ func (p *pendingLeaseRequest) requestLease(
 	return pErr.GoError()
 }
 
+// maybeVerifyLeaseLiveness double-checks the liveness record that the given
+// epoch-based lease, requested by this replica for itself, relies on against
+// KV, for a sample of such leases (see LeaseLivenessVerificationSampleRate).
+// Divergences are logged and counted, but don't hold up the lease.
+func (p *pendingLeaseRequest) maybeVerifyLeaseLiveness(ctx context.Context, lease roachpb.Lease) {
+	rate := LeaseLivenessVerificationSampleRate.Get(&p.repl.store.ClusterSettings().SV)
+	if lease.Type() != roachpb.LeaseEpoch || lease.Replica.StoreID != p.repl.store.StoreID() ||
+		rate == 0 || rand.Float64() >= rate {
+		return
+	}
+	nl := p.repl.store.cfg.NodeLiveness
+	cached, _ := nl.GetLiveness(lease.Replica.NodeID)
+	fromKV, err := nl.GetLivenessFromKV(ctx, lease.Replica.NodeID)
+	if err != nil {
+		log.VEventf(ctx, 1, "unable to verify the liveness record lease %s relies on: %v", lease, err)
+		return
+	}
+	p.repl.store.metrics.LeaseLivenessVerifications.Inc(1)
+	if divergence := leaseLivenessDivergence(lease, cached.Liveness, fromKV); divergence != "" {
+		p.repl.store.metrics.LeaseLivenessDivergences.Inc(1)
+		log.Warningf(ctx, "lease %s relies on a liveness record diverging from KV: %s "+
+			"(cached: %s, KV: %s)", lease, divergence, cached.Liveness, fromKV)
+	}
+}
+
+// leaseLivenessDivergence returns how the liveness record cached for the owner
+// of the given epoch-based lease diverges from the one read from KV after it,
+// or an empty string if it doesn't. Since the record in KV only moves forward,
+// a lease epoch other than the one in KV, or a cached record ahead of it, mean
+// that the lease relies on a stale or bogus record. A concurrent increment of
+// the epoch is reported too, though the lease request then fails on its own.
+func leaseLivenessDivergence(lease roachpb.Lease, cached, fromKV livenesspb.Liveness) string {
+	switch {
+	case lease.Epoch != fromKV.Epoch:
+		return fmt.Sprintf("lease epoch %d, epoch %d in KV", lease.Epoch, fromKV.Epoch)
+	case cached.Epoch != fromKV.Epoch:
+		return fmt.Sprintf("cached epoch %d, epoch %d in KV", cached.Epoch, fromKV.Epoch)
+	case fromKV.Expiration.Less(cached.Expiration):
+		return fmt.Sprintf("cached expiration %s ahead of expiration %s in KV",
+			cached.Expiration, fromKV.Expiration)
+	}
+	return ""
+}
+
 // JoinRequest adds one more waiter to the currently pending request.
 // It is the caller's responsibility to ensure that there is a pending request,
 // and that the request is compatible with whatever the caller is currently
@@ -856,6 +952,12 @@ func (r *Replica) requestLeaseLocked(
 	if pErr := r.store.TestingKnobs().PinnedLeases.rejectLeaseIfPinnedElsewhere(r); pErr != nil {
 		return r.mu.pendingLeaseRequest.newResolvedHandle(pErr)
 	}
+	if err := r.checkSelfLivenessRLocked(status.Now); err != nil {
+		return r.mu.pendingLeaseRequest.newResolvedHandle(kvpb.NewError(err))
+	}
+	if err := r.checkHeartbeatBackpressureRLocked(status); err != nil {
+		return r.mu.pendingLeaseRequest.newResolvedHandle(kvpb.NewError(err))
+	}
 
 	// Propose a Raft command to get a lease for this replica.
 	repDesc, err := r.getReplicaDescriptorRLocked()
@@ -1101,7 +1203,7 @@ func (r *Replica) checkRequestTimeRLocked(now hlc.ClockTimestamp, reqTS hlc.Time
 // This ensures that callers are properly sequenced with TransferLease
 // requests, which declare a conflict with all other commands.
 //
-// The method can has four possible outcomes:
+// The method can has five possible outcomes:
 //
 // (1) the request timestamp is too far in the future. In this case,
 //
@@ -1118,7 +1220,12 @@ func (r *Replica) checkRequestTimeRLocked(now hlc.ClockTimestamp, reqTS hlc.Time
 //	a NotLeaseHolderError is returned, which is propagated back up to
 //	the DistSender and triggers a redirection of the request.
 //
-// (4) the lease is valid, held locally, and capable of serving the
+// (4) the lease is valid and held locally, but StrictSelfLivenessEnabled is
+//
+//	set and this node cannot verify that its own liveness record is live.
+//	In this case, a ReplicaUnavailableError is returned.
+//
+// (5) the lease is valid, held locally, and capable of serving the
 //
 //	given request. In this case, no error is returned.
 func (r *Replica) leaseGoodToGoRLocked(
@@ -1187,10 +1294,55 @@ func (r *Replica) leaseGoodToGoForStatusRLocked(
 			st.Lease, r.store.StoreID(), r.descRLocked(), "lease held by different store",
 		)
 	}
-	// Case (4): all good.
+	if err := r.checkSelfLivenessRLocked(now); err != nil {
+		// Case (4): strict mode and unverifiable self-liveness.
+		return err
+	}
+	// Case (5): all good.
 	return nil
 }
 
+// checkSelfLivenessRLocked returns a ReplicaUnavailableError if
+// StrictSelfLivenessEnabled is set and this node's own liveness record is not
+// known to be live at the given time. Ranges that require expiration-based
+// leases are exempt, as they back the liveness records themselves.
+func (r *Replica) checkSelfLivenessRLocked(now hlc.ClockTimestamp) error {
+	if !StrictSelfLivenessEnabled.Get(&r.ClusterSettings().SV) || r.requiresExpirationLeaseRLocked() {
+		return nil
+	}
+	if self, ok := r.store.cfg.NodeLiveness.Self(); ok && self.IsLive(now.ToTimestamp()) {
+		return nil
+	}
+	repDesc, err := r.getReplicaDescriptorRLocked()
+	if err != nil {
+		return err
+	}
+	return kvpb.NewReplicaUnavailableError(
+		errors.Errorf("n%d cannot verify that its own liveness record is live", r.NodeID()),
+		r.descRLocked(), repDesc)
+}
+
+// checkHeartbeatBackpressureRLocked returns a NotLeaseHolderError if
+// HeartbeatFailureBackpressureEnabled is set, this node is likely to lose its
+// liveness, and the given lease status calls for the acquisition of a
+// new epoch-based lease, i.e. the lease isn't held by this replica or has
+// expired. Ranges that use expiration-based leases are exempt, as their leases
+// don't depend on the liveness of the node.
+func (r *Replica) checkHeartbeatBackpressureRLocked(status kvserverpb.LeaseStatus) error {
+	if !HeartbeatFailureBackpressureEnabled.Get(&r.ClusterSettings().SV) ||
+		r.shouldUseExpirationLeaseRLocked() {
+		return nil
+	}
+	if status.Lease.OwnedBy(r.store.StoreID()) && status.State != kvserverpb.LeaseState_EXPIRED {
+		return nil
+	}
+	if !r.store.cfg.NodeLiveness.LikelyToLoseLiveness() {
+		return nil
+	}
+	return kvpb.NewNotLeaseHolderError(roachpb.Lease{}, r.store.StoreID(), r.descRLocked(),
+		fmt.Sprintf("n%d refuses to acquire the lease while its liveness heartbeats fail", r.NodeID()))
+}
+
 // leaseGoodToGo is like leaseGoodToGoRLocked, but will acquire the replica read
 // lock.
 func (r *Replica) leaseGoodToGo(
@@ -1318,6 +1470,10 @@ func (r *Replica) redirectOnOrAcquireLeaseForRequest(
 					return r.requestLeaseLocked(ctx, status, nil), kvserverpb.LeaseStatus{}, false, nil
 				}
 
+				if err := r.checkSelfLivenessRLocked(now); err != nil {
+					return nil, kvserverpb.LeaseStatus{}, false, kvpb.NewError(err)
+				}
+
 				// Return a nil handle and status to signal that we have a valid lease.
 				return nil, status, false, nil
 
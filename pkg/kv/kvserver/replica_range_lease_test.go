@@ -17,15 +17,18 @@ import (
 
 	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
 	"github.com/cockroachdb/cockroach/pkg/gossip"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvpb"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/kvserverpb"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
 	"github.com/cockroachdb/cockroach/pkg/util/hlc"
 	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/metric"
 	"github.com/cockroachdb/cockroach/pkg/util/stop"
+	"github.com/cockroachdb/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -133,7 +136,7 @@ func TestReplicaLeaseStatus(t *testing.T) {
 				cfg:   StoreConfig{Clock: clock, NodeLiveness: l},
 			}}
 			var empty livenesspb.Liveness
-			if maybeLiveness := tc.liveness; maybeLiveness != empty {
+			if maybeLiveness := tc.liveness; !maybeLiveness.Equal(empty) {
 				l.TestingMaybeUpdate(ctx, liveness.Record{Liveness: maybeLiveness})
 			}
 
@@ -148,3 +151,85 @@ func TestReplicaLeaseStatus(t *testing.T) {
 		})
 	}
 }
+
+// TestReplicaCheckSelfLiveness tests that a replica refuses to serve with
+// StrictSelfLivenessEnabled unless its own liveness record is live.
+func TestReplicaCheckSelfLiveness(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	stopper := stop.NewStopper()
+	defer stopper.Stop(ctx)
+
+	clock := hlc.NewClockForTesting(hlc.NewHybridManualClock())
+	now := hlc.ClockTimestamp{WallTime: 2000}
+	st := cluster.MakeTestingClusterSettings()
+	l := liveness.NewNodeLiveness(liveness.NodeLivenessOptions{
+		Clock: clock,
+		Gossip: gossip.NewTest(roachpb.NodeID(1), stopper, metric.NewRegistry(),
+			zonepb.DefaultZoneConfigRef()),
+	})
+	r := Replica{RangeID: 10, store: &Store{
+		Ident: &roachpb.StoreIdent{StoreID: 1, NodeID: 1},
+		cfg:   StoreConfig{Clock: clock, NodeLiveness: l, Settings: st},
+	}}
+	r.mu.state.Desc = &roachpb.RangeDescriptor{
+		RangeID:  10,
+		StartKey: roachpb.RKey("a"),
+		EndKey:   roachpb.RKey("b"),
+		InternalReplicas: []roachpb.ReplicaDescriptor{
+			{NodeID: 1, StoreID: 1, ReplicaID: 1},
+		},
+	}
+
+	// Strict mode is off by default.
+	require.NoError(t, r.checkSelfLivenessRLocked(now))
+
+	StrictSelfLivenessEnabled.Override(ctx, &st.SV, true)
+	err := r.checkSelfLivenessRLocked(now)
+	require.True(t, errors.HasType(err, (*kvpb.ReplicaUnavailableError)(nil)), "%v", err)
+
+	// An expired record doesn't verify liveness either.
+	l.TestingMaybeUpdate(ctx, liveness.Record{Liveness: livenesspb.Liveness{
+		NodeID: 1, Epoch: 1, Expiration: hlc.LegacyTimestamp{WallTime: 1000},
+	}})
+	require.Error(t, r.checkSelfLivenessRLocked(now))
+
+	l.TestingMaybeUpdate(ctx, liveness.Record{Liveness: livenesspb.Liveness{
+		NodeID: 1, Epoch: 1, Expiration: hlc.LegacyTimestamp{WallTime: 3000},
+	}})
+	require.NoError(t, r.checkSelfLivenessRLocked(now))
+}
+
+// TestLeaseLivenessDivergence tests the detection of epoch-based leases relying
+// on a cached liveness record that diverges from KV.
+func TestLeaseLivenessDivergence(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	mkLiveness := func(epoch int64, expiration int64) livenesspb.Liveness {
+		return livenesspb.Liveness{
+			NodeID:     1,
+			Epoch:      epoch,
+			Expiration: hlc.LegacyTimestamp{WallTime: expiration},
+		}
+	}
+	lease := roachpb.Lease{Epoch: 2}
+	for _, tc := range []struct {
+		name           string
+		cached, fromKV livenesspb.Liveness
+		diverges       bool
+	}{
+		{name: "same", cached: mkLiveness(2, 10), fromKV: mkLiveness(2, 10)},
+		{name: "heartbeated since", cached: mkLiveness(2, 10), fromKV: mkLiveness(2, 20)},
+		{name: "epoch incremented", cached: mkLiveness(2, 10), fromKV: mkLiveness(3, 10), diverges: true},
+		{name: "stale cache", cached: mkLiveness(1, 10), fromKV: mkLiveness(2, 10), diverges: true},
+		{name: "cache ahead", cached: mkLiveness(2, 20), fromKV: mkLiveness(2, 10), diverges: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			divergence := leaseLivenessDivergence(lease, tc.cached, tc.fromKV)
+			require.Equal(t, tc.diverges, divergence != "", "%s", divergence)
+		})
+	}
+}
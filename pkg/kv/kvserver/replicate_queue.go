@@ -37,6 +37,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/util/tracing/tracingpb"
 	"github.com/cockroachdb/errors"
 	"github.com/cockroachdb/redact"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // The replicate queue processes replicas that required replication changes.
@@ -870,7 +871,12 @@ func (rq *replicateQueue) processOneChange(
 
 	// Apply the change generated by PlanOneChange. This call will block until
 	// the change has either been applied successfully or failed.
+	decommissionSp := rq.startDecommissionSpan(ctx, repl, change.Action)
 	err = rq.applyChange(ctx, change, repl)
+	if err != nil {
+		decommissionSp.Recordf("%v", err)
+	}
+	decommissionSp.Finish()
 
 	// TODO(kvoli): The results tracking currently ignore which operation was
 	// planned and instead adopts the allocator action to update the metrics.
@@ -928,6 +934,34 @@ func (rq *replicateQueue) preProcessCheck(ctx context.Context, repl *Replica) er
 	return nil
 }
 
+// startDecommissionSpan returns a span covering a change made on behalf of the
+// decommission of a node holding a replica of the range, as a child of the
+// trace recorded in that node's liveness record when it started
+// decommissioning. This lets the replica movements of a decommission be traced
+// as part of it, from wherever they happen. The span isn't attached to the
+// context, which keeps the change in the trace of the queue. Returns nil if the
+// action isn't a decommission action or no trace is found.
+func (rq *replicateQueue) startDecommissionSpan(
+	ctx context.Context, repl *Replica, action allocatorimpl.AllocatorAction,
+) *tracing.Span {
+	nl := rq.store.cfg.NodeLiveness
+	if !isDecommissionAction(action) || nl == nil || rq.Tracer == nil {
+		return nil
+	}
+	for _, rd := range repl.Desc().Replicas().Descriptors() {
+		l, ok := nl.GetLiveness(rd.NodeID)
+		if !ok || !l.Membership.Decommissioning() || l.DecommissionTrace.Empty() {
+			continue
+		}
+		_, sp := rq.Tracer.StartSpanCtx(ctx, "replicate queue decommission change",
+			tracing.WithRemoteParentFromTraceInfo(l.DecommissionTrace.TraceInfo()))
+		sp.SetTag("range", attribute.IntValue(int(repl.RangeID)))
+		sp.SetTag("action", attribute.StringValue(action.String()))
+		return sp
+	}
+	return nil
+}
+
 func maybeAnnotateDecommissionErr(err error, action allocatorimpl.AllocatorAction) error {
 	if err != nil && isDecommissionAction(action) {
 		err = decommissionPurgatoryError{err}
@@ -52,6 +52,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/log/logpb"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
 	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
 	"github.com/cockroachdb/cockroach/pkg/util/tracing/tracingpb"
 	"github.com/cockroachdb/errors"
@@ -822,6 +823,131 @@ func TestReplicateQueueDecommissioningNonVoters(t *testing.T) {
 	})
 }
 
+// TestReplicateQueueDecommissioningSnapshots verifies that the snapshots sent
+// by the replicate queue to move replicas off decommissioning nodes, and only
+// those, are marked as such, and that they go through the snapshot send queue
+// with the priority of the decommission action.
+func TestReplicateQueueDecommissioningSnapshots(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+	skip.UnderRace(t, "takes a long time or times out under race")
+
+	type snapshot struct {
+		decommissioning bool
+		priority        float64
+		queued          bool
+	}
+	var scratchRangeID int64
+	var mu struct {
+		syncutil.Mutex
+		snapshots []snapshot
+		// sending is the index of the snapshot of the scratch range each store
+		// is sending, if any.
+		sending map[int]int
+	}
+	mu.sending = make(map[int]int)
+
+	const numNodes = 4
+	serverArgs := make(map[int]base.TestServerArgs, numNodes)
+	for i := 0; i < numNodes; i++ {
+		i := i
+		serverArgs[i] = base.TestServerArgs{
+			Knobs: base.TestingKnobs{
+				Store: &kvserver.StoreTestingKnobs{
+					// The scratch range is empty.
+					ThrottleEmptySnapshots: true,
+					SendSnapshot: func(req *kvserverpb.DelegateSendSnapshotRequest) {
+						if int64(req.RangeID) != atomic.LoadInt64(&scratchRangeID) ||
+							req.SenderQueueName != kvserverpb.SnapshotRequest_REPLICATE_QUEUE {
+							return
+						}
+						mu.Lock()
+						defer mu.Unlock()
+						mu.sending[i] = len(mu.snapshots)
+						mu.snapshots = append(mu.snapshots, snapshot{
+							decommissioning: req.Decommissioning,
+							priority:        req.SenderQueuePriority,
+						})
+					},
+					AfterSendSnapshotThrottle: func() {
+						mu.Lock()
+						defer mu.Unlock()
+						if idx, ok := mu.sending[i]; ok {
+							mu.snapshots[idx].queued = true
+							delete(mu.sending, i)
+						}
+					},
+				},
+			},
+		}
+	}
+
+	ctx := context.Background()
+	tc := testcluster.StartTestCluster(t, numNodes, base.TestClusterArgs{
+		ReplicationMode:   base.ReplicationManual,
+		ServerArgsPerNode: serverArgs,
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	// Place the scratch range on n1 and n2, and decommission n2.
+	key := tc.ScratchRange(t)
+	desc := tc.AddVotersOrFatal(t, key, tc.Target(1))
+	atomic.StoreInt64(&scratchRangeID, int64(desc.RangeID))
+	require.NoError(t, tc.Server(0).Decommission(
+		ctx, livenesspb.MembershipStatus_DECOMMISSIONING, []roachpb.NodeID{tc.Server(1).NodeID()}))
+
+	store := tc.GetFirstStoreFromServer(t, 0)
+	repl := store.LookupReplica(roachpb.RKey(key))
+	// process runs the replicate queue on the scratch range until the given
+	// condition holds, and returns the snapshots sent in the meantime.
+	process := func(cond func(roachpb.RangeDescriptor) error) []snapshot {
+		mu.Lock()
+		start := len(mu.snapshots)
+		mu.Unlock()
+		testutils.SucceedsSoon(t, func() error {
+			if _, processErr, err := store.Enqueue(
+				ctx, "replicate", repl, true /* skipShouldQueue */, false, /* async */
+			); err != nil {
+				return err
+			} else if processErr != nil {
+				return processErr
+			}
+			return cond(tc.LookupRangeOrFatal(t, key))
+		})
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]snapshot(nil), mu.snapshots[start:]...)
+	}
+
+	// The range is first up-replicated, which doesn't move the replica off n2
+	// although n2 is decommissioning.
+	snapshots := process(func(desc roachpb.RangeDescriptor) error {
+		if n := len(desc.Replicas().VoterDescriptors()); n != 3 {
+			return errors.Errorf("expected 3 voters, got %d: %s", n, desc)
+		}
+		return nil
+	})
+	require.NotEmpty(t, snapshots)
+	for _, snap := range snapshots {
+		require.False(t, snap.decommissioning)
+		require.True(t, snap.queued)
+	}
+
+	// The replica on n2 is then replaced.
+	snapshots = process(func(desc roachpb.RangeDescriptor) error {
+		if _, ok := desc.GetReplicaDescriptor(tc.Target(1).StoreID); ok {
+			return errors.Errorf("expected no replica on n2: %s", desc)
+		}
+		return nil
+	})
+	require.NotEmpty(t, snapshots)
+	for _, snap := range snapshots {
+		require.True(t, snap.decommissioning)
+		require.True(t, snap.queued)
+		require.Equal(t, allocatorimpl.AllocatorReplaceDecommissioningVoter.Priority(), snap.priority)
+	}
+}
+
 // TestReplicateQueueTracingOnError tests that an error or slowdown in
 // processing a replica results in traces being logged.
 func TestReplicateQueueTracingOnError(t *testing.T) {
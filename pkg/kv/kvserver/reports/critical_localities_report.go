@@ -18,6 +18,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/config"
 	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
 	"github.com/cockroachdb/cockroach/pkg/kv"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/sql/isql"
 	"github.com/cockroachdb/cockroach/pkg/sql/sem/tree"
@@ -82,6 +83,75 @@ func (r LocalityReport) CountRangeAtRisk(zKey ZoneKey, loc LocalityRepr) {
 	r[lKey] = lStat
 }
 
+// ByLocality aggregates the report across zones, returning the number of
+// ranges for which each locality is critical.
+func (r LocalityReport) ByLocality() map[LocalityRepr]int32 {
+	res := make(map[LocalityRepr]int32)
+	for key, status := range r {
+		res[key.locality] += status.atRiskRanges
+	}
+	return res
+}
+
+// ComputeCriticalLocalities computes, against the provided liveness map, the
+// number of ranges for which each locality is critical, i.e. the number of
+// ranges that would lose quorum if all the nodes in the locality were to fail.
+// Unlike the replication_critical_localities report, the result isn't broken
+// down by zone, and can thus be computed on demand on any node without access
+// to the system config.
+func ComputeCriticalLocalities(
+	ctx context.Context,
+	db *kv.DB,
+	allStores map[roachpb.StoreID]roachpb.StoreDescriptor,
+	isLiveMap livenesspb.IsLiveMap,
+) (map[LocalityRepr]int32, error) {
+	nodeLocalities := make(map[roachpb.NodeID]roachpb.Locality, len(allStores))
+	for _, storeDesc := range allStores {
+		nodeLocalities[storeDesc.Node.NodeID] = storeDesc.Node.Locality
+	}
+	allLocalities := expandLocalities(nodeLocalities)
+	isNodeLive := func(nodeID roachpb.NodeID) bool {
+		return isLiveMap[nodeID].IsLive
+	}
+
+	const descriptorReadBatchSize = 10000
+	rangeIter := makeMeta2RangeIter(db, descriptorReadBatchSize)
+	defer rangeIter.Close(ctx)
+	report := make(LocalityReport)
+	for {
+		rd, err := rangeIter.Next(ctx)
+		if err != nil {
+			if errIsRetriable(err) {
+				// The iterator has been positioned to the beginning.
+				report = make(LocalityReport)
+				continue
+			}
+			return nil, err
+		}
+		if rd.RangeID == 0 {
+			// We're done.
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		replicas := rd.Replicas().Descriptors()
+		stores := make([]roachpb.StoreDescriptor, len(replicas))
+		dedupLocal := make(map[string]roachpb.Locality)
+		for i, rep := range replicas {
+			stores[i] = allStores[rep.StoreID]
+			for s, loc := range allLocalities[rep.NodeID] {
+				dedupLocal[s] = loc
+			}
+		}
+		for _, loc := range dedupLocal {
+			processLocalityForRange(ctx, &rd, ZoneKey{}, loc, isNodeLive, stores, report)
+		}
+	}
+	return report.ByLocality(), nil
+}
+
 func (r *replicationCriticalLocalitiesReportSaver) loadPreviousVersion(
 	ctx context.Context, ex isql.Executor, txn *kv.Txn,
 ) error {
@@ -342,3 +342,20 @@ func TestExpandLocalities(t *testing.T) {
 		expanded,
 	)
 }
+
+func TestLocalityReportByLocality(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	rep := make(LocalityReport)
+	zone1 := MakeZoneKey(1, NoSubzone)
+	zone2 := MakeZoneKey(2, NoSubzone)
+	rep.CountRangeAtRisk(zone1, "region=r1")
+	rep.CountRangeAtRisk(zone1, "region=r1")
+	rep.CountRangeAtRisk(zone2, "region=r1")
+	rep.CountRangeAtRisk(zone2, "region=r2")
+	require.Equal(t,
+		map[LocalityRepr]int32{"region=r1": 3, "region=r2": 1},
+		rep.ByLocality(),
+	)
+}
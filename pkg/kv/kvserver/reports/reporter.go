@@ -36,6 +36,8 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/sql/sem/tree"
 	"github.com/cockroachdb/cockroach/pkg/sql/sessiondata"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/metric"
+	"github.com/cockroachdb/cockroach/pkg/util/metric/aggmetric"
 	"github.com/cockroachdb/cockroach/pkg/util/stop"
 	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
 	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
@@ -54,6 +56,20 @@ var ReporterInterval = settings.RegisterDurationSetting(
 	settings.NonNegativeDuration,
 ).WithPublic()
 
+var metaCriticalLocalityAtRiskRanges = metric.Metadata{
+	Name: "ranges.critical_locality.at_risk",
+	Help: "Number of ranges that would lose quorum if all the nodes in a locality failed, " +
+		"as of the last replication report generated by this node",
+	Measurement: "Ranges",
+	Unit:        metric.Unit_COUNT,
+}
+
+// Metrics contains the metrics exported by the Reporter. They are only
+// populated on the node generating the replication reports.
+type Metrics struct {
+	CriticalLocalityAtRiskRanges *aggmetric.AggGauge
+}
+
 // Reporter periodically produces a couple of reports on the cluster's data
 // distribution: the system tables: replication_constraint_stats,
 // replication_stats_report and replication_critical_localities.
@@ -83,6 +99,12 @@ type Reporter struct {
 		interval time.Duration
 		changeCh chan struct{}
 	}
+
+	metrics Metrics
+	// criticalLocalityGauges holds the per-locality children of
+	// metrics.CriticalLocalityAtRiskRanges. Only accessed by the reporter's
+	// async task.
+	criticalLocalityGauges map[LocalityRepr]*aggmetric.Gauge
 }
 
 // NewReporter creates a Reporter.
@@ -103,11 +125,42 @@ func NewReporter(
 		liveness:    liveness,
 		executor:    executor,
 		cfgs:        provider,
+		metrics: Metrics{
+			CriticalLocalityAtRiskRanges: aggmetric.NewGauge(metaCriticalLocalityAtRiskRanges, "locality"),
+		},
+		criticalLocalityGauges: make(map[LocalityRepr]*aggmetric.Gauge),
 	}
 	r.frequencyMu.changeCh = make(chan struct{})
 	return &r
 }
 
+// Metrics returns the metrics exported by the Reporter.
+func (stats *Reporter) Metrics() *Metrics {
+	return &stats.metrics
+}
+
+// updateCriticalLocalityMetrics sets the per-locality at-risk range gauges to
+// the given counts, removing the gauges of localities that are no longer
+// critical. Passing a nil map clears all the gauges; this is done when the
+// node stops generating the reports.
+func (stats *Reporter) updateCriticalLocalityMetrics(atRisk map[LocalityRepr]int32) {
+	for loc, g := range stats.criticalLocalityGauges {
+		if _, ok := atRisk[loc]; !ok {
+			g.Update(0)
+			g.Unlink()
+			delete(stats.criticalLocalityGauges, loc)
+		}
+	}
+	for loc, count := range atRisk {
+		g, ok := stats.criticalLocalityGauges[loc]
+		if !ok {
+			g = stats.metrics.CriticalLocalityAtRiskRanges.AddChild(string(loc))
+			stats.criticalLocalityGauges[loc] = g
+		}
+		g.Update(int64(count))
+	}
+}
+
 // reportInterval returns the current value of the frequency setting and a
 // channel that will get closed when the value is not current any more.
 func (stats *Reporter) reportInterval() (time.Duration, <-chan struct{}) {
@@ -158,6 +211,10 @@ func (stats *Reporter) Start(ctx context.Context, stopper *stop.Stopper) {
 					); err != nil {
 						log.Errorf(ctx, "failed to generate replication reports: %s", err)
 					}
+				} else {
+					// Another node is generating the reports; our metrics would be
+					// stale.
+					stats.updateCriticalLocalityMetrics(nil)
 				}
 				timer.Reset(interval)
 				timerCh = timer.C
@@ -251,6 +308,7 @@ func (stats *Reporter) update(
 		}
 	}
 	if !localityStatsVisitor.failed() {
+		stats.updateCriticalLocalityMetrics(localityStatsVisitor.Report().ByLocality())
 		if err := locSaver.Save(
 			ctx, localityStatsVisitor.Report(), timeutil.Now() /* reportTS */, stats.db, stats.executor,
 		); err != nil {
@@ -809,6 +809,9 @@ type Store struct {
 	// transport is connected to, and is used by the canonical
 	// replicaFlowControlIntegration implementation.
 	raftTransportForFlowControl raftTransportForFlowControl
+	// applyProgress tracks whether the store's replicas are making progress
+	// applying committed Raft commands. See CheckApplicationProgress.
+	applyProgress applyProgress
 
 	coalescedMu struct {
 		syncutil.Mutex
@@ -830,6 +833,11 @@ type Store struct {
 	// Queue to limit concurrent non-empty snapshot sending.
 	snapshotSendQueue *multiqueue.MultiQueue
 
+	// Limiter for concurrent non-empty snapshot sending on behalf of replicas
+	// moving off decommissioning nodes, on top of snapshotSendQueue. See
+	// decommissionSnapshotConcurrency.
+	decommissionSnapshotSendLimiter limit.ConcurrentRequestLimiter
+
 	// draining holds a bool which indicates whether this store is draining. See
 	// SetDraining() for a more detailed explanation of behavior changes.
 	//
@@ -1405,6 +1413,13 @@ func NewStore(
 	s.metrics.registry.AddMetricStruct(s.txnWaitMetrics)
 	s.snapshotApplyQueue = multiqueue.NewMultiQueue(int(cfg.SnapshotApplyLimit))
 	s.snapshotSendQueue = multiqueue.NewMultiQueue(int(cfg.SnapshotSendLimit))
+	s.decommissionSnapshotSendLimiter = limit.MakeConcurrentRequestLimiter(
+		"decommissionSnapshotSendLimiter", int(decommissionSnapshotConcurrency.Get(&cfg.Settings.SV)),
+	)
+	decommissionSnapshotConcurrency.SetOnChange(&cfg.Settings.SV, func(ctx context.Context) {
+		s.decommissionSnapshotSendLimiter.SetLimit(
+			int(decommissionSnapshotConcurrency.Get(&cfg.Settings.SV)))
+	})
 
 	s.consistencyLimiter = quotapool.NewRateLimiter(
 		"ConsistencyQueue",
@@ -1505,6 +1520,7 @@ func NewStore(
 
 	if s.cfg.Gossip != nil {
 		s.storeGossip = NewStoreGossip(cfg.Gossip, s, cfg.TestingKnobs.GossipTestingKnobs)
+		s.storeGossip.sv = &cfg.Settings.SV
 
 		// Add range scanner and configure with queues.
 		s.scanner = newReplicaScanner(
@@ -0,0 +1,74 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package kvserver
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/errors"
+)
+
+// applyProgress tracks whether the replicas of a store are making progress
+// applying committed Raft commands, so that a store that is wedged applying
+// them can be detected. It is cheap enough to be updated for every batch of
+// applied commands.
+type applyProgress struct {
+	// inFlight is the number of replicas applying committed commands.
+	inFlight atomic.Int64
+	// lastProgress is the time, in nanoseconds since the epoch, at which a
+	// replica last completed applying committed commands, or at which one
+	// started doing so while none were.
+	lastProgress atomic.Int64
+}
+
+// start is called when a replica starts applying committed commands.
+func (p *applyProgress) start(now time.Time) {
+	if p.inFlight.Add(1) == 1 {
+		p.lastProgress.Store(now.UnixNano())
+	}
+}
+
+// finish is called when a replica is done applying committed commands,
+// whether or not it succeeded.
+func (p *applyProgress) finish(now time.Time) {
+	p.lastProgress.Store(now.UnixNano())
+	p.inFlight.Add(-1)
+}
+
+// stalledFor returns for how long replicas have been applying committed
+// commands without any of them completing, or zero if none are.
+func (p *applyProgress) stalledFor(now time.Time) time.Duration {
+	if p.inFlight.Load() == 0 {
+		return 0
+	}
+	return now.Sub(timeutil.Unix(0, p.lastProgress.Load()))
+}
+
+// CheckApplicationProgress returns an error if the store's replicas have been
+// applying committed Raft commands for at least the given threshold without
+// any of them completing, i.e. if the store appears wedged applying them.
+func (s *Store) CheckApplicationProgress(threshold time.Duration) error {
+	if stalled := s.applyProgress.stalledFor(timeutil.Now()); stalled >= threshold {
+		return errors.Errorf("s%d has not completed applying any committed Raft commands in %s",
+			s.StoreID(), stalled)
+	}
+	return nil
+}
+
+// CheckApplicationProgress returns an error if any of the stores appears
+// wedged applying committed Raft commands. See Store.CheckApplicationProgress.
+func (ls *Stores) CheckApplicationProgress(threshold time.Duration) error {
+	return ls.VisitStores(func(s *Store) error {
+		return s.CheckApplicationProgress(threshold)
+	})
+}
@@ -0,0 +1,46 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package kvserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyProgress(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	var p applyProgress
+	t0 := time.Unix(1000, 0)
+
+	// A store that isn't applying anything isn't stalled, however long ago it
+	// last applied commands.
+	require.Zero(t, p.stalledFor(t0))
+
+	// Starting an application after being idle starts the clock.
+	p.start(t0)
+	require.Equal(t, time.Second, p.stalledFor(t0.Add(time.Second)))
+
+	// A concurrent application doesn't reset it, but completing one does.
+	p.start(t0.Add(2 * time.Second))
+	require.Equal(t, 3*time.Second, p.stalledFor(t0.Add(3*time.Second)))
+	p.finish(t0.Add(4 * time.Second))
+	require.Equal(t, time.Second, p.stalledFor(t0.Add(5*time.Second)))
+
+	// Once all applications completed, the store isn't stalled anymore.
+	p.finish(t0.Add(6 * time.Second))
+	require.Zero(t, p.stalledFor(t0.Add(time.Minute)))
+}
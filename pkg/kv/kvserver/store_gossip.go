@@ -20,7 +20,9 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/config"
 	"github.com/cockroachdb/cockroach/pkg/gossip"
 	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/kvserverbase"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/settings"
 	"github.com/cockroachdb/cockroach/pkg/spanconfig/spanconfigstore"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
@@ -242,6 +244,10 @@ type StoreGossip struct {
 	// descriptorGetter is used for getting an up to date or cached store
 	// descriptor to gossip.
 	descriptorGetter StoreDescriptorProvider
+	// sv is used to check whether the capacity of the store is recorded in the
+	// liveness record of its node, see shouldGossipOnCapacityDelta. Nil if
+	// unknown, in which case it isn't.
+	sv *settings.Values
 }
 
 // StoreGossipTestingKnobs defines the testing knobs specific to StoreGossip.
@@ -444,6 +450,12 @@ func (s *StoreGossip) shouldGossipOnCapacityDelta() (should bool, reason string)
 	if s.gossipOngoing.Get() {
 		return
 	}
+	// If the capacity of the store rides along with the liveness heartbeats of
+	// its node, there is no need to gossip it on changes: the periodic gossip
+	// is enough to keep the rest of the descriptor up to date.
+	if s.sv != nil && kvserverbase.LivenessStoreDigestsEnabled.Get(s.sv) {
+		return
+	}
 
 	gossipWhenCapacityDeltaExceedsFraction := defaultGossipWhenCapacityDeltaExceedsFraction
 	if overrideCapacityDeltaFraction := s.knobs.OverrideGossipWhenCapacityDeltaExceedsFraction; overrideCapacityDeltaFraction > 0 {
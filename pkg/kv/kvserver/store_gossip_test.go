@@ -11,9 +11,12 @@
 package kvserver
 
 import (
+	"context"
 	"testing"
 
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/kvserverbase"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
 	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
 	"github.com/stretchr/testify/require"
 )
@@ -26,6 +29,7 @@ func TestStoreGossipDeltaTrigger(t *testing.T) {
 	testCases := []struct {
 		desc                 string
 		cached, lastGossiped roachpb.StoreCapacity
+		storeDigests         bool
 		expectedReason       string
 		expectedShould       bool
 	}{
@@ -64,6 +68,14 @@ func TestStoreGossipDeltaTrigger(t *testing.T) {
 			expectedReason: "queries-per-second(100.0) writes-per-second(-100.0) range-count(5.0) lease-count(-5.0) change",
 			expectedShould: true,
 		},
+		{
+			desc:           "shouldn't gossip on any delta with store digests",
+			lastGossiped:   roachpb.StoreCapacity{QueriesPerSecond: 100, WritesPerSecond: 100, RangeCount: 10, LeaseCount: 10},
+			cached:         roachpb.StoreCapacity{QueriesPerSecond: 200, WritesPerSecond: 0, RangeCount: 15, LeaseCount: 5},
+			storeDigests:   true,
+			expectedReason: "",
+			expectedShould: false,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -73,6 +85,9 @@ func TestStoreGossipDeltaTrigger(t *testing.T) {
 			sg := NewStoreGossip(nil, nil, cfg.TestingKnobs.GossipTestingKnobs)
 			sg.cachedCapacity.cached = tc.cached
 			sg.cachedCapacity.lastGossiped = tc.lastGossiped
+			st := cluster.MakeTestingClusterSettings()
+			kvserverbase.LivenessStoreDigestsEnabled.Override(context.Background(), &st.SV, tc.storeDigests)
+			sg.sv = &st.SV
 
 			should, reason := sg.shouldGossipOnCapacityDelta()
 			require.Equal(t, tc.expectedReason, reason)
@@ -714,7 +714,20 @@ func (s *Store) reserveSendSnapshot(
 		fn()
 	}
 
-	return s.throttleSnapshot(ctx,
+	// Snapshots moving replicas off decommissioning nodes are further limited
+	// before they queue for a spot in the shared send queue, where they are
+	// prioritized by the allocator priority of the decommission action like any
+	// other snapshot sent by the replicate queue.
+	var release func()
+	if req.Decommissioning && rangeSize != 0 {
+		alloc, err := s.decommissionSnapshotSendLimiter.Begin(ctx)
+		if err != nil {
+			return nil, err
+		}
+		release = alloc.Release
+	}
+
+	cleanup, err := s.throttleSnapshot(ctx,
 		s.snapshotSendQueue,
 		int(req.SenderQueueName),
 		req.SenderQueuePriority,
@@ -728,6 +741,17 @@ func (s *Store) reserveSendSnapshot(
 			s.metrics.RangeSnapshotSendTotalInProgress,
 		},
 	)
+	if release == nil {
+		return cleanup, err
+	}
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return func() {
+		cleanup()
+		release()
+	}, nil
 }
 
 // throttleSnapshot is a helper function to throttle snapshot sending and
@@ -1245,6 +1269,49 @@ var rebalanceSnapshotRate = settings.RegisterByteSizeSetting(
 	},
 ).WithPublic()
 
+// decommissionSnapshotRate is the rate at which snapshots moving replicas off
+// decommissioning nodes are sent. It allows node drains to be sped up or
+// slowed down independently of regular rebalancing.
+var decommissionSnapshotRate = settings.RegisterByteSizeSetting(
+	settings.SystemOnly,
+	"kv.snapshot_decommission.max_rate",
+	"the rate limit (bytes/sec) to use for snapshots moving replicas off "+
+		"decommissioning nodes; 0 uses kv.snapshot_rebalance.max_rate",
+	0,
+	func(v int64) error {
+		if v != 0 && v < minSnapshotRate {
+			return errors.Errorf("snapshot rate cannot be set to a value below %s: %s",
+				humanizeutil.IBytes(minSnapshotRate), humanizeutil.IBytes(v))
+		}
+		return nil
+	},
+)
+
+// decommissionSnapshotConcurrency is the number of snapshots moving replicas
+// off decommissioning nodes that a store sends concurrently. These snapshots
+// still go through the shared snapshot send queue, whose concurrency is fixed
+// at startup, so this can only lower their concurrency below that of the
+// queue.
+var decommissionSnapshotConcurrency = settings.RegisterIntSetting(
+	settings.SystemOnly,
+	"kv.snapshot_decommission.max_concurrent_range_movements",
+	"the maximum number of snapshots moving replicas off decommissioning nodes "+
+		"that a store sends concurrently, within the limit on all snapshots it sends",
+	DefaultSnapshotSendLimit,
+	settings.PositiveInt,
+)
+
+// snapshotSendRate returns the rate limit (bytes/sec) to use when sending the
+// snapshot described by the given header.
+func snapshotSendRate(st *cluster.Settings, header *kvserverpb.SnapshotRequest_Header) int64 {
+	if header.Decommissioning {
+		if r := decommissionSnapshotRate.Get(&st.SV); r != 0 {
+			return r
+		}
+	}
+	return rebalanceSnapshotRate.Get(&st.SV)
+}
+
 // TODO(baptist): Remove in v24.1, no longer read in v23.2.
 func init() {
 	settings.RegisterByteSizeSetting(
@@ -1588,7 +1655,7 @@ func sendSnapshot(
 	start = timeutil.Now()
 
 	// Consult cluster settings to determine rate limits and batch sizes.
-	targetRate := rate.Limit(rebalanceSnapshotRate.Get(&st.SV))
+	targetRate := rate.Limit(snapshotSendRate(st, &header))
 	batchSize := snapshotSenderBatchSize.Get(&st.SV)
 
 	// Convert the bytes/sec rate limit to batches/sec.
@@ -3490,6 +3490,25 @@ func TestSnapshotRateLimit(t *testing.T) {
 	require.Equal(t, int64(32<<20), limit)
 }
 
+func TestDecommissionSnapshotRateLimit(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	st := cluster.MakeTestingClusterSettings()
+	rebalance := &kvserverpb.SnapshotRequest_Header{}
+	decommission := &kvserverpb.SnapshotRequest_Header{Decommissioning: true}
+
+	// By default, decommissioning snapshots use the rebalance rate.
+	require.Equal(t, int64(32<<20), snapshotSendRate(st, rebalance))
+	require.Equal(t, int64(32<<20), snapshotSendRate(st, decommission))
+
+	// Once set, the decommission rate only applies to decommissioning snapshots.
+	decommissionSnapshotRate.Override(ctx, &st.SV, 128<<20)
+	require.Equal(t, int64(32<<20), snapshotSendRate(st, rebalance))
+	require.Equal(t, int64(128<<20), snapshotSendRate(st, decommission))
+}
+
 type mockSpanConfigReader struct {
 	real      spanconfig.StoreReader
 	overrides map[string]roachpb.SpanConfig
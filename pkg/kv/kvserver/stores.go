@@ -13,6 +13,7 @@ package kvserver
 import (
 	"context"
 	"fmt"
+	"sort"
 	"unsafe"
 
 	"github.com/cockroachdb/cockroach/pkg/gossip"
@@ -20,6 +21,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/kv"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvpb"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/kvadmission"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/storage"
 	"github.com/cockroachdb/cockroach/pkg/util/future"
@@ -152,6 +154,26 @@ func (ls *Stores) VisitStores(visitor func(s *Store) error) error {
 	return err
 }
 
+// StoreDigests returns the digests of the cached capacity of the stores,
+// ordered by store ID, to be recorded in the liveness record of the node.
+// Stores whose descriptor isn't available are skipped.
+func (ls *Stores) StoreDigests(ctx context.Context) []livenesspb.StoreDigest {
+	var digests []livenesspb.StoreDigest
+	_ = ls.VisitStores(func(s *Store) error {
+		desc, err := s.Descriptor(ctx, true /* useCached */)
+		if err != nil {
+			log.VEventf(ctx, 2, "skipping store digest of s%d: %v", s.StoreID(), err)
+			return nil
+		}
+		digests = append(digests, livenesspb.MakeStoreDigest(desc.StoreID, desc.Capacity))
+		return nil
+	})
+	sort.Slice(digests, func(i, j int) bool {
+		return digests[i].StoreID < digests[j].StoreID
+	})
+	return digests
+}
+
 // GetReplicaForRangeID returns the replica and store which contains the
 // specified range. If the replica is not found on any store then
 // kvpb.RangeNotFoundError will be returned.
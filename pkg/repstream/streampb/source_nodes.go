@@ -0,0 +1,32 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package streampb
+
+import (
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+)
+
+// UnavailableSourceNodes returns the IDs of the source cluster's nodes that
+// were unavailable, dead or of unknown status at the time of the heartbeat.
+// Live nodes that are draining or decommissioning are not included.
+func (s *StreamReplicationStatus) UnavailableSourceNodes() []roachpb.NodeID {
+	var ids []roachpb.NodeID
+	for _, n := range s.SourceNodes {
+		switch n.Status {
+		case livenesspb.NodeLivenessStatus_LIVE, livenesspb.NodeLivenessStatus_DRAINING,
+			livenesspb.NodeLivenessStatus_DECOMMISSIONING:
+		default:
+			ids = append(ids, n.NodeID)
+		}
+	}
+	return ids
+}
@@ -11,6 +11,7 @@
 package roachpb
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"strconv"
@@ -55,6 +56,28 @@ func (s NodeIDSlice) String() string {
 	return sb.String()
 }
 
+type clientNodeIDKey struct{}
+
+// ContextWithClientNodeID creates a new context with the ID of the node that's
+// the client of an RPC, as authenticated by the RPC layer. The node ID can be
+// retrieved later with ClientNodeIDFromContext. A zero node ID clears it, for
+// RPCs whose client isn't an authenticated node.
+func ContextWithClientNodeID(ctx context.Context, nodeID NodeID) context.Context {
+	if ctxNodeID, _ := ClientNodeIDFromContext(ctx); nodeID == ctxNodeID {
+		// The context already has the right node.
+		return ctx
+	}
+	return context.WithValue(ctx, clientNodeIDKey{}, nodeID)
+}
+
+// ClientNodeIDFromContext returns the ID of the node that's the client of the
+// current RPC, if that information was put in the ctx by
+// ContextWithClientNodeID.
+func ClientNodeIDFromContext(ctx context.Context) (nodeID NodeID, ok bool) {
+	nodeID, _ = ctx.Value(clientNodeIDKey{}).(NodeID)
+	return nodeID, nodeID != 0
+}
+
 // StoreID is a custom type for a cockroach store ID.
 type StoreID int32
 
@@ -15,6 +15,7 @@ import (
 	"crypto/x509"
 	"fmt"
 
+	"github.com/cockroachdb/cockroach/pkg/base"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/security"
 	"github.com/cockroachdb/cockroach/pkg/security/username"
@@ -40,12 +41,22 @@ func authErrorf(format string, a ...interface{}) error {
 	return status.Errorf(codes.Unauthenticated, format, a...)
 }
 
+// isHealthCheckMethod returns whether the given method is part of the standard
+// gRPC health checking protocol.
+func isHealthCheckMethod(fullMethod string) bool {
+	return fullMethod == "/grpc.health.v1.Health/Check" ||
+		fullMethod == "/grpc.health.v1.Health/Watch"
+}
+
 // kvAuth is the standard auth policy used for RPCs sent to an RPC server. It
 // validates that client TLS certificate provided by the incoming connection
 // contains a sufficiently privileged user.
 type kvAuth struct {
 	sv     *settings.Values
 	tenant tenantAuthorizer
+	// nodeID is the ID of the local node, which is the client of the local
+	// requests. It is nil on tenant servers.
+	nodeID *base.NodeIDContainer
 }
 
 // kvAuth implements the auth interface.
@@ -65,6 +76,11 @@ func (a kvAuth) unaryInterceptor(
 	if info.FullMethod == "/cockroach.server.serverpb.Admin/RequestCertBundle" {
 		return handler(ctx, req)
 	}
+	// Allow unauthenticated health checks, which load balancers issue without
+	// client certificates. They only reveal whether the node is serving.
+	if isHealthCheckMethod(info.FullMethod) {
+		return handler(ctx, req)
+	}
 
 	// Perform authentication and authz selection.
 	authnRes, authz, err := a.authenticateAndSelectAuthzRule(ctx)
@@ -110,6 +126,11 @@ func (a kvAuth) streamInterceptor(
 ) error {
 	ctx := ss.Context()
 
+	// See the comment in unaryInterceptor.
+	if isHealthCheckMethod(info.FullMethod) {
+		return handler(srv, ss)
+	}
+
 	// Perform authentication and authz selection.
 	authnRes, authz, err := a.authenticateAndSelectAuthzRule(ctx)
 	if err != nil {
@@ -213,7 +234,13 @@ type authnSuccessPeerIsTenantServer roachpb.TenantID
 
 // authnSuccessPeerIsPrivileged indicates authentication
 // has succeeded, and the peer has used a root or node client cert.
-type authnSuccessPeerIsPrivileged struct{}
+type authnSuccessPeerIsPrivileged struct {
+	// nodeID is the ID of the node the peer authenticated as: the node named
+	// by its client cert (see security.GetCertificateNodeID), or the local
+	// node for local requests. It is zero if the peer isn't known to be a
+	// node.
+	nodeID roachpb.NodeID
+}
 
 func (authnSuccessPeerIsTenantServer) authnResult() {}
 func (authnSuccessPeerIsPrivileged) authnResult()   {}
@@ -268,12 +295,12 @@ func (a kvAuth) authenticateLocalRequest(
 		return nil, authErrorf("programming error")
 	}
 
-	if !clientTenantID.IsSet() {
-		return authnSuccessPeerIsPrivileged{}, nil
-	}
-
-	if clientTenantID.IsSystem() {
-		return authnSuccessPeerIsPrivileged{}, nil
+	if !clientTenantID.IsSet() || clientTenantID.IsSystem() {
+		var nodeID roachpb.NodeID
+		if a.nodeID != nil {
+			nodeID = a.nodeID.Get()
+		}
+		return authnSuccessPeerIsPrivileged{nodeID: nodeID}, nil
 	}
 
 	return authnSuccessPeerIsTenantServer(clientTenantID), nil
@@ -334,7 +361,11 @@ func (a kvAuth) authenticateNetworkRequest(ctx context.Context) (authnResult, er
 	if tenantIDFromMetadata.IsSet() {
 		return authnSuccessPeerIsTenantServer(tenantIDFromMetadata), nil
 	}
-	return authnSuccessPeerIsPrivileged{}, nil
+	nodeID, err := security.GetCertificateNodeID(clientCert)
+	if err != nil {
+		return nil, authErrorf("%v", err)
+	}
+	return authnSuccessPeerIsPrivileged{nodeID: nodeID}, nil
 }
 
 // requiredAuthzMethod is a sum type that describes which authorization
@@ -434,7 +465,16 @@ func checkRootOrNodeInScope(
 // tenant ID is still present when the call is received at (c).
 // However, we don't want the API handler at (c) to see it any more.
 // So we need to remove it.
+//
+// Likewise, the node the peer authenticated as, if any, replaces the one the
+// context may have been given by the authentication of another RPC.
 func contextForRequest(ctx context.Context, authnRes authnResult) context.Context {
+	var nodeID roachpb.NodeID
+	if ar, ok := authnRes.(authnSuccessPeerIsPrivileged); ok {
+		nodeID = ar.nodeID
+	}
+	ctx = roachpb.ContextWithClientNodeID(ctx, nodeID)
+
 	switch ar := authnRes.(type) {
 	case authnSuccessPeerIsTenantServer:
 		// The simple context key will be used in various places via
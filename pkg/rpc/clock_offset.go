@@ -306,6 +306,29 @@ func (r *RemoteClockMonitor) GetOffset(id roachpb.NodeID) RemoteOffset {
 	return r.mu.offsets[id]
 }
 
+// MaxOffset returns the largest absolute offset, accounting for its
+// uncertainty, among the offsets to other nodes that aren't stale. Returns zero
+// if no offset is known.
+func (r *RemoteClockMonitor) MaxOffset() time.Duration {
+	now := r.clock.Now()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var maxOffset int64
+	for _, offset := range r.mu.offsets {
+		if offset.isStale(r.offsetTTL, now) {
+			continue
+		}
+		abs := offset.Offset
+		if abs < 0 {
+			abs = -abs
+		}
+		if abs+offset.Uncertainty > maxOffset {
+			maxOffset = abs + offset.Uncertainty
+		}
+	}
+	return time.Duration(maxOffset)
+}
+
 // VerifyClockOffset calculates the number of nodes to which the known offset
 // is healthy (as defined by RemoteOffset.isHealthy). It returns nil iff more
 // than half the known offsets are healthy, and an error otherwise. A non-nil
@@ -128,6 +128,29 @@ func TestVerifyClockOffset(t *testing.T) {
 	}
 }
 
+func TestMaxOffset(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	ttl := time.Hour
+	now := ttl.Nanoseconds() + 100
+	clock := timeutil.NewManualTime(timeutil.Unix(0, now))
+	monitor := newRemoteClockMonitor(clock, 50*time.Nanosecond, ttl, 0)
+	if offset := monitor.MaxOffset(); offset != 0 {
+		t.Errorf("expected no offset, got %s", offset)
+	}
+
+	monitor.mu.offsets = map[roachpb.NodeID]RemoteOffset{
+		1: {Offset: 20, Uncertainty: 10, MeasuredAt: now},
+		2: {Offset: -40, Uncertainty: 5, MeasuredAt: now},
+		3: {Offset: 30, Uncertainty: 2, MeasuredAt: now},
+		// Stale offsets are ignored.
+		4: {Offset: 1000, MeasuredAt: 0},
+	}
+	if e, a := 45*time.Nanosecond, monitor.MaxOffset(); e != a {
+		t.Errorf("expected %s, got %s", e, a)
+	}
+}
+
 // TestIsHealthyOffsetInterval tests if we correctly determine if
 // a clusterOffsetInterval is healthy or not i.e. if it indicates that the
 // local clock has too great an offset or not.
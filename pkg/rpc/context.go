@@ -151,6 +151,9 @@ func NewServerEx(
 				capabilitiesAuthorizer: rpcCtx.capabilitiesAuthorizer,
 			},
 		}
+		if rpcCtx.tenID.IsSystem() {
+			a.nodeID = rpcCtx.NodeID
+		}
 
 		unaryInterceptor = append(unaryInterceptor, a.AuthUnary())
 		streamInterceptor = append(streamInterceptor, a.AuthStream())
@@ -17,6 +17,7 @@ import (
 	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/jobs/jobspb"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
 	"github.com/cockroachdb/cockroach/pkg/scheduledjobs"
 	"github.com/cockroachdb/cockroach/pkg/sql"
 	"github.com/cockroachdb/cockroach/pkg/sql/catalog/descpb"
@@ -122,6 +123,37 @@ func ParseWaitBehavior(wait string, details *jobspb.ScheduleDetails) error {
 	return nil
 }
 
+// ParseAvoidNodeStates parses schedule option optAvoidNodeStates, a
+// comma-separated list of node lifecycle states (e.g. "draining,suspect"),
+// into jobspb.ScheduleDetails. An empty list clears the option.
+func ParseAvoidNodeStates(avoid string, details *jobspb.ScheduleDetails) error {
+	var states []livenesspb.NodeLifecycleState
+	for _, name := range strings.Split(avoid, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		state, err := livenesspb.ParseNodeLifecycleState(name)
+		if err != nil {
+			return pgerror.Wrapf(err, pgcode.InvalidParameterValue,
+				"%q is not a valid avoid_node_states", avoid)
+		}
+		states = append(states, state)
+	}
+	details.AvoidNodeStates = states
+	return nil
+}
+
+// ParseAvoidNodeStatesOption formats optAvoidNodeStates from
+// jobspb.ScheduleDetails
+func ParseAvoidNodeStatesOption(states []livenesspb.NodeLifecycleState) string {
+	names := make([]string, len(states))
+	for i, s := range states {
+		names[i] = s.String()
+	}
+	return strings.Join(names, ",")
+}
+
 // ParseOnPreviousRunningOption parses optOnPreviousRunning from
 // jobspb.ScheduleDetails_WaitBehavior
 func ParseOnPreviousRunningOption(
@@ -16,6 +16,7 @@ import (
 	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/jobs/jobspb"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
 	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
@@ -181,4 +182,23 @@ func TestParseWaitBehavior(t *testing.T) {
 	})
 }
 
+func TestParseAvoidNodeStates(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	details := &jobspb.ScheduleDetails{}
+	require.NoError(t, ParseAvoidNodeStates("Draining, suspect", details))
+	require.Equal(t, []livenesspb.NodeLifecycleState{
+		livenesspb.NodeLifecycleState_DRAINING, livenesspb.NodeLifecycleState_SUSPECT,
+	}, details.AvoidNodeStates)
+	require.Equal(t, "draining,suspect", ParseAvoidNodeStatesOption(details.AvoidNodeStates))
+
+	err := ParseAvoidNodeStates("draining,bogus", details)
+	require.Regexp(t, "is not a valid avoid_node_states", err)
+	require.Len(t, details.AvoidNodeStates, 2)
+
+	require.NoError(t, ParseAvoidNodeStates("", details))
+	require.Empty(t, details.AvoidNodeStates)
+}
+
 // CheckScheduleAlreadyExists is tested in scheduled_changefeed_test.go
@@ -106,7 +106,7 @@ func GetCertificateUserScope(
 ) (userScopes []CertificateUserScope, _ error) {
 	for _, uri := range peerCert.URIs {
 		uriString := uri.String()
-		if URISANHasCRDBPrefix(uriString) {
+		if URISANHasCRDBPrefix(uriString) && !URISANHasNodePrefix(uriString) {
 			tenantID, user, err := ParseTenantURISAN(uriString)
 			if err != nil {
 				return nil, err
@@ -180,6 +180,39 @@ func TestGetCertificateUserScope(t *testing.T) {
 	})
 }
 
+func TestGetCertificateNodeID(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	nodeURI, err := security.MakeNodeURISAN(3)
+	require.NoError(t, err)
+	for _, tc := range []struct {
+		spec   string
+		nodeID roachpb.NodeID
+		err    string
+	}{
+		{spec: "node", nodeID: 0},
+		{spec: "node,uri:" + nodeURI.String(), nodeID: 3},
+		{spec: "node,uri:crdb://tenant/123/user/node,uri:crdb://node/3", nodeID: 3},
+		{spec: "node,uri:crdb://node/3,uri:crdb://node/4", err: "several nodes"},
+		{spec: "node,uri:crdb://node/3x", err: "invalid node URI SAN"},
+		{spec: "node,uri:crdb://node/0", err: "invalid node URI SAN"},
+	} {
+		t.Run(tc.spec, func(t *testing.T) {
+			cert := makeFakeTLSState(t, tc.spec).PeerCertificates[0]
+			nodeID, err := security.GetCertificateNodeID(cert)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.nodeID, nodeID)
+			// Node URI SANs don't get in the way of the user scopes.
+			_, err = security.GetCertificateUserScope(cert)
+			require.NoError(t, err)
+		})
+	}
+}
+
 func TestSetCertPrincipalMap(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 	defer func() { _ = security.SetCertPrincipalMap(nil) }()
@@ -19,6 +19,7 @@ import (
 	"math/big"
 	"net"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -40,6 +41,8 @@ const (
 	caCommonName             = "Cockroach CA"
 	tenantURISANPrefixString = "crdb://"
 	tenantURISANFormatString = tenantURISANPrefixString + "tenant/%d/user/%s"
+	nodeURISANPrefixString   = tenantURISANPrefixString + "node/"
+	nodeURISANFormatString   = nodeURISANPrefixString + "%d"
 
 	// TenantsOU is the OrganizationalUnit that determines a client certificate should be treated as a tenant client
 	// certificate (as opposed to a KV node client certificate).
@@ -339,6 +342,40 @@ func URISANHasCRDBPrefix(rawlURI string) bool {
 	return strings.HasPrefix(rawlURI, tenantURISANPrefixString)
 }
 
+// MakeNodeURISAN constructs the URI SAN naming the node a node certificate is
+// issued to. The node ID named by the certificate of a peer is what other nodes
+// authenticate the peer as (see GetCertificateNodeID).
+func MakeNodeURISAN(nodeID roachpb.NodeID) (*url.URL, error) {
+	return url.Parse(fmt.Sprintf(nodeURISANFormatString, nodeID))
+}
+
+// URISANHasNodePrefix indicates whether a URI string has the node URI SAN
+// prefix.
+func URISANHasNodePrefix(rawURI string) bool {
+	return strings.HasPrefix(rawURI, nodeURISANPrefixString)
+}
+
+// GetCertificateNodeID returns the ID of the node the given certificate is
+// issued to, as named by its node URI SAN, or 0 if it doesn't name one.
+func GetCertificateNodeID(cert *x509.Certificate) (roachpb.NodeID, error) {
+	var nodeID roachpb.NodeID
+	for _, uri := range cert.URIs {
+		uriString := uri.String()
+		if !URISANHasNodePrefix(uriString) {
+			continue
+		}
+		id, err := strconv.ParseInt(strings.TrimPrefix(uriString, nodeURISANPrefixString), 10, 32)
+		if err != nil || id <= 0 {
+			return 0, errors.Errorf("invalid node URI SAN %s", uriString)
+		}
+		if nodeID != 0 && nodeID != roachpb.NodeID(id) {
+			return 0, errors.Errorf("certificate names several nodes: n%d and n%d", nodeID, id)
+		}
+		nodeID = roachpb.NodeID(id)
+	}
+	return nodeID, nil
+}
+
 // ParseTenantURISAN extracts the user and tenant ID contained within a tenant URI SAN.
 func ParseTenantURISAN(rawURL string) (roachpb.TenantID, string, error) {
 	r := strings.NewReader(rawURL)
@@ -24,6 +24,7 @@ import (
 
 	apd "github.com/cockroachdb/apd/v3"
 	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/clusterversion"
 	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
 	"github.com/cockroachdb/cockroach/pkg/jobs"
 	"github.com/cockroachdb/cockroach/pkg/jobs/jobspb"
@@ -159,6 +160,18 @@ var tableStatsMaxFetcherConcurrency = settings.RegisterIntSetting(
 	settings.PositiveInt,
 )
 
+// maxHeartbeatPause is the longest a node's heartbeats can be paused for
+// through the PauseHeartbeats RPC.
+var maxHeartbeatPause = settings.RegisterDurationSetting(
+	settings.SystemOnly,
+	"server.liveness.heartbeat_pause.max_duration",
+	"the longest the liveness heartbeats of a node can be paused for through the "+
+		"PauseHeartbeats RPC, which lets the handling of a node failing to heartbeat be "+
+		"exercised on a running cluster; 0 disables the RPC",
+	0,
+	settings.NonNegativeDurationWithMaximum(liveness.MaxHeartbeatPause),
+)
+
 func newSystemAdminServer(
 	sqlServer *SQLServer,
 	cs *cluster.Settings,
@@ -2193,6 +2206,12 @@ func (s *systemAdminServer) checkReadinessForHealthCheck(ctx context.Context) er
 		// has requested DrainMode_LEASES but not DrainMode_CLIENT.
 		return grpcstatus.Errorf(codes.Unavailable, "node is shutting down")
 	}
+	// Fail readiness ahead of drains declared in the liveness record, so that
+	// load balancers have moved new connections elsewhere by the time the node
+	// stops serving.
+	if l.DrainExpected(s.clock.Now(), readinessLeadTime.Get(&s.st.SV)) {
+		return grpcstatus.Errorf(codes.Unavailable, "node is about to drain")
+	}
 
 	if !s.sqlServer.isReady.Get() {
 		return grpcstatus.Errorf(codes.Unavailable, "node is not accepting SQL clients")
@@ -2268,12 +2287,121 @@ func (s *adminServer) Liveness(
 // Liveness returns the liveness state of all nodes on the cluster
 // based on a KV transaction. To reach all nodes in the cluster, consider
 // using (statusServer).NodesWithLiveness instead.
+//
+// If req.AsOf is set, the liveness records are read as of that time instead,
+// which allows reconstructing the cluster's view of liveness during a past
+// incident.
 func (s *systemAdminServer) Liveness(
-	ctx context.Context, _ *serverpb.LivenessRequest,
+	ctx context.Context, req *serverpb.LivenessRequest,
 ) (*serverpb.LivenessResponse, error) {
 	clock := s.clock
 
-	return getLivenessResponse(ctx, s.nodeLiveness, clock.Now(), s.st)
+	var resp *serverpb.LivenessResponse
+	var err error
+	if req.AsOf == nil {
+		resp, err = getLivenessResponse(ctx, s.nodeLiveness, clock.Now(), s.st)
+	} else {
+		asOf := hlc.Timestamp{WallTime: req.AsOf.UnixNano()}
+		if clock.Now().Less(asOf) {
+			return nil, grpcstatus.Errorf(codes.InvalidArgument, "as_of time %s is in the future", req.AsOf)
+		}
+		resp, err = getHistoricalLivenessResponse(ctx, s.nodeLiveness, asOf, s.st)
+	}
+	if err != nil {
+		return nil, err
+	}
+	filterLivenessResponseByTags(resp, req.Tags)
+	return resp, nil
+}
+
+// filterLivenessResponseByTags removes from the response the nodes that don't
+// carry all of the given tags.
+func filterLivenessResponseByTags(resp *serverpb.LivenessResponse, tags map[string]string) {
+	if len(tags) == 0 {
+		return
+	}
+	livenesses := resp.Livenesses[:0]
+	for _, l := range resp.Livenesses {
+		if l.MatchesTags(tags) {
+			livenesses = append(livenesses, l)
+		} else {
+			delete(resp.Statuses, l.NodeID)
+		}
+	}
+	resp.Livenesses = livenesses
+}
+
+// getHistoricalLivenessResponse is like getLivenessResponse, but reads the
+// liveness records as of the given timestamp and evaluates the statuses as of
+// that timestamp too.
+func getHistoricalLivenessResponse(
+	ctx context.Context, nl *liveness.NodeLiveness, asOf hlc.Timestamp, st *cluster.Settings,
+) (*serverpb.LivenessResponse, error) {
+	livenesses, err := nl.GetLivenessesFromKVAsOf(ctx, asOf)
+	if err != nil {
+		return nil, serverError(ctx, err)
+	}
+
+	threshold := liveness.TimeUntilStoreDead.Get(&st.SV)
+
+	statusMap := make(map[roachpb.NodeID]livenesspb.NodeLivenessStatus, len(livenesses))
+	for _, liveness := range livenesses {
+		statusMap[liveness.NodeID] = storepool.LivenessStatus(liveness, asOf, threshold)
+	}
+	return &serverpb.LivenessResponse{
+		Livenesses: livenesses,
+		Statuses:   statusMap,
+	}, nil
+}
+
+// MembershipStatus returns the membership and liveness status of the requested
+// nodes, derived from a snapshot of the liveness records read from KV. See
+// serverpb.AdminServer.MembershipStatus.
+func (s *systemAdminServer) MembershipStatus(
+	ctx context.Context, req *serverpb.MembershipStatusRequest,
+) (*serverpb.MembershipStatusResponse, error) {
+	ctx = s.AnnotateCtx(ctx)
+	if err := s.requireViewClusterMetadataPermission(ctx); err != nil {
+		// NB: not using serverError() here since the priv checker
+		// already returns a proper gRPC error status.
+		return nil, err
+	}
+
+	now := s.clock.Now()
+	livenesses, err := s.nodeLiveness.GetLivenessesFromKVAsOf(ctx, now)
+	if err != nil {
+		return nil, serverError(ctx, err)
+	}
+	requested := make(map[roachpb.NodeID]bool, len(req.NodeIDs))
+	for _, nodeID := range req.NodeIDs {
+		requested[nodeID] = true
+	}
+
+	threshold := liveness.TimeUntilStoreDead.Get(&s.st.SV)
+	resp := &serverpb.MembershipStatusResponse{Timestamp: now.GoTime()}
+	for _, l := range livenesses {
+		if len(requested) > 0 && !requested[l.NodeID] {
+			continue
+		}
+		delete(requested, l.NodeID)
+		resp.Nodes = append(resp.Nodes, serverpb.MembershipStatusResponse_Node{
+			NodeID:         l.NodeID,
+			Membership:     l.Membership,
+			LivenessStatus: storepool.LivenessStatus(l, now, threshold),
+			IsLive:         l.IsLive(now),
+			Draining:       l.Draining,
+		})
+	}
+	for nodeID := range requested {
+		resp.MissingNodeIDs = append(resp.MissingNodeIDs, nodeID)
+	}
+	sort.Slice(resp.Nodes, func(i, j int) bool {
+		return resp.Nodes[i].NodeID < resp.Nodes[j].NodeID
+	})
+	sort.Slice(resp.MissingNodeIDs, func(i, j int) bool {
+		return resp.MissingNodeIDs[i] < resp.MissingNodeIDs[j]
+	})
+	return resp, nil
 }
 
 func (s *adminServer) Jobs(
@@ -2771,12 +2899,15 @@ func (s *systemAdminServer) DecommissionPreCheck(
 	}
 
 	// Evaluate readiness by validating that there are no ranges with replicas on
-	// the given node(s) that did not pass checks.
+	// the given node(s) that did not pass checks, and that the remaining nodes
+	// can take over from them.
 	for _, nID := range nodesToCheck {
 		numReplicas := len(results.replicasByNode[nID])
 		var readiness serverpb.DecommissionPreCheckResponse_NodeReadiness
 		if len(rangeCheckErrsByNode[nID]) > 0 {
 			readiness = serverpb.DecommissionPreCheckResponse_ALLOCATION_ERRORS
+		} else if len(results.shortfalls) > 0 {
+			readiness = serverpb.DecommissionPreCheckResponse_REMAINING_NODE_SHORTFALLS
 		} else {
 			readiness = serverpb.DecommissionPreCheckResponse_READY
 		}
@@ -2787,6 +2918,7 @@ func (s *systemAdminServer) DecommissionPreCheck(
 			LivenessStatus:        livenessStatusByNodeID[nID],
 			ReplicaCount:          int64(numReplicas),
 			CheckedRanges:         rangeCheckErrsByNode[nID],
+			Shortfalls:            results.shortfalls,
 		}
 	}
 
@@ -2798,6 +2930,243 @@ func (s *systemAdminServer) DecommissionPreCheck(
 	return resp, nil
 }
 
+// SimulateMembershipChanges simulates the given plan of membership changes and
+// returns the predicted distribution of the replicas and range violations. It
+// scans all the range descriptors, so it requires the admin role.
+func (s *systemAdminServer) SimulateMembershipChanges(
+	ctx context.Context, req *serverpb.SimulateMembershipChangesRequest,
+) (*serverpb.SimulateMembershipChangesResponse, error) {
+	ctx = s.AnnotateCtx(ctx)
+	if _, err := s.requireAdminUser(ctx); err != nil {
+		// NB: not using serverError() here since the priv checker
+		// already returns a proper gRPC error status.
+		return nil, err
+	}
+
+	plan := membershipPlan{decommission: req.DecommissionNodeIDs}
+	for _, l := range req.AddNodeLocalities {
+		var locality roachpb.Locality
+		if l != "" {
+			if err := locality.Set(l); err != nil {
+				return nil, grpcstatus.Errorf(codes.InvalidArgument, "invalid locality %q: %v", l, err)
+			}
+		}
+		plan.add = append(plan.add, locality)
+	}
+	return s.server.SimulateMembershipChanges(ctx, plan)
+}
+
+// SafeToShutdown reports whether the requested node can be stopped right now
+// without causing any range to lose quorum.
+func (s *systemAdminServer) SafeToShutdown(
+	ctx context.Context, req *serverpb.SafeToShutdownRequest,
+) (*serverpb.SafeToShutdownResponse, error) {
+	ctx = s.AnnotateCtx(ctx)
+	if _, err := s.requireAdminUser(ctx); err != nil {
+		// NB: not using serverError() here since the priv checker
+		// already returns a proper gRPC error status.
+		return nil, err
+	}
+
+	nodeID := req.NodeID
+	if nodeID == 0 {
+		nodeID = roachpb.NodeID(s.serverIterator.getID())
+	}
+	if _, ok := s.nodeLiveness.GetLiveness(nodeID); !ok {
+		return nil, grpcstatus.Errorf(codes.NotFound, "n%d not found in liveness map", nodeID)
+	}
+
+	result, err := s.server.SafeToShutdown(ctx, nodeID, int(req.NumRangeReport))
+	if err != nil {
+		// NB: not using serverError() here since SafeToShutdown
+		// already returns a proper gRPC error status.
+		return nil, err
+	}
+	return &serverpb.SafeToShutdownResponse{
+		NodeID:                nodeID,
+		Safe:                  result.unavailableRangeCount == 0,
+		UnavailableRangeCount: result.unavailableRangeCount,
+		UnavailableRangeIDs:   result.unavailableRanges,
+		NonLiveNodeIDs:        result.nonLiveNodes,
+	}, nil
+}
+
+// ScheduleDecommission schedules the decommission of the given node.
+func (s *systemAdminServer) ScheduleDecommission(
+	ctx context.Context, req *serverpb.ScheduleDecommissionRequest,
+) (*serverpb.ScheduleDecommissionResponse, error) {
+	ctx = s.AnnotateCtx(ctx)
+	if _, err := s.requireAdminUser(ctx); err != nil {
+		// NB: not using serverError() here since the priv checker
+		// already returns a proper gRPC error status.
+		return nil, err
+	}
+	if req.NodeID == 0 {
+		return nil, grpcstatus.Errorf(codes.InvalidArgument, "no node ID specified")
+	}
+
+	var at hlc.Timestamp
+	if req.At != nil {
+		at = hlc.Timestamp{WallTime: req.At.UnixNano()}
+	}
+	if l, ok := s.nodeLiveness.GetLiveness(req.NodeID); ok && !at.IsEmpty() && !l.Membership.Active() {
+		return nil, grpcstatus.Errorf(codes.FailedPrecondition,
+			"can only schedule the decommission of an active node; n%d found to be %s", req.NodeID, l.Membership)
+	}
+	if err := s.nodeLiveness.ScheduleDecommission(ctx, req.NodeID, at, req.Reason); err != nil {
+		if errors.Is(err, liveness.ErrMissingRecord) {
+			return nil, grpcstatus.Error(codes.NotFound, liveness.ErrMissingRecord.Error())
+		}
+		return nil, serverError(ctx, err)
+	}
+	if at.IsEmpty() {
+		log.Ops.Infof(ctx, "canceled scheduled decommission of n%d", req.NodeID)
+	} else {
+		log.Ops.Infof(ctx, "scheduled decommission of n%d at %s", req.NodeID, at)
+	}
+	return &serverpb.ScheduleDecommissionResponse{}, nil
+}
+
+// SetMaintenanceWindow declares a maintenance window for the given node.
+func (s *systemAdminServer) SetMaintenanceWindow(
+	ctx context.Context, req *serverpb.SetMaintenanceWindowRequest,
+) (*serverpb.SetMaintenanceWindowResponse, error) {
+	ctx = s.AnnotateCtx(ctx)
+	if _, err := s.requireAdminUser(ctx); err != nil {
+		// NB: not using serverError() here since the priv checker
+		// already returns a proper gRPC error status.
+		return nil, err
+	}
+	if req.Duration < 0 {
+		return nil, grpcstatus.Errorf(codes.InvalidArgument, "invalid maintenance window duration %s", req.Duration)
+	}
+
+	nodeID := req.NodeID
+	if nodeID == 0 {
+		nodeID = roachpb.NodeID(s.serverIterator.getID())
+	}
+
+	var start, end hlc.Timestamp
+	if req.Duration > 0 {
+		start = s.clock.Now()
+		if req.Start != nil {
+			start = hlc.Timestamp{WallTime: req.Start.UnixNano()}
+		}
+		end = start.AddDuration(req.Duration)
+	}
+	if err := s.nodeLiveness.SetMaintenanceWindow(ctx, nodeID, start, end); err != nil {
+		if errors.Is(err, liveness.ErrMissingRecord) {
+			return nil, grpcstatus.Error(codes.NotFound, liveness.ErrMissingRecord.Error())
+		}
+		return nil, serverError(ctx, err)
+	}
+	log.Ops.Infof(ctx, "maintenance window for n%d set to [%s, %s)", nodeID, start, end)
+	return &serverpb.SetMaintenanceWindowResponse{}, nil
+}
+
+// SetTimeUntilDeadOverride overrides the time until the given nodes are
+// considered dead by the allocator.
+func (s *systemAdminServer) SetTimeUntilDeadOverride(
+	ctx context.Context, req *serverpb.SetTimeUntilDeadOverrideRequest,
+) (*serverpb.SetTimeUntilDeadOverrideResponse, error) {
+	ctx = s.AnnotateCtx(ctx)
+	if _, err := s.requireAdminUser(ctx); err != nil {
+		// NB: not using serverError() here since the priv checker
+		// already returns a proper gRPC error status.
+		return nil, err
+	}
+	if len(req.NodeIDs) == 0 {
+		return nil, grpcstatus.Errorf(codes.InvalidArgument, "no nodes specified")
+	}
+	if req.Duration < 0 {
+		return nil, grpcstatus.Errorf(codes.InvalidArgument, "invalid override duration %s", req.Duration)
+	}
+
+	var expiration hlc.Timestamp
+	if req.Duration > 0 {
+		if err := liveness.ValidateTimeUntilDeadOverride(req.TimeUntilDead); err != nil {
+			return nil, grpcstatus.Error(codes.InvalidArgument, err.Error())
+		}
+		expiration = s.clock.Now().AddDuration(req.Duration)
+	}
+	for _, nodeID := range req.NodeIDs {
+		if err := s.nodeLiveness.SetTimeUntilDeadOverride(ctx, nodeID, req.TimeUntilDead, expiration); err != nil {
+			if errors.Is(err, liveness.ErrMissingRecord) {
+				return nil, grpcstatus.Errorf(codes.NotFound, "n%d: %s", nodeID, liveness.ErrMissingRecord)
+			}
+			return nil, serverError(ctx, err)
+		}
+		if expiration.IsEmpty() {
+			log.Ops.Infof(ctx, "cleared time until dead override for n%d", nodeID)
+		} else {
+			log.Ops.Infof(ctx, "time until dead for n%d overridden to %s until %s",
+				nodeID, req.TimeUntilDead, expiration)
+		}
+	}
+	return &serverpb.SetTimeUntilDeadOverrideResponse{}, nil
+}
+
+// SetNodeTags replaces the tags of the given node.
+func (s *systemAdminServer) SetNodeTags(
+	ctx context.Context, req *serverpb.SetNodeTagsRequest,
+) (*serverpb.SetNodeTagsResponse, error) {
+	ctx = s.AnnotateCtx(ctx)
+	if _, err := s.requireAdminUser(ctx); err != nil {
+		// NB: not using serverError() here since the priv checker
+		// already returns a proper gRPC error status.
+		return nil, err
+	}
+	if err := livenesspb.ValidateTags(req.Tags); err != nil {
+		return nil, grpcstatus.Error(codes.InvalidArgument, err.Error())
+	}
+
+	nodeID := req.NodeID
+	if nodeID == 0 {
+		nodeID = roachpb.NodeID(s.serverIterator.getID())
+	}
+	if err := s.nodeLiveness.SetTags(ctx, nodeID, req.Tags); err != nil {
+		if errors.Is(err, liveness.ErrMissingRecord) {
+			return nil, grpcstatus.Error(codes.NotFound, liveness.ErrMissingRecord.Error())
+		}
+		return nil, serverError(ctx, err)
+	}
+	log.Ops.Infof(ctx, "tags of n%d set to %v", nodeID, req.Tags)
+	return &serverpb.SetNodeTagsResponse{}, nil
+}
+
+// PauseHeartbeats pauses the liveness heartbeats of the given node.
+func (s *systemAdminServer) PauseHeartbeats(
+	ctx context.Context, req *serverpb.PauseHeartbeatsRequest,
+) (*serverpb.PauseHeartbeatsResponse, error) {
+	ctx = s.AnnotateCtx(ctx)
+	if _, err := s.requireAdminUser(ctx); err != nil {
+		// NB: not using serverError() here since the priv checker
+		// already returns a proper gRPC error status.
+		return nil, err
+	}
+
+	if req.NodeID != 0 && req.NodeID != roachpb.NodeID(s.serverIterator.getID()) {
+		admin, err := s.dialNode(ctx, req.NodeID)
+		if err != nil {
+			return nil, serverError(ctx, err)
+		}
+		return admin.PauseHeartbeats(ctx, req)
+	}
+
+	max := maxHeartbeatPause.Get(&s.st.SV)
+	if max == 0 && req.Duration > 0 {
+		return nil, grpcstatus.Errorf(codes.FailedPrecondition,
+			"pausing heartbeats is disabled; see %s", maxHeartbeatPause.Key())
+	}
+	if req.Duration > max {
+		return nil, grpcstatus.Errorf(codes.InvalidArgument,
+			"heartbeat pause of %s exceeds the maximum of %s configured by %s",
+			req.Duration, max, maxHeartbeatPause.Key())
+	}
+	until := s.nodeLiveness.PauseHeartbeats(ctx, req.Duration)
+	return &serverpb.PauseHeartbeatsResponse{PausedUntil: until}, nil
+}
+
 // DecommissionStatus returns the DecommissionStatus for all or the given nodes.
 func (s *systemAdminServer) DecommissionStatus(
 	ctx context.Context, req *serverpb.DecommissionStatusRequest,
@@ -2951,14 +3320,58 @@ func (s *systemAdminServer) Decommission(
 	if len(nodeIDs) == 0 {
 		return nil, grpcstatus.Errorf(codes.InvalidArgument, "no node ID specified")
 	}
+	if req.AsJob && !req.TargetMembership.Decommissioning() {
+		return nil, grpcstatus.Errorf(codes.InvalidArgument,
+			"only a decommission to %s can be carried out by a job", livenesspb.MembershipStatus_DECOMMISSIONING)
+	}
+	if req.AsJob && !s.st.Version.IsActive(ctx, clusterversion.V23_2_DecommissionJob) {
+		// Unimplemented, like on the servers that predate decommission jobs,
+		// lets clients fall back to driving the decommission themselves.
+		return nil, grpcstatus.Errorf(codes.Unimplemented,
+			"decommissions can only be carried out by jobs once the cluster is upgraded to %s",
+			clusterversion.ByKey(clusterversion.V23_2_DecommissionJob))
+	}
+
+	if req.TargetMembership.Decommissioning() && !req.AllowUnderReplication {
+		if err := s.server.enforceReplicationFactor(ctx, nodeIDs); err != nil {
+			var urErr *underReplicationError
+			if errors.As(err, &urErr) {
+				telemetry.Inc(telemetryDecommissionFailedUnderReplication)
+				return nil, grpcstatus.Error(codes.FailedPrecondition, urErr.Error())
+			}
+			return nil, serverError(ctx, err)
+		}
+	}
+	if req.AsJob {
+		// The job marks the nodes as decommissioning itself, so that there are
+		// no nodes marked without a job to carry out their decommission.
+		if err := s.server.validateDecommissionJob(nodeIDs); err != nil {
+			return nil, err
+		}
+		user, err := userFromIncomingRPCContext(ctx)
+		if err != nil {
+			return nil, serverError(ctx, err)
+		}
+		jobID, err := s.createDecommissionJob(ctx, nodeIDs, req.Reason, req.AllowUnderReplication, user)
+		if err != nil {
+			return nil, serverError(ctx, err)
+		}
+		return &serverpb.DecommissionStatusResponse{JobID: int64(jobID)}, nil
+	}
 
 	// Mark the target nodes with their new membership status. They'll find out
 	// as they heartbeat their liveness.
-	if err := s.server.Decommission(ctx, req.TargetMembership, nodeIDs); err != nil {
+	changed, err := s.server.decommissionWithToken(
+		ctx, req.TargetMembership, nodeIDs, req.Reason, req.IdempotencyToken,
+	)
+	if err != nil {
 		// NB: not using serverError() here since Decommission
 		// already returns a proper gRPC error status.
 		return nil, err
 	}
+	if changed && req.TargetMembership.Decommissioning() && req.AllowUnderReplication {
+		telemetry.Inc(telemetryDecommissionForced)
+	}
 
 	// We return an empty response when setting the final DECOMMISSIONED state,
 	// since a node can be asked to decommission itself which may cause it to
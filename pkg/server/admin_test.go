@@ -3502,3 +3502,219 @@ func TestDatabaseAndTableIndexRecommendations(t *testing.T) {
 	}
 	require.Equal(t, false, tableDetails.HasIndexRecommendations)
 }
+
+// TestAdminPauseHeartbeats verifies that the PauseHeartbeats RPC pauses the
+// heartbeats of the target node within the configured bounds, and that the
+// node is live again once they are resumed.
+func TestAdminPauseHeartbeats(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := serverutils.StartNewTestCluster(t, 3, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	adminSrv := tc.Server(0)
+	conn, err := adminSrv.RPCContext().GRPCDialNode(
+		adminSrv.RPCAddr(), adminSrv.NodeID(), rpc.DefaultClass).Connect(ctx)
+	require.NoError(t, err)
+	adminClient := serverpb.NewAdminClient(conn)
+
+	target := tc.Server(2)
+	req := &serverpb.PauseHeartbeatsRequest{NodeID: target.NodeID(), Duration: time.Minute}
+
+	// The RPC is disabled by default.
+	_, err = adminClient.PauseHeartbeats(ctx, req)
+	require.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	// The limit is that of the target node.
+	maxHeartbeatPause.Override(ctx, &target.ClusterSettings().SV, 30*time.Second)
+	_, err = adminClient.PauseHeartbeats(ctx, req)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	req.Duration = 30 * time.Second
+	resp, err := adminClient.PauseHeartbeats(ctx, req)
+	require.NoError(t, err)
+	require.False(t, resp.PausedUntil.IsZero())
+
+	nl := adminSrv.NodeLiveness().(*liveness.NodeLiveness)
+	testutils.SucceedsSoon(t, func() error {
+		if live, err := nl.IsLive(target.NodeID()); err != nil || live {
+			return errors.Errorf("n%d still live (err=%v)", target.NodeID(), err)
+		}
+		return nil
+	})
+
+	req.Duration = 0
+	resp, err = adminClient.PauseHeartbeats(ctx, req)
+	require.NoError(t, err)
+	require.True(t, resp.PausedUntil.IsZero())
+	testutils.SucceedsSoon(t, func() error {
+		if live, err := nl.IsLive(target.NodeID()); err != nil || !live {
+			return errors.Errorf("n%d not live (err=%v)", target.NodeID(), err)
+		}
+		return nil
+	})
+}
+
+// TestAdminSetTimeUntilDeadOverride verifies that the SetTimeUntilDeadOverride
+// RPC records the override in the liveness records of the target nodes, and
+// clears it.
+func TestAdminSetTimeUntilDeadOverride(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := serverutils.StartNewTestCluster(t, 3, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	adminSrv := tc.Server(0)
+	conn, err := adminSrv.RPCContext().GRPCDialNode(
+		adminSrv.RPCAddr(), adminSrv.NodeID(), rpc.DefaultClass).Connect(ctx)
+	require.NoError(t, err)
+	adminClient := serverpb.NewAdminClient(conn)
+	nl := adminSrv.NodeLiveness().(*liveness.NodeLiveness)
+
+	targets := []roachpb.NodeID{tc.Server(1).NodeID(), tc.Server(2).NodeID()}
+	req := &serverpb.SetTimeUntilDeadOverrideRequest{
+		NodeIDs:       targets,
+		TimeUntilDead: time.Second,
+		Duration:      time.Hour,
+	}
+
+	// The override is subject to the same lower bound as the setting.
+	_, err = adminClient.SetTimeUntilDeadOverride(ctx, req)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	req.TimeUntilDead = 30 * time.Minute
+	_, err = adminClient.SetTimeUntilDeadOverride(ctx, req)
+	require.NoError(t, err)
+	now := adminSrv.Clock().Now()
+	livenesses, err := nl.GetLivenessesFromKV(ctx)
+	require.NoError(t, err)
+	for _, l := range livenesses {
+		exp := time.Minute
+		if l.NodeID != adminSrv.NodeID() {
+			exp = 30 * time.Minute
+		}
+		require.Equal(t, exp, l.TimeUntilDead(now, time.Minute), "n%d", l.NodeID)
+	}
+
+	// A zero duration clears the override.
+	req.Duration = 0
+	_, err = adminClient.SetTimeUntilDeadOverride(ctx, req)
+	require.NoError(t, err)
+	livenesses, err = nl.GetLivenessesFromKV(ctx)
+	require.NoError(t, err)
+	for _, l := range livenesses {
+		require.Equal(t, livenesspb.TimeUntilDeadOverride{}, l.TimeUntilDeadOverride)
+	}
+}
+
+// TestAdminSetNodeTags verifies that the SetNodeTags RPC records tags in the
+// liveness record of the target node, that the Liveness RPC can filter nodes
+// by tags, and that the tags can be cleared.
+func TestAdminSetNodeTags(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := serverutils.StartNewTestCluster(t, 3, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	adminSrv := tc.Server(0)
+	conn, err := adminSrv.RPCContext().GRPCDialNode(
+		adminSrv.RPCAddr(), adminSrv.NodeID(), rpc.DefaultClass).Connect(ctx)
+	require.NoError(t, err)
+	adminClient := serverpb.NewAdminClient(conn)
+
+	target := tc.Server(1).NodeID()
+	tags := map[string]string{"rack": "r12", "cost-center": "infra"}
+
+	// Tags are subject to size limits.
+	tooMany := make(map[string]string)
+	for i := 0; i <= livenesspb.MaxTags; i++ {
+		tooMany[fmt.Sprintf("k%d", i)] = "v"
+	}
+	_, err = adminClient.SetNodeTags(ctx, &serverpb.SetNodeTagsRequest{NodeID: target, Tags: tooMany})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = adminClient.SetNodeTags(ctx, &serverpb.SetNodeTagsRequest{NodeID: target, Tags: tags})
+	require.NoError(t, err)
+
+	resp, err := adminClient.Liveness(ctx, &serverpb.LivenessRequest{
+		Tags: map[string]string{"rack": "r12"},
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Livenesses, 1)
+	require.Equal(t, target, resp.Livenesses[0].NodeID)
+	require.Equal(t, tags, resp.Livenesses[0].Tags)
+	require.Len(t, resp.Statuses, 1)
+
+	// The tags survive the node's own heartbeats.
+	require.NoError(t, tc.Server(1).HeartbeatNodeLiveness())
+	resp, err = adminClient.Liveness(ctx, &serverpb.LivenessRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.Livenesses, 3)
+	for _, l := range resp.Livenesses {
+		if l.NodeID == target {
+			require.Equal(t, tags, l.Tags)
+		} else {
+			require.Empty(t, l.Tags)
+		}
+	}
+
+	// Setting no tags clears them.
+	_, err = adminClient.SetNodeTags(ctx, &serverpb.SetNodeTagsRequest{NodeID: target})
+	require.NoError(t, err)
+	resp, err = adminClient.Liveness(ctx, &serverpb.LivenessRequest{
+		Tags: map[string]string{"rack": "r12"},
+	})
+	require.NoError(t, err)
+	require.Empty(t, resp.Livenesses)
+}
+
+func TestAdminAPIMembershipStatus(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := serverutils.StartNewTestCluster(t, 3, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	s := tc.Server(0).(*TestServer)
+	drainingID := tc.Server(2).NodeID()
+	nl := tc.Server(2).NodeLiveness().(*liveness.NodeLiveness)
+	require.NoError(t, nl.SetDraining(ctx, true /* drain */, nil /* reporter */))
+
+	before := s.Clock().PhysicalTime()
+	res, err := s.admin.MembershipStatus(ctx, &serverpb.MembershipStatusRequest{
+		NodeIDs: []roachpb.NodeID{drainingID, 1, 7},
+	})
+	require.NoError(t, err)
+	require.False(t, res.Timestamp.Before(before))
+	require.Equal(t, []roachpb.NodeID{7}, res.MissingNodeIDs)
+	require.Len(t, res.Nodes, 2)
+	require.Equal(t, roachpb.NodeID(1), res.Nodes[0].NodeID)
+	require.False(t, res.Nodes[0].Draining)
+	require.Equal(t, drainingID, res.Nodes[1].NodeID)
+	require.True(t, res.Nodes[1].Draining)
+	for _, n := range res.Nodes {
+		require.Equal(t, livenesspb.MembershipStatus_ACTIVE, n.Membership)
+		require.True(t, n.IsLive)
+	}
+
+	// All the nodes are returned if none are requested.
+	res, err = s.admin.MembershipStatus(ctx, &serverpb.MembershipStatusRequest{})
+	require.NoError(t, err)
+	require.Len(t, res.Nodes, 3)
+	require.Empty(t, res.MissingNodeIDs)
+}
@@ -73,6 +73,9 @@ type ApiV2System interface {
 	health(w http.ResponseWriter, r *http.Request)
 	listNodes(w http.ResponseWriter, r *http.Request)
 	listNodeRanges(w http.ResponseWriter, r *http.Request)
+	listLiveness(w http.ResponseWriter, r *http.Request)
+	membershipGraph(w http.ResponseWriter, r *http.Request)
+	listSQLNodes(w http.ResponseWriter, r *http.Request)
 }
 
 type apiV2ServerOpts struct {
@@ -194,9 +197,12 @@ func registerRoutes(
 		// Any endpoint returning range information requires an admin user. This is because range start/end keys
 		// are sensitive info.
 		{"nodes/{node_id}/ranges/", systemRoutes.listNodeRanges, true, adminRole, noOption, false},
+		{"liveness/", systemRoutes.listLiveness, true, adminRole, noOption, false},
+		{"liveness/membership-graph/", systemRoutes.membershipGraph, true, adminRole, noOption, false},
 		{"ranges/hot/", a.listHotRanges, true, adminRole, noOption, false},
 		{"ranges/{range_id:[0-9]+}/", a.listRange, true, adminRole, noOption, false},
 		{"health/", systemRoutes.health, false, regularRole, noOption, false},
+		{"sql-nodes/", systemRoutes.listSQLNodes, true, regularRole, noOption, false},
 		{"users/", a.listUsers, true, regularRole, noOption, false},
 		{"events/", a.listEvents, true, adminRole, noOption, false},
 		{"databases/", a.listDatabases, true, regularRole, noOption, false},
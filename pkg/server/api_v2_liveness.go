@@ -0,0 +1,224 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+)
+
+// Liveness of a node.
+type nodeLiveness struct {
+	// NodeID is the integer ID of this node.
+	NodeID int32 `json:"node_id"`
+	// Epoch is the liveness epoch of this node, which is incremented every
+	// time the node's liveness record expires and another node takes over its
+	// leases.
+	Epoch int64 `json:"epoch"`
+	// Expiration is the time at which the node's liveness record expires
+	// unless heartbeated, in nanoseconds since Unix epoch.
+	Expiration int64 `json:"expiration"`
+	// Draining is whether the node is draining.
+	Draining bool `json:"draining"`
+	// Membership is the membership status of this node: active,
+	// decommissioning or decommissioned.
+	Membership string `json:"membership"`
+	// Status is the status of this node derived from its liveness record:
+	// live, draining, unavailable, dead, decommissioning, decommissioned or
+	// unknown.
+	Status string `json:"status"`
+	// Locality is the locality of this node, if known.
+	Locality roachpb.Locality `json:"locality"`
+}
+
+// Response struct for listLiveness.
+//
+// swagger:model livenessResponse
+type livenessResponse struct {
+	// Liveness of nodes, ordered by node ID.
+	//
+	// swagger:allOf
+	Nodes []nodeLiveness `json:"nodes"`
+	// Summary is the number of nodes in each status across the cluster,
+	// regardless of the filters of the request. It is derived from the same
+	// records and statuses as Nodes.
+	Summary livenesspb.IsLiveMapCounts `json:"summary"`
+	// Now is the time at which the statuses were derived, in nanoseconds since
+	// Unix epoch.
+	Now int64 `json:"now"`
+}
+
+// livenessStatusName returns the name of the given status used by the
+// liveness endpoint, e.g. "live" for NodeLivenessStatus_LIVE.
+func livenessStatusName(s livenesspb.NodeLivenessStatus) string {
+	return strings.ToLower(strings.TrimPrefix(s.String(), "NODE_STATUS_"))
+}
+
+// swagger:operation GET /liveness/ listLiveness
+//
+// # List node liveness
+//
+// List the liveness records of all nodes on this cluster, along with the
+// statuses derived from them.
+//
+// Client must be logged-in as a user with admin privileges.
+//
+// ---
+// parameters:
+//   - name: status
+//     type: string
+//     in: query
+//     description: Comma-separated list of statuses (e.g. live,draining) to
+//     restrict the results to.
+//     required: false
+//   - name: locality
+//     type: string
+//     in: query
+//     description: Locality tiers (e.g. region=us-east1,zone=a) that the
+//     returned nodes must all have.
+//     required: false
+//
+// produces:
+// - application/json
+// security:
+// - api_session: []
+// responses:
+//
+//	"200":
+//	  description: List liveness response.
+//	  schema:
+//	    "$ref": "#/definitions/livenessResponse"
+func (a *apiV2SystemServer) listLiveness(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	ctx = forwardHTTPAuthInfoToRPCCalls(ctx, r)
+
+	var statusFilter map[string]struct{}
+	if s := r.URL.Query().Get("status"); s != "" {
+		statusFilter = make(map[string]struct{})
+		for _, name := range strings.Split(s, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if _, ok := livenesspb.NodeLivenessStatus_value["NODE_STATUS_"+strings.ToUpper(name)]; !ok {
+				http.Error(w, fmt.Sprintf("invalid status %q", name), http.StatusBadRequest)
+				return
+			}
+			statusFilter[name] = struct{}{}
+		}
+	}
+	var localityFilter roachpb.Locality
+	if s := r.URL.Query().Get("locality"); s != "" {
+		if err := localityFilter.Set(s); err != nil {
+			http.Error(w, fmt.Sprintf("invalid locality: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	now := a.systemAdmin.clock.Now()
+	livenesses, err := getLivenessResponse(ctx, a.systemAdmin.nodeLiveness, now, a.systemAdmin.st)
+	if err != nil {
+		apiV2InternalError(ctx, err, w)
+		return
+	}
+
+	resp := livenessResponse{
+		Nodes:   make([]nodeLiveness, 0, len(livenesses.Livenesses)),
+		Summary: livenesspb.CountStatuses(livenesses.Statuses),
+		Now:     now.WallTime,
+	}
+	for _, l := range livenesses.Livenesses {
+		status := livenessStatusName(livenesses.Statuses[l.NodeID])
+		if _, ok := statusFilter[status]; statusFilter != nil && !ok {
+			continue
+		}
+		var locality roachpb.Locality
+		if desc, err := a.systemStatus.gossip.GetNodeDescriptor(l.NodeID); err == nil {
+			locality = desc.Locality
+		}
+		if ok, _ := locality.Matches(localityFilter); !ok {
+			continue
+		}
+		resp.Nodes = append(resp.Nodes, nodeLiveness{
+			NodeID:     int32(l.NodeID),
+			Epoch:      l.Epoch,
+			Expiration: l.Expiration.WallTime,
+			Draining:   l.Draining,
+			Membership: l.Membership.String(),
+			Status:     status,
+			Locality:   locality,
+		})
+	}
+	sort.Slice(resp.Nodes, func(i, j int) bool {
+		return resp.Nodes[i].NodeID < resp.Nodes[j].NodeID
+	})
+	writeJSONResponse(ctx, w, http.StatusOK, resp)
+}
+
+func (a *apiV2Server) listLiveness(w http.ResponseWriter, r *http.Request) {
+	writeJSONResponse(r.Context(), w, http.StatusNotImplemented, nil)
+}
+
+// swagger:operation GET /liveness/membership-graph/ membershipGraph
+//
+// # Membership state machine
+//
+// Describe the node membership state machine: the membership statuses, the
+// transitions between them that are allowed, and the membership status of
+// the node serving the request. The description is generated from the table
+// used to validate membership changes.
+//
+// Client must be logged-in as a user with admin privileges.
+//
+// ---
+// parameters:
+//   - name: format
+//     type: string
+//     in: query
+//     description: Either json (the default) or dot, for a rendering in the
+//     Graphviz DOT language.
+//     required: false
+//
+// produces:
+// - application/json
+// - text/vnd.graphviz
+// security:
+// - api_session: []
+// responses:
+//
+//	"200":
+//	  description: Membership state machine response.
+func (a *apiV2SystemServer) membershipGraph(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var current *livenesspb.MembershipStatus
+	if self, ok := a.systemAdmin.nodeLiveness.Self(); ok {
+		current = &self.Membership
+	}
+	graph := livenesspb.MakeMembershipGraph(current)
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+		writeJSONResponse(ctx, w, http.StatusOK, graph)
+	case "dot":
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(graph.DOT()))
+	default:
+		http.Error(w, fmt.Sprintf("invalid format %q", format), http.StatusBadRequest)
+	}
+}
+
+func (a *apiV2Server) membershipGraph(w http.ResponseWriter, r *http.Request) {
+	writeJSONResponse(r.Context(), w, http.StatusNotImplemented, nil)
+}
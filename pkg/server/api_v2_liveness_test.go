@@ -0,0 +1,133 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/testutils/serverutils"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLivenessV2(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	serverArgs := make(map[int]base.TestServerArgs)
+	for i, region := range []string{"r1", "r1", "r2"} {
+		serverArgs[i] = base.TestServerArgs{
+			Locality: roachpb.Locality{Tiers: []roachpb.Tier{{Key: "region", Value: region}}},
+		}
+	}
+	testCluster := serverutils.StartNewTestCluster(t, 3, base.TestClusterArgs{
+		ServerArgsPerNode: serverArgs,
+	})
+	ctx := context.Background()
+	defer testCluster.Stopper().Stop(ctx)
+
+	ts1 := testCluster.Server(0)
+	client, err := ts1.GetAdminHTTPClient()
+	require.NoError(t, err)
+
+	get := func(query string) (int, livenessResponse) {
+		req, err := http.NewRequest("GET", ts1.AdminURL()+apiV2Path+"liveness/"+query, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var livenessResp livenessResponse
+		if resp.StatusCode == http.StatusOK {
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&livenessResp))
+		}
+		return resp.StatusCode, livenessResp
+	}
+
+	code, resp := get("")
+	require.Equal(t, http.StatusOK, code)
+	require.Len(t, resp.Nodes, 3)
+	for i, n := range resp.Nodes {
+		require.Equal(t, int32(i+1), n.NodeID)
+		require.Equal(t, "live", n.Status)
+		require.Equal(t, "active", n.Membership)
+		require.Greater(t, n.Epoch, int64(0))
+		require.Greater(t, n.Expiration, int64(0))
+	}
+	require.Equal(t, livenesspb.IsLiveMapCounts{Live: 3}, resp.Summary)
+
+	for _, tc := range []struct {
+		query string
+		exp   []int32
+	}{
+		{query: "?status=live", exp: []int32{1, 2, 3}},
+		{query: "?status=dead,draining", exp: nil},
+		{query: "?locality=region=r2", exp: []int32{3}},
+		{query: "?status=live&locality=region=r1", exp: []int32{1, 2}},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			code, resp := get(tc.query)
+			require.Equal(t, http.StatusOK, code)
+			var nodeIDs []int32
+			for _, n := range resp.Nodes {
+				nodeIDs = append(nodeIDs, n.NodeID)
+			}
+			require.Equal(t, tc.exp, nodeIDs, "%+v", resp.Nodes)
+		})
+	}
+
+	code, _ = get("?status=sleepy")
+	require.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestMembershipGraphV2(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, _, _ := serverutils.StartServer(t, base.TestServerArgs{})
+	defer s.Stopper().Stop(ctx)
+
+	client, err := s.GetAdminHTTPClient()
+	require.NoError(t, err)
+
+	get := func(query string) *http.Response {
+		resp, err := client.Get(s.AdminURL() + apiV2Path + "liveness/membership-graph/" + query)
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := get("")
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var graph livenesspb.MembershipGraph
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&graph))
+	active := livenesspb.MembershipStatus_ACTIVE
+	require.Equal(t, livenesspb.MakeMembershipGraph(&active), graph)
+
+	dotResp := get("?format=dot")
+	defer dotResp.Body.Close()
+	require.Equal(t, http.StatusOK, dotResp.StatusCode)
+	dot, err := io.ReadAll(dotResp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(dot), `"active" [style=filled];`)
+
+	badResp := get("?format=svg")
+	defer badResp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, badResp.StatusCode)
+}
@@ -0,0 +1,139 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+)
+
+// A node accepting SQL connections.
+type sqlNode struct {
+	// NodeID is the integer ID of this node.
+	NodeID int32 `json:"node_id"`
+	// SQLAddress is the address advertised by this node for SQL connections.
+	SQLAddress string `json:"sql_address"`
+	// Locality is the locality of this node.
+	Locality roachpb.Locality `json:"locality"`
+}
+
+// Response struct for listSQLNodes.
+//
+// swagger:model sqlNodesResponse
+type sqlNodesResponse struct {
+	// Nodes accepting SQL connections, ordered by node ID.
+	//
+	// swagger:allOf
+	Nodes []sqlNode `json:"nodes"`
+	// Now is the time at which the list was computed, in nanoseconds since
+	// Unix epoch.
+	Now int64 `json:"now"`
+	// ValidUntil is the time until which the list holds absent new liveness
+	// information, in nanoseconds since Unix epoch. Clients watching the list
+	// should poll again no later than that. Zero if the list only changes when
+	// new liveness information arrives.
+	ValidUntil int64 `json:"valid_until"`
+}
+
+// swagger:operation GET /sql-nodes/ listSQLNodes
+//
+// # List SQL nodes
+//
+// List the nodes that currently accept SQL connections, along with the
+// addresses they advertise for them. A node is listed if it's live, reachable
+// from the node serving the request and neither draining, decommissioning nor
+// shutting down. This is meant for external load balancers and connection
+// pools to discover the nodes to route connections to.
+//
+// ---
+// parameters:
+//   - name: locality
+//     type: string
+//     in: query
+//     description: Locality tiers (e.g. region=us-east1,zone=a) that the
+//     returned nodes must all have.
+//     required: false
+//   - name: format
+//     type: string
+//     in: query
+//     description: Either json (the default) or text, for a list of the SQL
+//     addresses, one per line.
+//     required: false
+//
+// produces:
+// - application/json
+// - text/plain
+// security:
+// - api_session: []
+// responses:
+//
+//	"200":
+//	  description: List SQL nodes response.
+//	  schema:
+//	    "$ref": "#/definitions/sqlNodesResponse"
+func (a *apiV2SystemServer) listSQLNodes(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "text" {
+		http.Error(w, fmt.Sprintf("invalid format %q", format), http.StatusBadRequest)
+		return
+	}
+	var localityFilter roachpb.Locality
+	if s := r.URL.Query().Get("locality"); s != "" {
+		if err := localityFilter.Set(s); err != nil {
+			http.Error(w, fmt.Sprintf("invalid locality: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	now := a.systemAdmin.clock.Now()
+	nodes, validUntil := liveSQLNodes(
+		a.systemAdmin.nodeLiveness, a.systemStatus.gossip, a.systemAdmin.st, now, localityFilter)
+	resp := sqlNodesResponse{
+		Nodes: make([]sqlNode, 0, len(nodes)),
+		Now:   now.WallTime,
+	}
+	for nodeID, desc := range nodes {
+		resp.Nodes = append(resp.Nodes, sqlNode{
+			NodeID:     int32(nodeID),
+			SQLAddress: desc.CheckedSQLAddress().String(),
+			Locality:   desc.Locality,
+		})
+	}
+	if validUntil != hlc.MaxTimestamp {
+		resp.ValidUntil = validUntil.WallTime
+	}
+	sort.Slice(resp.Nodes, func(i, j int) bool {
+		return resp.Nodes[i].NodeID < resp.Nodes[j].NodeID
+	})
+
+	if format == "text" {
+		var b strings.Builder
+		for _, n := range resp.Nodes {
+			fmt.Fprintln(&b, n.SQLAddress)
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte(b.String()))
+		return
+	}
+	writeJSONResponse(ctx, w, http.StatusOK, resp)
+}
+
+func (a *apiV2Server) listSQLNodes(w http.ResponseWriter, r *http.Request) {
+	writeJSONResponse(r.Context(), w, http.StatusNotImplemented, nil)
+}
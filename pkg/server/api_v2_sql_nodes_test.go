@@ -0,0 +1,108 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/cockroach/pkg/testutils/serverutils"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLNodesV2(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	serverArgs := make(map[int]base.TestServerArgs)
+	for i, region := range []string{"r1", "r1", "r2"} {
+		serverArgs[i] = base.TestServerArgs{
+			Locality: roachpb.Locality{Tiers: []roachpb.Tier{{Key: "region", Value: region}}},
+		}
+	}
+	testCluster := serverutils.StartNewTestCluster(t, 3, base.TestClusterArgs{
+		ServerArgsPerNode: serverArgs,
+	})
+	ctx := context.Background()
+	defer testCluster.Stopper().Stop(ctx)
+
+	ts1 := testCluster.Server(0)
+	client, err := ts1.GetAdminHTTPClient()
+	require.NoError(t, err)
+
+	get := func(query string) (int, []byte) {
+		req, err := http.NewRequest("GET", ts1.AdminURL()+apiV2Path+"sql-nodes/"+query, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, body
+	}
+	list := func(query string) []int32 {
+		code, body := get(query)
+		require.Equal(t, http.StatusOK, code, "%s", body)
+		var resp sqlNodesResponse
+		require.NoError(t, json.Unmarshal(body, &resp))
+		var nodeIDs []int32
+		for _, n := range resp.Nodes {
+			require.Equal(t, testCluster.Server(int(n.NodeID-1)).ServingSQLAddr(), n.SQLAddress)
+			nodeIDs = append(nodeIDs, n.NodeID)
+		}
+		return nodeIDs
+	}
+
+	require.Equal(t, []int32{1, 2, 3}, list(""))
+	require.Equal(t, []int32{3}, list("?locality=region=r2"))
+
+	code, body := get("?format=text")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, []string{
+		testCluster.Server(0).ServingSQLAddr(),
+		testCluster.Server(1).ServingSQLAddr(),
+		testCluster.Server(2).ServingSQLAddr(),
+	}, strings.Fields(string(body)))
+
+	code, _ = get("?format=yaml")
+	require.Equal(t, http.StatusBadRequest, code)
+
+	// The list of nodes, and their addresses, is only disclosed to logged in
+	// users.
+	unauthenticated, err := ts1.GetUnauthenticatedHTTPClient()
+	require.NoError(t, err)
+	resp, err := unauthenticated.Get(ts1.AdminURL() + apiV2Path + "sql-nodes/")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// A draining node is no longer listed.
+	nl := testCluster.Server(1).NodeLiveness().(*liveness.NodeLiveness)
+	require.NoError(t, nl.SetDraining(ctx, true /* drain */, nil /* reporter */))
+	testutils.SucceedsSoon(t, func() error {
+		if nodeIDs := list(""); len(nodeIDs) != 2 {
+			return errors.Errorf("expected n2 to be excluded, got %v", nodeIDs)
+		}
+		return nil
+	})
+	require.Equal(t, []int32{1, 3}, list(""))
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/util/hlc"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/stop"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
 	"github.com/cockroachdb/errors"
 )
 
@@ -41,6 +42,21 @@ var (
 			"feature.",
 		0,
 	).WithPublic()
+
+	livenessRejoinMaxClockWait = settings.RegisterDurationSetting(
+		settings.SystemOnly,
+		"server.clock.liveness_rejoin_max_wait",
+		"the maximum amount of time a restarting node waits for its clock to catch up to the "+
+			"time of its last liveness heartbeat. A node whose clock is further behind than this "+
+			"refuses to start, since its clock was likely reset. Setting this to 0 disables the check.",
+		time.Minute,
+		settings.NonNegativeDuration,
+	)
+
+	// livenessRejoinReadTimeout bounds the time spent reading the previous
+	// liveness record on restart. If it can't be read in time, the check is
+	// skipped rather than holding up the start of the server.
+	livenessRejoinReadTimeout = 10 * time.Second
 )
 
 // startMonitoringForwardClockJumps starts a background task to monitor forward
@@ -148,6 +164,81 @@ func ensureClockMonotonicity(
 	}
 }
 
+// checkClockPastPreviousLiveness reads the liveness record this node wrote
+// before it was restarted and makes sure that the local clock is not behind
+// the time of the last heartbeat recorded in it. The HLC upper bound (if
+// enabled) and the max offset sleep in ensureClockMonotonicity protect the HLC
+// across restarts, but a clock that was reset backwards by more than that (for
+// example to a stale RTC value) would otherwise go unnoticed until it causes
+// liveness records and leases to be evaluated against the wrong time.
+//
+// If the clock is behind by less than server.clock.liveness_rejoin_max_wait,
+// this waits for it to catch up. Otherwise, an error is returned and the
+// server refuses to start.
+func (s *Server) checkClockPastPreviousLiveness(ctx context.Context) error {
+	maxWait := livenessRejoinMaxClockWait.Get(&s.st.SV)
+	if maxWait == 0 {
+		return nil
+	}
+	var lastHeartbeat hlc.Timestamp
+	if err := timeutil.RunWithTimeout(ctx, "read previous liveness record", livenessRejoinReadTimeout,
+		func(ctx context.Context) (err error) {
+			lastHeartbeat, err = s.nodeLiveness.LastHeartbeatFromKV(ctx, s.NodeID())
+			return err
+		}); err != nil {
+		log.Ops.Warningf(ctx, "unable to check clock against previous liveness record: %v", err)
+		return nil
+	}
+	return ensureClockPastPreviousLiveness(ctx, s.clock, lastHeartbeat, maxWait,
+		func(ctx context.Context, d time.Duration) error {
+			select {
+			case <-time.After(d):
+				return nil
+			case <-s.stopper.ShouldQuiesce():
+				return stop.ErrUnavailable
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+}
+
+// ensureClockPastPreviousLiveness waits until the physical clock has passed
+// lastHeartbeat, the time at which this node last heartbeat its liveness record
+// before being restarted. If the clock is behind by more than maxWait, an error
+// is returned instead. An empty lastHeartbeat (i.e. no previous record) is
+// always allowed.
+func ensureClockPastPreviousLiveness(
+	ctx context.Context,
+	clock *hlc.Clock,
+	lastHeartbeat hlc.Timestamp,
+	maxWait time.Duration,
+	sleepFn func(context.Context, time.Duration) error,
+) error {
+	if lastHeartbeat.IsEmpty() {
+		return nil
+	}
+	for {
+		behind := time.Duration(lastHeartbeat.WallTime - clock.PhysicalNow())
+		if behind <= 0 {
+			return nil
+		}
+		if behind > maxWait {
+			return errors.WithHintf(
+				errors.Newf("clock is %s behind the last liveness heartbeat of this node at %s; "+
+					"refusing to start", behind, lastHeartbeat.GoTime()),
+				"The clock was likely reset backwards. Check the clock synchronization "+
+					"(e.g. NTP) of this machine. Nodes whose clock is behind by less than %s "+
+					"(server.clock.liveness_rejoin_max_wait) wait for it to catch up instead.",
+				maxWait)
+		}
+		log.Ops.Warningf(ctx, "clock is %s behind the last liveness heartbeat of this node at %s; "+
+			"waiting for it to catch up", behind, lastHeartbeat.GoTime())
+		if err := sleepFn(ctx, behind); err != nil {
+			return err
+		}
+	}
+}
+
 // periodicallyPersistHLCUpperBound periodically persists an upper bound of
 // the HLC's wall time. The interval for persisting is read from
 // persistHLCUpperBoundIntervalCh. An interval of 0 disables persisting.
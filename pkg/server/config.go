@@ -30,6 +30,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/kv/kvclient/rangefeed"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvpb"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/rpc"
 	"github.com/cockroachdb/cockroach/pkg/server/autoconfig/acprovider"
@@ -372,6 +373,19 @@ type KVConfig struct {
 	// in zone configs.
 	Attrs string
 
+	// ColdNode, if set, runs the node in cold mode, meant for mostly idle nodes
+	// such as archival ones: the node heartbeats its liveness record
+	// kv.liveness.cold_node.ttl_multiplier times less often, with a
+	// proportionally longer TTL. This reduces the background load of the
+	// node, at the expense of its failure taking longer to be detected.
+	ColdNode bool
+
+	// ExternalFailureProbes report nodes that are definitively gone based on
+	// signals from outside the cluster, such as the instance termination
+	// notices of a cloud provider. Such nodes are considered dead as soon as
+	// their liveness expires. See liveness.ExternalProbe.
+	ExternalFailureProbes []liveness.ExternalProbe
+
 	// JoinList is a list of node addresses that is used to form a network of KV
 	// servers. Assuming a connected graph, it suffices to initialize any server
 	// in the network.
@@ -69,3 +69,37 @@ func TestCriticalNodes(t *testing.T) {
 		return nil
 	}, 2*time.Minute)
 }
+
+func TestQuorumCriticalNodes(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+	ctx := context.Background()
+	testCluster := serverutils.StartNewTestCluster(t, 3, base.TestClusterArgs{})
+	defer testCluster.Stopper().Stop(ctx)
+	require.NoError(t, testCluster.WaitForFullReplication())
+	s := testCluster.Server(0).StatusServer().(serverpb.StatusServer)
+
+	// With every node live, no single failure can cause a range to lose
+	// quorum.
+	res, err := s.QuorumCriticalNodes(ctx, &serverpb.QuorumCriticalNodesRequest{})
+	require.NoError(t, err)
+	require.Empty(t, res.CriticalNodes)
+	require.Zero(t, res.UnavailableRangeCount)
+
+	// Once a node is stopped, each of the two remaining nodes becomes critical
+	// for every range.
+	testCluster.StopServer(2)
+	testutils.SucceedsSoon(t, func() error {
+		res, err := s.QuorumCriticalNodes(ctx, &serverpb.QuorumCriticalNodesRequest{})
+		if err != nil {
+			return err
+		}
+		if len(res.CriticalNodes) != 2 {
+			return errors.Errorf("expected 2 critical nodes, found %+v", res.CriticalNodes)
+		}
+		for _, n := range res.CriticalNodes {
+			require.Positive(t, n.RangeCount)
+		}
+		return nil
+	})
+}
@@ -12,9 +12,13 @@ package server
 
 import (
 	"context"
+	"fmt"
+	"reflect"
 	"sort"
+	"strings"
 	"time"
 
+	"github.com/cockroachdb/cockroach/pkg/config/zonepb"
 	"github.com/cockroachdb/cockroach/pkg/keys"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvpb"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver"
@@ -23,19 +27,418 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
+	"github.com/cockroachdb/cockroach/pkg/server/status"
+	"github.com/cockroachdb/cockroach/pkg/server/status/statuspb"
+	"github.com/cockroachdb/cockroach/pkg/server/telemetry"
+	"github.com/cockroachdb/cockroach/pkg/settings"
 	"github.com/cockroachdb/cockroach/pkg/sql"
+	"github.com/cockroachdb/cockroach/pkg/sql/sem/tree"
+	"github.com/cockroachdb/cockroach/pkg/sql/sessiondata"
+	"github.com/cockroachdb/cockroach/pkg/util/humanizeutil"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/log/eventpb"
 	"github.com/cockroachdb/cockroach/pkg/util/log/logpb"
+	"github.com/cockroachdb/cockroach/pkg/util/protoutil"
 	"github.com/cockroachdb/cockroach/pkg/util/rangedesc"
+	"github.com/cockroachdb/cockroach/pkg/util/stop"
 	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
 	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/cockroach/pkg/util/tracing"
 	"github.com/cockroachdb/cockroach/pkg/util/tracing/tracingpb"
 	"github.com/cockroachdb/errors"
 	"google.golang.org/grpc/codes"
 	grpcstatus "google.golang.org/grpc/status"
 )
 
+// scheduledDecommissionCheckInterval is the interval at which each server
+// checks for nodes whose scheduled decommission is due.
+var scheduledDecommissionCheckInterval = settings.RegisterDurationSetting(
+	settings.SystemOnly,
+	"server.decommission.schedule_check_interval",
+	"the interval at which to check for nodes whose scheduled decommission is due",
+	time.Minute,
+	settings.PositiveDuration,
+)
+
+// minLiveNodesForDecommission is the minimum number of live, active nodes the
+// cluster must retain after a decommission.
+var minLiveNodesForDecommission = settings.RegisterIntSetting(
+	settings.SystemOnly,
+	"server.decommission.min_live_nodes",
+	"the minimum number of live nodes that are not decommissioning that the cluster "+
+		"must retain after a node is decommissioned; 0 disables the check",
+	0,
+	settings.NonNegativeInt,
+)
+
+// minLiveNodesPerRegionForDecommission is the minimum number of live, active
+// nodes each region must retain after a decommission.
+var minLiveNodesPerRegionForDecommission = settings.RegisterIntSetting(
+	settings.SystemOnly,
+	"server.decommission.min_live_nodes_per_region",
+	"the minimum number of live nodes that are not decommissioning that each region "+
+		"must retain after a node in that region is decommissioned; 0 disables the check",
+	0,
+	settings.NonNegativeInt,
+)
+
+// ErrMinLiveNodes is what the errors returned when a decommission would leave
+// the cluster, or one of its regions, with fewer live nodes than configured
+// are, as far as errors.Is is concerned.
+var ErrMinLiveNodes = errors.New("decommission would leave too few live nodes")
+
+// minLiveNodesError is returned when a decommission would leave the cluster, or
+// one of its regions, with fewer live nodes than configured. It is a gRPC
+// FailedPrecondition error, and is ErrMinLiveNodes.
+type minLiveNodesError struct {
+	// setting is the name of the cluster setting configuring the violated floor.
+	setting string
+	// region is the region whose floor would be violated, if the violated floor
+	// is a per-region one.
+	region    string
+	floor     int64
+	remaining int64
+}
+
+func (e *minLiveNodesError) Error() string {
+	if e.region != "" {
+		return fmt.Sprintf("decommission would leave region %q with %d live nodes, below the "+
+			"minimum of %d configured by %s", e.region, e.remaining, e.floor, e.setting)
+	}
+	return fmt.Sprintf("decommission would leave the cluster with %d live nodes, below the "+
+		"minimum of %d configured by %s", e.remaining, e.floor, e.setting)
+}
+
+// Is implements errors.Is.
+func (e *minLiveNodesError) Is(target error) bool {
+	return target == ErrMinLiveNodes
+}
+
+// GRPCStatus returns the gRPC status of the error.
+func (e *minLiveNodesError) GRPCStatus() *grpcstatus.Status {
+	return grpcstatus.New(codes.FailedPrecondition, e.Error())
+}
+
+// checkMinLiveNodes returns a *minLiveNodesError if decommissioning the given
+// nodes would leave the cluster, or any of the regions of those nodes, with
+// fewer live, active nodes than configured. Decommissioning nodes that don't
+// count towards the floor (e.g. dead ones) is always allowed.
+func (s *Server) checkMinLiveNodes(nodeIDs []roachpb.NodeID) error {
+	minGlobal := minLiveNodesForDecommission.Get(&s.st.SV)
+	minPerRegion := minLiveNodesPerRegionForDecommission.Get(&s.st.SV)
+	if minGlobal == 0 && minPerRegion == 0 {
+		return nil
+	}
+
+	targets := make(map[roachpb.NodeID]struct{}, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		targets[nodeID] = struct{}{}
+	}
+	regionOf := func(nodeID roachpb.NodeID) string {
+		desc, err := s.gossip.GetNodeDescriptor(nodeID)
+		if err != nil {
+			return ""
+		}
+		region, _ := desc.Locality.Find("region")
+		return region
+	}
+
+	var remaining int64
+	remainingPerRegion := make(map[string]int64)
+	affectedRegions := make(map[string]struct{})
+	affected := false
+	for nodeID, entry := range s.nodeLiveness.GetIsLiveMap() {
+		if !entry.IsLive || !entry.Membership.Active() {
+			continue
+		}
+		region := regionOf(nodeID)
+		if _, ok := targets[nodeID]; ok {
+			affected = true
+			if region != "" {
+				affectedRegions[region] = struct{}{}
+			}
+			continue
+		}
+		remaining++
+		if region != "" {
+			remainingPerRegion[region]++
+		}
+	}
+
+	if affected && remaining < minGlobal {
+		return &minLiveNodesError{
+			setting:   minLiveNodesForDecommission.Key(),
+			floor:     minGlobal,
+			remaining: remaining,
+		}
+	}
+	regions := make([]string, 0, len(affectedRegions))
+	for region := range affectedRegions {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	for _, region := range regions {
+		if remainingPerRegion[region] < minPerRegion {
+			return &minLiveNodesError{
+				setting:   minLiveNodesPerRegionForDecommission.Key(),
+				region:    region,
+				floor:     minPerRegion,
+				remaining: remainingPerRegion[region],
+			}
+		}
+	}
+	return nil
+}
+
+// underReplicatedZone describes a zone whose replication factor could no
+// longer be satisfied after a decommission.
+type underReplicatedZone struct {
+	target      string
+	numReplicas int32
+}
+
+// underReplicationError is returned when a decommission would leave fewer
+// eligible nodes than needed to place the replicas of one or more zones.
+type underReplicationError struct {
+	// remaining is the number of live nodes that are not decommissioning that
+	// would remain after the decommission.
+	remaining int64
+	zones     []underReplicatedZone
+}
+
+func (e *underReplicationError) Error() string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "decommission would leave %d eligible nodes, fewer than needed "+
+		"to place the replicas of the following zones:", e.remaining)
+	for _, z := range e.zones {
+		fmt.Fprintf(&buf, "\n  %s (num_replicas = %d)", z.target, z.numReplicas)
+	}
+	buf.WriteString("\nset allow_under_replication (--allow-under-replication on the " +
+		"command line) to decommission anyway")
+	return buf.String()
+}
+
+// enforceUnderReplicationCheck controls whether decommissions that would
+// under-replicate a zone are refused, or merely logged.
+var enforceUnderReplicationCheck = settings.RegisterBoolSetting(
+	settings.SystemOnly,
+	"server.decommission.under_replication_check.enabled",
+	"if set, decommissions that would leave fewer eligible nodes than needed to "+
+		"place the replicas of some zone are refused, unless under-replication is "+
+		"explicitly allowed; otherwise, they are only logged",
+	false,
+)
+
+// checkReplicationFactor returns an *underReplicationError if decommissioning
+// the given nodes would leave fewer live, active nodes than the number of
+// voters the allocator needs to place for any zone, and fewer than there
+// currently are. In other words, it reports the zones whose replicas on the
+// given nodes would have nowhere to go.
+//
+// The number of voters needed is the one the allocator targets given the
+// remaining nodes, which can be lower than the replication factor of the zone
+// (e.g. 5-way replicated zones are down-replicated to 3 voters on 4 nodes).
+// Zones that inherit their replication factor are not listed, since the zone
+// they inherit it from is.
+func (s *Server) checkReplicationFactor(ctx context.Context, nodeIDs []roachpb.NodeID) error {
+	targets := make(map[roachpb.NodeID]struct{}, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		targets[nodeID] = struct{}{}
+	}
+	var current, remaining int64
+	for nodeID, entry := range s.nodeLiveness.GetIsLiveMap() {
+		if !entry.IsLive || !entry.Membership.Active() {
+			continue
+		}
+		current++
+		if _, ok := targets[nodeID]; !ok {
+			remaining++
+		}
+	}
+	if remaining == current {
+		return nil
+	}
+
+	rows, err := s.sqlServer.internalExecutor.QueryBufferedEx(
+		ctx, "decommission-replication-factor", nil, /* txn */
+		sessiondata.NodeUserSessionDataOverride,
+		`SELECT target, raw_config_protobuf FROM crdb_internal.zones WHERE target IS NOT NULL`,
+	)
+	if err != nil {
+		return err
+	}
+	var zones []underReplicatedZone
+	for _, row := range rows {
+		var zone zonepb.ZoneConfig
+		if err := protoutil.Unmarshal([]byte(tree.MustBeDBytes(row[1])), &zone); err != nil {
+			return err
+		}
+		if zone.NumReplicas == nil {
+			continue
+		}
+		if need := allocatorimpl.GetNeededVoters(*zone.NumReplicas, int(remaining)); remaining < int64(need) {
+			zones = append(zones, underReplicatedZone{
+				target:      string(tree.MustBeDString(row[0])),
+				numReplicas: *zone.NumReplicas,
+			})
+		}
+	}
+	if len(zones) == 0 {
+		return nil
+	}
+	sort.Slice(zones, func(i, j int) bool {
+		return zones[i].target < zones[j].target
+	})
+	return &underReplicationError{remaining: remaining, zones: zones}
+}
+
+// enforceReplicationFactor returns the *underReplicationError reported by
+// checkReplicationFactor for the given nodes if
+// server.decommission.under_replication_check.enabled is set. Otherwise, the
+// check is advisory: the error is logged, and nil is returned.
+func (s *Server) enforceReplicationFactor(ctx context.Context, nodeIDs []roachpb.NodeID) error {
+	err := s.checkReplicationFactor(ctx, nodeIDs)
+	var urErr *underReplicationError
+	if !errors.As(err, &urErr) || enforceUnderReplicationCheck.Get(&s.st.SV) {
+		return err
+	}
+	log.Ops.Warningf(ctx, "decommissioning %v: %v", nodeIDs, urErr)
+	return nil
+}
+
+// maxDiskUtilizationAfterDecommission is the maximum disk utilization the
+// remaining stores may be projected to reach after absorbing the data of the
+// decommissioned nodes.
+var maxDiskUtilizationAfterDecommission = settings.RegisterFloatSetting(
+	settings.SystemOnly,
+	"server.decommission.max_disk_utilization",
+	"the maximum disk utilization the remaining stores may reach, on average, once they "+
+		"have taken over the data of the decommissioned nodes; a decommission pre-check "+
+		"reports a shortfall above it",
+	0.85,
+	settings.NonNegativeFloatWithMaximum(1),
+)
+
+// maxReplicasPerStoreAfterDecommission is the maximum number of replicas the
+// remaining stores may be projected to hold after absorbing the replicas of
+// the decommissioned nodes.
+var maxReplicasPerStoreAfterDecommission = settings.RegisterIntSetting(
+	settings.SystemOnly,
+	"server.decommission.max_replicas_per_store",
+	"the maximum number of replicas the remaining stores may hold, on average, once they "+
+		"have taken over the replicas of the decommissioned nodes; a decommission pre-check "+
+		"reports a shortfall above it; 0 disables the check",
+	100000,
+	settings.NonNegativeInt,
+)
+
+// remainingNodes returns the live, active nodes that would remain after
+// decommissioning the given nodes.
+func (s *Server) remainingNodes(nodeIDs []roachpb.NodeID) map[roachpb.NodeID]struct{} {
+	targets := make(map[roachpb.NodeID]struct{}, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		targets[nodeID] = struct{}{}
+	}
+	remaining := make(map[roachpb.NodeID]struct{})
+	for nodeID, entry := range s.nodeLiveness.GetIsLiveMap() {
+		if !entry.IsLive || !entry.Membership.Active() {
+			continue
+		}
+		if _, ok := targets[nodeID]; !ok {
+			remaining[nodeID] = struct{}{}
+		}
+	}
+	return remaining
+}
+
+// checkRemainingVersions returns a description of each remaining node that
+// runs a binary older than the active cluster version, as recorded in its
+// liveness record. Such a node is only tolerated while other nodes are around
+// to hold the data it can't, and removing those nodes puts the cluster in a
+// state that upgrades (and the version invariants they rely on) don't
+// account for. Nodes that haven't recorded their binary version yet, e.g.
+// because they run a binary that predates the field, are skipped rather than
+// reported, since nothing is known of their version.
+func (s *Server) checkRemainingVersions(
+	ctx context.Context, remaining map[roachpb.NodeID]struct{},
+) []string {
+	activeVersion := s.st.Version.ActiveVersion(ctx).Version
+	var shortfalls []string
+	for nodeID := range remaining {
+		rec, ok := s.nodeLiveness.GetLiveness(nodeID)
+		if !ok || rec.BinaryVersion == (roachpb.Version{}) ||
+			!rec.BinaryVersion.Less(activeVersion) {
+			continue
+		}
+		shortfalls = append(shortfalls, fmt.Sprintf(
+			"n%d runs binary version %s, older than the cluster version %s",
+			nodeID, rec.BinaryVersion, activeVersion))
+	}
+	sort.Strings(shortfalls)
+	return shortfalls
+}
+
+// checkRemainingCapacity returns a description of each way in which the
+// stores of the remaining nodes, as known to the given store pool, lack the
+// spare capacity to absorb the data and replicas of the stores of the
+// decommissioned nodes.
+func checkRemainingCapacity(
+	sv *settings.Values,
+	sp storepool.AllocatorStorePool,
+	nodeIDs []roachpb.NodeID,
+	remaining map[roachpb.NodeID]struct{},
+) []string {
+	targets := make(map[roachpb.NodeID]struct{}, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		targets[nodeID] = struct{}{}
+	}
+	var moved, remainingCap roachpb.StoreCapacity
+	var remainingStores int64
+	for _, desc := range sp.GetStores() {
+		c := desc.Capacity
+		if _, ok := targets[desc.Node.NodeID]; ok {
+			moved.Used += c.Used
+			moved.RangeCount += c.RangeCount
+			continue
+		}
+		if _, ok := remaining[desc.Node.NodeID]; ok {
+			remainingCap.Capacity += c.Capacity
+			remainingCap.Available += c.Available
+			remainingCap.RangeCount += c.RangeCount
+			remainingStores++
+		}
+	}
+	if remainingStores == 0 {
+		return []string{"no live nodes would remain to take over the data"}
+	}
+
+	var shortfalls []string
+	if remainingCap.Capacity > 0 {
+		maxUtilization := maxDiskUtilizationAfterDecommission.Get(sv)
+		utilization := float64(remainingCap.Capacity-remainingCap.Available+moved.Used) /
+			float64(remainingCap.Capacity)
+		if utilization > maxUtilization {
+			shortfalls = append(shortfalls, fmt.Sprintf(
+				"the remaining stores would reach a disk utilization of %.0f%% after taking "+
+					"over %s, above the maximum of %.0f%% configured by %s",
+				utilization*100, humanizeutil.IBytes(moved.Used), maxUtilization*100,
+				maxDiskUtilizationAfterDecommission.Key()))
+		}
+	}
+	if maxReplicas := maxReplicasPerStoreAfterDecommission.Get(sv); maxReplicas > 0 {
+		replicasPerStore := int64(remainingCap.RangeCount+moved.RangeCount) / remainingStores
+		if replicasPerStore > maxReplicas {
+			shortfalls = append(shortfalls, fmt.Sprintf(
+				"the remaining stores would hold %d replicas each after taking over %d replicas, "+
+					"above the maximum of %d configured by %s",
+				replicasPerStore, moved.RangeCount, maxReplicas,
+				maxReplicasPerStoreAfterDecommission.Key()))
+		}
+	}
+	return shortfalls
+}
+
 // decommissioningNodeMap tracks the set of nodes that we know are
 // decommissioning. This map is used to inform whether we need to proactively
 // enqueue some decommissioning node's ranges for rebalancing.
@@ -61,6 +464,10 @@ type decommissionPreCheckResult struct {
 	replicasByNode map[roachpb.NodeID][]roachpb.ReplicaIdent
 	actionCounts   map[string]int
 	rangesNotReady []decommissionRangeCheckResult
+	// shortfalls describes the ways in which the nodes that would remain after
+	// the decommission can't take over from the checked nodes, whether because
+	// of the binary versions they run or their spare capacity.
+	shortfalls []string
 }
 
 // makeOnNodeDecommissioningCallback returns a callback that enqueues the
@@ -155,6 +562,8 @@ func getPingCheckDecommissionFn(
 // prior to starting the Decommission(..) process. This is evaluated by checking
 // that any replicas on the given nodes are able to be replaced or removed,
 // following the current state of the cluster as well as the configuration.
+// It also checks that the nodes that would remain run recent enough binaries,
+// and have the spare capacity to take over from the given nodes.
 // If strictReadiness is true, all replicas are expected to need only replace
 // or remove actions. If maxErrors >0, range checks will stop once maxError is
 // reached.
@@ -277,11 +686,20 @@ func (s *Server) DecommissionPreCheck(
 		return decommissionPreCheckResult{}, grpcstatus.Errorf(codes.Internal, err.Error())
 	}
 
+	var shortfalls []string
+	if len(nodeIDs) > 0 {
+		remaining := s.remainingNodes(nodeIDs)
+		shortfalls = append(shortfalls, s.checkRemainingVersions(ctx, remaining)...)
+		shortfalls = append(shortfalls,
+			checkRemainingCapacity(&s.st.SV, existingStorePool, nodeIDs, remaining)...)
+	}
+
 	return decommissionPreCheckResult{
 		rangesChecked:  rangesChecked,
 		replicasByNode: replicasByNode,
 		actionCounts:   actionCounts,
 		rangesNotReady: rangeErrors,
+		shortfalls:     shortfalls,
 	}, nil
 }
 
@@ -335,6 +753,43 @@ func evaluateRangeCheckResult(
 func (s *Server) Decommission(
 	ctx context.Context, targetStatus livenesspb.MembershipStatus, nodeIDs []roachpb.NodeID,
 ) error {
+	return s.decommissionWithReason(ctx, targetStatus, nodeIDs, "" /* reason */)
+}
+
+// decommissionWithReason is like Decommission, but additionally records the
+// given operator-supplied reason in the liveness records of the target nodes
+// and in the emitted events.
+func (s *Server) decommissionWithReason(
+	ctx context.Context,
+	targetStatus livenesspb.MembershipStatus,
+	nodeIDs []roachpb.NodeID,
+	reason string,
+) error {
+	_, err := s.decommissionWithToken(ctx, targetStatus, nodeIDs, reason, "" /* token */)
+	return err
+}
+
+// decommissionWithToken is like decommissionWithReason, but additionally
+// makes the membership change of each node at most once for the given
+// client-supplied idempotency token: nodes the change was already made to
+// with the token are skipped, and no event is emitted for them. Returns whether
+// the membership status of any of the nodes changed, which clients polling for
+// the progress of a decommission don't do past their first call.
+func (s *Server) decommissionWithToken(
+	ctx context.Context,
+	targetStatus livenesspb.MembershipStatus,
+	nodeIDs []roachpb.NodeID,
+	reason string,
+	token string,
+) (changed bool, retErr error) {
+	defer func() { recordMembershipChangeFailureTelemetry(retErr) }()
+
+	if targetStatus.Decommissioning() {
+		if err := s.checkMinLiveNodes(nodeIDs); err != nil {
+			return false, err
+		}
+	}
+
 	// If we're asked to decommission ourself we may lose access to cluster RPC,
 	// so we decommission ourself last. We copy the slice to avoid mutating the
 	// input slice.
@@ -366,17 +821,36 @@ func (s *Server) Decommission(
 	}
 	event.CommonDetails().Timestamp = timeutil.Now().UnixNano()
 	nodeDetails.RequestingNodeID = int32(s.NodeID())
+	nodeDetails.Reason = reason
 
 	for _, nodeID := range nodeIDs {
-		statusChanged, err := s.nodeLiveness.SetMembershipStatus(ctx, nodeID, targetStatus)
+		opCtx, sp := ctx, (*tracing.Span)(nil)
+		if targetStatus.Decommissioning() {
+			// The span is recorded in the liveness record of the node, and
+			// the replica movements off the node as well as the final write
+			// marking it decommissioned are traced as its children. It needs
+			// to be a real span for them to have something to refer to.
+			opCtx, sp = tracing.EnsureChildSpan(ctx, s.cfg.AmbientCtx.Tracer,
+				fmt.Sprintf("decommission n%d", nodeID), tracing.WithForceRealSpan())
+		}
+		statusChanged, err := s.nodeLiveness.SetMembershipStatusWithToken(opCtx, nodeID, targetStatus, reason, token)
+		sp.Finish()
 		if err != nil {
 			if errors.Is(err, liveness.ErrMissingRecord) {
-				return grpcstatus.Error(codes.NotFound, liveness.ErrMissingRecord.Error())
+				return changed, grpcstatus.Error(codes.NotFound, liveness.ErrMissingRecord.Error())
+			}
+			var conflictErr *liveness.MembershipChangeConflictError
+			if errors.As(err, &conflictErr) {
+				return changed, grpcstatus.Error(codes.FailedPrecondition, conflictErr.Error())
 			}
 			log.Errorf(ctx, "%+s", err)
-			return grpcstatus.Errorf(codes.Internal, err.Error())
+			return changed, grpcstatus.Errorf(codes.Internal, err.Error())
 		}
 		if statusChanged {
+			if !changed {
+				recordMembershipChangeTelemetry(targetStatus)
+			}
+			changed = true
 			nodeDetails.TargetNodeID = int32(nodeID)
 			// Ensure an entry is produced in the external log in all cases.
 			log.StructuredEvent(ctx, event)
@@ -395,19 +869,49 @@ func (s *Server) Decommission(
 			)
 		}
 
-		// Similarly to the log event above, we may not be able to clean up the
+		// Similarly to the log event above, we may not be able to archive the
 		// status entry if we crash or fail -- the status entry is inline, and
-		// thus cannot be transactional. However, since decommissioning is
-		// idempotent, we can attempt to remove the key regardless of whether
-		// the status changed, such that a stale key can be removed by
-		// decommissioning the node again.
+		// thus cannot be transactional. However, since decommissioning and
+		// archival are both idempotent, we can attempt to archive the entry
+		// regardless of whether the status changed, such that a stale entry
+		// can be archived by decommissioning the node again. Archiving rather
+		// than removing the entry preserves the node's final status for
+		// dashboards, while marking its absence as expected.
 		if targetStatus.Decommissioned() {
-			if err := s.db.PutInline(ctx, keys.NodeStatusKey(nodeID), nil); err != nil {
-				log.Errorf(ctx, "unable to clean up node status data for node %d: %s", nodeID, err)
+			nodeStatus, err := status.ArchiveNodeStatus(ctx, s.db, nodeID, timeutil.Now())
+			if err != nil {
+				log.Errorf(ctx, "unable to archive node status data for node %d: %s", nodeID, err)
+			} else {
+				s.pruneDecommissionedNodeSeries(nodeID, nodeStatus)
 			}
 		}
 	}
-	return nil
+	return changed, nil
+}
+
+// pruneDecommissionedNodeSeries deletes, in the background, the time series
+// recorded by the given decommissioned node and its stores, as listed in its
+// archived status record. The node is usually shut down by the time it is
+// decommissioned, so this is done by the node driving the decommission rather
+// than left to the node itself. Its status record is kept as the archived
+// record of the node.
+func (s *Server) pruneDecommissionedNodeSeries(
+	nodeID roachpb.NodeID, nodeStatus statuspb.NodeStatus,
+) {
+	series := status.TimeSeriesSources(nodeStatus)
+	if len(series) == 0 {
+		return
+	}
+	ctx := s.AnnotateCtx(context.Background())
+	if err := s.stopper.RunAsyncTask(ctx, "prune-decommissioned-node-series", func(ctx context.Context) {
+		ctx, cancel := s.stopper.WithCancelOnQuiesce(ctx)
+		defer cancel()
+		if err := s.tsDB.PruneSourceSeries(ctx, s.db, series, timeutil.Now().UnixNano()); err != nil {
+			log.Warningf(ctx, "unable to prune time series of decommissioned n%d: %v", nodeID, err)
+		}
+	}); err != nil {
+		log.Warningf(ctx, "unable to prune time series of decommissioned n%d: %v", nodeID, err)
+	}
 }
 
 // DecommissioningNodeMap returns the set of node IDs that are decommissioning
@@ -421,3 +925,116 @@ func (s *Server) DecommissioningNodeMap() map[roachpb.NodeID]interface{} {
 	}
 	return nodes
 }
+
+// startScheduledDecommissionLoop starts a task that periodically checks for
+// nodes whose scheduled decommission is due, and starts decommissioning them.
+// Every server runs this loop; the membership change is idempotent, so only one
+// of them ends up carrying it out (and emitting the corresponding event).
+func (s *Server) startScheduledDecommissionLoop(ctx context.Context) error {
+	return s.stopper.RunAsyncTaskEx(ctx,
+		stop.TaskOpts{TaskName: "scheduled-decommission", SpanOpt: stop.SterileRootSpan},
+		func(ctx context.Context) {
+			ctx, cancel := s.stopper.WithCancelOnQuiesce(ctx)
+			defer cancel()
+
+			var timer timeutil.Timer
+			defer timer.Stop()
+			for {
+				timer.Reset(scheduledDecommissionCheckInterval.Get(&s.st.SV))
+				select {
+				case <-timer.C:
+					timer.Read = true
+					s.runScheduledDecommissions(ctx)
+				case <-ctx.Done():
+					return
+				}
+			}
+		})
+}
+
+// runScheduledDecommissions starts decommissioning the nodes whose scheduled
+// decommission is due.
+func (s *Server) runScheduledDecommissions(ctx context.Context) {
+	now := s.clock.Now()
+	for nodeID, entry := range s.nodeLiveness.GetIsLiveMap() {
+		if !entry.DecommissionDue(now) {
+			continue
+		}
+		log.Ops.Infof(ctx, "starting scheduled decommission of n%d (scheduled at %s)",
+			nodeID, entry.DecommissionAt)
+		if err := s.enforceReplicationFactor(ctx, []roachpb.NodeID{nodeID}); err != nil {
+			log.Ops.Warningf(ctx, "unable to start scheduled decommission of n%d: %v", nodeID, err)
+			telemetry.Inc(telemetryDecommissionFailedUnderReplication)
+			continue
+		}
+		telemetry.Inc(telemetryDecommissionScheduled)
+		if err := s.decommissionWithReason(ctx,
+			livenesspb.MembershipStatus_DECOMMISSIONING, []roachpb.NodeID{nodeID}, entry.Reason,
+		); err != nil {
+			log.Ops.Warningf(ctx, "unable to start scheduled decommission of n%d: %v", nodeID, err)
+		}
+	}
+}
+
+// waitForDecommissionedPollInterval is the interval at which
+// WaitForDecommissioned checks the decommission status of the nodes it waits
+// for.
+const waitForDecommissionedPollInterval = time.Second
+
+// WaitForDecommissioned waits for the given nodes to be fully decommissioned,
+// streaming their decommission status as it changes. See
+// serverpb.AdminServer.WaitForDecommissioned.
+func (s *systemAdminServer) WaitForDecommissioned(
+	req *serverpb.WaitForDecommissionedRequest, stream serverpb.Admin_WaitForDecommissionedServer,
+) error {
+	ctx := s.AnnotateCtx(stream.Context())
+	if len(req.NodeIDs) == 0 {
+		return grpcstatus.Errorf(codes.InvalidArgument, "no node ID specified")
+	}
+	ctx, cancel := s.server.stopper.WithCancelOnQuiesce(ctx)
+	defer cancel()
+	var deadline <-chan time.Time
+	if req.Timeout > 0 {
+		t := timeutil.NewTimer()
+		defer t.Stop()
+		t.Reset(req.Timeout)
+		deadline = t.C
+	}
+
+	var prev []serverpb.DecommissionStatusResponse_Status
+	var timer timeutil.Timer
+	defer timer.Stop()
+	for first := true; ; first = false {
+		res, err := s.decommissionStatusHelper(ctx, &serverpb.DecommissionStatusRequest{NodeIDs: req.NodeIDs})
+		if err != nil {
+			return serverError(ctx, err)
+		}
+		done := true
+		for _, status := range res.Status {
+			done = done && status.Membership.Decommissioned()
+		}
+		if first || done || !reflect.DeepEqual(prev, res.Status) {
+			if err := stream.Send(&serverpb.WaitForDecommissionedResponse{
+				Status: res.Status,
+				Done:   done,
+			}); err != nil {
+				return err
+			}
+		}
+		if done {
+			return nil
+		}
+		prev = res.Status
+
+		timer.Reset(waitForDecommissionedPollInterval)
+		select {
+		case <-timer.C:
+			timer.Read = true
+		case <-deadline:
+			return grpcstatus.Errorf(codes.DeadlineExceeded,
+				"nodes not decommissioned after %s", req.Timeout)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
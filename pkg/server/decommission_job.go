@@ -0,0 +1,314 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/jobs"
+	"github.com/cockroachdb/cockroach/pkg/jobs/jobspb"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/security/username"
+	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
+	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
+	"github.com/cockroachdb/cockroach/pkg/sql"
+	"github.com/cockroachdb/cockroach/pkg/sql/isql"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/errors"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+// decommissionJobPollInterval is the interval at which decommission jobs check
+// how many replicas are left on the nodes they decommission.
+const decommissionJobPollInterval = 5 * time.Second
+
+// validateDecommissionJob checks that the given nodes can be marked as
+// decommissioning, before a job is created to do so. The job repeats the
+// checks, which may no longer pass by the time it runs.
+func (s *Server) validateDecommissionJob(nodeIDs []roachpb.NodeID) error {
+	for _, nodeID := range nodeIDs {
+		l, ok := s.nodeLiveness.GetLiveness(nodeID)
+		if !ok {
+			return grpcstatus.Errorf(codes.NotFound, "n%d not found", nodeID)
+		}
+		if _, err := livenesspb.ValidateTransition(l.Liveness, livenesspb.MembershipStatus_DECOMMISSIONING); err != nil {
+			return err
+		}
+	}
+	if err := s.checkMinLiveNodes(nodeIDs); err != nil {
+		return err
+	}
+	return nil
+}
+
+// createDecommissionJob creates a job that carries out the decommission of the
+// given nodes. See decommissionResumer.
+func (s *systemAdminServer) createDecommissionJob(
+	ctx context.Context,
+	nodeIDs []roachpb.NodeID,
+	reason string,
+	allowUnderReplication bool,
+	user username.SQLUsername,
+) (jobspb.JobID, error) {
+	nodes := make([]string, len(nodeIDs))
+	for i, nodeID := range nodeIDs {
+		nodes[i] = nodeID.String()
+	}
+	record := jobs.Record{
+		Description: fmt.Sprintf("decommission of nodes %s", strings.Join(nodes, ", ")),
+		Username:    user,
+		Details: jobspb.DecommissionDetails{
+			NodeIDs:               nodeIDs,
+			Reason:                reason,
+			AllowUnderReplication: allowUnderReplication,
+		},
+		Progress: jobspb.DecommissionProgress{},
+	}
+	registry := s.sqlServer.execCfg.JobRegistry
+	jobID := registry.MakeJobID()
+	if err := s.sqlServer.execCfg.InternalDB.Txn(ctx, func(ctx context.Context, txn isql.Txn) error {
+		_, err := registry.CreateAdoptableJobWithTxn(ctx, record, jobID, txn)
+		return err
+	}); err != nil {
+		return jobspb.InvalidJobID, err
+	}
+	registry.NotifyToResume(ctx, jobID)
+	return jobID, nil
+}
+
+// decommissionResumer carries out the decommission of nodes: it marks the
+// nodes as decommissioning, waits for all the replicas to be moved off of
+// them, and marks them as decommissioned. Its progress is the fraction of the
+// replicas that were moved.
+//
+// Since the replicas are moved off of the nodes as long as they are marked as
+// decommissioning, pausing the job recommissions the nodes, and resuming it
+// marks them as decommissioning again. Canceling the job recommissions the
+// nodes that aren't decommissioned yet.
+//
+// Unlike 'cockroach node decommission', the job doesn't drain the nodes before
+// marking them as decommissioned.
+type decommissionResumer struct {
+	job *jobs.Job
+}
+
+var _ jobs.Resumer = (*decommissionResumer)(nil)
+var _ jobs.PauseRequester = (*decommissionResumer)(nil)
+
+func (r *decommissionResumer) decommissioner(execCtx interface{}) (serverpb.NodeDecommissioner, error) {
+	d := execCtx.(sql.JobExecContext).ExecCfg().NodeDecommissioner
+	if d == nil {
+		return nil, jobs.MarkAsPermanentJobError(
+			errors.New("nodes can only be decommissioned by the system tenant"))
+	}
+	return d, nil
+}
+
+// isPermanentDecommissionError returns whether the given error of a membership
+// change is due to the state of the nodes, rather than to a transient failure.
+func isPermanentDecommissionError(err error) bool {
+	switch grpcstatus.Code(err) {
+	case codes.InvalidArgument, codes.NotFound, codes.FailedPrecondition:
+		return true
+	}
+	return false
+}
+
+// Resume is part of the jobs.Resumer interface.
+func (r *decommissionResumer) Resume(ctx context.Context, execCtx interface{}) error {
+	d, err := r.decommissioner(execCtx)
+	if err != nil {
+		return err
+	}
+	details := r.job.Details().(jobspb.DecommissionDetails)
+
+	if !r.job.Progress().GetDecommission().Marked {
+		if _, err := d.Decommission(ctx, &serverpb.DecommissionRequest{
+			NodeIDs:               details.NodeIDs,
+			TargetMembership:      livenesspb.MembershipStatus_DECOMMISSIONING,
+			Reason:                details.Reason,
+			AllowUnderReplication: details.AllowUnderReplication,
+			IdempotencyToken:      r.idempotencyToken(),
+		}); err != nil {
+			if isPermanentDecommissionError(err) {
+				return err
+			}
+			return jobs.MarkAsRetryJobError(err)
+		}
+		if err := r.setMarked(ctx); err != nil {
+			return err
+		}
+	}
+	if err := execCtx.(sql.JobExecContext).ExecCfg().JobRegistry.CheckPausepoint(
+		"decommission.after_mark"); err != nil {
+		return err
+	}
+
+	var timer timeutil.Timer
+	defer timer.Stop()
+	for {
+		res, err := d.DecommissionStatus(ctx, &serverpb.DecommissionStatusRequest{NodeIDs: details.NodeIDs})
+		if err != nil {
+			return jobs.MarkAsRetryJobError(err)
+		}
+		var remaining int64
+		for _, status := range res.Status {
+			remaining += status.ReplicaCount
+		}
+		// The progress update fails if the job was paused in the meantime, in
+		// which case the nodes were recommissioned by the pause.
+		if err := r.updateProgress(ctx, remaining); err != nil {
+			return err
+		}
+		for _, status := range res.Status {
+			if status.Membership.Active() {
+				return errors.Newf("n%d was recommissioned", status.NodeID)
+			}
+		}
+		if remaining == 0 {
+			break
+		}
+
+		timer.Reset(decommissionJobPollInterval)
+		select {
+		case <-timer.C:
+			timer.Read = true
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if _, err := d.Decommission(ctx, &serverpb.DecommissionRequest{
+		NodeIDs:          details.NodeIDs,
+		TargetMembership: livenesspb.MembershipStatus_DECOMMISSIONED,
+		Reason:           details.Reason,
+		IdempotencyToken: r.idempotencyToken(),
+	}); err != nil {
+		return jobs.MarkAsRetryJobError(err)
+	}
+	return nil
+}
+
+// setMarked records that the job marked the nodes as decommissioning.
+func (r *decommissionResumer) setMarked(ctx context.Context) error {
+	return r.job.NoTxn().Update(ctx, func(
+		_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater,
+	) error {
+		if err := md.CheckRunningOrReverting(); err != nil {
+			return err
+		}
+		md.Progress.GetDecommission().Marked = true
+		ju.UpdateProgress(md.Progress)
+		return nil
+	})
+}
+
+// updateProgress records the number of replicas left on the nodes.
+func (r *decommissionResumer) updateProgress(ctx context.Context, remaining int64) error {
+	return r.job.NoTxn().Update(ctx, func(
+		_ isql.Txn, md jobs.JobMetadata, ju *jobs.JobUpdater,
+	) error {
+		if err := md.CheckRunningOrReverting(); err != nil {
+			return err
+		}
+		progress := md.Progress.GetDecommission()
+		if progress.InitialReplicaCount < remaining {
+			progress.InitialReplicaCount = remaining
+		}
+		progress.RemainingReplicaCount = remaining
+		fractionCompleted := float32(1)
+		if progress.InitialReplicaCount > 0 {
+			fractionCompleted = 1 - float32(remaining)/float32(progress.InitialReplicaCount)
+		}
+		md.Progress.Progress = &jobspb.Progress_FractionCompleted{
+			FractionCompleted: fractionCompleted,
+		}
+		md.Progress.RunningStatus = fmt.Sprintf("%d replicas left to move", remaining)
+		ju.UpdateProgress(md.Progress)
+		return nil
+	})
+}
+
+// idempotencyToken returns the idempotency token of the membership changes
+// made by the job, which keeps a retry of the job from emitting the
+// corresponding events again.
+func (r *decommissionResumer) idempotencyToken() string {
+	return fmt.Sprintf("decommission-job-%d", r.job.ID())
+}
+
+// recommission recommissions the nodes of the job that are decommissioning.
+func (r *decommissionResumer) recommission(
+	ctx context.Context, d serverpb.NodeDecommissioner, why string,
+) error {
+	details := r.job.Details().(jobspb.DecommissionDetails)
+	res, err := d.DecommissionStatus(ctx, &serverpb.DecommissionStatusRequest{NodeIDs: details.NodeIDs})
+	if err != nil {
+		return err
+	}
+	var nodeIDs []roachpb.NodeID
+	for _, status := range res.Status {
+		if status.Membership.Decommissioning() {
+			nodeIDs = append(nodeIDs, status.NodeID)
+		}
+	}
+	if len(nodeIDs) == 0 {
+		return nil
+	}
+	log.Ops.Infof(ctx, "recommissioning nodes %v %s", nodeIDs, why)
+	_, err = d.Decommission(ctx, &serverpb.DecommissionRequest{
+		NodeIDs:          nodeIDs,
+		TargetMembership: livenesspb.MembershipStatus_ACTIVE,
+		Reason:           details.Reason,
+		IdempotencyToken: r.idempotencyToken(),
+	})
+	return err
+}
+
+// OnPauseRequest is part of the jobs.PauseRequester interface.
+func (r *decommissionResumer) OnPauseRequest(
+	ctx context.Context, execCtx interface{}, _ isql.Txn, progress *jobspb.Progress,
+) error {
+	d, err := r.decommissioner(execCtx)
+	if err != nil {
+		return err
+	}
+	if err := r.recommission(ctx, d, "while the decommission job is paused"); err != nil {
+		return err
+	}
+	progress.GetDecommission().Marked = false
+	return nil
+}
+
+// OnFailOrCancel is part of the jobs.Resumer interface.
+func (r *decommissionResumer) OnFailOrCancel(
+	ctx context.Context, execCtx interface{}, jobErr error,
+) error {
+	d, err := r.decommissioner(execCtx)
+	if err != nil {
+		return err
+	}
+	return r.recommission(ctx, d, fmt.Sprintf("after the decommission job failed: %v", jobErr))
+}
+
+func init() {
+	jobs.RegisterConstructor(jobspb.TypeDecommission,
+		func(job *jobs.Job, _ *cluster.Settings) jobs.Resumer {
+			return &decommissionResumer{job: job}
+		},
+		jobs.DisablesTenantCostControl,
+	)
+}
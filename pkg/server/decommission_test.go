@@ -13,17 +13,23 @@ package server
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/base"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/allocator"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/allocator/allocatorimpl"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/rpc"
 	"github.com/cockroachdb/cockroach/pkg/security/username"
+	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
 	"github.com/cockroachdb/cockroach/pkg/testutils"
 	"github.com/cockroachdb/cockroach/pkg/testutils/keysutils"
 	"github.com/cockroachdb/cockroach/pkg/testutils/serverutils"
 	"github.com/cockroachdb/cockroach/pkg/testutils/skip"
+	"github.com/cockroachdb/cockroach/pkg/testutils/sqlutils"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
 	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/errors"
@@ -318,3 +324,429 @@ func waitForSpanConfig(
 		return nil
 	})
 }
+
+// TestDecommissionWithReason verifies that the operator-supplied reason for a
+// membership change is recorded in the target node's liveness record.
+func TestDecommissionWithReason(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := serverutils.StartNewTestCluster(t, 3, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	firstSvr := tc.Server(0).(*TestServer)
+	targetID := tc.Server(2).NodeID()
+
+	checkReason := func(expMembership livenesspb.MembershipStatus, expReason string) {
+		livenesses, err := firstSvr.nodeLiveness.GetLivenessesFromKV(ctx)
+		require.NoError(t, err)
+		for _, l := range livenesses {
+			if l.NodeID == targetID {
+				require.Equal(t, expMembership, l.Membership)
+				require.Equal(t, expReason, l.Reason)
+				return
+			}
+		}
+		t.Fatalf("liveness record for n%d not found", targetID)
+	}
+
+	require.NoError(t, firstSvr.decommissionWithReason(ctx,
+		livenesspb.MembershipStatus_DECOMMISSIONING, []roachpb.NodeID{targetID}, "TICKET-123"))
+	checkReason(livenesspb.MembershipStatus_DECOMMISSIONING, "TICKET-123")
+
+	// Recommissioning without a reason clears the previous one.
+	require.NoError(t, firstSvr.Decommission(ctx,
+		livenesspb.MembershipStatus_ACTIVE, []roachpb.NodeID{targetID}))
+	checkReason(livenesspb.MembershipStatus_ACTIVE, "")
+}
+
+// TestDecommissionWithToken verifies that a membership change made with an
+// idempotency token isn't applied again when retried with the same token.
+func TestDecommissionWithToken(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := serverutils.StartNewTestCluster(t, 3, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	firstSvr := tc.Server(0).(*TestServer)
+	targetID := tc.Server(2).NodeID()
+
+	checkMembership := func(exp livenesspb.MembershipStatus) {
+		livenesses, err := firstSvr.nodeLiveness.GetLivenessesFromKV(ctx)
+		require.NoError(t, err)
+		for _, l := range livenesses {
+			if l.NodeID == targetID {
+				require.Equal(t, exp, l.Membership)
+				return
+			}
+		}
+		t.Fatalf("liveness record for n%d not found", targetID)
+	}
+	decommission := func(token string) {
+		_, err := firstSvr.decommissionWithToken(ctx,
+			livenesspb.MembershipStatus_DECOMMISSIONING, []roachpb.NodeID{targetID}, "" /* reason */, token)
+		require.NoError(t, err)
+	}
+
+	decommission("token-1")
+	checkMembership(livenesspb.MembershipStatus_DECOMMISSIONING)
+	require.NoError(t, firstSvr.Decommission(ctx,
+		livenesspb.MembershipStatus_ACTIVE, []roachpb.NodeID{targetID}))
+	checkMembership(livenesspb.MembershipStatus_ACTIVE)
+
+	// A retry with the same token doesn't undo the recommission.
+	decommission("token-1")
+	checkMembership(livenesspb.MembershipStatus_ACTIVE)
+
+	// A new operation does go through.
+	decommission("token-2")
+	checkMembership(livenesspb.MembershipStatus_DECOMMISSIONING)
+}
+
+// TestWaitForDecommissioned verifies that WaitForDecommissioned streams the
+// decommission status of the nodes until they are decommissioned, or the
+// timeout passes.
+func TestWaitForDecommissioned(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := serverutils.StartNewTestCluster(t, 3, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	firstSvr := tc.Server(0).(*TestServer)
+	targetIDs := []roachpb.NodeID{tc.Server(2).NodeID()}
+	conn, err := firstSvr.RPCContext().GRPCDialNode(
+		firstSvr.RPCAddr(), firstSvr.NodeID(), rpc.DefaultClass).Connect(ctx)
+	require.NoError(t, err)
+	client := serverpb.NewAdminClient(conn)
+
+	// The wait times out while the node is active.
+	stream, err := client.WaitForDecommissioned(ctx, &serverpb.WaitForDecommissionedRequest{
+		NodeIDs: targetIDs,
+		Timeout: time.Millisecond,
+	})
+	require.NoError(t, err)
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	require.False(t, resp.Done)
+	require.Len(t, resp.Status, 1)
+	require.Equal(t, livenesspb.MembershipStatus_ACTIVE, resp.Status[0].Membership)
+	_, err = stream.Recv()
+	require.Equal(t, codes.DeadlineExceeded, grpcstatus.Code(err), "%v", err)
+
+	// The wait completes once the node is decommissioned.
+	stream, err = client.WaitForDecommissioned(ctx, &serverpb.WaitForDecommissionedRequest{
+		NodeIDs: targetIDs,
+	})
+	require.NoError(t, err)
+	resp, err = stream.Recv()
+	require.NoError(t, err)
+	require.False(t, resp.Done)
+	for _, target := range []livenesspb.MembershipStatus{
+		livenesspb.MembershipStatus_DECOMMISSIONING,
+		livenesspb.MembershipStatus_DECOMMISSIONED,
+	} {
+		require.NoError(t, firstSvr.Decommission(ctx, target, targetIDs))
+	}
+	for !resp.Done {
+		resp, err = stream.Recv()
+		require.NoError(t, err)
+	}
+	require.Equal(t, livenesspb.MembershipStatus_DECOMMISSIONED, resp.Status[0].Membership)
+}
+
+// TestDecommissionJob verifies that a decommission handed over to a job is
+// carried out by the job.
+func TestDecommissionJob(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := serverutils.StartNewTestCluster(t, 3, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	firstSvr := tc.Server(0).(*TestServer)
+	targetID := tc.Server(2).NodeID()
+
+	_, err := firstSvr.admin.Decommission(ctx, &serverpb.DecommissionRequest{
+		NodeIDs:          []roachpb.NodeID{targetID},
+		TargetMembership: livenesspb.MembershipStatus_DECOMMISSIONED,
+		AsJob:            true,
+	})
+	require.Equal(t, codes.InvalidArgument, grpcstatus.Code(err), "%v", err)
+
+	res, err := firstSvr.admin.Decommission(ctx, &serverpb.DecommissionRequest{
+		NodeIDs:               []roachpb.NodeID{targetID},
+		TargetMembership:      livenesspb.MembershipStatus_DECOMMISSIONING,
+		AllowUnderReplication: true,
+		Reason:                "TICKET-1",
+		AsJob:                 true,
+	})
+	require.NoError(t, err)
+	require.NotZero(t, res.JobID)
+
+	// With manual replication, the node has no replicas, so the job marks it as
+	// decommissioned right away.
+	sqlDB := sqlutils.MakeSQLRunner(tc.ServerConn(0))
+	testutils.SucceedsSoon(t, func() error {
+		var jobType, status string
+		sqlDB.QueryRow(t, `SELECT job_type, status FROM [SHOW JOBS] WHERE job_id = $1`,
+			res.JobID).Scan(&jobType, &status)
+		require.Equal(t, "DECOMMISSION", jobType)
+		if status != "succeeded" {
+			return errors.Errorf("job is %s", status)
+		}
+		return nil
+	})
+	livenesses, err := firstSvr.nodeLiveness.GetLivenessesFromKV(ctx)
+	require.NoError(t, err)
+	for _, l := range livenesses {
+		if l.NodeID == targetID {
+			require.Equal(t, livenesspb.MembershipStatus_DECOMMISSIONED, l.Membership)
+			require.Equal(t, "TICKET-1", l.Reason)
+		}
+	}
+}
+
+// TestDecommissionJobPause verifies that pausing a decommission job
+// recommissions the nodes, so that replicas stop being moved off of them, and
+// that resuming it marks them as decommissioning again.
+func TestDecommissionJobPause(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := serverutils.StartNewTestCluster(t, 3, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	firstSvr := tc.Server(0).(*TestServer)
+	targetID := tc.Server(2).NodeID()
+	sqlDB := sqlutils.MakeSQLRunner(tc.ServerConn(0))
+	sqlDB.Exec(t, `SET CLUSTER SETTING jobs.debug.pausepoints = 'decommission.after_mark'`)
+
+	res, err := firstSvr.admin.Decommission(ctx, &serverpb.DecommissionRequest{
+		NodeIDs:               []roachpb.NodeID{targetID},
+		TargetMembership:      livenesspb.MembershipStatus_DECOMMISSIONING,
+		AllowUnderReplication: true,
+		AsJob:                 true,
+	})
+	require.NoError(t, err)
+
+	waitForJob := func(exp string) {
+		testutils.SucceedsSoon(t, func() error {
+			var status string
+			sqlDB.QueryRow(t, `SELECT status FROM [SHOW JOBS] WHERE job_id = $1`,
+				res.JobID).Scan(&status)
+			if status != exp {
+				return errors.Errorf("job is %s", status)
+			}
+			return nil
+		})
+	}
+	checkMembership := func(exp livenesspb.MembershipStatus) {
+		livenesses, err := firstSvr.nodeLiveness.GetLivenessesFromKV(ctx)
+		require.NoError(t, err)
+		for _, l := range livenesses {
+			if l.NodeID == targetID {
+				require.Equal(t, exp, l.Membership)
+				return
+			}
+		}
+		t.Fatalf("liveness record for n%d not found", targetID)
+	}
+
+	// The job pauses itself once it marked the node as decommissioning, which
+	// recommissions the node.
+	waitForJob("paused")
+	checkMembership(livenesspb.MembershipStatus_ACTIVE)
+
+	sqlDB.Exec(t, `SET CLUSTER SETTING jobs.debug.pausepoints = ''`)
+	sqlDB.Exec(t, `RESUME JOB $1`, res.JobID)
+	waitForJob("succeeded")
+	checkMembership(livenesspb.MembershipStatus_DECOMMISSIONED)
+}
+
+// TestScheduledDecommission verifies that a node whose decommission was
+// scheduled starts decommissioning once the scheduled time has passed.
+func TestScheduledDecommission(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := serverutils.StartNewTestCluster(t, 3, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	firstSvr := tc.Server(0).(*TestServer)
+	scheduledDecommissionCheckInterval.Override(ctx, &firstSvr.ClusterSettings().SV, 10*time.Millisecond)
+	targetID := tc.Server(2).NodeID()
+
+	getLiveness := func() livenesspb.Liveness {
+		livenesses, err := firstSvr.nodeLiveness.GetLivenessesFromKV(ctx)
+		require.NoError(t, err)
+		for _, l := range livenesses {
+			if l.NodeID == targetID {
+				return l
+			}
+		}
+		t.Fatalf("liveness record for n%d not found", targetID)
+		return livenesspb.Liveness{}
+	}
+
+	// A decommission scheduled far in the future doesn't start, and can be
+	// canceled.
+	at := firstSvr.Clock().Now().AddDuration(time.Hour)
+	require.NoError(t, firstSvr.nodeLiveness.ScheduleDecommission(ctx, targetID, at, ""))
+	require.Equal(t, at, getLiveness().DecommissionAt)
+	require.NoError(t, firstSvr.nodeLiveness.ScheduleDecommission(ctx, targetID, hlc.Timestamp{}, ""))
+	require.True(t, getLiveness().DecommissionAt.IsEmpty())
+
+	// A decommission that is due is carried out.
+	at = firstSvr.Clock().Now()
+	require.NoError(t, firstSvr.nodeLiveness.ScheduleDecommission(ctx, targetID, at, "TICKET-456"))
+	testutils.SucceedsSoon(t, func() error {
+		if l := getLiveness(); !l.Membership.Decommissioning() {
+			return errors.Errorf("n%d has membership %s", targetID, l.Membership)
+		}
+		return nil
+	})
+	l := getLiveness()
+	require.True(t, l.DecommissionAt.IsEmpty())
+	require.Equal(t, "TICKET-456", l.Reason)
+}
+
+// TestDecommissionMinLiveNodes verifies that a decommission which would leave
+// the cluster with fewer live nodes than configured is refused.
+func TestDecommissionMinLiveNodes(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := serverutils.StartNewTestCluster(t, 3, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	firstSvr := tc.Server(0).(*TestServer)
+	sv := &firstSvr.ClusterSettings().SV
+	targetID := tc.Server(2).NodeID()
+	decommission := func() error {
+		return firstSvr.Decommission(ctx,
+			livenesspb.MembershipStatus_DECOMMISSIONING, []roachpb.NodeID{targetID})
+	}
+
+	minLiveNodesForDecommission.Override(ctx, sv, 3)
+	err := decommission()
+	require.Error(t, err)
+	s, ok := grpcstatus.FromError(err)
+	require.True(t, ok)
+	require.Equal(t, codes.FailedPrecondition, s.Code())
+	require.Contains(t, s.Message(), "server.decommission.min_live_nodes")
+	require.True(t, errors.Is(err, ErrMinLiveNodes), "unexpected error: %v", err)
+
+	minLiveNodesForDecommission.Override(ctx, sv, 2)
+	require.NoError(t, decommission())
+
+	// Decommissioning an already decommissioning node doesn't count against the
+	// floor again.
+	minLiveNodesForDecommission.Override(ctx, sv, 3)
+	require.NoError(t, decommission())
+}
+
+// TestDecommissionReplicationFactorCheck verifies that a decommission leaving
+// fewer eligible nodes than needed to place the replicas of a zone is
+// reported, and only refused when the check is enforced.
+func TestDecommissionReplicationFactorCheck(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := serverutils.StartNewTestCluster(t, 3, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	firstSvr := tc.Server(0).(*TestServer)
+	targetIDs := []roachpb.NodeID{tc.Server(2).NodeID()}
+
+	err := firstSvr.checkReplicationFactor(ctx, targetIDs)
+	var urErr *underReplicationError
+	require.True(t, errors.As(err, &urErr), "unexpected error: %v", err)
+	require.EqualValues(t, 2, urErr.remaining)
+	var targets []string
+	for _, z := range urErr.zones {
+		targets = append(targets, z.target)
+	}
+	require.Contains(t, targets, "RANGE default")
+
+	// The check is only enforced when enabled, unless under-replication is
+	// explicitly allowed.
+	conn, err := firstSvr.RPCContext().GRPCDialNode(
+		firstSvr.RPCAddr(), firstSvr.NodeID(), rpc.DefaultClass).Connect(ctx)
+	require.NoError(t, err)
+	adminClient := serverpb.NewAdminClient(conn)
+	req := &serverpb.DecommissionRequest{
+		NodeIDs:          targetIDs,
+		TargetMembership: livenesspb.MembershipStatus_DECOMMISSIONING,
+	}
+	enforceUnderReplicationCheck.Override(ctx, &firstSvr.ClusterSettings().SV, true)
+	_, err = adminClient.Decommission(ctx, req)
+	require.Equal(t, codes.FailedPrecondition, grpcstatus.Code(err), "unexpected error: %v", err)
+	enforceUnderReplicationCheck.Override(ctx, &firstSvr.ClusterSettings().SV, false)
+
+	// Once the node is decommissioning, it no longer counts as eligible, so
+	// re-issuing the decommission isn't refused.
+	_, err = adminClient.Decommission(ctx, req)
+	require.NoError(t, err)
+	testutils.SucceedsSoon(t, func() error {
+		return firstSvr.checkReplicationFactor(ctx, targetIDs)
+	})
+}
+
+// TestDecommissionPreCheckShortfalls verifies that the decommission pre-check
+// reports the remaining nodes lacking the capacity to take over from the
+// checked nodes.
+func TestDecommissionPreCheckShortfalls(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := serverutils.StartNewTestCluster(t, 4, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	firstSvr := tc.Server(0).(*TestServer)
+	sv := &firstSvr.ClusterSettings().SV
+	targetIDs := []roachpb.NodeID{tc.Server(3).NodeID()}
+
+	// All nodes run the same binary, and have plenty of capacity to spare.
+	results, err := firstSvr.DecommissionPreCheck(ctx, targetIDs,
+		false /* strictReadiness */, false /* collectTraces */, 0 /* maxErrors */)
+	require.NoError(t, err)
+	require.Empty(t, results.shortfalls)
+
+	maxReplicasPerStoreAfterDecommission.Override(ctx, sv, 1)
+	maxDiskUtilizationAfterDecommission.Override(ctx, sv, 0)
+	results, err = firstSvr.DecommissionPreCheck(ctx, targetIDs,
+		false /* strictReadiness */, false /* collectTraces */, 0 /* maxErrors */)
+	require.NoError(t, err)
+	require.Len(t, results.shortfalls, 2)
+	require.Contains(t, results.shortfalls[0], maxDiskUtilizationAfterDecommission.Key())
+	require.Contains(t, results.shortfalls[1], maxReplicasPerStoreAfterDecommission.Key())
+}
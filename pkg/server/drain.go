@@ -18,11 +18,14 @@ import (
 	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
+	"github.com/cockroachdb/cockroach/pkg/server/telemetry"
 	"github.com/cockroachdb/cockroach/pkg/settings"
 	"github.com/cockroachdb/cockroach/pkg/sql/sqlstats/persistedsqlstats"
 	"github.com/cockroachdb/cockroach/pkg/util/grpcutil"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/stop"
 	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
@@ -57,6 +60,18 @@ var (
 		settings.NonNegativeDurationWithMaximum(10*time.Hour),
 	).WithPublic()
 
+	readinessLeadTime = settings.RegisterDurationSetting(
+		settings.TenantWritable,
+		"server.shutdown.readiness_lead_time",
+		"the amount of time ahead of a drain at which a server starts failing readiness "+
+			"probes (/health?ready=1), so that load balancers stop routing new connections to "+
+			"it in time; this applies to maintenance windows and scheduled decommissions "+
+			"declared in the node's liveness record, and a drain waits at least this long "+
+			"in an unready state before closing connections",
+		0*time.Second,
+		settings.NonNegativeDurationWithMaximum(10*time.Hour),
+	)
+
 	connectionWait = settings.RegisterDurationSetting(
 		settings.TenantWritable,
 		"server.shutdown.connection_wait",
@@ -68,6 +83,16 @@ var (
 		settings.NonNegativeDurationWithMaximum(10*time.Hour),
 	).WithPublic()
 
+	plannedRestartMaxDuration = settings.RegisterDurationSetting(
+		settings.SystemOnly,
+		"server.shutdown.planned_restart.max_duration",
+		"the maximum duration a node drained with --planned-restart can be declared to be "+
+			"back within; longer durations are capped, so that a node that doesn't come back "+
+			"doesn't hold off the replacement of its replicas for too long",
+		time.Hour,
+		settings.NonNegativeDurationWithMaximum(24*time.Hour),
+	)
+
 	jobRegistryWait = settings.RegisterDurationSetting(
 		settings.TenantWritable,
 		"server.shutdown.jobs_wait",
@@ -156,12 +181,38 @@ func (s *drainServer) handleDrain(
 	ctx context.Context, req *serverpb.DrainRequest, stream serverpb.Admin_DrainServer,
 ) error {
 	log.Ops.Infof(ctx, "drain request received with doDrain = %v, shutdown = %v", req.DoDrain, req.Shutdown)
+	if req.Reason != "" {
+		log.Ops.Infof(ctx, "drain reason: %s", req.Reason)
+	}
+
+	if req.Undrain {
+		if req.DoDrain || req.Shutdown {
+			return status.Errorf(codes.InvalidArgument, "cannot undrain and drain or shut down at once")
+		}
+		telemetry.Inc(telemetryUndrain)
+		if err := s.undrain(ctx); err != nil {
+			log.Ops.Errorf(ctx, "undrain failed: %v", err)
+			telemetry.Inc(telemetryUndrainFailed)
+			return err
+		}
+		return stream.Send(&serverpb.DrainResponse{IsDraining: s.isDraining()})
+	}
 
 	res := serverpb.DrainResponse{}
 	if req.DoDrain {
-		remaining, info, err := s.runDrain(ctx, req.Verbose)
+		telemetry.Inc(telemetryDrain)
+		res.DrainID = s.recordDrainRequest(ctx, req.Reason)
+		if err := s.recordDrainIntent(ctx, req.Sticky, req.PlannedRestart); err != nil {
+			log.Ops.Errorf(ctx, "drain failed: %v", err)
+			telemetry.Inc(telemetryDrainFailed)
+			s.recordDrainOutcome(ctx, 0 /* remaining */, "" /* description */, err)
+			return err
+		}
+		remaining, info, err := s.runDrain(ctx, req.Verbose, req.Reason)
+		s.recordDrainOutcome(ctx, remaining, info.StripMarkers(), err)
 		if err != nil {
 			log.Ops.Errorf(ctx, "drain failed: %v", err)
+			telemetry.Inc(telemetryDrainFailed)
 			return err
 		}
 		res.DrainRemainingIndicator = remaining
@@ -190,6 +241,11 @@ func (s *drainServer) maybeShutdownAfterDrain(
 		return nil
 	}
 
+	telemetry.Inc(telemetryDrainShutdown)
+	if s.isDraining() {
+		s.markDeparting(ctx)
+	}
+
 	go func() {
 		// TODO(tbg): why don't we stop the stopper first? Stopping the stopper
 		// first seems more reasonable since grpc.Stop closes the listener right
@@ -281,8 +337,10 @@ func delegateDrain(
 //
 // The reporter function, if non-nil, is called for each
 // packet of load shed away from the server during the drain.
+//
+// The reason, if non-empty, is recorded in the node's liveness record.
 func (s *drainServer) runDrain(
-	ctx context.Context, verbose bool,
+	ctx context.Context, verbose bool, reason string,
 ) (remaining uint64, info redact.RedactableString, err error) {
 	reports := make(map[redact.SafeString]int)
 	var mu syncutil.Mutex
@@ -309,7 +367,7 @@ func (s *drainServer) runDrain(
 		}
 	}()
 
-	if err = s.drainInner(ctx, reporter, verbose); err != nil {
+	if err = s.drainInner(ctx, reporter, verbose, reason); err != nil {
 		return 0, "", err
 	}
 
@@ -317,7 +375,7 @@ func (s *drainServer) runDrain(
 }
 
 func (s *drainServer) drainInner(
-	ctx context.Context, reporter func(int, redact.SafeString), verbose bool,
+	ctx context.Context, reporter func(int, redact.SafeString), verbose bool, reason string,
 ) (err error) {
 	if s.serverCtl != nil {
 		// We are on a KV node, with a server controller.
@@ -347,7 +405,7 @@ func (s *drainServer) drainInner(
 	log.Infof(ctx, "done draining clients")
 
 	// Mark the node as draining in liveness and drain all range leases.
-	return s.drainNode(ctx, reporter, verbose)
+	return s.drainNode(ctx, reporter, verbose, reason)
 }
 
 // isDraining returns true if either SQL client connections are being drained
@@ -384,7 +442,11 @@ func (s *drainServer) drainClients(
 	if shouldDelayDraining {
 		log.Ops.Info(ctx, "waiting for health probes to notice that the node "+
 			"is not ready for new sql connections")
-		s.drainSleepFn(drainWait.Get(&s.sqlServer.execCfg.Settings.SV))
+		wait := drainWait.Get(&s.sqlServer.execCfg.Settings.SV)
+		if leadTime := readinessLeadTime.Get(&s.sqlServer.execCfg.Settings.SV); leadTime > wait {
+			wait = leadTime
+		}
+		s.drainSleepFn(wait)
 	}
 
 	// Wait for users to close the existing SQL connections.
@@ -470,7 +532,7 @@ func (s *drainServer) drainClients(
 // drainNode initiates the draining mode for the node, which
 // starts draining range leases.
 func (s *drainServer) drainNode(
-	ctx context.Context, reporter func(int, redact.SafeString), verbose bool,
+	ctx context.Context, reporter func(int, redact.SafeString), verbose bool, reason string,
 ) (err error) {
 	if s.kvServer.node == nil {
 		// No KV subsystem. Nothing to do.
@@ -478,13 +540,183 @@ func (s *drainServer) drainNode(
 	}
 
 	// Set the node's liveness status to "draining".
-	if err = s.kvServer.nodeLiveness.SetDraining(ctx, true /* drain */, reporter); err != nil {
+	if err = s.kvServer.nodeLiveness.SetDrainingWithReason(ctx, true /* drain */, reporter, reason); err != nil {
 		return err
 	}
 	// Mark the stores of the node as "draining" and drain all range leases.
 	return s.kvServer.node.SetDraining(true /* drain */, reporter, verbose)
 }
 
+// recordDrainIntent records in the node's liveness record, in a single update,
+// whether the node stays draining across restarts and, if plannedRestart is
+// positive, that the node is going down for a planned restart and is expected
+// back within that duration, capped by
+// server.shutdown.planned_restart.max_duration.
+func (s *drainServer) recordDrainIntent(
+	ctx context.Context, sticky bool, plannedRestart time.Duration,
+) error {
+	maxDuration := plannedRestartMaxDuration.Get(&s.sqlServer.execCfg.Settings.SV)
+	if plannedRestart > maxDuration {
+		log.Ops.Warningf(ctx, "planned restart of %s capped to %s", plannedRestart, maxDuration)
+		plannedRestart = maxDuration
+	}
+	if s.kvServer.node == nil || (!sticky && plannedRestart <= 0) {
+		// No KV subsystem, or nothing to record.
+		return nil
+	}
+	var update liveness.FieldUpdate
+	if sticky {
+		update.StickyDrain = &sticky
+	}
+	if plannedRestart > 0 {
+		until := s.kvServer.node.storeCfg.Clock.Now().AddDuration(plannedRestart)
+		log.Ops.Infof(ctx, "planned restart: node expected back by %s", until)
+		update.PlannedRestartUntil = &until
+	}
+	_, err := s.kvServer.nodeLiveness.UpdateFields(ctx, s.kvServer.node.Descriptor.NodeID, update)
+	return err
+}
+
+// recordDrainRequest records a drain request in the node's record of its last
+// drain (see livenesspb.Liveness.LastDrain), which starts a new drain unless
+// one is running already, and returns the ID of the drain. This is best
+// effort: failing to do so only leaves the drain unrecorded.
+func (s *drainServer) recordDrainRequest(ctx context.Context, reason string) int64 {
+	if s.kvServer.node == nil {
+		// No KV subsystem. Nothing to do.
+		return 0
+	}
+	drain, err := s.kvServer.nodeLiveness.UpdateLastDrain(ctx,
+		func(d *livenesspb.DrainOperation, now hlc.Timestamp) {
+			d.StartRequest(reason, now)
+		})
+	if err != nil {
+		log.Ops.Warningf(ctx, "unable to record drain request: %v", err)
+		return 0
+	}
+	log.Ops.Infof(ctx, "drain request %d of drain %d", drain.Requests, drain.ID)
+	return drain.ID
+}
+
+// recordDrainOutcome records the outcome of the last drain request in the
+// node's record of its last drain. Like recordDrainRequest, it is best effort.
+func (s *drainServer) recordDrainOutcome(
+	ctx context.Context, remaining uint64, description string, drainErr error,
+) {
+	s.updateLastDrain(ctx, func(d *livenesspb.DrainOperation, now hlc.Timestamp) {
+		d.FinishRequest(remaining, description, drainErr, now)
+	})
+}
+
+// endLastDrain ends the node's last drain with the given status, if it is
+// still running. Like recordDrainRequest, it is best effort.
+func (s *drainServer) endLastDrain(ctx context.Context, status livenesspb.DrainOperation_Status) {
+	s.updateLastDrain(ctx, func(d *livenesspb.DrainOperation, now hlc.Timestamp) {
+		if d.Running() {
+			log.Ops.Infof(ctx, "drain %d is %s", d.ID, status)
+		}
+		d.End(status, now)
+	})
+}
+
+func (s *drainServer) updateLastDrain(
+	ctx context.Context, update func(d *livenesspb.DrainOperation, now hlc.Timestamp),
+) {
+	if s.kvServer.node == nil {
+		// No KV subsystem. Nothing to do.
+		return
+	}
+	if _, err := s.kvServer.nodeLiveness.UpdateLastDrain(ctx, update); err != nil {
+		log.Ops.Warningf(ctx, "unable to record drain: %v", err)
+	}
+}
+
+// markDeparting announces through the node's liveness record that the node,
+// done draining, is about to stop, so that other nodes treat it as
+// unavailable right away. This is best effort: failing to do so merely means
+// that the other nodes find out once the node's liveness record expires.
+func (s *drainServer) markDeparting(ctx context.Context) {
+	if s.kvServer.node == nil {
+		// No KV subsystem. Nothing to do.
+		return
+	}
+	if err := s.kvServer.nodeLiveness.SetDeparting(ctx); err != nil {
+		log.Ops.Warningf(ctx, "unable to announce departure: %v", err)
+		return
+	}
+	log.Ops.Infof(ctx, "announced departure to the cluster")
+}
+
+// setStickyDrain records in the node's liveness record whether the node
+// stays draining across restarts.
+func (s *drainServer) setStickyDrain(ctx context.Context, sticky bool) error {
+	if s.kvServer.node == nil {
+		// No KV subsystem. Nothing to do.
+		return nil
+	}
+	return s.kvServer.nodeLiveness.SetStickyDrain(ctx, s.kvServer.node.Descriptor.NodeID, sticky)
+}
+
+// resumeStickyDrain puts a node that was drained with a sticky drain before it
+// restarted back into the draining state: it fails readiness probes, refuses
+// new SQL connections, and its stores refuse leases. It returns whether the
+// node is draining. It is called at startup, before the node accepts SQL
+// clients.
+func (s *drainServer) resumeStickyDrain(ctx context.Context) (bool, error) {
+	if s.kvServer.node == nil {
+		return false, nil
+	}
+	self, ok := s.kvServer.nodeLiveness.Self()
+	if !ok || !self.StickyDrain {
+		return false, nil
+	}
+	log.Ops.Infof(ctx, "node was drained with a sticky drain before restarting; "+
+		"it remains draining until undrained")
+	s.grpc.setMode(modeDraining)
+	s.sqlServer.isReady.Set(false)
+	if err := s.sqlServer.pgServer.WaitForSQLConnsToClose(ctx, 0 /* connectionWait */, s.stopper); err != nil {
+		return false, err
+	}
+	if err := s.drainNode(ctx, nil /* reporter */, false /* verbose */, "" /* reason */); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// undrain clears a sticky drain and brings the node back into rotation. The
+// SQL layer can only be brought back if it wasn't fully drained, which is the
+// case of a node that restarted after a sticky drain; other nodes need to be
+// restarted, which they then do undrained. The sticky drain is only cleared
+// once the node is undrained, or bound to be by its restart, so that an undrain
+// that fails can be retried.
+func (s *drainServer) undrain(ctx context.Context) error {
+	log.Ops.Infof(ctx, "undrain request received")
+	if s.sqlServer.gracefulDrainComplete.Get() {
+		if err := s.setStickyDrain(ctx, false /* sticky */); err != nil {
+			return err
+		}
+		return status.Errorf(codes.FailedPrecondition,
+			"the SQL layer of the node was fully drained; restart the node to undrain it")
+	}
+	if s.kvServer.node != nil {
+		if err := s.kvServer.nodeLiveness.SetDraining(ctx, false /* drain */, nil /* reporter */); err != nil {
+			return err
+		}
+		if err := s.kvServer.node.SetDraining(false /* drain */, nil /* reporter */, false /* verbose */); err != nil {
+			return err
+		}
+	}
+	if err := s.setStickyDrain(ctx, false /* sticky */); err != nil {
+		return err
+	}
+	s.endLastDrain(ctx, livenesspb.DrainOperation_CANCELED)
+	s.sqlServer.pgServer.Undrain()
+	s.grpc.setMode(modeOperational)
+	s.sqlServer.isReady.Set(true)
+	log.Ops.Infof(ctx, "node undrained")
+	return nil
+}
+
 // logOpenConns logs the number of open SQL connections every 3 seconds.
 func (s *drainServer) logOpenConns(ctx context.Context) error {
 	return s.stopper.RunAsyncTask(ctx, "log-open-conns", func(ctx context.Context) {
@@ -17,6 +17,7 @@ import (
 	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
 	"github.com/cockroachdb/cockroach/pkg/server"
 	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
 	"github.com/cockroachdb/cockroach/pkg/sql"
@@ -59,6 +60,8 @@ func doTestDrain(tt *testing.T) {
 	t.assertDraining(resp, true)
 	t.assertRemaining(resp, true)
 	t.assertEqual(1, drainSleepCallCount)
+	drainID := resp.DrainID
+	require.NotZero(t, drainID)
 
 	// Issue another probe. This checks that the server is still running
 	// (i.e. Shutdown: false was effective), the draining status is
@@ -85,6 +88,14 @@ func doTestDrain(tt *testing.T) {
 	})
 	t.assertEqual(1, drainSleepCallCount)
 
+	// All the requests are part of the same drain, which is recorded as
+	// successful in the node's liveness record.
+	require.Equal(t, drainID, resp.DrainID)
+	drain := t.lastDrain()
+	require.Equal(t, drainID, drain.ID)
+	require.Equal(t, livenesspb.DrainOperation_SUCCEEDED, drain.Status)
+	require.Greater(t, drain.Requests, int32(1))
+
 	// Now issue a drain request without drain but with shutdown.
 	// We're expecting the node to be shut down after that.
 	resp = t.sendShutdown()
@@ -252,6 +263,22 @@ func (t *testDrainContext) sendShutdown() *serverpb.DrainResponse {
 	return resp
 }
 
+// lastDrain returns the record of the last drain of the first node.
+func (t *testDrainContext) lastDrain() livenesspb.DrainOperation {
+	t.Helper()
+	resp, err := t.c.Liveness(context.Background(), &serverpb.LivenessRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range resp.Livenesses {
+		if l.NodeID == t.tc.Server(0).NodeID() {
+			return l.LastDrain
+		}
+	}
+	t.Fatalf("no liveness record for n%d", t.tc.Server(0).NodeID())
+	return livenesspb.DrainOperation{}
+}
+
 func (t *testDrainContext) assertDraining(resp *serverpb.DrainResponse, drain bool) {
 	t.Helper()
 	if resp.IsDraining != drain {
@@ -0,0 +1,139 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"context"
+
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/loqrecovery"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
+	"github.com/cockroachdb/cockroach/pkg/server/telemetry"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/uuid"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+// ForceDecommission marks a dead and unreachable node as decommissioned and
+// hands the ranges that lost quorum with it to loss of quorum recovery. See
+// serverpb.AdminServer.ForceDecommission.
+//
+// The node is moved through DECOMMISSIONING to DECOMMISSIONED right away,
+// without waiting for its replicas to be moved off of it: they can't be, since
+// the node is gone. The ranges that kept a quorum up-replicate on their own
+// once the node is decommissioned; the ones that lost quorum need a recovery
+// plan, which is computed from the replica info collected from the remaining
+// nodes.
+func (s *systemAdminServer) ForceDecommission(
+	ctx context.Context, req *serverpb.ForceDecommissionRequest,
+) (*serverpb.ForceDecommissionResponse, error) {
+	ctx = s.AnnotateCtx(ctx)
+	if _, err := s.requireAdminUser(ctx); err != nil {
+		// NB: not using serverError() here since the priv checker
+		// already returns a proper gRPC error status.
+		return nil, err
+	}
+	if req.NodeID == 0 {
+		return nil, grpcstatus.Errorf(codes.InvalidArgument, "no node ID specified")
+	}
+	if req.NodeID == s.server.NodeID() {
+		return nil, grpcstatus.Errorf(codes.InvalidArgument, "n%d cannot force-decommission itself", req.NodeID)
+	}
+
+	// Only a node that is gone for good may be force-decommissioned. A node
+	// that is merely slow or partitioned away should be decommissioned
+	// normally, or waited for.
+	now := s.server.clock.Now()
+	threshold := liveness.TimeUntilStoreDead.Get(&s.st.SV)
+	vitalities := s.nodeLiveness.ScanNodeVitalityFromCache()
+	v, ok := vitalities[req.NodeID]
+	if !ok {
+		return nil, grpcstatus.Error(codes.NotFound, liveness.ErrMissingRecord.Error())
+	}
+	if !v.Liveness.IsDead(now, threshold) {
+		return nil, grpcstatus.Errorf(codes.FailedPrecondition,
+			"n%d is not dead: it is %s", req.NodeID, v.Status(now, threshold))
+	}
+	if v.Connectivity == livenesspb.Connectivity_CONNECTED {
+		return nil, grpcstatus.Errorf(codes.FailedPrecondition,
+			"n%d is dead, but reachable over RPC", req.NodeID)
+	}
+
+	// The report needs to be made before the node is decommissioned, since the
+	// ranges may start moving their replicas off of it right after.
+	report, err := makeNodeRecoveryReport(ctx, s.server.db, req.NodeID, func(nodeID roachpb.NodeID) bool {
+		v, ok := vitalities[nodeID]
+		return ok && v.IsLive(now)
+	})
+	if err != nil {
+		return nil, serverError(ctx, err)
+	}
+
+	log.Ops.Infof(ctx, "force-decommissioning n%d, which leaves %d ranges unavailable",
+		req.NodeID, report.UnavailableRanges)
+	telemetry.Inc(telemetryForceDecommission)
+	nodeIDs := []roachpb.NodeID{req.NodeID}
+	for _, target := range []livenesspb.MembershipStatus{
+		livenesspb.MembershipStatus_DECOMMISSIONING,
+		livenesspb.MembershipStatus_DECOMMISSIONED,
+	} {
+		if err := s.server.decommissionWithReason(ctx, target, nodeIDs, req.Reason); err != nil {
+			// NB: not using serverError() here since Decommission
+			// already returns a proper gRPC error status.
+			return nil, err
+		}
+	}
+
+	res := &serverpb.ForceDecommissionResponse{Report: *report}
+	if report.UnavailableRanges == 0 {
+		return res, nil
+	}
+
+	// Errors past this point are reported in the response rather than
+	// returned, since the node is decommissioned already.
+	admin, err := s.dialNode(ctx, s.server.NodeID())
+	if err != nil {
+		return nil, serverError(ctx, err)
+	}
+	info, _, err := loqrecovery.CollectRemoteReplicaInfo(ctx, admin)
+	if err != nil {
+		res.PlanningProblems = append(res.PlanningProblems, "unable to collect replica info: "+err.Error())
+		return res, nil
+	}
+	plan, planningReport, err := loqrecovery.PlanReplicas(ctx, info, nil /* deadStoreIDs */, nodeIDs, uuid.DefaultGenerator)
+	if err != nil {
+		res.PlanningProblems = append(res.PlanningProblems, "unable to plan recovery: "+err.Error())
+		return res, nil
+	}
+	res.Plan = &plan
+	for _, p := range planningReport.Problems {
+		res.PlanningProblems = append(res.PlanningProblems, p.String())
+	}
+	if !req.StagePlan {
+		return res, nil
+	}
+
+	log.Ops.Infof(ctx, "staging loss of quorum recovery plan %s after force-decommissioning n%d",
+		plan.PlanID, req.NodeID)
+	staged, err := s.server.recoveryServer.StagePlan(ctx, &serverpb.RecoveryStagePlanRequest{
+		Plan:     &plan,
+		AllNodes: true,
+	})
+	if err != nil {
+		res.StagingErrors = append(res.StagingErrors, err.Error())
+		return res, nil
+	}
+	res.StagingErrors = staged.Errors
+	return res, nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
+	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/cockroach/pkg/testutils/serverutils"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+func TestForceDecommission(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := serverutils.StartNewTestCluster(t, 3, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	// With manual replication, only the scratch range has a replica on n3.
+	key := tc.ScratchRange(t)
+	desc := tc.AddVotersOrFatal(t, key, tc.Target(1), tc.Target(2))
+	deadNodeID := tc.Server(2).NodeID()
+	s := tc.Server(0).(*TestServer)
+	liveness.TimeUntilStoreDead.Override(ctx, &s.ClusterSettings().SV, liveness.TestTimeUntilStoreDead)
+
+	// A live node can't be force-decommissioned.
+	_, err := s.admin.ForceDecommission(ctx, &serverpb.ForceDecommissionRequest{NodeID: deadNodeID})
+	require.Equal(t, codes.FailedPrecondition, grpcstatus.Code(err), "%v", err)
+
+	tc.StopServer(2)
+	var res *serverpb.ForceDecommissionResponse
+	testutils.SucceedsSoon(t, func() error {
+		res, err = s.admin.ForceDecommission(ctx, &serverpb.ForceDecommissionRequest{
+			NodeID: deadNodeID,
+			Reason: "disk lost",
+		})
+		return err
+	})
+
+	// The range kept a quorum, so there's nothing to recover.
+	require.Equal(t, []serverpb.NodeRecoveryReportResponse_Range{{
+		RangeID:    desc.RangeID,
+		StartKey:   desc.StartKey,
+		Voters:     3,
+		LiveVoters: 2,
+	}}, res.Report.Ranges)
+	require.Zero(t, res.Report.UnavailableRanges)
+	require.Nil(t, res.Plan)
+	require.Empty(t, res.PlanningProblems)
+
+	testutils.SucceedsSoon(t, func() error {
+		l, ok := s.nodeLiveness.GetLiveness(deadNodeID)
+		if !ok {
+			return errors.Errorf("no liveness record for n%d", deadNodeID)
+		}
+		if l.Membership != livenesspb.MembershipStatus_DECOMMISSIONED {
+			return errors.Errorf("n%d is %s", deadNodeID, l.Membership)
+		}
+		return nil
+	})
+}
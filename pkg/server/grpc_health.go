@@ -0,0 +1,111 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"context"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/stop"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+const (
+	// grpcHealthServiceAlive is the service name under which the health
+	// service reports whether the process is up.
+	grpcHealthServiceAlive = "alive"
+	// grpcHealthServiceReady is the service name under which the health
+	// service reports whether the node is ready to accept traffic. The
+	// server as a whole (the empty service name) reports the same.
+	grpcHealthServiceReady = "ready"
+)
+
+// grpcHealthWatchInterval is the interval at which the health service
+// re-evaluates the serving status of a watched service.
+const grpcHealthWatchInterval = time.Second
+
+// grpcHealthServer implements the standard gRPC health checking protocol
+// (grpc.health.v1.Health), which lets L4/L7 load balancers probe nodes without
+// going through the HTTP health endpoint. It distinguishes between a node
+// being alive, meaning the process is up and answering, and a node being
+// ready, meaning it is initialized, not draining, and its liveness record is
+// valid (the same as /health?ready=1).
+type grpcHealthServer struct {
+	admin   *systemAdminServer
+	stopper *stop.Stopper
+}
+
+var _ healthpb.HealthServer = (*grpcHealthServer)(nil)
+
+// servingStatus returns the serving status of the given service.
+func (s *grpcHealthServer) servingStatus(
+	ctx context.Context, service string,
+) (healthpb.HealthCheckResponse_ServingStatus, error) {
+	switch service {
+	case grpcHealthServiceAlive:
+		return healthpb.HealthCheckResponse_SERVING, nil
+	case "", grpcHealthServiceReady:
+		if err := s.admin.checkReadinessForHealthCheck(ctx); err != nil {
+			log.VEventf(ctx, 2, "node not ready: %v", err)
+			return healthpb.HealthCheckResponse_NOT_SERVING, nil
+		}
+		return healthpb.HealthCheckResponse_SERVING, nil
+	default:
+		return healthpb.HealthCheckResponse_SERVICE_UNKNOWN,
+			grpcstatus.Errorf(codes.NotFound, "unknown service %q", service)
+	}
+}
+
+// Check implements the healthpb.HealthServer interface.
+func (s *grpcHealthServer) Check(
+	ctx context.Context, req *healthpb.HealthCheckRequest,
+) (*healthpb.HealthCheckResponse, error) {
+	status, err := s.servingStatus(ctx, req.Service)
+	if err != nil {
+		return nil, err
+	}
+	return &healthpb.HealthCheckResponse{Status: status}, nil
+}
+
+// Watch implements the healthpb.HealthServer interface. It sends the serving
+// status of the service right away, and then again every time it changes.
+// Unknown services are reported as SERVICE_UNKNOWN rather than failing the
+// call, as the protocol requires.
+func (s *grpcHealthServer) Watch(
+	req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer,
+) error {
+	ctx := stream.Context()
+	var timer timeutil.Timer
+	defer timer.Stop()
+	last := healthpb.HealthCheckResponse_ServingStatus(-1)
+	for {
+		status, _ := s.servingStatus(ctx, req.Service)
+		if status != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: status}); err != nil {
+				return err
+			}
+			last = status
+		}
+		timer.Reset(grpcHealthWatchInterval)
+		select {
+		case <-timer.C:
+			timer.Read = true
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.stopper.ShouldQuiesce():
+			return grpcstatus.Error(codes.Unavailable, "node is shutting down")
+		}
+	}
+}
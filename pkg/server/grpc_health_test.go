@@ -0,0 +1,78 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/rpc"
+	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/cockroach/pkg/testutils/serverutils"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+// TestGRPCHealth verifies that the gRPC health service reports the node as
+// alive regardless of its readiness, and as ready only while it isn't
+// draining.
+func TestGRPCHealth(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	s, _, _ := serverutils.StartServer(t, base.TestServerArgs{})
+	defer s.Stopper().Stop(ctx)
+	ts := s.(*TestServer)
+
+	conn, err := ts.RPCContext().GRPCDialNode(ts.RPCAddr(), ts.NodeID(), rpc.DefaultClass).Connect(ctx)
+	require.NoError(t, err)
+	client := healthpb.NewHealthClient(conn)
+	check := func(service string) (healthpb.HealthCheckResponse_ServingStatus, error) {
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			return healthpb.HealthCheckResponse_UNKNOWN, err
+		}
+		return resp.Status, nil
+	}
+	expect := func(service string, expected healthpb.HealthCheckResponse_ServingStatus) {
+		testutils.SucceedsSoon(t, func() error {
+			status, err := check(service)
+			if err != nil {
+				return err
+			}
+			if status != expected {
+				return errors.Errorf("%q: expected %s, got %s", service, expected, status)
+			}
+			return nil
+		})
+	}
+
+	expect("", healthpb.HealthCheckResponse_SERVING)
+	expect(grpcHealthServiceReady, healthpb.HealthCheckResponse_SERVING)
+	expect(grpcHealthServiceAlive, healthpb.HealthCheckResponse_SERVING)
+	_, err = check("unknown")
+	require.Equal(t, codes.NotFound, grpcstatus.Code(err))
+
+	// A draining node is alive, but not ready.
+	ts.grpc.setMode(modeDraining)
+	expect("", healthpb.HealthCheckResponse_NOT_SERVING)
+	expect(grpcHealthServiceReady, healthpb.HealthCheckResponse_NOT_SERVING)
+	expect(grpcHealthServiceAlive, healthpb.HealthCheckResponse_SERVING)
+	ts.grpc.setMode(modeOperational)
+	expect(grpcHealthServiceReady, healthpb.HealthCheckResponse_SERVING)
+}
@@ -73,6 +73,8 @@ var rpcsAllowedWhileBootstrapping = map[string]struct{}{
 	"/cockroach.gossip.Gossip/Gossip":           {},
 	"/cockroach.server.serverpb.Init/Bootstrap": {},
 	"/cockroach.server.serverpb.Admin/Health":   {},
+	"/grpc.health.v1.Health/Check":              {},
+	"/grpc.health.v1.Health/Watch":              {},
 }
 
 // intercept implements filtering rules for each server state.
@@ -138,6 +138,9 @@ type initState struct {
 	initializedEngines   []storage.Engine
 	uninitializedEngines []storage.Engine
 	initialSettingsKVs   []roachpb.KeyValue
+	// livenessRecords are the encoded liveness records received in the join
+	// response, if the node just joined the cluster.
+	livenessRecords [][]byte
 }
 
 // bootstrapped is a shorthand to check if there exists at least one initialized
@@ -576,10 +579,15 @@ func (s *initServer) initializeFirstStoreAfterJoin(
 		return nil, err
 	}
 
-	return inspectEngines(
+	state, err := inspectEngines(
 		ctx, s.inspectedDiskState.uninitializedEngines,
 		s.config.binaryVersion, s.config.binaryMinSupportedVersion,
 	)
+	if err != nil {
+		return nil, err
+	}
+	state.livenessRecords = resp.LivenessRecords
+	return state, nil
 }
 
 func assertEnginesEmpty(engines []storage.Engine) error {
@@ -0,0 +1,202 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+// localLivenessView returns the view of the liveness of all nodes cached by
+// this node.
+func (s *systemStatusServer) localLivenessView() serverpb.LivenessConsistencyResponse_View {
+	view := s.nodeLiveness.GetLivenessView()
+	threshold := s.nodeLiveness.GetLivenessThreshold()
+	takenAt := view.Now.GoTime()
+	res := serverpb.LivenessConsistencyResponse_View{
+		NodeID:  roachpb.NodeID(s.serverIterator.getID()),
+		TakenAt: takenAt,
+		Entries: make([]serverpb.LivenessConsistencyResponse_Entry, 0, len(view.IsLiveMap)),
+	}
+	for nodeID, entry := range view.IsLiveMap {
+		// Heartbeats extend the expiration of the record by its TTL.
+		heartbeatAt := entry.Expiration.ToTimestamp().GoTime().Add(-entry.TTL(threshold))
+		res.Entries = append(res.Entries, serverpb.LivenessConsistencyResponse_Entry{
+			NodeID:       nodeID,
+			Status:       view.Status(nodeID),
+			Epoch:        entry.Epoch,
+			Membership:   entry.Membership,
+			HeartbeatAge: takenAt.Sub(heartbeatAt),
+		})
+	}
+	sort.Slice(res.Entries, func(i, j int) bool {
+		return res.Entries[i].NodeID < res.Entries[j].NodeID
+	})
+	return res
+}
+
+// findLivenessDisagreements compares the given liveness views, node by node,
+// and returns the nodes they disagree on. Views that couldn't be retrieved are
+// ignored.
+func findLivenessDisagreements(
+	views []serverpb.LivenessConsistencyResponse_View,
+) []serverpb.LivenessConsistencyResponse_Disagreement {
+	type observed struct {
+		observer roachpb.NodeID
+		snapshot map[roachpb.NodeID]livenessSnapshotEntry
+	}
+	var observers []observed
+	for _, v := range views {
+		if v.ErrorMessage != "" {
+			continue
+		}
+		o := observed{
+			observer: v.NodeID,
+			snapshot: make(map[roachpb.NodeID]livenessSnapshotEntry, len(v.Entries)),
+		}
+		for _, e := range v.Entries {
+			o.snapshot[e.NodeID] = livenessSnapshotEntry{
+				liveness: livenesspb.Liveness{NodeID: e.NodeID, Epoch: e.Epoch, Membership: e.Membership},
+				status:   e.Status,
+			}
+		}
+		observers = append(observers, o)
+	}
+	sort.Slice(observers, func(i, j int) bool {
+		return observers[i].observer < observers[j].observer
+	})
+
+	// Every observer is compared to the first one, and the differences are
+	// accumulated per node.
+	differences := make(map[roachpb.NodeID][]string)
+	for i := 1; i < len(observers); i++ {
+		for _, n := range diffLivenessSnapshots(observers[0].snapshot, observers[i].snapshot) {
+		diffs:
+			for _, diff := range n.Differences {
+				for _, seen := range differences[n.NodeID] {
+					if seen == diff {
+						continue diffs
+					}
+				}
+				differences[n.NodeID] = append(differences[n.NodeID], diff)
+			}
+		}
+	}
+
+	disagreements := make([]serverpb.LivenessConsistencyResponse_Disagreement, 0, len(differences))
+	for nodeID, diffs := range differences {
+		d := serverpb.LivenessConsistencyResponse_Disagreement{NodeID: nodeID, Differences: diffs}
+		for _, o := range observers {
+			obs := serverpb.LivenessConsistencyResponse_Observation{ObserverNodeID: o.observer}
+			if e, ok := o.snapshot[nodeID]; ok {
+				obs.Present = true
+				obs.Status, obs.Epoch, obs.Membership = e.status, e.liveness.Epoch, e.liveness.Membership
+			}
+			d.Observations = append(d.Observations, obs)
+		}
+		disagreements = append(disagreements, d)
+	}
+	sort.Slice(disagreements, func(i, j int) bool {
+		return disagreements[i].NodeID < disagreements[j].NodeID
+	})
+	return disagreements
+}
+
+// setLivenessViewAges sets the age of the given liveness views as of now.
+func setLivenessViewAges(views []serverpb.LivenessConsistencyResponse_View, now time.Time) {
+	for i := range views {
+		if views[i].ErrorMessage == "" {
+			views[i].Age = now.Sub(views[i].TakenAt)
+		}
+	}
+}
+
+// LivenessConsistency reports the disagreements between the views of the
+// liveness of all nodes cached by each node. See
+// serverpb.StatusServer.LivenessConsistency.
+func (s *systemStatusServer) LivenessConsistency(
+	ctx context.Context, req *serverpb.LivenessConsistencyRequest,
+) (*serverpb.LivenessConsistencyResponse, error) {
+	ctx = s.AnnotateCtx(ctx)
+	if err := s.privilegeChecker.requireViewClusterMetadataPermission(ctx); err != nil {
+		// NB: not using serverError() here since the priv checker
+		// already returns a proper gRPC error status.
+		return nil, err
+	}
+
+	if len(req.NodeID) > 0 {
+		requestedNodeID, local, err := s.parseNodeID(req.NodeID)
+		if err != nil {
+			return nil, grpcstatus.Errorf(codes.InvalidArgument, err.Error())
+		}
+
+		// Only the view of the local node.
+		if local {
+			res := &serverpb.LivenessConsistencyResponse{
+				Views: []serverpb.LivenessConsistencyResponse_View{s.localLivenessView()},
+			}
+			setLivenessViewAges(res.Views, s.clock.PhysicalTime())
+			return res, nil
+		}
+
+		// Only the view of one non-local node.
+		status, err := s.dialNode(ctx, requestedNodeID)
+		if err != nil {
+			return nil, serverError(ctx, err)
+		}
+		res, err := status.LivenessConsistency(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		setLivenessViewAges(res.Views, s.clock.PhysicalTime())
+		return res, nil
+	}
+
+	// The views of all nodes.
+	var views []serverpb.LivenessConsistencyResponse_View
+	dialFn := func(ctx context.Context, nodeID roachpb.NodeID) (interface{}, error) {
+		client, err := s.dialNode(ctx, nodeID)
+		return client, err
+	}
+	remoteRequest := serverpb.LivenessConsistencyRequest{NodeID: "local"}
+	nodeFn := func(ctx context.Context, client interface{}, _ roachpb.NodeID) (interface{}, error) {
+		status := client.(serverpb.StatusClient)
+		return status.LivenessConsistency(ctx, &remoteRequest)
+	}
+	responseFn := func(nodeID roachpb.NodeID, resp interface{}) {
+		views = append(views, resp.(*serverpb.LivenessConsistencyResponse).Views...)
+	}
+	errorFn := func(nodeID roachpb.NodeID, err error) {
+		views = append(views, serverpb.LivenessConsistencyResponse_View{
+			NodeID:       nodeID,
+			ErrorMessage: err.Error(),
+		})
+	}
+	if err := s.iterateNodes(ctx, "liveness views", dialFn, nodeFn, responseFn, errorFn); err != nil {
+		return nil, serverError(ctx, err)
+	}
+
+	setLivenessViewAges(views, s.clock.PhysicalTime())
+	sort.Slice(views, func(i, j int) bool {
+		return views[i].NodeID < views[j].NodeID
+	})
+	return &serverpb.LivenessConsistencyResponse{
+		Views:         views,
+		Disagreements: findLivenessDisagreements(views),
+	}, nil
+}
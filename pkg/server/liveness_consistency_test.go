@@ -0,0 +1,110 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
+	"github.com/cockroachdb/cockroach/pkg/testutils/serverutils"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindLivenessDisagreements(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	const (
+		live  = livenesspb.NodeLivenessStatus_LIVE
+		dead  = livenesspb.NodeLivenessStatus_DEAD
+		act   = livenesspb.MembershipStatus_ACTIVE
+		decom = livenesspb.MembershipStatus_DECOMMISSIONING
+	)
+	entry := func(
+		nodeID roachpb.NodeID,
+		epoch int64,
+		status livenesspb.NodeLivenessStatus,
+		membership livenesspb.MembershipStatus,
+	) serverpb.LivenessConsistencyResponse_Entry {
+		return serverpb.LivenessConsistencyResponse_Entry{
+			NodeID: nodeID, Epoch: epoch, Status: status, Membership: membership,
+		}
+	}
+	views := []serverpb.LivenessConsistencyResponse_View{
+		{NodeID: 2, Entries: []serverpb.LivenessConsistencyResponse_Entry{
+			entry(1, 1, live, act), entry(2, 1, live, act), entry(3, 2, dead, act), entry(5, 1, live, act),
+		}},
+		{NodeID: 1, Entries: []serverpb.LivenessConsistencyResponse_Entry{
+			entry(1, 1, live, act), entry(2, 1, live, decom), entry(3, 1, live, act),
+		}},
+		// Views that couldn't be retrieved don't count as disagreeing.
+		{NodeID: 3, ErrorMessage: "boom"},
+	}
+
+	var nodeIDs []roachpb.NodeID
+	var diffs [][]string
+	for _, d := range findLivenessDisagreements(views) {
+		nodeIDs = append(nodeIDs, d.NodeID)
+		diffs = append(diffs, d.Differences)
+		require.Len(t, d.Observations, 2)
+		require.Equal(t, roachpb.NodeID(1), d.Observations[0].ObserverNodeID)
+		require.Equal(t, roachpb.NodeID(2), d.Observations[1].ObserverNodeID)
+	}
+	require.Equal(t, []roachpb.NodeID{2, 3, 5}, nodeIDs)
+	require.Equal(t, [][]string{
+		{"membership"},
+		{"status", "epoch"},
+		{"presence"},
+	}, diffs)
+}
+
+func TestLivenessConsistency(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := serverutils.StartNewTestCluster(t, 3, base.TestClusterArgs{})
+	defer tc.Stopper().Stop(ctx)
+
+	s := tc.Server(0).StatusServer().(serverpb.StatusServer)
+
+	// The views of healthy nodes agree with each other.
+	res, err := s.LivenessConsistency(ctx, &serverpb.LivenessConsistencyRequest{})
+	require.NoError(t, err)
+	require.Len(t, res.Views, tc.NumServers())
+	for i, v := range res.Views {
+		require.Equal(t, tc.Server(i).NodeID(), v.NodeID)
+		require.Empty(t, v.ErrorMessage)
+		require.Len(t, v.Entries, tc.NumServers())
+		require.GreaterOrEqual(t, int64(v.Age), int64(0))
+	}
+	for _, d := range res.Disagreements {
+		// Nodes may transiently disagree on the status of a node whose record
+		// is about to expire, but not on the presence, epoch or membership of
+		// healthy nodes.
+		require.Equal(t, []string{"status"}, d.Differences, "%+v", d)
+	}
+
+	// The view of a single node can be requested.
+	res, err = s.LivenessConsistency(ctx, &serverpb.LivenessConsistencyRequest{
+		NodeID: tc.Server(1).NodeID().String(),
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Views, 1)
+	require.Equal(t, tc.Server(1).NodeID(), res.Views[0].NodeID)
+	require.Empty(t, res.Disagreements)
+}
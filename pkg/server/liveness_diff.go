@@ -0,0 +1,155 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"context"
+	"sort"
+
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+// livenessSnapshotEntry is the liveness of a node in a liveness snapshot.
+type livenessSnapshotEntry struct {
+	liveness livenesspb.Liveness
+	status   livenesspb.NodeLivenessStatus
+}
+
+// livenessSnapshot returns the snapshot of the liveness of all nodes described
+// by the given source.
+func (s *systemStatusServer) livenessSnapshot(
+	ctx context.Context, src serverpb.LivenessSnapshotSource,
+) (map[roachpb.NodeID]livenessSnapshotEntry, error) {
+	snapshot := make(map[roachpb.NodeID]livenessSnapshotEntry)
+	switch {
+	case src.NodeID != "" && src.AsOf != nil:
+		return nil, grpcstatus.Errorf(codes.InvalidArgument,
+			"a liveness snapshot is either taken from a node or as of a time, not both")
+
+	case src.NodeID != "":
+		nodeID, local, err := s.parseNodeID(src.NodeID)
+		if err != nil {
+			return nil, grpcstatus.Errorf(codes.InvalidArgument, err.Error())
+		}
+		var resp *serverpb.NodeVitalityResponse
+		if local {
+			resp, err = s.NodeVitality(ctx, &serverpb.NodeVitalityRequest{})
+		} else {
+			var status serverpb.StatusClient
+			status, err = s.dialNode(ctx, nodeID)
+			if err != nil {
+				return nil, serverError(ctx, err)
+			}
+			resp, err = status.NodeVitality(ctx, &serverpb.NodeVitalityRequest{})
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range resp.Nodes {
+			snapshot[n.NodeID] = livenessSnapshotEntry{liveness: n.Liveness, status: n.Status}
+		}
+		return snapshot, nil
+
+	default:
+		var resp *serverpb.LivenessResponse
+		var err error
+		if src.AsOf == nil {
+			resp, err = getLivenessResponse(ctx, s.nodeLiveness, s.clock.Now(), s.st)
+		} else {
+			asOf := hlc.Timestamp{WallTime: src.AsOf.UnixNano()}
+			if s.clock.Now().Less(asOf) {
+				return nil, grpcstatus.Errorf(codes.InvalidArgument, "as_of time %s is in the future", src.AsOf)
+			}
+			resp, err = getHistoricalLivenessResponse(ctx, s.nodeLiveness, asOf, s.st)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range resp.Livenesses {
+			snapshot[l.NodeID] = livenessSnapshotEntry{liveness: l, status: resp.Statuses[l.NodeID]}
+		}
+		return snapshot, nil
+	}
+}
+
+// diffLivenessSnapshots compares the two given liveness snapshots, node by
+// node.
+func diffLivenessSnapshots(
+	a, b map[roachpb.NodeID]livenessSnapshotEntry,
+) []serverpb.LivenessDiffResponse_Node {
+	nodeIDs := make(map[roachpb.NodeID]struct{}, len(a))
+	for nodeID := range a {
+		nodeIDs[nodeID] = struct{}{}
+	}
+	for nodeID := range b {
+		nodeIDs[nodeID] = struct{}{}
+	}
+
+	nodes := make([]serverpb.LivenessDiffResponse_Node, 0, len(nodeIDs))
+	for nodeID := range nodeIDs {
+		n := serverpb.LivenessDiffResponse_Node{NodeID: nodeID}
+		var ea, eb livenessSnapshotEntry
+		ea, n.InA = a[nodeID]
+		eb, n.InB = b[nodeID]
+		if n.InA {
+			n.StatusA, n.EpochA, n.MembershipA = ea.status, ea.liveness.Epoch, ea.liveness.Membership
+		}
+		if n.InB {
+			n.StatusB, n.EpochB, n.MembershipB = eb.status, eb.liveness.Epoch, eb.liveness.Membership
+		}
+		if n.InA != n.InB {
+			n.Differences = append(n.Differences, "presence")
+		} else {
+			if n.StatusA != n.StatusB {
+				n.Differences = append(n.Differences, "status")
+			}
+			if n.EpochA != n.EpochB {
+				n.Differences = append(n.Differences, "epoch")
+			}
+			if n.MembershipA != n.MembershipB {
+				n.Differences = append(n.Differences, "membership")
+			}
+		}
+		nodes = append(nodes, n)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].NodeID < nodes[j].NodeID
+	})
+	return nodes
+}
+
+// LivenessDiff compares two snapshots of the liveness of all nodes. See
+// serverpb.StatusServer.LivenessDiff.
+func (s *systemStatusServer) LivenessDiff(
+	ctx context.Context, req *serverpb.LivenessDiffRequest,
+) (*serverpb.LivenessDiffResponse, error) {
+	ctx = s.AnnotateCtx(ctx)
+	if err := s.privilegeChecker.requireViewClusterMetadataPermission(ctx); err != nil {
+		// NB: not using serverError() here since the priv checker
+		// already returns a proper gRPC error status.
+		return nil, err
+	}
+
+	a, err := s.livenessSnapshot(ctx, req.A)
+	if err != nil {
+		return nil, err
+	}
+	b, err := s.livenessSnapshot(ctx, req.B)
+	if err != nil {
+		return nil, err
+	}
+	return &serverpb.LivenessDiffResponse{Nodes: diffLivenessSnapshots(a, b)}, nil
+}
@@ -0,0 +1,118 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
+	"github.com/cockroachdb/cockroach/pkg/testutils/serverutils"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+func TestDiffLivenessSnapshots(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	entry := func(
+		nodeID roachpb.NodeID,
+		epoch int64,
+		status livenesspb.NodeLivenessStatus,
+		membership livenesspb.MembershipStatus,
+	) livenessSnapshotEntry {
+		return livenessSnapshotEntry{
+			liveness: livenesspb.Liveness{NodeID: nodeID, Epoch: epoch, Membership: membership},
+			status:   status,
+		}
+	}
+	const (
+		live  = livenesspb.NodeLivenessStatus_LIVE
+		dead  = livenesspb.NodeLivenessStatus_DEAD
+		act   = livenesspb.MembershipStatus_ACTIVE
+		decom = livenesspb.MembershipStatus_DECOMMISSIONING
+	)
+	a := map[roachpb.NodeID]livenessSnapshotEntry{
+		1: entry(1, 1, live, act),
+		2: entry(2, 3, live, act),
+		3: entry(3, 1, live, act),
+		4: entry(4, 1, live, act),
+	}
+	b := map[roachpb.NodeID]livenessSnapshotEntry{
+		1: entry(1, 1, live, act),
+		2: entry(2, 4, dead, act),
+		3: entry(3, 1, live, decom),
+		5: entry(5, 1, live, act),
+	}
+
+	var diffs [][]string
+	var nodeIDs []roachpb.NodeID
+	for _, n := range diffLivenessSnapshots(a, b) {
+		nodeIDs = append(nodeIDs, n.NodeID)
+		diffs = append(diffs, n.Differences)
+	}
+	require.Equal(t, []roachpb.NodeID{1, 2, 3, 4, 5}, nodeIDs)
+	require.Equal(t, [][]string{
+		nil,
+		{"status", "epoch"},
+		{"membership"},
+		{"presence"},
+		{"presence"},
+	}, diffs)
+}
+
+func TestLivenessDiff(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := serverutils.StartNewTestCluster(t, 2, base.TestClusterArgs{})
+	defer tc.Stopper().Stop(ctx)
+
+	s := tc.Server(0).StatusServer().(serverpb.StatusServer)
+
+	// The views of two healthy nodes agree on epochs and memberships.
+	res, err := s.LivenessDiff(ctx, &serverpb.LivenessDiffRequest{
+		A: serverpb.LivenessSnapshotSource{NodeID: "local"},
+		B: serverpb.LivenessSnapshotSource{NodeID: tc.Server(1).NodeID().String()},
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Nodes, 2)
+	for _, n := range res.Nodes {
+		require.True(t, n.InA && n.InB)
+		require.Equal(t, n.EpochA, n.EpochB)
+		require.Equal(t, n.MembershipA, n.MembershipB)
+	}
+
+	// The current records in KV are compared against the records as of now.
+	now := tc.Server(0).Clock().Now().GoTime()
+	res, err = s.LivenessDiff(ctx, &serverpb.LivenessDiffRequest{
+		B: serverpb.LivenessSnapshotSource{AsOf: &now},
+	})
+	require.NoError(t, err)
+	require.Len(t, res.Nodes, 2)
+	for _, n := range res.Nodes {
+		require.True(t, n.InA && n.InB)
+		require.Equal(t, n.EpochA, n.EpochB)
+	}
+
+	_, err = s.LivenessDiff(ctx, &serverpb.LivenessDiffRequest{
+		A: serverpb.LivenessSnapshotSource{NodeID: "local", AsOf: &now},
+	})
+	require.Equal(t, codes.InvalidArgument, grpcstatus.Code(err), "%v", err)
+}
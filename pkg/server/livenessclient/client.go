@@ -0,0 +1,642 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+// Package livenessclient is a client of the liveness, vitality and membership
+// APIs of a cluster, meant for external tooling such as orchestrators and SQL
+// proxies. It saves their authors from driving the underlying RPCs, some of
+// which are streaming and need to be sequenced, by hand.
+package livenessclient
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/cockroach/pkg/util/uuid"
+	"github.com/cockroachdb/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+// Client is a client of the liveness, vitality and membership APIs of a
+// cluster, which it accesses through one of its nodes.
+type Client struct {
+	admin  serverpb.AdminClient
+	status serverpb.StatusClient
+}
+
+// New returns a Client sending its requests over the given connection to a
+// node of the cluster.
+func New(conn *grpc.ClientConn) *Client {
+	return NewFromClients(serverpb.NewAdminClient(conn), serverpb.NewStatusClient(conn))
+}
+
+// NewFromClients returns a Client sending its requests through the given
+// admin and status clients.
+func NewFromClients(admin serverpb.AdminClient, status serverpb.StatusClient) *Client {
+	return &Client{admin: admin, status: status}
+}
+
+// NodeVitality is the vitality of a node, as seen by the node the client is
+// connected to.
+type NodeVitality struct {
+	NodeID roachpb.NodeID
+	// Status is the verdict for the node, which combines its liveness record
+	// with the state of the RPC connection to it.
+	Status livenesspb.NodeLivenessStatus
+	// LifecycleState is the stage of its lifecycle the node is in, which,
+	// unlike Status, tells apart e.g. a dead decommissioning node from a
+	// decommissioned one.
+	LifecycleState livenesspb.NodeLifecycleState
+	Membership     livenesspb.MembershipStatus
+	Draining       bool
+	Epoch          int64
+	// Reason is the reason given for the last membership change of the node,
+	// if any.
+	Reason       string
+	Connectivity livenesspb.Connectivity
+	// ValidUntil is the time until which the verdict holds, absent new
+	// liveness records and connectivity changes, if it doesn't hold until new
+	// information arrives.
+	ValidUntil *time.Time
+	// TimeUntilDead is the time left until the node is considered dead, if its
+	// liveness record expired and it isn't dead yet.
+	TimeUntilDead *time.Duration
+	// Suspect is set if the node recently became live again after failing its
+	// liveness, and isn't yet eligible to receive replicas.
+	Suspect bool
+	// Degraded is why the node receives no new leases, if it doesn't.
+	Degraded string
+}
+
+func makeNodeVitality(n serverpb.NodeVitalityResponse_Node) NodeVitality {
+	return NodeVitality{
+		NodeID:         n.NodeID,
+		Status:         n.Status,
+		LifecycleState: n.LifecycleState,
+		Membership:     n.Liveness.Membership,
+		Draining:       n.Liveness.Draining,
+		Epoch:          n.Liveness.Epoch,
+		Reason:         n.Liveness.Reason,
+		Connectivity:   n.Connectivity,
+		ValidUntil:     n.ValidUntil,
+		TimeUntilDead:  n.TimeUntilDead,
+		Suspect:        n.Suspect,
+		Degraded:       n.Degraded,
+	}
+}
+
+// Vitality returns the vitality of every node, as seen by the node the client
+// is connected to, ordered by node ID.
+func (c *Client) Vitality(ctx context.Context) ([]NodeVitality, error) {
+	resp, err := c.status.NodeVitality(ctx, &serverpb.NodeVitalityRequest{})
+	if err != nil {
+		return nil, errors.Wrap(err, "fetching node vitality")
+	}
+	nodes := make([]NodeVitality, len(resp.Nodes))
+	for i, n := range resp.Nodes {
+		nodes[i] = makeNodeVitality(n)
+	}
+	return nodes, nil
+}
+
+// NodeVitality returns the vitality of the given node, as seen by the node the
+// client is connected to.
+func (c *Client) NodeVitality(ctx context.Context, nodeID roachpb.NodeID) (NodeVitality, error) {
+	nodes, err := c.Vitality(ctx)
+	if err != nil {
+		return NodeVitality{}, err
+	}
+	for _, node := range nodes {
+		if node.NodeID == nodeID {
+			return node, nil
+		}
+	}
+	return NodeVitality{}, errors.Errorf("n%d not found", nodeID)
+}
+
+// NodeMembership is the membership and liveness status of a node.
+type NodeMembership struct {
+	NodeID     roachpb.NodeID
+	Membership livenesspb.MembershipStatus
+	// LivenessStatus is the status of the node, derived from its liveness
+	// record.
+	LivenessStatus livenesspb.NodeLivenessStatus
+	// IsLive is whether the liveness record of the node was live.
+	IsLive   bool
+	Draining bool
+}
+
+// MembershipStatus is the membership and liveness status of a set of nodes,
+// all derived from the same snapshot of their liveness records.
+type MembershipStatus struct {
+	// Timestamp is the time of the snapshot.
+	Timestamp time.Time
+	// Nodes are ordered by node ID.
+	Nodes []NodeMembership
+	// MissingNodeIDs are the requested nodes that have no liveness record.
+	MissingNodeIDs []roachpb.NodeID
+}
+
+// MembershipStatus returns the membership and liveness status of the given
+// nodes, or of all nodes if none are given, all derived from the same snapshot
+// of their liveness records.
+func (c *Client) MembershipStatus(
+	ctx context.Context, nodeIDs ...roachpb.NodeID,
+) (MembershipStatus, error) {
+	resp, err := c.admin.MembershipStatus(ctx, &serverpb.MembershipStatusRequest{NodeIDs: nodeIDs})
+	if err != nil {
+		return MembershipStatus{}, errors.Wrap(err, "fetching membership status")
+	}
+	status := MembershipStatus{
+		Timestamp:      resp.Timestamp,
+		Nodes:          make([]NodeMembership, len(resp.Nodes)),
+		MissingNodeIDs: resp.MissingNodeIDs,
+	}
+	for i, n := range resp.Nodes {
+		status.Nodes[i] = NodeMembership{
+			NodeID:         n.NodeID,
+			Membership:     n.Membership,
+			LivenessStatus: n.LivenessStatus,
+			IsLive:         n.IsLive,
+			Draining:       n.Draining,
+		}
+	}
+	return status, nil
+}
+
+// SQLNode is a node accepting SQL connections.
+type SQLNode struct {
+	NodeID roachpb.NodeID
+	// SQLAddress is the address advertised by the node for SQL connections.
+	SQLAddress string
+	Locality   roachpb.Locality
+}
+
+// SQLNodesUpdate is a change to the set of nodes accepting SQL connections.
+type SQLNodesUpdate struct {
+	// Added are the nodes that started accepting SQL connections, or whose
+	// address changed, ordered by node ID.
+	Added []SQLNode
+	// Removed are the nodes that stopped accepting SQL connections, ordered by
+	// node ID.
+	Removed []roachpb.NodeID
+}
+
+// WatchSQLNodes calls fn with every change to the set of nodes accepting SQL
+// connections in the given locality (e.g. "region=us-east1"; all nodes if
+// empty), starting with the full set, until the context is canceled or fn
+// returns an error, which is then returned.
+func (c *Client) WatchSQLNodes(
+	ctx context.Context, locality string, fn func(SQLNodesUpdate) error,
+) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.status.WatchSQLNodes(ctx, &serverpb.WatchSQLNodesRequest{Locality: locality})
+	if err != nil {
+		return errors.Wrap(err, "watching SQL nodes")
+	}
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return errors.New("SQL node stream ended unexpectedly")
+		}
+		if err != nil {
+			return errors.Wrap(err, "watching SQL nodes")
+		}
+		update := SQLNodesUpdate{
+			Added:   make([]SQLNode, len(resp.Added)),
+			Removed: resp.Removed,
+		}
+		for i, n := range resp.Added {
+			update.Added[i] = SQLNode{
+				NodeID:     n.NodeID,
+				SQLAddress: n.SQLAddress.String(),
+				Locality:   n.Locality,
+			}
+		}
+		if err := fn(update); err != nil {
+			return err
+		}
+	}
+}
+
+// SafeToShutdownResult is whether a node can be stopped without causing range
+// unavailability.
+type SafeToShutdownResult struct {
+	Safe bool
+	// UnavailableRangeCount is the number of ranges that would lose quorum if
+	// the node were stopped.
+	UnavailableRangeCount int64
+	// UnavailableRangeIDs are some of the ranges that would lose quorum, up to
+	// the number requested.
+	UnavailableRangeIDs []roachpb.RangeID
+	// NonLiveNodeIDs are the nodes whose lack of liveness causes the
+	// unavailability.
+	NonLiveNodeIDs []roachpb.NodeID
+}
+
+// SafeToShutdown returns whether the given node can be stopped right now
+// without causing range unavailability. If it can't, the result lists the
+// ranges that would become unavailable, up to the given number, and the nodes
+// whose lack of liveness causes it.
+func (c *Client) SafeToShutdown(
+	ctx context.Context, nodeID roachpb.NodeID, numRangeReport int,
+) (SafeToShutdownResult, error) {
+	resp, err := c.admin.SafeToShutdown(ctx, &serverpb.SafeToShutdownRequest{
+		NodeID:         nodeID,
+		NumRangeReport: int32(numRangeReport),
+	})
+	if err != nil {
+		return SafeToShutdownResult{}, errors.Wrapf(err, "checking whether n%d is safe to shut down", nodeID)
+	}
+	return SafeToShutdownResult{
+		Safe:                  resp.Safe,
+		UnavailableRangeCount: resp.UnavailableRangeCount,
+		UnavailableRangeIDs:   resp.UnavailableRangeIDs,
+		NonLiveNodeIDs:        resp.NonLiveNodeIDs,
+	}, nil
+}
+
+// safeToShutdownPollInterval is the interval at which WaitSafeToShutdown
+// checks whether a node is safe to shut down.
+const safeToShutdownPollInterval = time.Second
+
+// WaitSafeToShutdown waits until the given node can be stopped without
+// causing range unavailability, e.g. once the node it replaces in a rolling
+// restart is back, or until the context is canceled.
+func (c *Client) WaitSafeToShutdown(ctx context.Context, nodeID roachpb.NodeID) error {
+	var timer timeutil.Timer
+	defer timer.Stop()
+	for {
+		res, err := c.SafeToShutdown(ctx, nodeID, 0 /* numRangeReport */)
+		if err != nil {
+			return err
+		}
+		if res.Safe {
+			return nil
+		}
+		timer.Reset(safeToShutdownPollInterval)
+		select {
+		case <-timer.C:
+			timer.Read = true
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "n%d not safe to shut down (%d unavailable ranges)",
+				nodeID, res.UnavailableRangeCount)
+		}
+	}
+}
+
+// DecommissionOptions configures a decommission. See Client.Decommission.
+type DecommissionOptions struct {
+	// Reason is an optional, free-form explanation for the decommission (e.g.
+	// a ticket number), recorded in the liveness records of the nodes and in
+	// the event log.
+	Reason string
+	// IdempotencyToken identifies the decommission, so that a retry of it is
+	// applied at most once per node. If empty, one is generated.
+	IdempotencyToken string
+	// AllowUnderReplication allows the decommission to proceed even though it
+	// would leave fewer eligible nodes than needed to place the replicas of
+	// some zones, when server.decommission.under_replication_check.enabled is
+	// set.
+	AllowUnderReplication bool
+	// Timeout bounds the wait for the nodes to be decommissioned, if set. The
+	// decommission carries on in the background when it is exceeded, short of
+	// marking the nodes as decommissioned on clusters that can't carry it out
+	// with a job.
+	Timeout time.Duration
+}
+
+// DecommissionStatus is the progress of the decommission of a node.
+type DecommissionStatus struct {
+	NodeID roachpb.NodeID
+	IsLive bool
+	// ReplicaCount is the number of replicas left on the node.
+	ReplicaCount int64
+	Membership   livenesspb.MembershipStatus
+	Draining     bool
+}
+
+func makeDecommissionStatus(
+	statuses []serverpb.DecommissionStatusResponse_Status,
+) []DecommissionStatus {
+	res := make([]DecommissionStatus, len(statuses))
+	for i, s := range statuses {
+		res[i] = DecommissionStatus{
+			NodeID:       s.NodeID,
+			IsLive:       s.IsLive,
+			ReplicaCount: s.ReplicaCount,
+			Membership:   s.Membership,
+			Draining:     s.Draining,
+		}
+	}
+	return res
+}
+
+// DecommissionProgressFunc is called with the decommission status of the
+// nodes being decommissioned every time it changes.
+type DecommissionProgressFunc func([]DecommissionStatus)
+
+// decommissionPollInterval is the interval at which the progress of a
+// decommission is polled on clusters that can't carry it out with a job.
+const decommissionPollInterval = 5 * time.Second
+
+// isUnimplemented returns whether the error is the server's way of saying
+// that it doesn't implement a request, because it predates it.
+func isUnimplemented(err error) bool {
+	return grpcstatus.Code(err) == codes.Unimplemented
+}
+
+// Decommission decommissions the given nodes: it marks them as
+// decommissioning, hands the rest of the decommission over to a job, which
+// moves their replicas off of them and marks them as decommissioned, and waits
+// for the job to be done. Progress, if set, is called with the status of the
+// nodes every time it changes in the meantime. The ID of the job is returned,
+// even if the wait failed, in which case the job can still be followed along
+// with the other jobs.
+//
+// Clusters that can't carry out a decommission with a job yet (i.e. that
+// aren't upgraded to 23.2) are driven through the decommission by the client
+// instead, as the `cockroach node decommission` command does, in which case
+// no job ID is returned.
+func (c *Client) Decommission(
+	ctx context.Context,
+	nodeIDs []roachpb.NodeID,
+	opts DecommissionOptions,
+	progress DecommissionProgressFunc,
+) (jobID int64, _ error) {
+	if opts.IdempotencyToken == "" {
+		opts.IdempotencyToken = uuid.MakeV4().String()
+	}
+	resp, err := c.admin.Decommission(ctx, &serverpb.DecommissionRequest{
+		NodeIDs:               nodeIDs,
+		TargetMembership:      livenesspb.MembershipStatus_DECOMMISSIONING,
+		Reason:                opts.Reason,
+		AllowUnderReplication: opts.AllowUnderReplication,
+		IdempotencyToken:      opts.IdempotencyToken,
+		AsJob:                 true,
+	})
+	// Servers that predate decommission jobs ignore the request for one, and
+	// merely mark the nodes as decommissioning.
+	if isUnimplemented(err) || (err == nil && resp.JobID == 0) {
+		return 0, c.decommissionWithoutJob(ctx, nodeIDs, opts, progress)
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "marking nodes as decommissioning")
+	}
+	return resp.JobID, c.WaitForDecommissioned(ctx, nodeIDs, opts.Timeout, progress)
+}
+
+// decommissionWithoutJob decommissions the given nodes on a cluster that
+// can't carry out a decommission with a job: it marks them as decommissioning
+// until they have no replicas left, and then as decommissioned.
+func (c *Client) decommissionWithoutJob(
+	ctx context.Context,
+	nodeIDs []roachpb.NodeID,
+	opts DecommissionOptions,
+	progress DecommissionProgressFunc,
+) error {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+	p := progressReporter{fn: progress}
+	if err := pollDecommission(ctx, func() (bool, error) {
+		// Marking the nodes as decommissioning again is a no-op, which returns
+		// their decommission status.
+		resp, err := c.admin.Decommission(ctx, &serverpb.DecommissionRequest{
+			NodeIDs:               nodeIDs,
+			TargetMembership:      livenesspb.MembershipStatus_DECOMMISSIONING,
+			Reason:                opts.Reason,
+			AllowUnderReplication: opts.AllowUnderReplication,
+			IdempotencyToken:      opts.IdempotencyToken,
+		})
+		if err != nil {
+			return false, errors.Wrap(err, "marking nodes as decommissioning")
+		}
+		status := makeDecommissionStatus(resp.Status)
+		p.report(status)
+		for _, s := range status {
+			if s.ReplicaCount > 0 {
+				return false, nil
+			}
+		}
+		return true, nil
+	}); err != nil {
+		return err
+	}
+	if _, err := c.admin.Decommission(ctx, &serverpb.DecommissionRequest{
+		NodeIDs:          nodeIDs,
+		TargetMembership: livenesspb.MembershipStatus_DECOMMISSIONED,
+		Reason:           opts.Reason,
+		IdempotencyToken: opts.IdempotencyToken,
+	}); err != nil {
+		return errors.Wrap(err, "marking nodes as decommissioned")
+	}
+	return nil
+}
+
+// WaitForDecommissioned waits for the given nodes to be fully decommissioned,
+// for at most the given timeout if set. Progress, if set, is called with the
+// status of the nodes every time it changes in the meantime.
+func (c *Client) WaitForDecommissioned(
+	ctx context.Context,
+	nodeIDs []roachpb.NodeID,
+	timeout time.Duration,
+	progress DecommissionProgressFunc,
+) error {
+	stream, err := c.admin.WaitForDecommissioned(ctx, &serverpb.WaitForDecommissionedRequest{
+		NodeIDs: nodeIDs,
+		Timeout: timeout,
+	})
+	if err != nil {
+		if isUnimplemented(err) {
+			return c.pollDecommissioned(ctx, nodeIDs, timeout, progress)
+		}
+		return errors.Wrap(err, "waiting for nodes to be decommissioned")
+	}
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return errors.New("decommission status stream ended unexpectedly")
+		}
+		if err != nil {
+			// The server only tells it doesn't implement the stream once it
+			// is received from.
+			if isUnimplemented(err) {
+				return c.pollDecommissioned(ctx, nodeIDs, timeout, progress)
+			}
+			return errors.Wrap(err, "waiting for nodes to be decommissioned")
+		}
+		if progress != nil {
+			progress(makeDecommissionStatus(resp.Status))
+		}
+		if resp.Done {
+			return nil
+		}
+	}
+}
+
+// pollDecommissioned is WaitForDecommissioned for servers that predate the
+// streaming of decommission statuses.
+func (c *Client) pollDecommissioned(
+	ctx context.Context,
+	nodeIDs []roachpb.NodeID,
+	timeout time.Duration,
+	progress DecommissionProgressFunc,
+) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	p := progressReporter{fn: progress}
+	return pollDecommission(ctx, func() (bool, error) {
+		resp, err := c.admin.DecommissionStatus(ctx, &serverpb.DecommissionStatusRequest{NodeIDs: nodeIDs})
+		if err != nil {
+			return false, errors.Wrap(err, "fetching decommission status")
+		}
+		status := makeDecommissionStatus(resp.Status)
+		p.report(status)
+		for _, s := range status {
+			if !s.Membership.Decommissioned() {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+}
+
+// pollDecommission calls done every decommissionPollInterval until it returns
+// true or an error, or the context is canceled.
+func pollDecommission(ctx context.Context, done func() (bool, error)) error {
+	var timer timeutil.Timer
+	defer timer.Stop()
+	for {
+		if ok, err := done(); err != nil || ok {
+			return err
+		}
+		timer.Reset(decommissionPollInterval)
+		select {
+		case <-timer.C:
+			timer.Read = true
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "waiting for nodes to be decommissioned")
+		}
+	}
+}
+
+// progressReporter calls a DecommissionProgressFunc with the polled status of
+// the nodes being decommissioned, if it changed since the last call.
+type progressReporter struct {
+	fn   DecommissionProgressFunc
+	last []DecommissionStatus
+}
+
+func (p *progressReporter) report(status []DecommissionStatus) {
+	if p.fn == nil || (p.last != nil && reflect.DeepEqual(p.last, status)) {
+		return
+	}
+	p.last = status
+	p.fn(status)
+}
+
+// Recommission brings the given decommissioning nodes back into the cluster,
+// canceling their decommission.
+func (c *Client) Recommission(ctx context.Context, nodeIDs []roachpb.NodeID, reason string) error {
+	if _, err := c.admin.Decommission(ctx, &serverpb.DecommissionRequest{
+		NodeIDs:          nodeIDs,
+		TargetMembership: livenesspb.MembershipStatus_ACTIVE,
+		Reason:           reason,
+		IdempotencyToken: uuid.MakeV4().String(),
+	}); err != nil {
+		return errors.Wrap(err, "recommissioning nodes")
+	}
+	return nil
+}
+
+// SimulatedNode is a node of the cluster at the end of a simulated plan.
+type SimulatedNode struct {
+	// NodeID is the ID of the node. The nodes added by the plan are given IDs
+	// past those of the existing nodes.
+	NodeID roachpb.NodeID
+	// Added is whether the node is added by the plan.
+	Added bool
+	// Removed is whether the node is decommissioned by the plan, or is already
+	// decommissioning or dead.
+	Removed  bool
+	Locality string
+	// ReplicasBefore and ReplicasAfter are the number of replicas on the node
+	// now, and at the end of the plan.
+	ReplicasBefore, ReplicasAfter int64
+}
+
+// SimulatedViolation is a range a simulated plan would leave in violation.
+type SimulatedViolation struct {
+	RangeID roachpb.RangeID
+	// Reason is why the range would be in violation, e.g. because it would be
+	// under-replicated.
+	Reason string
+}
+
+// SimulationResult is the outcome of a simulated plan of membership changes.
+type SimulationResult struct {
+	// Nodes are the nodes of the cluster at the end of the plan, ordered by
+	// node ID.
+	Nodes []SimulatedNode
+	// Violations are the ranges the plan would leave in violation, ordered by
+	// range ID.
+	Violations []SimulatedViolation
+	// RangesSimulated is the number of ranges the simulation was run on.
+	RangesSimulated int64
+}
+
+// SimulateMembershipChanges predicts the distribution of the replicas and the
+// ranges left in violation should the given nodes be decommissioned and nodes
+// be added in the given localities (e.g. "region=us-east1,zone=a"), one per
+// locality. Nothing is changed in the cluster.
+func (c *Client) SimulateMembershipChanges(
+	ctx context.Context, decommission []roachpb.NodeID, addLocalities []string,
+) (SimulationResult, error) {
+	resp, err := c.admin.SimulateMembershipChanges(ctx, &serverpb.SimulateMembershipChangesRequest{
+		DecommissionNodeIDs: decommission,
+		AddNodeLocalities:   addLocalities,
+	})
+	if err != nil {
+		return SimulationResult{}, errors.Wrap(err, "simulating membership changes")
+	}
+	res := SimulationResult{
+		Nodes:           make([]SimulatedNode, len(resp.Nodes)),
+		Violations:      make([]SimulatedViolation, len(resp.Violations)),
+		RangesSimulated: resp.RangesSimulated,
+	}
+	for i, n := range resp.Nodes {
+		res.Nodes[i] = SimulatedNode{
+			NodeID:         n.NodeID,
+			Added:          n.Added,
+			Removed:        n.Removed,
+			Locality:       n.Locality,
+			ReplicasBefore: n.ReplicasBefore,
+			ReplicasAfter:  n.ReplicasAfter,
+		}
+	}
+	for i, v := range resp.Violations {
+		res.Violations[i] = SimulatedViolation{RangeID: v.RangeID, Reason: v.Reason}
+	}
+	return res, nil
+}
@@ -0,0 +1,155 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package livenessclient_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/rpc"
+	"github.com/cockroachdb/cockroach/pkg/server/livenessclient"
+	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
+	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/cockroach/pkg/testutils/serverutils"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+func TestClient(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := serverutils.StartNewTestCluster(t, 3, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	ts := tc.Server(0)
+	conn, err := ts.RPCContext().GRPCDialNode(
+		ts.RPCAddr(), ts.NodeID(), rpc.DefaultClass).Connect(ctx)
+	require.NoError(t, err)
+	client := livenessclient.New(conn)
+
+	nodes, err := client.Vitality(ctx)
+	require.NoError(t, err)
+	require.Len(t, nodes, 3)
+	for i, node := range nodes {
+		require.Equal(t, roachpb.NodeID(i+1), node.NodeID)
+	}
+	_, err = client.NodeVitality(ctx, 4)
+	require.Error(t, err)
+	status, err := client.MembershipStatus(ctx, 1, 4)
+	require.NoError(t, err)
+	require.Len(t, status.Nodes, 1)
+	require.Equal(t, []roachpb.NodeID{4}, status.MissingNodeIDs)
+
+	// With manual replication, all the ranges are on n1, so stopping n3 leaves
+	// them available.
+	targetID := tc.Server(2).NodeID()
+	res, err := client.SafeToShutdown(ctx, targetID, 10 /* numRangeReport */)
+	require.NoError(t, err)
+	require.True(t, res.Safe, "%+v", res)
+	require.NoError(t, client.WaitSafeToShutdown(ctx, targetID))
+
+	// The first update lists the SQL nodes that are live at the time.
+	errDone := errors.New("done")
+	require.ErrorIs(t, client.WatchSQLNodes(ctx, "" /* locality */, func(
+		update livenessclient.SQLNodesUpdate,
+	) error {
+		require.NotEmpty(t, update.Added)
+		return errDone
+	}), errDone)
+
+	// n3 has no replicas, so it is decommissioned right away.
+	var updates int
+	_, err = client.Decommission(ctx, []roachpb.NodeID{targetID}, livenessclient.DecommissionOptions{
+		Reason: "scale down",
+	}, func(status []livenessclient.DecommissionStatus) {
+		require.Len(t, status, 1)
+		require.Equal(t, targetID, status[0].NodeID)
+		updates++
+	})
+	require.NoError(t, err)
+	require.NotZero(t, updates)
+
+	// The vitality is served from the liveness cache of n1, which may lag the
+	// liveness records the decommission was tracked with.
+	testutils.SucceedsSoon(t, func() error {
+		node, err := client.NodeVitality(ctx, targetID)
+		if err != nil {
+			return err
+		}
+		if !node.Membership.Decommissioned() {
+			return errors.Errorf("n%d is %s", targetID, node.Membership)
+		}
+		require.Equal(t, "scale down", node.Reason)
+		return nil
+	})
+}
+
+// legacyAdminClient is an admin client of a server that predates decommission
+// jobs, and records the membership changes it is asked to make.
+type legacyAdminClient struct {
+	serverpb.AdminClient
+	requests []livenesspb.MembershipStatus
+}
+
+func (c *legacyAdminClient) Decommission(
+	_ context.Context, req *serverpb.DecommissionRequest, _ ...grpc.CallOption,
+) (*serverpb.DecommissionStatusResponse, error) {
+	if req.AsJob {
+		return nil, grpcstatus.Error(codes.Unimplemented, "unknown field")
+	}
+	c.requests = append(c.requests, req.TargetMembership)
+	resp := &serverpb.DecommissionStatusResponse{}
+	for _, nodeID := range req.NodeIDs {
+		resp.Status = append(resp.Status, serverpb.DecommissionStatusResponse_Status{
+			NodeID:     nodeID,
+			Membership: req.TargetMembership,
+		})
+	}
+	return resp, nil
+}
+
+// TestClientDecommissionWithoutJob verifies that the client drives the
+// decommission itself on clusters that can't carry it out with a job.
+func TestClientDecommissionWithoutJob(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	admin := &legacyAdminClient{}
+	client := livenessclient.NewFromClients(admin, nil /* status */)
+	var updates int
+	jobID, err := client.Decommission(context.Background(), []roachpb.NodeID{3},
+		livenessclient.DecommissionOptions{}, func(status []livenessclient.DecommissionStatus) {
+			require.Equal(t, []livenessclient.DecommissionStatus{{
+				NodeID:     3,
+				Membership: livenesspb.MembershipStatus_DECOMMISSIONING,
+			}}, status)
+			updates++
+		})
+	require.NoError(t, err)
+	require.Zero(t, jobID)
+	require.Equal(t, 1, updates)
+	require.Equal(t, []livenesspb.MembershipStatus{
+		livenesspb.MembershipStatus_DECOMMISSIONING,
+		livenesspb.MembershipStatus_DECOMMISSIONED,
+	}, admin.requests)
+}
@@ -18,6 +18,8 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/loqrecovery"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/loqrecovery/loqrecoverypb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/server/telemetry"
 	"github.com/cockroachdb/cockroach/pkg/sql/isql"
 	"github.com/cockroachdb/cockroach/pkg/sql/sessiondata"
 	"github.com/cockroachdb/cockroach/pkg/storage"
@@ -162,6 +164,15 @@ func maybeRunLossOfQuorumRecoveryCleanup(
 			Multiplier:     2,
 		}
 		for r := retry.StartWithCtx(ctx, retryOpts); r.Next(); {
+			// Nodes listed as dead by the operator may never have joined the
+			// cluster, or may have lost their record together with the liveness
+			// range. There's nothing to carry over for them.
+			nodeIDs, err := nodesWithLivenessRecords(ctx, server, cleanup.DecommissionedNodeIDs)
+			if err != nil {
+				log.Infof(ctx,
+					"loss of quorum recovery cleanup failed to read liveness records, this is ok as cluster might not be healed yet: %s", err)
+				continue
+			}
 			// Nodes are already dead, but are in active state. Internal checks doesn't
 			// allow us throwing nodes away, and they need to go through legal state
 			// transitions within liveness to succeed. To achieve that we mark nodes as
@@ -172,20 +183,19 @@ func maybeRunLossOfQuorumRecoveryCleanup(
 			// Mind that it is valid to mark decommissioned nodes as decommissioned and
 			// that would result in a noop, so it is safe to always go through this
 			// cycle without prior checks for current state.
-			err := server.Decommission(ctx, livenesspb.MembershipStatus_DECOMMISSIONING,
-				cleanup.DecommissionedNodeIDs)
+			err = server.Decommission(ctx, livenesspb.MembershipStatus_DECOMMISSIONING, nodeIDs)
 			if err != nil {
 				log.Infof(ctx,
 					"loss of quorum recovery cleanup failed to decommissioning dead nodes, this is ok as cluster might not be healed yet: %s", err)
 				continue
 			}
-			err = server.Decommission(ctx, livenesspb.MembershipStatus_DECOMMISSIONED,
-				cleanup.DecommissionedNodeIDs)
+			err = server.Decommission(ctx, livenesspb.MembershipStatus_DECOMMISSIONED, nodeIDs)
 			if err != nil {
 				log.Infof(ctx,
 					"loss of quorum recovery cleanup failed to decommissioning dead nodes, this is ok as cluster might not be healed yet: %s", err)
 				continue
 			}
+			telemetry.Inc(telemetryDecommissionLossOfQuorum)
 			if err = loqrecovery.RemoveCleanupActionsInfo(ctx, actionsSource); err != nil {
 				log.Infof(ctx, "failed to remove ")
 			}
@@ -194,3 +204,27 @@ func maybeRunLossOfQuorumRecoveryCleanup(
 		log.Infof(ctx, "loss of quorum recovery cleanup finished decommissioning removed nodes")
 	})
 }
+
+// nodesWithLivenessRecords returns the subset of the given nodes that have a
+// liveness record.
+func nodesWithLivenessRecords(
+	ctx context.Context, server *Server, nodeIDs []roachpb.NodeID,
+) ([]roachpb.NodeID, error) {
+	livenesses, err := server.nodeLiveness.GetLivenessesFromKV(ctx)
+	if err != nil {
+		return nil, err
+	}
+	exists := make(map[roachpb.NodeID]struct{}, len(livenesses))
+	for _, l := range livenesses {
+		exists[l.NodeID] = struct{}{}
+	}
+	var res []roachpb.NodeID
+	for _, nodeID := range nodeIDs {
+		if _, ok := exists[nodeID]; ok {
+			res = append(res, nodeID)
+		} else {
+			log.Infof(ctx, "loss of quorum recovery skipping n%d which has no liveness record", nodeID)
+		}
+	}
+	return res, nil
+}
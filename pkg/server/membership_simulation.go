@@ -0,0 +1,448 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
+	"github.com/cockroachdb/cockroach/pkg/util/rangedesc"
+	"github.com/cockroachdb/errors"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+// membershipPlan is a set of membership changes to simulate.
+type membershipPlan struct {
+	// decommission are the nodes to decommission.
+	decommission []roachpb.NodeID
+	// add are the localities of the nodes to add, one per node.
+	add []roachpb.Locality
+}
+
+// simNode is a node of a membershipModel.
+type simNode struct {
+	nodeID   roachpb.NodeID
+	locality roachpb.Locality
+	// live is whether the node is live, so that its replicas count towards
+	// the quorum of their ranges.
+	live bool
+	// available is whether the node holds replicas at the end of the plan.
+	available bool
+	added     bool
+	// replicasBefore is the number of replicas on the node before the plan.
+	replicasBefore int
+	// ranges are the indexes, in membershipModel.ranges, of the ranges with a
+	// replica on the node.
+	ranges map[int]struct{}
+	// heapIdx is the index of the node in the nodeHeap used to rebalance the
+	// replicas, or -1 if it isn't in it.
+	heapIdx int
+}
+
+// simRange is a range of a membershipModel.
+type simRange struct {
+	rangeID roachpb.RangeID
+	// nodes are the nodes with a replica of the range.
+	nodes []roachpb.NodeID
+	// voters are the nodes with a voting replica of the range.
+	voters []roachpb.NodeID
+	// numReplicas is the number of replicas the zone configuration of the
+	// range calls for.
+	numReplicas int
+}
+
+// membershipModel is an in-memory copy of the nodes and ranges of the cluster,
+// on which plans of membership changes are simulated. The simulation is a
+// coarse approximation of what the allocator does: it replaces the replicas on
+// the nodes that go away with replicas on the nodes that maximize the
+// diversity of the range and hold the fewest replicas, and then balances the
+// replica counts of the nodes. It does not account for constraints, lease
+// preferences, or disk usage.
+type membershipModel struct {
+	nodes  map[roachpb.NodeID]*simNode
+	ranges []simRange
+}
+
+func newMembershipModel() *membershipModel {
+	return &membershipModel{nodes: make(map[roachpb.NodeID]*simNode)}
+}
+
+// addNode adds a node to the model. A node that is available holds replicas
+// at the end of the plan unless the plan decommissions it.
+func (m *membershipModel) addNode(
+	nodeID roachpb.NodeID, locality roachpb.Locality, live, available bool,
+) {
+	m.nodes[nodeID] = &simNode{
+		nodeID:    nodeID,
+		locality:  locality,
+		live:      live,
+		available: available,
+		ranges:    make(map[int]struct{}),
+		heapIdx:   -1,
+	}
+}
+
+// clearRanges removes all the ranges from the model.
+func (m *membershipModel) clearRanges() {
+	m.ranges = m.ranges[:0]
+	for _, n := range m.nodes {
+		n.replicasBefore = 0
+		n.ranges = make(map[int]struct{})
+	}
+}
+
+// addRange adds a range to the model. Its replicas on nodes unknown to the
+// model are ignored.
+func (m *membershipModel) addRange(desc *roachpb.RangeDescriptor, numReplicas int) {
+	idx := len(m.ranges)
+	r := simRange{rangeID: desc.RangeID, numReplicas: numReplicas}
+	for _, rd := range desc.Replicas().Descriptors() {
+		n, ok := m.nodes[rd.NodeID]
+		if !ok {
+			continue
+		}
+		if _, ok := n.ranges[idx]; ok {
+			continue
+		}
+		n.ranges[idx] = struct{}{}
+		n.replicasBefore++
+		r.nodes = append(r.nodes, rd.NodeID)
+		if rd.IsAnyVoter() {
+			r.voters = append(r.voters, rd.NodeID)
+		}
+	}
+	m.ranges = append(m.ranges, r)
+}
+
+// simulate carries out the given plan on the model, which it modifies, and
+// returns the resulting distribution of the replicas along with the ranges
+// left in violation.
+func (m *membershipModel) simulate(
+	ctx context.Context, plan membershipPlan,
+) (*serverpb.SimulateMembershipChangesResponse, error) {
+	var maxNodeID roachpb.NodeID
+	for nodeID := range m.nodes {
+		if nodeID > maxNodeID {
+			maxNodeID = nodeID
+		}
+	}
+	for _, nodeID := range plan.decommission {
+		n, ok := m.nodes[nodeID]
+		if !ok {
+			return nil, errors.Errorf("n%d is not a member of the cluster", nodeID)
+		}
+		n.available = false
+	}
+	for i, locality := range plan.add {
+		nodeID := maxNodeID + roachpb.NodeID(i+1)
+		m.addNode(nodeID, locality, true /* live */, true /* available */)
+		m.nodes[nodeID].added = true
+	}
+
+	resp := &serverpb.SimulateMembershipChangesResponse{RangesSimulated: int64(len(m.ranges))}
+	for idx := range m.ranges {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if reason := m.replaceUnavailable(idx); reason != "" {
+			resp.Violations = append(resp.Violations, serverpb.SimulateMembershipChangesResponse_Violation{
+				RangeID: m.ranges[idx].rangeID,
+				Reason:  reason,
+			})
+		}
+	}
+	if err := m.rebalance(ctx); err != nil {
+		return nil, err
+	}
+
+	for _, n := range m.nodes {
+		resp.Nodes = append(resp.Nodes, serverpb.SimulateMembershipChangesResponse_Node{
+			NodeID:         n.nodeID,
+			Added:          n.added,
+			Removed:        !n.available,
+			Locality:       n.locality.String(),
+			ReplicasBefore: int64(n.replicasBefore),
+			ReplicasAfter:  int64(len(n.ranges)),
+		})
+	}
+	sort.Slice(resp.Nodes, func(i, j int) bool {
+		return resp.Nodes[i].NodeID < resp.Nodes[j].NodeID
+	})
+	sort.Slice(resp.Violations, func(i, j int) bool {
+		return resp.Violations[i].RangeID < resp.Violations[j].RangeID
+	})
+	return resp, nil
+}
+
+// replaceUnavailable moves the replicas of the range at the given index off of
+// the nodes that are unavailable at the end of the plan, and returns why the
+// range is left in violation, if it is.
+func (m *membershipModel) replaceUnavailable(idx int) string {
+	r := &m.ranges[idx]
+	var liveVoters int
+	for _, nodeID := range r.voters {
+		if m.nodes[nodeID].live {
+			liveVoters++
+		}
+	}
+	if len(r.voters) > 0 && liveVoters < len(r.voters)/2+1 {
+		// Without a quorum, the range can't change its replicas.
+		return fmt.Sprintf("lost quorum: %d of %d voters are on nodes that aren't live",
+			len(r.voters)-liveVoters, len(r.voters))
+	}
+
+	kept := r.nodes[:0]
+	for _, nodeID := range r.nodes {
+		if n := m.nodes[nodeID]; n.available {
+			kept = append(kept, nodeID)
+		} else {
+			delete(n.ranges, idx)
+		}
+	}
+	r.nodes = kept
+	for len(r.nodes) < r.numReplicas {
+		target := m.pickTarget(r)
+		if target == nil {
+			return fmt.Sprintf("under-replicated: %d of %d replicas", len(r.nodes), r.numReplicas)
+		}
+		target.ranges[idx] = struct{}{}
+		r.nodes = append(r.nodes, target.nodeID)
+	}
+	return ""
+}
+
+// pickTarget returns the available node without a replica of the given range
+// that maximizes the diversity of the range, breaking ties in favor of the
+// nodes with the fewest replicas, or nil if there is none.
+func (m *membershipModel) pickTarget(r *simRange) *simNode {
+	var best *simNode
+	var bestDiversity float64
+	for _, n := range m.nodes {
+		if !n.available || r.has(n.nodeID) {
+			continue
+		}
+		diversity := m.diversity(r, n, 0 /* exclude */)
+		if best == nil || diversity > bestDiversity ||
+			(diversity == bestDiversity && fewerReplicas(n, best)) {
+			best, bestDiversity = n, diversity
+		}
+	}
+	return best
+}
+
+// rebalance moves replicas from the available nodes with the most replicas to
+// the ones with the fewest, as long as their counts differ by more than one and
+// the moves don't reduce the diversity of the ranges. The nodes are kept in a
+// heap by replica count, the nodes no replica can be moved off of are dropped
+// from it, and there are at most as many moves as there are replicas on the
+// available nodes, which bounds the work on large clusters.
+func (m *membershipModel) rebalance(ctx context.Context) error {
+	var h nodeHeap
+	var budget int
+	for _, n := range m.nodes {
+		if n.available {
+			n.heapIdx = len(h)
+			h = append(h, n)
+			budget += len(n.ranges)
+		}
+	}
+	heap.Init(&h)
+	targets := append([]*simNode(nil), h...)
+	for ; h.Len() > 0 && budget > 0; budget-- {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		src := h[0]
+		dst := m.moveReplicaOff(src, targets)
+		if dst == nil {
+			// The node can't shed replicas to the less loaded nodes, whose
+			// counts only grow from now on.
+			heap.Pop(&h)
+			continue
+		}
+		heap.Fix(&h, src.heapIdx)
+		if dst.heapIdx >= 0 {
+			heap.Fix(&h, dst.heapIdx)
+		}
+	}
+	return nil
+}
+
+// moveReplicaOff moves a single replica off of the given node to the least
+// loaded of the given nodes it can be moved to, and returns that node, or nil
+// if it found none.
+func (m *membershipModel) moveReplicaOff(src *simNode, targets []*simNode) *simNode {
+	sort.Slice(targets, func(i, j int) bool {
+		return fewerReplicas(targets[i], targets[j])
+	})
+	for idx := range src.ranges {
+		r := &m.ranges[idx]
+		srcDiversity := m.diversity(r, src, src.nodeID)
+		for _, dst := range targets {
+			if len(src.ranges)-len(dst.ranges) <= 1 {
+				break
+			}
+			if r.has(dst.nodeID) || m.diversity(r, dst, src.nodeID) < srcDiversity {
+				continue
+			}
+			delete(src.ranges, idx)
+			dst.ranges[idx] = struct{}{}
+			for j, nodeID := range r.nodes {
+				if nodeID == src.nodeID {
+					r.nodes[j] = dst.nodeID
+				}
+			}
+			return dst
+		}
+	}
+	return nil
+}
+
+// nodeHeap is a max-heap of nodes by replica count, see fewerReplicas.
+type nodeHeap []*simNode
+
+var _ heap.Interface = (*nodeHeap)(nil)
+
+func (h nodeHeap) Len() int           { return len(h) }
+func (h nodeHeap) Less(i, j int) bool { return fewerReplicas(h[j], h[i]) }
+func (h nodeHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].heapIdx, h[j].heapIdx = i, j
+}
+
+func (h *nodeHeap) Push(x interface{}) {
+	n := x.(*simNode)
+	n.heapIdx = len(*h)
+	*h = append(*h, n)
+}
+
+func (h *nodeHeap) Pop() interface{} {
+	old := *h
+	n := old[len(old)-1]
+	n.heapIdx = -1
+	*h = old[:len(old)-1]
+	return n
+}
+
+// diversity returns the sum of the diversity scores of the given node against
+// the nodes with a replica of the range, other than the excluded one.
+func (m *membershipModel) diversity(r *simRange, n *simNode, exclude roachpb.NodeID) float64 {
+	var diversity float64
+	for _, nodeID := range r.nodes {
+		if nodeID == exclude || nodeID == n.nodeID {
+			continue
+		}
+		diversity += n.locality.DiversityScore(m.nodes[nodeID].locality)
+	}
+	return diversity
+}
+
+// has returns whether the range has a replica on the given node.
+func (r *simRange) has(nodeID roachpb.NodeID) bool {
+	for _, id := range r.nodes {
+		if id == nodeID {
+			return true
+		}
+	}
+	return false
+}
+
+// fewerReplicas orders nodes by replica count, then by node ID.
+func fewerReplicas(a, b *simNode) bool {
+	if len(a.ranges) != len(b.ranges) {
+		return len(a.ranges) < len(b.ranges)
+	}
+	return a.nodeID < b.nodeID
+}
+
+// SimulateMembershipChanges simulates the given plan of membership changes on
+// a copy of the liveness records and range descriptors of the cluster. See
+// membershipModel for how faithful the simulation is. The error returned is a
+// gRPC error.
+func (s *Server) SimulateMembershipChanges(
+	ctx context.Context, plan membershipPlan,
+) (*serverpb.SimulateMembershipChangesResponse, error) {
+	now := s.clock.Now()
+	livenesses, err := s.nodeLiveness.GetLivenessesFromKVAsOf(ctx, now)
+	if err != nil {
+		return nil, grpcstatus.Error(codes.Unavailable, err.Error())
+	}
+	m := newMembershipModel()
+	threshold := liveness.TimeUntilStoreDead.Get(&s.st.SV)
+	for _, l := range livenesses {
+		if l.Membership.Decommissioned() {
+			continue
+		}
+		var locality roachpb.Locality
+		if desc, err := s.gossip.GetNodeDescriptor(l.NodeID); err == nil {
+			locality = desc.Locality
+		}
+		// NB: the status of a dead node that isn't active is DECOMMISSIONED.
+		status := l.Status(now, l.TimeUntilDead(now, threshold))
+		m.addNode(l.NodeID, locality, l.IsLive(now),
+			l.Membership.Active() && status != livenesspb.NodeLivenessStatus_DEAD)
+	}
+
+	// The replication factors of the ranges come from the span configs known
+	// to the first store of this node.
+	var evalStore *kvserver.Store
+	err = s.node.stores.VisitStores(func(s *kvserver.Store) error {
+		if evalStore == nil {
+			evalStore = s
+		}
+		return nil
+	})
+	if err == nil && evalStore == nil {
+		err = errors.Errorf("n%d has no initialized store", s.NodeID())
+	}
+	if err != nil {
+		return nil, grpcstatus.Error(codes.NotFound, err.Error())
+	}
+	confReader, err := evalStore.GetConfReader(ctx)
+	if err != nil {
+		return nil, grpcstatus.Error(codes.Unavailable, err.Error())
+	}
+
+	// The descriptors are added to the model page by page, so that only the
+	// model, and not every descriptor, is held in memory.
+	const pageSize = 10000
+	if err := rangedesc.NewScanner(s.db).Scan(ctx, pageSize, m.clearRanges,
+		keys.EverythingSpan, func(page ...roachpb.RangeDescriptor) error {
+			for i := range page {
+				conf, err := confReader.GetSpanConfigForKey(ctx, page[i].StartKey)
+				if err != nil {
+					return err
+				}
+				m.addRange(&page[i], int(conf.NumReplicas))
+			}
+			return nil
+		}); err != nil {
+		return nil, grpcstatus.Error(codes.Internal, err.Error())
+	}
+
+	resp, err := m.simulate(ctx, plan)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, grpcstatus.FromContextError(ctxErr).Err()
+		}
+		return nil, grpcstatus.Error(codes.InvalidArgument, err.Error())
+	}
+	return resp, nil
+}
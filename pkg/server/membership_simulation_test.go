@@ -0,0 +1,158 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
+	"github.com/cockroachdb/cockroach/pkg/testutils/serverutils"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+func TestMembershipModelSimulate(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	zone := func(z string) roachpb.Locality {
+		return roachpb.Locality{Tiers: []roachpb.Tier{{Key: "zone", Value: z}}}
+	}
+	desc := func(rangeID roachpb.RangeID, nodeIDs ...roachpb.NodeID) *roachpb.RangeDescriptor {
+		d := &roachpb.RangeDescriptor{RangeID: rangeID}
+		for _, nodeID := range nodeIDs {
+			d.AddReplica(nodeID, roachpb.StoreID(nodeID), roachpb.VOTER_FULL)
+		}
+		return d
+	}
+	// Five nodes across three zones, with n5 dead already. Each range has
+	// replicas on n1, n2 and n3 or n4.
+	makeModel := func() *membershipModel {
+		m := newMembershipModel()
+		m.addNode(1, zone("a"), true /* live */, true /* available */)
+		m.addNode(2, zone("b"), true /* live */, true /* available */)
+		m.addNode(3, zone("c"), true /* live */, true /* available */)
+		m.addNode(4, zone("c"), true /* live */, true /* available */)
+		m.addNode(5, zone("c"), false /* live */, false /* available */)
+		for i := 1; i <= 20; i++ {
+			third := roachpb.NodeID(3 + i%2)
+			m.addRange(desc(roachpb.RangeID(i), 1, 2, third), 3 /* numReplicas */)
+		}
+		return m
+	}
+
+	replicasAfter := func(resp *serverpb.SimulateMembershipChangesResponse) map[roachpb.NodeID]int64 {
+		counts := make(map[roachpb.NodeID]int64)
+		var total int64
+		for _, n := range resp.Nodes {
+			counts[n.NodeID] = n.ReplicasAfter
+			total += n.ReplicasAfter
+		}
+		// The replication factor of the ranges is preserved.
+		require.Equal(t, int64(60), total)
+		return counts
+	}
+
+	// Decommissioning n4 moves its replicas to n3, the only other node in its
+	// zone that doesn't already hold them, and n5 is reported as removed.
+	resp, err := makeModel().simulate(ctx, membershipPlan{decommission: []roachpb.NodeID{4}})
+	require.NoError(t, err)
+	require.EqualValues(t, 20, resp.RangesSimulated)
+	require.Empty(t, resp.Violations)
+	require.Equal(t, map[roachpb.NodeID]int64{1: 20, 2: 20, 3: 20, 4: 0, 5: 0}, replicasAfter(resp))
+	require.True(t, resp.Nodes[3].Removed)
+	require.True(t, resp.Nodes[4].Removed)
+
+	// Decommissioning n3 and n4 leaves no third node, unless one is added, in
+	// which case it takes over their replicas.
+	resp, err = makeModel().simulate(ctx, membershipPlan{decommission: []roachpb.NodeID{3, 4}})
+	require.NoError(t, err)
+	require.Len(t, resp.Violations, 20)
+	require.Equal(t, "under-replicated: 2 of 3 replicas", resp.Violations[0].Reason)
+
+	resp, err = makeModel().simulate(ctx, membershipPlan{
+		decommission: []roachpb.NodeID{3, 4},
+		add:          []roachpb.Locality{zone("d")},
+	})
+	require.NoError(t, err)
+	require.Empty(t, resp.Violations)
+	require.Len(t, resp.Nodes, 6)
+	require.True(t, resp.Nodes[5].Added)
+	require.Equal(t, "zone=d", resp.Nodes[5].Locality)
+	require.Equal(t, map[roachpb.NodeID]int64{1: 20, 2: 20, 3: 0, 4: 0, 5: 0, 6: 20}, replicasAfter(resp))
+
+	// Added nodes in existing zones receive replicas through rebalancing, as
+	// long as that doesn't hurt diversity.
+	resp, err = makeModel().simulate(ctx, membershipPlan{add: []roachpb.Locality{zone("c"), zone("c")}})
+	require.NoError(t, err)
+	require.Empty(t, resp.Violations)
+	counts := replicasAfter(resp)
+	require.Equal(t, int64(20), counts[1])
+	require.Equal(t, int64(20), counts[2])
+	for _, nodeID := range []roachpb.NodeID{3, 4, 6, 7} {
+		require.Equal(t, int64(5), counts[nodeID], "n%d", nodeID)
+	}
+
+	// A range whose voters are mostly on nodes that aren't live is stuck.
+	m := makeModel()
+	m.addRange(desc(21, 1, 5), 3 /* numReplicas */)
+	resp, err = m.simulate(ctx, membershipPlan{})
+	require.NoError(t, err)
+	require.Len(t, resp.Violations, 1)
+	require.Equal(t, roachpb.RangeID(21), resp.Violations[0].RangeID)
+	require.Contains(t, resp.Violations[0].Reason, "lost quorum")
+
+	_, err = makeModel().simulate(ctx, membershipPlan{decommission: []roachpb.NodeID{42}})
+	require.Error(t, err)
+}
+
+func TestAdminAPISimulateMembershipChanges(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := serverutils.StartNewTestCluster(t, 3, base.TestClusterArgs{})
+	defer tc.Stopper().Stop(ctx)
+	s := tc.Server(0).(*TestServer)
+
+	// With three nodes, decommissioning one leaves the ranges under-replicated,
+	// unless a node is added to replace it.
+	resp, err := s.admin.SimulateMembershipChanges(ctx, &serverpb.SimulateMembershipChangesRequest{
+		DecommissionNodeIDs: []roachpb.NodeID{3},
+	})
+	require.NoError(t, err)
+	require.NotZero(t, resp.RangesSimulated)
+	require.NotEmpty(t, resp.Violations)
+	require.Len(t, resp.Nodes, 3)
+	require.True(t, resp.Nodes[2].Removed)
+	require.Zero(t, resp.Nodes[2].ReplicasAfter)
+
+	resp, err = s.admin.SimulateMembershipChanges(ctx, &serverpb.SimulateMembershipChangesRequest{
+		DecommissionNodeIDs: []roachpb.NodeID{3},
+		AddNodeLocalities:   []string{"region=test"},
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Nodes, 4)
+	require.True(t, resp.Nodes[3].Added)
+	require.NotZero(t, resp.Nodes[3].ReplicasAfter)
+
+	_, err = s.admin.SimulateMembershipChanges(ctx, &serverpb.SimulateMembershipChangesRequest{
+		AddNodeLocalities: []string{"bogus"},
+	})
+	require.Equal(t, codes.InvalidArgument, grpcstatus.Code(err))
+}
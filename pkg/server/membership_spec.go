@@ -0,0 +1,402 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/kv"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
+	"github.com/cockroachdb/cockroach/pkg/settings"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/stop"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+// membershipReconcileInterval is the interval at which each server reconciles
+// the membership of the nodes with the membership spec of the cluster.
+var membershipReconcileInterval = settings.RegisterDurationSetting(
+	settings.SystemOnly,
+	"server.membership.reconcile_interval",
+	"the interval at which to reconcile the membership of the nodes with the "+
+		"membership spec declared by an orchestrator",
+	10*time.Second,
+	settings.PositiveDuration,
+)
+
+// getMembershipSpecRecord reads the membership spec of the cluster and its
+// status. Both are empty if no spec was ever set.
+func getMembershipSpecRecord(
+	ctx context.Context, txn *kv.Txn,
+) (serverpb.MembershipSpecRecord, error) {
+	var record serverpb.MembershipSpecRecord
+	err := txn.GetProto(ctx, keys.MembershipSpecKey, &record)
+	return record, err
+}
+
+// validateMembershipSpec returns an error if the given spec can't be
+// reconciled with the given liveness records.
+func validateMembershipSpec(
+	spec serverpb.MembershipSpec, livenesses map[roachpb.NodeID]livenesspb.Liveness,
+) error {
+	seen := make(map[roachpb.NodeID]struct{})
+	check := func(nodeID roachpb.NodeID) error {
+		if _, ok := seen[nodeID]; ok {
+			return grpcstatus.Errorf(codes.InvalidArgument, "n%d is listed more than once", nodeID)
+		}
+		seen[nodeID] = struct{}{}
+		if _, ok := livenesses[nodeID]; !ok {
+			return grpcstatus.Errorf(codes.NotFound, "n%d not found", nodeID)
+		}
+		return nil
+	}
+	for _, nodeID := range spec.ActiveNodeIDs {
+		if err := check(nodeID); err != nil {
+			return err
+		}
+		if livenesses[nodeID].Membership.Decommissioned() {
+			return grpcstatus.Errorf(codes.FailedPrecondition,
+				"n%d is decommissioned and cannot be made active", nodeID)
+		}
+	}
+	for _, nodeID := range spec.DecommissionedNodeIDs {
+		if err := check(nodeID); err != nil {
+			return err
+		}
+	}
+
+	windows := make(map[roachpb.NodeID]struct{}, len(spec.MaintenanceWindows))
+	for _, w := range spec.MaintenanceWindows {
+		if _, ok := windows[w.NodeID]; ok {
+			return grpcstatus.Errorf(codes.InvalidArgument, "n%d has more than one maintenance window", w.NodeID)
+		}
+		windows[w.NodeID] = struct{}{}
+		if !w.Start.Before(w.End) {
+			return grpcstatus.Errorf(codes.InvalidArgument,
+				"the maintenance window of n%d must end after it starts", w.NodeID)
+		}
+	}
+	for _, nodeID := range spec.ActiveNodeIDs {
+		delete(windows, nodeID)
+	}
+	for nodeID := range windows {
+		return grpcstatus.Errorf(codes.InvalidArgument,
+			"maintenance windows can only be declared for active nodes; n%d is not one", nodeID)
+	}
+	return nil
+}
+
+// SetMembershipSpec replaces the membership spec of the cluster. See
+// serverpb.AdminServer.SetMembershipSpec.
+func (s *systemAdminServer) SetMembershipSpec(
+	ctx context.Context, req *serverpb.SetMembershipSpecRequest,
+) (*serverpb.SetMembershipSpecResponse, error) {
+	ctx = s.AnnotateCtx(ctx)
+	if _, err := s.requireAdminUser(ctx); err != nil {
+		// NB: not using serverError() here since the priv checker
+		// already returns a proper gRPC error status.
+		return nil, err
+	}
+
+	ls, err := s.nodeLiveness.GetLivenessesFromKV(ctx)
+	if err != nil {
+		return nil, serverError(ctx, err)
+	}
+	livenesses := make(map[roachpb.NodeID]livenesspb.Liveness, len(ls))
+	for _, l := range ls {
+		livenesses[l.NodeID] = l
+	}
+	if err := validateMembershipSpec(req.Spec, livenesses); err != nil {
+		return nil, err
+	}
+
+	spec := req.Spec
+	if err := s.db.Txn(ctx, func(ctx context.Context, txn *kv.Txn) error {
+		record, err := getMembershipSpecRecord(ctx, txn)
+		if err != nil {
+			return err
+		}
+		if req.ExpectedGeneration != 0 && req.ExpectedGeneration != record.Spec.Generation {
+			return grpcstatus.Errorf(codes.Aborted,
+				"the membership spec is at generation %d, not %d", record.Spec.Generation, req.ExpectedGeneration)
+		}
+		spec.Generation = record.Spec.Generation + 1
+		// The status is kept until the new spec is reconciled, so that the
+		// conditions that don't change keep their transition time.
+		record.Spec = spec
+		return txn.Put(ctx, keys.MembershipSpecKey, &record)
+	}); err != nil {
+		if _, ok := grpcstatus.FromError(err); ok {
+			return nil, err
+		}
+		return nil, serverError(ctx, err)
+	}
+	log.Ops.Infof(ctx, "membership spec set to generation %d: active %v, decommissioned %v, %d maintenance windows",
+		spec.Generation, spec.ActiveNodeIDs, spec.DecommissionedNodeIDs, len(spec.MaintenanceWindows))
+
+	// Don't wait for the next round of the reconciliation loop.
+	s.reconcileMembershipAsync()
+	return &serverpb.SetMembershipSpecResponse{Generation: spec.Generation}, nil
+}
+
+// MembershipSpec returns the membership spec of the cluster and its status.
+// See serverpb.AdminServer.MembershipSpec.
+func (s *systemAdminServer) MembershipSpec(
+	ctx context.Context, req *serverpb.MembershipSpecRequest,
+) (*serverpb.MembershipSpecResponse, error) {
+	ctx = s.AnnotateCtx(ctx)
+	if err := s.requireViewClusterMetadataPermission(ctx); err != nil {
+		// NB: not using serverError() here since the priv checker
+		// already returns a proper gRPC error status.
+		return nil, err
+	}
+	var record serverpb.MembershipSpecRecord
+	if err := s.db.GetProto(ctx, keys.MembershipSpecKey, &record); err != nil {
+		return nil, serverError(ctx, err)
+	}
+	return &serverpb.MembershipSpecResponse{Spec: record.Spec, Status: record.Status}, nil
+}
+
+// startMembershipReconcileLoop starts a task that periodically reconciles the
+// membership of the nodes with the membership spec of the cluster. Like the
+// scheduled decommission loop, every server runs it; the membership changes
+// are idempotent, so only one of them ends up carrying out each of them.
+func (s *systemAdminServer) startMembershipReconcileLoop(ctx context.Context) error {
+	return s.server.stopper.RunAsyncTaskEx(ctx,
+		stop.TaskOpts{TaskName: "membership-reconcile", SpanOpt: stop.SterileRootSpan},
+		func(ctx context.Context) {
+			ctx, cancel := s.server.stopper.WithCancelOnQuiesce(ctx)
+			defer cancel()
+
+			var timer timeutil.Timer
+			defer timer.Stop()
+			for {
+				timer.Reset(membershipReconcileInterval.Get(&s.st.SV))
+				select {
+				case <-timer.C:
+					timer.Read = true
+					if err := s.reconcileMembership(ctx); err != nil {
+						log.Ops.Warningf(ctx, "unable to reconcile the membership spec: %v", err)
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		})
+}
+
+// reconcileMembershipAsync runs a round of reconciliation in the background.
+func (s *systemAdminServer) reconcileMembershipAsync() {
+	ctx := s.AnnotateCtx(context.Background())
+	_ = s.server.stopper.RunAsyncTask(ctx, "membership-reconcile-now", func(ctx context.Context) {
+		ctx, cancel := s.server.stopper.WithCancelOnQuiesce(ctx)
+		defer cancel()
+		if err := s.reconcileMembership(ctx); err != nil {
+			log.Ops.Warningf(ctx, "unable to reconcile the membership spec: %v", err)
+		}
+	})
+}
+
+// reconcileMembership makes one step toward the membership spec of the
+// cluster for each of its nodes, and records the resulting status of the
+// spec.
+func (s *systemAdminServer) reconcileMembership(ctx context.Context) error {
+	var record serverpb.MembershipSpecRecord
+	if err := s.db.GetProto(ctx, keys.MembershipSpecKey, &record); err != nil {
+		return err
+	}
+	spec := record.Spec
+	if spec.Generation == 0 {
+		// No spec was ever set.
+		return nil
+	}
+
+	ls, err := s.nodeLiveness.GetLivenessesFromKV(ctx)
+	if err != nil {
+		return err
+	}
+	livenesses := make(map[roachpb.NodeID]livenesspb.Liveness, len(ls))
+	for _, l := range ls {
+		livenesses[l.NodeID] = l
+	}
+
+	var conditions []serverpb.MembershipSpecStatus_Condition
+	for _, nodeID := range spec.ActiveNodeIDs {
+		conditions = append(conditions,
+			s.reconcileNodeMembership(ctx, spec, livenesses, nodeID, livenesspb.MembershipStatus_ACTIVE))
+	}
+	for _, nodeID := range spec.DecommissionedNodeIDs {
+		conditions = append(conditions,
+			s.reconcileNodeMembership(ctx, spec, livenesses, nodeID, livenesspb.MembershipStatus_DECOMMISSIONED))
+	}
+	sort.Slice(conditions, func(i, j int) bool {
+		return conditions[i].NodeID < conditions[j].NodeID
+	})
+	return s.recordMembershipSpecStatus(ctx, spec.Generation, conditions)
+}
+
+// reconcileNodeMembership makes one step toward the desired membership of the
+// given node, e.g. starts decommissioning it, and returns its condition.
+func (s *systemAdminServer) reconcileNodeMembership(
+	ctx context.Context,
+	spec serverpb.MembershipSpec,
+	livenesses map[roachpb.NodeID]livenesspb.Liveness,
+	nodeID roachpb.NodeID,
+	desired livenesspb.MembershipStatus,
+) serverpb.MembershipSpecStatus_Condition {
+	cond := serverpb.MembershipSpecStatus_Condition{NodeID: nodeID, DesiredMembership: desired}
+	l, ok := livenesses[nodeID]
+	if !ok {
+		cond.Message = "no liveness record"
+		return cond
+	}
+	cond.Membership = l.Membership
+
+	change := func(target livenesspb.MembershipStatus) error {
+		log.Ops.Infof(ctx, "moving n%d to %s per the membership spec at generation %d",
+			nodeID, target, spec.Generation)
+		if err := s.server.decommissionWithReason(ctx, target, []roachpb.NodeID{nodeID}, spec.Reason); err != nil {
+			return err
+		}
+		cond.Membership = target
+		return nil
+	}
+
+	switch {
+	case desired.Active() && l.Membership.Decommissioning():
+		if err := change(livenesspb.MembershipStatus_ACTIVE); err != nil {
+			cond.Message = fmt.Sprintf("unable to recommission: %v", err)
+			return cond
+		}
+
+	case desired.Active() && l.Membership.Decommissioned():
+		cond.Message = "decommissioned nodes cannot be recommissioned"
+		return cond
+
+	case desired.Decommissioned() && l.Membership.Active():
+		if err := s.server.enforceReplicationFactor(ctx, []roachpb.NodeID{nodeID}); err != nil {
+			cond.Message = fmt.Sprintf("waiting to decommission: %v", err)
+			return cond
+		}
+		if err := change(livenesspb.MembershipStatus_DECOMMISSIONING); err != nil {
+			cond.Message = fmt.Sprintf("unable to decommission: %v", err)
+		}
+		// The node's replicas have yet to be moved off of it.
+		return cond
+
+	case desired.Decommissioned() && l.Membership.Decommissioning():
+		res, err := s.decommissionStatusHelper(ctx, &serverpb.DecommissionStatusRequest{
+			NodeIDs: []roachpb.NodeID{nodeID},
+		})
+		if err != nil {
+			cond.Message = fmt.Sprintf("unable to count replicas: %v", err)
+			return cond
+		}
+		if n := res.Status[0].ReplicaCount; n > 0 {
+			cond.Message = fmt.Sprintf("%d replicas left to move", n)
+			return cond
+		}
+		if err := change(livenesspb.MembershipStatus_DECOMMISSIONED); err != nil {
+			cond.Message = fmt.Sprintf("unable to decommission: %v", err)
+			return cond
+		}
+	}
+
+	if desired.Active() {
+		if msg := s.reconcileMaintenanceWindow(ctx, spec, l); msg != "" {
+			cond.Message = msg
+			return cond
+		}
+	}
+	cond.Reconciled = true
+	return cond
+}
+
+// reconcileMaintenanceWindow declares the maintenance window the spec has for
+// the node, if any, and returns a message if it couldn't.
+func (s *systemAdminServer) reconcileMaintenanceWindow(
+	ctx context.Context, spec serverpb.MembershipSpec, l livenesspb.Liveness,
+) string {
+	for _, w := range spec.MaintenanceWindows {
+		if w.NodeID != l.NodeID {
+			continue
+		}
+		start := hlc.Timestamp{WallTime: w.Start.UnixNano()}
+		end := hlc.Timestamp{WallTime: w.End.UnixNano()}
+		if (l.MaintenanceStart == start && l.MaintenanceEnd == end) || !s.clock.Now().Less(end) {
+			// The window is declared already, or has lapsed.
+			return ""
+		}
+		if err := s.nodeLiveness.SetMaintenanceWindow(ctx, l.NodeID, start, end); err != nil {
+			return fmt.Sprintf("unable to declare maintenance window: %v", err)
+		}
+		log.Ops.Infof(ctx, "maintenance window for n%d set to [%s, %s) per the membership spec at generation %d",
+			l.NodeID, start, end, spec.Generation)
+	}
+	return ""
+}
+
+// recordMembershipSpecStatus records the given conditions of the nodes of the
+// spec at the given generation, unless the spec was replaced in the meantime.
+// The transition time of the conditions that didn't change is preserved.
+func (s *systemAdminServer) recordMembershipSpecStatus(
+	ctx context.Context, generation int64, conditions []serverpb.MembershipSpecStatus_Condition,
+) error {
+	return s.db.Txn(ctx, func(ctx context.Context, txn *kv.Txn) error {
+		record, err := getMembershipSpecRecord(ctx, txn)
+		if err != nil {
+			return err
+		}
+		if record.Spec.Generation != generation {
+			// The next round reconciles the new spec.
+			return nil
+		}
+
+		prev := make(map[roachpb.NodeID]serverpb.MembershipSpecStatus_Condition, len(record.Status.Conditions))
+		for _, c := range record.Status.Conditions {
+			prev[c.NodeID] = c
+		}
+		now := s.clock.PhysicalTime()
+		status := serverpb.MembershipSpecStatus{
+			ObservedGeneration: generation,
+			Reconciled:         true,
+			Conditions:         conditions,
+		}
+		changed := record.Status.ObservedGeneration != generation ||
+			len(record.Status.Conditions) != len(conditions)
+		for i := range status.Conditions {
+			c := &status.Conditions[i]
+			status.Reconciled = status.Reconciled && c.Reconciled
+			p, ok := prev[c.NodeID]
+			if ok && p.DesiredMembership == c.DesiredMembership && p.Membership == c.Membership &&
+				p.Reconciled == c.Reconciled && p.Message == c.Message {
+				c.LastTransition = p.LastTransition
+				continue
+			}
+			c.LastTransition = now
+			changed = true
+		}
+		if !changed {
+			return nil
+		}
+		record.Status = status
+		return txn.Put(ctx, keys.MembershipSpecKey, &record)
+	})
+}
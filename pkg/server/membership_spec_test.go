@@ -0,0 +1,176 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
+	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/cockroach/pkg/testutils/serverutils"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+func TestValidateMembershipSpec(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	livenesses := map[roachpb.NodeID]livenesspb.Liveness{
+		1: {NodeID: 1, Membership: livenesspb.MembershipStatus_ACTIVE},
+		2: {NodeID: 2, Membership: livenesspb.MembershipStatus_DECOMMISSIONING},
+		3: {NodeID: 3, Membership: livenesspb.MembershipStatus_DECOMMISSIONED},
+	}
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	window := func(nodeID roachpb.NodeID, d time.Duration) serverpb.MembershipSpec_MaintenanceWindow {
+		return serverpb.MembershipSpec_MaintenanceWindow{NodeID: nodeID, Start: start, End: start.Add(d)}
+	}
+	for _, tc := range []struct {
+		name string
+		spec serverpb.MembershipSpec
+		code codes.Code
+	}{
+		{
+			name: "valid",
+			spec: serverpb.MembershipSpec{
+				ActiveNodeIDs:         []roachpb.NodeID{1, 2},
+				DecommissionedNodeIDs: []roachpb.NodeID{3},
+				MaintenanceWindows:    []serverpb.MembershipSpec_MaintenanceWindow{window(1, time.Hour)},
+			},
+			code: codes.OK,
+		},
+		{
+			name: "empty",
+			code: codes.OK,
+		},
+		{
+			name: "duplicate node",
+			spec: serverpb.MembershipSpec{
+				ActiveNodeIDs:         []roachpb.NodeID{1},
+				DecommissionedNodeIDs: []roachpb.NodeID{1},
+			},
+			code: codes.InvalidArgument,
+		},
+		{
+			name: "unknown node",
+			spec: serverpb.MembershipSpec{ActiveNodeIDs: []roachpb.NodeID{4}},
+			code: codes.NotFound,
+		},
+		{
+			name: "recommission decommissioned node",
+			spec: serverpb.MembershipSpec{ActiveNodeIDs: []roachpb.NodeID{3}},
+			code: codes.FailedPrecondition,
+		},
+		{
+			name: "empty maintenance window",
+			spec: serverpb.MembershipSpec{
+				ActiveNodeIDs:      []roachpb.NodeID{1},
+				MaintenanceWindows: []serverpb.MembershipSpec_MaintenanceWindow{window(1, 0)},
+			},
+			code: codes.InvalidArgument,
+		},
+		{
+			name: "maintenance window of inactive node",
+			spec: serverpb.MembershipSpec{
+				DecommissionedNodeIDs: []roachpb.NodeID{2},
+				MaintenanceWindows:    []serverpb.MembershipSpec_MaintenanceWindow{window(2, time.Hour)},
+			},
+			code: codes.InvalidArgument,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateMembershipSpec(tc.spec, livenesses)
+			require.Equal(t, tc.code, grpcstatus.Code(err), "%v", err)
+		})
+	}
+}
+
+func TestMembershipSpec(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := serverutils.StartNewTestCluster(t, 4, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	s := tc.Server(0).(*TestServer)
+	res, err := s.admin.MembershipSpec(ctx, &serverpb.MembershipSpecRequest{})
+	require.NoError(t, err)
+	require.Zero(t, res.Spec.Generation)
+
+	// With manual replication, n4 has no replicas, so it can be decommissioned
+	// right away.
+	now := s.Clock().PhysicalTime()
+	spec := serverpb.MembershipSpec{
+		ActiveNodeIDs:         []roachpb.NodeID{1, 2, 3},
+		DecommissionedNodeIDs: []roachpb.NodeID{4},
+		MaintenanceWindows: []serverpb.MembershipSpec_MaintenanceWindow{{
+			NodeID: 2,
+			Start:  now,
+			End:    now.Add(time.Hour),
+		}},
+		Reason: "scale down",
+	}
+	setRes, err := s.admin.SetMembershipSpec(ctx, &serverpb.SetMembershipSpecRequest{Spec: spec})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), setRes.Generation)
+
+	testutils.SucceedsSoon(t, func() error {
+		if err := s.admin.reconcileMembership(ctx); err != nil {
+			return err
+		}
+		res, err = s.admin.MembershipSpec(ctx, &serverpb.MembershipSpecRequest{})
+		if err != nil {
+			return err
+		}
+		if !res.Status.Reconciled {
+			return errors.Errorf("not reconciled yet: %+v", res.Status.Conditions)
+		}
+		return nil
+	})
+	require.Equal(t, int64(1), res.Status.ObservedGeneration)
+	require.Len(t, res.Status.Conditions, 4)
+	for _, c := range res.Status.Conditions {
+		require.Equal(t, c.DesiredMembership, c.Membership)
+		require.Empty(t, c.Message)
+	}
+
+	livenesses, err := s.nodeLiveness.GetLivenessesFromKV(ctx)
+	require.NoError(t, err)
+	for _, l := range livenesses {
+		switch l.NodeID {
+		case 2:
+			require.Equal(t, hlc.Timestamp{WallTime: now.Add(time.Hour).UnixNano()}, l.MaintenanceEnd)
+		case 4:
+			require.Equal(t, livenesspb.MembershipStatus_DECOMMISSIONED, l.Membership)
+			require.Equal(t, "scale down", l.Reason)
+		}
+	}
+
+	// A spec based on an outdated generation is refused.
+	_, err = s.admin.SetMembershipSpec(ctx, &serverpb.SetMembershipSpecRequest{
+		Spec:               serverpb.MembershipSpec{ActiveNodeIDs: []roachpb.NodeID{1}},
+		ExpectedGeneration: 3,
+	})
+	require.Equal(t, codes.Aborted, grpcstatus.Code(err), "%v", err)
+}
@@ -0,0 +1,79 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/server/telemetry"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+// Telemetry counters for the node membership operations. They only record
+// which flows are used and how they fail, never node IDs or reasons.
+var (
+	telemetryDecommissioning = telemetry.GetCounterOnce("server.decommission.decommissioning")
+	telemetryDecommissioned  = telemetry.GetCounterOnce("server.decommission.decommissioned")
+	telemetryRecommission    = telemetry.GetCounterOnce("server.decommission.recommission")
+	// telemetryDecommissionScheduled counts decommissions started because a
+	// previously scheduled decommission became due.
+	telemetryDecommissionScheduled = telemetry.GetCounterOnce("server.decommission.scheduled")
+	// telemetryDecommissionForced counts decommissions that skipped the
+	// under-replication safety check.
+	telemetryDecommissionForced = telemetry.GetCounterOnce("server.decommission.forced")
+	// telemetryForceDecommission counts the dead and unreachable nodes
+	// decommissioned through ForceDecommission, which stages their ranges that
+	// lost quorum for loss of quorum recovery.
+	telemetryForceDecommission = telemetry.GetCounterOnce("server.decommission.force_decommission")
+	// telemetryDecommissionLossOfQuorum counts the transitions forced upon dead
+	// nodes during loss of quorum recovery cleanup.
+	telemetryDecommissionLossOfQuorum = telemetry.GetCounterOnce("server.decommission.loss_of_quorum_cleanup")
+
+	telemetryDecommissionFailedPrecondition     = telemetry.GetCounterOnce("server.decommission.failed.precondition")
+	telemetryDecommissionFailedUnderReplication = telemetry.GetCounterOnce("server.decommission.failed.under_replication")
+	telemetryDecommissionFailedNotFound         = telemetry.GetCounterOnce("server.decommission.failed.not_found")
+	telemetryDecommissionFailedOther            = telemetry.GetCounterOnce("server.decommission.failed.other")
+
+	telemetryDrain         = telemetry.GetCounterOnce("server.drain.drain")
+	telemetryDrainShutdown = telemetry.GetCounterOnce("server.drain.shutdown")
+	telemetryUndrain       = telemetry.GetCounterOnce("server.drain.undrain")
+	telemetryDrainFailed   = telemetry.GetCounterOnce("server.drain.failed")
+	telemetryUndrainFailed = telemetry.GetCounterOnce("server.drain.undrain_failed")
+)
+
+// recordMembershipChangeTelemetry records a change of the membership status of
+// nodes to the given target status.
+func recordMembershipChangeTelemetry(targetStatus livenesspb.MembershipStatus) {
+	switch {
+	case targetStatus.Decommissioning():
+		telemetry.Inc(telemetryDecommissioning)
+	case targetStatus.Decommissioned():
+		telemetry.Inc(telemetryDecommissioned)
+	case targetStatus.Active():
+		telemetry.Inc(telemetryRecommission)
+	}
+}
+
+// recordMembershipChangeFailureTelemetry records the failure mode of a
+// membership change from the gRPC error it returned.
+func recordMembershipChangeFailureTelemetry(err error) {
+	if err == nil {
+		return
+	}
+	switch grpcstatus.Code(err) {
+	case codes.FailedPrecondition:
+		telemetry.Inc(telemetryDecommissionFailedPrecondition)
+	case codes.NotFound:
+		telemetry.Inc(telemetryDecommissionFailedNotFound)
+	default:
+		telemetry.Inc(telemetryDecommissionFailedOther)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/server/telemetry"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+func TestMembershipChangeTelemetry(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	for _, tc := range []struct {
+		target  livenesspb.MembershipStatus
+		counter telemetry.Counter
+	}{
+		{livenesspb.MembershipStatus_DECOMMISSIONING, telemetryDecommissioning},
+		{livenesspb.MembershipStatus_DECOMMISSIONED, telemetryDecommissioned},
+		{livenesspb.MembershipStatus_ACTIVE, telemetryRecommission},
+	} {
+		before := telemetry.Read(tc.counter)
+		recordMembershipChangeTelemetry(tc.target)
+		require.Equal(t, before+1, telemetry.Read(tc.counter), "%s", tc.target)
+	}
+
+	for _, tc := range []struct {
+		err     error
+		counter telemetry.Counter
+	}{
+		{grpcstatus.Error(codes.FailedPrecondition, "boom"), telemetryDecommissionFailedPrecondition},
+		{grpcstatus.Error(codes.NotFound, "boom"), telemetryDecommissionFailedNotFound},
+		{grpcstatus.Error(codes.Internal, "boom"), telemetryDecommissionFailedOther},
+		{errors.New("boom"), telemetryDecommissionFailedOther},
+	} {
+		before := telemetry.Read(tc.counter)
+		recordMembershipChangeFailureTelemetry(tc.err)
+		require.Equal(t, before+1, telemetry.Read(tc.counter), "%v", tc.err)
+	}
+
+	// A successful change records no failure.
+	before := telemetry.Read(telemetryDecommissionFailedOther)
+	recordMembershipChangeFailureTelemetry(nil)
+	require.Equal(t, before, telemetry.Read(telemetryDecommissionFailedOther))
+}
@@ -0,0 +1,417 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/settings"
+	"github.com/cockroachdb/cockroach/pkg/util/httputil"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/stop"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/errors"
+)
+
+// validateMembershipWebhookTemplate checks that the given string is a valid
+// template for membership webhooks.
+func validateMembershipWebhookTemplate(_ *settings.Values, s string) error {
+	_, err := parseMembershipWebhookTemplate(s)
+	return err
+}
+
+var membershipWebhookURL = settings.RegisterValidatedStringSetting(
+	settings.SystemOnly,
+	"server.membership_webhook.url",
+	"the URL notified when a node joins the cluster, starts being decommissioned, is "+
+		"recommissioned or is decommissioned, as a Go text/template over the change (e.g. "+
+		"http://consul:8500/v1/agent/service/deregister/crdb-{{.NodeID}}); see "+
+		"server.membership_webhook.payload_template for the fields of the change. "+
+		"Empty to disable the webhook",
+	"",
+	validateMembershipWebhookTemplate,
+)
+
+const (
+	membershipWebhookMethodPost = iota
+	membershipWebhookMethodPut
+)
+
+var membershipWebhookMethod = settings.RegisterEnumSetting(
+	settings.SystemOnly,
+	"server.membership_webhook.method",
+	"the HTTP method of the requests to server.membership_webhook.url",
+	"POST",
+	map[int64]string{
+		membershipWebhookMethodPost: "POST",
+		membershipWebhookMethodPut:  "PUT",
+	},
+)
+
+var membershipWebhookContentType = settings.RegisterStringSetting(
+	settings.SystemOnly,
+	"server.membership_webhook.content_type",
+	"the content type of the payload of the requests to server.membership_webhook.url",
+	httputil.JSONContentType,
+)
+
+// defaultMembershipWebhookPayload is the default payload template of the
+// membership webhook, which encodes the change as JSON.
+const defaultMembershipWebhookPayload = `{"node_id": {{.NodeID}}, "event": {{json .Event}}, ` +
+	`"membership": {{json .Membership}}, "address": {{json .Address}}, ` +
+	`"sql_address": {{json .SQLAddress}}, "http_address": {{json .HTTPAddress}}, ` +
+	`"locality": {{json .Locality}}, "timestamp": {{json .Timestamp}}}`
+
+var membershipWebhookPayloadTemplate = settings.RegisterValidatedStringSetting(
+	settings.SystemOnly,
+	"server.membership_webhook.payload_template",
+	"the payload of the requests to server.membership_webhook.url, as a Go text/template "+
+		"over the change, which has the fields .NodeID, .Event (joined, decommissioning, "+
+		"recommissioned or decommissioned), .Membership, .Address, .SQLAddress, .HTTPAddress, "+
+		".Locality and .Timestamp; the json function encodes a value as JSON",
+	defaultMembershipWebhookPayload,
+	validateMembershipWebhookTemplate,
+)
+
+const (
+	// membershipWebhookCheckInterval is the interval at which the membership
+	// of the nodes is checked for changes.
+	membershipWebhookCheckInterval = 5 * time.Second
+	// membershipWebhookMaxBackoff is the maximum delay between attempts to
+	// deliver a change the hook failed to accept.
+	membershipWebhookMaxBackoff = 5 * time.Minute
+	// membershipWebhookMaxPending is the maximum number of changes kept for
+	// delivery; the oldest ones are dropped beyond that.
+	membershipWebhookMaxPending = 1000
+	// membershipWebhookHandoffWindow is how long the nodes other than the
+	// coordinator keep the changes they observe. Should the coordinator die
+	// before delivering them, the node that takes over delivers them once its
+	// liveness cache notices, which takes less than that.
+	membershipWebhookHandoffWindow = time.Minute
+)
+
+// The events of a MembershipChange.
+const (
+	MembershipEventJoined          = "joined"
+	MembershipEventDecommissioning = "decommissioning"
+	MembershipEventRecommissioned  = "recommissioned"
+	MembershipEventDecommissioned  = "decommissioned"
+)
+
+// MembershipChange is a change of the membership of a node, as reported to
+// service discovery backends.
+type MembershipChange struct {
+	NodeID roachpb.NodeID
+	// Event is one of the MembershipEvent constants.
+	Event string
+	// Membership is the membership status of the node after the change.
+	Membership string
+	// Address, SQLAddress and HTTPAddress are the addresses of the node, as
+	// last gossiped.
+	Address     string
+	SQLAddress  string
+	HTTPAddress string
+	Locality    string
+	// Timestamp is the time at which the change was observed.
+	Timestamp time.Time
+}
+
+// MembershipChangeHook is notified of changes of the membership of the nodes.
+// The built-in hook posts them to server.membership_webhook.url.
+type MembershipChangeHook interface {
+	NotifyMembershipChange(ctx context.Context, change MembershipChange) error
+}
+
+// parseMembershipWebhookTemplate parses a template of the membership webhook.
+func parseMembershipWebhookTemplate(s string) (*template.Template, error) {
+	return template.New("membership_webhook").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Option("missingkey=error").Parse(s)
+}
+
+// webhookMembershipChangeHook sends membership changes to a URL, both
+// rendered from templates, e.g. to update a DNS or Consul view of the cluster.
+type webhookMembershipChangeHook struct {
+	url         *template.Template
+	payload     *template.Template
+	method      string
+	contentType string
+}
+
+var _ MembershipChangeHook = webhookMembershipChangeHook{}
+
+// NotifyMembershipChange implements the MembershipChangeHook interface.
+func (h webhookMembershipChangeHook) NotifyMembershipChange(
+	ctx context.Context, change MembershipChange,
+) error {
+	var url, body bytes.Buffer
+	if err := h.url.Execute(&url, change); err != nil {
+		return errors.Wrap(err, "rendering the webhook URL")
+	}
+	if err := h.payload.Execute(&body, change); err != nil {
+		return errors.Wrap(err, "rendering the webhook payload")
+	}
+	req, err := http.NewRequestWithContext(ctx, h.method, strings.TrimSpace(url.String()), &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", h.contentType)
+	resp, err := httputil.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Newf("webhook %s responded with %s", req.URL, resp.Status)
+	}
+	return nil
+}
+
+// membershipObservation is the membership of a node, along with its gossiped
+// descriptor, if any.
+type membershipObservation struct {
+	membership livenesspb.MembershipStatus
+	desc       *roachpb.NodeDescriptor
+}
+
+// membershipTracker tracks the membership of the nodes, and produces changes
+// when it evolves. The first observation of the nodes is a baseline which
+// produces no changes. It also keeps the changes that weren't delivered to the
+// membership change hook yet.
+type membershipTracker struct {
+	initialized bool
+	nodes       map[roachpb.NodeID]membershipObservation
+
+	// pending are the changes not delivered yet, in the order they were
+	// observed.
+	pending []MembershipChange
+	// retryAt is the time before which the delivery of the pending changes
+	// isn't attempted again after a failure, and backoff the delay before the
+	// next attempt.
+	retryAt time.Time
+	backoff time.Duration
+}
+
+func newMembershipTracker() *membershipTracker {
+	return &membershipTracker{nodes: make(map[roachpb.NodeID]membershipObservation)}
+}
+
+// observe records the membership of the given nodes, and returns the changes
+// to report, ordered by node ID. A node joining the cluster is only reported
+// once its descriptor is gossiped, since there is no address to register
+// before that. The last descriptor known of a node is used for the changes
+// reported after it stops being gossiped.
+func (t *membershipTracker) observe(
+	nodes map[roachpb.NodeID]membershipObservation, now time.Time,
+) []MembershipChange {
+	var changes []MembershipChange
+	for nodeID, cur := range nodes {
+		prev, known := t.nodes[nodeID]
+		if cur.desc == nil {
+			cur.desc = prev.desc
+		}
+		var event string
+		switch {
+		case !known:
+			if cur.membership.Active() && cur.desc == nil && t.initialized {
+				// Wait for the descriptor of the new node.
+				continue
+			}
+			if cur.membership.Active() {
+				event = MembershipEventJoined
+			}
+		case prev.membership == cur.membership:
+		case cur.membership.Decommissioning():
+			event = MembershipEventDecommissioning
+		case cur.membership.Active():
+			event = MembershipEventRecommissioned
+		case cur.membership.Decommissioned():
+			event = MembershipEventDecommissioned
+		}
+		t.nodes[nodeID] = cur
+		if event == "" || !t.initialized {
+			continue
+		}
+		change := MembershipChange{
+			NodeID:     nodeID,
+			Event:      event,
+			Membership: cur.membership.String(),
+			Timestamp:  now,
+		}
+		if cur.desc != nil {
+			change.Address = cur.desc.Address.String()
+			change.SQLAddress = cur.desc.CheckedSQLAddress().String()
+			change.HTTPAddress = cur.desc.HTTPAddress.String()
+			change.Locality = cur.desc.Locality.String()
+		}
+		changes = append(changes, change)
+	}
+	t.initialized = true
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].NodeID < changes[j].NodeID
+	})
+	return changes
+}
+
+// enqueue adds the given changes to the changes to deliver, dropping the
+// oldest ones beyond membershipWebhookMaxPending.
+func (t *membershipTracker) enqueue(ctx context.Context, changes []MembershipChange) {
+	t.pending = append(t.pending, changes...)
+	if excess := len(t.pending) - membershipWebhookMaxPending; excess > 0 {
+		log.Ops.Warningf(ctx, "dropping %d undelivered membership changes", excess)
+		t.pending = append([]MembershipChange(nil), t.pending[excess:]...)
+	}
+}
+
+// expire drops the pending changes observed before the given time.
+func (t *membershipTracker) expire(before time.Time) {
+	i := sort.Search(len(t.pending), func(i int) bool {
+		return !t.pending[i].Timestamp.Before(before)
+	})
+	t.pending = t.pending[i:]
+}
+
+// deliver notifies the hook of the pending changes, in order. The delivery
+// stops at the first change the hook fails to accept, which is retried, along
+// with the changes that follow it, with exponential backoff.
+func (t *membershipTracker) deliver(
+	ctx context.Context, hook MembershipChangeHook, now time.Time,
+) {
+	if now.Before(t.retryAt) {
+		return
+	}
+	for len(t.pending) > 0 {
+		change := t.pending[0]
+		if err := hook.NotifyMembershipChange(ctx, change); err != nil {
+			t.backoff *= 2
+			if t.backoff == 0 {
+				t.backoff = membershipWebhookCheckInterval
+			} else if t.backoff > membershipWebhookMaxBackoff {
+				t.backoff = membershipWebhookMaxBackoff
+			}
+			t.retryAt = now.Add(t.backoff)
+			log.Ops.Warningf(ctx, "unable to notify the %s membership change of n%d, "+
+				"retrying in %s: %v", change.Event, change.NodeID, t.backoff, err)
+			return
+		}
+		t.pending = t.pending[1:]
+	}
+	t.backoff = 0
+	t.retryAt = time.Time{}
+}
+
+// membershipChangeHook returns the hook to notify of membership changes, or
+// nil if there is none.
+func (s *Server) membershipChangeHook() MembershipChangeHook {
+	if knobs, ok := s.cfg.TestingKnobs.Server.(*TestingKnobs); ok && knobs.MembershipChangeHook != nil {
+		return knobs.MembershipChangeHook
+	}
+	urlTemplate := membershipWebhookURL.Get(&s.st.SV)
+	if urlTemplate == "" {
+		return nil
+	}
+	// The templates were validated when the settings were set.
+	url, err := parseMembershipWebhookTemplate(urlTemplate)
+	if err != nil {
+		return nil
+	}
+	payload, err := parseMembershipWebhookTemplate(membershipWebhookPayloadTemplate.Get(&s.st.SV))
+	if err != nil {
+		return nil
+	}
+	method := http.MethodPost
+	if membershipWebhookMethod.Get(&s.st.SV) == membershipWebhookMethodPut {
+		method = http.MethodPut
+	}
+	return webhookMembershipChangeHook{
+		url:         url,
+		payload:     payload,
+		method:      method,
+		contentType: membershipWebhookContentType.Get(&s.st.SV),
+	}
+}
+
+// startMembershipWebhookLoop starts a task that periodically checks the
+// membership of the nodes, and notifies the membership change hook when it
+// changes. As for node alerts, every server tracks the membership of the
+// nodes, but only the live node with the lowest ID, the coordinator, notifies
+// the hook. The other nodes keep the changes they observe for
+// membershipWebhookHandoffWindow, so that they are delivered even if the
+// coordinator dies before it could deliver them. The hook is thus notified of
+// each change at least once, and may be notified of some twice when the
+// coordinator changes.
+func (s *Server) startMembershipWebhookLoop(ctx context.Context) error {
+	interval := membershipWebhookCheckInterval
+	if knobs, ok := s.cfg.TestingKnobs.Server.(*TestingKnobs); ok &&
+		knobs.MembershipWebhookCheckInterval != 0 {
+		interval = knobs.MembershipWebhookCheckInterval
+	}
+	return s.stopper.RunAsyncTaskEx(ctx,
+		stop.TaskOpts{TaskName: "membership-webhook", SpanOpt: stop.SterileRootSpan},
+		func(ctx context.Context) {
+			ctx, cancel := s.stopper.WithCancelOnQuiesce(ctx)
+			defer cancel()
+
+			tracker := newMembershipTracker()
+			var timer timeutil.Timer
+			defer timer.Stop()
+			for {
+				timer.Reset(interval)
+				select {
+				case <-timer.C:
+					timer.Read = true
+					s.checkMembershipChanges(ctx, tracker)
+				case <-ctx.Done():
+					return
+				}
+			}
+		})
+}
+
+// checkMembershipChanges observes the membership of every node, and notifies
+// the membership change hook of the changes, if this node is the coordinator.
+func (s *Server) checkMembershipChanges(ctx context.Context, tracker *membershipTracker) {
+	now := s.clock.Now()
+	vitalities := s.nodeLiveness.ScanNodeVitalityFromCache()
+	nodes := make(map[roachpb.NodeID]membershipObservation, len(vitalities))
+	for nodeID, v := range vitalities {
+		obs := membershipObservation{membership: v.Liveness.Membership}
+		if desc, err := s.gossip.GetNodeDescriptor(nodeID); err == nil {
+			obs.desc = desc
+		}
+		nodes[nodeID] = obs
+	}
+	tracker.enqueue(ctx, tracker.observe(nodes, now.GoTime()))
+	if !isLowestLiveNode(s.NodeID(), vitalities, now) {
+		// The coordinator delivers the changes. Only keep the recent ones, in
+		// case it dies before doing so.
+		tracker.expire(now.GoTime().Add(-membershipWebhookHandoffWindow))
+		return
+	}
+	hook := s.membershipChangeHook()
+	if hook == nil {
+		tracker.pending = nil
+		return
+	}
+	tracker.deliver(ctx, hook, now.GoTime())
+}
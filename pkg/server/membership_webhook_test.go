@@ -0,0 +1,295 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/cockroach/pkg/testutils/serverutils"
+	"github.com/cockroachdb/cockroach/pkg/util"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMembershipTracker(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	const (
+		act   = livenesspb.MembershipStatus_ACTIVE
+		decom = livenesspb.MembershipStatus_DECOMMISSIONING
+		gone  = livenesspb.MembershipStatus_DECOMMISSIONED
+	)
+	desc := func(nodeID roachpb.NodeID) *roachpb.NodeDescriptor {
+		return &roachpb.NodeDescriptor{
+			NodeID:  nodeID,
+			Address: util.MakeUnresolvedAddr("tcp", "n:26257"),
+		}
+	}
+	events := func(changes []MembershipChange) map[roachpb.NodeID]string {
+		m := make(map[roachpb.NodeID]string, len(changes))
+		for _, c := range changes {
+			m[c.NodeID] = c.Event
+		}
+		return m
+	}
+	now := time.Unix(10, 0)
+
+	tr := newMembershipTracker()
+	// The first observation is a baseline, even for nodes without descriptor.
+	require.Empty(t, tr.observe(map[roachpb.NodeID]membershipObservation{
+		1: {membership: act, desc: desc(1)},
+		2: {membership: act},
+		3: {membership: act, desc: desc(3)},
+	}, now))
+
+	// A new node is only reported once its descriptor is known.
+	require.Empty(t, tr.observe(map[roachpb.NodeID]membershipObservation{
+		1: {membership: act, desc: desc(1)},
+		2: {membership: act},
+		3: {membership: act, desc: desc(3)},
+		4: {membership: act},
+	}, now))
+	changes := tr.observe(map[roachpb.NodeID]membershipObservation{
+		1: {membership: act, desc: desc(1)},
+		2: {membership: decom},
+		3: {membership: act, desc: desc(3)},
+		4: {membership: act, desc: desc(4)},
+	}, now)
+	require.Equal(t, map[roachpb.NodeID]string{
+		2: MembershipEventDecommissioning,
+		4: MembershipEventJoined,
+	}, events(changes))
+	require.Equal(t, roachpb.NodeID(2), changes[0].NodeID)
+	require.Equal(t, "n:26257", changes[1].Address)
+	require.Equal(t, now, changes[1].Timestamp)
+
+	// Recommissions and decommissions are reported, with the last known
+	// descriptor of the node.
+	changes = tr.observe(map[roachpb.NodeID]membershipObservation{
+		1: {membership: act, desc: desc(1)},
+		2: {membership: act},
+		3: {membership: gone},
+		4: {membership: act, desc: desc(4)},
+	}, now)
+	require.Equal(t, map[roachpb.NodeID]string{
+		2: MembershipEventRecommissioned,
+		3: MembershipEventDecommissioned,
+	}, events(changes))
+	require.Equal(t, "n:26257", changes[1].Address)
+	require.Equal(t, gone.String(), changes[1].Membership)
+}
+
+// recordingMembershipChangeHook records the changes it is notified of, and
+// fails to accept them while failing is set.
+type recordingMembershipChangeHook struct {
+	syncutil.Mutex
+	failing bool
+	changes []MembershipChange
+}
+
+func (h *recordingMembershipChangeHook) NotifyMembershipChange(
+	_ context.Context, change MembershipChange,
+) error {
+	h.Lock()
+	defer h.Unlock()
+	if h.failing {
+		return errors.New("unavailable")
+	}
+	h.changes = append(h.changes, change)
+	return nil
+}
+
+func (h *recordingMembershipChangeHook) events(nodeID roachpb.NodeID) []string {
+	h.Lock()
+	defer h.Unlock()
+	var events []string
+	for _, c := range h.changes {
+		if c.NodeID == nodeID {
+			events = append(events, c.Event)
+		}
+	}
+	return events
+}
+
+func TestMembershipTrackerDelivery(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	now := time.Unix(10, 0)
+	change := func(nodeID roachpb.NodeID, ts time.Time) MembershipChange {
+		return MembershipChange{NodeID: nodeID, Event: MembershipEventJoined, Timestamp: ts}
+	}
+	hook := &recordingMembershipChangeHook{failing: true}
+	tr := newMembershipTracker()
+	tr.enqueue(ctx, []MembershipChange{change(1, now), change(2, now)})
+
+	// Changes the hook fails to accept are retried with backoff.
+	tr.deliver(ctx, hook, now)
+	require.Len(t, tr.pending, 2)
+	require.Equal(t, membershipWebhookCheckInterval, tr.backoff)
+	hook.failing = false
+	tr.deliver(ctx, hook, now.Add(time.Second))
+	require.Empty(t, hook.events(1))
+	hook.failing = true
+	tr.deliver(ctx, hook, now.Add(membershipWebhookCheckInterval))
+	require.Equal(t, 2*membershipWebhookCheckInterval, tr.backoff)
+	hook.failing = false
+	now = now.Add(3 * membershipWebhookCheckInterval)
+	tr.deliver(ctx, hook, now)
+	require.Empty(t, tr.pending)
+	require.Zero(t, tr.backoff)
+	require.Equal(t, []string{MembershipEventJoined}, hook.events(1))
+	require.Equal(t, []string{MembershipEventJoined}, hook.events(2))
+
+	// Nodes other than the coordinator only keep recent changes.
+	tr.enqueue(ctx, []MembershipChange{change(3, now), change(4, now.Add(time.Minute))})
+	tr.expire(now.Add(time.Second))
+	require.Len(t, tr.pending, 1)
+	require.Equal(t, roachpb.NodeID(4), tr.pending[0].NodeID)
+}
+
+// TestMembershipChangeHookOnDecommission verifies that the membership change
+// hook is notified exactly once of each step of the decommission of a node.
+func TestMembershipChangeHookOnDecommission(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	hook := &recordingMembershipChangeHook{}
+	tc := serverutils.StartNewTestCluster(t, 3, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+		ServerArgs: base.TestServerArgs{
+			Knobs: base.TestingKnobs{
+				Server: &TestingKnobs{
+					MembershipChangeHook:           hook,
+					MembershipWebhookCheckInterval: 10 * time.Millisecond,
+				},
+			},
+		},
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	firstSvr := tc.Server(0).(*TestServer)
+	targetIDs := []roachpb.NodeID{tc.Server(2).NodeID()}
+	var exp []string
+	for _, step := range []struct {
+		target livenesspb.MembershipStatus
+		event  string
+	}{
+		{livenesspb.MembershipStatus_DECOMMISSIONING, MembershipEventDecommissioning},
+		{livenesspb.MembershipStatus_DECOMMISSIONED, MembershipEventDecommissioned},
+	} {
+		require.NoError(t, firstSvr.Decommission(ctx, step.target, targetIDs))
+		exp = append(exp, step.event)
+		testutils.SucceedsSoon(t, func() error {
+			if events := hook.events(targetIDs[0]); len(events) < len(exp) {
+				return errors.Errorf("notified of %v", events)
+			}
+			return nil
+		})
+	}
+	// Let a few more checks run, which mustn't notify the hook again.
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(t, exp, hook.events(targetIDs[0]))
+}
+
+func TestWebhookMembershipChangeHook(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	type request struct {
+		method, path, contentType string
+		body                      []byte
+	}
+	var received []request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			http.Error(w, "failed", http.StatusInternalServerError)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		received = append(received, request{
+			method: r.Method, path: r.URL.Path, contentType: r.Header.Get("Content-Type"), body: body,
+		})
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	change := MembershipChange{
+		NodeID:     3,
+		Event:      MembershipEventDecommissioned,
+		Membership: livenesspb.MembershipStatus_DECOMMISSIONED.String(),
+		Address:    "n3:26257",
+		SQLAddress: "n3:26257",
+		Locality:   "region=us-east1",
+		Timestamp:  time.Unix(10, 0).UTC(),
+	}
+	hook := func(urlTemplate, payloadTemplate string) webhookMembershipChangeHook {
+		url, err := parseMembershipWebhookTemplate(urlTemplate)
+		require.NoError(t, err)
+		payload, err := parseMembershipWebhookTemplate(payloadTemplate)
+		require.NoError(t, err)
+		return webhookMembershipChangeHook{
+			url: url, payload: payload, method: http.MethodPut, contentType: "text/plain",
+		}
+	}
+
+	// The default payload is valid JSON.
+	require.NoError(t, hook(srv.URL+"/nodes/{{.NodeID}}", defaultMembershipWebhookPayload).
+		NotifyMembershipChange(ctx, change))
+	require.Len(t, received, 1)
+	require.Equal(t, http.MethodPut, received[0].method)
+	require.Equal(t, "/nodes/3", received[0].path)
+	require.Equal(t, "text/plain", received[0].contentType)
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal(received[0].body, &payload))
+	require.Equal(t, map[string]interface{}{
+		"node_id":      float64(3),
+		"event":        "decommissioned",
+		"membership":   "decommissioned",
+		"address":      "n3:26257",
+		"sql_address":  "n3:26257",
+		"http_address": "",
+		"locality":     "region=us-east1",
+		"timestamp":    "1970-01-01T00:00:10Z",
+	}, payload)
+
+	// Custom payloads are rendered as is.
+	require.NoError(t, hook(srv.URL, "{{.Event}} n{{.NodeID}}").NotifyMembershipChange(ctx, change))
+	require.Len(t, received, 2)
+	require.Equal(t, "decommissioned n3", string(received[1].body))
+
+	require.Error(t, hook(srv.URL+"/fail", defaultMembershipWebhookPayload).
+		NotifyMembershipChange(ctx, change))
+
+	// Invalid templates are rejected.
+	_, err := parseMembershipWebhookTemplate("{{.NodeID")
+	require.Error(t, err)
+}
@@ -274,16 +274,6 @@ type Node struct {
 
 var _ kvpb.InternalServer = &Node{}
 
-// allocateNodeID increments the node id generator key to allocate
-// a new, unique node id.
-func allocateNodeID(ctx context.Context, db *kv.DB) (roachpb.NodeID, error) {
-	val, err := kv.IncrementValRetryable(ctx, db, keys.NodeIDGenerator, 1)
-	if err != nil {
-		return 0, errors.Wrap(err, "unable to allocate node ID")
-	}
-	return roachpb.NodeID(val), nil
-}
-
 // allocateStoreIDs increments the store id generator key for the
 // specified node to allocate count new, unique store ids. The
 // first ID in a contiguous range is returned on success.
@@ -1950,7 +1940,20 @@ func (n *Node) Join(
 		return nil, grpcstatus.Error(codes.PermissionDenied, ErrIncompatibleBinaryVersion.Error())
 	}
 
-	nodeID, err := allocateNodeID(ctx, n.storeCfg.DB)
+	// We allocate the node ID and create a liveness record for the joining node
+	// in a single transaction. We do so to maintain the invariant that there's
+	// always a liveness record for a given node, even if we crash midway. See
+	// `WriteInitialClusterData` for the other codepath where we manually create
+	// a liveness record to maintain this same invariant.
+	//
+	// NB: This invariant will be required for when we introduce long running
+	// upgrades. See https://github.com/cockroachdb/cockroach/pull/48843 for
+	// details.
+	//
+	// The record is created with a provisional expiration and gossiped, so that
+	// the joining node is visible to the allocator and the liveness map before
+	// its first heartbeat.
+	nodeID, err := n.storeCfg.NodeLiveness.AllocateNodeIDWithLivenessRecord(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -1960,25 +1963,21 @@ func (n *Node) Join(
 		return nil, err
 	}
 
-	// We create a liveness record here for the joining node while here. We do
-	// so to maintain the invariant that there's always a liveness record for a
-	// given node. See `WriteInitialClusterData` for the other codepath where we
-	// manually create a liveness record to maintain this same invariant.
-	//
-	// NB: This invariant will be required for when we introduce long running
-	// upgrades. See https://github.com/cockroachdb/cockroach/pull/48843 for
-	// details.
-	if err := n.storeCfg.NodeLiveness.CreateLivenessRecord(ctx, nodeID); err != nil {
-		return nil, err
-	}
-
 	log.Infof(ctx, "allocated IDs: n%d, s%d", nodeID, storeID)
 
+	// The joining node can do without the liveness records, which it would
+	// learn about through gossip otherwise.
+	livenessRecords, err := n.storeCfg.NodeLiveness.EncodedLivenessesFromKV(ctx)
+	if err != nil {
+		log.Warningf(ctx, "unable to include the liveness records in the join response of n%d: %v", nodeID, err)
+	}
+
 	return &kvpb.JoinNodeResponse{
-		ClusterID:     n.clusterID.Get().GetBytes(),
-		NodeID:        int32(nodeID),
-		StoreID:       int32(storeID),
-		ActiveVersion: &activeVersion.Version,
+		ClusterID:       n.clusterID.Get().GetBytes(),
+		NodeID:          int32(nodeID),
+		StoreID:         int32(storeID),
+		ActiveVersion:   &activeVersion.Version,
+		LivenessRecords: livenessRecords,
 	}, nil
 }
 
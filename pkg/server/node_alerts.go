@@ -0,0 +1,291 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/settings"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/httputil"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/stop"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	nodeAlertHookOff = iota
+	nodeAlertHookLog
+	nodeAlertHookWebhook
+)
+
+var nodeAlertHookSetting = settings.RegisterEnumSetting(
+	settings.SystemOnly,
+	"server.node_alerts.hook",
+	"the hook notified when the cluster declares a node dead and when the node recovers: "+
+		"off, log (an entry in the OPS logging channel) or webhook (a JSON POST request to "+
+		"server.node_alerts.webhook_url)",
+	"log",
+	map[int64]string{
+		nodeAlertHookOff:     "off",
+		nodeAlertHookLog:     "log",
+		nodeAlertHookWebhook: "webhook",
+	},
+)
+
+var nodeAlertWebhookURL = settings.RegisterStringSetting(
+	settings.SystemOnly,
+	"server.node_alerts.webhook_url",
+	"the URL node alerts are posted to when server.node_alerts.hook is set to webhook",
+	"",
+)
+
+var nodeAlertDedupInterval = settings.RegisterDurationSetting(
+	settings.SystemOnly,
+	"server.node_alerts.dedup_interval",
+	"the minimum interval between two alerts about the same node; the status of a node "+
+		"that changes more often is reported once the interval has passed, if it still "+
+		"differs from the last status reported",
+	5*time.Minute,
+	settings.NonNegativeDuration,
+)
+
+// nodeAlertCheckInterval is the interval at which the status of the nodes is
+// checked for alerts.
+const nodeAlertCheckInterval = 5 * time.Second
+
+// NodeAlert is an alert about a node being declared dead by the cluster, or
+// recovering.
+type NodeAlert struct {
+	NodeID roachpb.NodeID `json:"node_id"`
+	// Dead is true if the node was declared dead, and false if it recovered.
+	Dead bool `json:"dead"`
+	// Timestamp is the time at which the change was observed.
+	Timestamp time.Time `json:"timestamp"`
+	// LivenessExpiration is the expiration of the node's liveness record.
+	LivenessExpiration time.Time `json:"liveness_expiration"`
+	// StatusChanges is the number of times the status of the node changed since
+	// the previous alert about it. More than one means that alerts were
+	// suppressed because the node was flapping.
+	StatusChanges int `json:"status_changes"`
+}
+
+// NodeAlertHook is notified of node alerts. The built-in hooks are selected by
+// the server.node_alerts.hook cluster setting.
+type NodeAlertHook interface {
+	NotifyNodeAlert(ctx context.Context, alert NodeAlert) error
+}
+
+// logNodeAlertHook logs node alerts to the OPS logging channel.
+type logNodeAlertHook struct{}
+
+var _ NodeAlertHook = logNodeAlertHook{}
+
+// NotifyNodeAlert implements the NodeAlertHook interface.
+func (logNodeAlertHook) NotifyNodeAlert(ctx context.Context, alert NodeAlert) error {
+	if alert.Dead {
+		log.Ops.Warningf(ctx, "n%d is dead (liveness expired at %s, %d status changes since last alert)",
+			alert.NodeID, alert.LivenessExpiration, alert.StatusChanges)
+	} else {
+		log.Ops.Infof(ctx, "n%d has recovered (%d status changes since last alert)",
+			alert.NodeID, alert.StatusChanges)
+	}
+	return nil
+}
+
+// webhookNodeAlertHook posts node alerts, encoded as JSON, to a URL.
+type webhookNodeAlertHook struct {
+	url string
+}
+
+var _ NodeAlertHook = webhookNodeAlertHook{}
+
+// NotifyNodeAlert implements the NodeAlertHook interface.
+func (h webhookNodeAlertHook) NotifyNodeAlert(ctx context.Context, alert NodeAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	resp, err := httputil.Post(ctx, h.url, httputil.JSONContentType, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return errors.Newf("webhook %s responded with %s", h.url, resp.Status)
+	}
+	return nil
+}
+
+// nodeAlertState is the alerting state of a node.
+type nodeAlertState struct {
+	// dead is the status of the node last observed, and reportedDead the one
+	// last reported.
+	dead, reportedDead bool
+	// lastAlert is the time of the last alert about the node.
+	lastAlert time.Time
+	// changes is the number of status changes since the last alert.
+	changes int
+}
+
+// nodeAlerter tracks the status of the nodes, and produces alerts when they
+// change. Alerts are deduplicated per node: a node whose status changes back
+// and forth is reported at most once per server.node_alerts.dedup_interval,
+// and only if its status differs from the last one reported.
+type nodeAlerter struct {
+	nodes map[roachpb.NodeID]*nodeAlertState
+}
+
+func newNodeAlerter() *nodeAlerter {
+	return &nodeAlerter{nodes: make(map[roachpb.NodeID]*nodeAlertState)}
+}
+
+// observe records the status of the given node, and returns the alert to emit,
+// if any. Nodes are assumed to be alive when first observed.
+func (a *nodeAlerter) observe(
+	nodeID roachpb.NodeID, dead bool, now time.Time, dedupInterval time.Duration,
+) (NodeAlert, bool) {
+	st, ok := a.nodes[nodeID]
+	if !ok {
+		st = &nodeAlertState{}
+		a.nodes[nodeID] = st
+	}
+	if dead != st.dead {
+		st.dead = dead
+		st.changes++
+	}
+	if st.dead == st.reportedDead {
+		return NodeAlert{}, false
+	}
+	if !st.lastAlert.IsZero() && now.Sub(st.lastAlert) < dedupInterval {
+		return NodeAlert{}, false
+	}
+	alert := NodeAlert{
+		NodeID:        nodeID,
+		Dead:          st.dead,
+		Timestamp:     now,
+		StatusChanges: st.changes,
+	}
+	st.reportedDead = st.dead
+	st.lastAlert = now
+	st.changes = 0
+	return alert, true
+}
+
+// forget stops tracking the given node.
+func (a *nodeAlerter) forget(nodeID roachpb.NodeID) {
+	delete(a.nodes, nodeID)
+}
+
+// nodeAlertHook returns the hook to notify of node alerts, or nil if there is
+// none.
+func (s *Server) nodeAlertHook() NodeAlertHook {
+	if knobs, ok := s.cfg.TestingKnobs.Server.(*TestingKnobs); ok && knobs.NodeAlertHook != nil {
+		return knobs.NodeAlertHook
+	}
+	switch nodeAlertHookSetting.Get(&s.st.SV) {
+	case nodeAlertHookLog:
+		return logNodeAlertHook{}
+	case nodeAlertHookWebhook:
+		if url := nodeAlertWebhookURL.Get(&s.st.SV); url != "" {
+			return webhookNodeAlertHook{url: url}
+		}
+	}
+	return nil
+}
+
+// isLowestLiveNode returns whether the given node is the live node with the
+// lowest ID, which coordinates the notifications sent on behalf of the
+// cluster.
+func isLowestLiveNode(
+	self roachpb.NodeID, vitalities livenesspb.NodeVitalityMap, now hlc.Timestamp,
+) bool {
+	for nodeID, v := range vitalities {
+		if nodeID < self && v.IsLive(now) {
+			return false
+		}
+	}
+	return true
+}
+
+// startNodeAlertLoop starts a task that periodically checks the status of the
+// nodes, and notifies the node alert hook when one is declared dead or
+// recovers. Every server tracks the status of the nodes, but only the live
+// node with the lowest ID notifies the hook, so that each change is reported
+// once by the cluster.
+func (s *Server) startNodeAlertLoop(ctx context.Context) error {
+	return s.stopper.RunAsyncTaskEx(ctx,
+		stop.TaskOpts{TaskName: "node-alerts", SpanOpt: stop.SterileRootSpan},
+		func(ctx context.Context) {
+			ctx, cancel := s.stopper.WithCancelOnQuiesce(ctx)
+			defer cancel()
+
+			alerter := newNodeAlerter()
+			var timer timeutil.Timer
+			defer timer.Stop()
+			for {
+				timer.Reset(nodeAlertCheckInterval)
+				select {
+				case <-timer.C:
+					timer.Read = true
+					s.checkNodeAlerts(ctx, alerter)
+				case <-ctx.Done():
+					return
+				}
+			}
+		})
+}
+
+// checkNodeAlerts observes the status of every node, and notifies the node
+// alert hook of the resulting alerts. Nodes declared dead are also recorded in
+// the event log, with a report of the ranges that lost a replica with them.
+func (s *Server) checkNodeAlerts(ctx context.Context, alerter *nodeAlerter) {
+	now := s.clock.Now()
+	threshold := liveness.TimeUntilStoreDead.Get(&s.st.SV)
+	dedupInterval := nodeAlertDedupInterval.Get(&s.st.SV)
+	vitalities := s.nodeLiveness.ScanNodeVitalityFromCache()
+
+	coordinator := isLowestLiveNode(s.NodeID(), vitalities, now)
+	hook := s.nodeAlertHook()
+
+	for nodeID, v := range vitalities {
+		status := v.Status(now, threshold)
+		if status == livenesspb.NodeLivenessStatus_DECOMMISSIONED {
+			alerter.forget(nodeID)
+			continue
+		}
+		alert, ok := alerter.observe(nodeID, status == livenesspb.NodeLivenessStatus_DEAD,
+			now.GoTime(), dedupInterval)
+		// Alerts produced while this node isn't the coordinator are
+		// consumed, so that it doesn't report changes that were already
+		// reported if it becomes the coordinator later on.
+		if !ok || !coordinator {
+			continue
+		}
+		if alert.Dead {
+			s.logNodeDeadEvent(ctx, nodeID)
+		}
+		if hook == nil {
+			continue
+		}
+		alert.LivenessExpiration = v.Expiration.ToTimestamp().GoTime()
+		if err := hook.NotifyNodeAlert(ctx, alert); err != nil {
+			log.Ops.Warningf(ctx, "unable to notify alert about n%d: %v", nodeID, err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeAlerterDedup(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	const dedup = time.Minute
+	start := time.Unix(0, 0)
+	at := func(d time.Duration) time.Time { return start.Add(d) }
+
+	a := newNodeAlerter()
+	_, ok := a.observe(1, false /* dead */, at(0), dedup)
+	require.False(t, ok)
+
+	// The node is declared dead.
+	alert, ok := a.observe(1, true /* dead */, at(time.Second), dedup)
+	require.True(t, ok)
+	require.True(t, alert.Dead)
+	require.Equal(t, 1, alert.StatusChanges)
+	_, ok = a.observe(1, true /* dead */, at(2*time.Second), dedup)
+	require.False(t, ok)
+
+	// The node flaps within the dedup interval, which isn't reported.
+	_, ok = a.observe(1, false /* dead */, at(3*time.Second), dedup)
+	require.False(t, ok)
+	_, ok = a.observe(1, true /* dead */, at(4*time.Second), dedup)
+	require.False(t, ok)
+	_, ok = a.observe(1, false /* dead */, at(5*time.Second), dedup)
+	require.False(t, ok)
+
+	// Once the interval has passed, the recovery is reported.
+	alert, ok = a.observe(1, false /* dead */, at(time.Minute+time.Second), dedup)
+	require.True(t, ok)
+	require.False(t, alert.Dead)
+	require.Equal(t, 3, alert.StatusChanges)
+
+	// A node that is dead when first observed is reported right away.
+	alert, ok = a.observe(2, true /* dead */, at(0), dedup)
+	require.True(t, ok)
+	require.True(t, alert.Dead)
+
+	// A forgotten node starts over.
+	a.forget(2)
+	_, ok = a.observe(2, false /* dead */, at(time.Second), dedup)
+	require.False(t, ok)
+}
+
+func TestWebhookNodeAlertHook(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	var received []NodeAlert
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			http.Error(w, "failed", http.StatusInternalServerError)
+			return
+		}
+		var alert NodeAlert
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		received = append(received, alert)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	alert := NodeAlert{NodeID: 3, Dead: true, Timestamp: time.Unix(10, 0).UTC(), StatusChanges: 1}
+	require.NoError(t, webhookNodeAlertHook{url: srv.URL}.NotifyNodeAlert(ctx, alert))
+	require.Equal(t, []NodeAlert{alert}, received)
+
+	require.Error(t, webhookNodeAlertHook{url: srv.URL + "/fail"}.NotifyNodeAlert(ctx, alert))
+}
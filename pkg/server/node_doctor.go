@@ -0,0 +1,115 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/gossip"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
+)
+
+// The names of the checks run by NodeDoctor.
+const (
+	nodeDoctorIdentity  = "identity"
+	nodeDoctorClock     = "clock"
+	nodeDoctorHeartbeat = "heartbeat"
+	nodeDoctorGossip    = "gossip"
+)
+
+// runNodeDoctor runs the liveness sanity checks of the local node. The checks
+// are independent: a failing check doesn't prevent the next ones from running.
+func (s *systemStatusServer) runNodeDoctor(
+	ctx context.Context, nodeID roachpb.NodeID,
+) []serverpb.NodeDoctorResponse_Check {
+	var checks []serverpb.NodeDoctorResponse_Check
+	check := func(name string, ok bool, format string, args ...interface{}) {
+		checks = append(checks, serverpb.NodeDoctorResponse_Check{
+			Name:   name,
+			OK:     ok,
+			Detail: fmt.Sprintf(format, args...),
+		})
+	}
+
+	// The liveness record in KV is the source of truth: the other checks
+	// compare against it.
+	var kvLiveness livenesspb.Liveness
+	var kvFound bool
+	if livenesses, err := s.nodeLiveness.GetLivenessesFromKV(ctx); err != nil {
+		check(nodeDoctorIdentity, false, "unable to read liveness records: %v", err)
+	} else {
+		for _, l := range livenesses {
+			if l.NodeID == nodeID {
+				kvLiveness, kvFound = l, true
+				break
+			}
+		}
+		switch {
+		case !kvFound:
+			check(nodeDoctorIdentity, false, "no liveness record found for n%d", nodeID)
+		case !kvLiveness.Membership.Active():
+			check(nodeDoctorIdentity, false, "liveness record of n%d is %s", nodeID, kvLiveness.Membership)
+		default:
+			check(nodeDoctorIdentity, true, "liveness record matches n%d", nodeID)
+		}
+	}
+
+	// The clock offset is measured against the leaseholder of the node
+	// liveness range, since it's the clock that liveness expirations are
+	// effectively judged by.
+	tolerated := s.clock.ToleratedOffset()
+	if _, leaseholder, err := s.livenessRangePlacement(ctx); err != nil {
+		check(nodeDoctorClock, false, "unable to locate the node liveness leaseholder: %v", err)
+	} else if leaseholder == nodeID {
+		check(nodeDoctorClock, true, "n%d holds the node liveness lease", nodeID)
+	} else if offset := s.rpcCtx.RemoteClocks.GetOffset(leaseholder); offset.MeasuredAt == 0 {
+		check(nodeDoctorClock, false, "no clock offset measured against the node liveness leaseholder n%d", leaseholder)
+	} else {
+		abs := offset.Offset
+		if abs < 0 {
+			abs = -abs
+		}
+		// Like RemoteClockMonitor, only fail if the offset is definitely above
+		// the tolerated offset.
+		ok := tolerated == 0 || time.Duration(abs-offset.Uncertainty) <= tolerated
+		check(nodeDoctorClock, ok, "offset of %s (+/- %s) against the node liveness leaseholder n%d, tolerated offset %s",
+			time.Duration(offset.Offset), time.Duration(offset.Uncertainty), leaseholder, tolerated)
+	}
+
+	now := s.clock.Now()
+	if !kvFound {
+		check(nodeDoctorHeartbeat, false, "no liveness record to heartbeat")
+	} else if kvLiveness.IsLive(now) {
+		check(nodeDoctorHeartbeat, true, "liveness record expires in %s",
+			kvLiveness.Expiration.ToTimestamp().GoTime().Sub(now.GoTime()))
+	} else {
+		check(nodeDoctorHeartbeat, false, "liveness record expired %s ago",
+			now.GoTime().Sub(kvLiveness.Expiration.ToTimestamp().GoTime()))
+	}
+
+	var gossiped livenesspb.Liveness
+	if _, err := s.gossip.GetNodeDescriptor(nodeID); err != nil {
+		check(nodeDoctorGossip, false, "node descriptor not gossiped: %v", err)
+	} else if err := s.gossip.GetInfoProto(gossip.MakeNodeLivenessKey(nodeID), &gossiped); err != nil {
+		check(nodeDoctorGossip, false, "liveness record not gossiped: %v", err)
+	} else if kvFound && gossiped.Epoch != kvLiveness.Epoch {
+		check(nodeDoctorGossip, false, "gossiped liveness epoch %d, but %d in KV", gossiped.Epoch, kvLiveness.Epoch)
+	} else if !gossiped.IsLive(now) {
+		check(nodeDoctorGossip, false, "gossiped liveness record expired at %s", gossiped.Expiration)
+	} else {
+		check(nodeDoctorGossip, true, "gossiped liveness record is current")
+	}
+	return checks
+}
@@ -0,0 +1,128 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"context"
+	"sort"
+
+	"github.com/cockroachdb/cockroach/pkg/kv"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvclient"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
+	"github.com/cockroachdb/cockroach/pkg/sql"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/cockroach/pkg/util/log/eventpb"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+)
+
+// maxNodeDeadEventRangeIDs is the maximum number of unavailable ranges listed
+// in a node_dead event.
+const maxNodeDeadEventRangeIDs = 100
+
+// makeNodeRecoveryReport returns the report of the ranges that lost a replica
+// with the given node. A range is considered unavailable if fewer than a
+// quorum of its voters are on nodes for which isLive returns true.
+func makeNodeRecoveryReport(
+	ctx context.Context, db *kv.DB, nodeID roachpb.NodeID, isLive func(roachpb.NodeID) bool,
+) (*serverpb.NodeRecoveryReportResponse, error) {
+	res := &serverpb.NodeRecoveryReportResponse{NodeID: nodeID}
+	if err := db.Txn(ctx, func(ctx context.Context, txn *kv.Txn) error {
+		res.Ranges = res.Ranges[:0]
+		res.UnderReplicatedRanges, res.UnavailableRanges = 0, 0
+
+		kvs, err := kvclient.ScanMetaKVs(ctx, txn, roachpb.Span{
+			Key:    roachpb.KeyMin,
+			EndKey: roachpb.KeyMax,
+		})
+		if err != nil {
+			return err
+		}
+		var desc roachpb.RangeDescriptor
+		for _, kv := range kvs {
+			if err := kv.ValueProto(&desc); err != nil {
+				return err
+			}
+			var onNode bool
+			for _, r := range desc.Replicas().Descriptors() {
+				onNode = onNode || r.NodeID == nodeID
+			}
+			if !onNode {
+				continue
+			}
+			voters := desc.Replicas().VoterDescriptors()
+			var liveVoters int32
+			for _, r := range voters {
+				if r.NodeID != nodeID && isLive(r.NodeID) {
+					liveVoters++
+				}
+			}
+			unavailable := int(liveVoters) < len(voters)/2+1
+			if unavailable {
+				res.UnavailableRanges++
+			} else {
+				res.UnderReplicatedRanges++
+			}
+			res.Ranges = append(res.Ranges, serverpb.NodeRecoveryReportResponse_Range{
+				RangeID:     desc.RangeID,
+				StartKey:    desc.StartKey,
+				Voters:      int32(len(voters)),
+				LiveVoters:  liveVoters,
+				Unavailable: unavailable,
+			})
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Slice(res.Ranges, func(i, j int) bool {
+		return res.Ranges[i].RangeID < res.Ranges[j].RangeID
+	})
+	return res, nil
+}
+
+// logNodeDeadEvent records a node_dead event for the given node, with the
+// report of the ranges that lost a replica with it.
+func (s *Server) logNodeDeadEvent(ctx context.Context, nodeID roachpb.NodeID) {
+	now := s.clock.Now()
+	vitalities := s.nodeLiveness.ScanNodeVitalityFromCache()
+	report, err := makeNodeRecoveryReport(ctx, s.db, nodeID, func(nodeID roachpb.NodeID) bool {
+		v, ok := vitalities[nodeID]
+		return ok && v.IsLive(now)
+	})
+	if err != nil {
+		log.Ops.Warningf(ctx, "unable to compute the recovery report of n%d: %v", nodeID, err)
+		return
+	}
+
+	ev := &eventpb.NodeDead{
+		ReportingNodeID:       int32(s.NodeID()),
+		TargetNodeID:          int32(nodeID),
+		UnderReplicatedRanges: report.UnderReplicatedRanges,
+		UnavailableRanges:     report.UnavailableRanges,
+	}
+	ev.CommonDetails().Timestamp = timeutil.Now().UnixNano()
+	for _, r := range report.Ranges {
+		if len(ev.UnavailableRangeIDs) == maxNodeDeadEventRangeIDs {
+			break
+		}
+		if r.Unavailable {
+			ev.UnavailableRangeIDs = append(ev.UnavailableRangeIDs, int64(r.RangeID))
+		}
+	}
+	log.StructuredEvent(ctx, ev)
+	sql.InsertEventRecords(
+		ctx,
+		s.sqlServer.execCfg,
+		sql.LogToSystemTable|sql.LogToDevChannelIfVerbose, /* not LogExternally: we already call log.StructuredEvent above */
+		ev,
+	)
+}
@@ -0,0 +1,80 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
+	"github.com/cockroachdb/cockroach/pkg/testutils/serverutils"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeRecoveryReport(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := serverutils.StartNewTestCluster(t, 3, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	// With manual replication, only the scratch range has a replica on n3.
+	key := tc.ScratchRange(t)
+	desc := tc.AddVotersOrFatal(t, key, tc.Target(1), tc.Target(2))
+	deadNodeID := tc.Server(2).NodeID()
+	s := tc.Server(0).(*TestServer)
+
+	liveExcept := func(dead ...roachpb.NodeID) func(roachpb.NodeID) bool {
+		return func(nodeID roachpb.NodeID) bool {
+			for _, d := range dead {
+				if nodeID == d {
+					return false
+				}
+			}
+			return true
+		}
+	}
+
+	// Losing n3 leaves the range with a quorum.
+	report, err := makeNodeRecoveryReport(ctx, s.db, deadNodeID, liveExcept(deadNodeID))
+	require.NoError(t, err)
+	require.Equal(t, []serverpb.NodeRecoveryReportResponse_Range{{
+		RangeID:    desc.RangeID,
+		StartKey:   desc.StartKey,
+		Voters:     3,
+		LiveVoters: 2,
+	}}, report.Ranges)
+	require.Equal(t, int32(1), report.UnderReplicatedRanges)
+	require.Zero(t, report.UnavailableRanges)
+
+	// Losing n2 as well makes the range unavailable.
+	report, err = makeNodeRecoveryReport(ctx, s.db, deadNodeID,
+		liveExcept(deadNodeID, tc.Server(1).NodeID()))
+	require.NoError(t, err)
+	require.Len(t, report.Ranges, 1)
+	require.True(t, report.Ranges[0].Unavailable)
+	require.Zero(t, report.UnderReplicatedRanges)
+	require.Equal(t, int32(1), report.UnavailableRanges)
+
+	// The endpoint reports on the live cluster, where the range is healthy
+	// but does have a replica on n3.
+	res, err := s.status.NodeRecoveryReport(ctx, &serverpb.NodeRecoveryReportRequest{NodeID: deadNodeID})
+	require.NoError(t, err)
+	require.Len(t, res.Ranges, 1)
+	require.Equal(t, desc.RangeID, res.Ranges[0].RangeID)
+}
@@ -28,6 +28,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/kv/kvpb"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/kvstorage"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/server/status"
 	"github.com/cockroachdb/cockroach/pkg/server/status/statuspb"
@@ -241,6 +242,34 @@ func TestNodeJoin(t *testing.T) {
 	})
 }
 
+// TestNodeJoinLivenessRecords verifies that the join response carries the
+// liveness records of the cluster, including the one of the joining node.
+func TestNodeJoinLivenessRecords(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+	ctx := context.Background()
+
+	s, _, _ := serverutils.StartServer(t, base.TestServerArgs{})
+	defer s.Stopper().Stop(ctx)
+	ts := s.(*TestServer)
+
+	resp, err := ts.node.Join(ctx, &kvpb.JoinNodeRequest{
+		BinaryVersion: &clusterversion.TestingBinaryVersion,
+	})
+	require.NoError(t, err)
+
+	var nodeIDs []roachpb.NodeID
+	for _, raw := range resp.LivenessRecords {
+		var v roachpb.Value
+		v.SetTagAndData(raw)
+		var l livenesspb.Liveness
+		require.NoError(t, v.GetProto(&l))
+		nodeIDs = append(nodeIDs, l.NodeID)
+	}
+	sort.Slice(nodeIDs, func(i, j int) bool { return nodeIDs[i] < nodeIDs[j] })
+	require.Equal(t, []roachpb.NodeID{s.NodeID(), roachpb.NodeID(resp.NodeID)}, nodeIDs)
+}
+
 // TestCorruptedClusterID verifies that a node fails to start when a
 // store's cluster ID is empty.
 func TestCorruptedClusterID(t *testing.T) {
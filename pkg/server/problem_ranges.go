@@ -14,6 +14,7 @@ import (
 	"context"
 	"sort"
 
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
@@ -54,6 +55,10 @@ func (s *systemStatusServer) ProblemRanges(
 		err    error
 	}
 
+	// replicaStores tracks the stores holding replicas of the problem ranges,
+	// grouped by node, to report on the vitality of these nodes.
+	replicaStores := make(map[roachpb.NodeID]map[roachpb.StoreID]struct{})
+
 	responses := make(chan nodeResponse)
 	// TODO(bram): consider abstracting out this repeated pattern.
 	for nodeID := range isLiveMap {
@@ -101,6 +106,22 @@ func (s *systemStatusServer) ProblemRanges(
 					}
 					continue
 				}
+				if info.Problems != (serverpb.RangeProblems{}) {
+					rangeID := info.State.Desc.RangeID
+					if problems.ReplicaNodeIDsByRangeID == nil {
+						problems.ReplicaNodeIDsByRangeID =
+							make(map[roachpb.RangeID]serverpb.ProblemRangesResponse_ReplicaNodeIDs)
+					}
+					var replicaNodes serverpb.ProblemRangesResponse_ReplicaNodeIDs
+					for _, r := range info.State.Desc.Replicas().Descriptors() {
+						replicaNodes.NodeIDs = append(replicaNodes.NodeIDs, r.NodeID)
+						if replicaStores[r.NodeID] == nil {
+							replicaStores[r.NodeID] = make(map[roachpb.StoreID]struct{})
+						}
+						replicaStores[r.NodeID][r.StoreID] = struct{}{}
+					}
+					problems.ReplicaNodeIDsByRangeID[rangeID] = replicaNodes
+				}
 				if info.Problems.Unavailable {
 					problems.UnavailableRangeIDs =
 						append(problems.UnavailableRangeIDs, info.State.Desc.RangeID)
@@ -158,5 +179,43 @@ func (s *systemStatusServer) ProblemRanges(
 		}
 	}
 
+	response.VitalityByNodeID = make(
+		map[roachpb.NodeID]serverpb.ProblemRangesResponse_NodeVitality, len(replicaStores))
+	for nodeID, storeIDs := range replicaStores {
+		response.VitalityByNodeID[nodeID] = s.problemRangesNodeVitality(nodeID, storeIDs)
+	}
+
 	return response, nil
 }
+
+// problemRangesNodeVitality returns the vitality of the given node, which
+// holds replicas of problem ranges on the given stores. The node is considered
+// suspect if any of these stores is.
+func (s *systemStatusServer) problemRangesNodeVitality(
+	nodeID roachpb.NodeID, storeIDs map[roachpb.StoreID]struct{},
+) serverpb.ProblemRangesResponse_NodeVitality {
+	vitality, ok := s.nodeLiveness.GetNodeVitality(nodeID)
+	if !ok {
+		return serverpb.ProblemRangesResponse_NodeVitality{
+			Status: livenesspb.NodeLivenessStatus_UNKNOWN,
+		}
+	}
+	now := s.clock.Now()
+	result := serverpb.ProblemRangesResponse_NodeVitality{
+		Status:   vitality.Status(now, liveness.TimeUntilStoreDead.Get(&s.st.SV)),
+		Draining: vitality.Draining || vitality.InMaintenance(now),
+	}
+	// A healthy node renews its liveness record about halfway through its TTL,
+	// so less than half of the TTL remaining means that a heartbeat was missed.
+	if vitality.IsLive(now) {
+		ttl := vitality.TTL(s.nodeLiveness.GetLivenessThreshold())
+		result.NearExpiration = vitality.Expiration.ToTimestamp().AddDuration(-ttl / 2).LessEq(now)
+	}
+	for storeID := range storeIDs {
+		if suspect, err := s.storePool.IsSuspect(storeID); err == nil && suspect {
+			result.Suspect = true
+			break
+		}
+	}
+	return result
+}
@@ -0,0 +1,101 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"context"
+	"sort"
+
+	"github.com/cockroachdb/cockroach/pkg/keys"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/util/rangedesc"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+// safeToShutdownResult is the result of checking whether a node can be
+// stopped without causing any range to lose quorum.
+type safeToShutdownResult struct {
+	unavailableRangeCount int64
+	unavailableRanges     []roachpb.RangeID
+	nonLiveNodes          []roachpb.NodeID
+}
+
+// SafeToShutdown evaluates whether the given node can be stopped right now
+// without causing range unavailability. A range is considered to become
+// unavailable if, after removing the node's replica, the remaining live voters
+// can no longer form a quorum. Peers are considered live according to the
+// local liveness cache. At most maxRanges unavailable ranges are returned,
+// although all of them are counted.
+// The error returned is a gRPC error.
+func (s *Server) SafeToShutdown(
+	ctx context.Context, nodeID roachpb.NodeID, maxRanges int,
+) (safeToShutdownResult, error) {
+	isLiveMap := s.nodeLiveness.GetIsLiveMap()
+	var res safeToShutdownResult
+	for nID, entry := range isLiveMap {
+		// Nodes that died while decommissioning won't come back, their absence
+		// isn't news. Decommissioned nodes aren't part of the map at all.
+		if entry.LivenessStatus == livenesspb.NodeLivenessStatus_DECOMMISSIONED {
+			continue
+		}
+		if nID != nodeID && !entry.IsLive {
+			res.nonLiveNodes = append(res.nonLiveNodes, nID)
+		}
+	}
+	sort.Slice(res.nonLiveNodes, func(i, j int) bool {
+		return res.nonLiveNodes[i] < res.nonLiveNodes[j]
+	})
+
+	const pageSize = 10000
+	initCounters := func() {
+		res.unavailableRangeCount = 0
+		res.unavailableRanges = res.unavailableRanges[:0]
+	}
+	rangeDescScanner := rangedesc.NewScanner(s.db)
+	if err := rangeDescScanner.Scan(ctx, pageSize, initCounters, keys.EverythingSpan,
+		func(descriptors ...roachpb.RangeDescriptor) error {
+			for _, desc := range descriptors {
+				if !desc.Replicas().HasReplicaOnNode(nodeID) {
+					continue
+				}
+				if canMakeProgressWithout(&desc, isLiveMap, nodeID) {
+					continue
+				}
+				res.unavailableRangeCount++
+				if len(res.unavailableRanges) < maxRanges {
+					res.unavailableRanges = append(res.unavailableRanges, desc.RangeID)
+				}
+			}
+			return nil
+		}); err != nil {
+		return safeToShutdownResult{}, grpcstatus.Errorf(codes.Internal, err.Error())
+	}
+	return res, nil
+}
+
+// canMakeProgressWithout returns whether the range can make progress if the
+// given node were to fail. A replica is considered able to participate in a
+// quorum if it lives on a live node other than the failed one. Nodes missing
+// from the liveness map (i.e. decommissioned nodes) are considered non-live.
+// Passing a zero nodeID evaluates the range as is.
+func canMakeProgressWithout(
+	desc *roachpb.RangeDescriptor, isLiveMap livenesspb.IsLiveMap, nodeID roachpb.NodeID,
+) bool {
+	return desc.Replicas().CanMakeProgress(func(rDesc roachpb.ReplicaDescriptor) bool {
+		if rDesc.NodeID == nodeID {
+			return false
+		}
+		entry, ok := isLiveMap[rDesc.NodeID]
+		return ok && entry.IsLive
+	})
+}
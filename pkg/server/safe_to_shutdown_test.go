@@ -0,0 +1,63 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/cockroach/pkg/testutils/serverutils"
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/cockroachdb/cockroach/pkg/util/log"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSafeToShutdown verifies that a node is reported as safe to stop while
+// all of its peers are live, and unsafe once a peer holding replicas of the
+// same ranges has been stopped.
+func TestSafeToShutdown(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := serverutils.StartNewTestCluster(t, 3, base.TestClusterArgs{})
+	defer tc.Stopper().Stop(ctx)
+	require.NoError(t, tc.WaitForFullReplication())
+
+	firstSvr := tc.Server(0).(*TestServer)
+	thirdNodeID := tc.Server(2).NodeID()
+
+	res, err := firstSvr.SafeToShutdown(ctx, thirdNodeID, 10 /* maxRanges */)
+	require.NoError(t, err)
+	require.Zero(t, res.unavailableRangeCount)
+	require.Empty(t, res.nonLiveNodes)
+
+	secondNodeID := tc.Server(1).NodeID()
+	tc.StopServer(1)
+
+	testutils.SucceedsSoon(t, func() error {
+		res, err := firstSvr.SafeToShutdown(ctx, thirdNodeID, 10 /* maxRanges */)
+		if err != nil {
+			return err
+		}
+		if len(res.nonLiveNodes) != 1 || res.nonLiveNodes[0] != secondNodeID {
+			return errors.Errorf("expected n%d to be non-live, found %v", secondNodeID, res.nonLiveNodes)
+		}
+		if res.unavailableRangeCount == 0 {
+			return errors.New("expected ranges to become unavailable")
+		}
+		require.LessOrEqual(t, len(res.unavailableRanges), 10)
+		return nil
+	})
+}
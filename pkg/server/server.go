@@ -53,6 +53,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/kvstorage"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesswatcher"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/loqrecovery"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/protectedts"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/protectedts/ptprovider"
@@ -126,6 +127,7 @@ import (
 	"github.com/cockroachdb/redact"
 	sentry "github.com/getsentry/sentry-go"
 	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
 // Server is the cockroach server node.
@@ -143,6 +145,7 @@ type Server struct {
 	gossip           *gossip.Gossip
 	nodeDialer       *nodedialer.Dialer
 	nodeLiveness     *liveness.NodeLiveness
+	livenessWatcher  *livenesswatcher.Watcher
 	storePool        *storepool.StorePool
 	tcsFactory       *kvcoord.TxnCoordSenderFactory
 	distSender       *kvcoord.DistSender
@@ -487,6 +490,10 @@ func NewServer(cfg Config, stopper *stop.Stopper) (*Server, error) {
 	decomNodeMap := &decommissioningNodeMap{
 		nodes: make(map[roachpb.NodeID]interface{}),
 	}
+	// The metrics recorder depends on node liveness, but is needed by the
+	// liveness callbacks to finalize this node's metrics once it has been
+	// decommissioned. It is assigned below.
+	var recorder *status.MetricsRecorder
 	nodeLiveness := liveness.NewNodeLiveness(liveness.NodeLivenessOptions{
 		AmbientCtx:              cfg.AmbientCtx,
 		Stopper:                 stopper,
@@ -495,8 +502,13 @@ func NewServer(cfg Config, stopper *stop.Stopper) (*Server, error) {
 		Gossip:                  g,
 		LivenessThreshold:       nlActive,
 		RenewalDuration:         nlRenewal,
+		Cold:                    cfg.ColdNode,
 		Settings:                st,
 		HistogramWindowInterval: cfg.HistogramWindowInterval(),
+		NodeDialer:              nodeDialer,
+		Prober:                  &swimProber{nodeDialer: nodeDialer},
+		MaxClockOffset:          rpcContext.RemoteClocks.MaxOffset,
+		ExternalProbes:          cfg.ExternalFailureProbes,
 		// When we learn that a node is decommissioning, we want to proactively
 		// enqueue the ranges we have that also have a replica on the
 		// decommissioning node.
@@ -512,8 +524,18 @@ func NewServer(cfg Config, stopper *stop.Stopper) (*Server, error) {
 			}
 
 			decomNodeMap.onNodeDecommissioned(liveness.NodeID)
+
+			// Once this node is decommissioned, stop recording its metrics and
+			// status so that its last status record remains archived.
+			if liveness.NodeID == nodeIDContainer.Get() && recorder != nil {
+				recorder.Archive()
+			}
 		},
-		Engines: engines,
+		Engines:                  engines,
+		CheckApplicationProgress: stores.CheckApplicationProgress,
+		UnderMemoryPressure:      runtimeSampler.UnderMemoryPressure,
+		Overloaded:               runtimeSampler.Overloaded,
+		StoreDigests:             stores.StoreDigests,
 		OnSelfHeartbeat: func(ctx context.Context) {
 			now := clock.Now()
 			if err := stores.VisitStores(func(s *kvserver.Store) error {
@@ -526,6 +548,8 @@ func NewServer(cfg Config, stopper *stop.Stopper) (*Server, error) {
 
 	registry.AddMetricStruct(nodeLiveness.Metrics())
 
+	livenessWatcher := livenesswatcher.New(clock, rangeFeedFactory, stopper, st.Version, nlActive)
+
 	nodeLivenessFn := storepool.MakeStorePoolNodeLivenessFunc(nodeLiveness)
 	if nodeLivenessKnobs, ok := cfg.TestingKnobs.NodeLiveness.(kvserver.NodeLivenessTestingKnobs); ok {
 		if nodeLivenessKnobs.StorePoolNodeLivenessFn != nil {
@@ -545,6 +569,7 @@ func NewServer(cfg Config, stopper *stop.Stopper) (*Server, error) {
 		nodeLivenessFn,
 		/* deterministic */ false,
 	)
+	storePool.NodeVitalityFn = nodeLiveness.GetNodeVitality
 
 	storesForFlowControl := kvserver.MakeStoresForFlowControl(stores)
 	kvflowTokenDispatch := kvflowdispatch.New(registry, storesForFlowControl, nodeIDContainer)
@@ -859,7 +884,7 @@ func NewServer(cfg Config, stopper *stop.Stopper) (*Server, error) {
 
 	systemTenantNameContainer := roachpb.NewTenantNameContainer(catconstants.SystemTenantName)
 
-	recorder := status.NewMetricsRecorder(
+	recorder = status.NewMetricsRecorder(
 		rpcContext.TenantID,
 		systemTenantNameContainer,
 		nodeLiveness,
@@ -910,6 +935,7 @@ func NewServer(cfg Config, stopper *stop.Stopper) (*Server, error) {
 	kvpb.RegisterInternalServer(grpcServer.Server, node)
 	kvserver.RegisterPerReplicaServer(grpcServer.Server, node.perReplicaServer)
 	kvserver.RegisterPerStoreServer(grpcServer.Server, node.perReplicaServer)
+	livenesspb.RegisterHeartbeatAggregatorServer(grpcServer.Server, nodeLiveness)
 	ctpb.RegisterSideTransportServer(grpcServer.Server, ctReceiver)
 
 	// Create blob service for inter-node file sharing.
@@ -931,6 +957,7 @@ func NewServer(cfg Config, stopper *stop.Stopper) (*Server, error) {
 	replicationReporter := reports.NewReporter(
 		db, node.stores, storePool, st, nodeLiveness, internalExecutor, systemConfigWatcher,
 	)
+	registry.AddMetricStruct(replicationReporter.Metrics())
 
 	lateBoundServer := &Server{}
 
@@ -1188,6 +1215,8 @@ func NewServer(cfg Config, stopper *stop.Stopper) (*Server, error) {
 		drain,
 		lateBoundServer,
 	)
+	// Tell the decommission jobs how to carry out decommissions.
+	sqlServer.execCfg.NodeDecommissioner = sAdmin
 
 	// Connect the various servers to RPC.
 	for i, gw := range []grpcGatewayServer{sAdmin, sStatus, sAuth, &sTS} {
@@ -1196,6 +1225,7 @@ func NewServer(cfg Config, stopper *stop.Stopper) (*Server, error) {
 		}
 		gw.RegisterService(grpcServer.Server)
 	}
+	healthpb.RegisterHealthServer(grpcServer.Server, &grpcHealthServer{admin: sAdmin, stopper: stopper})
 
 	// Tell the node event logger (join, restart) how to populate SQL entries
 	// into system.eventlog.
@@ -1260,6 +1290,7 @@ func NewServer(cfg Config, stopper *stop.Stopper) (*Server, error) {
 		gossip:                    g,
 		nodeDialer:                nodeDialer,
 		nodeLiveness:              nodeLiveness,
+		livenessWatcher:           livenessWatcher,
 		storePool:                 storePool,
 		tcsFactory:                tcsFactory,
 		distSender:                distSender,
@@ -1696,6 +1727,10 @@ func (s *Server) PreStart(ctx context.Context) error {
 	s.rpcContext.StorageClusterID.Set(ctx, state.clusterID)
 	s.rpcContext.NodeID.Set(ctx, state.nodeID)
 
+	// A node that just joined the cluster learns about the other nodes from
+	// the liveness records of the join response, instead of waiting for gossip.
+	s.nodeLiveness.SeedCache(ctx, state.livenessRecords)
+
 	// Ensure components in the DistSQLPlanner that rely on the node ID are
 	// initialized before store startup continues.
 	s.sqlServer.execCfg.DistSQLPlanner.SetGatewaySQLInstanceID(base.SQLInstanceID(state.nodeID))
@@ -1943,10 +1978,21 @@ func (s *Server) PreStart(ctx context.Context) error {
 
 	log.Event(ctx, "accepting connections")
 
+	// Before heartbeating, make sure our clock hasn't gone backwards since the
+	// last heartbeat this node performed before it was restarted.
+	if !initialStart {
+		if err := s.checkClockPastPreviousLiveness(ctx); err != nil {
+			return err
+		}
+	}
+
 	// Begin the node liveness heartbeat. Add a callback which records the local
 	// store "last up" timestamp for every store whenever the liveness record is
 	// updated.
 	s.nodeLiveness.Start(workersCtx)
+	if err := s.livenessWatcher.Start(workersCtx); err != nil {
+		return err
+	}
 
 	// Begin recording status summaries.
 	if err := s.node.startWriteNodeStatus(base.DefaultMetricsSampleInterval); err != nil {
@@ -2045,6 +2091,26 @@ func (s *Server) PreStart(ctx context.Context) error {
 		s.startDiagnostics(workersCtx)
 	}
 
+	// Start carrying out scheduled decommissions.
+	if err := s.startScheduledDecommissionLoop(workersCtx); err != nil {
+		return err
+	}
+
+	// Start reconciling the membership of the nodes with the membership spec.
+	if err := s.admin.startMembershipReconcileLoop(workersCtx); err != nil {
+		return err
+	}
+
+	// Start alerting about dead nodes.
+	if err := s.startNodeAlertLoop(workersCtx); err != nil {
+		return err
+	}
+
+	// Start notifying service discovery backends of membership changes.
+	if err := s.startMembershipWebhookLoop(workersCtx); err != nil {
+		return err
+	}
+
 	s.eventsExporter.SetNodeInfo(obs.NodeInfo{
 		ClusterID:     state.clusterID,
 		NodeID:        int32(state.nodeID),
@@ -2153,6 +2219,15 @@ func (s *Server) PreStart(ctx context.Context) error {
 func (s *Server) AcceptClients(ctx context.Context) error {
 	workersCtx := s.AnnotateCtx(context.Background())
 
+	// A drain that was running when the node went down was cut short.
+	s.drain.endLastDrain(ctx, livenesspb.DrainOperation_INTERRUPTED)
+
+	// A node drained with a sticky drain comes back up draining.
+	stickyDrained, err := s.drain.resumeStickyDrain(ctx)
+	if err != nil {
+		return err
+	}
+
 	if err := startServeSQL(
 		workersCtx,
 		s.stopper,
@@ -2174,7 +2249,9 @@ func (s *Server) AcceptClients(ctx context.Context) error {
 		return err
 	}
 
-	s.sqlServer.isReady.Set(true)
+	if !stickyDrained {
+		s.sqlServer.isReady.Set(true)
+	}
 
 	log.Event(ctx, "server ready")
 	return nil
@@ -2237,6 +2314,12 @@ func (s *Server) SpanConfigReporter() spanconfig.Reporter {
 	return s.spanConfigReporter
 }
 
+// LivenessWatcher returns the livenesswatcher.Watcher, through which the
+// liveness records can be followed without going through gossip.
+func (s *Server) LivenessWatcher() *livenesswatcher.Watcher {
+	return s.livenessWatcher
+}
+
 // LogicalClusterID implements cli.serverStartupInterface. This
 // implementation exports the logical cluster ID of the system tenant.
 func (s *Server) LogicalClusterID() uuid.UUID {
@@ -2278,10 +2361,17 @@ func (s *Server) TenantCapabilitiesReader() tenantcapabilities.Reader {
 // TODO(knz): This method is currently exported for use by the
 // shutdown code in cli/start.go; however, this is a mis-design. The
 // start code should use the Drain() RPC like quit does.
+//
+// Since it is only used ahead of a shutdown, the node announces its departure
+// to the cluster once the drain is complete.
 func (s *Server) Drain(
 	ctx context.Context, verbose bool,
 ) (remaining uint64, info redact.RedactableString, err error) {
-	return s.drain.runDrain(ctx, verbose)
+	remaining, info, err = s.drain.runDrain(ctx, verbose, "" /* reason */)
+	if err == nil && remaining == 0 {
+		s.drain.markDeparting(ctx)
+	}
+	return remaining, info, err
 }
 
 // MakeServerOptionsForURL creates the input for MakeURLForServer().
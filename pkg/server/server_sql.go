@@ -40,7 +40,9 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/kv/kvclient/rangefeed"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvclient/rangestats"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/allocator/storepool"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/kvserverbase"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/protectedts"
 	"github.com/cockroachdb/cockroach/pkg/multitenant"
 	"github.com/cockroachdb/cockroach/pkg/multitenant/tenantcapabilities"
@@ -618,6 +620,33 @@ func newSQLServer(ctx context.Context, cfg sqlServerArgs) (*SQLServer, error) {
 		cfg.podNodeDialer = nodedialer.New(cfg.rpcContext, addressResolver)
 	}
 
+	nodeLiveness, hasNodeLiveness := cfg.nodeLiveness.Optional(47900)
+
+	// isEligibleCoordinator tells whether a node is fit to adopt jobs and
+	// coordinate long-running flows such as changefeeds. Nodes that are about
+	// to go away (draining, decommissioning) or that just came back from being
+	// unavailable (suspect) are avoided, unless no node at all is eligible, in
+	// which case holding off would only stall the jobs.
+	var isEligibleCoordinator func(sqlInstanceID base.SQLInstanceID) bool
+	if hasNodeLiveness {
+		isEligibleCoordinator = func(sqlInstanceID base.SQLInstanceID) bool {
+			suspectDuration := storepool.TimeAfterStoreSuspect.Get(&cfg.Settings.SV)
+			if nodeLiveness.IsEligibleTarget(
+				roachpb.NodeID(sqlInstanceID), livenesspb.TargetJobCoordination, suspectDuration,
+			) {
+				return true
+			}
+			view := nodeLiveness.GetLivenessView()
+			return !view.HasEligibleTarget(livenesspb.TargetJobCoordination, suspectDuration)
+		}
+	} else {
+		// Tenants have no node liveness to consult; the sqlliveness sessions
+		// of their instances already govern job adoption.
+		isEligibleCoordinator = func(sqlInstanceID base.SQLInstanceID) bool {
+			return true
+		}
+	}
+
 	jobRegistry := cfg.circularJobRegistry
 	{
 		cfg.registry.AddMetricStruct(cfg.sqlLivenessProvider.Metrics())
@@ -644,6 +673,7 @@ func newSQLServer(ctx context.Context, cfg sqlServerArgs) (*SQLServer, error) {
 				return sql.MakeJobExecContext(ctx, opName, user, &sql.MemoryMetrics{}, execCfg)
 			},
 			jobAdoptionStopFile,
+			isEligibleCoordinator,
 			td,
 			jobsKnobs,
 		)
@@ -887,15 +917,18 @@ func newSQLServer(ctx context.Context, cfg sqlServerArgs) (*SQLServer, error) {
 	}
 
 	var isAvailable func(sqlInstanceID base.SQLInstanceID) bool
-	nodeLiveness, hasNodeLiveness := cfg.nodeLiveness.Optional(47900)
 	if hasNodeLiveness {
 		// TODO(erikgrinaker): We may want to use IsAvailableNotDraining instead, to
 		// avoid scheduling long-running flows (e.g. rangefeeds or backups) on nodes
 		// that are being drained/decommissioned. However, these nodes can still be
 		// leaseholders, and preventing processor scheduling on them can cause a
 		// performance cliff for e.g. table reads that then hit the network.
+		//
+		// Nodes under memory pressure are likely to crash soon, taking the flows
+		// scheduled on them down with them, so we don't plan on them either.
 		isAvailable = func(sqlInstanceID base.SQLInstanceID) bool {
-			return nodeLiveness.IsAvailable(roachpb.NodeID(sqlInstanceID))
+			nodeID := roachpb.NodeID(sqlInstanceID)
+			return nodeLiveness.IsAvailable(nodeID) && !nodeLiveness.IsUnderMemoryPressure(nodeID)
 		}
 	} else {
 		// We're on a SQL tenant, so this is the only node DistSQL will ever
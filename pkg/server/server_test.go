@@ -585,6 +585,48 @@ func TestEnsureInitialWallTimeMonotonicity(t *testing.T) {
 	}
 }
 
+func TestEnsureClockPastPreviousLiveness(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	const maxWait = time.Minute
+	testCases := []struct {
+		name          string
+		lastHeartbeat time.Duration // relative to the clock's start time
+		expErr        bool
+	}{
+		{name: "no previous record"},
+		{name: "clock ahead", lastHeartbeat: -time.Second},
+		{name: "clock slightly behind", lastHeartbeat: 10 * time.Second},
+		{name: "clock far behind", lastHeartbeat: time.Hour, expErr: true},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.name, func(t *testing.T) {
+			start := timeutil.Unix(0, int64(24*time.Hour))
+			m := timeutil.NewManualTime(start)
+			c := hlc.NewClock(m, time.Nanosecond /* maxOffset */, time.Nanosecond /* toleratedOffset */)
+			sleepFn := func(ctx context.Context, d time.Duration) error {
+				m.Advance(d)
+				return nil
+			}
+
+			var lastHeartbeat hlc.Timestamp
+			if test.lastHeartbeat != 0 {
+				lastHeartbeat = hlc.Timestamp{WallTime: start.Add(test.lastHeartbeat).UnixNano()}
+			}
+			err := ensureClockPastPreviousLiveness(
+				context.Background(), c, lastHeartbeat, maxWait, sleepFn)
+			if test.expErr {
+				require.ErrorContains(t, err, "refusing to start")
+				return
+			}
+			require.NoError(t, err)
+			require.GreaterOrEqual(t, c.PhysicalNow(), lastHeartbeat.WallTime)
+		})
+	}
+}
+
 func TestPersistHLCUpperBound(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 	defer log.Scope(t).Close(t)
@@ -1003,10 +1045,25 @@ func TestDecommissionNodeStatus(t *testing.T) {
 	require.NoError(t, srv.Decommission(
 		ctx, livenesspb.MembershipStatus_DECOMMISSIONED, []roachpb.NodeID{decomNodeID}))
 
-	// The node status entry should now have been cleaned up.
-	entry, err := srv.DB().Get(ctx, keys.NodeStatusKey(decomNodeID))
+	// The node status entry should now have been archived.
+	var nodeStatus statuspb.NodeStatus
+	require.NoError(t, srv.DB().GetProto(ctx, keys.NodeStatusKey(decomNodeID), &nodeStatus))
+	require.NotZero(t, nodeStatus.ArchivedAt, "node status entry not archived for node %d", decomNodeID)
+
+	// Archiving is idempotent, and keeps the original archival time.
+	require.NoError(t, srv.Decommission(
+		ctx, livenesspb.MembershipStatus_DECOMMISSIONED, []roachpb.NodeID{decomNodeID}))
+	var again statuspb.NodeStatus
+	require.NoError(t, srv.DB().GetProto(ctx, keys.NodeStatusKey(decomNodeID), &again))
+	require.Equal(t, nodeStatus.ArchivedAt, again.ArchivedAt)
+
+	// Archived entries are no longer listed among the cluster's nodes.
+	statuses, _, err := getNodeStatuses(ctx, srv.DB(), 0 /* limit */, 0 /* offset */)
 	require.NoError(t, err)
-	require.Nil(t, entry.Value, "found stale node status entry for node %d", decomNodeID)
+	require.Len(t, statuses, tc.NumServers()-1)
+	for _, s := range statuses {
+		require.NotEqual(t, decomNodeID, s.Desc.NodeID)
+	}
 }
 
 func TestSQLDecommissioned(t *testing.T) {
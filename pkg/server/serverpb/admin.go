@@ -56,6 +56,8 @@ func (r DecommissionPreCheckResponse_NodeReadiness) String() string {
 		return "already decommissioned"
 	case DecommissionPreCheckResponse_ALLOCATION_ERRORS:
 		return "allocation errors"
+	case DecommissionPreCheckResponse_REMAINING_NODE_SHORTFALLS:
+		return "remaining node shortfalls"
 	default:
 		panic("unknown decommission node readiness")
 	}
@@ -65,6 +67,14 @@ type TenantAdminServer interface {
 	Liveness(context.Context, *LivenessRequest) (*LivenessResponse, error)
 }
 
+// NodeDecommissioner is the subset of the serverpb.AdminServer that carries
+// out node decommissions. It is used by decommission jobs, and is only
+// available on the system tenant.
+type NodeDecommissioner interface {
+	Decommission(context.Context, *DecommissionRequest) (*DecommissionStatusResponse, error)
+	DecommissionStatus(context.Context, *DecommissionStatusRequest) (*DecommissionStatusResponse, error)
+}
+
 // Empty is true if there are no unavailable ranges and no error performing
 // healthcheck.
 func (r *RecoveryVerifyResponse_UnavailableRanges) Empty() bool {
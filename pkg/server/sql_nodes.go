@@ -0,0 +1,178 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"sort"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/gossip"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
+	"github.com/cockroachdb/cockroach/pkg/settings/cluster"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// watchSQLNodesPollInterval is the interval at which WatchSQLNodes recomputes
+// the set of nodes accepting SQL connections absent any gossip update, so as
+// to pick up changes of RPC connectivity.
+const watchSQLNodesPollInterval = time.Second
+
+// watchSQLNodesMinWait is the minimum time WatchSQLNodes waits before
+// recomputing the set of nodes accepting SQL connections, so as not to spin
+// while the liveness of a node is past its validity but not yet updated.
+const watchSQLNodesMinWait = 50 * time.Millisecond
+
+// liveSQLNodes returns the descriptors of the nodes accepting SQL connections
+// whose locality matches the given one, keyed by node ID. A node accepts SQL
+// connections if its vitality is LIVE: it's live, reachable, and neither
+// draining, decommissioning nor shutting down. Nodes without a gossiped
+// descriptor are omitted, since there's no address to route connections to.
+//
+// liveSQLNodes also returns the time until which the result holds absent new
+// liveness information, or hlc.MaxTimestamp if it only changes when new
+// information arrives.
+func liveSQLNodes(
+	nl *liveness.NodeLiveness,
+	g *gossip.Gossip,
+	st *cluster.Settings,
+	now hlc.Timestamp,
+	locality roachpb.Locality,
+) (map[roachpb.NodeID]*roachpb.NodeDescriptor, hlc.Timestamp) {
+	threshold := liveness.TimeUntilStoreDead.Get(&st.SV)
+	nodes := make(map[roachpb.NodeID]*roachpb.NodeDescriptor)
+	validUntil := hlc.MaxTimestamp
+	for nodeID, v := range nl.ScanNodeVitalityFromCache() {
+		if until := v.ValidUntil(now, threshold); until.Less(validUntil) {
+			validUntil = until
+		}
+		if v.Status(now, threshold) != livenesspb.NodeLivenessStatus_LIVE {
+			continue
+		}
+		desc, err := g.GetNodeDescriptor(nodeID)
+		if err != nil {
+			continue
+		}
+		if ok, _ := desc.Locality.Matches(locality); !ok {
+			continue
+		}
+		nodes[nodeID] = desc
+	}
+	return nodes, validUntil
+}
+
+// diffSQLNodes returns the changes turning the prev set of nodes accepting SQL
+// connections into the cur one. A node whose address or locality changed is
+// reported as added.
+func diffSQLNodes(
+	prev, cur map[roachpb.NodeID]*roachpb.NodeDescriptor,
+) *serverpb.WatchSQLNodesResponse {
+	resp := &serverpb.WatchSQLNodesResponse{}
+	for nodeID, desc := range cur {
+		if p, ok := prev[nodeID]; ok &&
+			p.CheckedSQLAddress().String() == desc.CheckedSQLAddress().String() &&
+			p.Locality.Equals(desc.Locality) {
+			continue
+		}
+		resp.Added = append(resp.Added, serverpb.WatchSQLNodesResponse_Node{
+			NodeID:     nodeID,
+			SQLAddress: *desc.CheckedSQLAddress(),
+			Locality:   desc.Locality,
+		})
+	}
+	for nodeID := range prev {
+		if _, ok := cur[nodeID]; !ok {
+			resp.Removed = append(resp.Removed, nodeID)
+		}
+	}
+	sort.Slice(resp.Added, func(i, j int) bool {
+		return resp.Added[i].NodeID < resp.Added[j].NodeID
+	})
+	sort.Slice(resp.Removed, func(i, j int) bool {
+		return resp.Removed[i] < resp.Removed[j]
+	})
+	return resp
+}
+
+// WatchSQLNodes streams the changes to the set of nodes accepting SQL
+// connections. See liveSQLNodes.
+//
+// Liveness records and node descriptors are gossiped, so the stream is woken
+// up as soon as a node drains or its descriptor changes. A node that dies is
+// removed once its liveness record expires, which happens within a heartbeat
+// interval of its last heartbeat.
+func (s *systemStatusServer) WatchSQLNodes(
+	req *serverpb.WatchSQLNodesRequest, stream serverpb.Status_WatchSQLNodesServer,
+) error {
+	ctx := s.AnnotateCtx(stream.Context())
+	if err := s.privilegeChecker.requireViewClusterMetadataPermission(ctx); err != nil {
+		// NB: not using serverError() here since the priv checker
+		// already returns a proper gRPC error status.
+		return err
+	}
+	var locality roachpb.Locality
+	if req.Locality != "" {
+		if err := locality.Set(req.Locality); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid locality: %v", err)
+		}
+	}
+	ctx, cancel := s.stopper.WithCancelOnQuiesce(ctx)
+	defer cancel()
+
+	changed := make(chan struct{}, 1)
+	notify := func(string, roachpb.Value) {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	}
+	unregisterLiveness := s.gossip.RegisterCallback(
+		gossip.MakePrefixPattern(gossip.KeyNodeLivenessPrefix), notify)
+	defer unregisterLiveness()
+	unregisterDesc := s.gossip.RegisterCallback(
+		gossip.MakePrefixPattern(gossip.KeyNodeDescPrefix), notify)
+	defer unregisterDesc()
+
+	var timer timeutil.Timer
+	defer timer.Stop()
+	var known map[roachpb.NodeID]*roachpb.NodeDescriptor
+	for first := true; ; first = false {
+		now := s.clock.Now()
+		nodes, validUntil := liveSQLNodes(s.nodeLiveness, s.gossip, s.st, now, locality)
+		if resp := diffSQLNodes(known, nodes); first || len(resp.Added) > 0 || len(resp.Removed) > 0 {
+			if err := stream.Send(resp); err != nil {
+				return err
+			}
+		}
+		known = nodes
+
+		wait := watchSQLNodesPollInterval
+		if validUntil.Less(now.Add(wait.Nanoseconds(), 0)) {
+			wait = validUntil.GoTime().Sub(now.GoTime())
+		}
+		if wait < watchSQLNodesMinWait {
+			wait = watchSQLNodesMinWait
+		}
+		timer.Reset(wait)
+		select {
+		case <-changed:
+		case <-timer.C:
+			timer.Read = true
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -42,9 +42,12 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/kv/kvclient"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvclient/kvcoord"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvclient/rangestats"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvpb"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/allocator/storepool"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/reports"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/rpc"
 	"github.com/cockroachdb/cockroach/pkg/security"
@@ -76,6 +79,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/util/log/logpb"
 	"github.com/cockroachdb/cockroach/pkg/util/metric"
 	"github.com/cockroachdb/cockroach/pkg/util/quotapool"
+	"github.com/cockroachdb/cockroach/pkg/util/rangedesc"
 	"github.com/cockroachdb/cockroach/pkg/util/stop"
 	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
 	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
@@ -908,6 +912,364 @@ func (s *systemStatusServer) CriticalNodes(
 	return res, nil
 }
 
+// QuorumCriticalNodes retrieves the nodes whose failure would render at least
+// one range unavailable, given the current liveness map. This complements
+// CriticalNodes, which is based on the span config conformance report and
+// doesn't take the liveness of the other replicas into account.
+func (s *systemStatusServer) QuorumCriticalNodes(
+	ctx context.Context, req *serverpb.QuorumCriticalNodesRequest,
+) (*serverpb.QuorumCriticalNodesResponse, error) {
+	ctx = s.AnnotateCtx(ctx)
+	if _, err := s.privilegeChecker.requireAdminUser(ctx); err != nil {
+		return nil, err
+	}
+
+	isLiveMap := s.nodeLiveness.GetIsLiveMap()
+	var rangeCounts map[roachpb.NodeID]int64
+	var unavailable int64
+	initCounters := func() {
+		rangeCounts = make(map[roachpb.NodeID]int64)
+		unavailable = 0
+	}
+	const pageSize = 10000
+	if err := rangedesc.NewScanner(s.db).Scan(ctx, pageSize, initCounters, keys.EverythingSpan,
+		func(descriptors ...roachpb.RangeDescriptor) error {
+			for i := range descriptors {
+				desc := &descriptors[i]
+				if !canMakeProgressWithout(desc, isLiveMap, 0 /* nodeID */) {
+					unavailable++
+					continue
+				}
+				for _, rDesc := range desc.Replicas().VoterDescriptors() {
+					if !canMakeProgressWithout(desc, isLiveMap, rDesc.NodeID) {
+						rangeCounts[rDesc.NodeID]++
+					}
+				}
+			}
+			return nil
+		}); err != nil {
+		return nil, serverError(ctx, err)
+	}
+
+	res := &serverpb.QuorumCriticalNodesResponse{UnavailableRangeCount: unavailable}
+	for nodeID, count := range rangeCounts {
+		res.CriticalNodes = append(res.CriticalNodes, serverpb.QuorumCriticalNodesResponse_Node{
+			NodeID:     nodeID,
+			RangeCount: count,
+		})
+	}
+	sort.Slice(res.CriticalNodes, func(i, j int) bool {
+		return res.CriticalNodes[i].NodeID < res.CriticalNodes[j].NodeID
+	})
+	return res, nil
+}
+
+// CriticalLocalities computes, against the current liveness map, the number of
+// ranges for which each locality is critical. See
+// reports.ComputeCriticalLocalities.
+func (s *systemStatusServer) CriticalLocalities(
+	ctx context.Context, req *serverpb.CriticalLocalitiesRequest,
+) (*serverpb.CriticalLocalitiesResponse, error) {
+	ctx = s.AnnotateCtx(ctx)
+	if _, err := s.privilegeChecker.requireAdminUser(ctx); err != nil {
+		return nil, err
+	}
+
+	atRisk, err := reports.ComputeCriticalLocalities(
+		ctx, s.db, s.storePool.GetStores(), s.nodeLiveness.GetIsLiveMap(),
+	)
+	if err != nil {
+		return nil, serverError(ctx, err)
+	}
+	res := &serverpb.CriticalLocalitiesResponse{}
+	for loc, count := range atRisk {
+		res.Localities = append(res.Localities, serverpb.CriticalLocalitiesResponse_Locality{
+			Locality:     string(loc),
+			AtRiskRanges: count,
+		})
+	}
+	sort.Slice(res.Localities, func(i, j int) bool {
+		return res.Localities[i].Locality < res.Localities[j].Locality
+	})
+	return res, nil
+}
+
+// NodeVitality returns the vitality of all the nodes known to this node. See
+// liveness.NodeLiveness.ScanNodeVitalityFromCache.
+func (s *systemStatusServer) NodeVitality(
+	ctx context.Context, req *serverpb.NodeVitalityRequest,
+) (*serverpb.NodeVitalityResponse, error) {
+	ctx = s.AnnotateCtx(ctx)
+	if err := s.privilegeChecker.requireViewClusterMetadataPermission(ctx); err != nil {
+		// NB: not using serverError() here since the priv checker
+		// already returns a proper gRPC error status.
+		return nil, err
+	}
+
+	// The placement of the liveness range is best effort: the vitality of the
+	// nodes matters the most when the liveness range is unavailable.
+	livenessReplicas, livenessLeaseholder, err := s.livenessRangePlacement(ctx)
+	if err != nil {
+		log.Warningf(ctx, "unable to determine the placement of the node liveness range: %v", err)
+	}
+
+	suspect := make(map[roachpb.NodeID]bool)
+	compactionDebt := make(map[roachpb.NodeID]bool)
+	lsmHealth := make(map[roachpb.NodeID][]serverpb.NodeVitalityResponse_StoreLSMHealth)
+	compactionDebtThreshold := storepool.CompactionDebtThreshold.Get(&s.st.SV)
+	for storeID, desc := range s.storePool.GetStores() {
+		if isSuspect, err := s.storePool.IsSuspect(storeID); err == nil && isSuspect {
+			suspect[desc.Node.NodeID] = true
+		}
+		if storepool.HasCompactionDebt(desc, compactionDebtThreshold) {
+			compactionDebt[desc.Node.NodeID] = true
+		}
+		score, _ := desc.Capacity.IOThreshold.Score()
+		lsmHealth[desc.Node.NodeID] = append(lsmHealth[desc.Node.NodeID],
+			serverpb.NodeVitalityResponse_StoreLSMHealth{
+				StoreID:         storeID,
+				L0SubLevels:     desc.Capacity.IOThreshold.L0NumSubLevels,
+				L0Files:         desc.Capacity.IOThreshold.L0NumFiles,
+				IOOverloadScore: score,
+			})
+	}
+
+	now := s.clock.Now()
+	threshold := liveness.TimeUntilStoreDead.Get(&s.st.SV)
+	res := &serverpb.NodeVitalityResponse{}
+	for nodeID, v := range s.nodeLiveness.ScanNodeVitalityFromCache() {
+		_, hasReplica := livenessReplicas[nodeID]
+		node := serverpb.NodeVitalityResponse_Node{
+			NodeID:                   nodeID,
+			Status:                   v.Status(now, threshold),
+			LifecycleState:           v.LifecycleState(now, threshold),
+			Liveness:                 v.Liveness,
+			Connectivity:             v.Connectivity,
+			LivenessRangeReplica:     hasReplica,
+			LivenessRangeLeaseholder: nodeID == livenessLeaseholder,
+			Suspect:                  suspect[nodeID],
+			Stores:                   lsmHealth[nodeID],
+		}
+		sort.Slice(node.Stores, func(i, j int) bool {
+			return node.Stores[i].StoreID < node.Stores[j].StoreID
+		})
+		var degraded []string
+		if compactionDebt[nodeID] {
+			degraded = append(degraded, "compaction debt")
+		}
+		if v.IsLive(now) && v.MemoryPressure {
+			degraded = append(degraded, "memory pressure")
+		}
+		if v.IsLive(now) && v.ShedLeases {
+			degraded = append(degraded, "shedding leases")
+		}
+		node.Degraded = strings.Join(degraded, ", ")
+		if remaining, ok := v.RemainingUntilDead(now, threshold); ok {
+			node.TimeUntilDead = &remaining
+		}
+		if validUntil := v.ValidUntil(now, threshold); validUntil != hlc.MaxTimestamp {
+			t := validUntil.GoTime()
+			node.ValidUntil = &t
+		}
+		if v.IsLive(now) && v.MaxClockOffsetNanos > res.MaxClockOffsetNanos {
+			res.MaxClockOffsetNanos = v.MaxClockOffsetNanos
+		}
+		res.Nodes = append(res.Nodes, node)
+	}
+	sort.Slice(res.Nodes, func(i, j int) bool {
+		return res.Nodes[i].NodeID < res.Nodes[j].NodeID
+	})
+	return res, nil
+}
+
+// NodesSummary returns the vitality of all the nodes known to this node,
+// merged with the contents of their last status summary.
+func (s *systemStatusServer) NodesSummary(
+	ctx context.Context, req *serverpb.NodesSummaryRequest,
+) (*serverpb.NodesSummaryResponse, error) {
+	ctx = s.AnnotateCtx(ctx)
+	if err := s.privilegeChecker.requireViewClusterMetadataPermission(ctx); err != nil {
+		// NB: not using serverError() here since the priv checker
+		// already returns a proper gRPC error status.
+		return nil, err
+	}
+
+	var localityFilter roachpb.Locality
+	if req.Locality != "" {
+		if err := localityFilter.Set(req.Locality); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid locality: %v", err)
+		}
+	}
+	var statusFilter map[livenesspb.NodeLivenessStatus]struct{}
+	if len(req.Statuses) > 0 {
+		statusFilter = make(map[livenesspb.NodeLivenessStatus]struct{}, len(req.Statuses))
+		for _, st := range req.Statuses {
+			statusFilter[st] = struct{}{}
+		}
+	}
+
+	nodeStatuses, _, err := getNodeStatuses(ctx, s.db, 0 /* limit */, 0 /* offset */)
+	if err != nil {
+		return nil, serverError(ctx, err)
+	}
+	statusByNodeID := make(map[roachpb.NodeID]*statuspb.NodeStatus, len(nodeStatuses))
+	for i := range nodeStatuses {
+		statusByNodeID[nodeStatuses[i].Desc.NodeID] = &nodeStatuses[i]
+	}
+
+	now := s.clock.Now()
+	threshold := liveness.TimeUntilStoreDead.Get(&s.st.SV)
+	var nodes []serverpb.NodesSummaryResponse_Node
+	for nodeID, v := range s.nodeLiveness.ScanNodeVitalityFromCache() {
+		node := serverpb.NodesSummaryResponse_Node{
+			NodeID:       nodeID,
+			Status:       v.Status(now, threshold),
+			Liveness:     v.Liveness,
+			Connectivity: v.Connectivity,
+		}
+		if _, ok := statusFilter[node.Status]; statusFilter != nil && !ok {
+			continue
+		}
+		if ns, ok := statusByNodeID[nodeID]; ok {
+			node.BuildInfo = ns.BuildInfo
+			node.Locality = ns.Desc.Locality
+			node.Address = ns.Desc.Address
+			node.SQLAddress = ns.Desc.SQLAddress
+			for _, ss := range ns.StoreStatuses {
+				node.Stores = append(node.Stores, serverpb.NodesSummaryResponse_Store{
+					StoreID:  ss.Desc.StoreID,
+					Capacity: ss.Desc.Capacity,
+				})
+			}
+			sort.Slice(node.Stores, func(i, j int) bool {
+				return node.Stores[i].StoreID < node.Stores[j].StoreID
+			})
+		}
+		if ok, _ := node.Locality.Matches(localityFilter); !ok {
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].NodeID < nodes[j].NodeID
+	})
+
+	res := &serverpb.NodesSummaryResponse{Nodes: nodes}
+	if req.Limit > 0 {
+		nodesInterface, next := simplePaginate(nodes, int(req.Limit), int(req.Offset))
+		res.Nodes = nodesInterface.([]serverpb.NodesSummaryResponse_Node)
+		res.Next = int32(next)
+	}
+	return res, nil
+}
+
+// NodeRecoveryReport returns the ranges that lost a replica with the requested
+// node. See makeNodeRecoveryReport.
+func (s *systemStatusServer) NodeRecoveryReport(
+	ctx context.Context, req *serverpb.NodeRecoveryReportRequest,
+) (*serverpb.NodeRecoveryReportResponse, error) {
+	ctx = s.AnnotateCtx(ctx)
+	if err := s.privilegeChecker.requireViewClusterMetadataPermission(ctx); err != nil {
+		// NB: not using serverError() here since the priv checker
+		// already returns a proper gRPC error status.
+		return nil, err
+	}
+
+	now := s.clock.Now()
+	vitalities := s.nodeLiveness.ScanNodeVitalityFromCache()
+	res, err := makeNodeRecoveryReport(ctx, s.db, req.NodeID, func(nodeID roachpb.NodeID) bool {
+		v, ok := vitalities[nodeID]
+		return ok && v.IsLive(now)
+	})
+	if err != nil {
+		return nil, serverError(ctx, err)
+	}
+	return res, nil
+}
+
+// NodeDoctor runs the liveness sanity checks of the requested node, by
+// forwarding the request to it. See runNodeDoctor.
+func (s *systemStatusServer) NodeDoctor(
+	ctx context.Context, req *serverpb.NodeDoctorRequest,
+) (*serverpb.NodeDoctorResponse, error) {
+	ctx = forwardSQLIdentityThroughRPCCalls(ctx)
+	ctx = s.AnnotateCtx(ctx)
+	if err := s.privilegeChecker.requireViewClusterMetadataPermission(ctx); err != nil {
+		// NB: not using serverError() here since the priv checker
+		// already returns a proper gRPC error status.
+		return nil, err
+	}
+
+	nodeID, local, err := s.parseNodeID(req.NodeId)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, err.Error())
+	}
+	if !local {
+		status, err := s.dialNode(ctx, nodeID)
+		if err != nil {
+			return nil, serverError(ctx, err)
+		}
+		return status.NodeDoctor(ctx, req)
+	}
+	return &serverpb.NodeDoctorResponse{
+		NodeID: nodeID,
+		Checks: s.runNodeDoctor(ctx, nodeID),
+	}, nil
+}
+
+// ProbeNode checks that the requested node is reachable from this node, by
+// forwarding the probe to it. See swimProber.
+func (s *systemStatusServer) ProbeNode(
+	ctx context.Context, req *serverpb.ProbeNodeRequest,
+) (*serverpb.ProbeNodeResponse, error) {
+	ctx = forwardSQLIdentityThroughRPCCalls(ctx)
+	ctx = s.AnnotateCtx(ctx)
+	if err := s.privilegeChecker.requireViewClusterMetadataPermission(ctx); err != nil {
+		// NB: not using serverError() here since the priv checker
+		// already returns a proper gRPC error status.
+		return nil, err
+	}
+
+	if req.NodeID == roachpb.NodeID(s.serverIterator.getID()) {
+		return &serverpb.ProbeNodeResponse{}, nil
+	}
+	client, err := s.dialNode(ctx, req.NodeID)
+	if err == nil {
+		_, err = client.ProbeNode(ctx, req)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "n%d is unreachable: %v", req.NodeID, err)
+	}
+	return &serverpb.ProbeNodeResponse{}, nil
+}
+
+// livenessRangePlacement returns the nodes holding a replica of the node
+// liveness range, and the node holding its lease.
+func (s *systemStatusServer) livenessRangePlacement(
+	ctx context.Context,
+) (replicas map[roachpb.NodeID]struct{}, leaseholder roachpb.NodeID, _ error) {
+	descs, _, err := kv.RangeLookup(ctx, s.db.NonTransactionalSender(),
+		keys.NodeLivenessPrefix, kvpb.READ_UNCOMMITTED, 0 /* prefetchNum */, false /* reverse */)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(descs) == 0 {
+		return nil, 0, errors.AssertionFailedf("no range descriptor found for the node liveness range")
+	}
+	replicas = make(map[roachpb.NodeID]struct{})
+	for _, rDesc := range descs[0].Replicas().Descriptors() {
+		replicas[rDesc.NodeID] = struct{}{}
+	}
+
+	resp, pErr := kv.SendWrapped(ctx, s.db.NonTransactionalSender(), &kvpb.LeaseInfoRequest{
+		RequestHeader: kvpb.RequestHeader{Key: keys.NodeLivenessPrefix},
+	})
+	if pErr != nil {
+		return replicas, 0, pErr.GoError()
+	}
+	return replicas, resp.(*kvpb.LeaseInfoResponse).Lease.Replica.NodeID, nil
+}
+
 // AllocatorRange returns simulated allocator info for the requested range.
 func (s *systemStatusServer) AllocatorRange(
 	ctx context.Context, req *serverpb.AllocatorRangeRequest,
@@ -1873,6 +2235,9 @@ func (s *systemStatusServer) ListNodesInternal(
 	return resp, err
 }
 
+// getNodeStatuses returns the status records of all nodes, skipping those
+// which have been archived after their node was decommissioned.
+//
 // Note that the function returns plain errors, and it is the caller's
 // responsibility to convert them to serverErrors.
 func getNodeStatuses(
@@ -1887,18 +2252,23 @@ func getNodeStatuses(
 		return nil, 0, err
 	}
 
-	var rows []kv.KeyValue
-	if len(b.Results[0].Rows) > 0 {
-		var rowsInterface interface{}
-		rowsInterface, next = simplePaginate(b.Results[0].Rows, limit, offset)
-		rows = rowsInterface.([]kv.KeyValue)
-	}
-
-	statuses = make([]statuspb.NodeStatus, len(rows))
-	for i, row := range rows {
-		if err := row.ValueProto(&statuses[i]); err != nil {
+	rows := b.Results[0].Rows
+	statuses = make([]statuspb.NodeStatus, 0, len(rows))
+	for _, row := range rows {
+		var nodeStatus statuspb.NodeStatus
+		if err := row.ValueProto(&nodeStatus); err != nil {
 			return nil, 0, err
 		}
+		if nodeStatus.ArchivedAt != 0 {
+			continue
+		}
+		statuses = append(statuses, nodeStatus)
+	}
+
+	if len(statuses) > 0 {
+		var statusesInterface interface{}
+		statusesInterface, next = simplePaginate(statuses, limit, offset)
+		statuses = statusesInterface.([]statuspb.NodeStatus)
 	}
 	return statuses, next, nil
 }
@@ -0,0 +1,57 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package status
+
+import (
+	"math"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+// memoryPressureWindow is how long the process is considered under memory
+// pressure after it was last found close to its memory limits. Memory usage
+// drops sharply once the Go GC reclaims memory to stay under its limit, so a
+// single sample below the threshold doesn't mean the node is out of danger.
+const memoryPressureWindow = time.Minute
+
+// UnderMemoryPressure returns whether the process is, or recently was, close
+// to running out of memory, as of the last sample of the environment: its
+// resident set size is at least the given fraction of the memory available to
+// it, or its Go memory usage is at least the given fraction of the Go memory
+// limit (past which the Go GC runs more and more often to avoid running out
+// of memory). A zero threshold disables the check.
+func (rsr *RuntimeStatSampler) UnderMemoryPressure(threshold float64) bool {
+	if threshold == 0 {
+		return false
+	}
+	now := rsr.clock.Now().UnixNano()
+	if exceedsMemoryThreshold(
+		rsr.RSSBytes.Value(), rsr.TotalMemBytes.Value(),
+		rsr.GoTotalBytes.Value(), debug.SetMemoryLimit(-1 /* query */),
+		threshold,
+	) {
+		atomic.StoreInt64(&rsr.lastMemoryPressure, now)
+		return true
+	}
+	last := atomic.LoadInt64(&rsr.lastMemoryPressure)
+	return last != 0 && time.Duration(now-last) < memoryPressureWindow
+}
+
+// exceedsMemoryThreshold returns whether rss is at least the given fraction of
+// totalMem, or goTotal is at least the given fraction of goLimit. Limits that
+// are unknown (zero) or unset (math.MaxInt64) are ignored.
+func exceedsMemoryThreshold(rss, totalMem, goTotal, goLimit int64, threshold float64) bool {
+	exceeds := func(usage, limit int64) bool {
+		return limit > 0 && limit != math.MaxInt64 && float64(usage) >= threshold*float64(limit)
+	}
+	return exceeds(rss, totalMem) || exceeds(goTotal, goLimit)
+}
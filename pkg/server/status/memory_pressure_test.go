@@ -0,0 +1,42 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package status
+
+import (
+	"math"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExceedsMemoryThreshold(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	const gb = 1 << 30
+	testCases := []struct {
+		name                            string
+		rss, totalMem, goTotal, goLimit int64
+		expected                        bool
+	}{
+		{"low usage", 1 * gb, 8 * gb, 1 * gb, 4 * gb, false},
+		{"rss near total memory", 8 * gb * 9 / 10, 8 * gb, 1 * gb, 4 * gb, true},
+		{"go memory near limit", 1 * gb, 8 * gb, 4 * gb * 9 / 10, 4 * gb, true},
+		{"unknown total memory", 8 * gb, 0, 1 * gb, 4 * gb, false},
+		{"unset go limit", 1 * gb, 8 * gb, 4 * gb, math.MaxInt64, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected,
+				exceedsMemoryThreshold(tc.rss, tc.totalMem, tc.goTotal, tc.goLimit, 0.9))
+		})
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package status
+
+// Overloaded returns whether the process is overloaded as of the last sample
+// of the environment: the CPU usage of the process, normalized by the number
+// of cores, is at least cpuThreshold, or the average number of goroutines
+// waiting to run per core is at least runnableThreshold. A zero threshold
+// disables the respective check.
+func (rsr *RuntimeStatSampler) Overloaded(cpuThreshold, runnableThreshold float64) bool {
+	return exceedsOverloadThreshold(
+		rsr.CPUCombinedPercentNorm.Value(), cpuThreshold,
+		rsr.RunnableGoroutinesPerCPU.Value(), runnableThreshold,
+	)
+}
+
+// exceedsOverloadThreshold returns whether cpu is at least cpuThreshold or
+// runnable is at least runnableThreshold, ignoring zero thresholds.
+func exceedsOverloadThreshold(cpu, cpuThreshold, runnable, runnableThreshold float64) bool {
+	return (cpuThreshold > 0 && cpu >= cpuThreshold) ||
+		(runnableThreshold > 0 && runnable >= runnableThreshold)
+}
@@ -0,0 +1,41 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package status
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExceedsOverloadThreshold(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	testCases := []struct {
+		name                        string
+		cpu, cpuThreshold           float64
+		runnable, runnableThreshold float64
+		expected                    bool
+	}{
+		{"idle", 0.2, 0.95, 1, 64, false},
+		{"cpu saturated", 0.97, 0.95, 1, 64, true},
+		{"goroutine backlog", 0.5, 0.95, 80, 64, true},
+		{"cpu check disabled", 1, 0, 1, 64, false},
+		{"goroutine check disabled", 0.5, 0.95, 80, 0, false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected,
+				exceedsOverloadThreshold(tc.cpu, tc.cpuThreshold, tc.runnable, tc.runnableThreshold))
+		})
+	}
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/kv/kvbase"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvpb"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
 	"github.com/cockroachdb/cockroach/pkg/multitenant"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/rpc"
@@ -140,6 +141,12 @@ type MetricsRecorder struct {
 
 		// tenantRegistries contains the registries for shared-process tenants.
 		tenantRegistries map[roachpb.TenantID]*metric.Registry
+
+		// archived is set once the node has been decommissioned. An archived
+		// recorder no longer produces time series data or node statuses, which
+		// finalizes the node's per-node series instead of leaving them to
+		// dangle.
+		archived bool
 	}
 
 	// WriteNodeStatus is a potentially long-running method (with a network
@@ -346,6 +353,10 @@ func (mr *MetricsRecorder) GetTimeSeriesData() []tspb.TimeSeriesData {
 		}
 		return nil
 	}
+	if mr.mu.archived {
+		// The node has been decommissioned; its series are final.
+		return nil
+	}
 
 	lastDataCount := atomic.LoadInt64(&mr.lastDataCount)
 	data := make([]tspb.TimeSeriesData, 0, lastDataCount)
@@ -451,6 +462,12 @@ func (mr *MetricsRecorder) getNetworkActivity(
 			currentAverages = mr.remoteClocks.AllLatencies()
 		}
 		for nodeID, entry := range isLiveMap {
+			if entry.LivenessStatus == livenesspb.NodeLivenessStatus_DECOMMISSIONED {
+				// Nodes that died while decommissioning are gone for good;
+				// reporting them as unreachable would only raise false alarms.
+				// Decommissioned nodes aren't part of the map to begin with.
+				continue
+			}
 			na := statuspb.NodeStatus_NetworkActivity{}
 			if entry.IsLive {
 				if latency, ok := currentAverages[nodeID]; ok {
@@ -479,6 +496,10 @@ func (mr *MetricsRecorder) GenerateNodeStatus(ctx context.Context) *statuspb.Nod
 		}
 		return nil
 	}
+	if mr.mu.archived {
+		// The node has been decommissioned and its status record archived.
+		return nil
+	}
 
 	now := mr.clock.Now()
 
@@ -550,9 +571,26 @@ func flattenStrings(s []redact.RedactableString) []string {
 	return res
 }
 
+// Archive marks the node as decommissioned. From then on the recorder stops
+// producing time series data and node statuses, so that the node's last
+// recorded status remains as its final, archived record.
+func (mr *MetricsRecorder) Archive() {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	mr.mu.archived = true
+}
+
+// IsArchived returns whether Archive has been called on the recorder.
+func (mr *MetricsRecorder) IsArchived() bool {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+	return mr.mu.archived
+}
+
 // WriteNodeStatus writes the supplied summary to the given client. If mustExist
-// is true, the key must already exist and must not change while being updated,
-// otherwise an error is returned -- if false, the status is always written.
+// is true, the key must already exist, must not have been archived and must
+// not change while being updated, otherwise an error is returned -- if false,
+// the status is always written.
 func (mr *MetricsRecorder) WriteNodeStatus(
 	ctx context.Context, db *kv.DB, nodeStatus statuspb.NodeStatus, mustExist bool,
 ) error {
@@ -573,6 +611,13 @@ func (mr *MetricsRecorder) WriteNodeStatus(
 		if entry.Value == nil {
 			return errors.New("status entry not found, node may have been decommissioned")
 		}
+		var existing statuspb.NodeStatus
+		if err := entry.Value.GetProto(&existing); err != nil {
+			return err
+		}
+		if existing.ArchivedAt != 0 {
+			return errors.New("status entry archived, node has been decommissioned")
+		}
 		err = db.CPutInline(ctx, key, &nodeStatus, entry.Value.TagAndDataBytes())
 		if detail := (*kvpb.ConditionFailedError)(nil); errors.As(err, &detail) {
 			if detail.ActualValue == nil {
@@ -597,6 +642,67 @@ func (mr *MetricsRecorder) WriteNodeStatus(
 	return nil
 }
 
+// ArchiveNodeStatus marks the status record of the given node as archived as
+// of archivedAt, freezing it in its last recorded state, and returns the
+// archived record. It is a no-op if the node has no status record, in which
+// case an empty record is returned, or if the record has already been
+// archived, so it can safely be invoked repeatedly, e.g. whenever a node is
+// observed to have been decommissioned.
+func ArchiveNodeStatus(
+	ctx context.Context, db *kv.DB, nodeID roachpb.NodeID, archivedAt time.Time,
+) (statuspb.NodeStatus, error) {
+	key := keys.NodeStatusKey(nodeID)
+	for {
+		entry, err := db.Get(ctx, key)
+		if err != nil {
+			return statuspb.NodeStatus{}, err
+		}
+		if entry.Value == nil {
+			return statuspb.NodeStatus{}, nil
+		}
+		var nodeStatus statuspb.NodeStatus
+		if err := entry.Value.GetProto(&nodeStatus); err != nil {
+			return statuspb.NodeStatus{}, err
+		}
+		if nodeStatus.ArchivedAt != 0 {
+			return nodeStatus, nil
+		}
+		nodeStatus.ArchivedAt = archivedAt.UnixNano()
+		err = db.CPutInline(ctx, key, &nodeStatus, entry.Value.TagAndDataBytes())
+		if detail := (*kvpb.ConditionFailedError)(nil); errors.As(err, &detail) {
+			// The node wrote a status concurrently; retry against the new value.
+			continue
+		}
+		return nodeStatus, err
+	}
+}
+
+// TimeSeriesSources returns the time series recorded by the node with the
+// given status, by source: the node-level series of the node and the
+// store-level series of each of its stores, as listed in the status. The
+// series of secondary tenants aren't included.
+func TimeSeriesSources(nodeStatus statuspb.NodeStatus) map[string][]string {
+	sources := make(map[string][]string, len(nodeStatus.StoreStatuses)+1)
+	if len(nodeStatus.Metrics) > 0 {
+		names := make([]string, 0, len(nodeStatus.Metrics))
+		for name := range nodeStatus.Metrics {
+			names = append(names, fmt.Sprintf(nodeTimeSeriesPrefix, name))
+		}
+		sources[nodeStatus.Desc.NodeID.String()] = names
+	}
+	for _, ss := range nodeStatus.StoreStatuses {
+		if len(ss.Metrics) == 0 {
+			continue
+		}
+		names := make([]string, 0, len(ss.Metrics))
+		for name := range ss.Metrics {
+			names = append(names, fmt.Sprintf(storeTimeSeriesPrefix, name))
+		}
+		sources[ss.Desc.StoreID.String()] = names
+	}
+	return sources
+}
+
 // registryRecorder is a helper class for recording time series datapoints
 // from a metrics Registry.
 type registryRecorder struct {
@@ -680,3 +680,50 @@ func TestMetricsRecorder(t *testing.T) {
 	wg.Wait()
 	recorder.mu.RUnlock()
 }
+
+func TestMetricsRecorderArchive(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	reg := metric.NewRegistry()
+	reg.AddMetric(metric.NewGauge(metric.Metadata{Name: "gauge"}))
+	manual := timeutil.NewManualTime(timeutil.Unix(0, 100))
+	st := cluster.MakeTestingClusterSettings()
+	recorder := NewMetricsRecorder(roachpb.SystemTenantID, roachpb.NewTenantNameContainer(""), nil, nil, manual, st)
+	recorder.AddNode(reg, roachpb.NodeDescriptor{NodeID: 1}, 50, "foo:26257", "foo:26258", "foo:5432")
+
+	if data := recorder.GetTimeSeriesData(); len(data) == 0 {
+		t.Fatal("expected time series data before archival")
+	}
+	if status := recorder.GenerateNodeStatus(context.Background()); status == nil {
+		t.Fatal("expected node status before archival")
+	}
+
+	recorder.Archive()
+	if !recorder.IsArchived() {
+		t.Fatal("expected recorder to be archived")
+	}
+	if data := recorder.GetTimeSeriesData(); data != nil {
+		t.Errorf("expected no time series data after archival, got %v", data)
+	}
+	if status := recorder.GenerateNodeStatus(context.Background()); status != nil {
+		t.Errorf("expected no node status after archival, got %v", status)
+	}
+}
+
+func TestTimeSeriesSources(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	sources := TimeSeriesSources(statuspb.NodeStatus{
+		Desc:    roachpb.NodeDescriptor{NodeID: 1},
+		Metrics: map[string]float64{"gauge": 1},
+		StoreStatuses: []statuspb.StoreStatus{
+			{Desc: roachpb.StoreDescriptor{StoreID: 2}, Metrics: map[string]float64{"bytes": 1}},
+			{Desc: roachpb.StoreDescriptor{StoreID: 3}},
+		},
+	})
+	require.Equal(t, map[string][]string{
+		"1": {"cr.node.gauge"},
+		"2": {"cr.store.bytes"},
+	}, sources)
+	require.Empty(t, TimeSeriesSources(statuspb.NodeStatus{}))
+}
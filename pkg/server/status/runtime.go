@@ -302,6 +302,11 @@ type RuntimeStatSampler struct {
 	// Only show "not implemented" errors once, we don't need the log spam.
 	fdUsageNotImplemented bool
 
+	// lastMemoryPressure is the time, in nanoseconds since the epoch, at which
+	// UnderMemoryPressure last found the process to be close to its memory
+	// limits. Accessed atomically.
+	lastMemoryPressure int64
+
 	// Metric gauges maintained by the sampler.
 	// Go runtime stats.
 	CgoCalls                 *metric.Gauge
@@ -37,6 +37,8 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/keys"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/allocator/plan"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/rpc"
 	"github.com/cockroachdb/cockroach/pkg/security/username"
@@ -3843,3 +3845,165 @@ func TestUnprivilegedUserResetIndexUsageStats(t *testing.T) {
 
 	require.Contains(t, err.Error(), "requires admin privilege")
 }
+
+// TestNodeVitalityLivenessRange verifies that the vitality output reports the
+// nodes holding replicas and the lease of the node liveness range.
+func TestNodeVitalityLivenessRange(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := serverutils.StartNewTestCluster(t, 3, base.TestClusterArgs{
+		ReplicationMode: base.ReplicationManual,
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	livenessKey := keys.NodeLivenessPrefix
+	tc.AddVotersOrFatal(t, livenessKey, tc.Target(1))
+	s := tc.Server(2).StatusServer().(serverpb.StatusServer)
+
+	testutils.SucceedsSoon(t, func() error {
+		res, err := s.NodeVitality(ctx, &serverpb.NodeVitalityRequest{})
+		if err != nil {
+			return err
+		}
+		if len(res.Nodes) != 3 {
+			return errors.Errorf("expected 3 nodes, found %d", len(res.Nodes))
+		}
+		for i, n := range res.Nodes {
+			if expected := i < 2; n.LivenessRangeReplica != expected {
+				return errors.Errorf("n%d: expected liveness range replica %t", n.NodeID, expected)
+			}
+			if expected := i == 0; n.LivenessRangeLeaseholder != expected {
+				return errors.Errorf("n%d: expected liveness range lease %t", n.NodeID, expected)
+			}
+			if n.LifecycleState != livenesspb.NodeLifecycleState_ACTIVE {
+				return errors.Errorf("n%d: expected active, found %s", n.NodeID, n.LifecycleState)
+			}
+		}
+		return nil
+	})
+}
+
+// TestNodesSummary verifies that the nodes summary merges the vitality of the
+// nodes with their status summaries, and filters and paginates them.
+func TestNodesSummary(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	serverArgs := make(map[int]base.TestServerArgs)
+	for i, region := range []string{"r1", "r1", "r2"} {
+		serverArgs[i] = base.TestServerArgs{
+			Locality: roachpb.Locality{Tiers: []roachpb.Tier{{Key: "region", Value: region}}},
+		}
+	}
+	ctx := context.Background()
+	tc := serverutils.StartNewTestCluster(t, 3, base.TestClusterArgs{
+		ServerArgsPerNode: serverArgs,
+	})
+	defer tc.Stopper().Stop(ctx)
+
+	s := tc.Server(0).StatusServer().(serverpb.StatusServer)
+	nodeIDs := func(req *serverpb.NodesSummaryRequest) ([]roachpb.NodeID, int32) {
+		res, err := s.NodesSummary(ctx, req)
+		require.NoError(t, err)
+		var ids []roachpb.NodeID
+		for _, n := range res.Nodes {
+			ids = append(ids, n.NodeID)
+		}
+		return ids, res.Next
+	}
+
+	testutils.SucceedsSoon(t, func() error {
+		res, err := s.NodesSummary(ctx, &serverpb.NodesSummaryRequest{})
+		if err != nil {
+			return err
+		}
+		if len(res.Nodes) != 3 {
+			return errors.Errorf("expected 3 nodes, found %d", len(res.Nodes))
+		}
+		for _, n := range res.Nodes {
+			if n.Status != livenesspb.NodeLivenessStatus_LIVE {
+				return errors.Errorf("n%d: expected live, found %s", n.NodeID, n.Status)
+			}
+			if len(n.Stores) != 1 || n.BuildInfo.Tag == "" {
+				return errors.Errorf("n%d: no status summary yet", n.NodeID)
+			}
+		}
+		return nil
+	})
+
+	ids, next := nodeIDs(&serverpb.NodesSummaryRequest{Locality: "region=r1"})
+	require.Equal(t, []roachpb.NodeID{1, 2}, ids)
+	require.Zero(t, next)
+
+	ids, _ = nodeIDs(&serverpb.NodesSummaryRequest{
+		Statuses: []livenesspb.NodeLivenessStatus{livenesspb.NodeLivenessStatus_DEAD},
+	})
+	require.Empty(t, ids)
+
+	ids, next = nodeIDs(&serverpb.NodesSummaryRequest{Limit: 2})
+	require.Equal(t, []roachpb.NodeID{1, 2}, ids)
+	require.Equal(t, int32(2), next)
+	ids, next = nodeIDs(&serverpb.NodesSummaryRequest{Limit: 2, Offset: next})
+	require.Equal(t, []roachpb.NodeID{3}, ids)
+	require.Zero(t, next)
+
+	_, err := s.NodesSummary(ctx, &serverpb.NodesSummaryRequest{Locality: "region"})
+	require.Error(t, err)
+}
+
+func TestWatchSQLNodes(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := serverutils.StartNewTestCluster(t, 3, base.TestClusterArgs{})
+	defer tc.Stopper().Stop(ctx)
+
+	ts := tc.Server(0)
+	conn, err := ts.RPCContext().GRPCDialNode(
+		ts.RPCAddr(), ts.NodeID(), rpc.DefaultClass).Connect(ctx)
+	require.NoError(t, err)
+	client := serverpb.NewStatusClient(conn)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.WatchSQLNodes(ctx, &serverpb.WatchSQLNodesRequest{})
+	require.NoError(t, err)
+
+	// The first response lists the live nodes as added. Not all of them may
+	// be live yet, so the initial set is reconciled with the next responses.
+	live := make(map[roachpb.NodeID]string)
+	apply := func() *serverpb.WatchSQLNodesResponse {
+		resp, err := stream.Recv()
+		require.NoError(t, err)
+		for _, n := range resp.Added {
+			live[n.NodeID] = n.SQLAddress.String()
+		}
+		for _, nodeID := range resp.Removed {
+			delete(live, nodeID)
+		}
+		return resp
+	}
+	apply()
+	for len(live) < 3 {
+		apply()
+	}
+	for i := 0; i < 3; i++ {
+		require.Equal(t, tc.Server(i).ServingSQLAddr(), live[tc.Server(i).NodeID()])
+	}
+
+	// A draining node is pushed as removed.
+	drainingID := tc.Server(1).NodeID()
+	nl := tc.Server(1).NodeLiveness().(*liveness.NodeLiveness)
+	require.NoError(t, nl.SetDraining(ctx, true /* drain */, nil /* reporter */))
+	for {
+		resp := apply()
+		if len(resp.Removed) > 0 {
+			require.Equal(t, []roachpb.NodeID{drainingID}, resp.Removed)
+			break
+		}
+	}
+	require.Len(t, live, 2)
+}
@@ -0,0 +1,49 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package server
+
+import (
+	"context"
+
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/rpc"
+	"github.com/cockroachdb/cockroach/pkg/rpc/nodedialer"
+	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
+)
+
+// swimProber implements liveness.Prober on top of the ProbeNode RPC. A direct
+// probe is a ProbeNode round trip to the target itself; an indirect probe asks
+// another node to forward the ProbeNode request to the target.
+type swimProber struct {
+	nodeDialer *nodedialer.Dialer
+}
+
+var _ liveness.Prober = (*swimProber)(nil)
+
+// Probe implements liveness.Prober.
+func (p *swimProber) Probe(ctx context.Context, target roachpb.NodeID) error {
+	return p.probe(ctx, target, target)
+}
+
+// ProbeVia implements liveness.Prober.
+func (p *swimProber) ProbeVia(ctx context.Context, via, target roachpb.NodeID) error {
+	return p.probe(ctx, via, target)
+}
+
+func (p *swimProber) probe(ctx context.Context, via, target roachpb.NodeID) error {
+	conn, err := p.nodeDialer.Dial(ctx, via, rpc.SystemClass)
+	if err != nil {
+		return err
+	}
+	_, err = serverpb.NewStatusClient(conn).ProbeNode(ctx, &serverpb.ProbeNodeRequest{NodeID: target})
+	return err
+}
@@ -153,7 +153,7 @@ type SQLServerWrapper struct {
 func (s *SQLServerWrapper) Drain(
 	ctx context.Context, verbose bool,
 ) (remaining uint64, info redact.RedactableString, err error) {
-	return s.drainServer.runDrain(ctx, verbose)
+	return s.drainServer.runDrain(ctx, verbose, "" /* reason */)
 }
 
 // tenantServerDeps holds dependencies for the SQL server that we want
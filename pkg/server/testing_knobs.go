@@ -142,6 +142,17 @@ type TestingKnobs struct {
 	// DrainReportCh, if set, is a channel that will be notified when
 	// the SQL service shuts down.
 	DrainReportCh chan struct{}
+
+	// NodeAlertHook, if set, is notified of node alerts in place of the hook
+	// configured by server.node_alerts.hook.
+	NodeAlertHook NodeAlertHook
+
+	// MembershipChangeHook, if set, is notified of membership changes in place
+	// of the webhook configured by server.membership_webhook.url.
+	MembershipChangeHook MembershipChangeHook
+	// MembershipWebhookCheckInterval, if set, overrides the interval at which
+	// the membership of the nodes is checked for changes.
+	MembershipWebhookCheckInterval time.Duration
 }
 
 // ModuleTestingKnobs is part of the base.ModuleTestingKnobs interface.
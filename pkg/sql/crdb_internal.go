@@ -4688,7 +4688,8 @@ CREATE TABLE crdb_internal.gossip_liveness (
   draining         BOOL NOT NULL,
   decommissioning  BOOL NOT NULL,
   membership       STRING NOT NULL,
-  updated_at       TIMESTAMP
+  updated_at       TIMESTAMP,
+  reason           STRING NOT NULL
 )
 	`,
 	populate: func(ctx context.Context, p *planner, _ catalog.DatabaseDescriptor, addRow func(...tree.Datum) error) error {
@@ -4751,6 +4752,7 @@ CREATE TABLE crdb_internal.gossip_liveness (
 				tree.MakeDBool(tree.DBool(!l.Membership.Active())),
 				tree.NewDString(l.Membership.String()),
 				updatedTSDatum,
+				tree.NewDString(l.Reason),
 			); err != nil {
 				return err
 			}
@@ -785,6 +785,9 @@ const (
 	// NodeDistSQLVersionIncompatible means that the node should be avoided
 	// because it's DistSQL version is not compatible.
 	NodeDistSQLVersionIncompatible
+	// NodeAvoided means that the node should be avoided because it is in one of
+	// the lifecycle states the work being planned declared to avoid.
+	NodeAvoided
 )
 
 // PlanningCtx contains data used and updated throughout the planning process of
@@ -793,6 +796,11 @@ type PlanningCtx struct {
 	ExtendedEvalCtx *extendedEvalContext
 
 	localityFilter roachpb.Locality
+	// avoidedInstances are the SQL instances that are hosted on KV nodes in one
+	// of the lifecycle states the work being planned declared to avoid (see
+	// SetupAllNodesPlanningWithOracle). The gateway is never avoided, since it
+	// is where the work is coordinated from.
+	avoidedInstances map[base.SQLInstanceID]struct{}
 
 	spanIter physicalplan.SpanResolverIterator
 	// nodeStatuses contains info for all SQLInstanceIDs that are referenced by
@@ -1140,7 +1148,9 @@ func (dsp *DistSQLPlanner) checkInstanceHealthAndVersionSystem(
 	}
 
 	var status NodeStatus
-	if err := dsp.nodeHealth.checkSystem(ctx, sqlInstanceID); err != nil {
+	if _, avoided := planCtx.avoidedInstances[sqlInstanceID]; avoided {
+		status = NodeAvoided
+	} else if err := dsp.nodeHealth.checkSystem(ctx, sqlInstanceID); err != nil {
 		status = NodeUnhealthy
 	} else if !dsp.nodeVersionIsCompatibleSystem(sqlInstanceID) {
 		status = NodeDistSQLVersionIncompatible
@@ -1327,7 +1337,9 @@ func (dsp *DistSQLPlanner) deprecatedPartitionSpansSystem(
 func (dsp *DistSQLPlanner) partitionSpans(
 	ctx context.Context, planCtx *PlanningCtx, spans roachpb.Spans,
 ) (partitions []SpanPartition, ignoreMisplannedRanges bool, _ error) {
-	resolver, instances, err := dsp.makeInstanceResolver(ctx, planCtx.localityFilter)
+	resolver, instances, err := dsp.makeInstanceResolver(
+		ctx, planCtx.localityFilter, planCtx.avoidedInstances,
+	)
 	if err != nil {
 		return nil, false, err
 	}
@@ -1401,12 +1413,13 @@ func instanceIDForKVNodeHostedInstance(nodeID roachpb.NodeID) base.SQLInstanceID
 // wish to post-process that assignment (such as adjusting based on localities).
 // If the instance was assigned statically or the instance list had no locality
 // information leading to random assignments then no instance list is returned.
+// The avoided instances, other than the gateway, are never chosen.
 func (dsp *DistSQLPlanner) makeInstanceResolver(
-	ctx context.Context, locFilter roachpb.Locality,
+	ctx context.Context, locFilter roachpb.Locality, avoided map[base.SQLInstanceID]struct{},
 ) (func(roachpb.NodeID) base.SQLInstanceID, []sqlinstance.InstanceInfo, error) {
 	_, mixedProcessMode := dsp.distSQLSrv.NodeID.OptionalNodeID()
 
-	if mixedProcessMode && locFilter.Empty() {
+	if mixedProcessMode && locFilter.Empty() && len(avoided) == 0 {
 		return instanceIDForKVNodeHostedInstance, nil, nil
 	}
 
@@ -1416,6 +1429,16 @@ func (dsp *DistSQLPlanner) makeInstanceResolver(
 	if err != nil {
 		return nil, nil, err
 	}
+	if len(avoided) > 0 {
+		eligible := make([]sqlinstance.InstanceInfo, 0, len(instances))
+		for i := range instances {
+			if _, ok := avoided[instances[i].InstanceID]; !ok ||
+				instances[i].InstanceID == dsp.gatewaySQLInstanceID {
+				eligible = append(eligible, instances[i])
+			}
+		}
+		instances = eligible
+	}
 	if len(instances) == 0 {
 		return nil, nil, errors.New("no healthy sql instances available for planning")
 	}
@@ -1462,9 +1485,11 @@ func (dsp *DistSQLPlanner) makeInstanceResolver(
 			}
 
 			// If we're in mixed-mode, check if the picked node already matches the
-			// locality filter in which case we can just use it.
+			// locality filter, and isn't avoided, in which case we can just use it.
 			if mixedProcessMode {
-				if ok, _ := nodeDesc.Locality.Matches(locFilter); ok {
+				if _, ok := avoided[instanceIDForKVNodeHostedInstance(nodeID)]; ok {
+					log.VEventf(ctx, 2, "node %d is avoided, finding alternative placement...", nodeID)
+				} else if ok, _ := nodeDesc.Locality.Matches(locFilter); ok {
 					return instanceIDForKVNodeHostedInstance(nodeID)
 				} else {
 					log.VEventf(ctx, 2,
@@ -1609,7 +1634,9 @@ func (dsp *DistSQLPlanner) getInstanceIDForScan(
 	if dsp.useGossipPlanning(ctx, planCtx) && planCtx.localityFilter.Empty() {
 		return dsp.deprecatedSQLInstanceIDForKVNodeIDSystem(ctx, planCtx, replDesc.NodeID), nil
 	}
-	resolver, _, err := dsp.makeInstanceResolver(ctx, planCtx.localityFilter)
+	resolver, _, err := dsp.makeInstanceResolver(
+		ctx, planCtx.localityFilter, planCtx.avoidedInstances,
+	)
 	if err != nil {
 		return 0, err
 	}
@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/server/serverpb"
 	"github.com/cockroachdb/cockroach/pkg/sql/physicalplan"
@@ -38,31 +39,47 @@ func (dsp *DistSQLPlanner) SetupAllNodesPlanning(
 // for planning, filtered using the passed locality filter, which along with the
 // passed replica oracle, is stored in the returned planning context to be used
 // by subsequent physical planning such as PartitionSpans.
+//
+// Nodes in any of the passed lifecycle states, according to the liveness view
+// of this node, are avoided as well, so that background work such as backups
+// stays off of nodes that are e.g. draining or in a maintenance window. The
+// work they would have done is planned on the closest instance, by locality,
+// that isn't avoided; the gateway is never avoided. This only applies to the
+// system tenant, the only one with access to the liveness of the KV nodes.
 func (dsp *DistSQLPlanner) SetupAllNodesPlanningWithOracle(
 	ctx context.Context,
 	evalCtx *extendedEvalContext,
 	execCfg *ExecutorConfig,
 	oracle replicaoracle.Oracle,
 	localityFilter roachpb.Locality,
+	avoidNodeStates ...livenesspb.NodeLifecycleState,
 ) (*PlanningCtx, []base.SQLInstanceID, error) {
 	if dsp.codec.ForSystemTenant() {
-		return dsp.setupAllNodesPlanningSystem(ctx, evalCtx, execCfg, oracle, localityFilter)
+		return dsp.setupAllNodesPlanningSystem(
+			ctx, evalCtx, execCfg, oracle, localityFilter, avoidNodeStates,
+		)
 	}
 	return dsp.setupAllNodesPlanningTenant(ctx, evalCtx, execCfg, oracle, localityFilter)
 }
 
 // setupAllNodesPlanningSystem creates a planCtx and returns all nodes available
 // in a system tenant, filtered using the passed locality filter if it is
-// non-empty.
+// non-empty and excluding the nodes in the passed lifecycle states.
 func (dsp *DistSQLPlanner) setupAllNodesPlanningSystem(
 	ctx context.Context,
 	evalCtx *extendedEvalContext,
 	execCfg *ExecutorConfig,
 	oracle replicaoracle.Oracle,
 	localityFilter roachpb.Locality,
+	avoidNodeStates []livenesspb.NodeLifecycleState,
 ) (*PlanningCtx, []base.SQLInstanceID, error) {
 	planCtx := dsp.NewPlanningCtxWithOracle(ctx, evalCtx, nil /* planner */, nil, /* txn */
 		DistributionTypeAlways, oracle, localityFilter)
+	if len(avoidNodeStates) > 0 {
+		if nl, ok := execCfg.NodeLiveness.Optional(47900); ok {
+			planCtx.avoidedInstances = dsp.avoidedInstances(ctx, nl.GetLivenessView(), avoidNodeStates)
+		}
+	}
 
 	ss, err := execCfg.NodesStatusServer.OptionalNodesStatusServer(47900)
 	if err != nil {
@@ -89,6 +106,30 @@ func (dsp *DistSQLPlanner) setupAllNodesPlanningSystem(
 	return planCtx, nodes, nil
 }
 
+// avoidedInstances returns the SQL instances, other than the gateway, hosted on
+// the KV nodes that are in any of the given lifecycle states as of the given
+// liveness view.
+func (dsp *DistSQLPlanner) avoidedInstances(
+	ctx context.Context, view livenesspb.LivenessView, states []livenesspb.NodeLifecycleState,
+) map[base.SQLInstanceID]struct{} {
+	avoided := make(map[base.SQLInstanceID]struct{})
+	for nodeID := range view.IsLiveMap {
+		sqlInstanceID := instanceIDForKVNodeHostedInstance(nodeID)
+		if sqlInstanceID == dsp.gatewaySQLInstanceID {
+			continue
+		}
+		state := view.LifecycleState(nodeID)
+		for _, s := range states {
+			if state == s {
+				log.VEventf(ctx, 1, "avoiding n%d for this plan since it is %s", nodeID, state)
+				avoided[sqlInstanceID] = struct{}{}
+				break
+			}
+		}
+	}
+	return avoided
+}
+
 // setupAllNodesPlanningTenant creates a planCtx and returns all nodes available
 // in a non-system tenant, filtered using the passed locality filter if is
 // non-empty.
@@ -11,11 +11,17 @@
 package sql
 
 import (
+	"context"
 	"math"
 	"testing"
+	"time"
 
+	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/sql/execinfrapb"
 	"github.com/cockroachdb/cockroach/pkg/sql/physicalplan"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
 	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
 	"github.com/stretchr/testify/require"
 )
@@ -83,3 +89,44 @@ func TestCalculatePlanGrowth(t *testing.T) {
 		})
 	}
 }
+
+func TestAvoidedInstances(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+
+	ctx := context.Background()
+	now := hlc.Timestamp{WallTime: int64(time.Hour)}
+	live := hlc.LegacyTimestamp{WallTime: now.WallTime + int64(time.Second)}
+	entry := func(nodeID roachpb.NodeID, l livenesspb.Liveness) livenesspb.IsLiveMapEntry {
+		l.NodeID, l.Epoch, l.Membership = nodeID, 1, livenesspb.MembershipStatus_ACTIVE
+		return livenesspb.IsLiveMapEntry{Liveness: l, IsLive: l.IsLive(now)}
+	}
+	view := livenesspb.LivenessView{
+		IsLiveMap: livenesspb.IsLiveMap{
+			1: entry(1, livenesspb.Liveness{Expiration: live, Draining: true}),
+			// The gateway is never avoided.
+			2: entry(2, livenesspb.Liveness{Expiration: live, Draining: true}),
+			3: entry(3, livenesspb.Liveness{
+				Expiration:       live,
+				MaintenanceStart: now,
+				MaintenanceEnd:   now.Add(int64(time.Hour), 0),
+			}),
+			4: entry(4, livenesspb.Liveness{Expiration: live}),
+			5: entry(5, livenesspb.Liveness{Expiration: hlc.LegacyTimestamp{WallTime: 1}}),
+		},
+		Now:           now,
+		DeadThreshold: time.Minute,
+	}
+
+	dsp := DistSQLPlanner{gatewaySQLInstanceID: 2}
+	avoided := dsp.avoidedInstances(ctx, view, []livenesspb.NodeLifecycleState{
+		livenesspb.NodeLifecycleState_DRAINING, livenesspb.NodeLifecycleState_SUSPECT,
+	})
+	require.Equal(t, map[base.SQLInstanceID]struct{}{1: {}, 3: {}}, avoided)
+
+	// Avoided nodes aren't planned on, regardless of their health.
+	planCtx := &PlanningCtx{
+		avoidedInstances: avoided,
+		nodeStatuses:     make(map[base.SQLInstanceID]NodeStatus),
+	}
+	require.Equal(t, NodeAvoided, dsp.checkInstanceHealthAndVersionSystem(ctx, planCtx, 3))
+}
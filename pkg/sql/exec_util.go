@@ -1264,6 +1264,9 @@ type ExecutorConfig struct {
 	// available when not running as a system tenant.
 	SQLStatusServer    serverpb.SQLStatusServer
 	TenantStatusServer serverpb.TenantStatusServer
+	// NodeDecommissioner carries out node decommissions on behalf of
+	// decommission jobs. It is only set when running as the system tenant.
+	NodeDecommissioner serverpb.NodeDecommissioner
 	MetricsRecorder    nodeStatusGenerator
 	SessionRegistry    *SessionRegistry
 	ClosedSessionCache *ClosedSessionCache
@@ -11,6 +11,7 @@ func _() {
 	_ = x[NodeOK-0]
 	_ = x[NodeUnhealthy-1]
 	_ = x[NodeDistSQLVersionIncompatible-2]
+	_ = x[NodeAvoided-3]
 }
 
 func (i NodeStatus) String() string {
@@ -21,6 +22,8 @@ func (i NodeStatus) String() string {
 		return "NodeUnhealthy"
 	case NodeDistSQLVersionIncompatible:
 		return "NodeDistSQLVersionIncompatible"
+	case NodeAvoided:
+		return "NodeAvoided"
 	default:
 		return "NodeStatus(" + strconv.FormatInt(int64(i), 10) + ")"
 	}
@@ -12,6 +12,7 @@ package optionalnodeliveness
 
 import (
 	"context"
+	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness"
 	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
@@ -26,7 +27,10 @@ type Interface interface {
 	GetLivenessesFromKV(ctx context.Context) ([]livenesspb.Liveness, error)
 	IsAvailable(roachpb.NodeID) bool
 	IsAvailableNotDraining(roachpb.NodeID) bool
+	IsUnderMemoryPressure(roachpb.NodeID) bool
+	IsEligibleTarget(roachpb.NodeID, livenesspb.TargetIntent, time.Duration) bool
 	IsLive(roachpb.NodeID) (bool, error)
+	GetLivenessView() livenesspb.LivenessView
 }
 
 // Container optionally gives access to liveness information about
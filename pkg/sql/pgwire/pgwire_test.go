@@ -29,6 +29,9 @@ import (
 	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/base"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness"
+	"github.com/cockroachdb/cockroach/pkg/kv/kvserver/liveness/livenesspb"
+	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/security/username"
 	"github.com/cockroachdb/cockroach/pkg/server"
 	"github.com/cockroachdb/cockroach/pkg/server/telemetry"
@@ -138,6 +141,77 @@ func TestPGWireDrainClient(t *testing.T) {
 	}
 }
 
+// TestPGWireDecommissioningNode checks that, depending on
+// server.decommissioning.sql_connections, a node being decommissioned accepts
+// new connections, rejects them, or rejects them with a hint to reconnect to
+// server.decommissioning.sql_redirect_address.
+func TestPGWireDecommissioningNode(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	defer log.Scope(t).Close(t)
+
+	ctx := context.Background()
+	tc := serverutils.StartNewTestCluster(t, 3, base.TestClusterArgs{})
+	defer tc.Stopper().Stop(ctx)
+
+	decomNode := tc.Server(2)
+	require.NoError(t, tc.Server(0).Decommission(
+		ctx, livenesspb.MembershipStatus_DECOMMISSIONING, []roachpb.NodeID{decomNode.NodeID()}))
+	testutils.SucceedsSoon(t, func() error {
+		self, ok := decomNode.NodeLiveness().(*liveness.NodeLiveness).Self()
+		if !ok || !self.Membership.Decommissioning() {
+			return errors.New("node not yet decommissioning")
+		}
+		return nil
+	})
+
+	pgURL, cleanupFn := sqlutils.PGUrl(
+		t, decomNode.ServingSQLAddr(), t.Name(), url.User(username.RootUser))
+	defer cleanupFn()
+	sqlDB := sqlutils.MakeSQLRunner(tc.ServerConn(0))
+
+	// By default, new connections are accepted.
+	require.NoError(t, trivialQuery(pgURL))
+
+	sqlDB.Exec(t, `SET CLUSTER SETTING server.decommissioning.sql_connections = 'reject'`)
+	testutils.SucceedsSoon(t, func() error {
+		err := trivialQuery(pgURL)
+		var pqErr *pq.Error
+		if !errors.As(err, &pqErr) {
+			return errors.Newf("expected connection to be rejected, got %v", err)
+		}
+		require.Equal(t, pgcode.CannotConnectNow.String(), string(pqErr.Code))
+		require.Equal(t, pgwire.ErrDecommissioningNewConn, pqErr.Message)
+		require.Empty(t, pqErr.Hint)
+		return nil
+	})
+
+	// Connections are only redirected to the configured address, since the
+	// hint is sent before the client is authenticated.
+	sqlDB.Exec(t, `SET CLUSTER SETTING server.decommissioning.sql_connections = 'redirect'`)
+	sqlDB.Exec(t, `SET CLUSTER SETTING server.decommissioning.sql_redirect_address = 'lb.example.com:26257'`)
+	testutils.SucceedsSoon(t, func() error {
+		err := trivialQuery(pgURL)
+		var pqErr *pq.Error
+		if !errors.As(err, &pqErr) || pqErr.Hint == "" {
+			return errors.Newf("expected connection to be redirected, got %v", err)
+		}
+		require.Equal(t, pgcode.CannotConnectNow.String(), string(pqErr.Code))
+		require.Equal(t, "connect to lb.example.com:26257", pqErr.Hint)
+		return nil
+	})
+
+	// Nodes that aren't being decommissioned are unaffected.
+	otherURL, cleanupFn := sqlutils.PGUrl(
+		t, tc.Server(0).ServingSQLAddr(), t.Name(), url.User(username.RootUser))
+	defer cleanupFn()
+	require.NoError(t, trivialQuery(otherURL))
+
+	sqlDB.Exec(t, `SET CLUSTER SETTING server.decommissioning.sql_connections = 'accept'`)
+	testutils.SucceedsSoon(t, func() error {
+		return trivialQuery(pgURL)
+	})
+}
+
 // TestPGWireDrainOngoingTxns tests that connections with open transactions are
 // canceled when they go on for too long.
 func TestPGWireDrainOngoingTxns(t *testing.T) {
@@ -119,6 +119,43 @@ var maxNumNonRootConnectionsReason = settings.RegisterStringSetting(
 	"",
 )
 
+// The possible values of server.decommissioning.sql_connections.
+const (
+	decommissioningConnsAccept int64 = iota
+	decommissioningConnsReject
+	decommissioningConnsRedirect
+)
+
+// decommissioningConns controls the handling of new SQL connections by a node
+// being decommissioned.
+var decommissioningConns = settings.RegisterEnumSetting(
+	settings.SystemOnly,
+	"server.decommissioning.sql_connections",
+	"the handling of new SQL connections by a node being decommissioned: "+
+		"accept them; reject them with error code 57P03 (cannot_connect_now); or "+
+		"redirect them, i.e. reject them with a hint to reconnect to "+
+		"server.decommissioning.sql_redirect_address",
+	"accept",
+	map[int64]string{
+		decommissioningConnsAccept:   "accept",
+		decommissioningConnsReject:   "reject",
+		decommissioningConnsRedirect: "redirect",
+	},
+).WithPublic()
+
+// decommissioningRedirectAddress is the address new SQL connections to a node
+// being decommissioned are redirected to. The hint is sent before the client
+// is authenticated, so it only ever discloses this address, and not the
+// addresses of the other nodes.
+var decommissioningRedirectAddress = settings.RegisterStringSetting(
+	settings.SystemOnly,
+	"server.decommissioning.sql_redirect_address",
+	"the address clients connecting to a node being decommissioned are told to reconnect to "+
+		"(e.g. that of a load balancer), when server.decommissioning.sql_connections is set "+
+		"to redirect; if empty, their connections are rejected without hint",
+	"",
+).WithPublic()
+
 const (
 	// ErrSSLRequired is returned when a client attempts to connect to a
 	// secure server in cleartext.
@@ -130,6 +167,10 @@ const (
 	// ErrDrainingExistingConn is returned when a connection is shut down because
 	// the server is draining.
 	ErrDrainingExistingConn = "server is shutting down"
+	// ErrDecommissioningNewConn is returned when a client attempts to connect
+	// to a node being decommissioned, if server.decommissioning.sql_connections
+	// is set to reject or redirect them.
+	ErrDecommissioningNewConn = "node is being decommissioned, try another node"
 )
 
 // Fully-qualified names for metrics.
@@ -781,6 +822,10 @@ func (s *Server) ServeConn(
 		log.Ops.Info(ctx, "rejecting new connection while server is draining")
 		return s.sendErr(ctx, st, conn, newAdminShutdownErr(ErrDrainingNewConn))
 	}
+	if err := s.checkDecommissioning(); err != nil {
+		log.Ops.Info(ctx, "rejecting new connection while node is being decommissioned")
+		return s.sendErr(ctx, st, conn, err)
+	}
 
 	sArgs, err := finalizeClientParameters(ctx, preServeStatus.clientParameters, &st.SV)
 	if err != nil {
@@ -942,6 +987,35 @@ func (s *Server) registerConn(
 	return
 }
 
+// checkDecommissioning returns an error if this node is being decommissioned
+// and server.decommissioning.sql_connections is set to reject new connections.
+// If it's set to redirect them, the error carries a hint to reconnect to
+// server.decommissioning.sql_redirect_address.
+func (s *Server) checkDecommissioning() error {
+	mode := decommissioningConns.Get(&s.execCfg.Settings.SV)
+	if mode == decommissioningConnsAccept {
+		return nil
+	}
+	// Only KV nodes get decommissioned.
+	nl, ok := s.execCfg.NodeLiveness.Optional(47900)
+	if !ok {
+		return nil
+	}
+	self, ok := nl.Self()
+	if !ok || !self.Membership.Decommissioning() {
+		return nil
+	}
+	err := pgerror.New(pgcode.CannotConnectNow, ErrDecommissioningNewConn)
+	if mode != decommissioningConnsRedirect {
+		return err
+	}
+	addr := decommissioningRedirectAddress.Get(&s.execCfg.Settings.SV)
+	if addr == "" {
+		return err
+	}
+	return errors.WithHintf(err, "connect to %s", addr)
+}
+
 // sendErr sends errors to the client during the connection startup
 // sequence. Later error sends during/after authentication are handled
 // in conn.go.
@@ -1537,7 +1537,7 @@ func (tc *TestCluster) WaitForNodeLiveness(t testing.TB) {
 			if err := db.GetProto(context.Background(), key, &liveness); err != nil {
 				return err
 			}
-			if (liveness == livenesspb.Liveness{}) {
+			if liveness.Equal(livenesspb.Liveness{}) {
 				return fmt.Errorf("no liveness record")
 			}
 			fmt.Printf("n%d: found liveness\n", s.NodeID())
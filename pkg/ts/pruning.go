@@ -147,3 +147,54 @@ func (tsdb *DB) pruneTimeSeries(
 
 	return db.Run(ctx, b)
 }
+
+// pruneSourceSeriesBatchSize is the number of keys deleted per batch by
+// PruneSourceSeries.
+const pruneSourceSeriesBatchSize = 1000
+
+// PruneSourceSeries deletes all the data recorded by the given sources for the
+// given time series, up to the given timestamp. The series are supplied by
+// source. This is meant for sources that will never record data again, e.g.
+// the node and stores of a decommissioned node, whose series would otherwise
+// linger until they age out.
+//
+// As the source is the last component of time series keys, the data of a
+// source can't be deleted by range: every time slot of the series is deleted
+// individually, back to the pruning threshold of its resolution, since older
+// data is pruned by the time series maintenance queue anyway. As range
+// deletion of inline data is idempotent, it is safe to run this operation
+// repeatedly.
+func (tsdb *DB) PruneSourceSeries(
+	ctx context.Context, db *kv.DB, series map[string][]string, until int64,
+) error {
+	b := &kv.Batch{}
+	var pending int
+	for _, r := range []Resolution{Resolution10s, Resolution30m} {
+		slab := r.SlabDuration()
+		start := until - tsdb.PruneThreshold(r)
+		for timestamp := start - start%slab; timestamp <= until; timestamp += slab {
+			for source, names := range series {
+				for _, name := range names {
+					key := MakeDataKey(name, source, r, timestamp)
+					b.AddRawRequest(&kvpb.DeleteRangeRequest{
+						RequestHeader: kvpb.RequestHeader{
+							Key:    key,
+							EndKey: key.Next(),
+						},
+						Inline: true,
+					})
+					if pending++; pending == pruneSourceSeriesBatchSize {
+						if err := db.Run(ctx, b); err != nil {
+							return err
+						}
+						b, pending = &kv.Batch{}, 0
+					}
+				}
+			}
+		}
+	}
+	if pending == 0 {
+		return nil
+	}
+	return db.Run(ctx, b)
+}
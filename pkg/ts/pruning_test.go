@@ -11,12 +11,14 @@
 package ts
 
 import (
+	"context"
 	"reflect"
 	"testing"
 	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/keys"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
+	"github.com/cockroachdb/cockroach/pkg/ts/testmodel"
 	"github.com/cockroachdb/cockroach/pkg/ts/tspb"
 	"github.com/cockroachdb/cockroach/pkg/util/hlc"
 	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
@@ -385,6 +387,55 @@ func TestPruneTimeSeries(t *testing.T) {
 	})
 }
 
+func TestPruneSourceSeries(t *testing.T) {
+	defer leaktest.AfterTest(t)()
+	runTestCaseMultipleFormats(t, func(t *testing.T, tm testModelRunner) {
+		// Arbitrary timestamp
+		var now int64 = 1475700000 * 1e9
+
+		// Populate data: two metrics, two sources, two keys.
+		metrics := []string{"metric.a", "metric.z"}
+		sources := []string{"source1", "source2"}
+		for _, metric := range metrics {
+			for _, source := range sources {
+				tm.storeTimeSeriesData(Resolution10s, []tspb.TimeSeriesData{
+					{
+						Name:   metric,
+						Source: source,
+						Datapoints: []tspb.TimeSeriesDatapoint{
+							{
+								TimestampNanos: now - int64(24*time.Hour),
+								Value:          2,
+							},
+							{
+								TimestampNanos: now,
+								Value:          1,
+							},
+						},
+					},
+				})
+			}
+		}
+		tm.assertKeyCount(8)
+
+		// Only the data of the given sources is deleted, and only for the given
+		// metrics.
+		if err := tm.DB.PruneSourceSeries(context.Background(), tm.LocalTestCluster.DB,
+			map[string][]string{"source1": metrics, "source2": {"metric.notexists"}}, now,
+		); err != nil {
+			t.Fatal(err)
+		}
+		tm.assertKeyCount(4)
+		for _, metric := range metrics {
+			tm.model.VisitSeries(resolutionModelKey(metric, Resolution10s),
+				func(name, source string, data testmodel.DataSeries) (testmodel.DataSeries, bool) {
+					return nil, source == "source1"
+				})
+		}
+		tm.assertModelCorrect()
+	})
+}
+
 func TestMaintainTimeSeriesWithRollups(t *testing.T) {
 	defer leaktest.AfterTest(t)()
 	tm := newTestModelRunner(t)
@@ -206,6 +206,11 @@ export const typeOptions = [
     name: "Time-to-live Deletions",
     key: jobTypeKeys[JobType.ROW_LEVEL_TTL],
   },
+  {
+    value: JobType.DECOMMISSION.toString(),
+    name: "Node Decommissions",
+    key: jobTypeKeys[JobType.DECOMMISSION],
+  },
 ];
 
 export function isValidJobType(jobType: number): boolean {
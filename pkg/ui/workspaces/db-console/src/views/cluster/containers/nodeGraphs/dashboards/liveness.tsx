@@ -0,0 +1,94 @@
+// Copyright 2023 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+import React from "react";
+import _ from "lodash";
+
+import LineGraph from "src/views/cluster/components/linegraph";
+import { Metric, Axis } from "src/views/shared/components/metricQuery";
+import { AxisUnits } from "@cockroachlabs/cluster-ui";
+
+import { GraphDashboardProps, nodeDisplayName } from "./dashboardUtils";
+
+export default function (props: GraphDashboardProps) {
+  const { nodeIDs, nodeSources, nodeDisplayNameByID, tenantSource } = props;
+
+  return [
+    <LineGraph
+      title="Liveness Range Heartbeat Rate"
+      sources={nodeSources}
+      tenantSource={tenantSource}
+      tooltip={`The rate of heartbeats of all nodes to the node liveness range, as observed
+                through gossip. Every node observes all heartbeats, so the maximum across
+                nodes is displayed.`}
+    >
+      <Axis label="heartbeats per second">
+        <Metric
+          name="cr.node.liveness.range.heartbeat_rate"
+          title="Heartbeats"
+          aggregateMax
+        />
+      </Axis>
+    </LineGraph>,
+
+    <LineGraph
+      title="Liveness Range Utilization"
+      tenantSource={tenantSource}
+      tooltip={`The estimated utilization of the node liveness range, relative to
+                kv.liveness.range_capacity and to the time nodes have to renew their
+                liveness. Once it reaches kv.liveness.hotspot.utilization_threshold, the
+                range is considered hot and nodes spread out their heartbeats.
+                Values are displayed individually for each node.`}
+    >
+      <Axis units={AxisUnits.Percentage} label="utilization">
+        {_.map(nodeIDs, node => (
+          <Metric
+            key={node}
+            name="cr.node.liveness.range.utilization"
+            title={nodeDisplayName(nodeDisplayNameByID, node)}
+            sources={[node]}
+            downsampleMax
+          />
+        ))}
+      </Axis>
+    </LineGraph>,
+
+    <LineGraph
+      title="Nodes Seeing a Hot Liveness Range"
+      sources={nodeSources}
+      tenantSource={tenantSource}
+      tooltip={`The number of nodes that consider the node liveness range hot, and have
+                extended their liveness records by kv.liveness.hotspot.ttl_multiplier.`}
+    >
+      <Axis label="nodes">
+        <Metric name="cr.node.liveness.range.hot" title="Nodes" />
+      </Axis>
+    </LineGraph>,
+
+    <LineGraph
+      title="Node Heartbeat Latency: 99th percentile"
+      tenantSource={tenantSource}
+      tooltip={`The 99th percentile of latency to heartbeat a node's internal liveness record over a 1 minute period.
+                              Values are displayed individually for each node.`}
+    >
+      <Axis units={AxisUnits.Duration} label="heartbeat latency">
+        {_.map(nodeIDs, node => (
+          <Metric
+            key={node}
+            name="cr.node.liveness.heartbeatlatency-p99"
+            title={nodeDisplayName(nodeDisplayNameByID, node)}
+            sources={[node]}
+            downsampleMax
+          />
+        ))}
+      </Axis>
+    </LineGraph>,
+  ];
+}
@@ -70,6 +70,7 @@ import changefeedsDashboard from "./dashboards/changefeeds";
 import overloadDashboard from "./dashboards/overload";
 import ttlDashboard from "./dashboards/ttl";
 import crossClusterReplicationDashboard from "./dashboards/crossClusterReplication";
+import livenessDashboard from "./dashboards/liveness";
 import { getMatchParamByName } from "src/util/query";
 import { PayloadAction } from "src/interfaces/action";
 import {
@@ -148,6 +149,11 @@ const dashboards: { [key: string]: GraphDashboard } = {
     isKvDashboard: true,
   },
   ttl: { label: "TTL", component: ttlDashboard, isKvDashboard: false },
+  liveness: {
+    label: "Liveness",
+    component: livenessDashboard,
+    isKvDashboard: true,
+  },
   crossClusterReplication: {
     label: "Cross-Cluster Replication",
     component: crossClusterReplicationDashboard,
@@ -82,6 +82,17 @@ type PrometheusIterable interface {
 	Each([]*prometheusgo.LabelPair, func(metric *prometheusgo.Metric))
 }
 
+// PrometheusEssentialIterable is a PrometheusIterable whose children are
+// exported to prometheus even when children metrics aren't in general (see
+// server.child_metrics.enabled). This is meant for metrics whose children are
+// few and bounded in number, and carry the information the metric is about.
+type PrometheusEssentialIterable interface {
+	PrometheusIterable
+
+	// EssentialChildren marks the metric as such.
+	EssentialChildren()
+}
+
 // WindowedHistogram represents a histogram with data over recent window of
 // time. It's used primarily to record histogram data into CRDB's internal
 // time-series database, which does not know how to encode cumulative
@@ -94,7 +94,10 @@ func (pm *PrometheusExporter) ScrapeRegistry(registry *Registry, includeChildMet
 		// Deal with metrics which have children which are exposed to
 		// prometheus if we should.
 		promIter, ok := v.(PrometheusIterable)
-		if !ok || !includeChildMetrics {
+		if !ok {
+			return
+		}
+		if _, essential := v.(PrometheusEssentialIterable); !essential && !includeChildMetrics {
 			return
 		}
 		promIter.Each(m.Label, func(metric *prometheusgo.Metric) {
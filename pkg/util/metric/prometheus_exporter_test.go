@@ -15,6 +15,8 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/gogo/protobuf/proto"
+	prometheusgo "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/require"
 )
 
@@ -131,3 +133,32 @@ func TestPrometheusExporter(t *testing.T) {
 	require.Regexp(t, "shared_counter{counter=\"one\"}", output)
 	require.Len(t, strings.Split(output, "\n"), 7)
 }
+
+// essentialGauge is a gauge with a single child, which is essential.
+type essentialGauge struct {
+	*Gauge
+}
+
+func (g essentialGauge) Inspect(f func(interface{})) { f(g) }
+
+func (g essentialGauge) Each(labels []*prometheusgo.LabelPair, f func(*prometheusgo.Metric)) {
+	f(&prometheusgo.Metric{
+		Label: append(labels, &prometheusgo.LabelPair{Name: proto.String("child"), Value: proto.String("a")}),
+		Gauge: &prometheusgo.Gauge{Value: proto.Float64(float64(g.Value()))},
+	})
+}
+
+func (g essentialGauge) EssentialChildren() {}
+
+func TestPrometheusExporterEssentialChildren(t *testing.T) {
+	r := NewRegistry()
+	r.AddMetric(essentialGauge{NewGauge(Metadata{Name: "essential.gauge"})})
+
+	// The children are exported even when children metrics aren't.
+	pe := MakePrometheusExporter()
+	pe.ScrapeRegistry(r, false /* includeChildMetrics */)
+	family, ok := pe.families["essential_gauge"]
+	require.True(t, ok)
+	require.Len(t, family.Metric, 2)
+	require.Equal(t, "child", family.Metric[1].Label[0].GetName())
+}